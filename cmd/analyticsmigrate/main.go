@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/alexnthnz/url-shortener/internal/config"
+	"github.com/alexnthnz/url-shortener/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+func main() {
+	cfg := config.Load()
+
+	backfill := flag.Bool("backfill", false, "copy historical clicks from the primary database to the mirror")
+	verify := flag.Bool("verify", false, "compare daily click counts between the primary database and the mirror")
+	afterID := flag.Int64("after-id", 0, "resume the backfill after this click id")
+	batchSize := flag.Int("batch-size", 1000, "clicks copied per batch")
+	pause := flag.Duration("pause", 100*time.Millisecond, "pause between backfill batches")
+	days := flag.Int("days", 30, "number of days compared by -verify")
+	flag.Parse()
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.InfoLevel)
+
+	if !*backfill && !*verify {
+		logger.Fatal("Nothing to do: pass -backfill, -verify or both")
+	}
+	if cfg.AnalyticsMirrorDatabaseURL == "" {
+		logger.Fatal("ANALYTICS_MIRROR_DATABASE_URL is not set")
+	}
+
+	primaryDB, err := repository.NewPostgresDB(cfg.DatabaseURL, repository.ToolPool(), nil)
+	if err != nil {
+		logger.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer primaryDB.Close()
+
+	mirrorDB, err := repository.NewPostgresDB(cfg.AnalyticsMirrorDatabaseURL, repository.ToolPool(), nil)
+	if err != nil {
+		logger.Fatalf("Failed to connect to analytics mirror database: %v", err)
+	}
+	defer mirrorDB.Close()
+
+	if err := repository.RunAnalyticsMirrorMigrations(mirrorDB, cfg.MigrationLockTimeout); err != nil {
+		logger.Fatalf("Failed to run analytics mirror migrations: %v", err)
+	}
+
+	// Both databases share the data keys stored in the primary
+	piiCipher, err := repository.NewPIICipherFromConfig(primaryDB, cfg.PIIEncryptionKeys, cfg.PIIEncryptionActiveKey, cfg.PIIBlindIndexKey)
+	if err != nil {
+		logger.Fatalf("Failed to initialize PII encryption: %v", err)
+	}
+
+	// Backfill batches and verification scans run without the server's query timeout
+	primary := repository.NewAnalyticsRepository(primaryDB, piiCipher, 0)
+	mirror := repository.NewAnalyticsRepository(mirrorDB, piiCipher, 0)
+	ctx := context.Background()
+
+	if *backfill {
+		if err := runBackfill(ctx, primary, mirror, *afterID, *batchSize, *pause, logger); err != nil {
+			logger.Fatalf("Backfill failed: %v", err)
+		}
+	}
+
+	if *verify {
+		mismatches, err := runVerify(ctx, primary, mirror, *days)
+		if err != nil {
+			logger.Fatalf("Verification failed: %v", err)
+		}
+		if mismatches > 0 {
+			os.Exit(1)
+		}
+	}
+}
+
+// runBackfill copies every click up to the newest one present when it starts. Copies keep
+// their primary ids and skip rows the double-write already delivered, so the backfill can
+// run while the server is double-writing and be resumed with -after-id after an interruption.
+func runBackfill(ctx context.Context, primary, mirror repository.AnalyticsStore, afterID int64, batchSize int, pause time.Duration, logger *logrus.Logger) error {
+	maxID, err := primary.MaxClickID(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read newest click id: %w", err)
+	}
+
+	var copied, skipped int64
+	for afterID < maxID {
+		clicks, err := primary.ListClicksAfter(ctx, afterID, maxID, batchSize)
+		if err != nil {
+			return fmt.Errorf("failed to read clicks after id %d: %w", afterID, err)
+		}
+		if len(clicks) == 0 {
+			break
+		}
+
+		inserted, err := mirror.CopyClicks(ctx, clicks)
+		if err != nil {
+			return fmt.Errorf("failed to copy clicks after id %d (resume with -after-id %d): %w", afterID, afterID, err)
+		}
+		copied += inserted
+		skipped += int64(len(clicks)) - inserted
+		afterID = clicks[len(clicks)-1].ID
+
+		logger.Infof("Backfilled up to click id %d of %d (%d copied, %d already present)", afterID, maxID, copied, skipped)
+		time.Sleep(pause)
+	}
+
+	logger.Infof("Backfill complete: %d copied, %d already present", copied, skipped)
+	return nil
+}
+
+// runVerify prints daily click counts that differ between the two databases and returns
+// how many days disagree. The current day may lag by one analytics flush interval.
+func runVerify(ctx context.Context, primary, mirror repository.AnalyticsStore, days int) (int, error) {
+	since := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -days)
+
+	primaryDays, err := primary.GetDailyRedirects(ctx, since)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count primary clicks: %w", err)
+	}
+	mirrorDays, err := mirror.GetDailyRedirects(ctx, since)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count mirror clicks: %w", err)
+	}
+
+	counts := make(map[string][2]int64)
+	for _, day := range primaryDays {
+		key := day.Day.Format("2006-01-02")
+		c := counts[key]
+		c[0] = day.Count
+		counts[key] = c
+	}
+	for _, day := range mirrorDays {
+		key := day.Day.Format("2006-01-02")
+		c := counts[key]
+		c[1] = day.Count
+		counts[key] = c
+	}
+
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	mismatches := 0
+	for _, key := range keys {
+		c := counts[key]
+		if c[0] != c[1] {
+			fmt.Printf("%s primary=%d mirror=%d diff=%d\n", key, c[0], c[1], c[1]-c[0])
+			mismatches++
+		}
+	}
+
+	if mismatches == 0 {
+		fmt.Printf("Primary and mirror agree for the last %d days\n", days)
+	}
+	return mismatches, nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/alexnthnz/url-shortener/internal/config"
+	"github.com/alexnthnz/url-shortener/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+func main() {
+	analyze := flag.Bool("analyze", false, "run EXPLAIN ANALYZE (executes the read-only queries) to include timings")
+	flag.Parse()
+
+	cfg := config.Load()
+	logger := logrus.New()
+
+	db, err := repository.NewPostgresDB(cfg.DatabaseURL, repository.ToolPool(), nil)
+	if err != nil {
+		logger.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	advice, err := repository.AdviseIndexes(db, *analyze)
+	if err != nil {
+		logger.Fatalf("Index advisor failed: %v", err)
+	}
+
+	problems := 0
+	for _, query := range advice.Queries {
+		fmt.Printf("%-22s cost=%.2f", query.Name, query.TotalCost)
+		if *analyze {
+			fmt.Printf(" time=%.2fms", query.ExecutionTime)
+		}
+		if len(query.IndexesUsed) > 0 {
+			fmt.Printf(" indexes=%s", strings.Join(query.IndexesUsed, ","))
+		}
+		fmt.Println()
+
+		for _, scan := range query.SeqScans {
+			status := "ok, small table"
+			if scan.Flagged {
+				status = "SEQ SCAN on large table, consider an index"
+				problems++
+			}
+			fmt.Printf("    seq scan on %s (~%d rows): %s\n", scan.Relation, scan.TableRows, status)
+		}
+	}
+
+	for _, index := range advice.MissingIndexes {
+		fmt.Printf("MISSING index %s (declared in migrations)\n", index)
+		problems++
+	}
+	for _, index := range advice.InvalidIndexes {
+		fmt.Printf("INVALID index %s (failed concurrent build, drop and re-run migrations)\n", index)
+		problems++
+	}
+
+	if problems > 0 {
+		fmt.Printf("\n%d problem(s) found\n", problems)
+		os.Exit(1)
+	}
+	fmt.Println("\nNo index problems found")
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/alexnthnz/url-shortener/internal/config"
+	"github.com/alexnthnz/url-shortener/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+func main() {
+	cfg := config.Load()
+
+	check := flag.Bool("check", false, "report pending migrations that would take long locks, without applying them")
+	lockTimeout := flag.Duration("lock-timeout", cfg.MigrationLockTimeout, "lock_timeout enforced on every migration statement")
+	flag.Parse()
+
+	logger := logrus.New()
+	logger.SetLevel(logrus.InfoLevel)
+
+	db, err := repository.NewPostgresDB(cfg.DatabaseURL, repository.ToolPool(), nil)
+	if err != nil {
+		logger.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	if *check {
+		os.Exit(preflight(db, logger))
+	}
+
+	if err := repository.RunMigrations(db, *lockTimeout); err != nil {
+		logger.Fatalf("Failed to run migrations: %v", err)
+	}
+	logger.Info("Migrations applied")
+}
+
+// preflight prints findings for pending migrations and returns the process exit code
+func preflight(db *sql.DB, logger *logrus.Logger) int {
+	pending, findings, err := repository.CheckMigrations(db)
+	if err != nil {
+		logger.Errorf("Pre-flight check failed: %v", err)
+		return 2
+	}
+
+	fmt.Printf("%d pending migration(s)\n", pending)
+
+	exitCode := 0
+	for _, finding := range findings {
+		fmt.Printf("[%s] %s\n    %s\n", finding.Severity, finding.Message, finding.Statement)
+		if finding.Severity == repository.SeverityError {
+			exitCode = 1
+		}
+	}
+
+	if len(findings) == 0 {
+		fmt.Println("No unsafe operations found")
+	}
+	return exitCode
+}
@@ -8,8 +8,12 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/alexnthnz/url-shortener/internal/accesslog"
+	"github.com/alexnthnz/url-shortener/internal/apidocs"
+	"github.com/alexnthnz/url-shortener/internal/buildinfo"
 	"github.com/alexnthnz/url-shortener/internal/config"
 	"github.com/alexnthnz/url-shortener/internal/handlers"
+	"github.com/alexnthnz/url-shortener/internal/mtls"
 	"github.com/alexnthnz/url-shortener/internal/repository"
 	"github.com/alexnthnz/url-shortener/internal/services"
 	"github.com/gin-gonic/gin"
@@ -24,32 +28,337 @@ func main() {
 	logger := logrus.New()
 	logger.SetLevel(logrus.InfoLevel)
 
+	// Fault injectors stay nil unless enabled outside production
+	var postgresFaults, redisFaults *repository.FaultInjector
+	if cfg.FaultInjectionEnabled {
+		postgresFaults = repository.NewFaultInjector("postgres", repository.FaultConfig{
+			ErrorRate: cfg.FaultPostgresErrorRate,
+			DelayRate: cfg.FaultPostgresDelayRate,
+			Delay:     cfg.FaultPostgresDelay,
+		})
+		redisFaults = repository.NewFaultInjector("redis", repository.FaultConfig{
+			ErrorRate: cfg.FaultRedisErrorRate,
+			DelayRate: cfg.FaultRedisDelayRate,
+			Delay:     cfg.FaultRedisDelay,
+		})
+		logger.Warn("Fault injection is enabled; Redis and Postgres calls will be delayed or failed on purpose")
+	}
+
+	logPrivacy, err := services.NewLogPrivacy(cfg.LogShortCodes, cfg.LogHashKey)
+	if err != nil {
+		logger.Fatalf("Invalid log privacy settings: %v", err)
+	}
+
+	// Access logs may go to their own file or syslog for SIEM ingestion
+	accessLogger, accessLogCloser, err := accesslog.New(accesslog.Config{
+		Output:     cfg.AccessLogOutput,
+		File:       cfg.AccessLogFile,
+		MaxSizeMB:  cfg.AccessLogMaxSizeMB,
+		MaxAge:     cfg.AccessLogMaxAge,
+		MaxBackups: cfg.AccessLogMaxBackups,
+		SyslogAddr: cfg.AccessLogSyslogAddr,
+		SyslogTag:  cfg.AccessLogSyslogTag,
+	}, logger)
+	if err != nil {
+		logger.Fatalf("Failed to set up access log: %v", err)
+	}
+	defer accessLogCloser.Close()
+
+	// Subsystems register their health checks as they come up
+	healthService := services.NewHealthService(cfg.HealthCheckTimeout)
+
 	// Initialize database
-	db, err := repository.NewPostgresDB(cfg.DatabaseURL)
+	db, err := repository.NewPostgresDB(cfg.DatabaseURL, repository.PoolConfig{
+		MaxOpenConns:    cfg.DBMaxOpenConns,
+		MaxIdleConns:    cfg.DBMaxIdleConns,
+		ConnMaxLifetime: cfg.DBConnMaxLifetime,
+		ConnMaxIdleTime: cfg.DBConnMaxIdleTime,
+	}, postgresFaults)
 	if err != nil {
 		logger.Fatalf("Failed to connect to database: %v", err)
 	}
 	defer db.Close()
 
 	// Run migrations
-	if err := repository.RunMigrations(db); err != nil {
+	if err := repository.RunMigrations(db, cfg.MigrationLockTimeout); err != nil {
 		logger.Fatalf("Failed to run migrations: %v", err)
 	}
 
-	// Initialize Redis cache
-	cache := repository.NewRedisCache(cfg.RedisURL)
+	// Encrypt personal data in click analytics when a keyring is configured
+	piiCipher, err := repository.NewPIICipherFromConfig(db, cfg.PIIEncryptionKeys, cfg.PIIEncryptionActiveKey, cfg.PIIBlindIndexKey)
+	if err != nil {
+		logger.Fatalf("Failed to initialize PII encryption: %v", err)
+	}
+	if piiCipher != nil {
+		logger.Infof("Encrypting click personal data with data key %d", piiCipher.ActiveKeyID())
+	}
+
+	// Double-write clicks to the analytics mirror while a backend migration is in progress
+	var mirrorRepo repository.AnalyticsStore
+	if cfg.AnalyticsMirrorDatabaseURL != "" {
+		mirrorDB, err := repository.NewPostgresDB(cfg.AnalyticsMirrorDatabaseURL, repository.PoolConfig{
+			MaxOpenConns:    cfg.DBMaxOpenConns,
+			MaxIdleConns:    cfg.DBMaxIdleConns,
+			ConnMaxLifetime: cfg.DBConnMaxLifetime,
+			ConnMaxIdleTime: cfg.DBConnMaxIdleTime,
+		}, nil)
+		if err != nil {
+			logger.Fatalf("Failed to connect to analytics mirror database: %v", err)
+		}
+		defer mirrorDB.Close()
+
+		if err := repository.RunAnalyticsMirrorMigrations(mirrorDB, cfg.MigrationLockTimeout); err != nil {
+			logger.Fatalf("Failed to run analytics mirror migrations: %v", err)
+		}
+		mirrorRepo = repository.NewAnalyticsRepository(mirrorDB, piiCipher, cfg.DBQueryTimeout)
+		healthService.Register("analytics_mirror", services.CriticalityDegraded, mirrorDB.PingContext)
+		logger.Info("Double-writing analytics to the mirror database")
+	}
+
+	// Initialize cache
+	var cache repository.Cache
+	switch cfg.CacheBackend {
+	case "redis":
+		cache = repository.NewRedisCache(cfg.RedisURL, cfg.RedisTimeout, redisFaults)
+	case "layered":
+		cache = repository.NewLayeredCache(repository.NewRedisCache(cfg.RedisURL, cfg.RedisTimeout, redisFaults), cfg.CacheLocalEntries, cfg.CacheLocalTTL)
+		logger.Infof("Caching up to %d hot keys locally for %s in front of Redis", cfg.CacheLocalEntries, cfg.CacheLocalTTL)
+	case "memory":
+		cache = repository.NewMemoryCache(cfg.CacheLocalEntries)
+		logger.Warn("Using the in-memory cache: locks, counters and rate limits are not shared between instances")
+	default:
+		logger.Fatalf("Invalid cache backend %q: use redis, memory or layered", cfg.CacheBackend)
+	}
 	defer cache.Close()
 
+	// Boot is complete; start failing dependency calls if configured
+	postgresFaults.Arm()
+	redisFaults.Arm()
+
 	// Initialize repositories
-	urlRepo := repository.NewURLRepository(db)
-	analyticsRepo := repository.NewAnalyticsRepository(db)
+	urlRepo := repository.NewURLRepository(db, cfg.DBQueryTimeout)
+	aliasRepo := repository.NewAliasRepository(db, cfg.DBQueryTimeout)
+	analyticsRepo := repository.NewAnalyticsRepository(db, piiCipher, cfg.DBQueryTimeout)
+	webhookRepo := repository.NewWebhookRepository(db)
+	jobRepo := repository.NewJobRepository(db)
+	complianceRepo := repository.NewComplianceRepository(db)
+	rateLimitRepo := repository.NewRateLimitRepository(db)
+	signingKeyRepo := repository.NewSigningKeyRepository(db)
+	domainPolicyRepo := repository.NewDomainPolicyRepository(db)
+	takedownRepo := repository.NewTakedownRepository(db)
+	goalRepo := repository.NewGoalRepository(db)
+	reportRepo := repository.NewReportRepository(db)
+	eventDestinationRepo := repository.NewEventDestinationRepository(db)
+	aliasClaimRepo := repository.NewAliasClaimRepository(db)
+	domainRepo := repository.NewDomainRepository(db, cfg.DBQueryTimeout)
 
 	// Initialize services
-	urlService := services.NewURLService(urlRepo, cache, logger)
-	analyticsService := services.NewAnalyticsService(analyticsRepo, logger)
+	usageService := services.NewUsageService(urlRepo, analyticsRepo, cache, logger)
+	domainPolicyService := services.NewDomainPolicyService(domainPolicyRepo, urlRepo, logger)
+	jobService := services.NewJobService(jobRepo, cfg.PurgeBatchPause, cfg.JobStaleAfter, logger)
+	safeBrowsingService, err := services.NewSafeBrowsingService(services.SafeBrowsingConfig{
+		APIKey:         cfg.SafeBrowsingAPIKey,
+		Endpoint:       cfg.SafeBrowsingEndpoint,
+		Action:         cfg.SafeBrowsingAction,
+		CacheTTL:       cfg.SafeBrowsingCacheTTL,
+		RescanInterval: cfg.SafeBrowsingRescanInterval,
+		Version:        buildinfo.Version,
+	}, urlRepo, cache, jobService, logger)
+	if err != nil {
+		logger.Fatalf("Invalid Safe Browsing settings: %v", err)
+	}
+	randomCodeLength := 0
+	if cfg.ShortCodeRandom {
+		if err := services.ValidateRandomCodeLength(cfg.ShortCodeLength, cfg.ShortCodeChecksum); err != nil {
+			logger.Fatalf("Invalid short code settings: %v", err)
+		}
+		randomCodeLength = cfg.ShortCodeLength
+	}
+	wordlist, err := services.LoadWordlist(cfg.WordCodeList)
+	if err != nil {
+		logger.Fatalf("Invalid word code settings: %v", err)
+	}
+	wordCodes, err := services.NewWordCodeGenerator(wordlist, cfg.WordCodeWords, cfg.WordCodeDigits, cfg.WordCodeSeparator)
+	if err != nil {
+		logger.Fatalf("Invalid word code settings: %v", err)
+	}
+	var deterministicCodes *services.DeterministicCodeGenerator
+	if cfg.DeterministicCodeKey != "" {
+		deterministicCodes, err = services.NewDeterministicCodeGenerator(cfg.DeterministicCodeKey, cfg.DeterministicCodeLength, cfg.ShortCodeChecksum)
+		if err != nil {
+			logger.Fatalf("Invalid deterministic code settings: %v", err)
+		}
+	}
+	var streamPublisher services.StreamPublisher
+	switch cfg.EventStream {
+	case "":
+	case services.StreamKafka:
+		publisher, err := services.NewKafkaPublisher(services.KafkaConfig{
+			RESTURL:  cfg.KafkaRESTURL,
+			Username: cfg.KafkaRESTUsername,
+			Password: cfg.KafkaRESTPassword,
+		})
+		if err != nil {
+			logger.Fatalf("Invalid Kafka settings: %v", err)
+		}
+		streamPublisher = publisher
+	default:
+		logger.Fatalf("Invalid event stream %q: use kafka", cfg.EventStream)
+	}
+	eventStreamService := services.NewEventStreamService(streamPublisher, services.EventStreamConfig{
+		ClickTopic: cfg.StreamClickTopic,
+		LinkTopic:  cfg.StreamLinkTopic,
+	}, logger)
+	canaryService, err := services.NewCanaryService(cfg.CanaryPercent, cfg.CanaryFeatures)
+	if err != nil {
+		logger.Fatalf("Invalid canary settings: %v", err)
+	}
+	urlService := services.NewURLService(urlRepo, aliasRepo, cache, cfg.NotFoundCacheTTL, cfg.CacheEarlyRefreshBeta, usageService, cfg.ShortCodeChecksum, randomCodeLength, wordCodes, deterministicCodes, cfg.EmojiAliases, cfg.DeduplicateURLs, services.NormalizeOptions{
+		ForceHTTPS:         cfg.NormalizeForceHTTPS,
+		StripTrailingSlash: cfg.NormalizeStripTrailingSlash,
+		StripFragment:      cfg.NormalizeStripFragment,
+		LowercaseHost:      cfg.NormalizeLowercaseHost,
+		SortQuery:          cfg.NormalizeSortQuery,
+	}, cfg.BlockedDomains, domainPolicyService, safeBrowsingService, eventStreamService, canaryService, logger)
+	if cfg.LinkValidatorURL != "" {
+		urlService.RegisterValidator(services.NewWebhookValidator(cfg.LinkValidatorURL, cfg.LinkValidatorSecret, cfg.LinkValidatorTimeout, cfg.LinkValidatorFailOpen, logger))
+	}
+	webhookService := services.NewWebhookService(webhookRepo, urlRepo, logger)
+	trendingService := services.NewTrendingService(cache, cfg.TrendingHalfLife, cfg.TrendingMaxLinks, logger)
+	internalNetworks, err := services.ParseInternalNetworks(cfg.InternalNetworks)
+	if err != nil {
+		logger.Fatalf("Invalid internal networks: %v", err)
+	}
+	eventForwardingService := services.NewEventForwardingService(services.EventForwardingConfig{
+		SegmentURL:   cfg.SegmentAPIURL,
+		AmplitudeURL: cfg.AmplitudeAPIURL,
+	}, eventDestinationRepo, logger)
+	analyticsService := services.NewAnalyticsService(analyticsRepo, mirrorRepo, webhookService, trendingService, eventForwardingService, eventStreamService, internalNetworks, logPrivacy, logger)
+	widgetService := services.NewWidgetService(analyticsRepo, urlRepo, cfg.WidgetSigningKey, logger)
+	sloService := services.NewSLOService(cfg.SLOAvailabilityObjective, cfg.SLOLatencyObjective, cfg.SLOLatencyThreshold)
+	redirectAuditService := services.NewRedirectAuditService(cache, cfg.RedirectAuditPercent, cfg.RedirectAuditMaxEntries, logger)
+	takedownService := services.NewTakedownService(takedownRepo, urlService, webhookService, cfg.TakedownAutoDisable, logger)
+	goalService := services.NewGoalService(goalRepo, analyticsRepo, urlService, webhookService, cache, cfg.GoalCheckInterval, logger)
+	reportService, err := services.NewReportService(services.ReportConfig{
+		Periods:    cfg.ReportPeriods,
+		BrandName:  cfg.ReportBrandName,
+		BrandColor: cfg.ReportBrandColor,
+		LogoURL:    cfg.ReportLogoURL,
+	}, reportRepo, urlRepo, analyticsRepo, webhookService, cache, logger)
+	if err != nil {
+		logger.Fatalf("Invalid report settings: %v", err)
+	}
+	alertInstance := cfg.AlertInstance
+	if alertInstance == "" {
+		alertInstance, _ = os.Hostname()
+	}
+	alertService := services.NewAlertService(services.AlertConfig{
+		AlertmanagerURL:     cfg.AlertmanagerURL,
+		PagerDutyRoutingKey: cfg.PagerDutyRoutingKey,
+		PagerDutyURL:        cfg.PagerDutyEventsURL,
+		Instance:            alertInstance,
+		Interval:            cfg.AlertInterval,
+		Thresholds: services.AlertThresholds{
+			ErrorRate:      cfg.AlertErrorRate,
+			QueueDepth:     cfg.AlertQueueDepth,
+			CacheHitRatio:  cfg.AlertCacheHitRatio,
+			ReplicationLag: cfg.AlertReplicationLag,
+		},
+	}, sloService, usageService, analyticsService, webhookService, db, logger)
+	safeBrowsingService.SetTakedownService(takedownService)
+	complianceService := services.NewComplianceService(complianceRepo, cfg.ComplianceSensitiveDomains, logger)
+	maintenanceService := services.NewMaintenanceService(cache, cfg.ReadOnlyMode, cfg.MaintenanceRetryAfter, logger)
+	privacyService := services.NewPrivacyService(analyticsRepo, mirrorRepo, logger)
+	retentionService := services.NewRetentionService(analyticsRepo, jobService, cache, cfg.AnalyticsRetentionDays, cfg.PurgeBatchSize, logger)
+	encryptionService := services.NewEncryptionService(piiCipher, analyticsRepo, mirrorRepo, jobService, cfg.PurgeBatchSize, logger)
+	clientBackfillService := services.NewClientBackfillService(analyticsRepo, mirrorRepo, jobService, cfg.PurgeBatchSize, logger)
+
+	// Every job type is registered by now, so jobs cut off by a restart can continue
+	jobService.ResumeInterrupted()
+
+	rateLimitService := services.NewRateLimitService(rateLimitRepo, cache, map[string]int{
+		services.RateLimitTierDefault:  cfg.RateLimitDefault,
+		services.RateLimitTierShorten:  cfg.RateLimitShorten,
+		services.RateLimitTierRedirect: cfg.RateLimitRedirect,
+		services.RateLimitTierStats:    cfg.RateLimitStats,
+	}, cfg.RateLimitWindow, logger)
+	aliasClaimService := services.NewAliasClaimService(aliasClaimRepo, cache, cfg.AliasClaimIPLimit, cfg.AliasClaimKeyLimit, cfg.AliasClaimCooldown, logger)
+	idempotencyService := services.NewIdempotencyService(cache, cfg.IdempotencyKeyTTL, logger)
+	loginThrottle := services.NewLoginThrottle(cache, services.LoginThrottleConfig{
+		IPMaxFailures:      cfg.LoginIPMaxFailures,
+		AccountMaxFailures: cfg.LoginAccountMaxFailures,
+		Window:             cfg.LoginFailureWindow,
+		Lockout:            cfg.LoginLockout,
+		MaxLockout:         cfg.LoginMaxLockout,
+	}, webhookService, logger)
+	domainService := services.NewDomainService(domainRepo, urlService, cache, cfg.BaseURL, cfg.SMSMaxURLLength, logger)
+	updateService := services.NewUpdateService(buildinfo.Version, cfg.UpdateCheckURL, cfg.UpdateCheckEnabled, cfg.UpdateCheckInterval, logger)
+	telemetryService := services.NewTelemetryService(services.TelemetryConfig{
+		Enabled:  cfg.TelemetryEnabled,
+		Endpoint: cfg.TelemetryEndpoint,
+		Version:  buildinfo.Version,
+		Features: enabledFeatures(cfg),
+	}, usageService, cache, logger)
+
+	// Health checks of the core dependencies; optional subsystems register their own
+	healthService.Register("database", services.CriticalityCritical, func(ctx context.Context) error {
+		return urlService.HealthCheck(ctx)
+	})
+	healthService.Register("cache", services.CriticalityDegraded, func(ctx context.Context) error {
+		return urlService.CacheHealthCheck(ctx)
+	})
+
+	signingKeys, err := services.ParseSigningKeys(cfg.RequestSigningKeys)
+	if err != nil {
+		logger.Fatalf("Invalid request signing keys: %v", err)
+	}
+	if cfg.SharePagesEnabled && cfg.RequestSigningRequired {
+		logger.Fatal("SHARE_PAGES_ENABLED cannot be combined with REQUEST_SIGNING_REQUIRED: share pages cannot sign requests")
+	}
+	keyScopes, err := services.ParseKeyScopes(cfg.RequestSigningKeyScopes, cfg.RequestSigningKeyDomains, signingKeys)
+	if err != nil {
+		logger.Fatalf("Invalid request signing settings: %v", err)
+	}
+	keyExpiry, err := services.ParseKeyExpiry(cfg.RequestSigningKeyExpiry, signingKeys)
+	if err != nil {
+		logger.Fatalf("Invalid request signing settings: %v", err)
+	}
+	requestVerifier, err := services.NewRequestVerifier(signingKeys, keyScopes, keyExpiry, cfg.RequestSigningWindow, cfg.RequestSigningRequired, cache)
+	if err != nil {
+		logger.Fatalf("Invalid request signing settings: %v", err)
+	}
+	signingKeyService := services.NewSigningKeyService(signingKeyRepo, requestVerifier, cfg.SigningKeyStaleAfter, logger)
+
+	apiSpec, err := apidocs.Spec(cfg.BaseURL)
+	if err != nil {
+		logger.Fatalf("Failed to load API specification: %v", err)
+	}
 
 	// Initialize handlers
-	urlHandler := handlers.NewURLHandler(urlService, analyticsService, logger)
+	h := &routeHandlers{
+		slo:        sloService,
+		health:     handlers.NewHealthHandler(healthService, updateService),
+		url:        handlers.NewURLHandler(urlService, analyticsService, widgetService, sloService, canaryService, complianceService, aliasClaimService, domainService, redirectAuditService, trendingService, logger),
+		webhook:    handlers.NewWebhookHandler(webhookService, logger),
+		widget:     handlers.NewWidgetHandler(widgetService, logger),
+		takedown:   handlers.NewTakedownHandler(takedownService, logger),
+		goal:       handlers.NewGoalHandler(goalService, logger),
+		report:     handlers.NewReportHandler(reportService, logger),
+		alert:      handlers.NewAlertHandler(alertService, logger),
+		forwarding: handlers.NewEventDestinationHandler(eventForwardingService, logger),
+		domain:     handlers.NewDomainHandler(domainService, logger),
+		share:      handlers.NewShareHandler(urlService, domainService, cfg.BaseURL, logger),
+		docs:       handlers.NewDocsHandler(apiSpec, cfg.SwaggerUIURL),
+		admin:      handlers.NewAdminHandler(usageService, jobService, retentionService, clientBackfillService, maintenanceService, privacyService, encryptionService, complianceService, telemetryService, rateLimitService, domainPolicyService, aliasClaimService, safeBrowsingService, redirectAuditService, signingKeyService, logger),
+
+		verifier:    requestVerifier,
+		signingKeys: signingKeyService,
+		domains:     domainService,
+		idempotency: idempotencyService,
+		logins:      loginThrottle,
+		rateLimits:  rateLimitService,
+		logger:      logger,
+	}
 
 	// Setup Gin router
 	if cfg.Environment == "production" {
@@ -57,14 +366,21 @@ func main() {
 	}
 
 	router := gin.New()
+	// Rate limits, lockouts and alias claims are counted per client IP, so X-Forwarded-For
+	// is only believed from the proxies in front of the service
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		logger.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
 	router.Use(gin.Recovery())
-	router.Use(handlers.LoggerMiddleware(logger))
+	router.Use(handlers.LoggerMiddleware(accessLogger, logPrivacy))
+	router.Use(handlers.ErrorMiddleware(logger))
+	router.Use(handlers.CanaryMiddleware(canaryService, cfg.CanaryHeader, cfg.CanaryCookie))
 	router.Use(handlers.CORSMiddleware())
 	router.Use(handlers.SecurityMiddleware())
-	router.Use(handlers.RateLimitMiddleware(cache))
+	router.Use(handlers.ReadOnlyMiddleware(maintenanceService))
 
 	// Setup routes
-	setupRoutes(router, urlHandler)
+	setupRoutes(router, cfg, h)
 
 	// Start server
 	srv := &http.Server{
@@ -79,6 +395,37 @@ func main() {
 		}
 	}()
 
+	// Internal listener for service-to-service calls, authenticated by client certificate
+	var internalSrv *http.Server
+	if cfg.InternalAddr != "" {
+		tlsConfig, err := mtls.ServerConfig(cfg.InternalTLSCert, cfg.InternalTLSKey, cfg.InternalClientCA, cfg.InternalAllowedSANs)
+		if err != nil {
+			logger.Fatalf("Failed to configure internal listener TLS: %v", err)
+		}
+
+		internalRouter := gin.New()
+		if err := internalRouter.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+			logger.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+		}
+		internalRouter.Use(gin.Recovery())
+		internalRouter.Use(handlers.LoggerMiddleware(accessLogger, logPrivacy))
+		internalRouter.Use(handlers.ErrorMiddleware(logger))
+		setupInternalRoutes(internalRouter, h)
+
+		internalSrv = &http.Server{
+			Addr:      cfg.InternalAddr,
+			Handler:   internalRouter,
+			TLSConfig: tlsConfig,
+		}
+
+		go func() {
+			logger.Infof("Internal mTLS server starting on %s", cfg.InternalAddr)
+			if err := internalSrv.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
+				logger.Fatalf("Failed to start internal server: %v", err)
+			}
+		}()
+	}
+
 	// Wait for interrupt signal to gracefully shutdown the server
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -89,27 +436,254 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	if internalSrv != nil {
+		if err := internalSrv.Shutdown(ctx); err != nil {
+			logger.Errorf("Internal server forced to shutdown: %v", err)
+		}
+	}
 	if err := srv.Shutdown(ctx); err != nil {
-		logger.Fatalf("Server forced to shutdown: %v", err)
+		logger.Errorf("Server forced to shutdown: %v", err)
+	}
+
+	// No more redirects arrive; persist the clicks still buffered
+	drained, err := analyticsService.Stop(ctx)
+	if err != nil {
+		logger.Errorf("Failed to stop analytics pipeline: %v", err)
+	} else if drained.Dropped > 0 {
+		logger.Warnf("Analytics pipeline stopped: %d buffered events persisted, %d dropped", drained.Persisted, drained.Dropped)
+	} else {
+		logger.Infof("Analytics pipeline stopped: %d buffered events persisted", drained.Persisted)
 	}
 
 	logger.Info("Server exited")
 }
 
-func setupRoutes(router *gin.Engine, urlHandler *handlers.URLHandler) {
+// enabledFeatures names the optional subsystems turned on in cfg, for telemetry heartbeats
+func enabledFeatures(cfg *config.Config) []string {
+	flags := []struct {
+		name    string
+		enabled bool
+	}{
+		{"admin_api", cfg.AdminToken != ""},
+		{"alerting", cfg.AlertInterval > 0 && (cfg.AlertmanagerURL != "" || cfg.PagerDutyRoutingKey != "")},
+		{"analytics_mirror", cfg.AnalyticsMirrorDatabaseURL != ""},
+		{"analytics_retention", cfg.AnalyticsRetentionDays > 0},
+		{"attribution", cfg.AttributionEnabled},
+		{"canary", cfg.CanaryPercent > 0},
+		{"compliance_log", len(cfg.ComplianceSensitiveDomains) > 0},
+		{"deduplicate_urls", cfg.DeduplicateURLs},
+		{"deterministic_codes", cfg.DeterministicCodeKey != ""},
+		{"event_stream", cfg.EventStream != ""},
+		{"internal_mtls", cfg.InternalAddr != ""},
+		{"internal_networks", len(cfg.InternalNetworks) > 0},
+		{"link_validator", cfg.LinkValidatorURL != ""},
+		{"pii_encryption", cfg.PIIEncryptionKeys != ""},
+		{"quick_shorten", len(cfg.QuickShortenTokens) > 0},
+		{"redirect_audit", cfg.RedirectAuditPercent > 0},
+		{"reports", len(cfg.ReportPeriods) > 0},
+		{"request_signing", cfg.RequestSigningKeys != ""},
+		{"safe_browsing", cfg.SafeBrowsingAPIKey != ""},
+		{"share_pages", cfg.SharePagesEnabled},
+		{"short_code_random", cfg.ShortCodeRandom},
+		{"takedown_auto_disable", cfg.TakedownAutoDisable},
+		{"widgets", cfg.WidgetSigningKey != ""},
+	}
+
+	var features []string
+	for _, flag := range flags {
+		if flag.enabled {
+			features = append(features, flag.name)
+		}
+	}
+	return features
+}
+
+// routeHandlers groups the HTTP handlers, and services backing route middleware, mounted by setupRoutes
+type routeHandlers struct {
+	slo        *services.SLOService
+	health     *handlers.HealthHandler
+	url        *handlers.URLHandler
+	webhook    *handlers.WebhookHandler
+	widget     *handlers.WidgetHandler
+	takedown   *handlers.TakedownHandler
+	goal       *handlers.GoalHandler
+	report     *handlers.ReportHandler
+	alert      *handlers.AlertHandler
+	forwarding *handlers.EventDestinationHandler
+	domain     *handlers.DomainHandler
+	share      *handlers.ShareHandler
+	docs       *handlers.DocsHandler
+	admin      *handlers.AdminHandler
+
+	verifier    *services.RequestVerifier
+	signingKeys *services.SigningKeyService
+	domains     *services.DomainService
+	idempotency *services.IdempotencyService
+	logins      *services.LoginThrottle
+	rateLimits  *services.RateLimitService
+	logger      *logrus.Logger
+}
+
+// setupInternalRoutes mounts the routes of the mTLS listener; the client certificate is
+// the credential, so no tokens or signatures are checked
+func setupInternalRoutes(router *gin.Engine, h *routeHandlers) {
+	router.GET("/health", h.health.HealthCheck)
+
+	internal := router.Group("/internal/v1")
+	{
+		internal.GET("/resolve/:short_code", h.url.ResolveURL)
+	}
+}
+
+func setupRoutes(router *gin.Engine, cfg *config.Config, h *routeHandlers) {
+	// Rate limiting runs per route, after signature verification, so signed requests
+	// are counted against their API key
+	rateLimit := handlers.RateLimitMiddleware(h.rateLimits)
+
 	// Health check
-	router.GET("/health", urlHandler.HealthCheck)
+	router.GET("/health", rateLimit, h.health.HealthCheck)
 
 	// Metrics endpoint
-	router.GET("/metrics", urlHandler.MetricsHandler)
+	router.GET("/metrics", rateLimit, h.url.MetricsHandler)
+
+	// Redirect SLO status
+	router.GET("/slo", rateLimit, h.url.SLOStatus)
+
+	// Share pages shorten links from a browser or a phone's share sheet
+	if cfg.SharePagesEnabled {
+		router.GET("/manifest.webmanifest", rateLimit, h.share.Manifest)
+		router.GET("/share-icon.png", rateLimit, h.share.Icon)
+		router.GET("/share", rateLimit, h.share.ShareForm)
+		router.POST("/share", rateLimit, h.share.Share)
+		router.GET("/bookmarklet", rateLimit, h.share.Bookmarklet)
+	}
+
+	// API documentation, for browsing and for generating clients
+	if cfg.APIDocsEnabled {
+		router.GET("/docs", rateLimit, h.docs.SwaggerUI)
+		router.GET("/api/v1/openapi.json", rateLimit, h.docs.OpenAPISpec)
+	}
 
-	// API routes
+	// API routes; widgets are embedded by browsers and carry their own signed token,
+	// version information and webhook schemas are public like /health, and anyone may
+	// report a link
+	signatures := handlers.SignatureMiddleware(h.verifier, h.signingKeys, h.logger)
 	api := router.Group("/api/v1")
+	public := api.Group("", rateLimit)
+	{
+		public.GET("/version", h.health.Version)
+		public.GET("/urls/:short_code/widget", h.widget.WidgetEmbed)
+		public.GET("/urls/:short_code/widget.svg", h.widget.WidgetSVG)
+		public.POST("/takedowns", h.takedown.SubmitTakedown)
+		public.GET("/schemas", h.webhook.ListSchemas)
+		public.GET("/schemas/:event", h.webhook.GetSchema)
+	}
+	// Signing keys given scopes only reach the routes of those scopes
+	signed := api.Group("", signatures, rateLimit)
+	linksRead := signed.Group("", handlers.RequireScope(services.ScopeLinksRead))
+	{
+		linksRead.GET("/urls/:short_code", h.url.GetURLInfo)
+		linksRead.GET("/urls/:short_code/history", h.url.GetURLHistory)
+		linksRead.GET("/urls/:short_code/rules", h.url.GetRedirectRules)
+		linksRead.GET("/urls/:short_code/page", h.url.GetLandingPage)
+		linksRead.POST("/urls/:short_code/simulate", h.url.SimulateRedirect)
+		linksRead.GET("/urls/:short_code/aliases", h.url.ListAliases)
+	}
+	linksWrite := signed.Group("", handlers.RequireScope(services.ScopeLinksWrite))
+	{
+		linksWrite.POST("/shorten", handlers.IdempotencyMiddleware(h.idempotency, h.logger), h.url.ShortenURL)
+	}
+	// Changes to existing links need a signing key or the admin token even when signing
+	// is optional, and are attributed to it. Keys restricted to custom domains only change
+	// links on those domains.
+	credential := handlers.CredentialMiddleware(cfg.AdminToken, h.logins, h.logger)
+	linksEdit := signed.Group("", credential, handlers.RequireScope(services.ScopeLinksWrite), handlers.RequireLinkDomain(h.domains))
+	{
+		linksEdit.PUT("/urls/:short_code", h.url.UpdateURL)
+		linksEdit.PUT("/urls/:short_code/rules", h.url.SetRedirectRules)
+		linksEdit.PUT("/urls/:short_code/page", h.url.SetLandingPage)
+		linksEdit.DELETE("/urls/:short_code/page", h.url.DeleteLandingPage)
+		linksEdit.POST("/urls/:short_code/aliases", h.url.AddAlias)
+		linksEdit.DELETE("/urls/:short_code/aliases/:alias", h.url.DeleteAlias)
+		linksEdit.POST("/urls/:short_code/goals", h.goal.CreateGoal)
+		linksEdit.DELETE("/urls/:short_code/goals/:id", h.goal.DeleteGoal)
+	}
+	statsRead := signed.Group("", handlers.RequireScope(services.ScopeStatsRead))
+	{
+		statsRead.GET("/urls/trending", h.url.GetTrendingLinks)
+		statsRead.GET("/urls/:short_code/stats", h.url.GetURLStats)
+		statsRead.GET("/urls/:short_code/analytics", h.url.GetURLAnalytics)
+		statsRead.GET("/urls/:short_code/goals", h.goal.ListGoals)
+		statsRead.GET("/stats/domains", h.url.GetDomainStats)
+	}
+	// Webhooks and domains configure the instance, so they need a signing
+	// key or the admin token too
+	configure := signed.Group("", credential, handlers.RequireScope(services.ScopeAdmin))
+	{
+		configure.POST("/webhooks", h.webhook.CreateWebhook)
+		configure.GET("/webhooks", h.webhook.ListWebhooks)
+		configure.DELETE("/webhooks/:id", h.webhook.DeleteWebhook)
+
+		configure.POST("/domains", h.domain.RegisterDomain)
+		configure.GET("/domains", h.domain.ListDomains)
+		configure.DELETE("/domains/:domain", h.domain.DeleteDomain)
+	}
+
+	// Quick shorten answers launchers and bookmarklets in plain text; they cannot sign requests
+	quick := api.Group("", handlers.QuickShortenAuthMiddleware(cfg.QuickShortenTokens, h.logins, h.logger), rateLimit)
+	{
+		quick.GET("/quick", h.url.QuickShorten)
+	}
+
+	// Admin routes
+	admin := router.Group("/api/v1/admin", handlers.AdminAuthMiddleware(cfg.AdminToken, h.logins, h.logger), signatures, handlers.RequireScope(services.ScopeAdmin), rateLimit)
 	{
-		api.POST("/shorten", urlHandler.ShortenURL)
-		api.GET("/urls/:short_code/stats", urlHandler.GetURLStats)
+		admin.GET("/usage", h.admin.GetUsage)
+		admin.GET("/stats", h.admin.GetTotals)
+		admin.GET("/links/top", h.admin.GetTopLinks)
+		admin.GET("/links/recent", h.admin.GetRecentLinks)
+		admin.GET("/redirect-audit", h.admin.GetRedirectAudit)
+		admin.GET("/code-space", h.url.GetCodeSpace)
+		admin.DELETE("/urls/:short_code", h.url.DeleteURL)
+		admin.GET("/jobs", h.admin.ListJobs)
+		admin.GET("/jobs/:id", h.admin.GetJob)
+		admin.POST("/retention/purge", h.admin.RunRetentionPurge)
+		admin.POST("/analytics/client-backfill", h.admin.RunClientBackfill)
+		admin.POST("/safe-browsing/rescan", h.admin.RunSafeBrowsingRescan)
+		admin.GET("/maintenance", h.admin.GetMaintenance)
+		admin.PUT("/maintenance", h.admin.SetMaintenance)
+		admin.GET("/attribution/visitors/:visitor_id", h.url.GetVisitorJourney)
+		admin.GET("/privacy/report", h.admin.GetPrivacyReport)
+		admin.POST("/privacy/erase", h.admin.ErasePrivacyData)
+		admin.POST("/encryption/rotate", h.admin.RotateEncryptionKey)
+		admin.POST("/encryption/reencrypt", h.admin.ReencryptAnalytics)
+		admin.GET("/compliance/redirects", h.admin.ExportComplianceLog)
+		admin.GET("/telemetry", h.admin.GetTelemetry)
+		admin.GET("/rate-limits", h.admin.GetRateLimits)
+		admin.PUT("/rate-limits/keys/:key_id", h.admin.SetRateLimitOverride)
+		admin.DELETE("/rate-limits/keys/:key_id", h.admin.DeleteRateLimitOverride)
+		admin.GET("/signing-keys", h.admin.ListSigningKeys)
+		admin.GET("/alias-claims", h.admin.ListAliasClaims)
+		admin.GET("/domain-policies", h.admin.ListDomainPolicies)
+		admin.GET("/domain-policies/violations", h.admin.GetDomainPolicyViolations)
+		admin.PUT("/domain-policies/:domain", h.admin.SetDomainPolicy)
+		admin.DELETE("/domain-policies/:domain", h.admin.DeleteDomainPolicy)
+		admin.GET("/takedowns", h.takedown.ListTakedowns)
+		admin.GET("/takedowns/:id", h.takedown.GetTakedown)
+		admin.POST("/takedowns/:id/resolve", h.takedown.ResolveTakedown)
+		admin.POST("/reports", h.report.GenerateReport)
+		admin.GET("/reports", h.report.ListReports)
+		admin.GET("/reports/:id", h.report.DownloadReport)
+		admin.GET("/alerts", h.alert.GetAlerts)
+		admin.GET("/event-destinations", h.forwarding.ListEventDestinations)
+		admin.PUT("/event-destinations/:provider", h.forwarding.SetEventDestination)
+		admin.DELETE("/event-destinations/:provider", h.forwarding.DeleteEventDestination)
 	}
 
-	// Redirect route
-	router.GET("/:short_code", urlHandler.RedirectURL)
+	// Redirect routes; /n/ serves numeric codes and the last one links with path passthrough
+	attribution := handlers.AttributionMiddleware(cfg.AttributionEnabled, cfg.AttributionCookie,
+		cfg.AttributionCookieTTL, cfg.AttributionConsentCookie)
+	router.GET("/n/:digits", rateLimit, handlers.SLOMiddleware(h.slo), attribution, h.url.RedirectNumeric)
+	router.GET("/:short_code", rateLimit, handlers.SLOMiddleware(h.slo), attribution, h.url.RedirectURL)
+	router.GET("/:short_code/*path", rateLimit, handlers.SLOMiddleware(h.slo), attribution, h.url.RedirectURL)
 }
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/alexnthnz/url-shortener/pkg/client"
+)
+
+func runShorten(c *cli, args []string) error {
+	fs := newFlagSet("shorten")
+	alias := fs.String("alias", "", "custom alias")
+	domain := fs.String("domain", "", "custom domain to put the link on")
+	maxClicks := fs.Int64("max-clicks", 0, "disable the link after this many clicks")
+	activateAt := fs.String("activate-at", "", "schedule the link, RFC 3339 time")
+	deterministic := fs.Bool("deterministic", false, "derive the code from the destination, returning the existing link on repeats")
+	args, err := c.parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := exactArgs(args, 1, "shorten <url> [--alias alias] [--domain domain] [--max-clicks n] [--activate-at time] [--deterministic]"); err != nil {
+		return err
+	}
+
+	req := client.ShortenRequest{URL: args[0], CustomAlias: *alias, Domain: *domain, Deterministic: *deterministic}
+	if *maxClicks > 0 {
+		req.MaxClicks = maxClicks
+	}
+	if *activateAt != "" {
+		at, err := time.Parse(time.RFC3339, *activateAt)
+		if err != nil {
+			return fmt.Errorf("--activate-at must be an RFC 3339 time such as 2024-03-01T09:00:00Z")
+		}
+		req.ActivateAt = &at
+	}
+
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+	link, err := api.Shorten(c.ctx, req)
+	if err != nil {
+		return err
+	}
+	return c.print(link, func(t *table) {
+		t.add("SHORT URL", link.ShortURL)
+		t.add("SHORT CODE", link.ShortCode)
+		t.add("DESTINATION", link.OriginalURL)
+		if link.NumericURL != "" {
+			t.add("NUMERIC URL", link.NumericURL)
+		}
+		if link.ExpiresAt != nil {
+			t.add("EXPIRES", formatTime(*link.ExpiresAt))
+		}
+		if link.ActivateAt != nil {
+			t.add("ACTIVATES", formatTime(*link.ActivateAt))
+		}
+	})
+}
+
+func runInfo(c *cli, args []string) error {
+	args, err := c.parseFlags(newFlagSet("info"), args)
+	if err != nil {
+		return err
+	}
+	if err := exactArgs(args, 1, "info <short-code>"); err != nil {
+		return err
+	}
+
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+	info, err := api.Info(c.ctx, args[0])
+	if err != nil {
+		return err
+	}
+	return c.print(info, func(t *table) {
+		t.add("SHORT CODE", info.ShortCode)
+		t.add("DESTINATION", info.OriginalURL)
+		t.add("CREATED", formatTime(info.CreatedAt))
+		t.add("CLICKS", strconv.FormatInt(info.ClickCount, 10))
+		if info.ClicksRemaining != nil {
+			t.add("CLICKS LEFT", strconv.FormatInt(*info.ClicksRemaining, 10))
+		}
+		if info.ExpiresAt != nil {
+			t.add("EXPIRES", formatTime(*info.ExpiresAt))
+		}
+		if info.ActivateAt != nil {
+			t.add("ACTIVATES", formatTime(*info.ActivateAt))
+		}
+		t.add("STATUS", linkStatus(info))
+	})
+}
+
+func runStats(c *cli, args []string) error {
+	args, err := c.parseFlags(newFlagSet("stats"), args)
+	if err != nil {
+		return err
+	}
+	if err := exactArgs(args, 1, "stats <short-code>"); err != nil {
+		return err
+	}
+
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+	stats, err := api.Stats(c.ctx, args[0])
+	if err != nil {
+		return err
+	}
+	return c.print(stats, func(t *table) { statsTable(t, stats) })
+}
+
+func statsTable(t *table, stats *client.URLStats) {
+	t.add("SHORT CODE", stats.ShortCode)
+	t.add("DESTINATION", stats.OriginalURL)
+	t.add("CLICKS", strconv.FormatInt(stats.ClickCount, 10))
+	t.add("BOT CLICKS", strconv.FormatInt(stats.BotClicks, 10))
+	t.add("QR SCANS", strconv.FormatInt(stats.QRScans, 10))
+	t.add("CREATED", formatTime(stats.CreatedAt))
+}
+
+func runDelete(c *cli, args []string) error {
+	args, err := c.parseFlags(newFlagSet("delete"), args)
+	if err != nil {
+		return err
+	}
+	if err := exactArgs(args, 1, "delete <short-code>"); err != nil {
+		return err
+	}
+
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+	if err := api.Delete(c.ctx, args[0]); err != nil {
+		return err
+	}
+	result := map[string]interface{}{"short_code": args[0], "deleted": true}
+	return c.print(result, func(t *table) {
+		t.add("DELETED", args[0])
+	})
+}
+
+func runProfiles(c *cli, args []string) error {
+	fs := newFlagSet("profiles")
+	names := fs.Bool("names", false, "print only the profile names, for shell completion")
+	adminToken := fs.String("admin-token", "", "admin token (profiles set)")
+	signingKey := fs.String("signing-key", "", "request signing key as id:secret (profiles set)")
+	defaultOutput := fs.String("default-output", "", "default output format of the profile (profiles set)")
+	args, err := c.parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	path, err := configPath(c.opts.configPath)
+	if err != nil {
+		return err
+	}
+	cfg, err := loadConfig(path)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case len(args) == 0:
+		if *names {
+			for _, name := range cfg.profileNames() {
+				fmt.Fprintln(c.stdout, name)
+			}
+			return nil
+		}
+		type profileSummary struct {
+			Name    string `json:"name"`
+			Server  string `json:"server"`
+			Current bool   `json:"current"`
+		}
+		summaries := make([]profileSummary, 0, len(cfg.Profiles))
+		for _, name := range cfg.profileNames() {
+			summaries = append(summaries, profileSummary{name, cfg.Profiles[name].Server, name == cfg.CurrentProfile})
+		}
+		return c.print(summaries, func(t *table) {
+			t.header = []string{"CURRENT", "NAME", "SERVER"}
+			for _, summary := range summaries {
+				current := ""
+				if summary.Current {
+					current = "*"
+				}
+				t.add(current, summary.Name, summary.Server)
+			}
+		})
+
+	case len(args) == 2 && args[0] == "use":
+		if _, ok := cfg.Profiles[args[1]]; !ok {
+			return fmt.Errorf("profile %q not found", args[1])
+		}
+		cfg.CurrentProfile = args[1]
+		if err := cfg.save(path); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.stdout, "Using profile %s\n", args[1])
+		return nil
+
+	case len(args) == 2 && args[0] == "set":
+		profile := cfg.Profiles[args[1]]
+		updates := []struct {
+			value  string
+			target *string
+		}{
+			{c.opts.server, &profile.Server},
+			{*adminToken, &profile.AdminToken},
+			{*signingKey, &profile.SigningKey},
+			{*defaultOutput, &profile.Output},
+		}
+		for _, update := range updates {
+			if update.value != "" {
+				*update.target = update.value
+			}
+		}
+		if profile.Server == "" {
+			return fmt.Errorf("a profile needs a server: pass --server")
+		}
+		if cfg.Profiles == nil {
+			cfg.Profiles = map[string]Profile{}
+		}
+		cfg.Profiles[args[1]] = profile
+		if cfg.CurrentProfile == "" {
+			cfg.CurrentProfile = args[1]
+		}
+		if err := cfg.save(path); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.stdout, "Saved profile %s to %s\n", args[1], path)
+		return nil
+
+	default:
+		return fmt.Errorf("usage: urlctl profiles [--names] | profiles use <name> | profiles set <name> --server url [--admin-token token] [--signing-key id:secret] [--default-output format]")
+	}
+}
+
+func linkStatus(info *client.URLInfo) string {
+	switch {
+	case info.Disabled:
+		return "disabled"
+	case !info.Active:
+		return "scheduled"
+	case info.Ephemeral:
+		return "active (ephemeral)"
+	default:
+		return "active"
+	}
+}
+
+func formatTime(t time.Time) string {
+	return t.Local().Format("2006-01-02 15:04:05 MST")
+}
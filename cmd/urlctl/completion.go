@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+const bashCompletion = `# bash completion for urlctl; load with: source <(urlctl completion bash)
+_urlctl() {
+    local cur prev
+    cur="${COMP_WORDS[COMP_CWORD]}"
+    prev="${COMP_WORDS[COMP_CWORD-1]}"
+
+    case "$prev" in
+        -o|-output|--output)
+            COMPREPLY=($(compgen -W "%[2]s" -- "$cur")); return ;;
+        -profile|--profile)
+            COMPREPLY=($(compgen -W "$(urlctl profiles --names 2>/dev/null)" -- "$cur")); return ;;
+        -config|--config)
+            COMPREPLY=($(compgen -f -- "$cur")); return ;;
+        completion)
+            COMPREPLY=($(compgen -W "bash zsh fish" -- "$cur")); return ;;
+        watch)
+            COMPREPLY=($(compgen -W "stats" -- "$cur")); return ;;
+        profiles)
+            COMPREPLY=($(compgen -W "use set" -- "$cur")); return ;;
+        use)
+            COMPREPLY=($(compgen -W "$(urlctl profiles --names 2>/dev/null)" -- "$cur")); return ;;
+    esac
+
+    if [[ "$cur" == -* ]]; then
+        COMPREPLY=($(compgen -W "--output --profile --server --config" -- "$cur"))
+        return
+    fi
+
+    local i
+    for ((i = 1; i < COMP_CWORD; i++)); do
+        case "${COMP_WORDS[i]}" in
+            -o|-output|--output|-profile|--profile|-server|--server|-config|--config) ((i++)) ;;
+            -*) ;;
+            *) return ;;
+        esac
+    done
+    COMPREPLY=($(compgen -W "%[1]s" -- "$cur"))
+}
+complete -F _urlctl urlctl
+`
+
+const zshCompletion = `#compdef urlctl
+# zsh completion for urlctl; load with: source <(urlctl completion zsh)
+_urlctl() {
+    local -a commands
+    commands=(
+%[1]s
+    )
+
+    _arguments -C \
+        '(-o --output)'{-o,--output}'[output format]:format:(%[2]s)' \
+        '--profile[profile of the config file]:profile:($(urlctl profiles --names 2>/dev/null))' \
+        '--server[base URL of the instance]:url:' \
+        '--config[config file]:file:_files' \
+        '1: :->command' \
+        '*:: :->args'
+
+    case $state in
+        command)
+            _describe 'command' commands ;;
+        args)
+            case $words[1] in
+                completion) _values 'shell' bash zsh fish ;;
+                watch) (( CURRENT == 2 )) && _values 'target' stats ;;
+                profiles)
+                    if (( CURRENT == 2 )); then
+                        _values 'action' use set
+                    elif [[ $words[2] == use ]]; then
+                        _values 'profile' $(urlctl profiles --names 2>/dev/null)
+                    fi ;;
+            esac ;;
+    esac
+}
+compdef _urlctl urlctl
+`
+
+const fishCompletion = `# fish completion for urlctl; load with: urlctl completion fish | source
+complete -c urlctl -f
+%[1]s
+complete -c urlctl -s o -l output -x -a '%[2]s' -d 'Output format'
+complete -c urlctl -l profile -x -a '(urlctl profiles --names 2>/dev/null)' -d 'Profile of the config file'
+complete -c urlctl -l server -x -d 'Base URL of the instance'
+complete -c urlctl -l config -r -F -d 'Config file'
+complete -c urlctl -n '__fish_seen_subcommand_from completion' -a 'bash zsh fish'
+complete -c urlctl -n '__fish_seen_subcommand_from watch' -a 'stats'
+complete -c urlctl -n '__fish_seen_subcommand_from profiles; and not __fish_seen_subcommand_from use set' -a 'use set'
+complete -c urlctl -n '__fish_seen_subcommand_from use' -a '(urlctl profiles --names 2>/dev/null)'
+`
+
+func runCompletion(c *cli, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: urlctl completion bash|zsh|fish")
+	}
+
+	formats := strings.Join(outputFormats, " ")
+	var names []string
+	var script string
+	switch args[0] {
+	case "bash":
+		for _, cmd := range commandList() {
+			names = append(names, cmd.name)
+		}
+		script = fmt.Sprintf(bashCompletion, strings.Join(names, " "), formats)
+	case "zsh":
+		for _, cmd := range commandList() {
+			names = append(names, fmt.Sprintf("        '%s:%s'", cmd.name, cmd.summary))
+		}
+		script = fmt.Sprintf(zshCompletion, strings.Join(names, "\n"), formats)
+	case "fish":
+		for _, cmd := range commandList() {
+			names = append(names, fmt.Sprintf("complete -c urlctl -n '__fish_use_subcommand' -a %s -d '%s'", cmd.name, cmd.summary))
+		}
+		script = fmt.Sprintf(fishCompletion, strings.Join(names, "\n"), formats)
+	default:
+		return fmt.Errorf("unsupported shell %q: use bash, zsh or fish", args[0])
+	}
+
+	_, err := fmt.Fprint(c.stdout, script)
+	return err
+}
@@ -0,0 +1,219 @@
+// Command urlctl manages links on URL shortener instances from the terminal.
+//
+//	urlctl shorten https://example.com/spring-sale --alias spring
+//	urlctl stats spring -o yaml
+//	urlctl watch stats spring
+//	urlctl --profile staging info spring
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/alexnthnz/url-shortener/pkg/client"
+)
+
+// command is a urlctl subcommand
+type command struct {
+	name    string
+	args    string
+	summary string
+	run     func(c *cli, args []string) error
+}
+
+func commandList() []command {
+	return []command{
+		{"shorten", "<url>", "Create a short link", runShorten},
+		{"info", "<short-code>", "Show a short link", runInfo},
+		{"stats", "<short-code>", "Show the statistics of a short link", runStats},
+		{"delete", "<short-code>", "Delete a short link and its statistics (admin)", runDelete},
+		{"watch", "stats <short-code>", "Follow the statistics of a short link live", runWatch},
+		{"profiles", "[use <name> | set <name>]", "List, select or set up instance profiles", runProfiles},
+		{"completion", "bash|zsh|fish", "Print a shell completion script", runCompletion},
+	}
+}
+
+// globalOptions are accepted before and after the subcommand
+type globalOptions struct {
+	profile    string
+	output     string
+	server     string
+	configPath string
+}
+
+func (o *globalOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.profile, "profile", o.profile, "profile of the config file to use")
+	fs.StringVar(&o.output, "output", o.output, "output format: table, json or yaml")
+	fs.StringVar(&o.output, "o", o.output, "shorthand for --output")
+	fs.StringVar(&o.server, "server", o.server, "base URL of the instance, overriding the profile")
+	fs.StringVar(&o.configPath, "config", o.configPath, "config file (default ~/.config/urlctl/config.yaml)")
+}
+
+// cli carries the options and output of one invocation
+type cli struct {
+	opts   globalOptions
+	ctx    context.Context
+	stdout io.Writer
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	c := &cli{ctx: ctx, stdout: os.Stdout}
+	if err := c.run(os.Args[1:]); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, "urlctl:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+func (c *cli) run(args []string) error {
+	fs := flag.NewFlagSet("urlctl", flag.ContinueOnError)
+	fs.Usage = func() { usage(fs.Output()) }
+	c.opts.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		usage(os.Stderr)
+		return flag.ErrHelp
+	}
+
+	name := fs.Arg(0)
+	for _, cmd := range commandList() {
+		if cmd.name == name {
+			return cmd.run(c, fs.Args()[1:])
+		}
+	}
+	return fmt.Errorf("unknown command %q; run urlctl -h for the commands", name)
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: urlctl [--profile name] [-o table|json|yaml] <command> [arguments]")
+	fmt.Fprintln(w, "\nCommands:")
+	for _, cmd := range commandList() {
+		fmt.Fprintf(w, "  %-11s %-28s %s\n", cmd.name, cmd.args, cmd.summary)
+	}
+	fmt.Fprintln(w, "\nGlobal flags:")
+	fmt.Fprintln(w, "  --profile   profile of the config file to use")
+	fmt.Fprintln(w, "  -o, --output  output format: table, json or yaml")
+	fmt.Fprintln(w, "  --server    base URL of the instance, overriding the profile")
+	fmt.Fprintln(w, "  --config    config file (default ~/.config/urlctl/config.yaml, or $URLCTL_CONFIG)")
+}
+
+// parseFlags parses a subcommand's flags, which may come before, between or after its
+// arguments, and returns the arguments
+func (c *cli) parseFlags(fs *flag.FlagSet, args []string) ([]string, error) {
+	c.opts.register(fs)
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		rest := fs.Args()
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		// Everything after a "--" is an argument
+		if len(args) > len(rest) && args[len(args)-len(rest)-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+// newFlagSet returns the flag set of a subcommand
+func newFlagSet(cmd string) *flag.FlagSet {
+	return flag.NewFlagSet("urlctl "+cmd, flag.ContinueOnError)
+}
+
+// profile loads the selected profile, with the environment and --server applied
+func (c *cli) profile() (Profile, error) {
+	path, err := configPath(c.opts.configPath)
+	if err != nil {
+		return Profile{}, err
+	}
+	cfg, err := loadConfig(path)
+	if err != nil {
+		return Profile{}, err
+	}
+	profile, err := cfg.profile(c.opts.profile)
+	if err != nil {
+		return Profile{}, err
+	}
+
+	overrides := []struct {
+		env   string
+		value *string
+	}{
+		{"URLCTL_SERVER", &profile.Server},
+		{"URLCTL_ADMIN_TOKEN", &profile.AdminToken},
+		{"URLCTL_SIGNING_KEY", &profile.SigningKey},
+	}
+	for _, override := range overrides {
+		if value := os.Getenv(override.env); value != "" {
+			*override.value = value
+		}
+	}
+	if c.opts.server != "" {
+		profile.Server = c.opts.server
+	}
+	return profile, nil
+}
+
+// client returns an API client for the selected profile
+func (c *cli) client() (*client.Client, error) {
+	profile, err := c.profile()
+	if err != nil {
+		return nil, err
+	}
+	if profile.Server == "" {
+		return nil, fmt.Errorf("no instance selected: pass --server, set URLCTL_SERVER or set up a profile with urlctl profiles set")
+	}
+
+	var opts []client.Option
+	if profile.AdminToken != "" {
+		opts = append(opts, client.WithAdminToken(profile.AdminToken))
+	}
+	if profile.SigningKey != "" {
+		keyID, secret, ok := strings.Cut(profile.SigningKey, ":")
+		if !ok || keyID == "" || secret == "" {
+			return nil, fmt.Errorf("signing key must look like id:secret")
+		}
+		opts = append(opts, client.WithSigningKey(keyID, []byte(secret)))
+	}
+	return client.New(profile.Server, opts...), nil
+}
+
+// output returns the output format: the flag, else the profile's default, else table
+func (c *cli) output() string {
+	if c.opts.output != "" {
+		return c.opts.output
+	}
+	if profile, err := c.profile(); err == nil && profile.Output != "" {
+		return profile.Output
+	}
+	return outputTable
+}
+
+// print writes a result in the selected output format
+func (c *cli) print(v interface{}, build func(t *table)) error {
+	return printResult(c.stdout, c.output(), v, build)
+}
+
+// exactArgs checks the number of arguments of a subcommand
+func exactArgs(args []string, n int, usage string) error {
+	if len(args) != n {
+		return fmt.Errorf("usage: urlctl %s", usage)
+	}
+	return nil
+}
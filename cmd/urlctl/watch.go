@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/alexnthnz/url-shortener/pkg/client"
+)
+
+// statsSample is one reading of watch stats, with the clicks since the previous one
+type statsSample struct {
+	*client.URLStats
+	SampledAt    time.Time `json:"sampled_at"`
+	NewClicks    int64     `json:"new_clicks"`
+	ClicksPerMin float64   `json:"clicks_per_minute"`
+}
+
+func runWatch(c *cli, args []string) error {
+	fs := newFlagSet("watch")
+	interval := fs.Duration("interval", 2*time.Second, "time between readings")
+	count := fs.Int("count", 0, "stop after this many readings (0 runs until interrupted)")
+	args, err := c.parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) != 2 || args[0] != "stats" {
+		return fmt.Errorf("usage: urlctl watch stats <short-code> [--interval 2s] [--count n]")
+	}
+	if *interval < time.Second {
+		return fmt.Errorf("--interval must be at least 1s")
+	}
+
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+
+	// A terminal is redrawn in place; pipes and JSON or YAML get one reading after another
+	format := c.output()
+	redraw := format == outputTable && isTerminal(os.Stdout)
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+
+	var previous *statsSample
+	for readings := 0; *count == 0 || readings < *count; readings++ {
+		if readings > 0 {
+			select {
+			case <-c.ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+		}
+
+		stats, err := api.Stats(c.ctx, args[1])
+		if err != nil {
+			if c.ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		sample := &statsSample{URLStats: stats, SampledAt: time.Now()}
+		if previous != nil {
+			sample.NewClicks = stats.ClickCount - previous.ClickCount
+			if elapsed := sample.SampledAt.Sub(previous.SampledAt); elapsed > 0 {
+				sample.ClicksPerMin = float64(sample.NewClicks) / elapsed.Minutes()
+			}
+		}
+		previous = sample
+
+		if err := c.printSample(format, redraw, sample, *interval); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *cli) printSample(format string, redraw bool, sample *statsSample, interval time.Duration) error {
+	switch {
+	case format == outputYAML:
+		fmt.Fprintln(c.stdout, "---")
+	case redraw:
+		// Clear the screen and move the cursor home
+		fmt.Fprint(c.stdout, "\033[H\033[2J")
+	}
+	if format == outputJSON {
+		// One compact document per line, for jq and log shippers
+		return json.NewEncoder(c.stdout).Encode(sample)
+	}
+
+	return printResult(c.stdout, format, sample, func(t *table) {
+		statsTable(t, sample.URLStats)
+		t.add("NEW CLICKS", "+"+strconv.FormatInt(sample.NewClicks, 10))
+		t.add("CLICKS/MIN", strconv.FormatFloat(sample.ClicksPerMin, 'f', 1, 64))
+		if redraw {
+			t.add("", "")
+			t.add("UPDATED", fmt.Sprintf("%s, every %s (Ctrl-C to stop)", sample.SampledAt.Format("15:04:05"), interval))
+		} else {
+			t.add("SAMPLED", formatTime(sample.SampledAt))
+			t.add("", "")
+		}
+	})
+}
+
+// isTerminal reports whether f is a terminal rather than a pipe or file
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,12 @@
+//go:build windows || plan9
+
+package accesslog
+
+import (
+	"fmt"
+	"io"
+)
+
+func newSyslogWriter(addr, tag string) (io.WriteCloser, error) {
+	return nil, fmt.Errorf("syslog access logs are not supported on this platform")
+}
@@ -0,0 +1,33 @@
+// Package apidocs holds the OpenAPI specification of the /api/v1 endpoints. It is written
+// by hand, so routes added in cmd/server need an entry in openapi.json as well.
+package apidocs
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+//go:embed openapi.json
+var files embed.FS
+
+// Spec returns the specification with its server set to the API under baseURL, so
+// generated clients and Swagger UI call the instance that served it
+func Spec(baseURL string) ([]byte, error) {
+	raw, err := files.ReadFile("openapi.json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read OpenAPI specification: %w", err)
+	}
+
+	var spec map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &spec); err != nil {
+		return nil, fmt.Errorf("failed to parse OpenAPI specification: %w", err)
+	}
+	servers, err := json.Marshal([]map[string]string{{"url": strings.TrimSuffix(baseURL, "/") + "/api/v1"}})
+	if err != nil {
+		return nil, err
+	}
+	spec["servers"] = servers
+	return json.Marshal(spec)
+}
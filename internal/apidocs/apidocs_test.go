@@ -0,0 +1,72 @@
+package apidocs
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestSpec(t *testing.T) {
+	raw, err := Spec("https://sho.rt/")
+	if err != nil {
+		t.Fatalf("Spec failed: %v", err)
+	}
+
+	var spec struct {
+		OpenAPI string `json:"openapi"`
+		Servers []struct {
+			URL string `json:"url"`
+		} `json:"servers"`
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Schemas   map[string]json.RawMessage `json:"schemas"`
+			Responses map[string]json.RawMessage `json:"responses"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(raw, &spec); err != nil {
+		t.Fatalf("specification is not valid JSON: %v", err)
+	}
+	if !strings.HasPrefix(spec.OpenAPI, "3.") {
+		t.Errorf("openapi = %q, want 3.x", spec.OpenAPI)
+	}
+	if len(spec.Servers) != 1 || spec.Servers[0].URL != "https://sho.rt/api/v1" {
+		t.Errorf("servers = %+v, want https://sho.rt/api/v1", spec.Servers)
+	}
+
+	operationIDs := make(map[string]string)
+	for path, item := range spec.Paths {
+		for method, body := range item {
+			var operation struct {
+				OperationID string                     `json:"operationId"`
+				Responses   map[string]json.RawMessage `json:"responses"`
+			}
+			if err := json.Unmarshal(body, &operation); err != nil {
+				t.Fatalf("%s %s: %v", method, path, err)
+			}
+			if operation.OperationID == "" {
+				t.Errorf("%s %s has no operationId", method, path)
+			} else if other, ok := operationIDs[operation.OperationID]; ok {
+				t.Errorf("%s %s reuses operationId %q of %s", method, path, operation.OperationID, other)
+			}
+			operationIDs[operation.OperationID] = method + " " + path
+			if len(operation.Responses) == 0 {
+				t.Errorf("%s %s has no responses", method, path)
+			}
+		}
+	}
+
+	// Every reference resolves, or generated clients fail to build
+	for _, ref := range strings.Split(string(raw), `"$ref":"`)[1:] {
+		ref = ref[:strings.IndexByte(ref, '"')]
+		var found bool
+		switch {
+		case strings.HasPrefix(ref, "#/components/schemas/"):
+			_, found = spec.Components.Schemas[strings.TrimPrefix(ref, "#/components/schemas/")]
+		case strings.HasPrefix(ref, "#/components/responses/"):
+			_, found = spec.Components.Responses[strings.TrimPrefix(ref, "#/components/responses/")]
+		}
+		if !found {
+			t.Errorf("reference %s does not resolve", ref)
+		}
+	}
+}
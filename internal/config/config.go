@@ -2,6 +2,9 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -12,18 +15,503 @@ type Config struct {
 	DatabaseURL string
 	RedisURL    string
 	BaseURL     string
+
+	// CacheBackend is "redis", "memory" (in process, for development and tests) or
+	// "layered" (a local LRU of CacheLocalEntries keys, each kept up to CacheLocalTTL,
+	// in front of Redis)
+	CacheBackend      string
+	CacheLocalEntries int
+	CacheLocalTTL     time.Duration
+	// NotFoundCacheTTL is how long a short code found neither as a link nor as an alias
+	// is answered with 404 from the cache; 0 disables negative caching
+	NotFoundCacheTTL time.Duration
+	// CacheEarlyRefreshBeta makes redirects of hot links refresh their cache entry shortly
+	// before it expires, with a probability growing towards expiry (XFetch); 0 disables it
+	CacheEarlyRefreshBeta float64
+
+	// Database connection pool; defaults depend on Environment
+	DBMaxOpenConns    int
+	DBMaxIdleConns    int
+	DBConnMaxLifetime time.Duration
+	DBConnMaxIdleTime time.Duration
+	// DBQueryTimeout bounds every query of the link and analytics repositories and
+	// RedisTimeout every cache call, so a stuck dependency fails requests instead of
+	// holding them; 0 leaves them bounded only by the request
+	DBQueryTimeout time.Duration
+	RedisTimeout   time.Duration
+
+	// ShortCodeChecksum appends a check character to generated short codes so typos are detected
+	ShortCodeChecksum bool
+	// ShortCodeRandom generates random short codes instead of encoding the next id, so codes
+	// cannot be enumerated
+	ShortCodeRandom bool
+	// ShortCodeLength is the length of random short codes, before any check character
+	ShortCodeLength int
+	// WordCodeList is a wordlist file for the "words" code style; empty uses the built-in list
+	WordCodeList string
+	// WordCodeWords, WordCodeDigits and WordCodeSeparator shape word codes such as red-fox-42
+	WordCodeWords     int
+	WordCodeDigits    int
+	WordCodeSeparator string
+	// DeterministicCodeKey keys the hash deterministic requests derive their codes from;
+	// instances sharing it give a destination the same code. Empty rejects such requests.
+	DeterministicCodeKey    string
+	DeterministicCodeLength int
+	// EmojiAliases allows custom aliases made of emoji
+	EmojiAliases bool
+	// IdempotencyKeyTTL is how long responses to shorten requests with an Idempotency-Key
+	// are replayed to retries
+	IdempotencyKeyTTL time.Duration
+	// DeduplicateURLs makes shortening a destination that already has a plain link return
+	// that link instead of a new one; requests can override it
+	DeduplicateURLs bool
+	// SMSMaxURLLength caps the whole short URL of links created with the "sms" profile
+	SMSMaxURLLength int
+
+	// BlockedDomains lists destination domains (and their subdomains) that cannot be shortened
+	BlockedDomains []string
+	// InternalNetworks lists the CIDR ranges of the team's own networks, whose clicks are
+	// flagged internal and left out of reported stats
+	InternalNetworks []string
+	// TrustedProxies lists the addresses or CIDR ranges of the proxies in front of the
+	// service. Client IPs are read from X-Forwarded-For only on requests from them; with
+	// none, the connecting address is the client IP.
+	TrustedProxies []string
+
+	// URL normalization rules applied to destinations unless a request overrides them
+	NormalizeForceHTTPS         bool
+	NormalizeStripTrailingSlash bool
+	NormalizeStripFragment      bool
+	NormalizeLowercaseHost      bool
+	NormalizeSortQuery          bool
+
+	// MigrationLockTimeout bounds how long any migration statement may wait for a lock
+	MigrationLockTimeout time.Duration
+
+	// Internal listener for service-to-service calls such as resolve, authenticated with
+	// mutual TLS: clients need a certificate from InternalClientCA and, when
+	// InternalAllowedSANs is set, one of those subject alternative names. Off when
+	// InternalAddr is empty.
+	InternalAddr        string
+	InternalTLSCert     string
+	InternalTLSKey      string
+	InternalClientCA    string
+	InternalAllowedSANs []string
+
+	// Safe Browsing screens destinations of new links and, every SafeBrowsingRescanInterval,
+	// of stored ones; listed destinations of new links are rejected or, with the "flag"
+	// action, filed for takedown review. Off when SafeBrowsingAPIKey is empty.
+	SafeBrowsingAPIKey         string
+	SafeBrowsingEndpoint       string
+	SafeBrowsingAction         string
+	SafeBrowsingCacheTTL       time.Duration
+	SafeBrowsingRescanInterval time.Duration
+
+	// Link validator: an external service asked to approve every new link, alias,
+	// destination change and redirect rule; off when LinkValidatorURL is empty. Unless
+	// LinkValidatorFailOpen is set, requests fail while the service is unavailable.
+	LinkValidatorURL      string
+	LinkValidatorSecret   string
+	LinkValidatorTimeout  time.Duration
+	LinkValidatorFailOpen bool
+
+	// ComplianceSensitiveDomains lists destination domains (and their subdomains) whose
+	// redirects are recorded in the append-only compliance log
+	ComplianceSensitiveDomains []string
+
+	// TakedownAutoDisable disables a link as soon as a takedown request is filed against
+	// it, until an admin resolves the request
+	TakedownAutoDisable bool
+
+	// GoalCheckInterval is how often click goals are evaluated for alerts; 0 turns alerts off
+	GoalCheckInterval time.Duration
+
+	// Campaign reports: ReportPeriods ("weekly", "monthly") are generated on schedule for every
+	// utm_campaign, rendered with the brand name, color and logo
+	ReportPeriods    []string
+	ReportBrandName  string
+	ReportBrandColor string
+	ReportLogoURL    string
+
+	// Batch endpoints click events are forwarded to; the destinations and their API keys
+	// are set up through the admin API
+	SegmentAPIURL   string
+	AmplitudeAPIURL string
+
+	// Event streaming publishes every click and link lifecycle event to EventStream ("kafka"
+	// through a REST Proxy); off when empty
+	EventStream       string
+	StreamClickTopic  string
+	StreamLinkTopic   string
+	KafkaRESTURL      string
+	KafkaRESTUsername string
+	KafkaRESTPassword string
+
+	// Telemetry sends an anonymous daily heartbeat (version, enabled features, rounded
+	// usage counts) to TelemetryEndpoint; off unless opted in, and DO_NOT_TRACK turns it off
+	TelemetryEnabled  bool
+	TelemetryEndpoint string
+
+	// Update checks poll UpdateCheckURL (a GitHub "latest release" endpoint) and report
+	// newer versions in the health and version endpoints; off by default
+	UpdateCheckEnabled  bool
+	UpdateCheckURL      string
+	UpdateCheckInterval time.Duration
+
+	// HealthCheckTimeout bounds each dependency check of the health endpoint
+	HealthCheckTimeout time.Duration
+
+	// Rate limits: requests per RateLimitWindow for each client IP, or API key for signed
+	// requests, counted separately per route tier; 0 disables a tier's limit
+	RateLimitWindow   time.Duration
+	RateLimitDefault  int
+	RateLimitShorten  int
+	RateLimitRedirect int
+	RateLimitStats    int
+
+	// Custom alias claims: aliases each client IP, or signing key for signed requests, may
+	// claim per day, and the wait between two claims; 0 disables a limit
+	AliasClaimIPLimit  int
+	AliasClaimKeyLimit int
+	AliasClaimCooldown time.Duration
+
+	// Failed admin and quick shorten token attempts: a client IP reaching its limit within
+	// LoginFailureWindow is locked out for LoginLockout, doubling with each further failure
+	// up to LoginMaxLockout; every client of a token reaching its limit raises an alert
+	// (0 limits turn a check off)
+	LoginIPMaxFailures      int
+	LoginAccountMaxFailures int
+	LoginFailureWindow      time.Duration
+	LoginLockout            time.Duration
+	LoginMaxLockout         time.Duration
+
+	// AdminToken protects the /api/v1/admin endpoints; the admin API is disabled when empty
+	AdminToken string
+	// QuickShortenTokens are the bearer tokens of GET /api/v1/quick, the plain text
+	// shorten endpoint for launchers and bookmarklets; it is disabled when empty
+	QuickShortenTokens []string
+	// SharePagesEnabled serves /share, its Web Share Target manifest and /bookmarklet, which
+	// shorten links from a browser form without a token, like unsigned API requests
+	SharePagesEnabled bool
+	// APIDocsEnabled serves the OpenAPI specification at /api/v1/openapi.json and Swagger UI
+	// at /docs, loading the Swagger UI assets from SwaggerUIURL
+	APIDocsEnabled bool
+	SwaggerUIURL   string
+
+	// Request signing: HMAC keys as "id:secret,..." that API clients may sign requests with,
+	// the accepted clock skew, and whether unsigned API requests are rejected
+	RequestSigningKeys     string
+	RequestSigningWindow   time.Duration
+	RequestSigningRequired bool
+	// RequestSigningKeyScopes ("id:scope+scope,...") limits keys to the routes of their
+	// scopes, and RequestSigningKeyDomains ("id:domain+domain,...") to creating links on
+	// those custom domains; keys in neither keep full access
+	RequestSigningKeyScopes  []string
+	RequestSigningKeyDomains []string
+	// RequestSigningKeyExpiry ("id:2006-01-02,...") stops accepting keys from a date, and
+	// keys unused for SigningKeyStaleAfter are reported as stale
+	RequestSigningKeyExpiry []string
+	SigningKeyStaleAfter    time.Duration
+
+	// WidgetSigningKey signs embeddable stats widget tokens; widgets are disabled when empty
+	WidgetSigningKey string
+
+	// AnalyticsMirrorDatabaseURL receives a copy of every click while analytics moves to
+	// a new backend; double-writing is off when empty
+	AnalyticsMirrorDatabaseURL string
+
+	// PII encryption seals click IP addresses and user agents with data keys wrapped by the
+	// key encryption keys in PIIEncryptionKeys ("id:base64key,..."); off when empty.
+	// PIIBlindIndexKey (at least 32 bytes) keys the hashes used to look IP addresses up.
+	PIIEncryptionKeys      string
+	PIIEncryptionActiveKey string
+	PIIBlindIndexKey       string
+
+	// AnalyticsRetentionDays purges click events older than this many days; 0 keeps them forever
+	AnalyticsRetentionDays int
+	// PurgeBatchSize and PurgeBatchPause bound bulk deletions to limit WAL bloat and lock time
+	PurgeBatchSize  int
+	PurgeBatchPause time.Duration
+	// JobStaleAfter is how long a running job may go without recording progress before
+	// it is taken to have been interrupted and is resumed at startup
+	JobStaleAfter time.Duration
+
+	// Redirect SLOs: availability counts 5xx as bad, latency counts responses over the threshold as bad
+	SLOAvailabilityObjective float64
+	SLOLatencyObjective      float64
+	SLOLatencyThreshold      time.Duration
+
+	// Alerting evaluates the built-in health rules every AlertInterval and pushes alerts
+	// to Alertmanager and/or PagerDuty; off unless one of them is set. A zero threshold
+	// turns its rule off.
+	AlertInterval       time.Duration
+	AlertmanagerURL     string
+	PagerDutyRoutingKey string
+	PagerDutyEventsURL  string
+	AlertInstance       string
+	AlertErrorRate      float64
+	AlertQueueDepth     int
+	AlertCacheHitRatio  float64
+	AlertReplicationLag time.Duration
+
+	// Trending links: clicks count towards the leaderboard with a weight halving every
+	// TrendingHalfLife, and TrendingMaxLinks links are kept; a zero half-life disables it
+	TrendingHalfLife time.Duration
+	TrendingMaxLinks int
+
+	// Redirect audit: percentage of redirects whose full decision (rule, A/B bucket, stage
+	// timings) is recorded, and how many records are kept; 0 percent disables it
+	RedirectAuditPercent    float64
+	RedirectAuditMaxEntries int
+
+	// Canary release: percentage of visitors sampled into the canary cohort, and the
+	// header/cookie that pin a request to a cohort explicitly. CanaryFeatures run only
+	// for the canary cohort.
+	CanaryPercent  float64
+	CanaryHeader   string
+	CanaryCookie   string
+	CanaryFeatures []string
+
+	// Cross-link attribution: a first-party visitor cookie set on redirect, honouring
+	// GPC/DNT and, when AttributionConsentCookie is set, only after consent is granted
+	AttributionEnabled       bool
+	AttributionCookie        string
+	AttributionCookieTTL     time.Duration
+	AttributionConsentCookie string
+
+	// LogShortCodes is "plain" or "hashed"; hashed logs a keyed hash of short codes and no
+	// destinations, with LogHashKey shared by all instances so logs can be correlated
+	LogShortCodes string
+	LogHashKey    string
+
+	// Access logs go to the application log ("app"), or as JSON lines to a rotating file
+	// ("file") or syslog ("syslog"), separate from application logs
+	AccessLogOutput     string
+	AccessLogFile       string
+	AccessLogMaxSizeMB  int
+	AccessLogMaxAge     time.Duration
+	AccessLogMaxBackups int
+	AccessLogSyslogAddr string
+	AccessLogSyslogTag  string
+
+	// ReadOnlyMode forces maintenance mode regardless of the runtime admin switch
+	ReadOnlyMode          bool
+	MaintenanceRetryAfter time.Duration
+
+	// Fault injection delays or fails a fraction of Redis and Postgres calls to exercise
+	// fallbacks in staging; it is always off in production
+	FaultInjectionEnabled  bool
+	FaultRedisErrorRate    float64
+	FaultRedisDelayRate    float64
+	FaultRedisDelay        time.Duration
+	FaultPostgresErrorRate float64
+	FaultPostgresDelayRate float64
+	FaultPostgresDelay     time.Duration
 }
 
 func Load() *Config {
 	// Load .env file if it exists
 	_ = godotenv.Load()
 
+	environment := getEnv("ENVIRONMENT", "development")
+
+	// Small pools outside production so local and staging databases are not overwhelmed
+	maxOpenConns, maxIdleConns := 10, 5
+	if environment == "production" {
+		maxOpenConns, maxIdleConns = 50, 25
+	}
+
 	return &Config{
 		Port:        getEnv("PORT", "8080"),
-		Environment: getEnv("ENVIRONMENT", "development"),
+		Environment: environment,
 		DatabaseURL: getEnv("DATABASE_URL", "postgres://localhost:5432/urlshortener?sslmode=disable"),
 		RedisURL:    getEnv("REDIS_URL", "redis://localhost:6379"),
 		BaseURL:     getEnv("BASE_URL", "http://localhost:8080"),
+
+		CacheBackend:      getEnv("CACHE_BACKEND", "redis"),
+		CacheLocalEntries: getEnvInt("CACHE_LOCAL_ENTRIES", 10000),
+		CacheLocalTTL:     getEnvDuration("CACHE_LOCAL_TTL", 5*time.Second),
+		NotFoundCacheTTL:  getEnvDuration("NOT_FOUND_CACHE_TTL", 30*time.Second),
+
+		CacheEarlyRefreshBeta: getEnvFloat("CACHE_EARLY_REFRESH_BETA", 0),
+
+		DBMaxOpenConns:    getEnvInt("DB_MAX_OPEN_CONNS", maxOpenConns),
+		DBMaxIdleConns:    getEnvInt("DB_MAX_IDLE_CONNS", maxIdleConns),
+		DBConnMaxLifetime: getEnvDuration("DB_CONN_MAX_LIFETIME", time.Hour),
+		DBConnMaxIdleTime: getEnvDuration("DB_CONN_MAX_IDLE_TIME", 30*time.Minute),
+		DBQueryTimeout:    getEnvDuration("DB_QUERY_TIMEOUT", 5*time.Second),
+		RedisTimeout:      getEnvDuration("REDIS_TIMEOUT", time.Second),
+
+		ShortCodeChecksum: getEnvBool("SHORT_CODE_CHECKSUM", false),
+		ShortCodeRandom:   getEnvBool("SHORT_CODE_RANDOM", false),
+		ShortCodeLength:   getEnvInt("SHORT_CODE_LENGTH", 7),
+		WordCodeList:      getEnv("WORD_CODE_LIST", ""),
+		WordCodeWords:     getEnvInt("WORD_CODE_WORDS", 2),
+		WordCodeDigits:    getEnvInt("WORD_CODE_DIGITS", 2),
+		WordCodeSeparator: getEnv("WORD_CODE_SEPARATOR", "-"),
+
+		DeterministicCodeKey:    getEnv("DETERMINISTIC_CODE_KEY", ""),
+		DeterministicCodeLength: getEnvInt("DETERMINISTIC_CODE_LENGTH", 8),
+
+		EmojiAliases:      getEnvBool("EMOJI_ALIASES", false),
+		DeduplicateURLs:   getEnvBool("DEDUPLICATE_URLS", false),
+		IdempotencyKeyTTL: getEnvDuration("IDEMPOTENCY_KEY_TTL", 24*time.Hour),
+		SMSMaxURLLength:   getEnvInt("SMS_MAX_URL_LENGTH", 30),
+
+		BlockedDomains:   getEnvList("BLOCKED_DOMAINS"),
+		InternalNetworks: getEnvList("INTERNAL_NETWORKS"),
+		TrustedProxies:   getEnvList("TRUSTED_PROXIES"),
+
+		NormalizeForceHTTPS:         getEnvBool("NORMALIZE_FORCE_HTTPS", false),
+		NormalizeStripTrailingSlash: getEnvBool("NORMALIZE_STRIP_TRAILING_SLASH", true),
+		NormalizeStripFragment:      getEnvBool("NORMALIZE_STRIP_FRAGMENT", false),
+		NormalizeLowercaseHost:      getEnvBool("NORMALIZE_LOWERCASE_HOST", false),
+		NormalizeSortQuery:          getEnvBool("NORMALIZE_SORT_QUERY", false),
+
+		MigrationLockTimeout: getEnvDuration("MIGRATION_LOCK_TIMEOUT", 5*time.Second),
+
+		InternalAddr:        getEnv("INTERNAL_ADDR", ""),
+		InternalTLSCert:     getEnv("INTERNAL_TLS_CERT", ""),
+		InternalTLSKey:      getEnv("INTERNAL_TLS_KEY", ""),
+		InternalClientCA:    getEnv("INTERNAL_CLIENT_CA", ""),
+		InternalAllowedSANs: getEnvList("INTERNAL_ALLOWED_SANS"),
+
+		SafeBrowsingAPIKey:         getEnv("SAFE_BROWSING_API_KEY", ""),
+		SafeBrowsingEndpoint:       getEnv("SAFE_BROWSING_ENDPOINT", "https://safebrowsing.googleapis.com/v4/threatMatches:find"),
+		SafeBrowsingAction:         getEnv("SAFE_BROWSING_ACTION", "reject"),
+		SafeBrowsingCacheTTL:       getEnvDuration("SAFE_BROWSING_CACHE_TTL", time.Hour),
+		SafeBrowsingRescanInterval: getEnvDuration("SAFE_BROWSING_RESCAN_INTERVAL", 24*time.Hour),
+
+		LinkValidatorURL:      getEnv("LINK_VALIDATOR_URL", ""),
+		LinkValidatorSecret:   getEnv("LINK_VALIDATOR_SECRET", ""),
+		LinkValidatorTimeout:  getEnvDuration("LINK_VALIDATOR_TIMEOUT", 2*time.Second),
+		LinkValidatorFailOpen: getEnvBool("LINK_VALIDATOR_FAIL_OPEN", false),
+
+		ComplianceSensitiveDomains: getEnvList("COMPLIANCE_SENSITIVE_DOMAINS"),
+
+		TakedownAutoDisable: getEnvBool("TAKEDOWN_AUTO_DISABLE", false),
+
+		GoalCheckInterval: getEnvDuration("GOAL_CHECK_INTERVAL", 5*time.Minute),
+
+		ReportPeriods:    getEnvList("REPORT_PERIODS"),
+		ReportBrandName:  getEnv("REPORT_BRAND_NAME", "URL Shortener"),
+		ReportBrandColor: getEnv("REPORT_BRAND_COLOR", "#2563eb"),
+		ReportLogoURL:    getEnv("REPORT_LOGO_URL", ""),
+
+		SegmentAPIURL:   getEnv("SEGMENT_API_URL", "https://api.segment.io/v1/batch"),
+		AmplitudeAPIURL: getEnv("AMPLITUDE_API_URL", "https://api2.amplitude.com/batch"),
+
+		EventStream:       getEnv("EVENT_STREAM", ""),
+		StreamClickTopic:  getEnv("EVENT_STREAM_CLICK_TOPIC", "urlshortener.clicks"),
+		StreamLinkTopic:   getEnv("EVENT_STREAM_LINK_TOPIC", "urlshortener.links"),
+		KafkaRESTURL:      getEnv("KAFKA_REST_URL", ""),
+		KafkaRESTUsername: getEnv("KAFKA_REST_USERNAME", ""),
+		KafkaRESTPassword: getEnv("KAFKA_REST_PASSWORD", ""),
+
+		TelemetryEnabled:  getEnvBool("TELEMETRY_ENABLED", false) && !getEnvBool("DO_NOT_TRACK", false),
+		TelemetryEndpoint: getEnv("TELEMETRY_ENDPOINT", ""),
+
+		UpdateCheckEnabled:  getEnvBool("UPDATE_CHECK_ENABLED", false),
+		UpdateCheckURL:      getEnv("UPDATE_CHECK_URL", "https://api.github.com/repos/alexnthnz/url-shortener/releases/latest"),
+		UpdateCheckInterval: getEnvDuration("UPDATE_CHECK_INTERVAL", 24*time.Hour),
+
+		HealthCheckTimeout: getEnvDuration("HEALTH_CHECK_TIMEOUT", 2*time.Second),
+
+		RateLimitWindow:   getEnvDuration("RATE_LIMIT_WINDOW", time.Minute),
+		RateLimitDefault:  getEnvInt("RATE_LIMIT_DEFAULT", 100),
+		RateLimitShorten:  getEnvInt("RATE_LIMIT_SHORTEN", 100),
+		RateLimitRedirect: getEnvInt("RATE_LIMIT_REDIRECT", 100),
+		RateLimitStats:    getEnvInt("RATE_LIMIT_STATS", 100),
+
+		AliasClaimIPLimit:  getEnvInt("ALIAS_CLAIM_IP_LIMIT", 10),
+		AliasClaimKeyLimit: getEnvInt("ALIAS_CLAIM_KEY_LIMIT", 100),
+		AliasClaimCooldown: getEnvDuration("ALIAS_CLAIM_COOLDOWN", 10*time.Second),
+
+		LoginIPMaxFailures:      getEnvInt("LOGIN_IP_MAX_FAILURES", 5),
+		LoginAccountMaxFailures: getEnvInt("LOGIN_ACCOUNT_MAX_FAILURES", 0),
+		LoginFailureWindow:      getEnvDuration("LOGIN_FAILURE_WINDOW", 15*time.Minute),
+		LoginLockout:            getEnvDuration("LOGIN_LOCKOUT", time.Minute),
+		LoginMaxLockout:         getEnvDuration("LOGIN_MAX_LOCKOUT", time.Hour),
+
+		AdminToken:         getEnv("ADMIN_TOKEN", ""),
+		QuickShortenTokens: getEnvList("QUICK_SHORTEN_TOKENS"),
+		SharePagesEnabled:  getEnvBool("SHARE_PAGES_ENABLED", false),
+		APIDocsEnabled:     getEnvBool("API_DOCS_ENABLED", true),
+		SwaggerUIURL:       getEnv("SWAGGER_UI_URL", "https://unpkg.com/swagger-ui-dist@5"),
+
+		RequestSigningKeys:     getEnv("REQUEST_SIGNING_KEYS", ""),
+		RequestSigningWindow:   getEnvDuration("REQUEST_SIGNING_WINDOW", 5*time.Minute),
+		RequestSigningRequired: getEnvBool("REQUEST_SIGNING_REQUIRED", false),
+
+		RequestSigningKeyScopes:  getEnvList("REQUEST_SIGNING_KEY_SCOPES"),
+		RequestSigningKeyDomains: getEnvList("REQUEST_SIGNING_KEY_DOMAINS"),
+		RequestSigningKeyExpiry:  getEnvList("REQUEST_SIGNING_KEY_EXPIRY"),
+		SigningKeyStaleAfter:     getEnvDuration("SIGNING_KEY_STALE_AFTER", 90*24*time.Hour),
+
+		WidgetSigningKey: getEnv("WIDGET_SIGNING_KEY", ""),
+
+		AnalyticsMirrorDatabaseURL: getEnv("ANALYTICS_MIRROR_DATABASE_URL", ""),
+
+		PIIEncryptionKeys:      getEnv("PII_ENCRYPTION_KEYS", ""),
+		PIIEncryptionActiveKey: getEnv("PII_ENCRYPTION_ACTIVE_KEY", ""),
+		PIIBlindIndexKey:       getEnv("PII_BLIND_INDEX_KEY", ""),
+
+		AnalyticsRetentionDays: getEnvInt("ANALYTICS_RETENTION_DAYS", 0),
+		PurgeBatchSize:         getEnvInt("PURGE_BATCH_SIZE", 1000),
+		PurgeBatchPause:        getEnvDuration("PURGE_BATCH_PAUSE", 100*time.Millisecond),
+		JobStaleAfter:          getEnvDuration("JOB_STALE_AFTER", 10*time.Minute),
+
+		SLOAvailabilityObjective: getEnvFloat("SLO_AVAILABILITY_OBJECTIVE", 0.999),
+		SLOLatencyObjective:      getEnvFloat("SLO_LATENCY_OBJECTIVE", 0.99),
+		SLOLatencyThreshold:      getEnvDuration("SLO_LATENCY_THRESHOLD", 100*time.Millisecond),
+
+		AlertInterval:       getEnvDuration("ALERT_INTERVAL", time.Minute),
+		AlertmanagerURL:     getEnv("ALERTMANAGER_URL", ""),
+		PagerDutyRoutingKey: getEnv("PAGERDUTY_ROUTING_KEY", ""),
+		PagerDutyEventsURL:  getEnv("PAGERDUTY_EVENTS_URL", "https://events.pagerduty.com/v2/enqueue"),
+		AlertInstance:       getEnv("ALERT_INSTANCE", ""),
+		AlertErrorRate:      getEnvFloat("ALERT_ERROR_RATE", 0.05),
+		AlertQueueDepth:     getEnvInt("ALERT_QUEUE_DEPTH", 5000),
+		AlertCacheHitRatio:  getEnvFloat("ALERT_CACHE_HIT_RATIO", 0.5),
+		AlertReplicationLag: getEnvDuration("ALERT_REPLICATION_LAG", 30*time.Second),
+
+		TrendingHalfLife: getEnvDuration("TRENDING_HALF_LIFE", time.Hour),
+		TrendingMaxLinks: getEnvInt("TRENDING_MAX_LINKS", 1000),
+
+		RedirectAuditPercent:    getEnvFloat("REDIRECT_AUDIT_PERCENT", 0),
+		RedirectAuditMaxEntries: getEnvInt("REDIRECT_AUDIT_MAX_ENTRIES", 1000),
+
+		CanaryPercent:  getEnvFloat("CANARY_PERCENT", 0),
+		CanaryHeader:   getEnv("CANARY_HEADER", "X-Canary"),
+		CanaryCookie:   getEnv("CANARY_COOKIE", "canary"),
+		CanaryFeatures: getEnvList("CANARY_FEATURES"),
+
+		AttributionEnabled:       getEnvBool("ATTRIBUTION_ENABLED", false),
+		AttributionCookie:        getEnv("ATTRIBUTION_COOKIE", "visitor_id"),
+		AttributionCookieTTL:     getEnvDuration("ATTRIBUTION_COOKIE_TTL", 30*24*time.Hour),
+		AttributionConsentCookie: getEnv("ATTRIBUTION_CONSENT_COOKIE", ""),
+
+		LogShortCodes: getEnv("LOG_SHORT_CODES", "plain"),
+		LogHashKey:    getEnv("LOG_HASH_KEY", ""),
+
+		AccessLogOutput:     getEnv("ACCESS_LOG_OUTPUT", "app"),
+		AccessLogFile:       getEnv("ACCESS_LOG_FILE", "/var/log/urlshortener/access.log"),
+		AccessLogMaxSizeMB:  getEnvInt("ACCESS_LOG_MAX_SIZE_MB", 100),
+		AccessLogMaxAge:     getEnvDuration("ACCESS_LOG_MAX_AGE", 24*time.Hour),
+		AccessLogMaxBackups: getEnvInt("ACCESS_LOG_MAX_BACKUPS", 7),
+		AccessLogSyslogAddr: getEnv("ACCESS_LOG_SYSLOG_ADDR", ""),
+		AccessLogSyslogTag:  getEnv("ACCESS_LOG_SYSLOG_TAG", "urlshortener-access"),
+
+		ReadOnlyMode:          getEnvBool("READ_ONLY_MODE", false),
+		MaintenanceRetryAfter: getEnvDuration("MAINTENANCE_RETRY_AFTER", 2*time.Minute),
+
+		FaultInjectionEnabled:  environment != "production" && getEnvBool("FAULT_INJECTION_ENABLED", false),
+		FaultRedisErrorRate:    getEnvFloat("FAULT_REDIS_ERROR_RATE", 0),
+		FaultRedisDelayRate:    getEnvFloat("FAULT_REDIS_DELAY_RATE", 0),
+		FaultRedisDelay:        getEnvDuration("FAULT_REDIS_DELAY", 0),
+		FaultPostgresErrorRate: getEnvFloat("FAULT_POSTGRES_ERROR_RATE", 0),
+		FaultPostgresDelayRate: getEnvFloat("FAULT_POSTGRES_DELAY_RATE", 0),
+		FaultPostgresDelay:     getEnvDuration("FAULT_POSTGRES_DELAY", 0),
 	}
 }
 
@@ -33,3 +521,42 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value, err := strconv.ParseFloat(os.Getenv(key), 64); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value, err := strconv.ParseBool(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value, err := time.ParseDuration(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// getEnvList reads a comma-separated list, skipping empty entries
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
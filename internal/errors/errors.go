@@ -0,0 +1,89 @@
+// Package errors defines the kinds of errors services return, so handlers pick responses
+// with errors.Is instead of matching on messages. Errors keep their messages; the kind is
+// attached with Errorf or Wrap, or by wrapping a specific kind with fmt.Errorf and %w.
+package errors
+
+import (
+	"errors"
+	"fmt"
+)
+
+// General kinds; every error a client can act on is one of them
+var (
+	// ErrInvalid is a request that fails validation
+	ErrInvalid = errors.New("invalid request")
+	// ErrUnauthorized is a request whose credentials or signature are rejected
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrNotFound is a request for something that does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is a request the current state of its target does not allow
+	ErrConflict = errors.New("conflict")
+	// ErrExpired is a request for something that existed but is used up
+	ErrExpired = errors.New("expired")
+	// ErrMismatch is a retried request that differs from the original
+	ErrMismatch = errors.New("request mismatch")
+	// ErrNotActive is a request for a link before its activation time
+	ErrNotActive = errors.New("link not active")
+	// ErrDisabled is a request for a link disabled after a complaint
+	ErrDisabled = errors.New("disabled")
+	// ErrNotConfigured is a request for a feature the instance has not set up
+	ErrNotConfigured = errors.New("not configured")
+)
+
+// Specific kinds, each also matching the general kind it refines
+var (
+	ErrInvalidURL  = refine(ErrInvalid, "invalid URL")
+	ErrAliasExists = refine(ErrInvalid, "custom alias already exists")
+	ErrRejected    = refine(ErrInvalid, "rejected by link policy")
+	ErrClickLimit  = refine(ErrExpired, "click limit reached")
+)
+
+// kindError is an error of a kind with its own message
+type kindError struct {
+	kind error
+	err  error
+}
+
+func (e *kindError) Error() string {
+	return e.err.Error()
+}
+
+func (e *kindError) Unwrap() []error {
+	return []error{e.kind, e.err}
+}
+
+// Errorf formats an error of a kind; %w wraps an error as with fmt.Errorf
+func Errorf(kind error, format string, args ...interface{}) error {
+	return &kindError{kind: kind, err: fmt.Errorf(format, args...)}
+}
+
+// Wrap marks err as being of a kind, keeping its message. It returns nil for a nil err.
+func Wrap(kind, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &kindError{kind: kind, err: err}
+}
+
+// refinedError is a specific kind of error with its own message
+type refinedError struct {
+	parent  error
+	message string
+}
+
+func refine(parent error, message string) error {
+	return &refinedError{parent: parent, message: message}
+}
+
+func (e *refinedError) Error() string {
+	return e.message
+}
+
+func (e *refinedError) Unwrap() error {
+	return e.parent
+}
+
+// Is reports whether any error in err's tree matches target, as errors.Is does
+func Is(err, target error) bool {
+	return errors.Is(err, target)
+}
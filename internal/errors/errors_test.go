@@ -0,0 +1,86 @@
+package errors
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestKinds(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		kinds    []error
+		notKinds []error
+		message  string
+	}{
+		{
+			name:     "Errorf",
+			err:      Errorf(ErrInvalid, "invalid max clicks: %d", -1),
+			kinds:    []error{ErrInvalid},
+			notKinds: []error{ErrInvalidURL, ErrNotFound},
+			message:  "invalid max clicks: -1",
+		},
+		{
+			name:     "specific kind",
+			err:      Errorf(ErrInvalidURL, "invalid URL: missing host"),
+			kinds:    []error{ErrInvalidURL, ErrInvalid},
+			notKinds: []error{ErrAliasExists},
+			message:  "invalid URL: missing host",
+		},
+		{
+			name:    "wrapped cause",
+			err:     Errorf(ErrClickLimit, "click limit reached: %w", errors.New("limit 10")),
+			kinds:   []error{ErrClickLimit, ErrExpired},
+			message: "click limit reached: limit 10",
+		},
+		{
+			name:     "Wrap",
+			err:      Wrap(ErrNotConfigured, errors.New("alerting is not configured")),
+			kinds:    []error{ErrNotConfigured},
+			notKinds: []error{ErrNotFound},
+			message:  "alerting is not configured",
+		},
+		{
+			name:    "wrapped by fmt.Errorf",
+			err:     fmt.Errorf("resolve redirect: %w", Errorf(ErrNotFound, "URL not found")),
+			kinds:   []error{ErrNotFound},
+			message: "resolve redirect: URL not found",
+		},
+		{
+			name:     "not active is not not found",
+			err:      ErrNotActive,
+			kinds:    []error{ErrNotActive},
+			notKinds: []error{ErrNotFound},
+			message:  "link not active",
+		},
+		{
+			name:     "plain error",
+			err:      errors.New("connection refused"),
+			notKinds: []error{ErrInvalid, ErrNotFound, ErrConflict},
+			message:  "connection refused",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.err.Error() != tt.message {
+				t.Errorf("message = %q, want %q", tt.err.Error(), tt.message)
+			}
+			for _, kind := range tt.kinds {
+				if !Is(tt.err, kind) {
+					t.Errorf("error is not %v", kind)
+				}
+			}
+			for _, kind := range tt.notKinds {
+				if Is(tt.err, kind) {
+					t.Errorf("error is %v", kind)
+				}
+			}
+		})
+	}
+
+	if Wrap(ErrInvalid, nil) != nil {
+		t.Error("Wrap of nil error is not nil")
+	}
+}
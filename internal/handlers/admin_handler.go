@@ -0,0 +1,527 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alexnthnz/url-shortener/internal/models"
+	"github.com/alexnthnz/url-shortener/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	maxUsageDays     = 365
+	maxLinkListLimit = 100
+)
+
+type AdminHandler struct {
+	usageService     *services.UsageService
+	jobService       *services.JobService
+	retentionService *services.RetentionService
+	clientBackfill   *services.ClientBackfillService
+	maintenance      *services.MaintenanceService
+	privacyService   *services.PrivacyService
+	encryption       *services.EncryptionService
+	compliance       *services.ComplianceService
+	telemetry        *services.TelemetryService
+	rateLimits       *services.RateLimitService
+	domainPolicies   *services.DomainPolicyService
+	aliasClaims      *services.AliasClaimService
+	safeBrowsing     *services.SafeBrowsingService
+	redirectAudit    *services.RedirectAuditService
+	signingKeys      *services.SigningKeyService
+	logger           *logrus.Logger
+}
+
+func NewAdminHandler(usageService *services.UsageService, jobService *services.JobService, retentionService *services.RetentionService, clientBackfill *services.ClientBackfillService, maintenance *services.MaintenanceService, privacyService *services.PrivacyService, encryption *services.EncryptionService, compliance *services.ComplianceService, telemetry *services.TelemetryService, rateLimits *services.RateLimitService, domainPolicies *services.DomainPolicyService, aliasClaims *services.AliasClaimService, safeBrowsing *services.SafeBrowsingService, redirectAudit *services.RedirectAuditService, signingKeys *services.SigningKeyService, logger *logrus.Logger) *AdminHandler {
+	return &AdminHandler{
+		usageService:     usageService,
+		jobService:       jobService,
+		retentionService: retentionService,
+		clientBackfill:   clientBackfill,
+		maintenance:      maintenance,
+		privacyService:   privacyService,
+		encryption:       encryption,
+		compliance:       compliance,
+		telemetry:        telemetry,
+		rateLimits:       rateLimits,
+		domainPolicies:   domainPolicies,
+		aliasClaims:      aliasClaims,
+		safeBrowsing:     safeBrowsing,
+		redirectAudit:    redirectAudit,
+		signingKeys:      signingKeys,
+		logger:           logger,
+	}
+}
+
+// GetUsage handles GET /api/v1/admin/usage
+func (h *AdminHandler) GetUsage(c *gin.Context) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if err != nil || days < 1 || days > maxUsageDays {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "days must be between 1 and 365"})
+		return
+	}
+
+	report, err := h.usageService.GetUsageReport(c.Request.Context(), days)
+	if err != nil {
+		h.logger.Errorf("Failed to get usage report: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve usage report"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// GetTotals handles GET /api/v1/admin/stats
+func (h *AdminHandler) GetTotals(c *gin.Context) {
+	totals, err := h.usageService.GetTotals(c.Request.Context())
+	if err != nil {
+		h.logger.Errorf("Failed to get totals: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve totals"})
+		return
+	}
+
+	c.JSON(http.StatusOK, totals)
+}
+
+// GetTopLinks handles GET /api/v1/admin/links/top
+func (h *AdminHandler) GetTopLinks(c *gin.Context) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if err != nil || days < 1 || days > maxUsageDays {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "days must be between 1 and 365"})
+		return
+	}
+	limit, ok := linkListLimit(c)
+	if !ok {
+		return
+	}
+
+	links, err := h.usageService.GetTopLinks(c.Request.Context(), days, limit)
+	if err != nil {
+		h.logger.Errorf("Failed to get top links: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve top links"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"days": days, "links": links})
+}
+
+// GetRecentLinks handles GET /api/v1/admin/links/recent
+func (h *AdminHandler) GetRecentLinks(c *gin.Context) {
+	limit, ok := linkListLimit(c)
+	if !ok {
+		return
+	}
+
+	links, err := h.usageService.GetRecentLinks(c.Request.Context(), limit)
+	if err != nil {
+		h.logger.Errorf("Failed to list recent links: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list recent links"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"links": links})
+}
+
+// linkListLimit parses the limit query parameter of admin link listings
+func linkListLimit(c *gin.Context) (int, bool) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > maxLinkListLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 100"})
+		return 0, false
+	}
+	return limit, true
+}
+
+// GetRedirectAudit handles GET /api/v1/admin/redirect-audit, listing sampled redirect
+// decisions newest first, optionally of one link
+func (h *AdminHandler) GetRedirectAudit(c *gin.Context) {
+	limit, ok := linkListLimit(c)
+	if !ok {
+		return
+	}
+
+	shortCode := services.NormalizeShortCode(c.Query("short_code"))
+	audits, err := h.redirectAudit.List(c.Request.Context(), shortCode, limit)
+	if err != nil {
+		h.logger.Errorf("Failed to list redirect audits: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list redirect audits"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"enabled": h.redirectAudit.Enabled(), "decisions": audits})
+}
+
+// ListJobs handles GET /api/v1/admin/jobs
+func (h *AdminHandler) ListJobs(c *gin.Context) {
+	jobs, err := h.jobService.ListJobs()
+	if err != nil {
+		h.logger.Errorf("Failed to list jobs: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list jobs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"jobs": jobs})
+}
+
+// GetJob handles GET /api/v1/admin/jobs/:id
+func (h *AdminHandler) GetJob(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job ID"})
+		return
+	}
+
+	job, err := h.jobService.GetJob(id)
+	if err != nil {
+		abortWithError(c, err, "Failed to retrieve job")
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// RunRetentionPurge handles POST /api/v1/admin/retention/purge
+func (h *AdminHandler) RunRetentionPurge(c *gin.Context) {
+	job, err := h.retentionService.RunPurge()
+	if err != nil {
+		abortWithError(c, err, "Failed to start retention purge")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, job)
+}
+
+// RunClientBackfill handles POST /api/v1/admin/analytics/client-backfill, parsing the
+// device type, browser and OS of clicks recorded before user agents were parsed
+func (h *AdminHandler) RunClientBackfill(c *gin.Context) {
+	job, err := h.clientBackfill.Run()
+	if err != nil {
+		abortWithError(c, err, "Failed to start client backfill")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, job)
+}
+
+// RunSafeBrowsingRescan handles POST /api/v1/admin/safe-browsing/rescan
+func (h *AdminHandler) RunSafeBrowsingRescan(c *gin.Context) {
+	job, err := h.safeBrowsing.RunRescan()
+	if err != nil {
+		abortWithError(c, err, "Failed to start Safe Browsing rescan")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, job)
+}
+
+// GetMaintenance handles GET /api/v1/admin/maintenance
+func (h *AdminHandler) GetMaintenance(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"read_only": h.maintenance.ReadOnly()})
+}
+
+// SetMaintenance handles PUT /api/v1/admin/maintenance
+func (h *AdminHandler) SetMaintenance(c *gin.Context) {
+	var req models.MaintenanceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	if err := h.maintenance.SetReadOnly(c.Request.Context(), *req.ReadOnly); err != nil {
+		h.logger.Errorf("Failed to set maintenance mode: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update maintenance mode"})
+		return
+	}
+
+	// A config-forced read-only mode cannot be lifted at runtime
+	c.JSON(http.StatusOK, gin.H{"read_only": h.maintenance.ReadOnly()})
+}
+
+// GetPrivacyReport handles GET /api/v1/admin/privacy/report?ip=|visitor_id=, returning
+// every stored click referencing the data subject as a downloadable JSON file
+func (h *AdminHandler) GetPrivacyReport(c *gin.Context) {
+	subject := models.DataSubject{IPAddress: c.Query("ip"), VisitorID: c.Query("visitor_id")}
+
+	report, err := h.privacyService.BuildReport(c.Request.Context(), subject)
+	if err != nil {
+		abortWithError(c, err, "Failed to build privacy report")
+		return
+	}
+
+	filename := "dsar-" + report.GeneratedAt.Format("20060102-150405") + ".json"
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, report)
+}
+
+// ErasePrivacyData handles POST /api/v1/admin/privacy/erase?ip=|visitor_id=
+func (h *AdminHandler) ErasePrivacyData(c *gin.Context) {
+	subject := models.DataSubject{IPAddress: c.Query("ip"), VisitorID: c.Query("visitor_id")}
+
+	result, err := h.privacyService.Erase(c.Request.Context(), subject)
+	if err != nil {
+		if ErrorStatus(err) != http.StatusInternalServerError {
+			abortWithError(c, err, "")
+			return
+		}
+
+		h.logger.Errorf("Failed to erase privacy data: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to erase data, retry to resume", "partial": result})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// RotateEncryptionKey handles POST /api/v1/admin/encryption/rotate
+func (h *AdminHandler) RotateEncryptionKey(c *gin.Context) {
+	h.startEncryptionJob(c, h.encryption.Rotate)
+}
+
+// ReencryptAnalytics handles POST /api/v1/admin/encryption/reencrypt, sealing clicks
+// stored before encryption was enabled without rotating the data key
+func (h *AdminHandler) ReencryptAnalytics(c *gin.Context) {
+	h.startEncryptionJob(c, h.encryption.Reencrypt)
+}
+
+func (h *AdminHandler) startEncryptionJob(c *gin.Context, start func() (*models.Job, error)) {
+	job, err := start()
+	if err != nil {
+		abortWithError(c, err, "Failed to start re-encryption")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, job)
+}
+
+// ExportComplianceLog handles GET /api/v1/admin/compliance/redirects?from=&to=&format=csv|jsonl,
+// streaming the redirects to sensitive domains within [from, to). Dates are RFC 3339
+// timestamps or YYYY-MM-DD days in UTC; to defaults to now.
+func (h *AdminHandler) ExportComplianceLog(c *gin.Context) {
+	from, err := parseTimeParam(c.Query("from"), time.Time{})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be an RFC 3339 timestamp or YYYY-MM-DD"})
+		return
+	}
+	to, err := parseTimeParam(c.Query("to"), time.Now().UTC())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to must be an RFC 3339 timestamp or YYYY-MM-DD"})
+		return
+	}
+	if !to.After(from) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to must be after from"})
+		return
+	}
+
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "jsonl" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or jsonl"})
+		return
+	}
+
+	filename := fmt.Sprintf("compliance-redirects-%s-%s.%s", from.Format("20060102"), to.Format("20060102"), format)
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Header("Cache-Control", "no-store")
+
+	var emit func(*models.ComplianceRedirect) error
+	var flush func() error
+	if format == "csv" {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		writer := csv.NewWriter(c.Writer)
+		writer.Write([]string{"id", "occurred_at", "short_code", "click_id", "destination", "destination_host", "matched_domain", "visitor_country"})
+		emit = func(r *models.ComplianceRedirect) error {
+			return writer.Write([]string{strconv.FormatInt(r.ID, 10), r.OccurredAt.UTC().Format(time.RFC3339),
+				r.ShortCode, r.ClickID, r.Destination, r.DestinationHost, r.MatchedDomain, r.VisitorCountry})
+		}
+		flush = func() error {
+			writer.Flush()
+			return writer.Error()
+		}
+	} else {
+		c.Header("Content-Type", "application/x-ndjson")
+		encoder := json.NewEncoder(c.Writer)
+		emit = func(r *models.ComplianceRedirect) error { return encoder.Encode(r) }
+		flush = func() error { return nil }
+	}
+
+	c.Status(http.StatusOK)
+	if err := h.compliance.Export(from, to, emit); err != nil {
+		// Headers are already sent; a truncated file is the best signal left
+		h.logger.Errorf("Failed to export compliance log: %v", err)
+	}
+	if err := flush(); err != nil {
+		h.logger.Errorf("Failed to write compliance export: %v", err)
+	}
+}
+
+// parseTimeParam accepts an RFC 3339 timestamp or a YYYY-MM-DD day in UTC
+func parseTimeParam(value string, fallback time.Time) (time.Time, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+// GetTelemetry handles GET /api/v1/admin/telemetry, showing the usage heartbeat exactly
+// as it is sent so operators can review it before opting in
+func (h *AdminHandler) GetTelemetry(c *gin.Context) {
+	report, err := h.telemetry.Report(c.Request.Context())
+	if err != nil {
+		h.logger.Errorf("Failed to build telemetry report: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build telemetry report"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":  h.telemetry.Sending(),
+		"endpoint": h.telemetry.Endpoint(),
+		"report":   report,
+	})
+}
+
+// GetRateLimits handles GET /api/v1/admin/rate-limits with the tier limits and per-key overrides
+func (h *AdminHandler) GetRateLimits(c *gin.Context) {
+	overrides, err := h.rateLimits.ListOverrides()
+	if err != nil {
+		h.logger.Errorf("Failed to list rate limit overrides: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list rate limit overrides"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"window":    h.rateLimits.Window().String(),
+		"tiers":     h.rateLimits.Tiers(),
+		"overrides": overrides,
+	})
+}
+
+// SetRateLimitOverride handles PUT /api/v1/admin/rate-limits/keys/:key_id
+func (h *AdminHandler) SetRateLimitOverride(c *gin.Context) {
+	var req models.RateLimitOverrideRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	override, err := h.rateLimits.SetOverride(c.Param("key_id"), req.RequestsPerWindow)
+	if err != nil {
+		abortWithError(c, err, "Failed to set rate limit override")
+		return
+	}
+
+	c.JSON(http.StatusOK, override)
+}
+
+// DeleteRateLimitOverride handles DELETE /api/v1/admin/rate-limits/keys/:key_id
+func (h *AdminHandler) DeleteRateLimitOverride(c *gin.Context) {
+	if err := h.rateLimits.DeleteOverride(c.Param("key_id")); err != nil {
+		abortWithError(c, err, "Failed to delete rate limit override")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListSigningKeys handles GET /api/v1/admin/signing-keys?stale=&stale_after=, the request
+// signing keys with their expiry and last use; stale=true lists only the keys to rotate or
+// remove
+func (h *AdminHandler) ListSigningKeys(c *gin.Context) {
+	staleAfter := h.signingKeys.StaleAfter()
+	if raw := c.Query("stale_after"); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "stale_after must be a positive duration such as 720h"})
+			return
+		}
+		staleAfter = parsed
+	}
+	staleOnly, _ := strconv.ParseBool(c.Query("stale"))
+
+	keys, err := h.signingKeys.List(staleAfter, staleOnly)
+	if err != nil {
+		h.logger.Errorf("Failed to list signing keys: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list signing keys"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"stale_after": staleAfter.String(), "keys": keys})
+}
+
+// ListAliasClaims handles GET /api/v1/admin/alias-claims?actor=&outcome=&days=, the log of
+// custom alias claims and refusals, newest first
+func (h *AdminHandler) ListAliasClaims(c *gin.Context) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", "7"))
+	if err != nil || days < 1 || days > maxUsageDays {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "days must be between 1 and 365"})
+		return
+	}
+
+	claims, err := h.aliasClaims.List(c.Query("actor"), c.Query("outcome"), days)
+	if err != nil {
+		abortWithError(c, err, "Failed to list alias claims")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"claims": claims})
+}
+
+// ListDomainPolicies handles GET /api/v1/admin/domain-policies
+func (h *AdminHandler) ListDomainPolicies(c *gin.Context) {
+	policies, err := h.domainPolicies.List()
+	if err != nil {
+		h.logger.Errorf("Failed to list domain policies: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list domain policies"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"policies": policies})
+}
+
+// SetDomainPolicy handles PUT /api/v1/admin/domain-policies/:domain
+func (h *AdminHandler) SetDomainPolicy(c *gin.Context) {
+	var req models.DomainPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	policy, err := h.domainPolicies.Set(c.Param("domain"), req.Action, req.Reason)
+	if err != nil {
+		abortWithError(c, err, "Failed to set domain policy")
+		return
+	}
+
+	c.JSON(http.StatusOK, policy)
+}
+
+// DeleteDomainPolicy handles DELETE /api/v1/admin/domain-policies/:domain
+func (h *AdminHandler) DeleteDomainPolicy(c *gin.Context) {
+	if err := h.domainPolicies.Delete(c.Param("domain")); err != nil {
+		abortWithError(c, err, "Failed to delete domain policy")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetDomainPolicyViolations handles GET /api/v1/admin/domain-policies/violations, listing
+// existing links that the current policies refuse
+func (h *AdminHandler) GetDomainPolicyViolations(c *gin.Context) {
+	violations, err := h.domainPolicies.Violations(c.Request.Context())
+	if err != nil {
+		h.logger.Errorf("Failed to report domain policy violations: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to report domain policy violations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"violations": violations})
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/alexnthnz/url-shortener/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type AlertHandler struct {
+	alertService *services.AlertService
+	logger       *logrus.Logger
+}
+
+func NewAlertHandler(alertService *services.AlertService, logger *logrus.Logger) *AlertHandler {
+	return &AlertHandler{
+		alertService: alertService,
+		logger:       logger,
+	}
+}
+
+// GetAlerts handles GET /api/v1/admin/alerts, listing the alert rules and the alerts
+// firing on the instance that serves the request
+func (h *AlertHandler) GetAlerts(c *gin.Context) {
+	status, err := h.alertService.Status()
+	if err != nil {
+		abortWithError(c, err, "Failed to get alerts")
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
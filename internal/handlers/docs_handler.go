@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DocsHandler serves the OpenAPI specification of the API and a Swagger UI page to browse it
+type DocsHandler struct {
+	spec         []byte
+	swaggerUIURL string
+}
+
+// NewDocsHandler serves spec; swaggerUIURL is where the swagger-ui-dist assets are loaded
+// from, a CDN by default
+func NewDocsHandler(spec []byte, swaggerUIURL string) *DocsHandler {
+	return &DocsHandler{spec: spec, swaggerUIURL: strings.TrimSuffix(swaggerUIURL, "/")}
+}
+
+// OpenAPISpec handles GET /api/v1/openapi.json, for SDK generators and Swagger UI
+func (h *DocsHandler) OpenAPISpec(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.Data(http.StatusOK, "application/json; charset=utf-8", h.spec)
+}
+
+// SwaggerUI handles GET /docs, rendering the specification for browsing and trying requests
+func (h *DocsHandler) SwaggerUI(c *gin.Context) {
+	assets := html.EscapeString(h.swaggerUIURL)
+	page := fmt.Sprintf(`<!DOCTYPE html><html><head><meta charset="utf-8">`+
+		`<meta name="viewport" content="width=device-width, initial-scale=1"><title>URL Shortener API</title>`+
+		`<link rel="stylesheet" href="%s/swagger-ui.css"></head><body><div id="swagger-ui"></div>`+
+		`<script src="%s/swagger-ui-bundle.js"></script>`+
+		`<script>window.ui=SwaggerUIBundle({url:"/api/v1/openapi.json",dom_id:"#swagger-ui"})</script>`+
+		`</body></html>`, assets, assets)
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(page))
+}
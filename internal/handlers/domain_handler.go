@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/alexnthnz/url-shortener/internal/models"
+	"github.com/alexnthnz/url-shortener/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type DomainHandler struct {
+	domainService *services.DomainService
+	logger        *logrus.Logger
+}
+
+func NewDomainHandler(domainService *services.DomainService, logger *logrus.Logger) *DomainHandler {
+	return &DomainHandler{
+		domainService: domainService,
+		logger:        logger,
+	}
+}
+
+// RegisterDomain handles POST /api/v1/domains
+func (h *DomainHandler) RegisterDomain(c *gin.Context) {
+	owner, ok := domainOwner(c)
+	if !ok {
+		return
+	}
+
+	var req models.DomainRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	domain, err := h.domainService.Register(req.Domain, owner)
+	if err != nil {
+		abortWithError(c, err, "Failed to register domain")
+		return
+	}
+
+	c.JSON(http.StatusCreated, domain)
+}
+
+// ListDomains handles GET /api/v1/domains, listing the domains of the signing key
+func (h *DomainHandler) ListDomains(c *gin.Context) {
+	owner, ok := domainOwner(c)
+	if !ok {
+		return
+	}
+
+	domains, err := h.domainService.List(owner)
+	if err != nil {
+		h.logger.Errorf("Failed to list domains: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list domains"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"domains": domains})
+}
+
+// DeleteDomain handles DELETE /api/v1/domains/:domain
+func (h *DomainHandler) DeleteDomain(c *gin.Context) {
+	owner, ok := domainOwner(c)
+	if !ok {
+		return
+	}
+
+	if err := h.domainService.Delete(c.Param("domain"), owner); err != nil {
+		abortWithError(c, err, "Failed to delete domain")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// domainOwner returns the signing key that owns the domains of a request. Domains belong
+// to signing keys, so unsigned requests are refused.
+func domainOwner(c *gin.Context) (string, bool) {
+	actor := RequestActor(c)
+	if !strings.HasPrefix(actor, "key:") {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Custom domains require a signed request"})
+		return "", false
+	}
+	return actor, true
+}
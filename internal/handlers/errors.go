@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"net/http"
+	"unicode"
+	"unicode/utf8"
+
+	apperrors "github.com/alexnthnz/url-shortener/internal/errors"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// errorStatuses maps error kinds to responses; the first kind an error matches wins
+var errorStatuses = []struct {
+	kind   error
+	status int
+}{
+	{apperrors.ErrInvalid, http.StatusBadRequest},
+	{apperrors.ErrUnauthorized, http.StatusUnauthorized},
+	{apperrors.ErrNotFound, http.StatusNotFound},
+	{apperrors.ErrNotActive, http.StatusNotFound},
+	{apperrors.ErrConflict, http.StatusConflict},
+	{apperrors.ErrExpired, http.StatusGone},
+	{apperrors.ErrMismatch, http.StatusUnprocessableEntity},
+	{apperrors.ErrDisabled, http.StatusUnavailableForLegalReasons},
+	{apperrors.ErrNotConfigured, http.StatusNotImplemented},
+}
+
+// ErrorStatus returns the status an error is answered with, 500 for errors of no known kind
+func ErrorStatus(err error) int {
+	for _, entry := range errorStatuses {
+		if apperrors.Is(err, entry.kind) {
+			return entry.status
+		}
+	}
+	return http.StatusInternalServerError
+}
+
+// ErrorMiddleware answers the error a handler attached with abortWithError. Errors of a
+// known kind get their status and message; others are logged and answered with 500 and
+// the handler's message, so internal details do not leak.
+func ErrorMiddleware(logger *logrus.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		last := c.Errors.Last()
+		if last == nil || c.Writer.Written() {
+			return
+		}
+
+		message, _ := last.Meta.(string)
+		status := ErrorStatus(last.Err)
+		if status == http.StatusInternalServerError {
+			logger.Errorf("%s: %v", message, last.Err)
+		} else {
+			message = capitalize(last.Err.Error())
+		}
+		c.JSON(status, gin.H{"error": message})
+	}
+}
+
+// abortWithError hands err to ErrorMiddleware; message is the response to errors of no
+// known kind
+func abortWithError(c *gin.Context, err error, message string) {
+	c.Error(err).SetMeta(message)
+	c.Abort()
+}
+
+// capitalize starts an error message with a capital letter, like the other messages of
+// the API
+func capitalize(message string) string {
+	r, size := utf8.DecodeRuneInString(message)
+	return string(unicode.ToUpper(r)) + message[size:]
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/alexnthnz/url-shortener/internal/models"
+	"github.com/alexnthnz/url-shortener/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type EventDestinationHandler struct {
+	forwardingService *services.EventForwardingService
+	logger            *logrus.Logger
+}
+
+func NewEventDestinationHandler(forwardingService *services.EventForwardingService, logger *logrus.Logger) *EventDestinationHandler {
+	return &EventDestinationHandler{
+		forwardingService: forwardingService,
+		logger:            logger,
+	}
+}
+
+// ListEventDestinations handles GET /api/v1/admin/event-destinations
+func (h *EventDestinationHandler) ListEventDestinations(c *gin.Context) {
+	destinations, err := h.forwardingService.List()
+	if err != nil {
+		h.logger.Errorf("Failed to list event destinations: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list event destinations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"destinations": destinations})
+}
+
+// SetEventDestination handles PUT /api/v1/admin/event-destinations/:provider, setting up a
+// destination or rotating its API key
+func (h *EventDestinationHandler) SetEventDestination(c *gin.Context) {
+	var req models.EventDestinationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	destination, err := h.forwardingService.Set(c.Param("provider"), &req)
+	if err != nil {
+		abortWithError(c, err, "Failed to save event destination")
+		return
+	}
+
+	c.JSON(http.StatusOK, destination)
+}
+
+// DeleteEventDestination handles DELETE /api/v1/admin/event-destinations/:provider
+func (h *EventDestinationHandler) DeleteEventDestination(c *gin.Context) {
+	if err := h.forwardingService.Delete(c.Param("provider")); err != nil {
+		abortWithError(c, err, "Failed to delete event destination")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/alexnthnz/url-shortener/internal/models"
+	"github.com/alexnthnz/url-shortener/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type GoalHandler struct {
+	goalService *services.GoalService
+	logger      *logrus.Logger
+}
+
+func NewGoalHandler(goalService *services.GoalService, logger *logrus.Logger) *GoalHandler {
+	return &GoalHandler{
+		goalService: goalService,
+		logger:      logger,
+	}
+}
+
+// CreateGoal handles POST /api/v1/urls/:short_code/goals
+func (h *GoalHandler) CreateGoal(c *gin.Context) {
+	var req models.ClickGoalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	goal, err := h.goalService.Create(c.Request.Context(), services.NormalizeShortCode(c.Param("short_code")), &req)
+	if err != nil {
+		abortWithError(c, err, "Failed to create goal")
+		return
+	}
+
+	c.JSON(http.StatusCreated, goal)
+}
+
+// ListGoals handles GET /api/v1/urls/:short_code/goals
+func (h *GoalHandler) ListGoals(c *gin.Context) {
+	goals, err := h.goalService.List(c.Request.Context(), services.NormalizeShortCode(c.Param("short_code")))
+	if err != nil {
+		abortWithError(c, err, "Failed to list goals")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"goals": goals})
+}
+
+// DeleteGoal handles DELETE /api/v1/urls/:short_code/goals/:id
+func (h *GoalHandler) DeleteGoal(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid goal ID"})
+		return
+	}
+
+	if err := h.goalService.Delete(c.Request.Context(), services.NormalizeShortCode(c.Param("short_code")), id); err != nil {
+		abortWithError(c, err, "Failed to delete goal")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
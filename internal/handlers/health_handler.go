@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/alexnthnz/url-shortener/internal/buildinfo"
+	"github.com/alexnthnz/url-shortener/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+type HealthHandler struct {
+	healthService *services.HealthService
+	updateService *services.UpdateService
+}
+
+func NewHealthHandler(healthService *services.HealthService, updateService *services.UpdateService) *HealthHandler {
+	return &HealthHandler{healthService: healthService, updateService: updateService}
+}
+
+// HealthCheck handles GET /health, aggregating the checks registered by every subsystem.
+// Only failing critical checks return 503, so load balancers keep routing to an instance
+// that is merely degraded.
+func (h *HealthHandler) HealthCheck(c *gin.Context) {
+	report := h.healthService.Check(c.Request.Context())
+
+	httpStatus := http.StatusOK
+	if report.Status == services.HealthStatusUnhealthy {
+		httpStatus = http.StatusServiceUnavailable
+	}
+
+	service := gin.H{
+		"name":    "url-shortener",
+		"version": buildinfo.Version,
+		"uptime":  time.Since(startTime).String(),
+	}
+	if update := h.updateService.Status(); update.UpdateAvailable {
+		service["update_available"] = update.LatestVersion
+	}
+
+	c.JSON(httpStatus, gin.H{
+		"status":  report.Status,
+		"checks":  report.Checks,
+		"service": service,
+	})
+}
+
+// Version handles GET /api/v1/version with the build information and, when update
+// checks are enabled, the latest published release
+func (h *HealthHandler) Version(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"build":  buildinfo.Get(),
+		"update": h.updateService.Status(),
+	})
+}
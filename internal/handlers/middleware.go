@@ -1,27 +1,61 @@
 package handlers
 
 import (
+	"bytes"
+	"crypto/subtle"
 	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
-	"github.com/alexnthnz/url-shortener/internal/repository"
+	apperrors "github.com/alexnthnz/url-shortener/internal/errors"
+	"github.com/alexnthnz/url-shortener/internal/services"
+	"github.com/alexnthnz/url-shortener/pkg/signing"
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 )
 
-// LoggerMiddleware creates a Gin middleware for logging
-func LoggerMiddleware(logger *logrus.Logger) gin.HandlerFunc {
-	return gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
+// LoggerMiddleware creates a Gin middleware for logging. When privacy hashes short codes,
+// the logged path is the route pattern with the short code hashed, so neither the code
+// nor any passthrough path or query forwarded to the destination is written to the logs.
+func LoggerMiddleware(logger *logrus.Logger, privacy *services.LogPrivacy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
 		logger.WithFields(logrus.Fields{
-			"status":     param.StatusCode,
-			"method":     param.Method,
-			"path":       param.Path,
-			"ip":         param.ClientIP,
-			"latency":    param.Latency,
-			"user_agent": param.Request.UserAgent(),
+			"status":     c.Writer.Status(),
+			"method":     c.Request.Method,
+			"path":       logPath(c, privacy),
+			"ip":         c.ClientIP(),
+			"latency":    time.Since(start),
+			"user_agent": c.Request.UserAgent(),
 		}).Info("HTTP Request")
-		return ""
-	})
+	}
+}
+
+// logPath returns the request path as it may appear in logs
+func logPath(c *gin.Context, privacy *services.LogPrivacy) string {
+	if !privacy.HashCodes() {
+		path := c.Request.URL.Path
+		if c.Request.URL.RawQuery != "" {
+			path += "?" + c.Request.URL.RawQuery
+		}
+		return path
+	}
+
+	route := c.FullPath()
+	if route == "" {
+		return "(unmatched)" // unknown paths may still be mistyped short codes
+	}
+	if shortCode := c.Param("short_code"); shortCode != "" {
+		route = strings.Replace(route, ":short_code", privacy.ShortCode(services.NormalizeShortCode(shortCode)), 1)
+	}
+	return route
 }
 
 // CORSMiddleware handles Cross-Origin Resource Sharing
@@ -29,7 +63,7 @@ func CORSMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Credentials", "true")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, Idempotency-Key")
 		c.Header("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
 
 		if c.Request.Method == "OPTIONS" {
@@ -53,55 +87,420 @@ func SecurityMiddleware() gin.HandlerFunc {
 	}
 }
 
-// RateLimitMiddleware implements distributed rate limiting using Redis
-func RateLimitMiddleware(cache *repository.RedisCache) gin.HandlerFunc {
-	const (
-		maxRequests = 100
-		timeWindow  = time.Minute
-	)
+// AdminAuthMiddleware requires the configured admin token as a bearer token. Clients that
+// keep sending wrong tokens are locked out by the login throttle.
+func AdminAuthMiddleware(adminToken string, throttle *services.LoginThrottle, logger *logrus.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if adminToken == "" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Admin API is not enabled"})
+			c.Abort()
+			return
+		}
+
+		if retryAfter := loginLockout(c, throttle, services.CredentialAdmin, logger); retryAfter > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed attempts, try again later"})
+			c.Abort()
+			return
+		}
+
+		token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+			loginResult(c, throttle, services.CredentialAdmin, false, logger)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid admin token"})
+			c.Abort()
+			return
+		}
+
+		loginResult(c, throttle, services.CredentialAdmin, true, logger)
+		c.Set(adminContextKey, true)
+		c.Next()
+	}
+}
+
+// QuickShortenAuthMiddleware admits requests bearing one of the quick shorten tokens;
+// the endpoint is hidden when none is configured
+func QuickShortenAuthMiddleware(tokens []string, throttle *services.LoginThrottle, logger *logrus.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if len(tokens) == 0 {
+			c.String(http.StatusNotFound, "Quick shorten is not enabled")
+			c.Abort()
+			return
+		}
+
+		if retryAfter := loginLockout(c, throttle, services.CredentialQuick, logger); retryAfter > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			c.String(http.StatusTooManyRequests, "Too many failed attempts, try again later")
+			c.Abort()
+			return
+		}
+
+		token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		valid := 0
+		for _, candidate := range tokens {
+			valid |= subtle.ConstantTimeCompare([]byte(token), []byte(candidate))
+		}
+		if token == "" || valid != 1 {
+			loginResult(c, throttle, services.CredentialQuick, false, logger)
+			c.String(http.StatusUnauthorized, "Invalid token")
+			c.Abort()
+			return
+		}
+
+		loginResult(c, throttle, services.CredentialQuick, true, logger)
+		c.Next()
+	}
+}
+
+// loginLockout returns how long the client is still locked out of a credential. If Redis
+// fails, attempts are allowed, as with rate limits.
+func loginLockout(c *gin.Context, throttle *services.LoginThrottle, credential string, logger *logrus.Logger) time.Duration {
+	retryAfter, err := throttle.Locked(c.Request.Context(), credential, c.ClientIP())
+	if err != nil {
+		logger.Warnf("Failed to check %s login lockout: %v", credential, err)
+		return 0
+	}
+	return retryAfter
+}
+
+// loginResult records the outcome of an attempt on a credential
+func loginResult(c *gin.Context, throttle *services.LoginThrottle, credential string, succeeded bool, logger *logrus.Logger) {
+	record := throttle.Failed
+	if succeeded {
+		record = throttle.Succeeded
+	}
+	if err := record(c.Request.Context(), credential, c.ClientIP()); err != nil {
+		logger.Warnf("Failed to record %s login attempt: %v", credential, err)
+	}
+}
 
+// maxSignedBodySize bounds the request body buffered to verify a signature or fingerprint
+// an idempotent request
+const maxSignedBodySize = 1 << 20
+
+// SignatureMiddleware verifies HMAC request signatures. Signed requests must carry a valid,
+// unused signature; unsigned ones pass unless the verifier requires signing. The last use
+// of each key is recorded.
+func SignatureMiddleware(verifier *services.RequestVerifier, signingKeys *services.SigningKeyService, logger *logrus.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		clientIP := c.ClientIP()
-		key := fmt.Sprintf("rate_limit:%s", clientIP)
+		if c.Request.Method == http.MethodOptions {
+			c.Next()
+			return
+		}
+		if c.GetHeader(signing.HeaderSignature) == "" && !verifier.Required() {
+			c.Next()
+			return
+		}
 
-		// Get current count from Redis
-		countStr, err := cache.Get(key)
-		var count int
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxSignedBodySize+1))
+		if err != nil || len(body) > maxSignedBodySize {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Request body too large to verify"})
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		keyID := c.GetHeader(signing.HeaderKeyID)
+		if err := verifier.Verify(c.Request.Context(), c.Request.Method, c.Request.URL.RequestURI(), c.Request.Header, body); err != nil {
+			if apperrors.Is(err, apperrors.ErrUnauthorized) {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+				c.Abort()
+				return
+			}
+
+			logger.Errorf("Failed to verify request signature: %v", err)
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Unable to verify request signature"})
+			c.Abort()
+			return
+		}
+
+		signingKeys.RecordUse(keyID, c.ClientIP())
+		c.Set(signingKeyContextKey, keyID)
+		if scopes := verifier.Scopes(keyID); scopes != nil {
+			c.Set(keyScopesContextKey, scopes)
+		}
+		c.Next()
+	}
+}
+
+// signingKeyContextKey is the Gin context key holding the key id of a verified signature
+const signingKeyContextKey = "signing_key_id"
+
+// keyScopesContextKey is the Gin context key holding the scopes of a restricted signing key
+const keyScopesContextKey = "signing_key_scopes"
+
+// RequireScope rejects requests signed with a key whose scopes do not grant scope. It must
+// run after SignatureMiddleware; unsigned requests and keys without scopes pass.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if scopes := requestScopes(c); scopes != nil && !scopes.Allows(scope) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "API key lacks the " + scope + " scope"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireLinkDomain rejects requests signed with a key restricted to custom domains when
+// the link in the short_code parameter is on a domain the key may not create links on,
+// so the key cannot change links it could not have created. It must run after
+// SignatureMiddleware; unsigned requests and keys without domains pass.
+func RequireLinkDomain(domains *services.DomainService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scopes := requestScopes(c)
+		if scopes == nil || len(scopes.Domains) == 0 {
+			c.Next()
+			return
+		}
+
+		linkDomains, err := domains.LinkDomains(c.Request.Context(), c.Param("short_code"))
 		if err != nil {
-			// Key doesn't exist, start with 1
-			count = 1
-			if err := cache.SetWithTTL(key, "1", timeWindow); err != nil {
-				// If Redis fails, allow request but log error
-				c.Next()
+			abortWithError(c, err, "Failed to retrieve URL")
+			return
+		}
+		for _, domain := range linkDomains {
+			if !scopes.AllowsDomain(domain) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "API key may not change links on this domain"})
+				c.Abort()
 				return
 			}
-		} else {
-			// Parse current count
-			fmt.Sscanf(countStr, "%d", &count)
-			count++
-
-			// Check if rate limit exceeded
-			if count > maxRequests {
-				c.JSON(429, gin.H{
-					"error":   "Rate limit exceeded",
-					"message": fmt.Sprintf("Maximum %d requests per minute allowed", maxRequests),
-				})
+		}
+		c.Next()
+	}
+}
+
+// adminContextKey is the Gin context key marking requests that presented the admin token
+const adminContextKey = "admin_token"
+
+// CredentialMiddleware admits only requests with a credential: a verified signature or
+// the admin token as a bearer token. It must run after SignatureMiddleware, and guards
+// routes that change what existing links do or where instance events are sent, which
+// anonymous clients must not reach even when signing is optional.
+func CredentialMiddleware(adminToken string, throttle *services.LoginThrottle, logger *logrus.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString(signingKeyContextKey) != "" {
+			c.Next()
+			return
+		}
+
+		token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if adminToken != "" && token != "" {
+			if retryAfter := loginLockout(c, throttle, services.CredentialAdmin, logger); retryAfter > 0 {
+				c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+				c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed attempts, try again later"})
 				c.Abort()
 				return
 			}
+			if subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) == 1 {
+				loginResult(c, throttle, services.CredentialAdmin, true, logger)
+				c.Set(adminContextKey, true)
+				c.Next()
+				return
+			}
+			loginResult(c, throttle, services.CredentialAdmin, false, logger)
+		}
+
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "A signed request or the admin token is required"})
+		c.Abort()
+	}
+}
+
+// requestScopes returns the restrictions of the key that signed the request, nil when
+// the request is unsigned or the key unrestricted
+func requestScopes(c *gin.Context) *services.KeyScopes {
+	scopes, _ := c.Get(keyScopesContextKey)
+	restricted, _ := scopes.(*services.KeyScopes)
+	return restricted
+}
+
+// RequestActor identifies who made a request for audit records: the signing key of a
+// signed request, the admin for requests with the admin token, otherwise the client IP
+func RequestActor(c *gin.Context) string {
+	if keyID := c.GetString(signingKeyContextKey); keyID != "" {
+		return "key:" + keyID
+	}
+	if c.GetBool(adminContextKey) {
+		return "admin"
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// IdempotencyMiddleware makes a route safe to retry: a request with an Idempotency-Key
+// header runs once per client and key, and retries get the original response with an
+// Idempotent-Replayed header. Server errors and rate limiting are not kept, so they can be
+// retried. It must run after SignatureMiddleware, since keys are scoped per signing key or
+// client IP.
+func IdempotencyMiddleware(idempotency *services.IdempotencyService, logger *logrus.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("Idempotency-Key")
+		if key == "" {
+			c.Next()
+			return
+		}
+		if err := services.ValidateIdempotencyKey(key); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxSignedBodySize+1))
+		if err != nil || len(body) > maxSignedBodySize {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Request body too large"})
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		scope := RequestActor(c)
+		fingerprint := services.RequestFingerprint(c.Request.Method, c.Request.URL.RequestURI(), body)
+		replay, err := idempotency.Begin(c.Request.Context(), scope, key, fingerprint)
+		if err != nil {
+			switch {
+			case apperrors.Is(err, apperrors.ErrConflict):
+				c.JSON(http.StatusConflict, gin.H{"error": "A request with this Idempotency-Key is still in progress"})
+				c.Abort()
+			case apperrors.Is(err, apperrors.ErrMismatch):
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used with a different request"})
+				c.Abort()
+			default:
+				// Like rate limiting, a Redis failure lets the request through
+				logger.Warnf("Failed to check idempotency key: %v", err)
+				c.Next()
+			}
+			return
+		}
+		if replay != nil {
+			for name, values := range replay.Header {
+				c.Writer.Header()[name] = values
+			}
+			c.Header("Idempotent-Replayed", "true")
+			c.Status(replay.Status)
+			c.Writer.Write(replay.Body)
+			c.Abort()
+			return
+		}
+
+		// Headers set before the route runs, such as rate limit counters, describe this
+		// request rather than the response and are not replayed
+		before := c.Writer.Header().Clone()
+		writer := &capturingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+
+		status := writer.Status()
+		if status >= http.StatusInternalServerError || status == http.StatusTooManyRequests {
+			idempotency.Release(c.Request.Context(), scope, key)
+			return
+		}
+		idempotency.Complete(c.Request.Context(), scope, key, fingerprint, &services.IdempotentResponse{
+			Status: status,
+			Header: addedHeaders(before, writer.Header()),
+			Body:   writer.body.Bytes(),
+		})
+	}
+}
+
+// addedHeaders returns the headers of after that are new or changed since before
+func addedHeaders(before, after http.Header) http.Header {
+	added := make(http.Header)
+	for name, values := range after {
+		if !slices.Equal(before[name], values) {
+			added[name] = values
+		}
+	}
+	return added
+}
+
+// capturingWriter keeps a copy of the response body
+type capturingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *capturingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *capturingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
 
-			// Increment counter
-			if err := cache.SetWithTTL(key, fmt.Sprintf("%d", count), timeWindow); err != nil {
-				// If Redis fails, allow request but log error
+// ReadOnlyMiddleware rejects writes while maintenance mode is active.
+// Redirects, stats and the admin API (needed to turn the mode off) keep working.
+func ReadOnlyMiddleware(maintenance *services.MaintenanceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			// Quick shorten creates links over GET for clients that cannot send a body
+			if c.FullPath() != quickShortenPath {
 				c.Next()
 				return
 			}
 		}
 
+		if !maintenance.ReadOnly() || strings.HasPrefix(c.Request.URL.Path, "/api/v1/admin") {
+			c.Next()
+			return
+		}
+
+		c.Header("Retry-After", strconv.Itoa(int(maintenance.RetryAfter().Seconds())))
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":   "Service is in read-only maintenance mode",
+			"message": "Writes are temporarily disabled, please retry later",
+		})
+		c.Abort()
+	}
+}
+
+// RateLimitMiddleware implements distributed rate limiting using Redis. The route's tier
+// selects the limit and bucket; it must run after SignatureMiddleware so signed requests
+// are counted against their verified API key. Limited responses carry the
+// X-RateLimit-Limit, X-RateLimit-Remaining and X-RateLimit-Reset (Unix seconds) headers.
+func RateLimitMiddleware(limits *services.RateLimitService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tier := services.RouteTier(c.FullPath())
+		decision, err := limits.Allow(c.Request.Context(), tier, c.GetString(signingKeyContextKey), c.ClientIP())
+		if err != nil || decision.Limit == 0 {
+			// If Redis fails, allow request
+			c.Next()
+			return
+		}
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(decision.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.FormatInt(decision.Remaining, 10))
+		c.Header("X-RateLimit-Reset", strconv.FormatInt(decision.Reset.Unix(), 10))
+
+		// Check if rate limit exceeded
+		if !decision.Allowed {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(time.Until(decision.Reset).Seconds()))))
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error":   "Rate limit exceeded",
+				"message": fmt.Sprintf("Maximum %d requests per %s allowed", decision.Limit, windowName(limits.Window())),
+			})
+			c.Abort()
+			return
+		}
+
 		c.Next()
 	}
 }
 
+// windowName describes a rate limit window for error messages
+func windowName(window time.Duration) string {
+	switch window {
+	case time.Second:
+		return "second"
+	case time.Minute:
+		return "minute"
+	case time.Hour:
+		return "hour"
+	}
+	return window.String()
+}
+
 // Deprecated: InMemoryRateLimitMiddleware - kept for backward compatibility
 // Use RateLimitMiddleware with Redis instead
 func InMemoryRateLimitMiddleware() gin.HandlerFunc {
@@ -110,3 +509,116 @@ func InMemoryRateLimitMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// SLOMiddleware records the outcome and latency of every request it wraps
+func SLOMiddleware(slo *services.SLOService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		slo.Record(time.Since(start), c.Writer.Status())
+	}
+}
+
+// CanaryMiddleware tags each request as stable or canary. An explicit header wins, then the
+// sticky cohort cookie; otherwise the configured percentage of visitors is sampled into the
+// canary and pinned there with a cookie so they see consistent behaviour. The cohort is
+// carried in the request context, where services check the features under canary.
+func CanaryMiddleware(canary *services.CanaryService, header, cookie string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cohort := ""
+		switch strings.ToLower(c.GetHeader(header)) {
+		case "1", "true", services.CohortCanary:
+			cohort = services.CohortCanary
+		case "0", "false", services.CohortStable:
+			cohort = services.CohortStable
+		}
+
+		if cohort == "" {
+			if value, err := c.Cookie(cookie); err == nil && (value == services.CohortCanary || value == services.CohortStable) {
+				cohort = value
+			}
+		}
+
+		if cohort == "" {
+			cohort = services.CohortStable
+			if canary.Enabled() {
+				cohort = canary.Assign()
+				c.SetCookie(cookie, cohort, int((24 * time.Hour).Seconds()), "/", "", false, true)
+			}
+		}
+
+		c.Request = c.Request.WithContext(services.WithCohort(c.Request.Context(), cohort))
+		c.Header("X-Cohort", cohort)
+
+		start := time.Now()
+		c.Next()
+		canary.Record(cohort, time.Since(start), c.Writer.Status())
+	}
+}
+
+// visitorContextKey is the Gin context key holding the attribution visitor id
+const visitorContextKey = "visitor_id"
+
+// AttributionMiddleware identifies returning visitors with a first-party cookie so clicks on
+// several short links can be attributed to one journey. It never reads or sets the cookie
+// when the browser sends a Global Privacy Control or Do Not Track signal, and when a consent
+// cookie is configured it only acts once that cookie grants consent.
+func AttributionMiddleware(enabled bool, cookie string, ttl time.Duration, consentCookie string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !enabled || !attributionAllowed(c, consentCookie) {
+			c.Next()
+			return
+		}
+
+		visitorID, err := c.Cookie(cookie)
+		if err != nil || !validVisitorID(visitorID) {
+			visitorID = services.NewClickID() // same random format as click ids
+		}
+
+		// Refresh the cookie on every visit so active journeys do not expire
+		c.SetSameSite(http.SameSiteLaxMode)
+		c.SetCookie(cookie, visitorID, int(ttl.Seconds()), "/", "", c.Request.TLS != nil, true)
+		c.Set(visitorContextKey, visitorID)
+
+		c.Next()
+	}
+}
+
+// VisitorID returns the attribution visitor id of the request, empty when attribution did not apply
+func VisitorID(c *gin.Context) string {
+	return c.GetString(visitorContextKey)
+}
+
+// attributionAllowed honours browser privacy signals and the configured consent cookie
+func attributionAllowed(c *gin.Context, consentCookie string) bool {
+	if c.GetHeader("Sec-GPC") == "1" || c.GetHeader("DNT") == "1" {
+		return false
+	}
+	if consentCookie == "" {
+		return true
+	}
+
+	value, err := c.Cookie(consentCookie)
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(value) {
+	case "1", "true", "yes", "granted":
+		return true
+	default:
+		return false
+	}
+}
+
+// validVisitorID accepts only ids this service could have issued
+func validVisitorID(id string) bool {
+	if len(id) != 32 {
+		return false
+	}
+	for _, r := range id {
+		if (r < '0' || r > '9') && (r < 'a' || r > 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alexnthnz/url-shortener/internal/models"
+	"github.com/alexnthnz/url-shortener/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type ReportHandler struct {
+	reportService *services.ReportService
+	logger        *logrus.Logger
+}
+
+func NewReportHandler(reportService *services.ReportService, logger *logrus.Logger) *ReportHandler {
+	return &ReportHandler{
+		reportService: reportService,
+		logger:        logger,
+	}
+}
+
+// GenerateReport handles POST /api/v1/admin/reports, reporting on a campaign now
+func (h *ReportHandler) GenerateReport(c *gin.Context) {
+	var req models.ReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	// Without a start, report on the last complete period
+	var report *models.Report
+	var err error
+	if req.Start != nil {
+		report, err = h.reportService.Generate(c.Request.Context(), req.Campaign, req.Period, *req.Start)
+	} else {
+		report, err = h.reportService.GenerateLast(c.Request.Context(), req.Campaign, req.Period, time.Now())
+	}
+	if err != nil {
+		abortWithError(c, err, "Failed to generate report")
+		return
+	}
+
+	c.JSON(http.StatusCreated, report)
+}
+
+// ListReports handles GET /api/v1/admin/reports, optionally of one ?campaign=
+func (h *ReportHandler) ListReports(c *gin.Context) {
+	limit, ok := linkListLimit(c)
+	if !ok {
+		return
+	}
+
+	reports, err := h.reportService.List(c.Query("campaign"), limit)
+	if err != nil {
+		h.logger.Errorf("Failed to list reports: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list reports"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"reports": reports})
+}
+
+// DownloadReport handles GET /api/v1/admin/reports/:id, returning the rendered HTML
+func (h *ReportHandler) DownloadReport(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid report ID"})
+		return
+	}
+
+	report, err := h.reportService.Get(id)
+	if err != nil {
+		abortWithError(c, err, "Failed to get report")
+		return
+	}
+
+	filename := fmt.Sprintf("report-%d-%s-%s.html", report.ID, report.Period, report.PeriodStart.Format("2006-01-02"))
+	if c.Query("download") != "" {
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(report.HTML))
+}
@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	apperrors "github.com/alexnthnz/url-shortener/internal/errors"
+	"github.com/alexnthnz/url-shortener/internal/models"
+	"github.com/alexnthnz/url-shortener/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// sharedURLPattern finds the link in shared text; Android apps often share "title https://..."
+// as text rather than in the url field
+var sharedURLPattern = regexp.MustCompile(`https?://\S+`)
+
+// shareIconSizes are the icon sizes the manifest offers, which launchers need to install the app
+var shareIconSizes = []int{192, 512}
+
+// ShareHandler serves the pages that shorten a link from a browser or from the share sheet
+// of a phone: the Web Share Target manifest, the share form and a bookmarklet
+type ShareHandler struct {
+	urlService *services.URLService
+	domains    *services.DomainService
+	baseURL    string
+	logger     *logrus.Logger
+
+	iconsMu sync.Mutex
+	icons   map[int][]byte
+}
+
+func NewShareHandler(urlService *services.URLService, domains *services.DomainService, baseURL string, logger *logrus.Logger) *ShareHandler {
+	return &ShareHandler{
+		urlService: urlService,
+		domains:    domains,
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		logger:     logger,
+		icons:      make(map[int][]byte),
+	}
+}
+
+// Manifest handles GET /manifest.webmanifest. Installed as an app, the shortener appears in
+// the share sheet and receives shared links as a form post to /share.
+func (h *ShareHandler) Manifest(c *gin.Context) {
+	icons := make([]gin.H, len(shareIconSizes))
+	for i, size := range shareIconSizes {
+		icons[i] = gin.H{
+			"src":   "/share-icon.png?size=" + strconv.Itoa(size),
+			"sizes": fmt.Sprintf("%dx%d", size, size),
+			"type":  "image/png",
+		}
+	}
+
+	c.Header("Cache-Control", "public, max-age=86400")
+	c.Header("Content-Type", "application/manifest+json")
+	c.JSON(http.StatusOK, gin.H{
+		"name":             "URL Shortener",
+		"short_name":       "Shorten",
+		"start_url":        "/share",
+		"scope":            "/share",
+		"display":          "standalone",
+		"background_color": "#ffffff",
+		"theme_color":      "#0f766e",
+		"icons":            icons,
+		"share_target": gin.H{
+			"action":  "/share",
+			"method":  "POST",
+			"enctype": "application/x-www-form-urlencoded",
+			"params":  gin.H{"title": "title", "text": "text", "url": "url"},
+		},
+	})
+}
+
+// ShareForm handles GET /share, a form prefilled from ?url= or ?text= that bookmarklets
+// open. Nothing is created until the form is submitted.
+func (h *ShareHandler) ShareForm(c *gin.Context) {
+	h.page(c, http.StatusOK, "Shorten a link", h.form(sharedURL(c.Query("url"), c.Query("text")), ""))
+}
+
+// Share handles POST /share from the form or the share sheet, showing the short URL with a
+// copy button
+func (h *ShareHandler) Share(c *gin.Context) {
+	link := sharedURL(c.PostForm("url"), c.PostForm("text"))
+	if link == "" {
+		h.page(c, http.StatusBadRequest, "Shorten a link", h.form("", "The shared content has no link to shorten."))
+		return
+	}
+
+	urlRecord, err := h.urlService.ShortenURL(c.Request.Context(), &models.ShortenRequest{URL: link})
+	if err != nil {
+		h.logger.Errorf("Failed to shorten shared URL: %v", err)
+		message, status := "The link could not be shortened, please try again.", http.StatusInternalServerError
+		if apperrors.Is(err, apperrors.ErrInvalidURL) || apperrors.Is(err, apperrors.ErrRejected) {
+			message, status = err.Error(), http.StatusBadRequest
+		}
+		h.page(c, status, "Shorten a link", h.form(link, message))
+		return
+	}
+
+	shortURL := html.EscapeString(h.domains.ShortURL("", urlRecord.ShortCode))
+	body := fmt.Sprintf(`<h1>Short link</h1>`+
+		`<input id="short" value="%s" readonly onfocus="this.select()">`+
+		`<button id="copy" type="button">Copy</button>`+
+		`<p class="original">%s</p><p><a href="/share">Shorten another</a></p>`+
+		`<script>document.getElementById("copy").onclick=function(){`+
+		`var input=document.getElementById("short"),button=this;`+
+		`function done(){button.textContent="Copied"}`+
+		`if(navigator.clipboard){navigator.clipboard.writeText(input.value).then(done)}`+
+		`else{input.select();document.execCommand("copy");done()}}</script>`,
+		shortURL, html.EscapeString(urlRecord.OriginalURL))
+
+	status := http.StatusCreated
+	if urlRecord.Existing {
+		status = http.StatusOK
+	}
+	h.page(c, status, "Short link", body)
+}
+
+// Bookmarklet handles GET /bookmarklet, offering a bookmark that opens the share form for
+// the page being viewed
+func (h *ShareHandler) Bookmarklet(c *gin.Context) {
+	script := fmt.Sprintf(`javascript:(function(){window.open(%s+encodeURIComponent(location.href),"_blank")})()`,
+		strconv.Quote(h.baseURL+"/share?url="))
+	body := fmt.Sprintf(`<h1>Bookmarklet</h1>`+
+		`<p>Drag this button to your bookmarks bar, then click it on any page to shorten that page.</p>`+
+		`<p><a class="button" href="%s">Shorten</a></p>`+
+		`<p>On a phone, <a href="/share">open the share page</a> and add it to your home screen: `+
+		`the shortener then appears when sharing a link from any app.</p>`,
+		html.EscapeString(script))
+	h.page(c, http.StatusOK, "Bookmarklet", body)
+}
+
+// Icon handles GET /share-icon.png?size=, the app icon of the manifest
+func (h *ShareHandler) Icon(c *gin.Context) {
+	size, _ := strconv.Atoi(c.DefaultQuery("size", "512"))
+	if size != shareIconSizes[0] && size != shareIconSizes[1] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "size must be 192 or 512"})
+		return
+	}
+
+	h.iconsMu.Lock()
+	icon, ok := h.icons[size]
+	if !ok {
+		icon = shareIcon(size)
+		h.icons[size] = icon
+	}
+	h.iconsMu.Unlock()
+
+	c.Header("Cache-Control", "public, max-age=86400")
+	c.Data(http.StatusOK, "image/png", icon)
+}
+
+func (h *ShareHandler) form(link, message string) string {
+	notice := ""
+	if message != "" {
+		notice = `<p class="error">` + html.EscapeString(message) + `</p>`
+	}
+	return fmt.Sprintf(`<h1>Shorten a link</h1>%s`+
+		`<form method="post" action="/share"><input name="url" type="url" value="%s" placeholder="https://" required autofocus>`+
+		`<button type="submit">Shorten</button></form>`,
+		notice, html.EscapeString(link))
+}
+
+func (h *ShareHandler) page(c *gin.Context, status int, title, body string) {
+	page := fmt.Sprintf(`<!DOCTYPE html><html><head><meta charset="utf-8">`+
+		`<meta name="viewport" content="width=device-width, initial-scale=1"><meta name="robots" content="noindex">`+
+		`<link rel="manifest" href="/manifest.webmanifest"><title>%s</title><style>`+
+		`body{font-family:sans-serif;max-width:480px;margin:2em auto;padding:0 1em;text-align:center}`+
+		`input{width:100%%;box-sizing:border-box;padding:.8em;font-size:1em;margin:.5em 0}`+
+		`button,.button{display:inline-block;padding:.8em 1.6em;font-size:1em;border:0;border-radius:8px;`+
+		`background:#0f766e;color:#fff;text-decoration:none}`+
+		`.original{color:#666;word-break:break-all}.error{color:#b91c1c}`+
+		`</style></head><body>%s</body></html>`,
+		html.EscapeString(title), body)
+
+	c.Header("Cache-Control", "no-store")
+	c.Data(status, "text/html; charset=utf-8", []byte(page))
+}
+
+// sharedURL picks the link to shorten from the url field, else from the shared text
+func sharedURL(link, text string) string {
+	if link = strings.TrimSpace(link); link != "" {
+		return link
+	}
+	return sharedURLPattern.FindString(text)
+}
+
+// shareIcon draws the app icon: two white chain links on a teal square
+func shareIcon(size int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	background := color.RGBA{0x0f, 0x76, 0x6e, 0xff}
+	s := float64(size)
+	radius, thickness := 0.17*s, 0.06*s
+
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			img.Set(x, y, background)
+			// Two rings on the diagonal, overlapping like chain links
+			px, py := float64(x)+0.5, float64(y)+0.5
+			for _, center := range []float64{0.4 * s, 0.6 * s} {
+				distance := math.Hypot(px-center, py-(s-center))
+				if math.Abs(distance-radius) <= thickness/2 {
+					img.Set(x, y, color.White)
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+	return buf.Bytes()
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/alexnthnz/url-shortener/internal/models"
+	"github.com/alexnthnz/url-shortener/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type TakedownHandler struct {
+	takedownService *services.TakedownService
+	logger          *logrus.Logger
+}
+
+func NewTakedownHandler(takedownService *services.TakedownService, logger *logrus.Logger) *TakedownHandler {
+	return &TakedownHandler{
+		takedownService: takedownService,
+		logger:          logger,
+	}
+}
+
+// SubmitTakedown handles POST /api/v1/takedowns, open to anyone reporting a link
+func (h *TakedownHandler) SubmitTakedown(c *gin.Context) {
+	var req models.TakedownSubmission
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	takedown, err := h.takedownService.Submit(c.Request.Context(), &req)
+	if err != nil {
+		abortWithError(c, err, "Failed to submit takedown request")
+		return
+	}
+
+	// Reporters only get a reference; the review itself is visible to admins
+	c.JSON(http.StatusAccepted, gin.H{
+		"id":         takedown.ID,
+		"short_code": takedown.ShortCode,
+		"status":     takedown.Status,
+		"created_at": takedown.CreatedAt,
+	})
+}
+
+// ListTakedowns handles GET /api/v1/admin/takedowns?status=
+func (h *TakedownHandler) ListTakedowns(c *gin.Context) {
+	takedowns, err := h.takedownService.List(c.Query("status"))
+	if err != nil {
+		abortWithError(c, err, "Failed to list takedown requests")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"takedowns": takedowns})
+}
+
+// GetTakedown handles GET /api/v1/admin/takedowns/:id
+func (h *TakedownHandler) GetTakedown(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid takedown ID"})
+		return
+	}
+
+	takedown, err := h.takedownService.Get(id)
+	if err != nil {
+		abortWithError(c, err, "Failed to get takedown request")
+		return
+	}
+
+	c.JSON(http.StatusOK, takedown)
+}
+
+// ResolveTakedown handles POST /api/v1/admin/takedowns/:id/resolve
+func (h *TakedownHandler) ResolveTakedown(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid takedown ID"})
+		return
+	}
+
+	var req models.TakedownResolution
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	takedown, err := h.takedownService.Resolve(c.Request.Context(), id, req.Status, req.Note, RequestActor(c))
+	if err != nil {
+		abortWithError(c, err, "Failed to resolve takedown request")
+		return
+	}
+
+	c.JSON(http.StatusOK, takedown)
+}
@@ -1,31 +1,63 @@
 package handlers
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"math"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/alexnthnz/url-shortener/internal/buildinfo"
+	apperrors "github.com/alexnthnz/url-shortener/internal/errors"
 	"github.com/alexnthnz/url-shortener/internal/models"
 	"github.com/alexnthnz/url-shortener/internal/services"
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 )
 
+const maxDomainStatsLimit = 100
+
+// quickShortenPath is the route of QuickShorten, the one GET that creates a link
+const quickShortenPath = "/api/v1/quick"
+
 type URLHandler struct {
 	urlService       *services.URLService
 	analyticsService *services.AnalyticsService
+	widgetService    *services.WidgetService
+	sloService       *services.SLOService
+	canaryService    *services.CanaryService
+	compliance       *services.ComplianceService
+	aliasClaims      *services.AliasClaimService
+	domains          *services.DomainService
+	redirectAudit    *services.RedirectAuditService
+	trending         *services.TrendingService
 	logger           *logrus.Logger
 }
 
-func NewURLHandler(urlService *services.URLService, analyticsService *services.AnalyticsService, logger *logrus.Logger) *URLHandler {
+func NewURLHandler(urlService *services.URLService, analyticsService *services.AnalyticsService, widgetService *services.WidgetService, sloService *services.SLOService, canaryService *services.CanaryService, compliance *services.ComplianceService, aliasClaims *services.AliasClaimService, domains *services.DomainService, redirectAudit *services.RedirectAuditService, trending *services.TrendingService, logger *logrus.Logger) *URLHandler {
 	return &URLHandler{
 		urlService:       urlService,
 		analyticsService: analyticsService,
+		widgetService:    widgetService,
+		sloService:       sloService,
+		canaryService:    canaryService,
+		compliance:       compliance,
+		aliasClaims:      aliasClaims,
+		domains:          domains,
+		redirectAudit:    redirectAudit,
+		trending:         trending,
 		logger:           logger,
 	}
 }
 
-// ShortenURL handles POST /api/v1/shorten
+// ShortenURL handles POST /api/v1/shorten. With ?dry_run=true the request is checked
+// and the link that would be created is returned with 200, without creating it.
 func (h *URLHandler) ShortenURL(c *gin.Context) {
 	var req models.ShortenRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -33,52 +65,299 @@ func (h *URLHandler) ShortenURL(c *gin.Context) {
 		return
 	}
 
-	// Create short URL
-	urlRecord, err := h.urlService.ShortenURL(req.URL, req.CustomAlias)
-	if err != nil {
-		h.logger.Errorf("Failed to shorten URL: %v", err)
+	dryRun, _ := strconv.ParseBool(c.Query("dry_run"))
+
+	if scopes := requestScopes(c); scopes != nil && !scopes.AllowsDomain(req.Domain) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key may not create links on this domain"})
+		return
+	}
 
-		// Handle specific error cases
-		if strings.Contains(err.Error(), "invalid URL") ||
-			strings.Contains(err.Error(), "invalid custom alias") ||
-			strings.Contains(err.Error(), "already exists") {
+	// Custom aliases are rationed so brandable names cannot be registered in bulk
+	actor := RequestActor(c)
+	if req.CustomAlias != "" && !dryRun && !h.allowAliasClaim(c, actor, req.CustomAlias) {
+		return
+	}
+
+	// The SMS profile caps the whole short URL, so the room for the code depends on the domain
+	if req.Profile == services.ProfileSMS {
+		budget, err := h.domains.SMSCodeBudget(req.Domain)
+		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		req.MaxCodeLength = budget
+	}
+
+	// Create short URL; links on a custom domain also get a code there
+	var urlRecord *models.URL
+	var code string
+	var err error
+	switch {
+	case req.Domain != "" && dryRun:
+		urlRecord, code, err = h.domains.PreviewShorten(c.Request.Context(), &req, actor)
+	case req.Domain != "":
+		urlRecord, code, err = h.domains.ShortenURL(c.Request.Context(), &req, actor)
+	case dryRun:
+		urlRecord, err = h.urlService.PreviewShorten(c.Request.Context(), &req)
+	default:
+		urlRecord, err = h.urlService.ShortenURL(c.Request.Context(), &req)
+	}
+	if err != nil {
+		abortWithError(c, err, "Failed to create short URL")
+		return
+	}
 
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create short URL"})
+	if code == "" && req.Domain == "" {
+		code = urlRecord.ShortCode
+	}
+
+	if dryRun {
+		preview := models.ShortenPreview{
+			DryRun:          true,
+			ShortCode:       urlRecord.ShortCode,
+			OriginalURL:     urlRecord.OriginalURL,
+			CustomAlias:     urlRecord.CustomAlias,
+			CodeStyle:       req.CodeStyle,
+			PathPassthrough: urlRecord.PathPassthrough,
+			MaxClicks:       urlRecord.MaxClicks,
+			Ephemeral:       urlRecord.Ephemeral,
+			Domain:          req.Domain,
+			UTM:             urlRecord.UTM,
+			ActivateAt:      urlRecord.ActivateAt,
+			Existing:        urlRecord.Existing,
+		}
+		if code != "" {
+			preview.ShortURL = h.domains.ShortURL(req.Domain, code)
+		}
+		c.JSON(http.StatusOK, preview)
 		return
 	}
 
-	// Build response
-	baseURL := c.GetHeader("X-Base-URL")
-	if baseURL == "" {
-		baseURL = "http://localhost:8080" // Fallback
+	if req.CustomAlias != "" {
+		h.aliasClaims.Claimed(actor, code, urlRecord.ShortCode)
 	}
-	baseURL = strings.TrimSuffix(baseURL, "/")
 
+	// The short code is the link's canonical code, which the rest of the API takes
 	response := models.ShortenResponse{
 		ShortCode:   urlRecord.ShortCode,
-		ShortURL:    baseURL + "/" + urlRecord.ShortCode,
+		ShortURL:    h.domains.ShortURL(req.Domain, code),
+		Domain:      req.Domain,
+		NumericCode: urlRecord.NumericCode,
 		OriginalURL: urlRecord.OriginalURL,
+		MaxClicks:   urlRecord.MaxClicks,
+		UTM:         urlRecord.UTM,
+		Ephemeral:   urlRecord.Ephemeral,
+		ActivateAt:  urlRecord.ActivateAt,
+		Existing:    urlRecord.Existing,
+	}
+	if urlRecord.Ephemeral {
+		// Ephemeral links have no statistics for a widget or QR scan counts to show
+		response.ExpiresAt = urlRecord.ExpiresAt
+	} else {
+		response.QRURL = services.QRScanURL(response.ShortURL)
+		response.NumericURL = h.domains.NumericURL(urlRecord.NumericCode)
+		response.WidgetToken = h.widgetService.Token(urlRecord.ShortCode)
 	}
 
+	// Deduplicated requests create nothing
+	if urlRecord.Existing {
+		c.JSON(http.StatusOK, response)
+		return
+	}
 	c.JSON(http.StatusCreated, response)
 }
 
-// RedirectURL handles GET /:short_code
+// QuickShorten handles GET /api/v1/quick?url=...&alias=..., shortening a URL for launchers
+// such as Raycast or Alfred and for bookmarklets. The response is the short URL alone as
+// plain text, and errors are plain text too.
+func (h *URLHandler) QuickShorten(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+
+	req := models.ShortenRequest{URL: c.Query("url"), CustomAlias: c.Query("alias")}
+	if req.URL == "" {
+		c.String(http.StatusBadRequest, "Missing url parameter")
+		return
+	}
+
+	actor := RequestActor(c)
+	if req.CustomAlias != "" {
+		if decision := h.aliasClaims.Allow(c.Request.Context(), actor, req.CustomAlias); !decision.Allowed {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(decision.RetryAfter.Seconds()))))
+			c.String(http.StatusTooManyRequests, "Alias claim limit exceeded")
+			return
+		}
+	}
+
+	urlRecord, err := h.urlService.ShortenURL(c.Request.Context(), &req)
+	if err != nil {
+		h.logger.Errorf("Failed to quick shorten URL: %v", err)
+		if apperrors.Is(err, apperrors.ErrInvalid) {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		c.String(http.StatusInternalServerError, "Failed to create short URL")
+		return
+	}
+	if req.CustomAlias != "" {
+		h.aliasClaims.Claimed(actor, urlRecord.ShortCode, urlRecord.ShortCode)
+	}
+
+	status := http.StatusCreated
+	if urlRecord.Existing {
+		status = http.StatusOK
+	}
+	c.String(status, h.domains.ShortURL("", urlRecord.ShortCode))
+}
+
+// ResolveURL handles GET /internal/v1/resolve/:short_code, returning the stored destination
+// to services inside the mesh without redirecting or recording a click
+func (h *URLHandler) ResolveURL(c *gin.Context) {
+	shortCode := services.NormalizeShortCode(c.Param("short_code"))
+
+	originalURL, canonicalCode, err := h.urlService.GetOriginalURL(c.Request.Context(), shortCode)
+	if err != nil {
+		if apperrors.Is(err, apperrors.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Short URL not found"})
+			return
+		}
+		if apperrors.Is(err, apperrors.ErrDisabled) {
+			c.JSON(http.StatusUnavailableForLegalReasons, gin.H{"error": "Short URL is disabled"})
+			return
+		}
+		var inactive *services.LinkInactiveError
+		if errors.As(err, &inactive) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Short URL is not active yet", "activate_at": inactive.ActivateAt})
+			return
+		}
+
+		h.logger.Errorf("Failed to resolve URL: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve URL"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"short_code":   shortCode,
+		"canonical":    canonicalCode,
+		"original_url": originalURL,
+	})
+}
+
+// RedirectURL handles GET /:short_code; GET /:short_code+ previews the link instead
 func (h *URLHandler) RedirectURL(c *gin.Context) {
-	shortCode := c.Param("short_code")
+	shortCode := services.NormalizeShortCode(c.Param("short_code"))
 	if shortCode == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Short code is required"})
 		return
 	}
+	preview, isPreview := strings.CutSuffix(shortCode, "+")
+	isPreview = isPreview && preview != "" && c.Param("path") == ""
+
+	// Custom domains only serve their own codes, which point to canonical links
+	if domain := h.domains.Lookup(c.Request.Host); domain != nil {
+		code := shortCode
+		if isPreview {
+			code = preview
+		}
+		canonical, err := h.domains.Resolve(c.Request.Context(), domain, code)
+		if err != nil {
+			abortWithError(c, err, "Failed to retrieve URL")
+			return
+		}
+		shortCode, preview = canonical, canonical
+	}
+
+	if isPreview {
+		h.previewURL(c, preview)
+		return
+	}
+	if services.IsEphemeralCode(shortCode) {
+		h.redirectEphemeral(c, shortCode)
+		return
+	}
+	h.redirect(c, shortCode)
+}
+
+// RedirectNumeric handles GET /n/:digits, following a link by its numeric code
+func (h *URLHandler) RedirectNumeric(c *gin.Context) {
+	digits := c.Param("digits")
+	if !services.IsNumericCode(digits) {
+		// Anything else under /n/ is the extra path of a link with the short code "n"
+		c.Params = gin.Params{{Key: "short_code", Value: "n"}, {Key: "path", Value: "/" + digits}}
+		h.RedirectURL(c)
+		return
+	}
+	// Numeric codes belong to the default domain; custom domains only serve their own codes
+	if h.domains.Lookup(c.Request.Host) != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Short URL not found"})
+		return
+	}
 
-	// Get original URL
-	originalURL, err := h.urlService.GetOriginalURL(shortCode)
+	shortCode, err := h.urlService.ResolveNumericCode(c.Request.Context(), digits)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Short URL not found"})
+		abortWithError(c, err, "Failed to retrieve URL")
+		return
+	}
+	h.redirect(c, shortCode)
+}
+
+// redirect follows a stored link, recording the click
+func (h *URLHandler) redirect(c *gin.Context, shortCode string) {
+	// QR code URLs mark the click as a scan; the marker is not forwarded
+	rawQuery, viaQR := services.StripQRScan(c.Request.URL.RawQuery)
+
+	// Click metadata feeds both redirect rules and templated destinations
+	clickID := services.NewClickID()
+	click := services.ClickContext{
+		ClickID:        clickID,
+		Country:        h.getCountry(c),
+		UTMSource:      c.Query("utm_source"),
+		UTMMedium:      c.Query("utm_medium"),
+		UTMCampaign:    c.Query("utm_campaign"),
+		AcceptLanguage: c.GetHeader("Accept-Language"),
+		UserAgent:      c.GetHeader("User-Agent"),
+	}
+	visitor := services.NewVisitor(click, abTestKey(c, c.ClientIP()), time.Now())
+
+	// A sample of redirects is recorded in full, whatever the response
+	var trace *services.RedirectTrace
+	var audit *models.RedirectAudit
+	if h.redirectAudit.Sample() {
+		trace = services.NewRedirectTrace()
+		audit = &models.RedirectAudit{
+			ClickID:   clickID,
+			At:        visitor.Time.UTC(),
+			ShortCode: shortCode,
+			Country:   visitor.Country,
+			Language:  visitor.Language,
+			Device:    visitor.Device,
+		}
+		defer func() {
+			trace.Finish(audit)
+			audit.Status = c.Writer.Status()
+			audit.Destination = c.Writer.Header().Get("Location")
+			h.redirectAudit.Record(c.Request.Context(), audit)
+		}()
+	}
+
+	// Redirect rules pick the destination; links with path passthrough forward the rest of
+	// the path and the query string to it
+	redirect, err := h.urlService.ResolveRedirect(c.Request.Context(), shortCode, c.Param("path"), rawQuery, visitor, trace)
+	if err != nil {
+		if apperrors.Is(err, apperrors.ErrNotFound) {
+			h.notFound(c, shortCode)
+			return
+		}
+		if apperrors.Is(err, apperrors.ErrInvalid) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if apperrors.Is(err, apperrors.ErrDisabled) {
+			c.JSON(http.StatusUnavailableForLegalReasons, gin.H{"error": "This link has been disabled following a complaint"})
+			return
+		}
+		var inactive *services.LinkInactiveError
+		if errors.As(err, &inactive) {
+			h.notActive(c, inactive.ActivateAt)
 			return
 		}
 
@@ -86,33 +365,378 @@ func (h *URLHandler) RedirectURL(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve URL"})
 		return
 	}
+	originalURL, canonicalCode := redirect.Destination, redirect.CanonicalCode
+	if audit != nil {
+		audit.CanonicalCode, audit.Bucket = canonicalCode, &redirect.Bucket
+		if redirect.Rule >= 0 {
+			audit.Rule = &redirect.Rule
+		}
+	}
 
-	// Record analytics asynchronously (non-blocking)
-	ipAddress := h.getClientIP(c)
-	userAgent := c.GetHeader("User-Agent")
-	h.analyticsService.RecordClickAsync(shortCode, ipAddress, userAgent)
+	// Destinations refused by a domain policy added after the link was created stay dark;
+	// landing pages check each of their buttons instead
+	if redirect.Page == nil {
+		err = h.urlService.CheckDestination(originalURL)
+	}
+	trace.Stage("destination_check")
+	if err != nil {
+		h.logger.Warnf("Refused redirect of %s: %v", canonicalCode, err)
+		c.JSON(http.StatusForbidden, gin.H{"error": "This link's destination is not permitted"})
+		return
+	}
+
+	// Links with a click cap stop redirecting once it is used up
+	_, err = h.urlService.ConsumeClick(c.Request.Context(), canonicalCode)
+	trace.Stage("click_limit")
+	if err != nil {
+		abortWithError(c, err, "Failed to retrieve URL")
+		return
+	}
+
+	// Substitute click metadata into templated destinations
+	click.ShortCode = canonicalCode
+	originalURL = services.ExpandDestination(originalURL, click)
+	trace.Stage("template")
+
+	// Record analytics asynchronously (non-blocking); a landing page view counts as a click
+	h.analyticsService.RecordClickAsync(services.AnalyticsEvent{
+		ClickID:   clickID,
+		VisitorID: VisitorID(c),
+		ShortCode: canonicalCode,
+		IPAddress: c.ClientIP(),
+		UserAgent: c.GetHeader("User-Agent"),
+		Referrer:  c.GetHeader("Referer"),
+		ViaQR:     viaQR,
+		Rule:      redirectRule(redirect),
+	})
+
+	if redirect.Page != nil {
+		trace.Stage("recording")
+		// Served uncached, so page edits and click caps take effect at once
+		c.Header("Cache-Control", "no-store")
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(h.urlService.RenderLandingPage(redirect.Page)))
+		return
+	}
+
+	// Redirects to sensitive domains are also kept in the compliance log
+	h.compliance.RecordRedirect(canonicalCode, clickID, originalURL, click.Country)
+	trace.Stage("recording")
+
+	// Redirect to original URL immediately. A cached redirect would skip the server from
+	// then on: destination edits, takedowns, rules, per-click templates and click caps
+	// would stop applying and the clicks would go uncounted, so none is cached.
+	c.Header("Cache-Control", "no-store")
+	c.Redirect(http.StatusFound, originalURL)
+}
+
+// abTestKey is the key that keeps a visitor in their A/B test variant: the attribution
+// visitor id, else a hash of the IP address. The hash is only used to pick the variant and
+// never stored.
+func abTestKey(c *gin.Context, clientIP string) string {
+	if visitorID := VisitorID(c); visitorID != "" {
+		return visitorID
+	}
+	if clientIP == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte("ab:" + clientIP))
+	return "ip:" + hex.EncodeToString(sum[:8])
+}
+
+// redirectRule is the rule recorded with a click, nil for the link's own destination
+func redirectRule(redirect *services.Redirect) *int {
+	if redirect.Rule < 0 {
+		return nil
+	}
+	rule := redirect.Rule
+	return &rule
+}
+
+// redirectEphemeral follows an ephemeral link. Its clicks are counted against its cap but
+// never recorded, keeping the link entirely out of the database.
+func (h *URLHandler) redirectEphemeral(c *gin.Context, shortCode string) {
+	if c.Param("path") != "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Short URL not found"})
+		return
+	}
+
+	originalURL, _, err := h.urlService.GetEphemeralURL(c.Request.Context(), shortCode)
+	if err != nil {
+		abortWithError(c, err, "Failed to retrieve URL")
+		return
+	}
+
+	if err := h.urlService.CheckDestination(originalURL); err != nil {
+		h.logger.Warnf("Refused redirect of ephemeral link: %v", err)
+		c.JSON(http.StatusForbidden, gin.H{"error": "This link's destination is not permitted"})
+		return
+	}
+
+	originalURL = services.ExpandDestination(originalURL, services.ClickContext{
+		ClickID:        services.NewClickID(),
+		ShortCode:      shortCode,
+		Country:        h.getCountry(c),
+		UTMSource:      c.Query("utm_source"),
+		UTMMedium:      c.Query("utm_medium"),
+		UTMCampaign:    c.Query("utm_campaign"),
+		AcceptLanguage: c.GetHeader("Accept-Language"),
+		UserAgent:      c.GetHeader("User-Agent"),
+	})
+
+	// The link expires, so browsers must not remember the redirect
+	c.Header("Cache-Control", "no-store")
+	c.Redirect(http.StatusFound, originalURL)
+}
+
+// SimulateRedirect handles POST /api/v1/urls/:short_code/simulate, reporting what following
+// a link would do for a synthetic visitor. Nothing is recorded and no click is consumed.
+func (h *URLHandler) SimulateRedirect(c *gin.Context) {
+	shortCode := services.NormalizeShortCode(c.Param("short_code"))
+	if shortCode == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Short code is required"})
+		return
+	}
+
+	var req models.SimulationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+	query, err := url.ParseQuery(strings.TrimPrefix(req.Query, "?"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query string"})
+		return
+	}
+	rawQuery, _ := services.StripQRScan(strings.TrimPrefix(req.Query, "?"))
+
+	info, err := h.urlService.GetURLInfo(c.Request.Context(), shortCode)
+	if err != nil {
+		abortWithError(c, err, "Failed to retrieve URL")
+		return
+	}
+	canonicalCode := shortCode
+	if info.CanonicalCode != "" {
+		canonicalCode = info.CanonicalCode
+	}
+
+	client := services.ParseUserAgent(req.UserAgent)
+	if req.Device != "" {
+		client.DeviceType = req.Device
+	}
+	click := services.ClickContext{
+		ClickID:        services.NewClickID(),
+		ShortCode:      canonicalCode,
+		Country:        strings.ToUpper(strings.TrimSpace(req.Country)),
+		UTMSource:      query.Get("utm_source"),
+		UTMMedium:      query.Get("utm_medium"),
+		UTMCampaign:    query.Get("utm_campaign"),
+		AcceptLanguage: req.AcceptLanguage,
+		UserAgent:      req.UserAgent,
+		Device:         req.Device,
+	}
+	bot := services.IsBot(req.UserAgent)
+	simulation := &models.RedirectSimulation{
+		ShortCode:     shortCode,
+		CanonicalCode: info.CanonicalCode,
+		Visitor: models.SimulatedVisitor{
+			Country:    click.Country,
+			Language:   services.PreferredLanguage(req.AcceptLanguage),
+			DeviceType: client.DeviceType,
+			Browser:    client.Browser,
+			OS:         client.OS,
+			Bot:        bot,
+		},
+	}
+
+	at := time.Now()
+	if req.Time != nil {
+		at = *req.Time
+	}
+
+	// The checks run in the order redirects run them
+	destination := info.OriginalURL
+	landingPage := false
+	switch {
+	case info.Disabled:
+		simulation.Outcome, simulation.Status = "disabled", http.StatusUnavailableForLegalReasons
+	case info.ActivateAt != nil && at.Before(*info.ActivateAt):
+		simulation.Outcome, simulation.Status = "not_active", http.StatusNotFound
+	case info.Ephemeral && req.Path != "":
+		simulation.Outcome, simulation.Status = "not_found", http.StatusNotFound
+	case !info.Ephemeral:
+		redirect, err := h.urlService.ResolveRedirect(c.Request.Context(), canonicalCode, req.Path, rawQuery,
+			services.NewVisitor(click, req.VisitorID, at), nil)
+		if err != nil {
+			switch {
+			case apperrors.Is(err, apperrors.ErrNotFound):
+				simulation.Outcome, simulation.Status = "not_found", http.StatusNotFound
+			case apperrors.Is(err, apperrors.ErrInvalid):
+				simulation.Outcome, simulation.Status = "invalid_path", http.StatusBadRequest
+			case apperrors.Is(err, apperrors.ErrNotActive):
+				// Links resolve by the current time, so a later time cannot skip the wait
+				simulation.Outcome, simulation.Status = "not_active", http.StatusNotFound
+			default:
+				h.logger.Errorf("Failed to get original URL: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve URL"})
+				return
+			}
+			break
+		}
+		destination, landingPage = redirect.Destination, redirect.Page != nil
+		if redirect.Rule >= 0 {
+			simulation.Rule = &redirect.Rule
+		}
+	}
+	if simulation.Outcome != "" {
+		c.JSON(http.StatusOK, simulation)
+		return
+	}
+
+	switch {
+	case !landingPage && h.urlService.CheckDestination(destination) != nil:
+		simulation.Outcome, simulation.Status = "destination_refused", http.StatusForbidden
+	case info.ClicksRemaining != nil && *info.ClicksRemaining <= 0:
+		simulation.Outcome, simulation.Status = "click_limit_reached", http.StatusGone
+	case landingPage:
+		simulation.Outcome, simulation.Status = "landing_page", http.StatusOK
+	default:
+		simulation.Outcome, simulation.Status = "redirect", http.StatusFound
+		simulation.Placeholders = services.TemplateValues(destination, click)
+		simulation.Destination = services.ExpandDestination(destination, click)
+	}
 
-	// Redirect to original URL immediately
-	c.Redirect(http.StatusMovedPermanently, originalURL)
+	c.JSON(http.StatusOK, simulation)
+}
+
+// notActive answers a scheduled link visited before its activation time with a holding
+// page, or JSON for API clients
+func (h *URLHandler) notActive(c *gin.Context, activateAt time.Time) {
+	c.Header("Cache-Control", "no-store")
+	if !strings.Contains(c.GetHeader("Accept"), "text/html") {
+		c.JSON(http.StatusNotFound, gin.H{"error": "This link is not active yet", "activate_at": activateAt})
+		return
+	}
+
+	page := fmt.Sprintf(`<!DOCTYPE html><html><head><meta charset="utf-8"><title>Coming soon</title></head>`+
+		`<body><h1>Coming soon</h1><p>This link opens on <time datetime="%s">%s</time>.</p></body></html>`,
+		activateAt.UTC().Format(time.RFC3339), activateAt.UTC().Format("2 January 2006 15:04 MST"))
+	c.Data(http.StatusNotFound, "text/html; charset=utf-8", []byte(page))
+}
+
+// notFound answers an unknown short code, suggesting the intended link when the code
+// looks like a typo of an existing one
+func (h *URLHandler) notFound(c *gin.Context, shortCode string) {
+	suggestions, err := h.urlService.SuggestShortCodes(c.Request.Context(), shortCode)
+	if err != nil {
+		h.logger.Warnf("Failed to suggest short codes: %v", err)
+	}
+	if len(suggestions) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Short URL not found"})
+		return
+	}
+
+	if !strings.Contains(c.GetHeader("Accept"), "text/html") {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Short URL not found", "suggestions": suggestions})
+		return
+	}
+
+	var links strings.Builder
+	for _, suggestion := range suggestions {
+		code := html.EscapeString(suggestion)
+		fmt.Fprintf(&links, `<li><a href="/%s">/%s</a></li>`, url.PathEscape(suggestion), code)
+	}
+	page := fmt.Sprintf(`<!DOCTYPE html><html><head><meta charset="utf-8"><title>Link not found</title></head>`+
+		`<body><h1>Link not found</h1><p>/%s does not exist. Did you mean:</p><ul>%s</ul></body></html>`,
+		html.EscapeString(shortCode), links.String())
+
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusNotFound, "text/html; charset=utf-8", []byte(page))
+}
+
+// GetURLInfo handles GET /api/v1/urls/:short_code, describing the link without redirecting
+func (h *URLHandler) GetURLInfo(c *gin.Context) {
+	shortCode := services.NormalizeShortCode(c.Param("short_code"))
+	if shortCode == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Short code is required"})
+		return
+	}
+
+	info, err := h.urlService.GetURLInfo(c.Request.Context(), shortCode)
+	if err != nil {
+		abortWithError(c, err, "Failed to retrieve URL")
+		return
+	}
+
+	c.JSON(http.StatusOK, info)
+}
+
+// previewURL serves /{short_code}+, showing where a link leads instead of following it:
+// a small page for browsers, otherwise the same JSON as GET /api/v1/urls/:short_code
+func (h *URLHandler) previewURL(c *gin.Context, shortCode string) {
+	info, err := h.urlService.GetURLInfo(c.Request.Context(), shortCode)
+	if err != nil {
+		if apperrors.Is(err, apperrors.ErrNotFound) {
+			h.notFound(c, shortCode)
+			return
+		}
+
+		h.logger.Errorf("Failed to get URL info: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve URL"})
+		return
+	}
+	// Previews are public, so a taken-down destination is not shown either
+	if info.Disabled {
+		c.JSON(http.StatusUnavailableForLegalReasons, gin.H{"error": "This link has been disabled following a complaint"})
+		return
+	}
+	// nor is the destination of a link ahead of its launch
+	if !info.Active {
+		h.notActive(c, *info.ActivateAt)
+		return
+	}
+
+	if !strings.Contains(c.GetHeader("Accept"), "text/html") {
+		c.JSON(http.StatusOK, info)
+		return
+	}
+
+	destination := html.EscapeString(info.OriginalURL)
+	details := fmt.Sprintf("%d clicks", info.ClickCount)
+	if info.Ephemeral {
+		details = "Expires " + info.ExpiresAt.UTC().Format("2 January 2006 15:04 MST")
+	}
+	page := fmt.Sprintf(`<!DOCTYPE html><html><head><meta charset="utf-8"><meta name="robots" content="noindex">`+
+		`<title>Link preview</title></head><body><h1>/%s</h1><p>This link leads to:</p>`+
+		`<p><a href="%s" rel="noopener noreferrer nofollow">%s</a></p>`+
+		`<p>Created %s &middot; %s</p></body></html>`,
+		html.EscapeString(shortCode), destination, destination,
+		info.CreatedAt.UTC().Format("2 January 2006"), details)
+
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(page))
 }
 
 // GetURLStats handles GET /api/v1/urls/:short_code/stats
 func (h *URLHandler) GetURLStats(c *gin.Context) {
-	shortCode := c.Param("short_code")
+	shortCode := services.NormalizeShortCode(c.Param("short_code"))
 	if shortCode == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Short code is required"})
 		return
 	}
+	if services.IsEphemeralCode(shortCode) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ephemeral links keep no statistics"})
+		return
+	}
 
 	// Get URL statistics
-	stats, err := h.urlService.GetURLStats(shortCode)
+	stats, err := h.urlService.GetURLStats(c.Request.Context(), shortCode)
 	if err != nil {
-		if strings.Contains(err.Error(), "not found") {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Short URL not found"})
-			return
-		}
+		abortWithError(c, err, "Failed to retrieve statistics")
+		return
+	}
 
-		h.logger.Errorf("Failed to get URL stats: %v", err)
+	// All clicks so far; the minute allows for clock skew with the database
+	if stats.TrafficBreakdown, err = h.analyticsService.GetTrafficBreakdown(c.Request.Context(), stats.ShortCode, time.Time{}, time.Now().UTC().Add(time.Minute), false); err != nil {
+		h.logger.Errorf("Failed to get traffic breakdown: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve statistics"})
 		return
 	}
@@ -120,73 +744,311 @@ func (h *URLHandler) GetURLStats(c *gin.Context) {
 	c.JSON(http.StatusOK, stats)
 }
 
-// getClientIP extracts the real client IP address
-func (h *URLHandler) getClientIP(c *gin.Context) string {
-	// Check X-Forwarded-For header
-	if xff := c.GetHeader("X-Forwarded-For"); xff != "" {
-		// Take the first IP from the comma-separated list
-		if ips := strings.Split(xff, ","); len(ips) > 0 {
-			return strings.TrimSpace(ips[0])
-		}
+// GetURLAnalytics handles GET /api/v1/urls/:short_code/analytics?interval=&from=&to=&include_internal=.
+// Dates are RFC 3339 timestamps or YYYY-MM-DD days in UTC; the range defaults to the
+// last 30 days.
+func (h *URLHandler) GetURLAnalytics(c *gin.Context) {
+	shortCode := services.NormalizeShortCode(c.Param("short_code"))
+	if shortCode == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Short code is required"})
+		return
+	}
+	if services.IsEphemeralCode(shortCode) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ephemeral links keep no statistics"})
+		return
+	}
+
+	to, err := parseTimeParam(c.Query("to"), time.Now().UTC())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to must be an RFC 3339 timestamp or YYYY-MM-DD"})
+		return
+	}
+	from, err := parseTimeParam(c.Query("from"), to.AddDate(0, 0, -30))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be an RFC 3339 timestamp or YYYY-MM-DD"})
+		return
+	}
+
+	canonicalCode, err := h.urlService.ResolveShortCode(c.Request.Context(), shortCode)
+	if err != nil {
+		abortWithError(c, err, "Failed to retrieve analytics")
+		return
 	}
 
-	// Check X-Real-IP header
-	if xri := c.GetHeader("X-Real-IP"); xri != "" {
-		return xri
+	includeInternal, _ := strconv.ParseBool(c.Query("include_internal"))
+	analytics, err := h.analyticsService.GetURLAnalytics(c.Request.Context(), canonicalCode, c.DefaultQuery("interval", services.AnalyticsIntervalDay), from, to, includeInternal)
+	if err != nil {
+		abortWithError(c, err, "Failed to retrieve analytics")
+		return
 	}
 
-	// Fallback to remote address
-	return c.ClientIP()
+	c.JSON(http.StatusOK, analytics)
 }
 
-// HealthCheck handles GET /health with comprehensive system checks
-func (h *URLHandler) HealthCheck(c *gin.Context) {
-	status := "healthy"
-	checks := make(map[string]interface{})
-	httpStatus := 200
+// GetDomainStats handles GET /api/v1/stats/domains
+func (h *URLHandler) GetDomainStats(c *gin.Context) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if err != nil || days < 1 || days > maxUsageDays {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "days must be between 1 and 365"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > maxDomainStatsLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 100"})
+		return
+	}
 
-	// Check database connectivity
-	if err := h.urlService.HealthCheck(); err != nil {
-		checks["database"] = map[string]interface{}{
-			"status": "unhealthy",
-			"error":  err.Error(),
-		}
-		status = "unhealthy"
-		httpStatus = 503
-	} else {
-		checks["database"] = map[string]interface{}{
-			"status": "healthy",
-		}
+	report, err := h.urlService.GetDomainStats(c.Request.Context(), days, limit)
+	if err != nil {
+		h.logger.Errorf("Failed to get domain stats: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve statistics"})
+		return
 	}
 
-	// Check cache connectivity
-	if err := h.urlService.CacheHealthCheck(); err != nil {
-		checks["cache"] = map[string]interface{}{
-			"status": "degraded",
-			"error":  err.Error(),
-		}
-		if status == "healthy" {
-			status = "degraded"
-		}
-	} else {
-		checks["cache"] = map[string]interface{}{
-			"status": "healthy",
-		}
+	c.JSON(http.StatusOK, report)
+}
+
+// GetCodeSpace handles GET /api/v1/admin/code-space
+func (h *URLHandler) GetCodeSpace(c *gin.Context) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if err != nil || days < 1 || days > maxUsageDays {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "days must be between 1 and 365"})
+		return
+	}
+
+	report, err := h.urlService.GetCodeSpaceReport(c.Request.Context(), days)
+	if err != nil {
+		h.logger.Errorf("Failed to get code space report: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve code space report"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// UpdateURL handles PUT /api/v1/urls/:short_code, changing the destination of a link
+func (h *URLHandler) UpdateURL(c *gin.Context) {
+	var req models.UpdateURLRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	shortCode := services.NormalizeShortCode(c.Param("short_code"))
+	entry, err := h.urlService.UpdateDestination(c.Request.Context(), shortCode, req.URL, req.Normalize, req.UTM, RequestActor(c))
+	if err != nil {
+		abortWithError(c, err, "Failed to update URL")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"short_code":   entry.ShortCode,
+		"original_url": entry.NewURL,
+		"previous_url": entry.PreviousURL,
+		"changed_at":   entry.ChangedAt,
+	})
+}
+
+// GetURLHistory handles GET /api/v1/urls/:short_code/history
+func (h *URLHandler) GetURLHistory(c *gin.Context) {
+	shortCode := services.NormalizeShortCode(c.Param("short_code"))
+	entries, err := h.urlService.GetURLHistory(c.Request.Context(), shortCode)
+	if err != nil {
+		abortWithError(c, err, "Failed to get URL history")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"history": entries})
+}
+
+// GetRedirectRules handles GET /api/v1/urls/:short_code/rules
+func (h *URLHandler) GetRedirectRules(c *gin.Context) {
+	shortCode := services.NormalizeShortCode(c.Param("short_code"))
+	rules, err := h.urlService.GetRedirectRules(c.Request.Context(), shortCode)
+	if err != nil {
+		abortWithError(c, err, "Failed to get redirect rules")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"rules": rules})
+}
+
+// SetRedirectRules handles PUT /api/v1/urls/:short_code/rules, replacing the rules of a link
+func (h *URLHandler) SetRedirectRules(c *gin.Context) {
+	var req models.RedirectRulesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	shortCode := services.NormalizeShortCode(c.Param("short_code"))
+	rules, err := h.urlService.SetRedirectRules(c.Request.Context(), shortCode, req.Rules)
+	if err != nil {
+		abortWithError(c, err, "Failed to update redirect rules")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"rules": rules})
+}
+
+// GetLandingPage handles GET /api/v1/urls/:short_code/page
+func (h *URLHandler) GetLandingPage(c *gin.Context) {
+	page, err := h.urlService.GetLandingPage(c.Request.Context(), services.NormalizeShortCode(c.Param("short_code")))
+	if err != nil {
+		abortWithError(c, err, "Failed to get landing page")
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// SetLandingPage handles PUT /api/v1/urls/:short_code/page, creating or replacing the
+// landing page the link serves instead of redirecting
+func (h *URLHandler) SetLandingPage(c *gin.Context) {
+	var req models.LandingPage
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	page, err := h.urlService.SetLandingPage(c.Request.Context(), services.NormalizeShortCode(c.Param("short_code")), &req)
+	if err != nil {
+		abortWithError(c, err, "Failed to update landing page")
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// DeleteLandingPage handles DELETE /api/v1/urls/:short_code/page; the link redirects again
+func (h *URLHandler) DeleteLandingPage(c *gin.Context) {
+	if err := h.urlService.DeleteLandingPage(c.Request.Context(), services.NormalizeShortCode(c.Param("short_code"))); err != nil {
+		abortWithError(c, err, "Failed to delete landing page")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// AddAlias handles POST /api/v1/urls/:short_code/aliases
+func (h *URLHandler) AddAlias(c *gin.Context) {
+	var req models.AliasRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+		return
+	}
+
+	actor := RequestActor(c)
+	if !h.allowAliasClaim(c, actor, req.Alias) {
+		return
+	}
+
+	alias, err := h.urlService.AddAlias(c.Request.Context(), c.Param("short_code"), req.Alias)
+	if err != nil {
+		abortWithError(c, err, "Failed to add alias")
+		return
+	}
+
+	h.aliasClaims.Claimed(actor, alias.Alias, alias.ShortCode)
+	c.JSON(http.StatusCreated, alias)
+}
+
+// allowAliasClaim answers 429 when the client has used up its custom alias claims or is
+// still cooling down from the previous one
+func (h *URLHandler) allowAliasClaim(c *gin.Context, actor, alias string) bool {
+	decision := h.aliasClaims.Allow(c.Request.Context(), actor, alias)
+	if decision.Allowed {
+		return true
+	}
+
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(decision.RetryAfter.Seconds()))))
+	message := fmt.Sprintf("Maximum %d custom aliases per day allowed", decision.Limit)
+	if decision.Outcome == services.AliasClaimCooldown {
+		message = "Please wait before claiming another custom alias"
+	}
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": "Alias claim limit exceeded", "message": message})
+	return false
+}
+
+// ListAliases handles GET /api/v1/urls/:short_code/aliases
+func (h *URLHandler) ListAliases(c *gin.Context) {
+	aliases, err := h.urlService.ListAliases(c.Request.Context(), c.Param("short_code"))
+	if err != nil {
+		abortWithError(c, err, "Failed to list aliases")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"aliases": aliases})
+}
+
+// DeleteAlias handles DELETE /api/v1/urls/:short_code/aliases/:alias
+func (h *URLHandler) DeleteAlias(c *gin.Context) {
+	if err := h.urlService.RemoveAlias(c.Request.Context(), c.Param("short_code"), c.Param("alias")); err != nil {
+		abortWithError(c, err, "Failed to delete alias")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetVisitorJourney handles GET /api/v1/admin/attribution/visitors/:visitor_id
+func (h *URLHandler) GetVisitorJourney(c *gin.Context) {
+	journey, err := h.analyticsService.GetVisitorJourney(c.Request.Context(), c.Param("visitor_id"))
+	if err != nil {
+		abortWithError(c, err, "Failed to retrieve visitor journey")
+		return
 	}
 
-	// Add service metadata
-	checks["service"] = map[string]interface{}{
-		"name":    "url-shortener",
-		"version": "1.0.0",
-		"uptime":  time.Since(startTime).String(),
+	c.JSON(http.StatusOK, journey)
+}
+
+// DeleteURL handles DELETE /api/v1/admin/urls/:short_code, removing an abusive link for
+// good. Taking a link down reversibly goes through takedown requests instead.
+func (h *URLHandler) DeleteURL(c *gin.Context) {
+	shortCode := services.NormalizeShortCode(c.Param("short_code"))
+	if err := h.urlService.DeleteURL(c.Request.Context(), shortCode); err != nil {
+		abortWithError(c, err, "Failed to delete URL")
+		return
 	}
 
-	response := gin.H{
-		"status": status,
-		"checks": checks,
+	h.trending.Remove(c.Request.Context(), shortCode)
+	h.logger.Infof("Admin deleted link %s", shortCode)
+	c.Status(http.StatusNoContent)
+}
+
+// GetTrendingLinks handles GET /api/v1/urls/trending, listing the links with the most
+// recent human clicks
+func (h *URLHandler) GetTrendingLinks(c *gin.Context) {
+	limit, ok := linkListLimit(c)
+	if !ok {
+		return
 	}
 
-	c.JSON(httpStatus, response)
+	links, err := h.trending.Top(c.Request.Context(), limit)
+	if err != nil {
+		h.logger.Errorf("Failed to get trending links: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get trending links"})
+		return
+	}
+	for i := range links {
+		links[i].ShortURL = h.domains.ShortURL("", links[i].ShortCode)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"links":     links,
+		"enabled":   h.trending.Enabled(),
+		"half_life": h.trending.HalfLife().String(),
+	})
+}
+
+// getCountry returns the visitor's ISO country code as reported by the CDN in front of the service
+func (h *URLHandler) getCountry(c *gin.Context) string {
+	for _, header := range []string{"CF-IPCountry", "CloudFront-Viewer-Country", "X-Country-Code"} {
+		if country := strings.ToUpper(strings.TrimSpace(c.GetHeader(header))); len(country) == 2 {
+			return country
+		}
+	}
+	return ""
 }
 
 var startTime = time.Now() // Track service start time
@@ -194,17 +1056,44 @@ var startTime = time.Now() // Track service start time
 // MetricsHandler provides basic metrics for monitoring
 func (h *URLHandler) MetricsHandler(c *gin.Context) {
 	// This is a basic implementation - in production you'd use Prometheus
+	cacheHits, cacheMisses := h.urlService.CacheCounts()
 	metrics := gin.H{
 		"service": gin.H{
 			"name":    "url-shortener",
-			"version": "1.0.0",
+			"version": buildinfo.Version,
 			"uptime":  time.Since(startTime).String(),
 		},
 		"system": gin.H{
 			"timestamp": time.Now().Unix(),
 		},
+		"slo":     h.sloService.Status(),
+		"cohorts": h.canaryService.Metrics(),
+		// Live numbers of the instance serving the request, for dashboards
+		"redirects": redirectRates(h.sloService),
+		"cache":     gin.H{"hits": cacheHits, "misses": cacheMisses},
+		"queues":    h.analyticsService.QueueDepths(),
 		// Add more metrics as needed
 	}
 
 	c.JSON(200, metrics)
 }
+
+// redirectRates averages redirects and server errors over the last five minutes
+func redirectRates(slo *services.SLOService) gin.H {
+	const window = 5 * time.Minute
+	total, serverErrors := slo.Outcomes(window)
+	errorRate := 0.0
+	if total > 0 {
+		errorRate = float64(serverErrors) / float64(total)
+	}
+	return gin.H{
+		"window":     window.String(),
+		"per_minute": float64(total) / window.Minutes(),
+		"error_rate": errorRate,
+	}
+}
+
+// SLOStatus handles GET /slo with redirect SLO compliance and burn rates
+func (h *URLHandler) SLOStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, h.sloService.Status())
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+
+	"github.com/alexnthnz/url-shortener/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type WidgetHandler struct {
+	widgetService *services.WidgetService
+	logger        *logrus.Logger
+}
+
+func NewWidgetHandler(widgetService *services.WidgetService, logger *logrus.Logger) *WidgetHandler {
+	return &WidgetHandler{
+		widgetService: widgetService,
+		logger:        logger,
+	}
+}
+
+// WidgetSVG handles GET /api/v1/urls/:short_code/widget.svg
+func (h *WidgetHandler) WidgetSVG(c *gin.Context) {
+	shortCode, ok := h.authorize(c)
+	if !ok {
+		return
+	}
+
+	svg, err := h.widgetService.RenderSVG(c.Request.Context(), shortCode)
+	if err != nil {
+		abortWithError(c, err, "Failed to render widget")
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.Data(http.StatusOK, "image/svg+xml", []byte(svg))
+}
+
+// WidgetEmbed handles GET /api/v1/urls/:short_code/widget, an iframe-friendly page wrapping the SVG
+func (h *WidgetHandler) WidgetEmbed(c *gin.Context) {
+	shortCode, ok := h.authorize(c)
+	if !ok {
+		return
+	}
+
+	src := fmt.Sprintf("/api/v1/urls/%s/widget.svg?token=%s",
+		url.PathEscape(shortCode), url.QueryEscape(c.Query("token")))
+	page := fmt.Sprintf(`<!DOCTYPE html><html><head><meta charset="utf-8"><meta http-equiv="refresh" content="300">`+
+		`<style>body{margin:0}</style></head><body><img src="%s" alt="Click statistics"></body></html>`,
+		html.EscapeString(src))
+
+	// Allow this page, and only this page, to be framed by third-party sites
+	c.Header("X-Frame-Options", "")
+	c.Header("Content-Security-Policy", "frame-ancestors *")
+	c.Header("Cache-Control", "public, max-age=300")
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(page))
+}
+
+// authorize validates the signed widget token for the requested short code
+func (h *WidgetHandler) authorize(c *gin.Context) (string, bool) {
+	if !h.widgetService.Enabled() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Widgets are not enabled"})
+		return "", false
+	}
+
+	shortCode := services.NormalizeShortCode(c.Param("short_code"))
+	if !h.widgetService.VerifyToken(shortCode, c.Query("token")) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid widget token"})
+		return "", false
+	}
+
+	return shortCode, true
+}
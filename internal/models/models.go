@@ -10,34 +10,964 @@ type URL struct {
 	CustomAlias bool       `json:"custom_alias" db:"custom_alias"`
 	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
 	ExpiresAt   *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	// ActivateAt schedules a link: until then it answers 404 with a holding page
+	ActivateAt *time.Time `json:"activate_at,omitempty" db:"activate_at"`
+	// PathPassthrough appends extra path segments and query parameters of the short URL to the destination
+	PathPassthrough bool `json:"path_passthrough" db:"path_passthrough"`
+	// MaxClicks is the number of redirects after which the link answers 410 Gone
+	MaxClicks *int64 `json:"max_clicks,omitempty" db:"max_clicks"`
+	// DisabledAt is set while the link is taken down and answers 451
+	DisabledAt     *time.Time `json:"disabled_at,omitempty" db:"disabled_at"`
+	DisabledReason string     `json:"disabled_reason,omitempty" db:"disabled_reason"`
+	// NumericCode is a digits-only code resolving through /n/{digits}, for SMS and NFC
+	NumericCode string `json:"numeric_code,omitempty" db:"numeric_code"`
+	// CodeStrategy records how the short code was chosen: sequential, random, deterministic,
+	// pronounceable, words or custom; links created before it was recorded have none
+	CodeStrategy string `json:"-" db:"code_strategy"`
+	// UTM holds the UTM parameters added to the destination at redirect time
+	UTM *UTMParams `json:"utm,omitempty" db:"-"`
+	// Ephemeral links live only in Redis until ExpiresAt
+	Ephemeral bool `json:"ephemeral,omitempty" db:"-"`
+	// Existing is set when a deduplicated shorten request returned this link instead of
+	// creating one
+	Existing bool `json:"-" db:"-"`
 }
 
 // Analytics represents click analytics for a URL
 type Analytics struct {
 	ID        int64     `json:"id" db:"id"`
+	ClickID   string    `json:"click_id,omitempty" db:"click_id"`
+	VisitorID string    `json:"visitor_id,omitempty" db:"visitor_id"`
 	ShortCode string    `json:"short_code" db:"short_code"`
 	ClickedAt time.Time `json:"clicked_at" db:"clicked_at"`
 	IPAddress string    `json:"ip_address" db:"ip_address"`
 	UserAgent string    `json:"user_agent" db:"user_agent"`
+	Referrer  string    `json:"referrer,omitempty" db:"referrer"`
+	// DeviceType, Browser and OS are parsed from the user agent when the click is recorded
+	DeviceType string `json:"device_type,omitempty" db:"device_type"`
+	Browser    string `json:"browser,omitempty" db:"browser"`
+	OS         string `json:"os,omitempty" db:"os"`
+	// IsBot flags clicks of crawlers and scripts, which default click counts leave out
+	IsBot bool `json:"is_bot" db:"is_bot"`
+	// IsInternal flags clicks from the team's own networks, which default click counts leave out
+	IsInternal bool `json:"is_internal" db:"is_internal"`
+	// ViaQR marks clicks that came through the QR code URL of the link
+	ViaQR bool `json:"via_qr" db:"via_qr"`
+	// RedirectRule is the index of the redirect rule that picked the destination, nil for
+	// the link's own destination
+	RedirectRule *int `json:"redirect_rule,omitempty" db:"redirect_rule"`
 }
 
 // URLStats represents aggregated statistics for a URL
 type URLStats struct {
-	ShortCode   string    `json:"short_code"`
-	OriginalURL string    `json:"original_url"`
-	ClickCount  int64     `json:"click_count"`
-	CreatedAt   time.Time `json:"created_at"`
+	ShortCode      string    `json:"short_code"`
+	OriginalURL    string    `json:"original_url"`
+	ClickCount     int64     `json:"click_count"` // clicks by people; bots and internal clicks are counted apart
+	BotClicks      int64     `json:"bot_clicks"`
+	InternalClicks int64     `json:"internal_clicks"`
+	QRScans        int64     `json:"qr_scans"` // the part of ClickCount scanned from a QR code
+	CreatedAt      time.Time `json:"created_at"`
+	Aliases        []string  `json:"aliases,omitempty"`
+	TrafficBreakdown
+}
+
+// URLInfo describes a link without following it
+type URLInfo struct {
+	ShortCode       string     `json:"short_code"`
+	CanonicalCode   string     `json:"canonical_code,omitempty"` // set when looked up by an alias
+	OriginalURL     string     `json:"original_url"`
+	CreatedAt       time.Time  `json:"created_at"`
+	ExpiresAt       *time.Time `json:"expires_at,omitempty"`
+	ActivateAt      *time.Time `json:"activate_at,omitempty"`
+	Active          bool       `json:"active"` // false until ActivateAt
+	PathPassthrough bool       `json:"path_passthrough"`
+	ClickCount      int64      `json:"click_count"`
+	MaxClicks       *int64     `json:"max_clicks,omitempty"`
+	ClicksRemaining *int64     `json:"clicks_remaining,omitempty"` // set for capped links
+	Disabled        bool       `json:"disabled,omitempty"`
+	Ephemeral       bool       `json:"ephemeral,omitempty"` // kept only in Redis, without statistics
+	NumericCode     string     `json:"numeric_code,omitempty"`
+	UTM             *UTMParams `json:"utm,omitempty"`
+}
+
+// UTMParams are the UTM parameters a link adds to its destination when redirecting.
+// Parameters the destination already sets are left alone.
+type UTMParams struct {
+	Source   string `json:"source,omitempty" db:"utm_source"`
+	Medium   string `json:"medium,omitempty" db:"utm_medium"`
+	Campaign string `json:"campaign,omitempty" db:"utm_campaign"`
+	Term     string `json:"term,omitempty" db:"utm_term"`
+	Content  string `json:"content,omitempty" db:"utm_content"`
+}
+
+// RedirectRule sends visitors matching all of its conditions to another destination. A
+// link's rules are evaluated in order and the first match wins; visitors matching none go
+// to the link's own destination.
+type RedirectRule struct {
+	Countries []string   `json:"countries,omitempty"` // ISO 3166 codes
+	Devices   []string   `json:"devices,omitempty"`   // desktop, mobile, tablet, bot or unknown
+	Platforms []string   `json:"platforms,omitempty"` // ios, android, windows, macos, linux, chromeos or other
+	Languages []string   `json:"languages,omitempty"` // primary language subtags, e.g. "de"
+	After     *time.Time `json:"after,omitempty"`
+	Before    *time.Time `json:"before,omitempty"`
+	// Percent limits the rule to a stable share of visitors, for A/B tests. Each percentage
+	// rule takes the next slice of visitors, so the percentages of a link add up to at most 100.
+	Percent     int    `json:"percent,omitempty"`
+	Destination string `json:"destination"`
+}
+
+// LandingPage is a hosted link-in-bio page that a short code serves instead of
+// redirecting, listing several destinations as buttons in order
+type LandingPage struct {
+	Title       string            `json:"title"`
+	Description string            `json:"description,omitempty"`
+	Links       []LandingPageLink `json:"links"`
+}
+
+// LandingPageLink is a button of a landing page
+type LandingPageLink struct {
+	Label string `json:"label"`
+	URL   string `json:"url"`
+}
+
+// LinkDestinations lists every URL a link can send visitors to: its destination, the
+// destinations of its redirect rules and the buttons of its landing page
+type LinkDestinations struct {
+	ID        int64
+	ShortCode string
+	URLs      []string
+}
+
+// RedirectRulesRequest replaces the redirect rules of a link
+type RedirectRulesRequest struct {
+	Rules []RedirectRule `json:"rules"`
+}
+
+// SimulationRequest describes a synthetic visitor of a link
+type SimulationRequest struct {
+	Country        string `json:"country,omitempty"` // ISO 3166 code, as CDNs send it
+	UserAgent      string `json:"user_agent,omitempty"`
+	Device         string `json:"device,omitempty"`          // overrides the device type parsed from UserAgent
+	AcceptLanguage string `json:"accept_language,omitempty"` // an Accept-Language header value
+	Path           string `json:"path,omitempty"`            // extra path after the short code
+	Query          string `json:"query,omitempty"`           // query string of the short URL
+	// VisitorID stands in for the attribution cookie, which keeps A/B variants stable
+	VisitorID string     `json:"visitor_id,omitempty"`
+	Time      *time.Time `json:"time,omitempty"` // defaults to now
+}
+
+// RedirectSimulation is what following a link would do for a synthetic visitor
+type RedirectSimulation struct {
+	ShortCode     string            `json:"short_code"`
+	CanonicalCode string            `json:"canonical_code,omitempty"` // set when simulated through an alias
+	Outcome       string            `json:"outcome"`
+	Status        int               `json:"status"`
+	Rule          *int              `json:"rule,omitempty"` // index of the redirect rule that fired
+	Destination   string            `json:"destination,omitempty"`
+	Placeholders  map[string]string `json:"placeholders,omitempty"` // values substituted into the destination template
+	Visitor       SimulatedVisitor  `json:"visitor"`
+}
+
+// SimulatedVisitor is how redirects see a synthetic visitor
+type SimulatedVisitor struct {
+	Country    string `json:"country,omitempty"`
+	Language   string `json:"language,omitempty"`
+	DeviceType string `json:"device_type"`
+	Browser    string `json:"browser"`
+	OS         string `json:"os"`
+	Bot        bool   `json:"bot"`
+}
+
+// RedirectAudit is a sampled record of how a redirect was decided
+type RedirectAudit struct {
+	ClickID       string       `json:"click_id"`
+	At            time.Time    `json:"at"`
+	ShortCode     string       `json:"short_code"`
+	CanonicalCode string       `json:"canonical_code,omitempty"`
+	Country       string       `json:"country,omitempty"`
+	Language      string       `json:"language,omitempty"`
+	Device        string       `json:"device"`
+	Bucket        *int         `json:"bucket,omitempty"` // A/B bucket of the visitor, 0 to 99
+	Rule          *int         `json:"rule,omitempty"`   // index of the redirect rule that fired
+	Outcome       string       `json:"outcome"`
+	Status        int          `json:"status"`
+	Destination   string       `json:"destination,omitempty"`
+	Stages        []AuditStage `json:"stages"`
+	TotalMicros   int64        `json:"total_us"`
+}
+
+// AuditStage is the time one stage of a redirect took
+type AuditStage struct {
+	Name   string `json:"name"`
+	Micros int64  `json:"duration_us"`
+}
+
+// DailyClicks represents the click count of a single day
+type DailyClicks struct {
+	Day    time.Time `json:"day"`
+	Clicks int64     `json:"clicks"`
+}
+
+// ClickBucket is the click count of one hour, day or week starting at Start
+type ClickBucket struct {
+	Start  time.Time `json:"start"`
+	Clicks int64     `json:"clicks"`
+}
+
+// URLAnalytics is the detailed click analytics of a link over [From, To)
+type URLAnalytics struct {
+	ShortCode      string           `json:"short_code"`
+	Interval       string           `json:"interval"`
+	From           time.Time        `json:"from"`
+	To             time.Time        `json:"to"`
+	TotalClicks    int64            `json:"total_clicks"` // clicks by people
+	BotClicks      int64            `json:"bot_clicks"`
+	InternalClicks int64            `json:"internal_clicks"` // by people on internal networks, not in TotalClicks by default
+	QRScans        int64            `json:"qr_scans"`
+	UniqueVisitors int64            `json:"unique_visitors"`
+	Buckets        []ClickBucket    `json:"buckets"`
+	TopUserAgents  []DimensionCount `json:"top_user_agents"`
+	TrafficBreakdown
+	// Variants splits the clicks by the redirect rule that picked their destination, for
+	// comparing A/B tests; absent when every click went to the link's own destination
+	Variants []VariantStats `json:"variants,omitempty"`
+}
+
+// VariantStats counts the clicks sent to one destination of a link
+type VariantStats struct {
+	Rule           *int  `json:"rule"` // index of the redirect rule, null for the link's own destination
+	Clicks         int64 `json:"clicks"`
+	UniqueVisitors int64 `json:"unique_visitors"`
+}
+
+// ClickSummary counts the clicks of a link over a time range
+type ClickSummary struct {
+	Clicks         int64 // by people
+	BotClicks      int64
+	InternalClicks int64 // by people on internal networks
+	QRScans        int64 // the part of Clicks scanned from a QR code
+	Visitors       int64 // unique people
+}
+
+// TrafficBreakdown tells where the clicks of a link come from: referring hosts, device
+// types (desktop, mobile, tablet), browsers and operating systems
+type TrafficBreakdown struct {
+	TopReferrers     []DimensionCount `json:"top_referrers"`
+	Devices          []DimensionCount `json:"devices"`
+	Browsers         []DimensionCount `json:"browsers"`
+	OperatingSystems []DimensionCount `json:"operating_systems"`
+}
+
+// DailyCount represents a generic count for a single day
+type DailyCount struct {
+	Day   time.Time `json:"day"`
+	Count int64     `json:"count"`
 }
 
 // ShortenRequest represents the request payload for shortening a URL
 type ShortenRequest struct {
 	URL         string `json:"url" binding:"required,url"`
 	CustomAlias string `json:"custom_alias,omitempty"`
+	CodeStyle   string `json:"code_style,omitempty"`
+	// PathPassthrough forwards /{short_code}/extra/path?x=1 to the destination with the extra path and query
+	PathPassthrough bool `json:"path_passthrough,omitempty"`
+	// Normalize overrides the instance's URL normalization rules for this link
+	Normalize *NormalizeRules `json:"normalize,omitempty"`
+	// MaxClicks retires the link with 410 Gone after this many redirects
+	MaxClicks *int64 `json:"max_clicks,omitempty"`
+	// Ephemeral keeps the link only in Redis for TTLSeconds (default one hour); it is
+	// never stored in the database and records no statistics
+	Ephemeral  bool  `json:"ephemeral,omitempty"`
+	TTLSeconds int64 `json:"ttl_seconds,omitempty"`
+	// Domain puts the link on a custom domain registered by the signing key, where the
+	// custom alias or generated code only has to be unique within that domain
+	Domain string `json:"domain,omitempty"`
+	// Profile adapts the short URL to a channel: "sms" keeps it within a length limit and
+	// to characters every SMS gateway passes through
+	Profile string `json:"profile,omitempty"`
+	// MaxCodeLength is the room the profile leaves for the code, worked out by the server
+	MaxCodeLength int `json:"-"`
+	// Deduplicate returns the existing link to the same destination, if there is a plain
+	// one, instead of creating another; unset keeps the instance default
+	Deduplicate *bool `json:"deduplicate,omitempty"`
+	// DomainAlias is the custom alias of a link on its custom domain, which the server
+	// moves out of CustomAlias since the canonical link gets a generated code
+	DomainAlias string `json:"-"`
+	// UTM tags every redirect of the link with these UTM parameters
+	UTM *UTMParams `json:"utm,omitempty"`
+	// ActivateAt creates the link ahead of a launch; it answers 404 until then
+	ActivateAt *time.Time `json:"activate_at,omitempty"`
+	// Deterministic derives the code from a keyed hash of the normalized destination, so
+	// repeating the request returns the same link instead of creating another
+	Deterministic bool `json:"deterministic,omitempty"`
+}
+
+// LinkProposal is what link validators are asked about: a new link, an alias, or a new
+// destination of an existing link
+type LinkProposal struct {
+	Action      string `json:"action"`
+	ShortCode   string `json:"short_code,omitempty"` // the existing link, for changes
+	Alias       string `json:"alias,omitempty"`      // the requested custom alias
+	Destination string `json:"destination,omitempty"`
+	Domain      string `json:"domain,omitempty"` // the custom domain of a new link
+	Ephemeral   bool   `json:"ephemeral,omitempty"`
+}
+
+// LinkVerdict is a validation service's answer to a LinkProposal
+type LinkVerdict struct {
+	Allowed bool   `json:"allowed"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// NormalizeRules selects the URL normalization rules applied to a destination; rules left
+// unset keep the instance default
+type NormalizeRules struct {
+	ForceHTTPS         *bool `json:"force_https,omitempty"`
+	StripTrailingSlash *bool `json:"strip_trailing_slash,omitempty"`
+	StripFragment      *bool `json:"strip_fragment,omitempty"`
+	LowercaseHost      *bool `json:"lowercase_host,omitempty"`
+	SortQuery          *bool `json:"sort_query,omitempty"`
+}
+
+// UpdateURLRequest represents the request payload for changing the destination of a short code
+type UpdateURLRequest struct {
+	URL       string          `json:"url" binding:"required,url"`
+	Normalize *NormalizeRules `json:"normalize,omitempty"`
+	// UTM replaces the link's UTM parameters; an empty object removes them, and leaving it
+	// out keeps them
+	UTM *UTMParams `json:"utm,omitempty"`
+}
+
+// URLHistoryEntry records one change of a short code's destination
+type URLHistoryEntry struct {
+	ID          int64     `json:"id" db:"id"`
+	ShortCode   string    `json:"short_code" db:"short_code"`
+	PreviousURL string    `json:"previous_url" db:"previous_url"`
+	NewURL      string    `json:"new_url" db:"new_url"`
+	ChangedBy   string    `json:"changed_by" db:"changed_by"`
+	ChangedAt   time.Time `json:"changed_at" db:"changed_at"`
 }
 
 // ShortenResponse represents the response when creating a short URL
 type ShortenResponse struct {
+	ShortCode   string     `json:"short_code"`
+	ShortURL    string     `json:"short_url"`
+	QRURL       string     `json:"qr_url,omitempty"` // ShortURL marked as a QR scan, to encode in QR codes
+	Domain      string     `json:"domain,omitempty"`
+	NumericCode string     `json:"numeric_code,omitempty"`
+	NumericURL  string     `json:"numeric_url,omitempty"` // resolves like ShortURL, with digits only
+	OriginalURL string     `json:"original_url"`
+	WidgetToken string     `json:"widget_token,omitempty"`
+	MaxClicks   *int64     `json:"max_clicks,omitempty"`
+	UTM         *UTMParams `json:"utm,omitempty"`
+	// Ephemeral links expire at ExpiresAt and keep no statistics
+	Ephemeral bool       `json:"ephemeral,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// ActivateAt is when a scheduled link starts redirecting
+	ActivateAt *time.Time `json:"activate_at,omitempty"`
+	// Existing is set when an existing link was returned instead of a new one
+	Existing bool `json:"existing,omitempty"`
+}
+
+// ShortenPreview is the link a dry-run shorten request would create. Generated codes are
+// assigned on creation, so ShortCode is only set for custom aliases.
+type ShortenPreview struct {
+	DryRun          bool       `json:"dry_run"`
+	ShortCode       string     `json:"short_code,omitempty"`
+	ShortURL        string     `json:"short_url,omitempty"`
+	OriginalURL     string     `json:"original_url"`
+	CustomAlias     bool       `json:"custom_alias"`
+	CodeStyle       string     `json:"code_style,omitempty"`
+	PathPassthrough bool       `json:"path_passthrough"`
+	MaxClicks       *int64     `json:"max_clicks,omitempty"`
+	Ephemeral       bool       `json:"ephemeral,omitempty"`
+	Domain          string     `json:"domain,omitempty"`
+	UTM             *UTMParams `json:"utm,omitempty"`
+	ActivateAt      *time.Time `json:"activate_at,omitempty"`
+	Existing        bool       `json:"existing,omitempty"` // an existing link would be returned
+}
+
+// Touchpoint is one click in a visitor journey
+type Touchpoint struct {
+	ShortCode string    `json:"short_code"`
+	ClickID   string    `json:"click_id,omitempty"`
+	ClickedAt time.Time `json:"clicked_at"`
+}
+
+// VisitorJourney lists the short links one attributed visitor clicked, oldest first
+type VisitorJourney struct {
+	VisitorID   string       `json:"visitor_id"`
+	FirstTouch  string       `json:"first_touch"`
+	LastTouch   string       `json:"last_touch"`
+	Touchpoints []Touchpoint `json:"touchpoints"`
+}
+
+// DataSubject identifies the person a privacy request is about, by IP address or
+// attribution visitor id
+type DataSubject struct {
+	IPAddress string `json:"ip_address,omitempty"`
+	VisitorID string `json:"visitor_id,omitempty"`
+}
+
+// DataSubjectReport lists all stored click analytics referencing a data subject
+type DataSubjectReport struct {
+	Subject     DataSubject  `json:"subject"`
+	GeneratedAt time.Time    `json:"generated_at"`
+	ClickCount  int          `json:"click_count"`
+	Clicks      []*Analytics `json:"clicks"`
+}
+
+// ErasureResult reports how many click events were anonymized per database
+type ErasureResult struct {
+	Subject    DataSubject `json:"subject"`
+	Anonymized int64       `json:"anonymized"`
+	Mirror     int64       `json:"mirror_anonymized,omitempty"`
+}
+
+// ComplianceRedirect is an append-only record of a redirect to a sensitive domain
+type ComplianceRedirect struct {
+	ID              int64     `json:"id" db:"id"`
+	OccurredAt      time.Time `json:"occurred_at" db:"occurred_at"`
+	ShortCode       string    `json:"short_code" db:"short_code"`
+	ClickID         string    `json:"click_id" db:"click_id"`
+	Destination     string    `json:"destination" db:"destination"`
+	DestinationHost string    `json:"destination_host" db:"destination_host"`
+	MatchedDomain   string    `json:"matched_domain" db:"matched_domain"`
+	VisitorCountry  string    `json:"visitor_country,omitempty" db:"visitor_country"`
+}
+
+// Alias is an additional short code sharing the link, analytics and settings of a canonical short code
+type Alias struct {
+	Alias     string    `json:"alias"`
+	ShortCode string    `json:"short_code"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AliasRequest represents the request payload for adding an alias
+type AliasRequest struct {
+	Alias string `json:"alias" binding:"required"`
+}
+
+// Webhook represents a click notification subscription
+type Webhook struct {
+	ID                int64     `json:"id" db:"id"`
+	TargetURL         string    `json:"target_url" db:"target_url"`
+	ShortCode         string    `json:"short_code,omitempty" db:"short_code"`
+	Secret            string    `json:"secret,omitempty" db:"secret"`
+	AggregationWindow int       `json:"aggregation_window" db:"aggregation_window"` // seconds, 0 delivers every click
+	CreatedAt         time.Time `json:"created_at" db:"created_at"`
+}
+
+// WebhookRequest represents the request payload for creating a webhook subscription
+type WebhookRequest struct {
+	TargetURL         string `json:"target_url" binding:"required,url"`
+	ShortCode         string `json:"short_code,omitempty"`
+	AggregationWindow int    `json:"aggregation_window,omitempty"`
+}
+
+// WebhookClickEvent is the payload delivered for every click to subscriptions without an aggregation window
+type WebhookClickEvent struct {
+	Event         string    `json:"event"`
+	SchemaVersion int       `json:"schema_version"`
+	ClickID       string    `json:"click_id,omitempty"`
+	ShortCode     string    `json:"short_code"`
+	ClickedAt     time.Time `json:"clicked_at"`
+	UserAgent     string    `json:"user_agent"`
+}
+
+// LinkEvent is a link lifecycle event as published to the event stream
+type LinkEvent struct {
+	Event       string    `json:"event"`
+	ShortCode   string    `json:"short_code"`
+	OriginalURL string    `json:"original_url,omitempty"`
+	Reason      string    `json:"reason,omitempty"`
+	OccurredAt  time.Time `json:"occurred_at"`
+}
+
+// WebhookClickWindow is the payload summarizing clicks seen during one aggregation window
+type WebhookClickWindow struct {
+	Event         string           `json:"event"`
+	SchemaVersion int              `json:"schema_version"`
+	WindowStart   time.Time        `json:"window_start"`
+	WindowEnd     time.Time        `json:"window_end"`
+	TotalClicks   int64            `json:"total_clicks"`
+	TopShortCodes []DimensionCount `json:"top_short_codes"`
+	TopUserAgents []DimensionCount `json:"top_user_agents"`
+}
+
+// TrendingLink is a link on the trending leaderboard
+type TrendingLink struct {
+	ShortCode string  `json:"short_code"`
+	ShortURL  string  `json:"short_url"`
+	Score     float64 `json:"score"` // clicks, each counting half per half-life of age
+}
+
+// EventSchema is one version of the JSON Schema of a webhook payload
+type EventSchema struct {
+	Event       string                 `json:"event"`
+	Version     int                    `json:"version"`
+	Current     bool                   `json:"current"` // the version deliveries use
+	Description string                 `json:"description"`
+	Schema      map[string]interface{} `json:"schema"`
+}
+
+// DomainStats summarizes the links to one destination domain and the clicks they received
+type DomainStats struct {
+	Domain string `json:"domain"`
+	Links  int64  `json:"links"`
+	Clicks int64  `json:"clicks"`
+}
+
+// DomainStatsReport lists the destination domains with the most clicks over a period
+type DomainStatsReport struct {
+	Days         int           `json:"days"`
+	TotalDomains int           `json:"total_domains"`
+	Domains      []DomainStats `json:"domains"`
+}
+
+// CodeSpaceUsage counts the links of one code strategy and code length
+type CodeSpaceUsage struct {
+	Strategy string
+	Length   int
+	Links    int64
+	Recent   int64 // created within the report window
+}
+
+// CodeSpaceReport shows how full the code space of every generation strategy is, so
+// operators know when to lengthen codes
+type CodeSpaceReport struct {
+	GeneratedAt time.Time      `json:"generated_at"`
+	Days        int            `json:"days"`
+	Strategies  []CodeStrategy `json:"strategies"`
+}
+
+// CodeStrategy reports the codes of one generation strategy. Attempts and collisions count
+// generated codes and the ones found taken since the serving instance started.
+type CodeStrategy struct {
+	Strategy      string            `json:"strategy"`
+	Links         int64             `json:"links"`
+	Attempts      int64             `json:"attempts"`
+	Collisions    int64             `json:"collisions"`
+	CollisionRate float64           `json:"collision_rate"`
+	Lengths       []CodeLengthUsage `json:"lengths"`
+}
+
+// CodeLengthUsage reports the codes of one length within a strategy. Capacity is left
+// out for custom aliases and links whose strategy was not recorded.
+type CodeLengthUsage struct {
+	Length        int        `json:"length"`
+	Links         int64      `json:"links"`
+	Capacity      int64      `json:"capacity,omitempty"`
+	Utilization   float64    `json:"utilization"`
+	CreatedPerDay float64    `json:"created_per_day"`
+	ExhaustsAt    *time.Time `json:"exhausts_at,omitempty"`
+}
+
+// DimensionCount represents a click count for a single dimension value
+type DimensionCount struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// UsageDay represents instance-wide activity for a single day
+type UsageDay struct {
+	Date          string  `json:"date"`
+	LinksCreated  int64   `json:"links_created"`
+	Redirects     int64   `json:"redirects"`
+	CacheHits     int64   `json:"cache_hits"`
+	CacheMisses   int64   `json:"cache_misses"`
+	CacheHitRatio float64 `json:"cache_hit_ratio"`
+}
+
+// UsageReport represents instance-wide usage over a period for capacity planning
+type UsageReport struct {
+	From          string           `json:"from"`
+	To            string           `json:"to"`
+	LinksCreated  int64            `json:"links_created"`
+	Redirects     int64            `json:"redirects"`
+	CacheHitRatio float64          `json:"cache_hit_ratio"`
+	Days          []UsageDay       `json:"days"`
+	TopDomains    []DimensionCount `json:"top_domains"`
+}
+
+// InstanceTotals counts everything stored by the instance
+type InstanceTotals struct {
+	Links         int64 `json:"links"`
+	DisabledLinks int64 `json:"disabled_links"`
+	Aliases       int64 `json:"aliases"`
+	Clicks        int64 `json:"clicks"` // without bots
+	BotClicks     int64 `json:"bot_clicks"`
+}
+
+// LinkSummary represents a link in admin listings
+type LinkSummary struct {
+	ShortCode   string    `json:"short_code"`
+	OriginalURL string    `json:"original_url"`
+	CreatedAt   time.Time `json:"created_at"`
+	Disabled    bool      `json:"disabled,omitempty"`
+	Clicks      int64     `json:"clicks"` // without bots; top links only count their period
+}
+
+// Job represents a long-running background job such as a bulk purge
+type Job struct {
+	ID         int64      `json:"id" db:"id"`
+	Type       string     `json:"type" db:"type"`
+	Status     string     `json:"status" db:"status"`
+	Processed  int64      `json:"processed" db:"processed"`
+	Error      string     `json:"error,omitempty" db:"error"`
+	Cursor     string     `json:"-" db:"cursor"` // where the next batch starts, to resume after a restart
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at" db:"updated_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty" db:"finished_at"`
+}
+
+// MaintenanceRequest represents the payload for toggling read-only mode
+type MaintenanceRequest struct {
+	ReadOnly *bool `json:"read_only" binding:"required"`
+}
+
+// RateLimitOverride replaces the route limits for requests signed with one API key
+type RateLimitOverride struct {
+	KeyID             string    `json:"key_id" db:"key_id"`
+	RequestsPerWindow int       `json:"requests_per_window" db:"requests_per_window"`
+	UpdatedAt         time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// RateLimitOverrideRequest represents the payload for setting an API key's rate limit
+type RateLimitOverrideRequest struct {
+	RequestsPerWindow int `json:"requests_per_window" binding:"required,min=1"`
+}
+
+// SigningKeyUsage is the last use of a request signing key
+type SigningKeyUsage struct {
+	KeyID      string    `db:"key_id"`
+	LastUsedAt time.Time `db:"last_used_at"`
+	LastUsedIP string    `db:"last_used_ip"`
+}
+
+// SigningKey describes a configured request signing key, without its secret
+type SigningKey struct {
+	KeyID      string     `json:"key_id"`
+	Scopes     []string   `json:"scopes,omitempty"` // empty for full access
+	Domains    []string   `json:"domains,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	Expired    bool       `json:"expired"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP string     `json:"last_used_ip,omitempty"`
+	Stale      bool       `json:"stale"` // expired, never used, or unused for longer than the stale period
+}
+
+// DomainPolicy allows or blocks shortening and redirecting to a destination domain and
+// its subdomains
+type DomainPolicy struct {
+	Domain    string    `json:"domain" db:"domain"`
+	Action    string    `json:"action" db:"action"` // "allow" or "block"
+	Reason    string    `json:"reason,omitempty" db:"reason"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// DomainPolicyRequest represents the payload for setting a domain policy
+type DomainPolicyRequest struct {
+	Action string `json:"action" binding:"required"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// DomainPolicyViolation describes existing links whose destination domain a policy no
+// longer permits
+type DomainPolicyViolation struct {
+	Domain     string   `json:"domain"`
+	Rule       string   `json:"rule"`             // the blocking domain, or "allowlist"
+	Reason     string   `json:"reason,omitempty"` // reason of the blocking policy
+	Links      int64    `json:"links"`
+	Clicks     int64    `json:"clicks"` // over the report period
+	ShortCodes []string `json:"short_codes"`
+}
+
+// TakedownRequest is a legal or abuse complaint against a link, reviewed by an admin
+type TakedownRequest struct {
+	ID             int64      `json:"id" db:"id"`
+	ShortCode      string     `json:"short_code" db:"short_code"`
+	Reason         string     `json:"reason" db:"reason"`
+	ReporterName   string     `json:"reporter_name,omitempty" db:"reporter_name"`
+	ReporterEmail  string     `json:"reporter_email" db:"reporter_email"`
+	Description    string     `json:"description" db:"description"`
+	EvidenceURLs   []string   `json:"evidence_urls" db:"evidence_urls"`
+	Status         string     `json:"status" db:"status"` // pending, upheld or rejected
+	ResolutionNote string     `json:"resolution_note,omitempty" db:"resolution_note"`
+	ResolvedBy     string     `json:"resolved_by,omitempty" db:"resolved_by"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	ResolvedAt     *time.Time `json:"resolved_at,omitempty" db:"resolved_at"`
+}
+
+// TakedownSubmission represents the payload for filing a takedown request
+type TakedownSubmission struct {
+	ShortCode     string   `json:"short_code" binding:"required"`
+	Reason        string   `json:"reason" binding:"required"`
+	ReporterName  string   `json:"reporter_name,omitempty"`
+	ReporterEmail string   `json:"reporter_email" binding:"required,email"`
+	Description   string   `json:"description" binding:"required"`
+	EvidenceURLs  []string `json:"evidence_urls,omitempty"`
+}
+
+// TakedownResolution represents the payload for resolving a takedown request
+type TakedownResolution struct {
+	Status string `json:"status" binding:"required"` // upheld or rejected
+	Note   string `json:"note,omitempty"`
+}
+
+// WebhookTakedownEvent is the payload delivered when a takedown request against a link
+// is filed or resolved
+type WebhookTakedownEvent struct {
+	Event         string    `json:"event"` // takedown.requested or takedown.resolved
+	SchemaVersion int       `json:"schema_version"`
+	TakedownID    int64     `json:"takedown_id"`
+	ShortCode     string    `json:"short_code"`
+	Reason        string    `json:"reason"`
+	Status        string    `json:"status"`
+	Disabled      bool      `json:"disabled"`
+	OccurredAt    time.Time `json:"occurred_at"`
+}
+
+// ClickGoal is a number of clicks a link should reach by a deadline. Clicks by people
+// from its creation on count towards it, as in click_count.
+type ClickGoal struct {
+	ID              int64      `json:"id" db:"id"`
+	ShortCode       string     `json:"short_code" db:"short_code"`
+	TargetClicks    int64      `json:"target_clicks" db:"target_clicks"`
+	Deadline        time.Time  `json:"deadline" db:"deadline"`
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+	ReachedAt       *time.Time `json:"reached_at,omitempty" db:"reached_at"`
+	MissedAt        *time.Time `json:"missed_at,omitempty" db:"missed_at"`
+	BehindAlertedAt *time.Time `json:"behind_alerted_at,omitempty" db:"behind_alerted_at"`
+
+	// Progress, filled in when the goal is read
+	Clicks          int64  `json:"clicks" db:"-"`
+	ProjectedClicks int64  `json:"projected_clicks" db:"-"` // at the deadline, at the pace so far
+	Status          string `json:"status" db:"-"`           // on_track, behind, reached or missed
+}
+
+// ClickGoalRequest represents the request payload for setting a click goal
+type ClickGoalRequest struct {
+	TargetClicks int64     `json:"target_clicks" binding:"required"`
+	Deadline     time.Time `json:"deadline" binding:"required"`
+}
+
+// WebhookGoalEvent is the payload delivered when a link reaches its click goal or falls
+// behind the pace needed to reach it
+type WebhookGoalEvent struct {
+	Event           string    `json:"event"` // goal.reached or goal.behind
+	SchemaVersion   int       `json:"schema_version"`
+	GoalID          int64     `json:"goal_id"`
+	ShortCode       string    `json:"short_code"`
+	TargetClicks    int64     `json:"target_clicks"`
+	Clicks          int64     `json:"clicks"`
+	ProjectedClicks int64     `json:"projected_clicks"`
+	Deadline        time.Time `json:"deadline"`
+	OccurredAt      time.Time `json:"occurred_at"`
+}
+
+// Report is a generated summary of a campaign's clicks over a week or a month. The
+// rendered HTML is downloaded separately.
+type Report struct {
+	ID             int64     `json:"id" db:"id"`
+	Campaign       string    `json:"campaign" db:"campaign"`
+	Period         string    `json:"period" db:"period"` // weekly or monthly
+	PeriodStart    time.Time `json:"period_start" db:"period_start"`
+	PeriodEnd      time.Time `json:"period_end" db:"period_end"`
+	Links          int       `json:"links" db:"links"`
+	Clicks         int64     `json:"clicks" db:"clicks"`
+	UniqueVisitors int64     `json:"unique_visitors" db:"unique_visitors"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+	HTML           string    `json:"-" db:"html"`
+}
+
+// ReportRequest asks for a campaign report to be generated now
+type ReportRequest struct {
+	Campaign string `json:"campaign" binding:"required"`
+	Period   string `json:"period" binding:"required"`
+	// Start is the first day of the period; the last complete period when omitted
+	Start *time.Time `json:"start,omitempty"`
+}
+
+// CampaignLink is a link tagged with a campaign's utm_campaign
+type CampaignLink struct {
 	ShortCode   string `json:"short_code"`
-	ShortURL    string `json:"short_url"`
 	OriginalURL string `json:"original_url"`
 }
+
+// WebhookReportEvent is the payload delivered to instance-wide webhook subscriptions
+// when a scheduled campaign report is ready
+type WebhookReportEvent struct {
+	Event         string    `json:"event"` // report.ready
+	SchemaVersion int       `json:"schema_version"`
+	ReportID      int64     `json:"report_id"`
+	Campaign      string    `json:"campaign"`
+	Period        string    `json:"period"`
+	PeriodStart   time.Time `json:"period_start"`
+	PeriodEnd     time.Time `json:"period_end"`
+	Clicks        int64     `json:"clicks"`
+	OccurredAt    time.Time `json:"occurred_at"`
+}
+
+// WebhookLoginLockoutEvent is the payload delivered to instance-wide webhook subscriptions
+// when failed attempts lock out a bearer token credential
+type WebhookLoginLockoutEvent struct {
+	Event         string    `json:"event"` // login.locked
+	SchemaVersion int       `json:"schema_version"`
+	Credential    string    `json:"credential"`           // admin or quick
+	Scope         string    `json:"scope"`                // ip
+	IPAddress     string    `json:"ip_address,omitempty"` // the locked out client
+	Failures      int64     `json:"failures"`
+	LockedUntil   time.Time `json:"locked_until"`
+	OccurredAt    time.Time `json:"occurred_at"`
+}
+
+// WebhookLoginFailuresEvent is the payload delivered to instance-wide webhook subscriptions
+// when the failed attempts of all clients on a bearer token credential reach the alert limit
+type WebhookLoginFailuresEvent struct {
+	Event         string    `json:"event"` // login.failures
+	SchemaVersion int       `json:"schema_version"`
+	Credential    string    `json:"credential"` // admin or quick
+	Failures      int64     `json:"failures"`
+	WindowSeconds int64     `json:"window_seconds"`
+	OccurredAt    time.Time `json:"occurred_at"`
+}
+
+// EventDestination is a product analytics service, Segment or Amplitude, that click events
+// are forwarded to. The API key is never returned, only its last characters.
+type EventDestination struct {
+	Provider        string     `json:"provider"`
+	APIKey          string     `json:"-"`
+	KeyHint         string     `json:"key_hint"`
+	Enabled         bool       `json:"enabled"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	LastDeliveredAt *time.Time `json:"last_delivered_at,omitempty"`
+	LastError       string     `json:"last_error,omitempty"`
+	LastErrorAt     *time.Time `json:"last_error_at,omitempty"`
+}
+
+// EventDestinationRequest sets up or updates an event destination. APIKey may be left out
+// to keep the current key; Enabled defaults to true.
+type EventDestinationRequest struct {
+	APIKey  string `json:"api_key,omitempty"`
+	Enabled *bool  `json:"enabled,omitempty"`
+}
+
+// SLIStatus represents the state of one service level indicator against its objective
+type SLIStatus struct {
+	Name                 string             `json:"name"`
+	Objective            float64            `json:"objective"`
+	Threshold            string             `json:"threshold,omitempty"`
+	Compliance           float64            `json:"compliance"`
+	ErrorBudgetRemaining float64            `json:"error_budget_remaining"`
+	BurnRates            map[string]float64 `json:"burn_rates"`
+	Alert                string             `json:"alert"`
+}
+
+// SLOStatus represents redirect SLO compliance over the SLO window
+type SLOStatus struct {
+	Window    string      `json:"window"`
+	Redirects int64       `json:"redirects"`
+	SLIs      []SLIStatus `json:"slis"`
+}
+
+// Alert is a firing instance health alert
+type Alert struct {
+	Name      string            `json:"name"`
+	Severity  string            `json:"severity"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Summary   string            `json:"summary"`
+	Value     float64           `json:"value"`
+	Threshold float64           `json:"threshold"`
+	StartsAt  time.Time         `json:"starts_at"`
+}
+
+// AlertRule describes a built-in alert rule and the threshold it fires at
+type AlertRule struct {
+	Name      string  `json:"name"`
+	Severity  string  `json:"severity"`
+	Threshold float64 `json:"threshold"`
+	Enabled   bool    `json:"enabled"`
+}
+
+// AlertStatus reports the alert rules of an instance and the alerts firing on it
+type AlertStatus struct {
+	Instance    string      `json:"instance"`
+	EvaluatedAt *time.Time  `json:"evaluated_at,omitempty"`
+	Rules       []AlertRule `json:"rules"`
+	Firing      []Alert     `json:"firing"`
+}
+
+// HealthCheckResult represents the outcome of one registered health check
+type HealthCheckResult struct {
+	Name        string  `json:"-"`
+	Criticality string  `json:"criticality"`
+	Status      string  `json:"status"`
+	Error       string  `json:"error,omitempty"`
+	LatencyMs   float64 `json:"latency_ms"`
+}
+
+// HealthReport represents the aggregated health of the service
+type HealthReport struct {
+	Status string                       `json:"status"`
+	Checks map[string]HealthCheckResult `json:"checks"`
+}
+
+// UpdateStatus reports the latest published release and whether it is newer than the
+// running version
+type UpdateStatus struct {
+	Enabled         bool       `json:"enabled"`
+	LatestVersion   string     `json:"latest_version,omitempty"`
+	UpdateAvailable bool       `json:"update_available"`
+	ReleaseURL      string     `json:"release_url,omitempty"`
+	CheckedAt       *time.Time `json:"checked_at,omitempty"`
+	LastError       string     `json:"last_error,omitempty"`
+}
+
+// TelemetryReport is the anonymous usage heartbeat; usage counts cover the last 7 days
+// and are rounded to an order of magnitude
+type TelemetryReport struct {
+	InstanceID   string   `json:"instance_id"`
+	Version      string   `json:"version"`
+	GoVersion    string   `json:"go_version"`
+	OS           string   `json:"os"`
+	Arch         string   `json:"arch"`
+	Features     []string `json:"features"`
+	LinksCreated string   `json:"links_created"`
+	Redirects    string   `json:"redirects"`
+}
+
+// CohortMetrics represents request outcomes of one canary release cohort
+type CohortMetrics struct {
+	Requests     int64   `json:"requests"`
+	ServerErrors int64   `json:"server_errors"`
+	ErrorRate    float64 `json:"error_rate"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+	MaxLatencyMs float64 `json:"max_latency_ms"`
+}
+
+// AliasClaim records an attempt to claim a custom alias, kept so admins can spot
+// mass-registration of brandable aliases
+type AliasClaim struct {
+	ID        int64     `json:"id" db:"id"`
+	Actor     string    `json:"actor" db:"actor"` // "ip:<address>" or "key:<signing key id>"
+	Alias     string    `json:"alias" db:"alias"`
+	ShortCode string    `json:"short_code,omitempty" db:"short_code"` // the link the alias points to, once claimed
+	Outcome   string    `json:"outcome" db:"outcome"`                 // claimed, limited or cooldown
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// Domain is a custom domain serving its own namespace of short codes, registered by the
+// signing key that owns it
+type Domain struct {
+	ID        int64     `json:"id" db:"id"`
+	Domain    string    `json:"domain" db:"domain"`
+	Owner     string    `json:"-" db:"owner"` // "key:<signing key id>"
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// DomainRequest represents the request payload for registering a custom domain
+type DomainRequest struct {
+	Domain string `json:"domain" binding:"required"`
+}
@@ -0,0 +1,73 @@
+// Package mtls builds TLS configurations that authenticate clients by certificate, for
+// listeners only reachable by other services.
+package mtls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ServerConfig returns a TLS configuration that requires a client certificate signed by
+// a CA in caFile. With allowedSANs set, the certificate must also carry one of them as a
+// DNS name, URI (such as a SPIFFE id), email address or IP address.
+func ServerConfig(certFile, keyFile, caFile string, allowedSANs []string) (*tls.Config, error) {
+	if certFile == "" || keyFile == "" || caFile == "" {
+		return nil, fmt.Errorf("a server certificate, key and client CA bundle are required")
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load server certificate: %w", err)
+	}
+
+	caPEM, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CA bundle: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("client CA bundle contains no certificates")
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+		MinVersion:   tls.VersionTLS12,
+	}
+	if len(allowedSANs) > 0 {
+		config.VerifyConnection = func(state tls.ConnectionState) error {
+			// The chain is already verified; only the leaf identifies the client
+			if len(state.PeerCertificates) == 0 || !HasAllowedSAN(state.PeerCertificates[0], allowedSANs) {
+				return fmt.Errorf("client certificate is not allowed")
+			}
+			return nil
+		}
+	}
+	return config, nil
+}
+
+// HasAllowedSAN reports whether the certificate carries one of the allowed subject alternative names
+func HasAllowedSAN(cert *x509.Certificate, allowedSANs []string) bool {
+	var names []string
+	names = append(names, cert.DNSNames...)
+	names = append(names, cert.EmailAddresses...)
+	for _, uri := range cert.URIs {
+		names = append(names, uri.String())
+	}
+	for _, ip := range cert.IPAddresses {
+		names = append(names, ip.String())
+	}
+
+	for _, name := range names {
+		for _, allowed := range allowedSANs {
+			if strings.EqualFold(name, allowed) {
+				return true
+			}
+		}
+	}
+	return false
+}
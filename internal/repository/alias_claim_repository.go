@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/alexnthnz/url-shortener/internal/models"
+)
+
+// AliasClaimRepository stores the log of custom alias claims
+type AliasClaimRepository struct {
+	db *sql.DB
+}
+
+func NewAliasClaimRepository(db *sql.DB) *AliasClaimRepository {
+	return &AliasClaimRepository{db: db}
+}
+
+// Create appends a claim to the log
+func (r *AliasClaimRepository) Create(claim *models.AliasClaim) error {
+	query := `
+		INSERT INTO alias_claims (actor, alias, short_code, outcome)
+		VALUES ($1, $2, NULLIF($3, ''), $4)
+		RETURNING id, created_at`
+
+	return r.db.QueryRow(query, claim.Actor, claim.Alias, claim.ShortCode, claim.Outcome).Scan(&claim.ID, &claim.CreatedAt)
+}
+
+// List returns up to limit claims made since the given time, newest first, optionally
+// only those of one actor or with one outcome
+func (r *AliasClaimRepository) List(actor, outcome string, since time.Time, limit int) ([]*models.AliasClaim, error) {
+	query := `
+		SELECT id, actor, alias, COALESCE(short_code, ''), outcome, created_at
+		FROM alias_claims
+		WHERE created_at >= $1 AND ($2 = '' OR actor = $2) AND ($3 = '' OR outcome = $3)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $4`
+
+	rows, err := r.db.Query(query, since, actor, outcome, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var claims []*models.AliasClaim
+	for rows.Next() {
+		claim := &models.AliasClaim{}
+		if err := rows.Scan(&claim.ID, &claim.Actor, &claim.Alias, &claim.ShortCode, &claim.Outcome, &claim.CreatedAt); err != nil {
+			return nil, err
+		}
+		claims = append(claims, claim)
+	}
+	return claims, rows.Err()
+}
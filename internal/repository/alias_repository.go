@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/alexnthnz/url-shortener/internal/models"
+)
+
+type AliasRepository struct {
+	db           *sql.DB
+	queryTimeout time.Duration // 0 leaves queries to the caller's context
+}
+
+func NewAliasRepository(db *sql.DB, queryTimeout time.Duration) *AliasRepository {
+	return &AliasRepository{db: db, queryTimeout: queryTimeout}
+}
+
+// Create attaches an additional alias to an existing short code
+func (r *AliasRepository) Create(alias *models.Alias) error {
+	query := `
+		INSERT INTO aliases (alias, short_code)
+		VALUES ($1, $2)
+		RETURNING created_at`
+
+	return mapDuplicate(r.db.QueryRow(query, alias.Alias, alias.ShortCode).Scan(&alias.CreatedAt))
+}
+
+// GetShortCode returns the canonical short code an alias points to, empty when the alias does not exist
+func (r *AliasRepository) GetShortCode(ctx context.Context, alias string) (string, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var shortCode string
+	err := r.db.QueryRowContext(ctx, `SELECT short_code FROM aliases WHERE alias = $1`, alias).Scan(&shortCode)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return shortCode, err
+}
+
+// ListByShortCode returns the aliases of a short code, oldest first
+func (r *AliasRepository) ListByShortCode(shortCode string) ([]*models.Alias, error) {
+	query := `
+		SELECT alias, short_code, created_at
+		FROM aliases
+		WHERE short_code = $1
+		ORDER BY created_at, alias`
+
+	rows, err := r.db.Query(query, shortCode)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var aliases []*models.Alias
+	for rows.Next() {
+		alias := &models.Alias{}
+		if err := rows.Scan(&alias.Alias, &alias.ShortCode, &alias.CreatedAt); err != nil {
+			return nil, err
+		}
+		aliases = append(aliases, alias)
+	}
+
+	return aliases, rows.Err()
+}
+
+// Delete detaches an alias from a short code, reporting whether it existed
+func (r *AliasRepository) Delete(shortCode, alias string) (bool, error) {
+	result, err := r.db.Exec(`DELETE FROM aliases WHERE short_code = $1 AND alias = $2`, shortCode, alias)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
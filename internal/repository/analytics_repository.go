@@ -1,38 +1,751 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
+	"net"
+	"strings"
+	"time"
 
+	apperrors "github.com/alexnthnz/url-shortener/internal/errors"
 	"github.com/alexnthnz/url-shortener/internal/models"
+	"github.com/lib/pq"
 )
 
 type AnalyticsRepository struct {
-	db *sql.DB
+	db           *sql.DB
+	cipher       *PIICipher    // optional, IP addresses and user agents are stored in plaintext without it
+	queryTimeout time.Duration // 0 leaves queries to the caller's context
 }
 
-func NewAnalyticsRepository(db *sql.DB) *AnalyticsRepository {
-	return &AnalyticsRepository{db: db}
+func NewAnalyticsRepository(db *sql.DB, cipher *PIICipher, queryTimeout time.Duration) *AnalyticsRepository {
+	return &AnalyticsRepository{db: db, cipher: cipher, queryTimeout: queryTimeout}
+}
+
+// piiColumns lists the personal data columns in the order sealPII returns their values
+const piiColumns = "ip_address, user_agent, ip_address_enc, user_agent_enc, ip_address_hmac, pii_key_id"
+
+// clickColumns selects a full click event as read by scanClicks
+const clickColumns = `id, COALESCE(click_id, ''), COALESCE(visitor_id, ''), short_code, clicked_at,
+	COALESCE(host(ip_address), ''), COALESCE(user_agent, ''), ip_address_enc, user_agent_enc, COALESCE(referrer, ''),
+	COALESCE(device_type, ''), COALESCE(browser, ''), COALESCE(os, ''), is_bot, is_internal, via_qr, redirect_rule`
+
+// sealPII returns the values of piiColumns for an IP address and user agent. With a cipher
+// the plaintext columns stay NULL and the IP address gets a blind index for lookups.
+func (r *AnalyticsRepository) sealPII(ipAddress, userAgent string) ([]interface{}, error) {
+	values := []interface{}{nil, nil, nil, nil, nil, nil}
+	if r.cipher == nil {
+		if ipAddress != "" {
+			values[0] = ipAddress
+		}
+		if userAgent != "" {
+			values[1] = userAgent
+		}
+		return values, nil
+	}
+
+	if ipAddress != "" {
+		ipAddress = normalizeIP(ipAddress)
+		sealed, err := r.cipher.Encrypt(ipAddress)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt IP address: %w", err)
+		}
+		values[2] = sealed
+		values[4] = r.cipher.BlindIndex(ipAddress)
+	}
+	if userAgent != "" {
+		sealed, err := r.cipher.Encrypt(userAgent)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt user agent: %w", err)
+		}
+		values[3] = sealed
+	}
+	if values[2] != nil || values[3] != nil {
+		values[5] = r.cipher.ActiveKeyID()
+	}
+	return values, nil
+}
+
+// ipBlindIndex returns the blind index to look an IP address up by, nil without a cipher
+func (r *AnalyticsRepository) ipBlindIndex(ipAddress string) interface{} {
+	if r.cipher == nil || ipAddress == "" {
+		return nil
+	}
+	return r.cipher.BlindIndex(normalizeIP(ipAddress))
+}
+
+// normalizeIP gives every spelling of an address the same blind index
+func normalizeIP(ipAddress string) string {
+	if ip := net.ParseIP(ipAddress); ip != nil {
+		return ip.String()
+	}
+	return ipAddress
 }
 
 // RecordClick stores a click event for analytics
-func (r *AnalyticsRepository) RecordClick(analytics *models.Analytics) error {
+func (r *AnalyticsRepository) RecordClick(ctx context.Context, analytics *models.Analytics) error {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	pii, err := r.sealPII(analytics.IPAddress, analytics.UserAgent)
+	if err != nil {
+		return err
+	}
+
 	query := `
-		INSERT INTO analytics (short_code, ip_address, user_agent)
-		VALUES ($1, $2, $3)
+		INSERT INTO analytics (short_code, click_id, visitor_id, referrer, device_type, browser, os, is_bot, is_internal, via_qr, redirect_rule, ` + piiColumns + `)
+		VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), NULLIF($4, ''), NULLIF($5, ''), NULLIF($6, ''), NULLIF($7, ''), $8, $9, $10, $11,
+			$12, $13, $14, $15, $16, $17)
 		RETURNING id, clicked_at`
 
-	return r.db.QueryRow(
-		query,
-		analytics.ShortCode,
-		analytics.IPAddress,
-		analytics.UserAgent,
-	).Scan(&analytics.ID, &analytics.ClickedAt)
+	args := append([]interface{}{analytics.ShortCode, analytics.ClickID, analytics.VisitorID, analytics.Referrer,
+		analytics.DeviceType, analytics.Browser, analytics.OS, analytics.IsBot, analytics.IsInternal, analytics.ViaQR, analytics.RedirectRule}, pii...)
+	return r.db.QueryRowContext(ctx, query, args...).Scan(&analytics.ID, &analytics.ClickedAt)
+}
+
+// GetVisitorClicks returns up to limit clicks of one attributed visitor, oldest first
+func (r *AnalyticsRepository) GetVisitorClicks(ctx context.Context, visitorID string, limit int) ([]models.Touchpoint, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT short_code, COALESCE(click_id, ''), clicked_at
+		FROM analytics
+		WHERE visitor_id = $1
+		ORDER BY clicked_at, id
+		LIMIT $2`
+
+	rows, err := r.db.QueryContext(ctx, query, visitorID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var touchpoints []models.Touchpoint
+	for rows.Next() {
+		var touchpoint models.Touchpoint
+		if err := rows.Scan(&touchpoint.ShortCode, &touchpoint.ClickID, &touchpoint.ClickedAt); err != nil {
+			return nil, err
+		}
+		touchpoints = append(touchpoints, touchpoint)
+	}
+
+	return touchpoints, rows.Err()
 }
 
-// GetClickCount returns the total click count for a short code
-func (r *AnalyticsRepository) GetClickCount(shortCode string) (int64, error) {
+// getClickCountQuery counts the clicks of a short code by people outside internal networks
+const getClickCountQuery = `SELECT COUNT(*) FROM analytics WHERE short_code = $1 AND NOT is_bot AND NOT is_internal`
+
+// GetClickCount returns the click count of a short code, leaving out bots and internal clicks
+func (r *AnalyticsRepository) GetClickCount(ctx context.Context, shortCode string) (int64, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var count int64
+	err := r.db.QueryRowContext(ctx, getClickCountQuery, shortCode).Scan(&count)
+	return count, err
+}
+
+// GetClickCountBetween returns the clicks of a short code within [from, to) that
+// click_count includes
+func (r *AnalyticsRepository) GetClickCountBetween(ctx context.Context, shortCode string, from, to time.Time) (int64, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT COUNT(*) FROM analytics
+		WHERE short_code = $1 AND clicked_at >= $2 AND clicked_at < $3 AND NOT is_bot AND NOT is_internal`
+
 	var count int64
-	query := `SELECT COUNT(*) FROM analytics WHERE short_code = $1`
-	err := r.db.QueryRow(query, shortCode).Scan(&count)
+	err := r.db.QueryRowContext(ctx, query, shortCode, from, to).Scan(&count)
 	return count, err
 }
+
+// getDailyClicksQuery is the per-link click time series, without bots and internal clicks
+const getDailyClicksQuery = `
+	SELECT date_trunc('day', clicked_at) AS day, COUNT(*)
+	FROM analytics
+	WHERE short_code = $1 AND clicked_at >= $2 AND NOT is_bot AND NOT is_internal
+	GROUP BY day
+	ORDER BY day`
+
+// GetDailyClicks returns click counts per day for a short code since the given time
+func (r *AnalyticsRepository) GetDailyClicks(ctx context.Context, shortCode string, since time.Time) ([]models.DailyClicks, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, getDailyClicksQuery, shortCode, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var days []models.DailyClicks
+	for rows.Next() {
+		var day models.DailyClicks
+		if err := rows.Scan(&day.Day, &day.Clicks); err != nil {
+			return nil, err
+		}
+		days = append(days, day)
+	}
+
+	return days, rows.Err()
+}
+
+// getClickBucketsQuery is the per-link click time series at a given date_trunc precision,
+// without bots and, unless $5, without internal clicks
+const getClickBucketsQuery = `
+	SELECT date_trunc($2, clicked_at) AS bucket, COUNT(*)
+	FROM analytics
+	WHERE short_code = $1 AND clicked_at >= $3 AND clicked_at < $4 AND NOT is_bot AND (NOT is_internal OR $5)
+	GROUP BY bucket
+	ORDER BY bucket`
+
+// GetClickBuckets returns the clicks of a short code within [from, to) per hour, day or
+// week, omitting buckets without clicks
+func (r *AnalyticsRepository) GetClickBuckets(ctx context.Context, shortCode, precision string, from, to time.Time, includeInternal bool) ([]models.ClickBucket, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, getClickBucketsQuery, shortCode, precision, from, to, includeInternal)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var buckets []models.ClickBucket
+	for rows.Next() {
+		var bucket models.ClickBucket
+		if err := rows.Scan(&bucket.Start, &bucket.Clicks); err != nil {
+			return nil, err
+		}
+		buckets = append(buckets, bucket)
+	}
+
+	return buckets, rows.Err()
+}
+
+// getClickSummaryQuery counts the clicks by people, bots and internal networks, the human
+// QR code scans and the unique human visitors of a link. Internal clicks count as clicks
+// by people only with $4. Visitors are told apart by their attribution id, else by IP
+// address.
+const getClickSummaryQuery = clickSummaryColumns + `
+	FROM analytics
+	WHERE short_code = $1 AND clicked_at >= $2 AND clicked_at < $3`
+
+// getLinksClickSummaryQuery is getClickSummaryQuery over an array of short codes
+const getLinksClickSummaryQuery = clickSummaryColumns + `
+	FROM analytics
+	WHERE short_code = ANY($1) AND clicked_at >= $2 AND clicked_at < $3`
+
+const clickSummaryColumns = `
+	SELECT COUNT(*) FILTER (WHERE NOT is_bot AND (NOT is_internal OR $4)),
+		COUNT(*) FILTER (WHERE is_bot),
+		COUNT(*) FILTER (WHERE is_internal AND NOT is_bot),
+		COUNT(*) FILTER (WHERE via_qr AND NOT is_bot AND (NOT is_internal OR $4)),
+		COUNT(DISTINCT COALESCE(visitor_id, encode(ip_address_hmac, 'hex'), host(ip_address))) FILTER (WHERE NOT is_bot AND (NOT is_internal OR $4))`
+
+// GetClickSummary counts the clicks of a short code within [from, to)
+func (r *AnalyticsRepository) GetClickSummary(ctx context.Context, shortCode string, from, to time.Time, includeInternal bool) (models.ClickSummary, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var summary models.ClickSummary
+	err := r.db.QueryRowContext(ctx, getClickSummaryQuery, shortCode, from, to, includeInternal).Scan(
+		&summary.Clicks, &summary.BotClicks, &summary.InternalClicks, &summary.QRScans, &summary.Visitors)
+	return summary, err
+}
+
+// GetVariantCounts counts the human clicks and visitors of a short code within [from, to)
+// per redirect rule that picked the destination, the link's own destination first
+func (r *AnalyticsRepository) GetVariantCounts(ctx context.Context, shortCode string, from, to time.Time, includeInternal bool) ([]models.VariantStats, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT redirect_rule, COUNT(*),
+			COUNT(DISTINCT COALESCE(visitor_id, encode(ip_address_hmac, 'hex'), host(ip_address)))
+		FROM analytics
+		WHERE short_code = $1 AND clicked_at >= $2 AND clicked_at < $3 AND NOT is_bot AND (NOT is_internal OR $4)
+		GROUP BY redirect_rule
+		ORDER BY redirect_rule NULLS FIRST`
+
+	rows, err := r.db.QueryContext(ctx, query, shortCode, from, to, includeInternal)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var variants []models.VariantStats
+	for rows.Next() {
+		var variant models.VariantStats
+		if err := rows.Scan(&variant.Rule, &variant.Clicks, &variant.UniqueVisitors); err != nil {
+			return nil, err
+		}
+		variants = append(variants, variant)
+	}
+	return variants, rows.Err()
+}
+
+// GetLinksClickSummary counts the clicks of several short codes within [from, to)
+// together, so visitors of more than one of them count once. Internal clicks are left out.
+func (r *AnalyticsRepository) GetLinksClickSummary(ctx context.Context, shortCodes []string, from, to time.Time) (models.ClickSummary, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var summary models.ClickSummary
+	err := r.db.QueryRowContext(ctx, getLinksClickSummaryQuery, pq.Array(shortCodes), from, to, false).Scan(
+		&summary.Clicks, &summary.BotClicks, &summary.InternalClicks, &summary.QRScans, &summary.Visitors)
+	return summary, err
+}
+
+// GetLinksClickBuckets returns the human clicks of several short codes within [from, to)
+// together per hour, day or week, omitting buckets without clicks. Internal clicks are
+// left out.
+func (r *AnalyticsRepository) GetLinksClickBuckets(ctx context.Context, shortCodes []string, precision string, from, to time.Time) ([]models.ClickBucket, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT date_trunc($2, clicked_at) AS bucket, COUNT(*)
+		FROM analytics
+		WHERE short_code = ANY($1) AND clicked_at >= $3 AND clicked_at < $4 AND NOT is_bot AND NOT is_internal
+		GROUP BY bucket
+		ORDER BY bucket`
+
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(shortCodes), precision, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var buckets []models.ClickBucket
+	for rows.Next() {
+		var bucket models.ClickBucket
+		if err := rows.Scan(&bucket.Start, &bucket.Clicks); err != nil {
+			return nil, err
+		}
+		buckets = append(buckets, bucket)
+	}
+	return buckets, rows.Err()
+}
+
+// GetUserAgentCounts returns the human clicks of a short code within [from, to) per user agent.
+// Encrypted user agents cannot be grouped by the database, so they are decrypted and
+// counted here; clicks without a user agent are left out.
+func (r *AnalyticsRepository) GetUserAgentCounts(ctx context.Context, shortCode string, from, to time.Time, includeInternal bool) (map[string]int64, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT COALESCE(user_agent, ''), user_agent_enc, COUNT(*)
+		FROM analytics
+		WHERE short_code = $1 AND clicked_at >= $2 AND clicked_at < $3 AND NOT is_bot AND (NOT is_internal OR $4)
+			AND (user_agent IS NOT NULL OR user_agent_enc IS NOT NULL)
+		GROUP BY user_agent, user_agent_enc`
+
+	rows, err := r.db.QueryContext(ctx, query, shortCode, from, to, includeInternal)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int64)
+	for rows.Next() {
+		var userAgent string
+		var userAgentEnc []byte
+		var count int64
+		if err := rows.Scan(&userAgent, &userAgentEnc, &count); err != nil {
+			return nil, err
+		}
+		if userAgentEnc != nil {
+			if r.cipher == nil {
+				return nil, fmt.Errorf("personal data is encrypted but no PII encryption keys are configured")
+			}
+			if userAgent, err = r.cipher.Decrypt(userAgentEnc); err != nil {
+				return nil, fmt.Errorf("failed to decrypt user agent: %w", err)
+			}
+		}
+		counts[userAgent] += count
+	}
+
+	return counts, rows.Err()
+}
+
+// getReferrerCountsQuery counts the human clicks of a short code per referring host
+const getReferrerCountsQuery = `
+	SELECT lower(substring(referrer from '^[a-z][a-z0-9+.-]*://([^/:]+)')) AS host, COUNT(*)
+	FROM analytics
+	WHERE short_code = $1 AND clicked_at >= $2 AND clicked_at < $3 AND referrer IS NOT NULL AND NOT is_bot
+		AND (NOT is_internal OR $4)
+	GROUP BY host`
+
+// GetReferrerCounts returns the clicks of a short code within [from, to) per referring
+// host; clicks without a Referer header are left out
+func (r *AnalyticsRepository) GetReferrerCounts(ctx context.Context, shortCode string, from, to time.Time, includeInternal bool) (map[string]int64, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, getReferrerCountsQuery, shortCode, from, to, includeInternal)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int64)
+	for rows.Next() {
+		var host sql.NullString
+		var count int64
+		if err := rows.Scan(&host, &count); err != nil {
+			return nil, err
+		}
+		if host.Valid && host.String != "" {
+			counts[host.String] += count
+		}
+	}
+
+	return counts, rows.Err()
+}
+
+// getClientCountsQuery counts the human clicks of a short code per device type, browser
+// and operating system. Clicks recorded before user agents were parsed have none.
+const getClientCountsQuery = `
+	SELECT device_type, browser, os, COUNT(*)
+	FROM analytics
+	WHERE short_code = $1 AND clicked_at >= $2 AND clicked_at < $3 AND device_type IS NOT NULL AND NOT is_bot
+		AND (NOT is_internal OR $4)
+	GROUP BY device_type, browser, os`
+
+// GetClientCounts returns the clicks of a short code within [from, to) per device type,
+// per browser and per operating system
+func (r *AnalyticsRepository) GetClientCounts(ctx context.Context, shortCode string, from, to time.Time, includeInternal bool) (devices, browsers, systems map[string]int64, err error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, getClientCountsQuery, shortCode, from, to, includeInternal)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer rows.Close()
+
+	devices, browsers, systems = make(map[string]int64), make(map[string]int64), make(map[string]int64)
+	for rows.Next() {
+		var device string
+		var browser, system sql.NullString
+		var count int64
+		if err := rows.Scan(&device, &browser, &system, &count); err != nil {
+			return nil, nil, nil, err
+		}
+		devices[device] += count
+		browsers[browser.String] += count
+		systems[system.String] += count
+	}
+
+	return devices, browsers, systems, rows.Err()
+}
+
+// getDailyRedirectsQuery is the instance-wide redirect time series
+const getDailyRedirectsQuery = `
+	SELECT date_trunc('day', clicked_at) AS day, COUNT(*)
+	FROM analytics
+	WHERE clicked_at >= $1
+	GROUP BY day
+	ORDER BY day`
+
+// GetDailyRedirects returns the number of redirects per day across all links since the given time
+func (r *AnalyticsRepository) GetDailyRedirects(ctx context.Context, since time.Time) ([]models.DailyCount, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	return queryDailyCounts(ctx, r.db, getDailyRedirectsQuery, since)
+}
+
+// DeleteOlderThan removes at most limit click events recorded before the cutoff
+// and returns how many rows were deleted. Callers loop until it returns 0.
+func (r *AnalyticsRepository) DeleteOlderThan(ctx context.Context, cutoff time.Time, limit int) (int64, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		DELETE FROM analytics
+		WHERE id IN (
+			SELECT id FROM analytics
+			WHERE clicked_at < $1
+			LIMIT $2
+		)`
+
+	result, err := r.db.ExecContext(ctx, query, cutoff, limit)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// MaxClickID returns the id of the newest click event, 0 when there are none
+func (r *AnalyticsRepository) MaxClickID(ctx context.Context) (int64, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var id int64
+	err := r.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(id), 0) FROM analytics`).Scan(&id)
+	return id, err
+}
+
+// ListClicksAfter returns up to limit click events with afterID < id <= maxID, oldest first
+func (r *AnalyticsRepository) ListClicksAfter(ctx context.Context, afterID, maxID int64, limit int) ([]*models.Analytics, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT ` + clickColumns + `
+		FROM analytics
+		WHERE id > $1 AND id <= $2
+		ORDER BY id
+		LIMIT $3`
+
+	rows, err := r.db.QueryContext(ctx, query, afterID, maxID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return r.scanClicks(rows)
+}
+
+// ListBySubject returns up to limit click events with an id above afterID that reference a
+// data subject, by IP address or attribution visitor id, oldest first. Encrypted IP
+// addresses are matched through their blind index.
+func (r *AnalyticsRepository) ListBySubject(ctx context.Context, subject models.DataSubject, afterID int64, limit int) ([]*models.Analytics, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT ` + clickColumns + `
+		FROM analytics
+		WHERE ((ip_address = NULLIF($1, '')::inet) OR (ip_address_hmac = $2) OR (visitor_id = NULLIF($3, '')))
+			AND id > $4
+		ORDER BY id
+		LIMIT $5`
+
+	rows, err := r.db.QueryContext(ctx, query, subject.IPAddress, r.ipBlindIndex(subject.IPAddress), subject.VisitorID, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return r.scanClicks(rows)
+}
+
+// AnonymizeByIDs clears the personal data of the given click events while keeping the
+// clicks themselves, so link statistics stay correct after an erasure request
+func (r *AnalyticsRepository) AnonymizeByIDs(ctx context.Context, ids []int64) (int64, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	query := `
+		UPDATE analytics
+		SET ip_address = NULL, user_agent = NULL, visitor_id = NULL, click_id = NULL, referrer = NULL,
+			ip_address_enc = NULL, user_agent_enc = NULL, ip_address_hmac = NULL, pii_key_id = NULL
+		WHERE id = ANY($1)`
+
+	result, err := r.db.ExecContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// scanClicks reads rows selected with clickColumns, decrypting personal data
+func (r *AnalyticsRepository) scanClicks(rows *sql.Rows) ([]*models.Analytics, error) {
+	defer rows.Close()
+
+	var clicks []*models.Analytics
+	for rows.Next() {
+		click := &models.Analytics{}
+		var ipEnc, userAgentEnc []byte
+		if err := rows.Scan(
+			&click.ID,
+			&click.ClickID,
+			&click.VisitorID,
+			&click.ShortCode,
+			&click.ClickedAt,
+			&click.IPAddress,
+			&click.UserAgent,
+			&ipEnc,
+			&userAgentEnc,
+			&click.Referrer,
+			&click.DeviceType,
+			&click.Browser,
+			&click.OS,
+			&click.IsBot,
+			&click.IsInternal,
+			&click.ViaQR,
+			&click.RedirectRule,
+		); err != nil {
+			return nil, err
+		}
+		if err := r.openPII(click, ipEnc, userAgentEnc); err != nil {
+			return nil, fmt.Errorf("click %d: %w", click.ID, err)
+		}
+		clicks = append(clicks, click)
+	}
+
+	return clicks, rows.Err()
+}
+
+// openPII fills in the encrypted IP address and user agent of a click, if any
+func (r *AnalyticsRepository) openPII(click *models.Analytics, ipEnc, userAgentEnc []byte) error {
+	if ipEnc == nil && userAgentEnc == nil {
+		return nil
+	}
+	if r.cipher == nil {
+		return fmt.Errorf("personal data is encrypted but no PII encryption keys are configured")
+	}
+
+	var err error
+	if ipEnc != nil {
+		if click.IPAddress, err = r.cipher.Decrypt(ipEnc); err != nil {
+			return fmt.Errorf("failed to decrypt IP address: %w", err)
+		}
+	}
+	if userAgentEnc != nil {
+		if click.UserAgent, err = r.cipher.Decrypt(userAgentEnc); err != nil {
+			return fmt.Errorf("failed to decrypt user agent: %w", err)
+		}
+	}
+	return nil
+}
+
+// ReencryptBatch seals up to limit click events with an id above afterID that are stored
+// in plaintext or under a data key other than the active one. It returns the last id it
+// handled, 0 when nothing is left, and how many events it re-encrypted.
+func (r *AnalyticsRepository) ReencryptBatch(ctx context.Context, afterID int64, limit int) (int64, int64, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	if r.cipher == nil {
+		return 0, 0, apperrors.Errorf(apperrors.ErrNotConfigured, "PII encryption is not configured")
+	}
+
+	query := `
+		SELECT ` + clickColumns + `
+		FROM analytics
+		WHERE id > $1
+			AND (ip_address IS NOT NULL OR user_agent IS NOT NULL OR pii_key_id <> $2)
+		ORDER BY id
+		LIMIT $3`
+
+	rows, err := r.db.QueryContext(ctx, query, afterID, r.cipher.ActiveKeyID(), limit)
+	if err != nil {
+		return 0, 0, err
+	}
+	clicks, err := r.scanClicks(rows)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(clicks) == 0 {
+		return 0, 0, nil
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	update := `UPDATE analytics SET (` + piiColumns + `) = ($1, $2, $3, $4, $5, $6) WHERE id = $7`
+	for _, click := range clicks {
+		pii, err := r.sealPII(click.IPAddress, click.UserAgent)
+		if err != nil {
+			return 0, 0, err
+		}
+		if _, err := tx.ExecContext(ctx, update, append(pii, click.ID)...); err != nil {
+			return 0, 0, fmt.Errorf("failed to re-encrypt click %d: %w", click.ID, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, 0, err
+	}
+
+	return clicks[len(clicks)-1].ID, int64(len(clicks)), nil
+}
+
+// ListUnparsed returns up to limit click events with an id above afterID recorded before
+// device type, browser and OS were parsed from user agents, with their PII decrypted
+func (r *AnalyticsRepository) ListUnparsed(ctx context.Context, afterID int64, limit int) ([]*models.Analytics, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT ` + clickColumns + `
+		FROM analytics
+		WHERE id > $1 AND device_type IS NULL
+		ORDER BY id
+		LIMIT $2`
+
+	rows, err := r.db.QueryContext(ctx, query, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return r.scanClicks(rows)
+}
+
+// SetClientInfo stores the device type, browser and OS of click events
+func (r *AnalyticsRepository) SetClientInfo(ctx context.Context, clicks []*models.Analytics) error {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	update := `UPDATE analytics SET device_type = $1, browser = $2, os = $3 WHERE id = $4`
+	for _, click := range clicks {
+		if _, err := tx.ExecContext(ctx, update, click.DeviceType, click.Browser, click.OS, click.ID); err != nil {
+			return fmt.Errorf("failed to update click %d: %w", click.ID, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// CopyClicks inserts click events keeping their ids and timestamps. Events already
+// present are skipped, so a copy can be retried without double-counting.
+func (r *AnalyticsRepository) CopyClicks(ctx context.Context, clicks []*models.Analytics) (int64, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	if len(clicks) == 0 {
+		return 0, nil
+	}
+
+	const columns = 19
+	values := make([]string, 0, len(clicks))
+	args := make([]interface{}, 0, len(clicks)*columns)
+	for i, click := range clicks {
+		pii, err := r.sealPII(click.IPAddress, click.UserAgent)
+		if err != nil {
+			return 0, err
+		}
+
+		n := i * columns
+		values = append(values, fmt.Sprintf("($%d, $%d, $%d, NULLIF($%d, ''), NULLIF($%d, ''), NULLIF($%d, ''), NULLIF($%d, ''), NULLIF($%d, ''), NULLIF($%d, ''), $%d, $%d, $%d, $%d::smallint, $%d::inet, $%d, $%d, $%d, $%d, $%d::integer)",
+			n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8, n+9, n+10, n+11, n+12, n+13, n+14, n+15, n+16, n+17, n+18, n+19))
+		args = append(args, click.ID, click.ShortCode, click.ClickedAt, click.ClickID, click.VisitorID, click.Referrer,
+			click.DeviceType, click.Browser, click.OS, click.IsBot, click.IsInternal, click.ViaQR, click.RedirectRule)
+		args = append(args, pii...)
+	}
+
+	query := `
+		INSERT INTO analytics (id, short_code, clicked_at, click_id, visitor_id, referrer, device_type, browser, os, is_bot, is_internal, via_qr, redirect_rule, ` + piiColumns + `)
+		VALUES ` + strings.Join(values, ", ") + `
+		ON CONFLICT (id) DO NOTHING`
+
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -7,15 +7,46 @@ import (
 	"github.com/go-redis/redis/v8"
 )
 
+// ErrCacheMiss is returned by Get when a key does not exist
+var ErrCacheMiss = redis.Nil
+
+// Cache is the key-value store behind link lookups, counters, locks and leaderboards.
+// RedisCache shares it between instances, MemoryCache keeps it in process and
+// LayeredCache puts a MemoryCache in front of another cache for hot keys.
+type Cache interface {
+	Get(ctx context.Context, key string) (string, error)
+	Set(ctx context.Context, key, value string) error
+	SetWithTTL(ctx context.Context, key, value string, ttl time.Duration) error
+	TTL(ctx context.Context, key string) (time.Duration, error)
+	Delete(ctx context.Context, keys ...string) error
+	Close() error
+	Ping(ctx context.Context) error
+	IncrByWithTTL(ctx context.Context, key string, value int64, ttl time.Duration) error
+	MGet(ctx context.Context, keys ...string) ([]string, error)
+	SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error)
+	IncrWindow(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error)
+	IncrExisting(ctx context.Context, key string) (int64, bool, error)
+	PushCapped(ctx context.Context, key, value string, maxLen int64) error
+	ListRange(ctx context.Context, key string, count int64) ([]string, error)
+	ZIncrBatch(ctx context.Context, key string, increments map[string]float64, maxLen int64, ttl time.Duration) error
+	ZMergeInto(ctx context.Context, dst, src string, weight float64, ttl time.Duration) error
+	ZTop(ctx context.Context, key string, count int64) ([]ScoredMember, error)
+	ZRem(ctx context.Context, member string, keys ...string) error
+}
+
+// defaultCacheTTL is how long Set keeps a value
+const defaultCacheTTL = 24 * time.Hour
+
 // RedisCache implements caching functionality
 type RedisCache struct {
-	client *redis.Client
-	ctx    context.Context
-	ttl    time.Duration
+	client  *redis.Client
+	ttl     time.Duration
+	timeout time.Duration // bounds every command; 0 leaves commands to the caller's context
 }
 
-// NewRedisCache creates a new Redis cache instance
-func NewRedisCache(redisURL string) *RedisCache {
+// NewRedisCache creates a new Redis cache instance whose commands give up after timeout.
+// A non-nil fault injector delays or fails commands on purpose.
+func NewRedisCache(redisURL string, timeout time.Duration, faults *FaultInjector) *RedisCache {
 	opt, err := redis.ParseURL(redisURL)
 	if err != nil {
 		// Fallback to default configuration
@@ -25,32 +56,60 @@ func NewRedisCache(redisURL string) *RedisCache {
 	}
 
 	client := redis.NewClient(opt)
+	if faults != nil {
+		client.AddHook(redisFaultHook{faults: faults})
+	}
 
 	return &RedisCache{
-		client: client,
-		ctx:    context.Background(),
-		ttl:    24 * time.Hour, // 24 hour TTL
+		client:  client,
+		ttl:     defaultCacheTTL,
+		timeout: timeout,
 	}
 }
 
+// withTimeout bounds a command by the configured timeout as well as by the caller's context
+func (c *RedisCache) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return withQueryTimeout(ctx, c.timeout)
+}
+
 // Get retrieves a value from cache
-func (c *RedisCache) Get(key string) (string, error) {
-	return c.client.Get(c.ctx, key).Result()
+func (c *RedisCache) Get(ctx context.Context, key string) (string, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	return c.client.Get(ctx, key).Result()
 }
 
 // Set stores a value in cache with TTL
-func (c *RedisCache) Set(key, value string) error {
-	return c.client.Set(c.ctx, key, value, c.ttl).Err()
+func (c *RedisCache) Set(ctx context.Context, key, value string) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	return c.client.Set(ctx, key, value, c.ttl).Err()
 }
 
 // SetWithTTL stores a value in cache with custom TTL
-func (c *RedisCache) SetWithTTL(key, value string, ttl time.Duration) error {
-	return c.client.Set(c.ctx, key, value, ttl).Err()
+func (c *RedisCache) SetWithTTL(ctx context.Context, key, value string, ttl time.Duration) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	return c.client.Set(ctx, key, value, ttl).Err()
+}
+
+// TTL returns the time left before a key expires
+func (c *RedisCache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	return c.client.TTL(ctx, key).Result()
 }
 
-// Delete removes a value from cache
-func (c *RedisCache) Delete(key string) error {
-	return c.client.Del(c.ctx, key).Err()
+// Delete removes values from cache
+func (c *RedisCache) Delete(ctx context.Context, keys ...string) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	return c.client.Del(ctx, keys...).Err()
 }
 
 // Close closes the Redis connection
@@ -59,6 +118,182 @@ func (c *RedisCache) Close() error {
 }
 
 // Ping checks if Redis is accessible
-func (c *RedisCache) Ping() error {
-	return c.client.Ping(c.ctx).Err()
+func (c *RedisCache) Ping(ctx context.Context) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	return c.client.Ping(ctx).Err()
+}
+
+// IncrByWithTTL atomically adds to a counter and refreshes its TTL
+func (c *RedisCache) IncrByWithTTL(ctx context.Context, key string, value int64, ttl time.Duration) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	pipe := c.client.TxPipeline()
+	pipe.IncrBy(ctx, key, value)
+	pipe.Expire(ctx, key, ttl)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// MGet retrieves several values at once; missing keys yield empty strings
+func (c *RedisCache) MGet(ctx context.Context, keys ...string) ([]string, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	values, err := c.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]string, len(values))
+	for i, value := range values {
+		if s, ok := value.(string); ok {
+			result[i] = s
+		}
+	}
+	return result, nil
+}
+
+// SetNX stores a value only if the key does not exist, reporting whether it was set
+func (c *RedisCache) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	return c.client.SetNX(ctx, key, value, ttl).Result()
+}
+
+// incrWindowScript increments a counter, starting its expiry on the first increment so
+// the window is fixed, and returns the count and the milliseconds left in the window
+var incrWindowScript = redis.NewScript(`
+local count = redis.call("INCR", KEYS[1])
+local ttl = redis.call("PTTL", KEYS[1])
+if ttl < 0 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+	ttl = tonumber(ARGV[1])
+end
+return {count, ttl}
+`)
+
+// IncrWindow atomically counts one hit in a fixed window of the given length, returning
+// the count so far and the time until the window resets
+func (c *RedisCache) IncrWindow(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	result, err := incrWindowScript.Run(ctx, c.client, []string{key}, window.Milliseconds()).Int64Slice()
+	if err != nil {
+		return 0, 0, err
+	}
+	return result[0], time.Duration(result[1]) * time.Millisecond, nil
+}
+
+// incrExistingScript increments a counter only if it exists, returning -1 otherwise
+var incrExistingScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return -1
+end
+return redis.call("INCR", KEYS[1])
+`)
+
+// IncrExisting atomically increments a counter that has already been set, reporting
+// false without creating it when the key does not exist
+func (c *RedisCache) IncrExisting(ctx context.Context, key string) (int64, bool, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	count, err := incrExistingScript.Run(ctx, c.client, []string{key}).Int64()
+	if err != nil {
+		return 0, false, err
+	}
+	if count < 0 {
+		return 0, false, nil
+	}
+	return count, true, nil
+}
+
+// PushCapped prepends a value to a list, keeping only its newest maxLen entries
+func (c *RedisCache) PushCapped(ctx context.Context, key, value string, maxLen int64) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	pipe := c.client.TxPipeline()
+	pipe.LPush(ctx, key, value)
+	pipe.LTrim(ctx, key, 0, maxLen-1)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// ListRange returns up to count entries from the head of a list
+func (c *RedisCache) ListRange(ctx context.Context, key string, count int64) ([]string, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	return c.client.LRange(ctx, key, 0, count-1).Result()
+}
+
+// ScoredMember is a member of a sorted set with its score
+type ScoredMember struct {
+	Member string
+	Score  float64
+}
+
+// ZIncrBatch adds to the scores of several sorted set members at once, keeping only the
+// maxLen highest scores and refreshing the set's TTL
+func (c *RedisCache) ZIncrBatch(ctx context.Context, key string, increments map[string]float64, maxLen int64, ttl time.Duration) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	pipe := c.client.TxPipeline()
+	for member, increment := range increments {
+		pipe.ZIncrBy(ctx, key, increment, member)
+	}
+	pipe.ZRemRangeByRank(ctx, key, 0, -maxLen-1)
+	pipe.Expire(ctx, key, ttl)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// ZMergeInto adds the scores of src, multiplied by weight, to the sorted set dst
+func (c *RedisCache) ZMergeInto(ctx context.Context, dst, src string, weight float64, ttl time.Duration) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	pipe := c.client.TxPipeline()
+	pipe.ZUnionStore(ctx, dst, &redis.ZStore{Keys: []string{dst, src}, Weights: []float64{1, weight}})
+	pipe.Expire(ctx, dst, ttl)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// ZTop returns the count highest scored members of a sorted set, highest first
+func (c *RedisCache) ZTop(ctx context.Context, key string, count int64) ([]ScoredMember, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	members, err := c.client.ZRevRangeWithScores(ctx, key, 0, count-1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]ScoredMember, len(members))
+	for i, member := range members {
+		name, _ := member.Member.(string)
+		result[i] = ScoredMember{Member: name, Score: member.Score}
+	}
+	return result, nil
+}
+
+// ZRem removes a member from several sorted sets
+func (c *RedisCache) ZRem(ctx context.Context, member string, keys ...string) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	pipe := c.client.TxPipeline()
+	for _, key := range keys {
+		pipe.ZRem(ctx, key, member)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
 }
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/alexnthnz/url-shortener/internal/models"
+)
+
+// ComplianceRepository stores the append-only log of redirects to sensitive domains.
+// It only ever inserts and reads; the table rejects updates and deletes.
+type ComplianceRepository struct {
+	db *sql.DB
+}
+
+func NewComplianceRepository(db *sql.DB) *ComplianceRepository {
+	return &ComplianceRepository{db: db}
+}
+
+// Create appends a redirect record
+func (r *ComplianceRepository) Create(record *models.ComplianceRedirect) error {
+	query := `
+		INSERT INTO compliance_redirects (short_code, click_id, destination, destination_host, matched_domain, visitor_country)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''))
+		RETURNING id, occurred_at`
+
+	return r.db.QueryRow(
+		query,
+		record.ShortCode,
+		record.ClickID,
+		record.Destination,
+		record.DestinationHost,
+		record.MatchedDomain,
+		record.VisitorCountry,
+	).Scan(&record.ID, &record.OccurredAt)
+}
+
+// ListRange returns up to limit records with from <= occurred_at < to and an id above
+// afterID, oldest first, so exports can page through large ranges
+func (r *ComplianceRepository) ListRange(from, to time.Time, afterID int64, limit int) ([]*models.ComplianceRedirect, error) {
+	query := `
+		SELECT id, occurred_at, short_code, click_id, destination, destination_host, matched_domain,
+			COALESCE(visitor_country, '')
+		FROM compliance_redirects
+		WHERE occurred_at >= $1 AND occurred_at < $2 AND id > $3
+		ORDER BY id
+		LIMIT $4`
+
+	rows, err := r.db.Query(query, from, to, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []*models.ComplianceRedirect
+	for rows.Next() {
+		record := &models.ComplianceRedirect{}
+		if err := rows.Scan(
+			&record.ID,
+			&record.OccurredAt,
+			&record.ShortCode,
+			&record.ClickID,
+			&record.Destination,
+			&record.DestinationHost,
+			&record.MatchedDomain,
+			&record.VisitorCountry,
+		); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+
+	return records, rows.Err()
+}
@@ -1,16 +1,38 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
-// NewPostgresDB creates a new PostgreSQL database connection
-func NewPostgresDB(databaseURL string) (*sql.DB, error) {
-	db, err := sql.Open("postgres", databaseURL)
+// PoolConfig sizes the database connection pool
+type PoolConfig struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+// ToolPool sizes the pool of one-off tools such as migrations, so they never compete with
+// the server for connections
+func ToolPool() PoolConfig {
+	return PoolConfig{
+		MaxOpenConns:    2,
+		MaxIdleConns:    1,
+		ConnMaxLifetime: time.Hour,
+		ConnMaxIdleTime: time.Minute,
+	}
+}
+
+// NewPostgresDB creates a new PostgreSQL database connection. A non-nil fault
+// injector wraps the driver so every connection delays or fails calls on purpose.
+func NewPostgresDB(databaseURL string, pool PoolConfig, faults *FaultInjector) (*sql.DB, error) {
+	db, err := openPostgres(databaseURL, faults)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -19,45 +41,382 @@ func NewPostgresDB(databaseURL string) (*sql.DB, error) {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	// Set connection pool settings optimized for high load
-	db.SetMaxOpenConns(100)                 // Increase from 25 to handle more concurrent requests
-	db.SetMaxIdleConns(25)                  // Increase from 5 to reduce connection establishment overhead
-	db.SetConnMaxLifetime(time.Hour)        // Prevent connection leaks and ensure fresh connections
-	db.SetConnMaxIdleTime(30 * time.Minute) // Close idle connections after 30 minutes
+	if err := validatePool(db, pool); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	db.SetMaxOpenConns(pool.MaxOpenConns)
+	db.SetMaxIdleConns(pool.MaxIdleConns)
+	db.SetConnMaxLifetime(pool.ConnMaxLifetime) // Prevent connection leaks and ensure fresh connections
+	db.SetConnMaxIdleTime(pool.ConnMaxIdleTime)
 
 	return db, nil
 }
 
-// RunMigrations executes database migrations
-func RunMigrations(db *sql.DB) error {
-	migrations := []string{
-		`CREATE TABLE IF NOT EXISTS urls (
-			id SERIAL PRIMARY KEY,
-			short_code VARCHAR(10) UNIQUE NOT NULL,
-			original_url TEXT NOT NULL,
-			custom_alias BOOLEAN DEFAULT FALSE,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			expires_at TIMESTAMP NULL
-		)`,
-		`CREATE INDEX IF NOT EXISTS idx_urls_short_code ON urls(short_code)`,
-		`CREATE INDEX IF NOT EXISTS idx_urls_created_at ON urls(created_at)`,
-		`CREATE TABLE IF NOT EXISTS analytics (
-			id SERIAL PRIMARY KEY,
-			short_code VARCHAR(10) NOT NULL,
-			clicked_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			ip_address INET,
-			user_agent TEXT,
-			FOREIGN KEY (short_code) REFERENCES urls(short_code) ON DELETE CASCADE
-		)`,
-		`CREATE INDEX IF NOT EXISTS idx_analytics_short_code ON analytics(short_code)`,
-		`CREATE INDEX IF NOT EXISTS idx_analytics_clicked_at ON analytics(clicked_at)`,
-		`CREATE INDEX IF NOT EXISTS idx_analytics_clicked_at_short_code ON analytics(clicked_at, short_code)`,
-		// Create atomic sequence for URL ID generation to prevent race conditions
-		`CREATE SEQUENCE IF NOT EXISTS url_id_sequence START WITH 1 INCREMENT BY 1`,
+// withQueryTimeout bounds a query by timeout as well as by the caller's context, so a
+// slow query fails instead of holding its connection; 0 leaves only the caller's context
+func withQueryTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+func openPostgres(databaseURL string, faults *FaultInjector) (*sql.DB, error) {
+	if faults == nil {
+		return sql.Open("postgres", databaseURL)
+	}
+
+	connector, err := pq.NewConnector(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	return sql.OpenDB(&faultConnector{Connector: connector, faults: faults}), nil
+}
+
+// validatePool rejects pool sizes the server cannot serve, so a misconfigured instance
+// fails at startup instead of starving every other client of the database under load
+func validatePool(db *sql.DB, pool PoolConfig) error {
+	if pool.MaxOpenConns < 1 {
+		return fmt.Errorf("max open connections must be at least 1, got %d", pool.MaxOpenConns)
+	}
+	if pool.MaxIdleConns > pool.MaxOpenConns {
+		return fmt.Errorf("max idle connections (%d) must not exceed max open connections (%d)",
+			pool.MaxIdleConns, pool.MaxOpenConns)
 	}
 
-	for _, migration := range migrations {
-		if _, err := db.Exec(migration); err != nil {
+	var maxConnections, reserved int
+	query := `
+		SELECT current_setting('max_connections')::int,
+			current_setting('superuser_reserved_connections')::int`
+	if err := db.QueryRow(query).Scan(&maxConnections, &reserved); err != nil {
+		return fmt.Errorf("failed to read server connection limits: %w", err)
+	}
+
+	if available := maxConnections - reserved; pool.MaxOpenConns > available {
+		return fmt.Errorf("max open connections (%d) exceeds the %d connections the server allows (max_connections=%d)",
+			pool.MaxOpenConns, available, maxConnections)
+	}
+
+	return nil
+}
+
+// migrations are applied in order at every boot, so each statement must be idempotent.
+// Indexes are built CONCURRENTLY so they never block writes on live tables.
+var migrations = []string{
+	`CREATE TABLE IF NOT EXISTS urls (
+		id SERIAL PRIMARY KEY,
+		short_code VARCHAR(10) UNIQUE NOT NULL,
+		original_url TEXT NOT NULL,
+		custom_alias BOOLEAN DEFAULT FALSE,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		expires_at TIMESTAMP NULL
+	)`,
+	`CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_urls_short_code ON urls(short_code)`,
+	`CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_urls_created_at ON urls(created_at)`,
+	`CREATE TABLE IF NOT EXISTS analytics (
+		id SERIAL PRIMARY KEY,
+		short_code VARCHAR(10) NOT NULL,
+		clicked_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		ip_address INET,
+		user_agent TEXT,
+		FOREIGN KEY (short_code) REFERENCES urls(short_code) ON DELETE CASCADE
+	)`,
+	`CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_analytics_short_code ON analytics(short_code)`,
+	`CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_analytics_clicked_at ON analytics(clicked_at)`,
+	`CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_analytics_clicked_at_short_code ON analytics(clicked_at, short_code)`,
+	// Create atomic sequence for URL ID generation to prevent race conditions
+	`CREATE SEQUENCE IF NOT EXISTS url_id_sequence START WITH 1 INCREMENT BY 1`,
+	`CREATE TABLE IF NOT EXISTS webhooks (
+		id SERIAL PRIMARY KEY,
+		target_url TEXT NOT NULL,
+		short_code VARCHAR(10) NULL,
+		secret VARCHAR(64) NOT NULL,
+		aggregation_window INTEGER NOT NULL DEFAULT 0,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (short_code) REFERENCES urls(short_code) ON DELETE CASCADE
+	)`,
+	`CREATE TABLE IF NOT EXISTS jobs (
+		id SERIAL PRIMARY KEY,
+		type VARCHAR(50) NOT NULL,
+		status VARCHAR(20) NOT NULL,
+		processed BIGINT NOT NULL DEFAULT 0,
+		error TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		finished_at TIMESTAMP NULL
+	)`,
+	`CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_jobs_created_at ON jobs(created_at)`,
+	`CREATE TABLE IF NOT EXISTS aliases (
+		alias VARCHAR(10) PRIMARY KEY,
+		short_code VARCHAR(10) NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (short_code) REFERENCES urls(short_code) ON DELETE CASCADE
+	)`,
+	`CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_aliases_short_code ON aliases(short_code)`,
+	`ALTER TABLE urls ADD COLUMN IF NOT EXISTS path_passthrough BOOLEAN NOT NULL DEFAULT FALSE`,
+	`ALTER TABLE analytics ADD COLUMN IF NOT EXISTS click_id VARCHAR(32) NULL`,
+	`ALTER TABLE analytics ADD COLUMN IF NOT EXISTS visitor_id VARCHAR(32) NULL`,
+	`CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_analytics_visitor_id ON analytics(visitor_id) WHERE visitor_id IS NOT NULL`,
+	`CREATE TABLE IF NOT EXISTS data_keys (
+		id SERIAL PRIMARY KEY,
+		kek_id VARCHAR(64) NOT NULL,
+		wrapped_key BYTEA NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		retired_at TIMESTAMP NULL
+	)`,
+	`ALTER TABLE analytics ADD COLUMN IF NOT EXISTS ip_address_enc BYTEA NULL`,
+	`ALTER TABLE analytics ADD COLUMN IF NOT EXISTS user_agent_enc BYTEA NULL`,
+	`ALTER TABLE analytics ADD COLUMN IF NOT EXISTS ip_address_hmac BYTEA NULL`,
+	`ALTER TABLE analytics ADD COLUMN IF NOT EXISTS pii_key_id INTEGER NULL`,
+	`CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_analytics_ip_address_hmac ON analytics(ip_address_hmac) WHERE ip_address_hmac IS NOT NULL`,
+	`CREATE TABLE IF NOT EXISTS url_history (
+		id SERIAL PRIMARY KEY,
+		short_code VARCHAR(10) NOT NULL,
+		previous_url TEXT NOT NULL,
+		new_url TEXT NOT NULL,
+		changed_by VARCHAR(100) NOT NULL,
+		changed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (short_code) REFERENCES urls(short_code) ON DELETE CASCADE
+	)`,
+	`CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_url_history_short_code ON url_history(short_code, changed_at)`,
+	// The compliance log outlives links and rejects every change once written
+	`CREATE TABLE IF NOT EXISTS compliance_redirects (
+		id BIGSERIAL PRIMARY KEY,
+		occurred_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		short_code VARCHAR(10) NOT NULL,
+		click_id VARCHAR(32) NOT NULL,
+		destination TEXT NOT NULL,
+		destination_host TEXT NOT NULL,
+		matched_domain TEXT NOT NULL,
+		visitor_country CHAR(2) NULL
+	)`,
+	`CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_compliance_redirects_occurred_at ON compliance_redirects(occurred_at)`,
+	`CREATE OR REPLACE FUNCTION compliance_redirects_append_only() RETURNS trigger AS $$
+	BEGIN
+		RAISE EXCEPTION 'compliance_redirects is append-only';
+	END;
+	$$ LANGUAGE plpgsql`,
+	`DO $$
+	BEGIN
+		IF NOT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = 'compliance_redirects_no_modify') THEN
+			CREATE TRIGGER compliance_redirects_no_modify BEFORE UPDATE OR DELETE ON compliance_redirects
+				FOR EACH ROW EXECUTE FUNCTION compliance_redirects_append_only();
+		END IF;
+		IF NOT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname = 'compliance_redirects_no_truncate') THEN
+			CREATE TRIGGER compliance_redirects_no_truncate BEFORE TRUNCATE ON compliance_redirects
+				FOR EACH STATEMENT EXECUTE FUNCTION compliance_redirects_append_only();
+		END IF;
+	END
+	$$`,
+	`CREATE TABLE IF NOT EXISTS rate_limit_overrides (
+		key_id VARCHAR(100) PRIMARY KEY,
+		requests_per_window INTEGER NOT NULL CHECK (requests_per_window > 0),
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`ALTER TABLE urls ADD COLUMN IF NOT EXISTS max_clicks BIGINT NULL CHECK (max_clicks > 0)`,
+	`ALTER TABLE urls ADD COLUMN IF NOT EXISTS clicks_used BIGINT NOT NULL DEFAULT 0`,
+	`CREATE TABLE IF NOT EXISTS domain_policies (
+		domain VARCHAR(253) PRIMARY KEY,
+		action VARCHAR(10) NOT NULL CHECK (action IN ('allow', 'block')),
+		reason TEXT NOT NULL DEFAULT '',
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`ALTER TABLE urls ADD COLUMN IF NOT EXISTS disabled_at TIMESTAMP NULL`,
+	`ALTER TABLE urls ADD COLUMN IF NOT EXISTS disabled_reason TEXT NULL`,
+	`CREATE TABLE IF NOT EXISTS takedown_requests (
+		id SERIAL PRIMARY KEY,
+		short_code VARCHAR(10) NOT NULL,
+		reason VARCHAR(20) NOT NULL,
+		reporter_name TEXT NOT NULL DEFAULT '',
+		reporter_email TEXT NOT NULL,
+		description TEXT NOT NULL,
+		evidence_urls TEXT[] NOT NULL DEFAULT '{}',
+		status VARCHAR(10) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'upheld', 'rejected')),
+		resolution_note TEXT NOT NULL DEFAULT '',
+		resolved_by VARCHAR(100) NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		resolved_at TIMESTAMP NULL,
+		FOREIGN KEY (short_code) REFERENCES urls(short_code) ON DELETE CASCADE
+	)`,
+	`CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_takedown_requests_status ON takedown_requests(status, created_at)`,
+	`CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_takedown_requests_short_code ON takedown_requests(short_code)`,
+	`ALTER TABLE analytics ADD COLUMN IF NOT EXISTS referrer TEXT NULL`,
+	`ALTER TABLE analytics ADD COLUMN IF NOT EXISTS device_type VARCHAR(20) NULL`,
+	`ALTER TABLE analytics ADD COLUMN IF NOT EXISTS browser VARCHAR(40) NULL`,
+	`ALTER TABLE analytics ADD COLUMN IF NOT EXISTS os VARCHAR(40) NULL`,
+	`CREATE TABLE IF NOT EXISTS alias_claims (
+		id BIGSERIAL PRIMARY KEY,
+		actor VARCHAR(120) NOT NULL,
+		alias VARCHAR(80) NOT NULL,
+		short_code VARCHAR(10) NULL,
+		outcome VARCHAR(20) NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_alias_claims_created_at ON alias_claims(created_at)`,
+	`CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_alias_claims_actor ON alias_claims(actor, created_at)`,
+	`ALTER TABLE analytics ADD COLUMN IF NOT EXISTS is_bot BOOLEAN NOT NULL DEFAULT FALSE`,
+	`ALTER TABLE analytics ADD COLUMN IF NOT EXISTS via_qr BOOLEAN NOT NULL DEFAULT FALSE`,
+	`CREATE TABLE IF NOT EXISTS domains (
+		id SERIAL PRIMARY KEY,
+		domain VARCHAR(253) UNIQUE NOT NULL,
+		owner VARCHAR(120) NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE TABLE IF NOT EXISTS domain_links (
+		domain_id INTEGER NOT NULL,
+		code VARCHAR(20) NOT NULL,
+		short_code VARCHAR(10) NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (domain_id, code),
+		FOREIGN KEY (domain_id) REFERENCES domains(id) ON DELETE CASCADE,
+		FOREIGN KEY (short_code) REFERENCES urls(short_code) ON DELETE CASCADE
+	)`,
+	`CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_domain_links_short_code ON domain_links(short_code)`,
+	`ALTER TABLE urls ADD COLUMN IF NOT EXISTS numeric_code VARCHAR(20) NULL`,
+	`CREATE UNIQUE INDEX CONCURRENTLY IF NOT EXISTS idx_urls_numeric_code ON urls(numeric_code) WHERE numeric_code IS NOT NULL`,
+	`ALTER TABLE urls ADD COLUMN IF NOT EXISTS redirect_rules JSONB NULL`,
+	// Destinations can be longer than a btree entry allows, so deduplication looks them up by hash
+	`CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_urls_original_url_md5 ON urls(md5(original_url))`,
+	`ALTER TABLE analytics ADD COLUMN IF NOT EXISTS is_internal BOOLEAN NOT NULL DEFAULT FALSE`,
+	`CREATE TABLE IF NOT EXISTS click_goals (
+		id BIGSERIAL PRIMARY KEY,
+		short_code VARCHAR(10) NOT NULL,
+		target_clicks BIGINT NOT NULL,
+		deadline TIMESTAMP NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		reached_at TIMESTAMP NULL,
+		missed_at TIMESTAMP NULL,
+		behind_alerted_at TIMESTAMP NULL,
+		FOREIGN KEY (short_code) REFERENCES urls(short_code) ON DELETE CASCADE
+	)`,
+	`CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_click_goals_short_code ON click_goals(short_code)`,
+	`CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_click_goals_open ON click_goals(deadline) WHERE reached_at IS NULL AND missed_at IS NULL`,
+	`CREATE TABLE IF NOT EXISTS link_utm_params (
+		short_code VARCHAR(10) PRIMARY KEY,
+		utm_source VARCHAR(200) NULL,
+		utm_medium VARCHAR(200) NULL,
+		utm_campaign VARCHAR(200) NULL,
+		utm_term VARCHAR(200) NULL,
+		utm_content VARCHAR(200) NULL,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (short_code) REFERENCES urls(short_code) ON DELETE CASCADE
+	)`,
+	`ALTER TABLE urls ADD COLUMN IF NOT EXISTS landing_page JSONB NULL`,
+	`ALTER TABLE analytics ADD COLUMN IF NOT EXISTS redirect_rule SMALLINT NULL`,
+	`CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_link_utm_params_campaign ON link_utm_params(utm_campaign) WHERE utm_campaign IS NOT NULL`,
+	`CREATE TABLE IF NOT EXISTS reports (
+		id BIGSERIAL PRIMARY KEY,
+		campaign VARCHAR(200) NOT NULL,
+		period VARCHAR(10) NOT NULL,
+		period_start TIMESTAMP NOT NULL,
+		period_end TIMESTAMP NOT NULL,
+		links INTEGER NOT NULL,
+		clicks BIGINT NOT NULL,
+		unique_visitors BIGINT NOT NULL,
+		html TEXT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (campaign, period, period_start)
+	)`,
+	`CREATE TABLE IF NOT EXISTS event_destinations (
+		provider VARCHAR(20) PRIMARY KEY,
+		api_key TEXT NOT NULL,
+		enabled BOOLEAN NOT NULL DEFAULT TRUE,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		last_delivered_at TIMESTAMP NULL,
+		last_error TEXT NULL,
+		last_error_at TIMESTAMP NULL
+	)`,
+	`ALTER TABLE urls ADD COLUMN IF NOT EXISTS activate_at TIMESTAMP NULL`,
+	`ALTER TABLE urls ADD COLUMN IF NOT EXISTS code_strategy VARCHAR(20) NULL`,
+	`CREATE TABLE IF NOT EXISTS signing_key_usage (
+		key_id VARCHAR(100) PRIMARY KEY,
+		last_used_at TIMESTAMP NOT NULL,
+		last_used_ip VARCHAR(45) NOT NULL
+	)`,
+	`ALTER TABLE jobs ADD COLUMN IF NOT EXISTS cursor TEXT NOT NULL DEFAULT ''`,
+}
+
+// analyticsMirrorMigrations prepare a secondary database that receives a copy of every
+// click while analytics moves to a new backend. Rows keep their primary ids, which makes
+// double-writes and backfills idempotent, and there is no foreign key to urls.
+var analyticsMirrorMigrations = []string{
+	`CREATE TABLE IF NOT EXISTS analytics (
+		id BIGINT PRIMARY KEY,
+		short_code VARCHAR(10) NOT NULL,
+		clicked_at TIMESTAMP NOT NULL,
+		ip_address INET,
+		user_agent TEXT
+	)`,
+	`CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_analytics_short_code ON analytics(short_code)`,
+	`CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_analytics_clicked_at ON analytics(clicked_at)`,
+	`ALTER TABLE analytics ADD COLUMN IF NOT EXISTS click_id VARCHAR(32) NULL`,
+	`ALTER TABLE analytics ADD COLUMN IF NOT EXISTS visitor_id VARCHAR(32) NULL`,
+	`ALTER TABLE analytics ADD COLUMN IF NOT EXISTS ip_address_enc BYTEA NULL`,
+	`ALTER TABLE analytics ADD COLUMN IF NOT EXISTS user_agent_enc BYTEA NULL`,
+	`ALTER TABLE analytics ADD COLUMN IF NOT EXISTS ip_address_hmac BYTEA NULL`,
+	`ALTER TABLE analytics ADD COLUMN IF NOT EXISTS pii_key_id INTEGER NULL`,
+	`CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_analytics_ip_address_hmac ON analytics(ip_address_hmac) WHERE ip_address_hmac IS NOT NULL`,
+	`ALTER TABLE analytics ADD COLUMN IF NOT EXISTS referrer TEXT NULL`,
+	`ALTER TABLE analytics ADD COLUMN IF NOT EXISTS device_type VARCHAR(20) NULL`,
+	`ALTER TABLE analytics ADD COLUMN IF NOT EXISTS browser VARCHAR(40) NULL`,
+	`ALTER TABLE analytics ADD COLUMN IF NOT EXISTS os VARCHAR(40) NULL`,
+	`ALTER TABLE analytics ADD COLUMN IF NOT EXISTS is_bot BOOLEAN NOT NULL DEFAULT FALSE`,
+	`ALTER TABLE analytics ADD COLUMN IF NOT EXISTS via_qr BOOLEAN NOT NULL DEFAULT FALSE`,
+	`ALTER TABLE analytics ADD COLUMN IF NOT EXISTS is_internal BOOLEAN NOT NULL DEFAULT FALSE`,
+	`ALTER TABLE analytics ADD COLUMN IF NOT EXISTS redirect_rule SMALLINT NULL`,
+}
+
+// migrationLockKey is the advisory lock that serializes migrations of instances booting
+// at the same time
+const migrationLockKey = 7294316048151731
+
+// RunMigrations executes database migrations. Every statement runs with the given
+// lock_timeout so a migration waiting on a busy table fails fast instead of queueing
+// all production traffic behind its lock. Statements that are already applied are
+// skipped, since ALTER TABLE locks the table even when the column exists.
+func RunMigrations(db *sql.DB, lockTimeout time.Duration) error {
+	return applyMigrations(db, migrations, lockTimeout)
+}
+
+// RunAnalyticsMirrorMigrations prepares the schema of an analytics mirror database
+func RunAnalyticsMirrorMigrations(db *sql.DB, lockTimeout time.Duration) error {
+	return applyMigrations(db, analyticsMirrorMigrations, lockTimeout)
+}
+
+func applyMigrations(db *sql.DB, statements []string, lockTimeout time.Duration) error {
+	ctx := context.Background()
+
+	// session settings only apply to a single connection, so pin one
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire migration connection: %w", err)
+	}
+	defer conn.Close()
+
+	// Instances booting together wait for the first one, then find everything applied.
+	// The lock is taken before lock_timeout is set, which would otherwise bound the wait.
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockKey); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, migrationLockKey)
+
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf("SET lock_timeout = %d", lockTimeout.Milliseconds())); err != nil {
+		return fmt.Errorf("failed to set lock timeout: %w", err)
+	}
+
+	for _, migration := range statements {
+		upper := strings.ToUpper(strings.TrimSpace(whitespaceRe.ReplaceAllString(migration, " ")))
+		applied, err := migrationApplied(conn, upper)
+		if err != nil {
+			return err
+		}
+		if applied {
+			continue
+		}
+		if _, err := conn.ExecContext(ctx, migration); err != nil {
 			return fmt.Errorf("failed to run migration: %w", err)
 		}
 	}
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/alexnthnz/url-shortener/internal/models"
+)
+
+// DomainPolicyRepository stores the allowed and blocked destination domains
+type DomainPolicyRepository struct {
+	db *sql.DB
+}
+
+func NewDomainPolicyRepository(db *sql.DB) *DomainPolicyRepository {
+	return &DomainPolicyRepository{db: db}
+}
+
+// List returns every policy ordered by domain
+func (r *DomainPolicyRepository) List() ([]*models.DomainPolicy, error) {
+	rows, err := r.db.Query(`SELECT domain, action, reason, updated_at FROM domain_policies ORDER BY domain`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var policies []*models.DomainPolicy
+	for rows.Next() {
+		policy := &models.DomainPolicy{}
+		if err := rows.Scan(&policy.Domain, &policy.Action, &policy.Reason, &policy.UpdatedAt); err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	}
+	return policies, rows.Err()
+}
+
+// Upsert sets the policy of a domain, replacing any previous one
+func (r *DomainPolicyRepository) Upsert(policy *models.DomainPolicy) error {
+	query := `
+		INSERT INTO domain_policies (domain, action, reason)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (domain) DO UPDATE SET action = EXCLUDED.action, reason = EXCLUDED.reason, updated_at = CURRENT_TIMESTAMP
+		RETURNING updated_at`
+
+	return r.db.QueryRow(query, policy.Domain, policy.Action, policy.Reason).Scan(&policy.UpdatedAt)
+}
+
+// Delete removes the policy of a domain, reporting whether one existed
+func (r *DomainPolicyRepository) Delete(domain string) (bool, error) {
+	result, err := r.db.Exec(`DELETE FROM domain_policies WHERE domain = $1`, domain)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
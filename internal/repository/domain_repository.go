@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/alexnthnz/url-shortener/internal/models"
+)
+
+// DomainRepository stores custom domains and the short codes links have on them
+type DomainRepository struct {
+	db           *sql.DB
+	queryTimeout time.Duration // 0 leaves queries to the caller's context
+}
+
+func NewDomainRepository(db *sql.DB, queryTimeout time.Duration) *DomainRepository {
+	return &DomainRepository{db: db, queryTimeout: queryTimeout}
+}
+
+// Create registers a custom domain
+func (r *DomainRepository) Create(domain *models.Domain) error {
+	query := `
+		INSERT INTO domains (domain, owner)
+		VALUES ($1, $2)
+		RETURNING id, created_at`
+
+	return mapDuplicate(r.db.QueryRow(query, domain.Domain, domain.Owner).Scan(&domain.ID, &domain.CreatedAt))
+}
+
+// List returns every custom domain ordered by domain
+func (r *DomainRepository) List() ([]*models.Domain, error) {
+	rows, err := r.db.Query(`SELECT id, domain, owner, created_at FROM domains ORDER BY domain`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var domains []*models.Domain
+	for rows.Next() {
+		domain := &models.Domain{}
+		if err := rows.Scan(&domain.ID, &domain.Domain, &domain.Owner, &domain.CreatedAt); err != nil {
+			return nil, err
+		}
+		domains = append(domains, domain)
+	}
+	return domains, rows.Err()
+}
+
+// Delete removes a custom domain of an owner together with its short codes, reporting
+// whether it existed
+func (r *DomainRepository) Delete(domain, owner string) (bool, error) {
+	result, err := r.db.Exec(`DELETE FROM domains WHERE domain = $1 AND owner = $2`, domain, owner)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// CreateLink gives a link a short code on a custom domain
+func (r *DomainRepository) CreateLink(domainID int64, code, shortCode string) error {
+	query := `INSERT INTO domain_links (domain_id, code, short_code) VALUES ($1, $2, $3)`
+	_, err := r.db.Exec(query, domainID, code, shortCode)
+	return mapDuplicate(err)
+}
+
+// ListLinkDomains returns the custom domains a link has codes on, ordered by domain
+func (r *DomainRepository) ListLinkDomains(ctx context.Context, shortCode string) ([]string, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT DISTINCT d.domain
+		FROM domain_links l
+		JOIN domains d ON d.id = l.domain_id
+		WHERE l.short_code = $1
+		ORDER BY d.domain`
+
+	rows, err := r.db.QueryContext(ctx, query, shortCode)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var domains []string
+	for rows.Next() {
+		var domain string
+		if err := rows.Scan(&domain); err != nil {
+			return nil, err
+		}
+		domains = append(domains, domain)
+	}
+	return domains, rows.Err()
+}
+
+// GetShortCode returns the canonical short code a code on a custom domain points to,
+// empty when the domain has no such code
+func (r *DomainRepository) GetShortCode(ctx context.Context, domainID int64, code string) (string, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var shortCode string
+	query := `SELECT short_code FROM domain_links WHERE domain_id = $1 AND code = $2`
+	err := r.db.QueryRowContext(ctx, query, domainID, code).Scan(&shortCode)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return shortCode, err
+}
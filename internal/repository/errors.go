@@ -0,0 +1,24 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// ErrDuplicate is returned when a write would repeat a value that must be unique, such as
+// a short code, alias or domain that is already taken
+var ErrDuplicate = errors.New("duplicate key")
+
+// uniqueViolation is the PostgreSQL error code of a unique constraint violation
+const uniqueViolation = "23505"
+
+// mapDuplicate wraps unique violations in ErrDuplicate and returns other errors as they are
+func mapDuplicate(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		return fmt.Errorf("%w: %s", ErrDuplicate, pqErr.Message)
+	}
+	return err
+}
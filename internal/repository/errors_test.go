@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestMapDuplicate(t *testing.T) {
+	testCases := []struct {
+		name      string
+		err       error
+		duplicate bool
+	}{
+		{"nil", nil, false},
+		{"unique violation", &pq.Error{Code: "23505", Message: `duplicate key value violates unique constraint "urls_short_code_key"`}, true},
+		{"foreign key violation", &pq.Error{Code: "23503", Message: "insert or update violates foreign key constraint"}, false},
+		{"other error", sql.ErrConnDone, false},
+	}
+
+	for _, tc := range testCases {
+		err := mapDuplicate(tc.err)
+		if duplicate := errors.Is(err, ErrDuplicate); duplicate != tc.duplicate {
+			t.Errorf("%s: errors.Is(mapDuplicate(err), ErrDuplicate) = %v; expected %v", tc.name, duplicate, tc.duplicate)
+		}
+		if !tc.duplicate && err != tc.err {
+			t.Errorf("%s: mapDuplicate changed the error to %v", tc.name, err)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/alexnthnz/url-shortener/internal/models"
+)
+
+// EventDestinationRepository stores the product analytics services click events are
+// forwarded to, with their API keys
+type EventDestinationRepository struct {
+	db *sql.DB
+}
+
+func NewEventDestinationRepository(db *sql.DB) *EventDestinationRepository {
+	return &EventDestinationRepository{db: db}
+}
+
+// List returns every destination, by provider
+func (r *EventDestinationRepository) List() ([]*models.EventDestination, error) {
+	query := `
+		SELECT provider, api_key, enabled, created_at, updated_at, last_delivered_at,
+			COALESCE(last_error, ''), last_error_at
+		FROM event_destinations
+		ORDER BY provider`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var destinations []*models.EventDestination
+	for rows.Next() {
+		destination := &models.EventDestination{}
+		if err := rows.Scan(&destination.Provider, &destination.APIKey, &destination.Enabled, &destination.CreatedAt,
+			&destination.UpdatedAt, &destination.LastDeliveredAt, &destination.LastError, &destination.LastErrorAt); err != nil {
+			return nil, err
+		}
+		destinations = append(destinations, destination)
+	}
+	return destinations, rows.Err()
+}
+
+// Upsert creates or updates a destination. A new API key clears the last delivery error,
+// which was most likely caused by the old one.
+func (r *EventDestinationRepository) Upsert(destination *models.EventDestination) error {
+	query := `
+		INSERT INTO event_destinations (provider, api_key, enabled)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (provider) DO UPDATE SET
+			api_key = EXCLUDED.api_key,
+			enabled = EXCLUDED.enabled,
+			updated_at = CURRENT_TIMESTAMP,
+			last_error = CASE WHEN event_destinations.api_key = EXCLUDED.api_key THEN event_destinations.last_error END,
+			last_error_at = CASE WHEN event_destinations.api_key = EXCLUDED.api_key THEN event_destinations.last_error_at END
+		RETURNING created_at, updated_at, last_delivered_at, COALESCE(last_error, ''), last_error_at`
+
+	return r.db.QueryRow(query, destination.Provider, destination.APIKey, destination.Enabled).Scan(
+		&destination.CreatedAt, &destination.UpdatedAt, &destination.LastDeliveredAt, &destination.LastError, &destination.LastErrorAt)
+}
+
+// Delete removes a destination, reporting whether it existed
+func (r *EventDestinationRepository) Delete(provider string) (bool, error) {
+	result, err := r.db.Exec(`DELETE FROM event_destinations WHERE provider = $1`, provider)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// RecordDelivery records the outcome of forwarding a batch; an empty message is a success
+func (r *EventDestinationRepository) RecordDelivery(provider, message string, at time.Time) error {
+	if message == "" {
+		_, err := r.db.Exec(`UPDATE event_destinations SET last_delivered_at = $2 WHERE provider = $1`, provider, at)
+		return err
+	}
+
+	_, err := r.db.Exec(`UPDATE event_destinations SET last_error = $2, last_error_at = $3 WHERE provider = $1`,
+		provider, message, at)
+	return err
+}
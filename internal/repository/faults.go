@@ -0,0 +1,178 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ErrInjectedFault is returned by calls failed on purpose by fault injection
+var ErrInjectedFault = errors.New("injected fault")
+
+// FaultConfig describes the faults injected into one dependency
+type FaultConfig struct {
+	ErrorRate float64       // fraction of calls that fail, 0-1
+	DelayRate float64       // fraction of calls that are delayed, 0-1
+	Delay     time.Duration // added latency for delayed calls
+}
+
+// FaultInjector delays or fails a fraction of calls to a dependency. It exists to
+// exercise fallbacks and degraded modes in staging and must never run in production.
+// Injectors start disarmed so connecting and migrating at boot are never disturbed.
+type FaultInjector struct {
+	name   string
+	config FaultConfig
+	armed  atomic.Bool
+}
+
+// NewFaultInjector returns an injector, or nil when the configuration injects nothing
+func NewFaultInjector(name string, config FaultConfig) *FaultInjector {
+	if config.ErrorRate <= 0 && (config.DelayRate <= 0 || config.Delay <= 0) {
+		return nil
+	}
+	return &FaultInjector{name: name, config: config}
+}
+
+// Arm starts injecting faults
+func (f *FaultInjector) Arm() {
+	if f != nil {
+		f.armed.Store(true)
+	}
+}
+
+// Inject applies the configured faults to a single call
+func (f *FaultInjector) Inject(ctx context.Context) error {
+	if f == nil || !f.armed.Load() {
+		return nil
+	}
+
+	if f.config.DelayRate > 0 && rand.Float64() < f.config.DelayRate {
+		select {
+		case <-time.After(f.config.Delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	if f.config.ErrorRate > 0 && rand.Float64() < f.config.ErrorRate {
+		return fmt.Errorf("%s: %w", f.name, ErrInjectedFault)
+	}
+	return nil
+}
+
+// redisFaultHook injects faults into every Redis command and pipeline
+type redisFaultHook struct {
+	faults *FaultInjector
+}
+
+func (h redisFaultHook) BeforeProcess(ctx context.Context, _ redis.Cmder) (context.Context, error) {
+	return ctx, h.faults.Inject(ctx)
+}
+
+func (h redisFaultHook) AfterProcess(context.Context, redis.Cmder) error {
+	return nil
+}
+
+func (h redisFaultHook) BeforeProcessPipeline(ctx context.Context, _ []redis.Cmder) (context.Context, error) {
+	return ctx, h.faults.Inject(ctx)
+}
+
+func (h redisFaultHook) AfterProcessPipeline(context.Context, []redis.Cmder) error {
+	return nil
+}
+
+// faultConnector wraps a driver connector so every connection injects faults
+type faultConnector struct {
+	driver.Connector
+	faults *FaultInjector
+}
+
+func (c *faultConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	if err := c.faults.Inject(ctx); err != nil {
+		return nil, err
+	}
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &faultConn{Conn: conn, faults: c.faults}, nil
+}
+
+// faultConn injects faults before queries, statements and transactions, delegating
+// to the optional context-aware interfaces of the wrapped driver connection
+type faultConn struct {
+	driver.Conn
+	faults *FaultInjector
+}
+
+func (c *faultConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	if err := c.faults.Inject(ctx); err != nil {
+		return nil, err
+	}
+	return queryer.QueryContext(ctx, query, args)
+}
+
+func (c *faultConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	if err := c.faults.Inject(ctx); err != nil {
+		return nil, err
+	}
+	return execer.ExecContext(ctx, query, args)
+}
+
+func (c *faultConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if err := c.faults.Inject(ctx); err != nil {
+		return nil, err
+	}
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return preparer.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+func (c *faultConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if err := c.faults.Inject(ctx); err != nil {
+		return nil, err
+	}
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin() //lint:ignore SA1019 fallback for drivers without BeginTx
+}
+
+func (c *faultConn) Ping(ctx context.Context) error {
+	if err := c.faults.Inject(ctx); err != nil {
+		return err
+	}
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *faultConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *faultConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
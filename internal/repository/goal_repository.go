@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/alexnthnz/url-shortener/internal/models"
+)
+
+// GoalRepository stores the click goals of links
+type GoalRepository struct {
+	db *sql.DB
+}
+
+func NewGoalRepository(db *sql.DB) *GoalRepository {
+	return &GoalRepository{db: db}
+}
+
+const goalColumns = `id, short_code, target_clicks, deadline, created_at, reached_at, missed_at, behind_alerted_at`
+
+// Create stores a new click goal
+func (r *GoalRepository) Create(goal *models.ClickGoal) error {
+	query := `
+		INSERT INTO click_goals (short_code, target_clicks, deadline)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at`
+
+	return r.db.QueryRow(query, goal.ShortCode, goal.TargetClicks, goal.Deadline).Scan(&goal.ID, &goal.CreatedAt)
+}
+
+// ListByShortCode returns the goals of a link, newest first
+func (r *GoalRepository) ListByShortCode(shortCode string) ([]*models.ClickGoal, error) {
+	rows, err := r.db.Query(`SELECT `+goalColumns+` FROM click_goals WHERE short_code = $1 ORDER BY created_at DESC, id DESC`, shortCode)
+	if err != nil {
+		return nil, err
+	}
+	return scanGoals(rows)
+}
+
+// ListOpen returns the goals that were neither reached nor missed yet
+func (r *GoalRepository) ListOpen() ([]*models.ClickGoal, error) {
+	rows, err := r.db.Query(`SELECT ` + goalColumns + ` FROM click_goals WHERE reached_at IS NULL AND missed_at IS NULL ORDER BY deadline, id`)
+	if err != nil {
+		return nil, err
+	}
+	return scanGoals(rows)
+}
+
+// Delete removes a goal of a link, reporting whether it existed
+func (r *GoalRepository) Delete(id int64, shortCode string) (bool, error) {
+	result, err := r.db.Exec(`DELETE FROM click_goals WHERE id = $1 AND short_code = $2`, id, shortCode)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// MarkReached records that an open goal was reached, reporting whether this call did so
+func (r *GoalRepository) MarkReached(id int64, at time.Time) (bool, error) {
+	return r.mark(`UPDATE click_goals SET reached_at = $2 WHERE id = $1 AND reached_at IS NULL AND missed_at IS NULL`, id, at)
+}
+
+// MarkMissed closes an open goal whose deadline passed
+func (r *GoalRepository) MarkMissed(id int64, at time.Time) (bool, error) {
+	return r.mark(`UPDATE click_goals SET missed_at = $2 WHERE id = $1 AND reached_at IS NULL AND missed_at IS NULL`, id, at)
+}
+
+// MarkBehindAlerted records the alert that a goal fell behind pace, reporting whether this
+// call did so; the alert is sent once per goal
+func (r *GoalRepository) MarkBehindAlerted(id int64, at time.Time) (bool, error) {
+	return r.mark(`UPDATE click_goals SET behind_alerted_at = $2 WHERE id = $1 AND behind_alerted_at IS NULL`, id, at)
+}
+
+func (r *GoalRepository) mark(query string, id int64, at time.Time) (bool, error) {
+	result, err := r.db.Exec(query, id, at)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func scanGoals(rows *sql.Rows) ([]*models.ClickGoal, error) {
+	defer rows.Close()
+
+	var goals []*models.ClickGoal
+	for rows.Next() {
+		goal := &models.ClickGoal{}
+		if err := rows.Scan(
+			&goal.ID,
+			&goal.ShortCode,
+			&goal.TargetClicks,
+			&goal.Deadline,
+			&goal.CreatedAt,
+			&goal.ReachedAt,
+			&goal.MissedAt,
+			&goal.BehindAlertedAt,
+		); err != nil {
+			return nil, err
+		}
+		goals = append(goals, goal)
+	}
+	return goals, rows.Err()
+}
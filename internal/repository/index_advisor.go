@@ -0,0 +1,176 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// seqScanRowThreshold is the table size above which a sequential scan on a hot path is flagged
+const seqScanRowThreshold = 10000
+
+// hotQuery is a performance-critical query explained by the index advisor
+type hotQuery struct {
+	name  string
+	query string
+	args  func(shortCode string, since time.Time) []interface{}
+}
+
+var hotQueries = []hotQuery{
+	{"lookup", getByShortCodeQuery, func(code string, _ time.Time) []interface{} { return []interface{}{code} }},
+	{"stats", getStatsQuery, func(code string, _ time.Time) []interface{} { return []interface{}{code} }},
+	{"click_count", getClickCountQuery, func(code string, _ time.Time) []interface{} { return []interface{}{code} }},
+	{"timeseries", getDailyClicksQuery, func(code string, since time.Time) []interface{} { return []interface{}{code, since} }},
+	{"redirects_timeseries", getDailyRedirectsQuery, func(_ string, since time.Time) []interface{} { return []interface{}{since} }},
+	{"top_domains", getTopDomainsQuery, func(_ string, since time.Time) []interface{} { return []interface{}{since, 10} }},
+}
+
+// SeqScan describes a sequential scan found in a query plan
+type SeqScan struct {
+	Relation  string
+	TableRows int64
+	Flagged   bool
+}
+
+// QueryPlanReport summarizes the plan of one hot query
+type QueryPlanReport struct {
+	Name          string
+	TotalCost     float64
+	ExecutionTime float64 // milliseconds, only with EXPLAIN ANALYZE
+	IndexesUsed   []string
+	SeqScans      []SeqScan
+}
+
+// IndexAdvice is the full index advisor report
+type IndexAdvice struct {
+	Queries        []QueryPlanReport
+	MissingIndexes []string
+	InvalidIndexes []string
+}
+
+// planNode mirrors the parts of EXPLAIN (FORMAT JSON) output the advisor reads
+type planNode struct {
+	NodeType     string     `json:"Node Type"`
+	RelationName string     `json:"Relation Name"`
+	IndexName    string     `json:"Index Name"`
+	TotalCost    float64    `json:"Total Cost"`
+	Plans        []planNode `json:"Plans"`
+}
+
+type explainOutput struct {
+	Plan          planNode `json:"Plan"`
+	ExecutionTime float64  `json:"Execution Time"`
+}
+
+// AdviseIndexes explains every hot query against the live schema and reports sequential
+// scans on large tables, indexes declared in migrations that are missing, and indexes
+// left INVALID by a failed concurrent build. With analyze the queries are executed.
+func AdviseIndexes(db *sql.DB, analyze bool) (*IndexAdvice, error) {
+	advice := &IndexAdvice{}
+
+	var sampleCode string
+	err := db.QueryRow(`SELECT short_code FROM urls ORDER BY id DESC LIMIT 1`).Scan(&sampleCode)
+	if err == sql.ErrNoRows {
+		sampleCode = "sample"
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to pick a sample short code: %w", err)
+	}
+	since := time.Now().AddDate(0, 0, -30)
+
+	explain := "EXPLAIN (FORMAT JSON) "
+	if analyze {
+		explain = "EXPLAIN (ANALYZE, FORMAT JSON) "
+	}
+
+	tableRows := make(map[string]int64)
+	for _, hq := range hotQueries {
+		var raw string
+		if err := db.QueryRow(explain+hq.query, hq.args(sampleCode, since)...).Scan(&raw); err != nil {
+			return nil, fmt.Errorf("failed to explain %s query: %w", hq.name, err)
+		}
+
+		var output []explainOutput
+		if err := json.Unmarshal([]byte(raw), &output); err != nil || len(output) == 0 {
+			return nil, fmt.Errorf("failed to parse %s query plan: %v", hq.name, err)
+		}
+
+		report := QueryPlanReport{
+			Name:          hq.name,
+			TotalCost:     output[0].Plan.TotalCost,
+			ExecutionTime: output[0].ExecutionTime,
+		}
+		if err := collectPlan(db, &output[0].Plan, &report, tableRows); err != nil {
+			return nil, err
+		}
+		advice.Queries = append(advice.Queries, report)
+	}
+
+	for _, migration := range migrations {
+		m := createIndexRe.FindStringSubmatch(strings.ToUpper(whitespaceRe.ReplaceAllString(migration, " ")))
+		if m == nil || m[2] == "" {
+			continue
+		}
+		exists, err := relationExists(db, m[2])
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			advice.MissingIndexes = append(advice.MissingIndexes, strings.ToLower(m[2]))
+		}
+	}
+
+	rows, err := db.Query(`
+		SELECT c.relname
+		FROM pg_index i
+		JOIN pg_class c ON c.oid = i.indexrelid
+		WHERE NOT i.indisvalid`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list invalid indexes: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		advice.InvalidIndexes = append(advice.InvalidIndexes, name)
+	}
+
+	return advice, rows.Err()
+}
+
+// collectPlan walks a plan tree recording index usage and sequential scans
+func collectPlan(db *sql.DB, node *planNode, report *QueryPlanReport, tableRows map[string]int64) error {
+	if node.IndexName != "" {
+		report.IndexesUsed = append(report.IndexesUsed, node.IndexName)
+	}
+
+	if node.NodeType == "Seq Scan" {
+		rowCount, ok := tableRows[node.RelationName]
+		if !ok {
+			// reltuples is the planner's estimate, cheap to read even on huge tables
+			var estimate float64
+			err := db.QueryRow(`SELECT reltuples FROM pg_class WHERE relname = $1`, node.RelationName).Scan(&estimate)
+			if err != nil {
+				return fmt.Errorf("failed to estimate size of %s: %w", node.RelationName, err)
+			}
+			rowCount = int64(estimate)
+			tableRows[node.RelationName] = rowCount
+		}
+
+		report.SeqScans = append(report.SeqScans, SeqScan{
+			Relation:  node.RelationName,
+			TableRows: rowCount,
+			Flagged:   rowCount >= seqScanRowThreshold,
+		})
+	}
+
+	for i := range node.Plans {
+		if err := collectPlan(db, &node.Plans[i], report, tableRows); err != nil {
+			return err
+		}
+	}
+	return nil
+}
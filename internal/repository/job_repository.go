@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/alexnthnz/url-shortener/internal/models"
+)
+
+type JobRepository struct {
+	db *sql.DB
+}
+
+func NewJobRepository(db *sql.DB) *JobRepository {
+	return &JobRepository{db: db}
+}
+
+const jobColumns = `id, type, status, processed, error, cursor, created_at, updated_at, finished_at`
+
+// Create stores a new job record
+func (r *JobRepository) Create(job *models.Job) error {
+	query := `
+		INSERT INTO jobs (type, status, cursor)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at, updated_at`
+
+	return r.db.QueryRow(query, job.Type, job.Status, job.Cursor).Scan(&job.ID, &job.CreatedAt, &job.UpdatedAt)
+}
+
+// UpdateProgress records how many items a running job has processed and where its next
+// batch starts
+func (r *JobRepository) UpdateProgress(id, processed int64, cursor string) error {
+	query := `UPDATE jobs SET processed = $2, cursor = $3, updated_at = CURRENT_TIMESTAMP WHERE id = $1`
+	_, err := r.db.Exec(query, id, processed, cursor)
+	return err
+}
+
+// Finish records the final status of a job
+func (r *JobRepository) Finish(id int64, status string, processed int64, jobErr string) error {
+	query := `
+		UPDATE jobs
+		SET status = $2, processed = $3, error = $4,
+			updated_at = CURRENT_TIMESTAMP, finished_at = CURRENT_TIMESTAMP
+		WHERE id = $1`
+	_, err := r.db.Exec(query, id, status, processed, jobErr)
+	return err
+}
+
+// ClaimStale returns the jobs with the given status whose progress has not been recorded
+// for staleAfter, touching them so that of several instances starting together only one
+// claims each
+func (r *JobRepository) ClaimStale(status string, staleAfter time.Duration) ([]*models.Job, error) {
+	query := `
+		UPDATE jobs
+		SET updated_at = CURRENT_TIMESTAMP
+		WHERE status = $1 AND updated_at < CURRENT_TIMESTAMP - make_interval(secs => $2)
+		RETURNING ` + jobColumns
+
+	rows, err := r.db.Query(query, status, staleAfter.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	return scanJobs(rows)
+}
+
+// GetByID retrieves a job by its ID
+func (r *JobRepository) GetByID(id int64) (*models.Job, error) {
+	query := `SELECT ` + jobColumns + ` FROM jobs WHERE id = $1`
+
+	rows, err := r.db.Query(query, id)
+	if err != nil {
+		return nil, err
+	}
+	jobs, err := scanJobs(rows)
+	if err != nil || len(jobs) == 0 {
+		return nil, err
+	}
+	return jobs[0], nil
+}
+
+// ListRecent returns the most recently created jobs
+func (r *JobRepository) ListRecent(limit int) ([]*models.Job, error) {
+	query := `SELECT ` + jobColumns + ` FROM jobs ORDER BY id DESC LIMIT $1`
+
+	rows, err := r.db.Query(query, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanJobs(rows)
+}
+
+func scanJobs(rows *sql.Rows) ([]*models.Job, error) {
+	defer rows.Close()
+
+	var jobs []*models.Job
+	for rows.Next() {
+		job := &models.Job{}
+		if err := rows.Scan(
+			&job.ID,
+			&job.Type,
+			&job.Status,
+			&job.Processed,
+			&job.Error,
+			&job.Cursor,
+			&job.CreatedAt,
+			&job.UpdatedAt,
+			&job.FinishedAt,
+		); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
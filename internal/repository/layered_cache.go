@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// LayeredCache answers reads of plain values from a local MemoryCache before asking the
+// shared cache, so hot links skip the network round trip. Local copies live for at most
+// localTTL: a change made through another instance shows up here within that time, while
+// changes made through this instance drop the local copy at once. Counters, lists,
+// sorted sets and locks always go to the shared cache.
+type LayeredCache struct {
+	local    *MemoryCache
+	shared   Cache
+	localTTL time.Duration
+}
+
+// NewLayeredCache puts a local cache of up to localEntries keys in front of shared
+func NewLayeredCache(shared Cache, localEntries int, localTTL time.Duration) *LayeredCache {
+	return &LayeredCache{
+		local:    NewMemoryCache(localEntries),
+		shared:   shared,
+		localTTL: localTTL,
+	}
+}
+
+// Get retrieves a value from the local cache, or from the shared cache and keeps it locally
+func (c *LayeredCache) Get(ctx context.Context, key string) (string, error) {
+	if value, err := c.local.Get(ctx, key); err == nil {
+		return value, nil
+	}
+	value, err := c.shared.Get(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	c.local.SetWithTTL(ctx, key, value, c.localTTL)
+	return value, nil
+}
+
+// Set stores a value in both caches
+func (c *LayeredCache) Set(ctx context.Context, key, value string) error {
+	return c.SetWithTTL(ctx, key, value, defaultCacheTTL)
+}
+
+// SetWithTTL stores a value in both caches, locally for no longer than localTTL
+func (c *LayeredCache) SetWithTTL(ctx context.Context, key, value string, ttl time.Duration) error {
+	if err := c.shared.SetWithTTL(ctx, key, value, ttl); err != nil {
+		c.local.Delete(ctx, key)
+		return err
+	}
+	localTTL := c.localTTL
+	if ttl > 0 && ttl < localTTL {
+		localTTL = ttl
+	}
+	c.local.SetWithTTL(ctx, key, value, localTTL)
+	return nil
+}
+
+// TTL returns the time left before a key expires in the shared cache
+func (c *LayeredCache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	return c.shared.TTL(ctx, key)
+}
+
+// Delete removes values from both caches
+func (c *LayeredCache) Delete(ctx context.Context, keys ...string) error {
+	c.local.Delete(ctx, keys...)
+	return c.shared.Delete(ctx, keys...)
+}
+
+// Close closes the shared cache
+func (c *LayeredCache) Close() error {
+	return c.shared.Close()
+}
+
+// Ping checks if the shared cache is accessible
+func (c *LayeredCache) Ping(ctx context.Context) error {
+	return c.shared.Ping(ctx)
+}
+
+// IncrByWithTTL adds to a counter in the shared cache
+func (c *LayeredCache) IncrByWithTTL(ctx context.Context, key string, value int64, ttl time.Duration) error {
+	c.local.Delete(ctx, key)
+	return c.shared.IncrByWithTTL(ctx, key, value, ttl)
+}
+
+// MGet retrieves several values, asking the shared cache only for those not held locally
+func (c *LayeredCache) MGet(ctx context.Context, keys ...string) ([]string, error) {
+	result := make([]string, len(keys))
+	var missing []string
+	var missingAt []int
+	for i, key := range keys {
+		value, err := c.local.Get(ctx, key)
+		if err != nil {
+			missing = append(missing, key)
+			missingAt = append(missingAt, i)
+			continue
+		}
+		result[i] = value
+	}
+	if len(missing) == 0 {
+		return result, nil
+	}
+
+	values, err := c.shared.MGet(ctx, missing...)
+	if err != nil {
+		return nil, err
+	}
+	for i, value := range values {
+		result[missingAt[i]] = value
+		if value != "" {
+			c.local.SetWithTTL(ctx, missing[i], value, c.localTTL)
+		}
+	}
+	return result, nil
+}
+
+// SetNX stores a value in the shared cache only if the key does not exist there
+func (c *LayeredCache) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	c.local.Delete(ctx, key)
+	return c.shared.SetNX(ctx, key, value, ttl)
+}
+
+// IncrWindow counts one hit in a fixed window in the shared cache
+func (c *LayeredCache) IncrWindow(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error) {
+	c.local.Delete(ctx, key)
+	return c.shared.IncrWindow(ctx, key, window)
+}
+
+// IncrExisting increments a counter of the shared cache that has already been set
+func (c *LayeredCache) IncrExisting(ctx context.Context, key string) (int64, bool, error) {
+	c.local.Delete(ctx, key)
+	return c.shared.IncrExisting(ctx, key)
+}
+
+// PushCapped prepends a value to a list in the shared cache
+func (c *LayeredCache) PushCapped(ctx context.Context, key, value string, maxLen int64) error {
+	return c.shared.PushCapped(ctx, key, value, maxLen)
+}
+
+// ListRange returns up to count entries from the head of a list in the shared cache
+func (c *LayeredCache) ListRange(ctx context.Context, key string, count int64) ([]string, error) {
+	return c.shared.ListRange(ctx, key, count)
+}
+
+// ZIncrBatch adds to the scores of sorted set members in the shared cache
+func (c *LayeredCache) ZIncrBatch(ctx context.Context, key string, increments map[string]float64, maxLen int64, ttl time.Duration) error {
+	return c.shared.ZIncrBatch(ctx, key, increments, maxLen, ttl)
+}
+
+// ZMergeInto merges sorted sets in the shared cache
+func (c *LayeredCache) ZMergeInto(ctx context.Context, dst, src string, weight float64, ttl time.Duration) error {
+	return c.shared.ZMergeInto(ctx, dst, src, weight, ttl)
+}
+
+// ZTop returns the highest scored members of a sorted set in the shared cache
+func (c *LayeredCache) ZTop(ctx context.Context, key string, count int64) ([]ScoredMember, error) {
+	return c.shared.ZTop(ctx, key, count)
+}
+
+// ZRem removes a member from sorted sets in the shared cache
+func (c *LayeredCache) ZRem(ctx context.Context, member string, keys ...string) error {
+	return c.shared.ZRem(ctx, member, keys...)
+}
@@ -0,0 +1,409 @@
+package repository
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var errWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+
+// MemoryCache is an in-process Cache that evicts the least recently used keys beyond
+// maxEntries. It runs the service without Redis in development and tests, and is the
+// local tier of LayeredCache. Nothing is shared between instances, so locks, counters
+// and rate limits only hold within one instance. Its calls never block, so contexts
+// are ignored.
+type MemoryCache struct {
+	mu         sync.Mutex
+	maxEntries int // 0 keeps every key
+	entries    map[string]*list.Element
+	order      *list.List // most recently used first
+	now        func() time.Time
+}
+
+// memoryEntry is a cached value: a string, a list ([]string) or a sorted set (map[string]float64)
+type memoryEntry struct {
+	key       string
+	value     interface{}
+	expiresAt time.Time // zero never expires
+}
+
+// NewMemoryCache creates an in-memory cache holding up to maxEntries keys
+func NewMemoryCache(maxEntries int) *MemoryCache {
+	return &MemoryCache{
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+		now:        time.Now,
+	}
+}
+
+// lookup returns a live entry and marks it recently used, dropping it if expired
+func (c *MemoryCache) lookup(key string) *memoryEntry {
+	element, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	entry := element.Value.(*memoryEntry)
+	if !entry.expiresAt.IsZero() && !c.now().Before(entry.expiresAt) {
+		c.remove(element)
+		return nil
+	}
+	c.order.MoveToFront(element)
+	return entry
+}
+
+// store sets a key, expiring after ttl unless ttl is 0, and evicts the least recently
+// used keys beyond the limit
+func (c *MemoryCache) store(key string, value interface{}, ttl time.Duration) *memoryEntry {
+	entry := &memoryEntry{key: key, value: value}
+	if ttl > 0 {
+		entry.expiresAt = c.now().Add(ttl)
+	}
+
+	if element, ok := c.entries[key]; ok {
+		element.Value = entry
+		c.order.MoveToFront(element)
+	} else {
+		c.entries[key] = c.order.PushFront(entry)
+	}
+	for c.maxEntries > 0 && c.order.Len() > c.maxEntries {
+		c.remove(c.order.Back())
+	}
+	return entry
+}
+
+func (c *MemoryCache) remove(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*memoryEntry).key)
+}
+
+// Get retrieves a value from cache
+func (c *MemoryCache) Get(ctx context.Context, key string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := c.lookup(key)
+	if entry == nil {
+		return "", ErrCacheMiss
+	}
+	value, ok := entry.value.(string)
+	if !ok {
+		return "", errWrongType
+	}
+	return value, nil
+}
+
+// Set stores a value in cache with the default TTL
+func (c *MemoryCache) Set(ctx context.Context, key, value string) error {
+	return c.SetWithTTL(ctx, key, value, defaultCacheTTL)
+}
+
+// SetWithTTL stores a value in cache with custom TTL
+func (c *MemoryCache) SetWithTTL(ctx context.Context, key, value string, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.store(key, value, ttl)
+	return nil
+}
+
+// TTL returns the time left before a key expires: -2 if it does not exist and -1 if it
+// never expires, as Redis reports them
+func (c *MemoryCache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := c.lookup(key)
+	switch {
+	case entry == nil:
+		return -2, nil
+	case entry.expiresAt.IsZero():
+		return -1, nil
+	}
+	return entry.expiresAt.Sub(c.now()), nil
+}
+
+// Delete removes values from cache
+func (c *MemoryCache) Delete(ctx context.Context, keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range keys {
+		if element, ok := c.entries[key]; ok {
+			c.remove(element)
+		}
+	}
+	return nil
+}
+
+// Close releases nothing; it satisfies Cache
+func (c *MemoryCache) Close() error {
+	return nil
+}
+
+// Ping always succeeds
+func (c *MemoryCache) Ping(ctx context.Context) error {
+	return nil
+}
+
+// incr adds to a counter stored as a decimal string, keeping its expiry
+func incr(entry *memoryEntry, value int64) (int64, error) {
+	current, ok := entry.value.(string)
+	if !ok {
+		return 0, errWrongType
+	}
+	count, err := strconv.ParseInt(current, 10, 64)
+	if err != nil {
+		return 0, errors.New("ERR value is not an integer or out of range")
+	}
+	count += value
+	entry.value = strconv.FormatInt(count, 10)
+	return count, nil
+}
+
+// IncrByWithTTL atomically adds to a counter and refreshes its TTL
+func (c *MemoryCache) IncrByWithTTL(ctx context.Context, key string, value int64, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := c.lookup(key)
+	if entry == nil {
+		c.store(key, strconv.FormatInt(value, 10), ttl)
+		return nil
+	}
+	if _, err := incr(entry, value); err != nil {
+		return err
+	}
+	entry.expiresAt = c.now().Add(ttl)
+	return nil
+}
+
+// MGet retrieves several values at once; missing keys yield empty strings
+func (c *MemoryCache) MGet(ctx context.Context, keys ...string) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	result := make([]string, len(keys))
+	for i, key := range keys {
+		if entry := c.lookup(key); entry != nil {
+			result[i], _ = entry.value.(string)
+		}
+	}
+	return result, nil
+}
+
+// SetNX stores a value only if the key does not exist, reporting whether it was set
+func (c *MemoryCache) SetNX(ctx context.Context, key, value string, ttl time.Duration) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.lookup(key) != nil {
+		return false, nil
+	}
+	c.store(key, value, ttl)
+	return true, nil
+}
+
+// IncrWindow counts one hit in a fixed window of the given length, returning the count
+// so far and the time until the window resets
+func (c *MemoryCache) IncrWindow(ctx context.Context, key string, window time.Duration) (int64, time.Duration, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := c.lookup(key)
+	if entry == nil {
+		c.store(key, "1", window)
+		return 1, window, nil
+	}
+	count, err := incr(entry, 1)
+	if err != nil {
+		return 0, 0, err
+	}
+	if entry.expiresAt.IsZero() {
+		entry.expiresAt = c.now().Add(window)
+	}
+	return count, entry.expiresAt.Sub(c.now()), nil
+}
+
+// IncrExisting increments a counter that has already been set, reporting false without
+// creating it when the key does not exist
+func (c *MemoryCache) IncrExisting(ctx context.Context, key string) (int64, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := c.lookup(key)
+	if entry == nil {
+		return 0, false, nil
+	}
+	count, err := incr(entry, 1)
+	if err != nil {
+		return 0, false, err
+	}
+	return count, true, nil
+}
+
+// PushCapped prepends a value to a list, keeping only its newest maxLen entries
+func (c *MemoryCache) PushCapped(ctx context.Context, key, value string, maxLen int64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := c.lookup(key)
+	if entry == nil {
+		entry = c.store(key, []string(nil), 0)
+	}
+	values, ok := entry.value.([]string)
+	if !ok {
+		return errWrongType
+	}
+
+	values = append([]string{value}, values...)
+	if int64(len(values)) > maxLen {
+		values = values[:maxLen]
+	}
+	entry.value = values
+	return nil
+}
+
+// ListRange returns up to count entries from the head of a list
+func (c *MemoryCache) ListRange(ctx context.Context, key string, count int64) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := c.lookup(key)
+	if entry == nil {
+		return []string{}, nil
+	}
+	values, ok := entry.value.([]string)
+	if !ok {
+		return nil, errWrongType
+	}
+	if count > 0 && count < int64(len(values)) {
+		values = values[:count]
+	}
+	return append([]string{}, values...), nil
+}
+
+// sortedSet returns the sorted set at key, or nil if it does not exist
+func (c *MemoryCache) sortedSet(key string) (map[string]float64, error) {
+	entry := c.lookup(key)
+	if entry == nil {
+		return nil, nil
+	}
+	set, ok := entry.value.(map[string]float64)
+	if !ok {
+		return nil, errWrongType
+	}
+	return set, nil
+}
+
+// ZIncrBatch adds to the scores of several sorted set members at once, keeping only the
+// maxLen highest scores and refreshing the set's TTL
+func (c *MemoryCache) ZIncrBatch(ctx context.Context, key string, increments map[string]float64, maxLen int64, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	set, err := c.sortedSet(key)
+	if err != nil {
+		return err
+	}
+	if set == nil {
+		set = make(map[string]float64, len(increments))
+	}
+	for member, increment := range increments {
+		set[member] += increment
+	}
+
+	members := rankMembers(set)
+	for _, member := range members[min(int64(len(members)), max(maxLen, 0)):] {
+		delete(set, member.Member)
+	}
+	c.store(key, set, ttl)
+	return nil
+}
+
+// ZMergeInto adds the scores of src, multiplied by weight, to the sorted set dst
+func (c *MemoryCache) ZMergeInto(ctx context.Context, dst, src string, weight float64, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	dstSet, err := c.sortedSet(dst)
+	if err != nil {
+		return err
+	}
+	srcSet, err := c.sortedSet(src)
+	if err != nil {
+		return err
+	}
+
+	merged := make(map[string]float64, len(dstSet)+len(srcSet))
+	for member, score := range dstSet {
+		merged[member] = score
+	}
+	for member, score := range srcSet {
+		merged[member] += score * weight
+	}
+	if len(merged) == 0 {
+		// Like ZUNIONSTORE, an empty result leaves no key behind
+		if element, ok := c.entries[dst]; ok {
+			c.remove(element)
+		}
+		return nil
+	}
+	c.store(dst, merged, ttl)
+	return nil
+}
+
+// ZTop returns the count highest scored members of a sorted set, highest first
+func (c *MemoryCache) ZTop(ctx context.Context, key string, count int64) ([]ScoredMember, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	set, err := c.sortedSet(key)
+	if err != nil {
+		return nil, err
+	}
+	members := rankMembers(set)
+	if count > 0 && count < int64(len(members)) {
+		members = members[:count]
+	}
+	return members, nil
+}
+
+// ZRem removes a member from several sorted sets
+func (c *MemoryCache) ZRem(ctx context.Context, member string, keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range keys {
+		set, err := c.sortedSet(key)
+		if err != nil {
+			return err
+		}
+		delete(set, member)
+		if set != nil && len(set) == 0 {
+			c.remove(c.entries[key])
+		}
+	}
+	return nil
+}
+
+// rankMembers orders a sorted set highest score first, ties in reverse member order as
+// ZREVRANGE does
+func rankMembers(set map[string]float64) []ScoredMember {
+	members := make([]ScoredMember, 0, len(set))
+	for member, score := range set {
+		members = append(members, ScoredMember{Member: member, Score: score})
+	}
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].Score != members[j].Score {
+			return members[i].Score > members[j].Score
+		}
+		return members[i].Member > members[j].Member
+	})
+	return members
+}
@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	cache := NewMemoryCache(2)
+	cache.Set(ctx, "a", "1")
+	cache.Set(ctx, "b", "2")
+	cache.Get(ctx, "a")
+	cache.Set(ctx, "c", "3")
+
+	testCases := []struct {
+		key   string
+		value string
+		found bool
+	}{
+		{"a", "1", true},
+		{"b", "", false},
+		{"c", "3", true},
+	}
+
+	for _, tc := range testCases {
+		value, err := cache.Get(ctx, tc.key)
+		if (err == nil) != tc.found || value != tc.value {
+			t.Errorf("Get(%q) = %q, %v; expected %q, found = %v", tc.key, value, err, tc.value, tc.found)
+		}
+	}
+}
+
+func TestMemoryCacheExpiry(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(0, 0)
+	cache := NewMemoryCache(0)
+	cache.now = func() time.Time { return now }
+
+	cache.SetWithTTL(ctx, "link", "https://example.com", time.Minute)
+	if ttl, _ := cache.TTL(ctx, "link"); ttl != time.Minute {
+		t.Errorf("TTL = %s; expected 1m", ttl)
+	}
+
+	cache.IncrWindow(ctx, "hits", time.Minute)
+	now = now.Add(40 * time.Second)
+	count, resetIn, _ := cache.IncrWindow(ctx, "hits", time.Minute)
+	if count != 2 || resetIn != 20*time.Second {
+		t.Errorf("IncrWindow = %d, %s; expected 2, 20s", count, resetIn)
+	}
+
+	now = now.Add(20 * time.Second)
+	if _, err := cache.Get(ctx, "link"); err != ErrCacheMiss {
+		t.Errorf("Get of an expired key = %v; expected a miss", err)
+	}
+	if ttl, _ := cache.TTL(ctx, "link"); ttl != -2 {
+		t.Errorf("TTL of an expired key = %s; expected -2ns", ttl)
+	}
+	if _, ok, _ := cache.IncrExisting(ctx, "hits"); ok {
+		t.Error("IncrExisting should not revive an expired counter")
+	}
+}
+
+func TestMemoryCacheSortedSet(t *testing.T) {
+	ctx := context.Background()
+	cache := NewMemoryCache(0)
+	cache.ZIncrBatch(ctx, "top", map[string]float64{"a": 3, "b": 1, "c": 2}, 2, time.Hour)
+	cache.ZIncrBatch(ctx, "old", map[string]float64{"b": 10, "d": 4}, 10, time.Hour)
+	cache.ZMergeInto(ctx, "top", "old", 0.5, time.Hour)
+	cache.ZRem(ctx, "a", "top", "old")
+
+	top, err := cache.ZTop(ctx, "top", 10)
+	if err != nil {
+		t.Fatalf("ZTop: %v", err)
+	}
+	expected := []ScoredMember{{"b", 5}, {"d", 2}, {"c", 2}}
+	if len(top) != len(expected) {
+		t.Fatalf("ZTop = %v; expected %v", top, expected)
+	}
+	for i := range expected {
+		if top[i] != expected[i] {
+			t.Errorf("ZTop[%d] = %v; expected %v", i, top[i], expected[i])
+		}
+	}
+}
+
+func TestLayeredCacheInvalidatesLocalCopy(t *testing.T) {
+	ctx := context.Background()
+	shared := NewMemoryCache(0)
+	cache := NewLayeredCache(shared, 10, time.Minute)
+
+	shared.Set(ctx, "abc", "https://example.com/old")
+	cache.Get(ctx, "abc")
+	shared.Set(ctx, "abc", "https://example.com/new")
+	if value, _ := cache.Get(ctx, "abc"); value != "https://example.com/old" {
+		t.Errorf("Get = %q; expected the local copy", value)
+	}
+
+	cache.Delete(ctx, "abc")
+	if _, err := cache.Get(ctx, "abc"); err != ErrCacheMiss {
+		t.Errorf("Get after Delete = %v; expected a miss", err)
+	}
+
+	cache.Set(ctx, "n", "1")
+	cache.IncrExisting(ctx, "n")
+	if value, _ := cache.Get(ctx, "n"); value != "2" {
+		t.Errorf("Get after IncrExisting = %q; expected 2", value)
+	}
+}
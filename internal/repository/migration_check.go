@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// MigrationFinding describes a potentially unsafe pending migration statement
+type MigrationFinding struct {
+	Statement string
+	Severity  string
+	Message   string
+}
+
+var (
+	whitespaceRe    = regexp.MustCompile(`\s+`)
+	createTableRe   = regexp.MustCompile(`^CREATE TABLE IF NOT EXISTS (\w+)`)
+	createSeqRe     = regexp.MustCompile(`^CREATE SEQUENCE IF NOT EXISTS (\w+)`)
+	createIndexRe   = regexp.MustCompile(`^CREATE (?:UNIQUE )?INDEX (CONCURRENTLY )?(?:IF NOT EXISTS (\w+) )?.*?ON (\w+)`)
+	alterTableRe    = regexp.MustCompile(`^ALTER TABLE (?:IF EXISTS )?(\w+) (.*)$`)
+	addColumnRe     = regexp.MustCompile(`ADD COLUMN IF NOT EXISTS (\w+)`)
+	volatileDefault = regexp.MustCompile(`DEFAULT (NOW|CLOCK_TIMESTAMP|RANDOM|GEN_RANDOM_UUID|UUID_GENERATE_V4)\(`)
+)
+
+// CheckMigrations is the pre-flight for booting against a live database. It works out
+// which idempotent migrations would actually execute and flags the ones that take
+// long locks or rewrite tables while production traffic is running.
+func CheckMigrations(db *sql.DB) (pending int, findings []MigrationFinding, err error) {
+	for _, migration := range migrations {
+		stmt := strings.TrimSpace(whitespaceRe.ReplaceAllString(migration, " "))
+		upper := strings.ToUpper(stmt)
+
+		applied, err := migrationApplied(db, upper)
+		if err != nil {
+			return 0, nil, err
+		}
+		if applied {
+			continue
+		}
+
+		pending++
+		findings = append(findings, lintStatement(db, stmt, upper)...)
+	}
+
+	return pending, findings, nil
+}
+
+// schemaQuerier is a database or a single connection the schema can be inspected through
+type schemaQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// migrationApplied reports whether an IF NOT EXISTS statement would be a no-op
+func migrationApplied(db schemaQuerier, upper string) (bool, error) {
+	if m := createTableRe.FindStringSubmatch(upper); m != nil {
+		return relationExists(db, m[1])
+	}
+	if m := createSeqRe.FindStringSubmatch(upper); m != nil {
+		return relationExists(db, m[1])
+	}
+	if m := createIndexRe.FindStringSubmatch(upper); m != nil && m[2] != "" {
+		return relationExists(db, m[2])
+	}
+	if m := alterTableRe.FindStringSubmatch(upper); m != nil {
+		if c := addColumnRe.FindStringSubmatch(m[2]); c != nil {
+			return columnExists(db, m[1], c[1])
+		}
+	}
+	return false, nil
+}
+
+// lintStatement flags locking or rewriting operations on tables that already exist
+func lintStatement(db *sql.DB, stmt, upper string) []MigrationFinding {
+	var findings []MigrationFinding
+	add := func(severity, message string) {
+		findings = append(findings, MigrationFinding{Statement: stmt, Severity: severity, Message: message})
+	}
+
+	if m := createIndexRe.FindStringSubmatch(upper); m != nil {
+		if m[1] == "" {
+			if exists, _ := relationExists(db, m[3]); exists {
+				add(SeverityError, "index is built without CONCURRENTLY and blocks writes to "+strings.ToLower(m[3]))
+			}
+		}
+		return findings
+	}
+
+	m := alterTableRe.FindStringSubmatch(upper)
+	if m == nil {
+		return findings
+	}
+	action := m[2]
+
+	switch {
+	case strings.Contains(action, "ALTER COLUMN") && strings.Contains(action, " TYPE "):
+		add(SeverityError, "changing a column type rewrites the table under an ACCESS EXCLUSIVE lock")
+	case strings.Contains(action, "ADD COLUMN") && strings.Contains(action, "NOT NULL") && !strings.Contains(action, "DEFAULT"):
+		add(SeverityError, "adding a NOT NULL column without a default fails on non-empty tables")
+	case strings.Contains(action, "ADD COLUMN") && volatileDefault.MatchString(action):
+		add(SeverityError, "adding a column with a volatile default rewrites the table")
+	case strings.Contains(action, "ADD CONSTRAINT") && !strings.Contains(action, "NOT VALID"):
+		add(SeverityWarning, "constraint is validated under lock; add it NOT VALID and VALIDATE separately")
+	case strings.Contains(action, "DROP COLUMN"), strings.Contains(action, "DROP CONSTRAINT"):
+		add(SeverityWarning, "destructive change; old application versions may still depend on it")
+	default:
+		add(SeverityWarning, "takes an ACCESS EXCLUSIVE lock on "+strings.ToLower(m[1])+"; bounded by lock_timeout")
+	}
+
+	return findings
+}
+
+func relationExists(db schemaQuerier, name string) (bool, error) {
+	var exists bool
+	err := db.QueryRowContext(context.Background(), `SELECT to_regclass($1) IS NOT NULL`, strings.ToLower(name)).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to inspect schema: %w", err)
+	}
+	return exists, nil
+}
+
+func columnExists(db schemaQuerier, table, column string) (bool, error) {
+	var exists bool
+	query := `
+		SELECT EXISTS(
+			SELECT 1 FROM information_schema.columns
+			WHERE table_name = $1 AND column_name = $2
+		)`
+	err := db.QueryRowContext(context.Background(), query, strings.ToLower(table), strings.ToLower(column)).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to inspect schema: %w", err)
+	}
+	return exists, nil
+}
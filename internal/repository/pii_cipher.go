@@ -0,0 +1,354 @@
+package repository
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// piiFormatVersion prefixes every ciphertext so the format can evolve
+const piiFormatVersion = 1
+
+// KeyWrapper protects data encryption keys with key encryption keys. The local
+// implementation holds the keys from configuration; a KMS client can implement
+// the same interface so key material never leaves it.
+type KeyWrapper interface {
+	ActiveKeyID() string
+	Wrap(plaintext []byte) (keyID string, wrapped []byte, err error)
+	Unwrap(keyID string, wrapped []byte) ([]byte, error)
+}
+
+// LocalKeyWrapper wraps data keys with AES-256-GCM key encryption keys from configuration
+type LocalKeyWrapper struct {
+	keys   map[string]cipher.AEAD
+	active string
+}
+
+// ParseKeyring parses "id:base64key,id:base64key" into 32-byte keys
+func ParseKeyring(spec string) (map[string][]byte, error) {
+	keys := make(map[string][]byte)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("key entry must look like id:base64key")
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("key %s is not valid base64: %w", id, err)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("key %s must be 32 bytes, got %d", id, len(key))
+		}
+		keys[id] = key
+	}
+	return keys, nil
+}
+
+func NewLocalKeyWrapper(keys map[string][]byte, active string) (*LocalKeyWrapper, error) {
+	if _, ok := keys[active]; !ok {
+		return nil, fmt.Errorf("active key %q is not in the keyring", active)
+	}
+
+	wrapper := &LocalKeyWrapper{keys: make(map[string]cipher.AEAD, len(keys)), active: active}
+	for id, key := range keys {
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+		wrapper.keys[id] = aead
+	}
+	return wrapper, nil
+}
+
+func (w *LocalKeyWrapper) ActiveKeyID() string {
+	return w.active
+}
+
+func (w *LocalKeyWrapper) Wrap(plaintext []byte) (string, []byte, error) {
+	wrapped, err := seal(w.keys[w.active], plaintext, []byte(w.active))
+	return w.active, wrapped, err
+}
+
+func (w *LocalKeyWrapper) Unwrap(keyID string, wrapped []byte) ([]byte, error) {
+	aead, ok := w.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("key encryption key %q is not configured", keyID)
+	}
+	return open(aead, wrapped, []byte(keyID))
+}
+
+// PIICipher encrypts personal data columns with envelope encryption. Data keys live in
+// the data_keys table, wrapped by a key encryption key; ciphertexts name the data key
+// they were sealed with, so old keys keep decrypting after a rotation.
+type PIICipher struct {
+	db       *sql.DB
+	wrapper  KeyWrapper
+	indexKey []byte
+
+	mu     sync.RWMutex
+	keys   map[int32]cipher.AEAD
+	active int32
+}
+
+// NewPIICipher loads the data keys, creating the first one on a fresh database. The
+// index key computes blind indexes that allow equality lookups on encrypted values.
+func NewPIICipher(db *sql.DB, wrapper KeyWrapper, indexKey []byte) (*PIICipher, error) {
+	if len(indexKey) < 32 {
+		return nil, fmt.Errorf("blind index key must be at least 32 bytes")
+	}
+
+	c := &PIICipher{db: db, wrapper: wrapper, indexKey: indexKey}
+	if err := c.Reload(); err != nil {
+		return nil, err
+	}
+	if c.ActiveKeyID() == 0 {
+		if _, err := c.createDataKey(); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// NewPIICipherFromConfig builds a cipher backed by the local keyring, or returns nil when
+// no keyring is configured and personal data is stored in plaintext
+func NewPIICipherFromConfig(db *sql.DB, keyring, activeKey, indexKey string) (*PIICipher, error) {
+	if keyring == "" {
+		return nil, nil
+	}
+
+	keys, err := ParseKeyring(keyring)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PII encryption keyring: %w", err)
+	}
+	wrapper, err := NewLocalKeyWrapper(keys, activeKey)
+	if err != nil {
+		return nil, err
+	}
+	return NewPIICipher(db, wrapper, []byte(indexKey))
+}
+
+// ActiveKeyID returns the id of the data key new values are encrypted with
+func (c *PIICipher) ActiveKeyID() int32 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.active
+}
+
+// Reload reads data keys created or rotated by other instances
+func (c *PIICipher) Reload() error {
+	rows, err := c.db.Query(`SELECT id, kek_id, wrapped_key, retired_at IS NULL FROM data_keys ORDER BY id`)
+	if err != nil {
+		return fmt.Errorf("failed to load data keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := make(map[int32]cipher.AEAD)
+	var active int32
+	for rows.Next() {
+		var id int32
+		var kekID string
+		var wrapped []byte
+		var current bool
+		if err := rows.Scan(&id, &kekID, &wrapped, &current); err != nil {
+			return err
+		}
+
+		key, err := c.wrapper.Unwrap(kekID, wrapped)
+		if err != nil {
+			return fmt.Errorf("failed to unwrap data key %d: %w", id, err)
+		}
+		if keys[id], err = newAEAD(key); err != nil {
+			return err
+		}
+		if current {
+			active = id
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.keys, c.active = keys, active
+	c.mu.Unlock()
+	return nil
+}
+
+// Rotate re-wraps every data key with the active key encryption key, then retires the
+// current data key in favour of a new one. Existing values still decrypt; re-encrypting
+// them under the new key is a separate batch job.
+func (c *PIICipher) Rotate() (int32, error) {
+	rows, err := c.db.Query(`SELECT id, kek_id, wrapped_key FROM data_keys WHERE kek_id <> $1`, c.wrapper.ActiveKeyID())
+	if err != nil {
+		return 0, fmt.Errorf("failed to load data keys: %w", err)
+	}
+	type rewrap struct {
+		id      int32
+		kekID   string
+		wrapped []byte
+	}
+	var stale []rewrap
+	for rows.Next() {
+		var r rewrap
+		if err := rows.Scan(&r.id, &r.kekID, &r.wrapped); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		stale = append(stale, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, r := range stale {
+		key, err := c.wrapper.Unwrap(r.kekID, r.wrapped)
+		if err != nil {
+			return 0, fmt.Errorf("failed to unwrap data key %d: %w", r.id, err)
+		}
+		kekID, wrapped, err := c.wrapper.Wrap(key)
+		if err != nil {
+			return 0, fmt.Errorf("failed to wrap data key %d: %w", r.id, err)
+		}
+		if _, err := c.db.Exec(`UPDATE data_keys SET kek_id = $1, wrapped_key = $2 WHERE id = $3`, kekID, wrapped, r.id); err != nil {
+			return 0, fmt.Errorf("failed to store data key %d: %w", r.id, err)
+		}
+	}
+
+	return c.createDataKey()
+}
+
+// createDataKey generates a data key, retires the previous one and makes it active
+func (c *PIICipher) createDataKey() (int32, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return 0, err
+	}
+	kekID, wrapped, err := c.wrapper.Wrap(key)
+	if err != nil {
+		return 0, fmt.Errorf("failed to wrap data key: %w", err)
+	}
+
+	tx, err := c.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE data_keys SET retired_at = CURRENT_TIMESTAMP WHERE retired_at IS NULL`); err != nil {
+		return 0, fmt.Errorf("failed to retire data key: %w", err)
+	}
+	var id int32
+	err = tx.QueryRow(`INSERT INTO data_keys (kek_id, wrapped_key) VALUES ($1, $2) RETURNING id`, kekID, wrapped).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("failed to store data key: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return id, c.Reload()
+}
+
+// Encrypt seals a value under the active data key
+func (c *PIICipher) Encrypt(plaintext string) ([]byte, error) {
+	c.mu.RLock()
+	id, aead := c.active, c.keys[c.active]
+	c.mu.RUnlock()
+	if aead == nil {
+		return nil, errors.New("no active data key")
+	}
+
+	header := make([]byte, 5)
+	header[0] = piiFormatVersion
+	binary.BigEndian.PutUint32(header[1:], uint32(id))
+
+	sealed, err := seal(aead, []byte(plaintext), header)
+	if err != nil {
+		return nil, err
+	}
+	return append(header, sealed...), nil
+}
+
+// Decrypt opens a value sealed by Encrypt with any known data key
+func (c *PIICipher) Decrypt(data []byte) (string, error) {
+	id, err := KeyIDOf(data)
+	if err != nil {
+		return "", err
+	}
+
+	c.mu.RLock()
+	aead := c.keys[id]
+	c.mu.RUnlock()
+	if aead == nil {
+		// The key may have been created by another instance since the last reload
+		if err := c.Reload(); err != nil {
+			return "", err
+		}
+		c.mu.RLock()
+		aead = c.keys[id]
+		c.mu.RUnlock()
+		if aead == nil {
+			return "", fmt.Errorf("unknown data key %d", id)
+		}
+	}
+
+	plaintext, err := open(aead, data[5:], data[:5])
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// BlindIndex returns a keyed hash of a value for equality lookups on encrypted columns
+func (c *PIICipher) BlindIndex(value string) []byte {
+	mac := hmac.New(sha256.New, c.indexKey)
+	mac.Write([]byte(value))
+	return mac.Sum(nil)
+}
+
+// KeyIDOf returns the data key a ciphertext was sealed with
+func KeyIDOf(data []byte) (int32, error) {
+	if len(data) < 5 || data[0] != piiFormatVersion {
+		return 0, errors.New("unsupported ciphertext format")
+	}
+	return int32(binary.BigEndian.Uint32(data[1:5])), nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts with a random nonce prepended to the ciphertext
+func seal(aead cipher.AEAD, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(aead cipher.AEAD, data, additionalData []byte) ([]byte, error) {
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
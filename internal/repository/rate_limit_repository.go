@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/alexnthnz/url-shortener/internal/models"
+)
+
+// RateLimitRepository stores per-API-key rate limit overrides
+type RateLimitRepository struct {
+	db *sql.DB
+}
+
+func NewRateLimitRepository(db *sql.DB) *RateLimitRepository {
+	return &RateLimitRepository{db: db}
+}
+
+// List returns every override ordered by key id
+func (r *RateLimitRepository) List() ([]*models.RateLimitOverride, error) {
+	rows, err := r.db.Query(`SELECT key_id, requests_per_window, updated_at FROM rate_limit_overrides ORDER BY key_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var overrides []*models.RateLimitOverride
+	for rows.Next() {
+		override := &models.RateLimitOverride{}
+		if err := rows.Scan(&override.KeyID, &override.RequestsPerWindow, &override.UpdatedAt); err != nil {
+			return nil, err
+		}
+		overrides = append(overrides, override)
+	}
+	return overrides, rows.Err()
+}
+
+// Upsert sets the limit of a key, replacing any previous override
+func (r *RateLimitRepository) Upsert(override *models.RateLimitOverride) error {
+	query := `
+		INSERT INTO rate_limit_overrides (key_id, requests_per_window)
+		VALUES ($1, $2)
+		ON CONFLICT (key_id) DO UPDATE SET requests_per_window = EXCLUDED.requests_per_window, updated_at = CURRENT_TIMESTAMP
+		RETURNING updated_at`
+
+	return r.db.QueryRow(query, override.KeyID, override.RequestsPerWindow).Scan(&override.UpdatedAt)
+}
+
+// Delete removes the override of a key, reporting whether one existed
+func (r *RateLimitRepository) Delete(keyID string) (bool, error) {
+	result, err := r.db.Exec(`DELETE FROM rate_limit_overrides WHERE key_id = $1`, keyID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
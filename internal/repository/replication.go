@@ -0,0 +1,23 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// replicationLagQuery reports the standbys streaming from the primary and the replay lag
+// of the slowest one. The lag columns read NULL without the pg_monitor role.
+const replicationLagQuery = `
+	SELECT COUNT(*), COALESCE(EXTRACT(EPOCH FROM MAX(replay_lag)), 0)
+	FROM pg_stat_replication`
+
+// ReplicationLag returns how many standbys replicate from the database and how far the
+// slowest of them is behind in replaying the primary's changes
+func ReplicationLag(db *sql.DB) (standbys int, lag time.Duration, err error) {
+	var seconds float64
+	if err := db.QueryRow(replicationLagQuery).Scan(&standbys, &seconds); err != nil {
+		return 0, 0, fmt.Errorf("failed to read replication status: %w", err)
+	}
+	return standbys, time.Duration(seconds * float64(time.Second)), nil
+}
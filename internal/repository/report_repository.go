@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/alexnthnz/url-shortener/internal/models"
+)
+
+// ReportRepository stores generated campaign reports
+type ReportRepository struct {
+	db *sql.DB
+}
+
+func NewReportRepository(db *sql.DB) *ReportRepository {
+	return &ReportRepository{db: db}
+}
+
+// Save stores a report, replacing the one of the same campaign and period if it was
+// generated before
+func (r *ReportRepository) Save(report *models.Report) error {
+	query := `
+		INSERT INTO reports (campaign, period, period_start, period_end, links, clicks, unique_visitors, html)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (campaign, period, period_start) DO UPDATE SET
+			period_end = EXCLUDED.period_end,
+			links = EXCLUDED.links,
+			clicks = EXCLUDED.clicks,
+			unique_visitors = EXCLUDED.unique_visitors,
+			html = EXCLUDED.html,
+			created_at = CURRENT_TIMESTAMP
+		RETURNING id, created_at`
+
+	return r.db.QueryRow(query, report.Campaign, report.Period, report.PeriodStart, report.PeriodEnd,
+		report.Links, report.Clicks, report.UniqueVisitors, report.HTML).Scan(&report.ID, &report.CreatedAt)
+}
+
+// Get returns a report with its HTML, or nil when it does not exist
+func (r *ReportRepository) Get(id int64) (*models.Report, error) {
+	query := `
+		SELECT id, campaign, period, period_start, period_end, links, clicks, unique_visitors, created_at, html
+		FROM reports
+		WHERE id = $1`
+
+	report := &models.Report{}
+	err := r.db.QueryRow(query, id).Scan(&report.ID, &report.Campaign, &report.Period, &report.PeriodStart,
+		&report.PeriodEnd, &report.Links, &report.Clicks, &report.UniqueVisitors, &report.CreatedAt, &report.HTML)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return report, err
+}
+
+// List returns up to limit reports without their HTML, newest period first, of one
+// campaign or of all when campaign is empty
+func (r *ReportRepository) List(campaign string, limit int) ([]*models.Report, error) {
+	query := `
+		SELECT id, campaign, period, period_start, period_end, links, clicks, unique_visitors, created_at
+		FROM reports
+		WHERE $1 = '' OR campaign = $1
+		ORDER BY period_start DESC, id DESC
+		LIMIT $2`
+
+	rows, err := r.db.Query(query, campaign, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reports []*models.Report
+	for rows.Next() {
+		report := &models.Report{}
+		if err := rows.Scan(&report.ID, &report.Campaign, &report.Period, &report.PeriodStart,
+			&report.PeriodEnd, &report.Links, &report.Clicks, &report.UniqueVisitors, &report.CreatedAt); err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+	return reports, rows.Err()
+}
@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/alexnthnz/url-shortener/internal/models"
+)
+
+// SigningKeyRepository stores when and from where each request signing key was last used;
+// the keys themselves are configured, not stored
+type SigningKeyRepository struct {
+	db *sql.DB
+}
+
+func NewSigningKeyRepository(db *sql.DB) *SigningKeyRepository {
+	return &SigningKeyRepository{db: db}
+}
+
+// ListUsage returns the last use of every key used so far, by key id
+func (r *SigningKeyRepository) ListUsage() (map[string]*models.SigningKeyUsage, error) {
+	rows, err := r.db.Query(`SELECT key_id, last_used_at, last_used_ip FROM signing_key_usage`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	usage := make(map[string]*models.SigningKeyUsage)
+	for rows.Next() {
+		use := &models.SigningKeyUsage{}
+		if err := rows.Scan(&use.KeyID, &use.LastUsedAt, &use.LastUsedIP); err != nil {
+			return nil, err
+		}
+		usage[use.KeyID] = use
+	}
+	return usage, rows.Err()
+}
+
+// RecordUse sets the last use of a key, keeping a later use recorded by another instance
+func (r *SigningKeyRepository) RecordUse(keyID, ip string, at time.Time) error {
+	_, err := r.db.Exec(`
+		INSERT INTO signing_key_usage (key_id, last_used_at, last_used_ip)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (key_id) DO UPDATE SET last_used_at = EXCLUDED.last_used_at, last_used_ip = EXCLUDED.last_used_ip
+		WHERE signing_key_usage.last_used_at < EXCLUDED.last_used_at`,
+		keyID, at, ip)
+	return err
+}
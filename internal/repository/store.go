@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/alexnthnz/url-shortener/internal/models"
+)
+
+// URLStore stores links, their settings and click counters. URLRepository implements it
+// on PostgreSQL; services depend on the interface so another backend can be plugged in.
+type URLStore interface {
+	// Create returns an error wrapping ErrDuplicate when the short code is taken
+	Create(ctx context.Context, url *models.URL) error
+	GetByShortCode(ctx context.Context, shortCode string) (*models.URL, error)
+	FindReusable(ctx context.Context, originalURL string) (*models.URL, error)
+	GetShortCodeByNumericCode(ctx context.Context, numericCode string) (string, error)
+	Exists(ctx context.Context, shortCode string) (bool, error)
+	FilterExisting(ctx context.Context, codes []string) ([]string, error)
+	SetDisabled(ctx context.Context, shortCode string, disabled bool, reason string) (bool, error)
+	GetClicksUsed(ctx context.Context, shortCode string) (int64, error)
+	ConsumeClick(ctx context.Context, shortCode string) (bool, error)
+	SyncClicksUsed(ctx context.Context, counts map[string]int64) error
+	GetNextID(ctx context.Context) (int64, error)
+	GetCurrentID(ctx context.Context) (int64, error)
+	GetCodeSpaceUsage(ctx context.Context, since time.Time) ([]models.CodeSpaceUsage, error)
+	GetStats(ctx context.Context, shortCode string) (*models.URLStats, error)
+	UpdateOriginalURL(ctx context.Context, shortCode, newURL, changedBy string) (*models.URLHistoryEntry, error)
+	ListHistory(ctx context.Context, shortCode string, limit int) ([]*models.URLHistoryEntry, error)
+	HealthCheck(ctx context.Context) (bool, error)
+	GetDailyCreated(ctx context.Context, since time.Time) ([]models.DailyCount, error)
+	GetTopDomains(ctx context.Context, since time.Time, limit int) ([]models.DimensionCount, error)
+	GetDomainStats(ctx context.Context, since time.Time) ([]models.DomainStats, error)
+	ListCodesByDomains(ctx context.Context, domains []string, limit int) (map[string][]string, error)
+	GetRedirectRules(ctx context.Context, shortCode string) ([]models.RedirectRule, bool, error)
+	SetRedirectRules(ctx context.Context, shortCode string, rules []models.RedirectRule) (bool, error)
+	GetLandingPage(ctx context.Context, shortCode string) (*models.LandingPage, bool, error)
+	SetLandingPage(ctx context.Context, shortCode string, page *models.LandingPage) (bool, error)
+	GetUTMParams(ctx context.Context, shortCode string) (*models.UTMParams, error)
+	SetUTMParams(ctx context.Context, shortCode string, utm *models.UTMParams) error
+	ListCampaigns(ctx context.Context) ([]string, error)
+	GetCampaignLinks(ctx context.Context, campaign string, limit int) ([]models.CampaignLink, error)
+	Delete(ctx context.Context, shortCode string) ([]string, bool, error)
+	ListDestinations(ctx context.Context, afterID int64, limit int) ([]models.LinkDestinations, error)
+	GetTotals(ctx context.Context) (*models.InstanceTotals, error)
+	GetTopLinks(ctx context.Context, since time.Time, limit int) ([]models.LinkSummary, error)
+	ListRecent(ctx context.Context, limit int) ([]models.LinkSummary, error)
+}
+
+// AnalyticsStore stores recorded clicks. AnalyticsRepository implements it on PostgreSQL,
+// for the primary database as well as for an analytics mirror.
+type AnalyticsStore interface {
+	RecordClick(ctx context.Context, analytics *models.Analytics) error
+	GetVisitorClicks(ctx context.Context, visitorID string, limit int) ([]models.Touchpoint, error)
+	GetClickCount(ctx context.Context, shortCode string) (int64, error)
+	GetClickCountBetween(ctx context.Context, shortCode string, from, to time.Time) (int64, error)
+	GetDailyClicks(ctx context.Context, shortCode string, since time.Time) ([]models.DailyClicks, error)
+	GetClickBuckets(ctx context.Context, shortCode, precision string, from, to time.Time, includeInternal bool) ([]models.ClickBucket, error)
+	GetClickSummary(ctx context.Context, shortCode string, from, to time.Time, includeInternal bool) (models.ClickSummary, error)
+	GetVariantCounts(ctx context.Context, shortCode string, from, to time.Time, includeInternal bool) ([]models.VariantStats, error)
+	GetLinksClickSummary(ctx context.Context, shortCodes []string, from, to time.Time) (models.ClickSummary, error)
+	GetLinksClickBuckets(ctx context.Context, shortCodes []string, precision string, from, to time.Time) ([]models.ClickBucket, error)
+	GetUserAgentCounts(ctx context.Context, shortCode string, from, to time.Time, includeInternal bool) (map[string]int64, error)
+	GetReferrerCounts(ctx context.Context, shortCode string, from, to time.Time, includeInternal bool) (map[string]int64, error)
+	GetClientCounts(ctx context.Context, shortCode string, from, to time.Time, includeInternal bool) (devices, browsers, systems map[string]int64, err error)
+	GetDailyRedirects(ctx context.Context, since time.Time) ([]models.DailyCount, error)
+	DeleteOlderThan(ctx context.Context, cutoff time.Time, limit int) (int64, error)
+	MaxClickID(ctx context.Context) (int64, error)
+	ListClicksAfter(ctx context.Context, afterID, maxID int64, limit int) ([]*models.Analytics, error)
+	ListBySubject(ctx context.Context, subject models.DataSubject, afterID int64, limit int) ([]*models.Analytics, error)
+	AnonymizeByIDs(ctx context.Context, ids []int64) (int64, error)
+	ReencryptBatch(ctx context.Context, afterID int64, limit int) (int64, int64, error)
+	ListUnparsed(ctx context.Context, afterID int64, limit int) ([]*models.Analytics, error)
+	SetClientInfo(ctx context.Context, clicks []*models.Analytics) error
+	CopyClicks(ctx context.Context, clicks []*models.Analytics) (int64, error)
+}
@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/alexnthnz/url-shortener/internal/models"
+	"github.com/lib/pq"
+)
+
+// TakedownRepository stores takedown requests and their resolution
+type TakedownRepository struct {
+	db *sql.DB
+}
+
+func NewTakedownRepository(db *sql.DB) *TakedownRepository {
+	return &TakedownRepository{db: db}
+}
+
+const takedownColumns = `id, short_code, reason, reporter_name, reporter_email, description, evidence_urls,
+	status, resolution_note, COALESCE(resolved_by, ''), created_at, resolved_at`
+
+// Create stores a new pending takedown request
+func (r *TakedownRepository) Create(takedown *models.TakedownRequest) error {
+	query := `
+		INSERT INTO takedown_requests (short_code, reason, reporter_name, reporter_email, description, evidence_urls)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, status, created_at`
+
+	return r.db.QueryRow(
+		query,
+		takedown.ShortCode,
+		takedown.Reason,
+		takedown.ReporterName,
+		takedown.ReporterEmail,
+		takedown.Description,
+		pq.Array(takedown.EvidenceURLs),
+	).Scan(&takedown.ID, &takedown.Status, &takedown.CreatedAt)
+}
+
+// Get returns a takedown request, or nil when it does not exist
+func (r *TakedownRepository) Get(id int64) (*models.TakedownRequest, error) {
+	rows, err := r.db.Query(`SELECT `+takedownColumns+` FROM takedown_requests WHERE id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	takedowns, err := scanTakedowns(rows)
+	if err != nil || len(takedowns) == 0 {
+		return nil, err
+	}
+	return takedowns[0], nil
+}
+
+// List returns up to limit takedown requests, oldest first, optionally only those with a status
+func (r *TakedownRepository) List(status string, limit int) ([]*models.TakedownRequest, error) {
+	query := `
+		SELECT ` + takedownColumns + `
+		FROM takedown_requests
+		WHERE $1 = '' OR status = $1
+		ORDER BY created_at, id
+		LIMIT $2`
+
+	rows, err := r.db.Query(query, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanTakedowns(rows)
+}
+
+// Resolve records the decision on a pending takedown request. It returns nil when the
+// request does not exist or was already resolved.
+func (r *TakedownRepository) Resolve(id int64, status, note, resolvedBy string) (*models.TakedownRequest, error) {
+	query := `
+		UPDATE takedown_requests
+		SET status = $2, resolution_note = $3, resolved_by = $4, resolved_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND status = 'pending'
+		RETURNING ` + takedownColumns
+
+	rows, err := r.db.Query(query, id, status, note, resolvedBy)
+	if err != nil {
+		return nil, err
+	}
+	takedowns, err := scanTakedowns(rows)
+	if err != nil || len(takedowns) == 0 {
+		return nil, err
+	}
+	return takedowns[0], nil
+}
+
+// CountOpen counts the other takedown requests against a link that keep it disabled: the
+// upheld ones, and the pending ones when pending requests disable links
+func (r *TakedownRepository) CountOpen(shortCode string, excludeID int64, includePending bool) (int64, error) {
+	query := `
+		SELECT COUNT(*) FROM takedown_requests
+		WHERE short_code = $1 AND id <> $2
+			AND (status = 'upheld' OR ($3 AND status = 'pending'))`
+
+	var count int64
+	err := r.db.QueryRow(query, shortCode, excludeID, includePending).Scan(&count)
+	return count, err
+}
+
+func scanTakedowns(rows *sql.Rows) ([]*models.TakedownRequest, error) {
+	defer rows.Close()
+
+	var takedowns []*models.TakedownRequest
+	for rows.Next() {
+		takedown := &models.TakedownRequest{}
+		if err := rows.Scan(
+			&takedown.ID,
+			&takedown.ShortCode,
+			&takedown.Reason,
+			&takedown.ReporterName,
+			&takedown.ReporterEmail,
+			&takedown.Description,
+			pq.Array(&takedown.EvidenceURLs),
+			&takedown.Status,
+			&takedown.ResolutionNote,
+			&takedown.ResolvedBy,
+			&takedown.CreatedAt,
+			&takedown.ResolvedAt,
+		); err != nil {
+			return nil, err
+		}
+		takedowns = append(takedowns, takedown)
+	}
+	return takedowns, rows.Err()
+}
@@ -1,50 +1,117 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
+	"time"
 
 	"github.com/alexnthnz/url-shortener/internal/models"
+	"github.com/lib/pq"
 )
 
 type URLRepository struct {
-	db *sql.DB
+	db           *sql.DB
+	queryTimeout time.Duration // 0 leaves queries to the caller's context
 }
 
-func NewURLRepository(db *sql.DB) *URLRepository {
-	return &URLRepository{db: db}
+func NewURLRepository(db *sql.DB, queryTimeout time.Duration) *URLRepository {
+	return &URLRepository{db: db, queryTimeout: queryTimeout}
 }
 
 // Create stores a new URL mapping in the database
-func (r *URLRepository) Create(url *models.URL) error {
+func (r *URLRepository) Create(ctx context.Context, url *models.URL) error {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	query := `
-		INSERT INTO urls (short_code, original_url, custom_alias, expires_at)
-		VALUES ($1, $2, $3, $4)
+		INSERT INTO urls (short_code, original_url, custom_alias, expires_at, path_passthrough, max_clicks, numeric_code, activate_at, code_strategy)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8, NULLIF($9, ''))
 		RETURNING id, created_at`
 
-	return r.db.QueryRow(
+	err := r.db.QueryRowContext(ctx,
 		query,
 		url.ShortCode,
 		url.OriginalURL,
 		url.CustomAlias,
 		url.ExpiresAt,
+		url.PathPassthrough,
+		url.MaxClicks,
+		url.NumericCode,
+		url.ActivateAt,
+		url.CodeStrategy,
 	).Scan(&url.ID, &url.CreatedAt)
+	return mapDuplicate(err)
 }
 
+// getByShortCodeQuery is the redirect lookup, the hottest query in the service
+const getByShortCodeQuery = `
+	SELECT id, short_code, original_url, custom_alias, created_at, expires_at, path_passthrough, max_clicks,
+		disabled_at, COALESCE(disabled_reason, ''), COALESCE(numeric_code, ''), activate_at, COALESCE(code_strategy, '')
+	FROM urls
+	WHERE short_code = $1`
+
 // GetByShortCode retrieves a URL by its short code
-func (r *URLRepository) GetByShortCode(shortCode string) (*models.URL, error) {
+func (r *URLRepository) GetByShortCode(ctx context.Context, shortCode string) (*models.URL, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	url := &models.URL{}
+	err := r.db.QueryRowContext(ctx, getByShortCodeQuery, shortCode).Scan(
+		&url.ID,
+		&url.ShortCode,
+		&url.OriginalURL,
+		&url.CustomAlias,
+		&url.CreatedAt,
+		&url.ExpiresAt,
+		&url.PathPassthrough,
+		&url.MaxClicks,
+		&url.DisabledAt,
+		&url.DisabledReason,
+		&url.NumericCode,
+		&url.ActivateAt,
+		&url.CodeStrategy,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	return url, err
+}
+
+// FindReusable returns the oldest plain link to a destination: one with a generated code,
+// no passthrough, click cap, redirect rules, landing page, UTM parameters or activation
+// time, and not disabled. nil when there is none.
+func (r *URLRepository) FindReusable(ctx context.Context, originalURL string) (*models.URL, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	query := `
-		SELECT id, short_code, original_url, custom_alias, created_at, expires_at
+		SELECT id, short_code, original_url, custom_alias, created_at, expires_at, path_passthrough, max_clicks,
+			disabled_at, COALESCE(disabled_reason, ''), COALESCE(numeric_code, ''), activate_at
 		FROM urls
-		WHERE short_code = $1`
+		WHERE md5(original_url) = md5($1) AND original_url = $1
+			AND NOT custom_alias AND NOT path_passthrough AND max_clicks IS NULL
+			AND redirect_rules IS NULL AND landing_page IS NULL AND disabled_at IS NULL AND activate_at IS NULL
+			AND NOT EXISTS (SELECT 1 FROM link_utm_params p WHERE p.short_code = urls.short_code)
+		ORDER BY id
+		LIMIT 1`
 
-	err := r.db.QueryRow(query, shortCode).Scan(
+	url := &models.URL{}
+	err := r.db.QueryRowContext(ctx, query, originalURL).Scan(
 		&url.ID,
 		&url.ShortCode,
 		&url.OriginalURL,
 		&url.CustomAlias,
 		&url.CreatedAt,
 		&url.ExpiresAt,
+		&url.PathPassthrough,
+		&url.MaxClicks,
+		&url.DisabledAt,
+		&url.DisabledReason,
+		&url.NumericCode,
+		&url.ActivateAt,
 	)
 
 	if err == sql.ErrNoRows {
@@ -54,42 +121,223 @@ func (r *URLRepository) GetByShortCode(shortCode string) (*models.URL, error) {
 	return url, err
 }
 
-// Exists checks if a short code already exists
-func (r *URLRepository) Exists(shortCode string) (bool, error) {
+// GetShortCodeByNumericCode returns the short code of the link with a numeric code, empty
+// when there is none
+func (r *URLRepository) GetShortCodeByNumericCode(ctx context.Context, numericCode string) (string, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var shortCode string
+	err := r.db.QueryRowContext(ctx, `SELECT short_code FROM urls WHERE numeric_code = $1`, numericCode).Scan(&shortCode)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return shortCode, err
+}
+
+// Exists checks if a short code is already taken by a link or an alias
+func (r *URLRepository) Exists(ctx context.Context, shortCode string) (bool, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	var exists bool
-	query := `SELECT EXISTS(SELECT 1 FROM urls WHERE short_code = $1)`
-	err := r.db.QueryRow(query, shortCode).Scan(&exists)
+	query := `
+		SELECT EXISTS(SELECT 1 FROM urls WHERE short_code = $1)
+			OR EXISTS(SELECT 1 FROM aliases WHERE alias = $1)`
+	err := r.db.QueryRowContext(ctx, query, shortCode).Scan(&exists)
 	return exists, err
 }
 
+// FilterExisting returns the short codes among codes that exist
+func (r *URLRepository) FilterExisting(ctx context.Context, codes []string) ([]string, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	if len(codes) == 0 {
+		return nil, nil
+	}
+
+	rows, err := r.db.QueryContext(ctx, `SELECT short_code FROM urls WHERE short_code = ANY($1) ORDER BY short_code`, pq.Array(codes))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var existing []string
+	for rows.Next() {
+		var code string
+		if err := rows.Scan(&code); err != nil {
+			return nil, err
+		}
+		existing = append(existing, code)
+	}
+
+	return existing, rows.Err()
+}
+
+// SetDisabled takes a link down with a reason shown to visitors, or brings it back when
+// disabled is false. It reports whether the link exists.
+func (r *URLRepository) SetDisabled(ctx context.Context, shortCode string, disabled bool, reason string) (bool, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `UPDATE urls SET disabled_at = CURRENT_TIMESTAMP, disabled_reason = $2 WHERE short_code = $1`
+	args := []interface{}{shortCode, reason}
+	if !disabled {
+		query = `UPDATE urls SET disabled_at = NULL, disabled_reason = NULL WHERE short_code = $1`
+		args = args[:1]
+	}
+
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// GetClicksUsed returns the clicks counted against a link's click cap
+func (r *URLRepository) GetClicksUsed(ctx context.Context, shortCode string) (int64, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var used int64
+	err := r.db.QueryRowContext(ctx, `SELECT clicks_used FROM urls WHERE short_code = $1`, shortCode).Scan(&used)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return used, err
+}
+
+// ConsumeClick counts one click against a capped link, reporting false when the cap is
+// already used up
+func (r *URLRepository) ConsumeClick(ctx context.Context, shortCode string) (bool, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		UPDATE urls SET clicks_used = clicks_used + 1
+		WHERE short_code = $1 AND clicks_used < max_clicks
+		RETURNING clicks_used`
+
+	var used int64
+	err := r.db.QueryRowContext(ctx, query, shortCode).Scan(&used)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// SyncClicksUsed copies click counts of capped links into the database. Counts only ever
+// grow and are capped at max_clicks, so a stale or repeated sync is harmless.
+func (r *URLRepository) SyncClicksUsed(ctx context.Context, counts map[string]int64) error {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	if len(counts) == 0 {
+		return nil
+	}
+
+	codes := make([]string, 0, len(counts))
+	used := make([]int64, 0, len(counts))
+	for code, count := range counts {
+		codes = append(codes, code)
+		used = append(used, count)
+	}
+
+	query := `
+		UPDATE urls u SET clicks_used = GREATEST(u.clicks_used, LEAST(c.used, u.max_clicks))
+		FROM unnest($1::text[], $2::bigint[]) AS c(short_code, used)
+		WHERE u.short_code = c.short_code AND u.max_clicks IS NOT NULL`
+	_, err := r.db.ExecContext(ctx, query, pq.Array(codes), pq.Array(used))
+	return err
+}
+
 // GetNextID returns the next sequential ID for generating short codes
-func (r *URLRepository) GetNextID() (int64, error) {
+func (r *URLRepository) GetNextID(ctx context.Context) (int64, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	var nextID int64
 	// Use atomic sequence to prevent race conditions in concurrent environments
 	query := `SELECT nextval('url_id_sequence')`
-	err := r.db.QueryRow(query).Scan(&nextID)
+	err := r.db.QueryRowContext(ctx, query).Scan(&nextID)
 	return nextID, err
 }
 
+// GetCurrentID returns the last ID handed out by the code sequence without advancing it
+func (r *URLRepository) GetCurrentID(ctx context.Context) (int64, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var currentID int64
+	err := r.db.QueryRowContext(ctx, `SELECT last_value FROM url_id_sequence`).Scan(&currentID)
+	return currentID, err
+}
+
+// GetCodeSpaceUsage counts links by code strategy and code length, and how many of them
+// were created since the given time. Links without a recorded strategy count as custom
+// when they have a custom alias and as unclassified otherwise.
+func (r *URLRepository) GetCodeSpaceUsage(ctx context.Context, since time.Time) ([]models.CodeSpaceUsage, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT COALESCE(code_strategy, CASE WHEN custom_alias THEN 'custom' ELSE 'unclassified' END) AS strategy,
+			char_length(short_code) AS length,
+			COUNT(*),
+			COUNT(*) FILTER (WHERE created_at >= $1)
+		FROM urls
+		GROUP BY strategy, length
+		ORDER BY strategy, length`
+
+	rows, err := r.db.QueryContext(ctx, query, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var usage []models.CodeSpaceUsage
+	for rows.Next() {
+		var row models.CodeSpaceUsage
+		if err := rows.Scan(&row.Strategy, &row.Length, &row.Links, &row.Recent); err != nil {
+			return nil, err
+		}
+		usage = append(usage, row)
+	}
+
+	return usage, rows.Err()
+}
+
+// getStatsQuery backs the stats endpoint
+const getStatsQuery = `
+	SELECT
+		u.short_code,
+		u.original_url,
+		u.created_at,
+		COUNT(a.id) FILTER (WHERE NOT a.is_bot AND NOT a.is_internal) AS click_count,
+		COUNT(a.id) FILTER (WHERE a.is_bot) AS bot_clicks,
+		COUNT(a.id) FILTER (WHERE a.is_internal AND NOT a.is_bot) AS internal_clicks,
+		COUNT(a.id) FILTER (WHERE a.via_qr AND NOT a.is_bot AND NOT a.is_internal) AS qr_scans
+	FROM urls u
+	LEFT JOIN analytics a ON u.short_code = a.short_code
+	WHERE u.short_code = $1
+	GROUP BY u.short_code, u.original_url, u.created_at`
+
 // GetStats retrieves statistics for a URL
-func (r *URLRepository) GetStats(shortCode string) (*models.URLStats, error) {
+func (r *URLRepository) GetStats(ctx context.Context, shortCode string) (*models.URLStats, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	stats := &models.URLStats{}
-	query := `
-		SELECT 
-			u.short_code,
-			u.original_url,
-			u.created_at,
-			COALESCE(COUNT(a.id), 0) as click_count
-		FROM urls u
-		LEFT JOIN analytics a ON u.short_code = a.short_code
-		WHERE u.short_code = $1
-		GROUP BY u.short_code, u.original_url, u.created_at`
-
-	err := r.db.QueryRow(query, shortCode).Scan(
+	err := r.db.QueryRowContext(ctx, getStatsQuery, shortCode).Scan(
 		&stats.ShortCode,
 		&stats.OriginalURL,
 		&stats.CreatedAt,
 		&stats.ClickCount,
+		&stats.BotClicks,
+		&stats.InternalClicks,
+		&stats.QRScans,
 	)
 
 	if err == sql.ErrNoRows {
@@ -99,14 +347,602 @@ func (r *URLRepository) GetStats(shortCode string) (*models.URLStats, error) {
 	return stats, err
 }
 
+// UpdateOriginalURL changes the destination of a short code and records the previous one
+// in url_history within one transaction. It returns nil when the short code does not exist.
+func (r *URLRepository) UpdateOriginalURL(ctx context.Context, shortCode, newURL, changedBy string) (*models.URLHistoryEntry, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	entry := &models.URLHistoryEntry{ShortCode: shortCode, NewURL: newURL, ChangedBy: changedBy}
+	err = tx.QueryRowContext(ctx, `SELECT original_url FROM urls WHERE short_code = $1 FOR UPDATE`, shortCode).Scan(&entry.PreviousURL)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE urls SET original_url = $1 WHERE short_code = $2`, newURL, shortCode); err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO url_history (short_code, previous_url, new_url, changed_by)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, changed_at`
+	if err := tx.QueryRowContext(ctx, query, shortCode, entry.PreviousURL, newURL, changedBy).Scan(&entry.ID, &entry.ChangedAt); err != nil {
+		return nil, err
+	}
+
+	return entry, tx.Commit()
+}
+
+// ListHistory returns up to limit destination changes of a short code, newest first
+func (r *URLRepository) ListHistory(ctx context.Context, shortCode string, limit int) ([]*models.URLHistoryEntry, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT id, short_code, previous_url, new_url, changed_by, changed_at
+		FROM url_history
+		WHERE short_code = $1
+		ORDER BY changed_at DESC, id DESC
+		LIMIT $2`
+
+	rows, err := r.db.QueryContext(ctx, query, shortCode, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*models.URLHistoryEntry
+	for rows.Next() {
+		entry := &models.URLHistoryEntry{}
+		if err := rows.Scan(&entry.ID, &entry.ShortCode, &entry.PreviousURL, &entry.NewURL, &entry.ChangedBy, &entry.ChangedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
 // HealthCheck performs a simple database connectivity test
-func (r *URLRepository) HealthCheck() (bool, error) {
+func (r *URLRepository) HealthCheck(ctx context.Context) (bool, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
 	// Simple query to test database connectivity
 	var result int
 	query := `SELECT 1`
-	err := r.db.QueryRow(query).Scan(&result)
+	err := r.db.QueryRowContext(ctx, query).Scan(&result)
 	if err != nil {
 		return false, err
 	}
 	return result == 1, nil
 }
+
+// GetDailyCreated returns the number of URLs created per day since the given time
+func (r *URLRepository) GetDailyCreated(ctx context.Context, since time.Time) ([]models.DailyCount, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT date_trunc('day', created_at) AS day, COUNT(*)
+		FROM urls
+		WHERE created_at >= $1
+		GROUP BY day
+		ORDER BY day`
+
+	return queryDailyCounts(ctx, r.db, query, since)
+}
+
+// getTopDomainsQuery lists the most shortened destination hosts
+const getTopDomainsQuery = `
+	SELECT lower(substring(original_url from '^[a-zA-Z]+://([^/:?#]+)')) AS domain, COUNT(*) AS links
+	FROM urls
+	WHERE created_at >= $1
+	GROUP BY domain
+	ORDER BY links DESC, domain
+	LIMIT $2`
+
+// GetTopDomains returns the most shortened destination hosts since the given time
+func (r *URLRepository) GetTopDomains(ctx context.Context, since time.Time, limit int) ([]models.DimensionCount, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, getTopDomainsQuery, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var domains []models.DimensionCount
+	for rows.Next() {
+		var domain models.DimensionCount
+		if err := rows.Scan(&domain.Value, &domain.Count); err != nil {
+			return nil, err
+		}
+		domains = append(domains, domain)
+	}
+
+	return domains, rows.Err()
+}
+
+// getDomainStatsQuery counts all links and their clicks since a given time per
+// destination host
+const getDomainStatsQuery = `
+	WITH clicks AS (
+		SELECT short_code, COUNT(*) AS clicks
+		FROM analytics
+		WHERE clicked_at >= $1 AND NOT is_bot AND NOT is_internal
+		GROUP BY short_code
+	)
+	SELECT lower(substring(u.original_url from '^[a-zA-Z]+://([^/:?#]+)')) AS domain,
+		COUNT(*) AS links,
+		COALESCE(SUM(c.clicks), 0) AS clicks
+	FROM urls u
+	LEFT JOIN clicks c ON c.short_code = u.short_code
+	GROUP BY domain`
+
+// GetDomainStats returns the links and clicks since the given time of every destination
+// host, in no particular order
+func (r *URLRepository) GetDomainStats(ctx context.Context, since time.Time) ([]models.DomainStats, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, getDomainStatsQuery, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var domains []models.DomainStats
+	for rows.Next() {
+		var domain sql.NullString
+		var stats models.DomainStats
+		if err := rows.Scan(&domain, &stats.Links, &stats.Clicks); err != nil {
+			return nil, err
+		}
+		stats.Domain = domain.String
+		domains = append(domains, stats)
+	}
+
+	return domains, rows.Err()
+}
+
+// ListCodesByDomains returns up to limit of the newest short codes per destination host,
+// for hosts as returned by GetDomainStats
+func (r *URLRepository) ListCodesByDomains(ctx context.Context, domains []string, limit int) (map[string][]string, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT domain, short_code
+		FROM (
+			SELECT domain, short_code,
+				row_number() OVER (PARTITION BY domain ORDER BY created_at DESC, id DESC) AS n
+			FROM (
+				SELECT lower(substring(original_url from '^[a-zA-Z]+://([^/:?#]+)')) AS domain, short_code, created_at, id
+				FROM urls
+			) hosts
+			WHERE domain = ANY($1)
+		) ranked
+		WHERE n <= $2
+		ORDER BY domain, n`
+
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(domains), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	codes := make(map[string][]string)
+	for rows.Next() {
+		var domain, code string
+		if err := rows.Scan(&domain, &code); err != nil {
+			return nil, err
+		}
+		codes[domain] = append(codes[domain], code)
+	}
+
+	return codes, rows.Err()
+}
+
+// GetRedirectRules returns the redirect rules of a link, reporting whether the link exists
+func (r *URLRepository) GetRedirectRules(ctx context.Context, shortCode string) ([]models.RedirectRule, bool, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var raw []byte
+	err := r.db.QueryRowContext(ctx, `SELECT redirect_rules FROM urls WHERE short_code = $1`, shortCode).Scan(&raw)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	var rules []models.RedirectRule
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &rules); err != nil {
+			return nil, true, err
+		}
+	}
+	return rules, true, nil
+}
+
+// SetRedirectRules replaces the redirect rules of a link, reporting whether the link exists
+func (r *URLRepository) SetRedirectRules(ctx context.Context, shortCode string, rules []models.RedirectRule) (bool, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var raw interface{} // NULL without rules
+	if len(rules) > 0 {
+		encoded, err := json.Marshal(rules)
+		if err != nil {
+			return false, err
+		}
+		raw = string(encoded)
+	}
+
+	result, err := r.db.ExecContext(ctx, `UPDATE urls SET redirect_rules = $2 WHERE short_code = $1`, shortCode, raw)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// GetLandingPage returns the landing page of a link, reporting whether the link exists
+func (r *URLRepository) GetLandingPage(ctx context.Context, shortCode string) (*models.LandingPage, bool, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var raw []byte
+	err := r.db.QueryRowContext(ctx, `SELECT landing_page FROM urls WHERE short_code = $1`, shortCode).Scan(&raw)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(raw) == 0 {
+		return nil, true, nil
+	}
+	page := &models.LandingPage{}
+	if err := json.Unmarshal(raw, page); err != nil {
+		return nil, true, err
+	}
+	return page, true, nil
+}
+
+// SetLandingPage replaces the landing page of a link, nil removing it, and reports
+// whether the link exists
+func (r *URLRepository) SetLandingPage(ctx context.Context, shortCode string, page *models.LandingPage) (bool, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	var raw interface{} // NULL without a page
+	if page != nil {
+		encoded, err := json.Marshal(page)
+		if err != nil {
+			return false, err
+		}
+		raw = string(encoded)
+	}
+
+	result, err := r.db.ExecContext(ctx, `UPDATE urls SET landing_page = $2 WHERE short_code = $1`, shortCode, raw)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// GetUTMParams returns the UTM parameters of a link, or nil when it has none
+func (r *URLRepository) GetUTMParams(ctx context.Context, shortCode string) (*models.UTMParams, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT COALESCE(utm_source, ''), COALESCE(utm_medium, ''), COALESCE(utm_campaign, ''),
+			COALESCE(utm_term, ''), COALESCE(utm_content, '')
+		FROM link_utm_params
+		WHERE short_code = $1`
+
+	utm := &models.UTMParams{}
+	err := r.db.QueryRowContext(ctx, query, shortCode).Scan(&utm.Source, &utm.Medium, &utm.Campaign, &utm.Term, &utm.Content)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return utm, nil
+}
+
+// SetUTMParams replaces the UTM parameters of an existing link; nil removes them
+func (r *URLRepository) SetUTMParams(ctx context.Context, shortCode string, utm *models.UTMParams) error {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	if utm == nil {
+		_, err := r.db.ExecContext(ctx, `DELETE FROM link_utm_params WHERE short_code = $1`, shortCode)
+		return err
+	}
+
+	query := `
+		INSERT INTO link_utm_params (short_code, utm_source, utm_medium, utm_campaign, utm_term, utm_content)
+		VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), NULLIF($4, ''), NULLIF($5, ''), NULLIF($6, ''))
+		ON CONFLICT (short_code) DO UPDATE SET
+			utm_source = EXCLUDED.utm_source,
+			utm_medium = EXCLUDED.utm_medium,
+			utm_campaign = EXCLUDED.utm_campaign,
+			utm_term = EXCLUDED.utm_term,
+			utm_content = EXCLUDED.utm_content,
+			updated_at = CURRENT_TIMESTAMP`
+
+	_, err := r.db.ExecContext(ctx, query, shortCode, utm.Source, utm.Medium, utm.Campaign, utm.Term, utm.Content)
+	return err
+}
+
+// ListCampaigns returns every utm_campaign some link is tagged with
+func (r *URLRepository) ListCampaigns(ctx context.Context) ([]string, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	rows, err := r.db.QueryContext(ctx, `SELECT DISTINCT utm_campaign FROM link_utm_params WHERE utm_campaign IS NOT NULL ORDER BY utm_campaign`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var campaigns []string
+	for rows.Next() {
+		var campaign string
+		if err := rows.Scan(&campaign); err != nil {
+			return nil, err
+		}
+		campaigns = append(campaigns, campaign)
+	}
+	return campaigns, rows.Err()
+}
+
+// GetCampaignLinks returns up to limit links tagged with a utm_campaign, oldest first
+func (r *URLRepository) GetCampaignLinks(ctx context.Context, campaign string, limit int) ([]models.CampaignLink, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT u.short_code, u.original_url
+		FROM link_utm_params p
+		JOIN urls u ON u.short_code = p.short_code
+		WHERE p.utm_campaign = $1
+		ORDER BY u.id
+		LIMIT $2`
+
+	rows, err := r.db.QueryContext(ctx, query, campaign, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var links []models.CampaignLink
+	for rows.Next() {
+		var link models.CampaignLink
+		if err := rows.Scan(&link.ShortCode, &link.OriginalURL); err != nil {
+			return nil, err
+		}
+		links = append(links, link)
+	}
+	return links, rows.Err()
+}
+
+// Delete removes a link together with its clicks, aliases, history, webhooks and codes on
+// custom domains, returning the aliases it had. It reports whether the link existed.
+func (r *URLRepository) Delete(ctx context.Context, shortCode string) ([]string, bool, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `SELECT alias FROM aliases WHERE short_code = $1`, shortCode)
+	if err != nil {
+		return nil, false, err
+	}
+	var aliases []string
+	for rows.Next() {
+		var alias string
+		if err := rows.Scan(&alias); err != nil {
+			rows.Close()
+			return nil, false, err
+		}
+		aliases = append(aliases, alias)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	// Dependent rows go with the link through ON DELETE CASCADE
+	result, err := tx.ExecContext(ctx, `DELETE FROM urls WHERE short_code = $1`, shortCode)
+	if err != nil {
+		return nil, false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil || affected == 0 {
+		return nil, false, err
+	}
+
+	return aliases, true, tx.Commit()
+}
+
+// ListDestinations returns up to limit enabled links with an id above afterID, in id
+// order, with their destination followed by those of their redirect rules and landing
+// page buttons
+func (r *URLRepository) ListDestinations(ctx context.Context, afterID int64, limit int) ([]models.LinkDestinations, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT id, short_code, original_url, redirect_rules, landing_page
+		FROM urls
+		WHERE id > $1 AND disabled_at IS NULL
+		ORDER BY id
+		LIMIT $2`
+
+	rows, err := r.db.QueryContext(ctx, query, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var links []models.LinkDestinations
+	for rows.Next() {
+		var link models.LinkDestinations
+		var destination string
+		var rawRules, rawPage []byte
+		if err := rows.Scan(&link.ID, &link.ShortCode, &destination, &rawRules, &rawPage); err != nil {
+			return nil, err
+		}
+		link.URLs = []string{destination}
+
+		if len(rawRules) > 0 {
+			var rules []models.RedirectRule
+			if err := json.Unmarshal(rawRules, &rules); err != nil {
+				return nil, err
+			}
+			for _, rule := range rules {
+				link.URLs = append(link.URLs, rule.Destination)
+			}
+		}
+		if len(rawPage) > 0 {
+			var page models.LandingPage
+			if err := json.Unmarshal(rawPage, &page); err != nil {
+				return nil, err
+			}
+			for _, button := range page.Links {
+				link.URLs = append(link.URLs, button.URL)
+			}
+		}
+		links = append(links, link)
+	}
+
+	return links, rows.Err()
+}
+
+// getTotalsQuery counts everything the instance stores
+const getTotalsQuery = `
+	SELECT
+		(SELECT COUNT(*) FROM urls),
+		(SELECT COUNT(*) FROM urls WHERE disabled_at IS NOT NULL),
+		(SELECT COUNT(*) FROM aliases),
+		(SELECT COUNT(*) FROM analytics WHERE NOT is_bot),
+		(SELECT COUNT(*) FROM analytics WHERE is_bot)`
+
+// GetTotals returns instance-wide counts of links, aliases and clicks
+func (r *URLRepository) GetTotals(ctx context.Context) (*models.InstanceTotals, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	totals := &models.InstanceTotals{}
+	err := r.db.QueryRowContext(ctx, getTotalsQuery).Scan(
+		&totals.Links,
+		&totals.DisabledLinks,
+		&totals.Aliases,
+		&totals.Clicks,
+		&totals.BotClicks,
+	)
+	return totals, err
+}
+
+// getTopLinksQuery ranks links by their clicks by people since a given time
+const getTopLinksQuery = `
+	WITH clicks AS (
+		SELECT short_code, COUNT(*) AS clicks
+		FROM analytics
+		WHERE clicked_at >= $1 AND NOT is_bot AND NOT is_internal
+		GROUP BY short_code
+		ORDER BY clicks DESC, short_code
+		LIMIT $2
+	)
+	SELECT u.short_code, u.original_url, u.created_at, u.disabled_at IS NOT NULL, c.clicks
+	FROM clicks c
+	JOIN urls u ON u.short_code = c.short_code
+	ORDER BY c.clicks DESC, u.short_code`
+
+// GetTopLinks returns the most clicked links since the given time
+func (r *URLRepository) GetTopLinks(ctx context.Context, since time.Time, limit int) ([]models.LinkSummary, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	return queryLinkSummaries(ctx, r.db, getTopLinksQuery, since, limit)
+}
+
+// listRecentQuery lists the newest links with their clicks by people
+const listRecentQuery = `
+	SELECT u.short_code, u.original_url, u.created_at, u.disabled_at IS NOT NULL,
+		(SELECT COUNT(*) FROM analytics a WHERE a.short_code = u.short_code AND NOT a.is_bot AND NOT a.is_internal)
+	FROM urls u
+	ORDER BY u.created_at DESC, u.id DESC
+	LIMIT $1`
+
+// ListRecent returns the most recently created links, newest first
+func (r *URLRepository) ListRecent(ctx context.Context, limit int) ([]models.LinkSummary, error) {
+	ctx, cancel := withQueryTimeout(ctx, r.queryTimeout)
+	defer cancel()
+
+	return queryLinkSummaries(ctx, r.db, listRecentQuery, limit)
+}
+
+// queryLinkSummaries runs a (short_code, original_url, created_at, disabled, clicks) query
+func queryLinkSummaries(ctx context.Context, db *sql.DB, query string, args ...interface{}) ([]models.LinkSummary, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	links := []models.LinkSummary{}
+	for rows.Next() {
+		var link models.LinkSummary
+		if err := rows.Scan(&link.ShortCode, &link.OriginalURL, &link.CreatedAt, &link.Disabled, &link.Clicks); err != nil {
+			return nil, err
+		}
+		links = append(links, link)
+	}
+
+	return links, rows.Err()
+}
+
+// queryDailyCounts runs a (day, count) aggregate query
+func queryDailyCounts(ctx context.Context, db *sql.DB, query string, args ...interface{}) ([]models.DailyCount, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var days []models.DailyCount
+	for rows.Next() {
+		var day models.DailyCount
+		if err := rows.Scan(&day.Day, &day.Count); err != nil {
+			return nil, err
+		}
+		days = append(days, day)
+	}
+
+	return days, rows.Err()
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/alexnthnz/url-shortener/internal/models"
+)
+
+type WebhookRepository struct {
+	db *sql.DB
+}
+
+func NewWebhookRepository(db *sql.DB) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+// Create stores a new webhook subscription
+func (r *WebhookRepository) Create(webhook *models.Webhook) error {
+	query := `
+		INSERT INTO webhooks (target_url, short_code, secret, aggregation_window)
+		VALUES ($1, NULLIF($2, ''), $3, $4)
+		RETURNING id, created_at`
+
+	return r.db.QueryRow(
+		query,
+		webhook.TargetURL,
+		webhook.ShortCode,
+		webhook.Secret,
+		webhook.AggregationWindow,
+	).Scan(&webhook.ID, &webhook.CreatedAt)
+}
+
+// List returns all webhook subscriptions
+func (r *WebhookRepository) List() ([]*models.Webhook, error) {
+	query := `
+		SELECT id, target_url, COALESCE(short_code, ''), secret, aggregation_window, created_at
+		FROM webhooks
+		ORDER BY id`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var webhooks []*models.Webhook
+	for rows.Next() {
+		webhook := &models.Webhook{}
+		if err := rows.Scan(
+			&webhook.ID,
+			&webhook.TargetURL,
+			&webhook.ShortCode,
+			&webhook.Secret,
+			&webhook.AggregationWindow,
+			&webhook.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, rows.Err()
+}
+
+// Delete removes a webhook subscription, reporting whether it existed
+func (r *WebhookRepository) Delete(id int64) (bool, error) {
+	result, err := r.db.Exec(`DELETE FROM webhooks WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
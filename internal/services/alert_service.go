@@ -0,0 +1,442 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	apperrors "github.com/alexnthnz/url-shortener/internal/errors"
+	"github.com/alexnthnz/url-shortener/internal/models"
+	"github.com/alexnthnz/url-shortener/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// Built-in alert rules
+const (
+	AlertRedirectErrorRate = "RedirectErrorRateHigh"
+	AlertQueueBacklog      = "QueueBacklog"
+	AlertCacheHitRatio     = "CacheHitRatioLow"
+	AlertReplicationLag    = "ReplicationLagHigh"
+)
+
+// Severities of alert rules, as Alertmanager routes and PagerDuty accepts them
+const (
+	SeverityCritical = "critical"
+	SeverityWarning  = "warning"
+)
+
+const (
+	// alertErrorWindow is the lookback of the redirect error rate
+	alertErrorWindow = 5 * time.Minute
+	// alertMinSamples is the traffic below which ratio rules stay quiet, so one failed
+	// redirect on an idle instance does not page anyone
+	alertMinSamples = 20
+)
+
+// AlertThresholds are the levels the built-in rules fire at; zero turns a rule off
+type AlertThresholds struct {
+	ErrorRate      float64       // share of redirects failing with a 5xx over the last 5 minutes
+	QueueDepth     int           // click events waiting in the analytics or webhook queue
+	CacheHitRatio  float64       // share of redirects served from cache since the last evaluation
+	ReplicationLag time.Duration // replay lag of the slowest Postgres standby
+}
+
+// AlertConfig holds where alerts are pushed and the thresholds they fire at
+type AlertConfig struct {
+	AlertmanagerURL     string // base URL of an Alertmanager; alerts go to /api/v2/alerts
+	PagerDutyRoutingKey string // integration key of a PagerDuty Events API v2 service
+	PagerDutyURL        string // https://events.pagerduty.com/v2/enqueue
+	Instance            string // the instance label alerts carry, the host name by default
+	Interval            time.Duration
+	Thresholds          AlertThresholds
+}
+
+// alertSample is what the rules are evaluated against
+type alertSample struct {
+	redirects      int64
+	serverErrors   int64
+	cacheHits      int64
+	cacheMisses    int64
+	queues         map[string]int
+	standbys       int
+	replicationLag time.Duration
+}
+
+// AlertService evaluates built-in health rules every interval and pushes the alerts to
+// Alertmanager and PagerDuty, for teams running the service without a monitoring stack.
+// Every instance evaluates its own error rate, queues and cache, so alerts are labelled
+// with the instance. Alertmanager is sent the firing alerts on every evaluation, as
+// Prometheus does; PagerDuty is sent a trigger when an alert starts and a resolve when
+// it stops.
+type AlertService struct {
+	cfg       AlertConfig
+	slo       *SLOService
+	usage     *UsageService
+	analytics *AnalyticsService
+	webhooks  *WebhookService
+	db        *sql.DB
+	client    *http.Client
+	logger    *logrus.Logger
+
+	mu          sync.Mutex
+	firing      map[string]*models.Alert
+	evaluatedAt *time.Time
+	lastHits    int64
+	lastMisses  int64
+}
+
+func NewAlertService(cfg AlertConfig, slo *SLOService, usage *UsageService, analytics *AnalyticsService, webhooks *WebhookService, db *sql.DB, logger *logrus.Logger) *AlertService {
+	service := &AlertService{
+		cfg:       cfg,
+		slo:       slo,
+		usage:     usage,
+		analytics: analytics,
+		webhooks:  webhooks,
+		db:        db,
+		client:    &http.Client{Timeout: 10 * time.Second},
+		logger:    logger,
+		firing:    make(map[string]*models.Alert),
+	}
+
+	if service.Enabled() {
+		go service.schedule()
+	}
+
+	return service
+}
+
+// Enabled reports whether alerts are evaluated and have somewhere to go
+func (s *AlertService) Enabled() bool {
+	return s.cfg.Interval > 0 && (s.cfg.AlertmanagerURL != "" || s.cfg.PagerDutyRoutingKey != "")
+}
+
+// Status returns the rules and the alerts currently firing on this instance
+func (s *AlertService) Status() (*models.AlertStatus, error) {
+	if !s.Enabled() {
+		return nil, apperrors.Errorf(apperrors.ErrNotConfigured, "alerting is not configured")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := &models.AlertStatus{
+		Instance:    s.cfg.Instance,
+		EvaluatedAt: s.evaluatedAt,
+		Rules:       alertRules(s.cfg.Thresholds),
+		Firing:      make([]models.Alert, 0, len(s.firing)),
+	}
+	for _, alert := range s.firing {
+		status.Firing = append(status.Firing, *alert)
+	}
+	sort.Slice(status.Firing, func(i, j int) bool {
+		return alertKey(&status.Firing[i]) < alertKey(&status.Firing[j])
+	})
+	return status, nil
+}
+
+// Evaluate samples the instance, updates the firing alerts and pushes them out
+func (s *AlertService) Evaluate(ctx context.Context, now time.Time) error {
+	sample := s.collect()
+	current := evaluateAlertRules(sample, s.cfg.Thresholds)
+
+	s.mu.Lock()
+	var started, resolved []*models.Alert
+	firing := make(map[string]*models.Alert, len(current))
+	for i := range current {
+		alert := &current[i]
+		if alert.Labels == nil {
+			alert.Labels = map[string]string{}
+		}
+		alert.Labels["instance"] = s.cfg.Instance
+
+		key := alertKey(alert)
+		if previous, ok := s.firing[key]; ok {
+			alert.StartsAt = previous.StartsAt
+		} else {
+			alert.StartsAt = now
+			started = append(started, alert)
+		}
+		firing[key] = alert
+	}
+	for key, alert := range s.firing {
+		if _, ok := firing[key]; !ok {
+			resolved = append(resolved, alert)
+		}
+	}
+	s.firing = firing
+	s.evaluatedAt = &now
+
+	active := make([]*models.Alert, 0, len(firing))
+	for _, alert := range firing {
+		active = append(active, alert)
+	}
+	s.mu.Unlock()
+
+	for _, alert := range started {
+		s.logger.Warnf("Alert %s firing: %s", alert.Name, alert.Summary)
+	}
+	for _, alert := range resolved {
+		s.logger.Infof("Alert %s resolved", alert.Name)
+	}
+
+	var errs []string
+	if s.cfg.AlertmanagerURL != "" && (len(active) > 0 || len(resolved) > 0) {
+		if err := s.pushAlertmanager(ctx, active, resolved, now); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if s.cfg.PagerDutyRoutingKey != "" {
+		for _, alert := range started {
+			if err := s.pushPagerDuty(ctx, "trigger", alert); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+		for _, alert := range resolved {
+			if err := s.pushPagerDuty(ctx, "resolve", alert); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to push alerts: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// collect samples the metrics the rules read. Cache counts are taken since the previous
+// sample; a failing replication query leaves the lag rule quiet for this round.
+func (s *AlertService) collect() alertSample {
+	sample := alertSample{
+		queues: map[string]int{
+			"analytics": s.analytics.QueueDepth(),
+			"webhooks":  s.webhooks.QueueDepth(),
+		},
+	}
+	sample.redirects, sample.serverErrors = s.slo.Outcomes(alertErrorWindow)
+
+	hits, misses := s.usage.CacheCounts()
+	s.mu.Lock()
+	sample.cacheHits, sample.cacheMisses = hits-s.lastHits, misses-s.lastMisses
+	s.lastHits, s.lastMisses = hits, misses
+	s.mu.Unlock()
+
+	if s.cfg.Thresholds.ReplicationLag > 0 {
+		standbys, lag, err := repository.ReplicationLag(s.db)
+		if err != nil {
+			s.logger.Warnf("Failed to read replication lag: %v", err)
+		} else {
+			sample.standbys, sample.replicationLag = standbys, lag
+		}
+	}
+	return sample
+}
+
+// schedule evaluates the rules every interval
+func (s *AlertService) schedule() {
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), s.cfg.Interval)
+		if err := s.Evaluate(ctx, now.UTC()); err != nil {
+			s.logger.Errorf("Alert evaluation failed: %v", err)
+		}
+		cancel()
+	}
+}
+
+// alertmanagerAlert is an alert as the Alertmanager v2 API accepts it
+type alertmanagerAlert struct {
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	StartsAt    time.Time         `json:"startsAt"`
+	EndsAt      time.Time         `json:"endsAt"`
+}
+
+// pushAlertmanager posts the firing alerts with an end a few intervals out, so they
+// resolve by themselves should this instance go away, and the resolved ones ending now
+func (s *AlertService) pushAlertmanager(ctx context.Context, active, resolved []*models.Alert, now time.Time) error {
+	payload := make([]alertmanagerAlert, 0, len(active)+len(resolved))
+	for _, alert := range active {
+		payload = append(payload, alertmanagerPayload(alert, now.Add(4*s.cfg.Interval)))
+	}
+	for _, alert := range resolved {
+		payload = append(payload, alertmanagerPayload(alert, now))
+	}
+
+	endpoint := strings.TrimSuffix(s.cfg.AlertmanagerURL, "/") + "/api/v2/alerts"
+	if err := s.post(ctx, endpoint, payload); err != nil {
+		return fmt.Errorf("alertmanager: %w", err)
+	}
+	return nil
+}
+
+func alertmanagerPayload(alert *models.Alert, endsAt time.Time) alertmanagerAlert {
+	labels := map[string]string{
+		"alertname": alert.Name,
+		"severity":  alert.Severity,
+		"service":   "urlshortener",
+	}
+	for name, value := range alert.Labels {
+		labels[name] = value
+	}
+	return alertmanagerAlert{
+		Labels: labels,
+		Annotations: map[string]string{
+			"summary":   alert.Summary,
+			"value":     formatAlertValue(alert.Value),
+			"threshold": formatAlertValue(alert.Threshold),
+		},
+		StartsAt: alert.StartsAt,
+		EndsAt:   endsAt,
+	}
+}
+
+// pushPagerDuty sends one Events API v2 event; the dedup key ties the resolve to its trigger
+func (s *AlertService) pushPagerDuty(ctx context.Context, action string, alert *models.Alert) error {
+	event := map[string]interface{}{
+		"routing_key":  s.cfg.PagerDutyRoutingKey,
+		"event_action": action,
+		"dedup_key":    "urlshortener/" + alertKey(alert),
+	}
+	if action == "trigger" {
+		event["payload"] = map[string]interface{}{
+			"summary":        alert.Summary,
+			"source":         s.cfg.Instance,
+			"severity":       alert.Severity,
+			"component":      "urlshortener",
+			"class":          alert.Name,
+			"timestamp":      alert.StartsAt.Format(time.RFC3339),
+			"custom_details": alert.Labels,
+		}
+	}
+
+	if err := s.post(ctx, s.cfg.PagerDutyURL, event); err != nil {
+		return fmt.Errorf("pagerduty: %w", err)
+	}
+	return nil
+}
+
+func (s *AlertService) post(ctx context.Context, endpoint string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// evaluateAlertRules returns the alerts a sample fires
+func evaluateAlertRules(sample alertSample, thresholds AlertThresholds) []models.Alert {
+	var alerts []models.Alert
+
+	if thresholds.ErrorRate > 0 && sample.redirects >= alertMinSamples {
+		rate := float64(sample.serverErrors) / float64(sample.redirects)
+		if rate > thresholds.ErrorRate {
+			alerts = append(alerts, models.Alert{
+				Name:      AlertRedirectErrorRate,
+				Severity:  SeverityCritical,
+				Summary:   fmt.Sprintf("%.1f%% of redirects failed over the last %s", rate*100, alertErrorWindow),
+				Value:     rate,
+				Threshold: thresholds.ErrorRate,
+			})
+		}
+	}
+
+	if thresholds.QueueDepth > 0 {
+		queues := make([]string, 0, len(sample.queues))
+		for queue := range sample.queues {
+			queues = append(queues, queue)
+		}
+		sort.Strings(queues)
+		for _, queue := range queues {
+			depth := sample.queues[queue]
+			if depth > thresholds.QueueDepth {
+				alerts = append(alerts, models.Alert{
+					Name:      AlertQueueBacklog,
+					Severity:  SeverityWarning,
+					Labels:    map[string]string{"queue": queue},
+					Summary:   fmt.Sprintf("%d click events waiting in the %s queue", depth, queue),
+					Value:     float64(depth),
+					Threshold: float64(thresholds.QueueDepth),
+				})
+			}
+		}
+	}
+
+	if thresholds.CacheHitRatio > 0 && sample.cacheHits+sample.cacheMisses >= alertMinSamples {
+		ratio := hitRatio(sample.cacheHits, sample.cacheMisses)
+		if ratio < thresholds.CacheHitRatio {
+			alerts = append(alerts, models.Alert{
+				Name:      AlertCacheHitRatio,
+				Severity:  SeverityWarning,
+				Summary:   fmt.Sprintf("%.1f%% of redirects were served from cache", ratio*100),
+				Value:     ratio,
+				Threshold: thresholds.CacheHitRatio,
+			})
+		}
+	}
+
+	if thresholds.ReplicationLag > 0 && sample.standbys > 0 && sample.replicationLag > thresholds.ReplicationLag {
+		alerts = append(alerts, models.Alert{
+			Name:      AlertReplicationLag,
+			Severity:  SeverityCritical,
+			Summary:   fmt.Sprintf("A database standby is %s behind the primary", sample.replicationLag.Round(time.Second)),
+			Value:     sample.replicationLag.Seconds(),
+			Threshold: thresholds.ReplicationLag.Seconds(),
+		})
+	}
+
+	return alerts
+}
+
+// alertRules describes the built-in rules under the configured thresholds
+func alertRules(thresholds AlertThresholds) []models.AlertRule {
+	return []models.AlertRule{
+		{Name: AlertRedirectErrorRate, Severity: SeverityCritical, Threshold: thresholds.ErrorRate, Enabled: thresholds.ErrorRate > 0},
+		{Name: AlertQueueBacklog, Severity: SeverityWarning, Threshold: float64(thresholds.QueueDepth), Enabled: thresholds.QueueDepth > 0},
+		{Name: AlertCacheHitRatio, Severity: SeverityWarning, Threshold: thresholds.CacheHitRatio, Enabled: thresholds.CacheHitRatio > 0},
+		{Name: AlertReplicationLag, Severity: SeverityCritical, Threshold: thresholds.ReplicationLag.Seconds(), Enabled: thresholds.ReplicationLag > 0},
+	}
+}
+
+// alertKey identifies an alert by its name and labels
+func alertKey(alert *models.Alert) string {
+	names := make([]string, 0, len(alert.Labels))
+	for name := range alert.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	key := alert.Name
+	for _, name := range names {
+		key += "," + name + "=" + alert.Labels[name]
+	}
+	return key
+}
+
+func formatAlertValue(value float64) string {
+	return fmt.Sprintf("%g", value)
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alexnthnz/url-shortener/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+func TestEvaluateAlertRules(t *testing.T) {
+	thresholds := AlertThresholds{
+		ErrorRate:      0.05,
+		QueueDepth:     100,
+		CacheHitRatio:  0.5,
+		ReplicationLag: 30 * time.Second,
+	}
+
+	tests := []struct {
+		name   string
+		sample alertSample
+		want   []string
+	}{
+		{"healthy", alertSample{redirects: 100, serverErrors: 1, cacheHits: 90, cacheMisses: 10, standbys: 1, replicationLag: time.Second}, nil},
+		{"error rate", alertSample{redirects: 100, serverErrors: 10}, []string{AlertRedirectErrorRate}},
+		{"too little traffic", alertSample{redirects: 5, serverErrors: 5, cacheHits: 1, cacheMisses: 5}, nil},
+		{"queue backlog", alertSample{queues: map[string]int{"analytics": 500, "webhooks": 10}}, []string{AlertQueueBacklog}},
+		{"cache hit ratio", alertSample{cacheHits: 10, cacheMisses: 30}, []string{AlertCacheHitRatio}},
+		{"replication lag", alertSample{standbys: 2, replicationLag: time.Minute}, []string{AlertReplicationLag}},
+		{"no standbys", alertSample{replicationLag: time.Minute}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			alerts := evaluateAlertRules(tt.sample, thresholds)
+			if len(alerts) != len(tt.want) {
+				t.Fatalf("evaluateAlertRules() = %v, want %v", alerts, tt.want)
+			}
+			for i, alert := range alerts {
+				if alert.Name != tt.want[i] {
+					t.Errorf("evaluateAlertRules()[%d] = %s, want %s", i, alert.Name, tt.want[i])
+				}
+			}
+		})
+	}
+
+	if alerts := evaluateAlertRules(alertSample{redirects: 100, serverErrors: 100}, AlertThresholds{}); len(alerts) != 0 {
+		t.Errorf("evaluateAlertRules() with zero thresholds = %v, want none", alerts)
+	}
+}
+
+func TestAlertServiceEvaluate(t *testing.T) {
+	var mu sync.Mutex
+	var alertmanager [][]alertmanagerAlert
+	var pagerduty []map[string]interface{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v2/alerts", func(w http.ResponseWriter, r *http.Request) {
+		var alerts []alertmanagerAlert
+		json.NewDecoder(r.Body).Decode(&alerts)
+		mu.Lock()
+		alertmanager = append(alertmanager, alerts)
+		mu.Unlock()
+	})
+	mux.HandleFunc("/enqueue", func(w http.ResponseWriter, r *http.Request) {
+		var event map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&event)
+		mu.Lock()
+		pagerduty = append(pagerduty, event)
+		mu.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	slo := NewSLOService(0.999, 0.99, 100*time.Millisecond)
+	slo.now = func() time.Time { return now }
+	for i := 0; i < 100; i++ {
+		status := http.StatusFound
+		if i%5 == 0 {
+			status = http.StatusInternalServerError
+		}
+		slo.Record(time.Millisecond, status)
+	}
+
+	service := &AlertService{
+		cfg: AlertConfig{
+			AlertmanagerURL:     server.URL,
+			PagerDutyRoutingKey: "routing-key",
+			PagerDutyURL:        server.URL + "/enqueue",
+			Instance:            "web-1",
+			Interval:            time.Minute,
+			Thresholds:          AlertThresholds{ErrorRate: 0.05},
+		},
+		slo:       slo,
+		usage:     &UsageService{},
+		analytics: &AnalyticsService{eventQueue: make(chan AnalyticsEvent, 1)},
+		webhooks:  &WebhookService{events: make(chan AnalyticsEvent, 1)},
+		client:    server.Client(),
+		logger:    logrus.New(),
+		firing:    map[string]*models.Alert{},
+	}
+
+	// Firing twice: Alertmanager hears about it both times, PagerDuty once
+	for i := 0; i < 2; i++ {
+		if err := service.Evaluate(context.Background(), now.Add(time.Duration(i)*time.Minute)); err != nil {
+			t.Fatalf("Evaluate() returned error: %v", err)
+		}
+	}
+	if len(alertmanager) != 2 || len(alertmanager[1]) != 1 {
+		t.Fatalf("Alertmanager received %v, want the alert twice", alertmanager)
+	}
+	alert := alertmanager[1][0]
+	if alert.Labels["alertname"] != AlertRedirectErrorRate || alert.Labels["instance"] != "web-1" || !alert.StartsAt.Equal(now) || !alert.EndsAt.After(now) {
+		t.Errorf("Alertmanager received %+v", alert)
+	}
+	if len(pagerduty) != 1 || pagerduty[0]["event_action"] != "trigger" || pagerduty[0]["routing_key"] != "routing-key" {
+		t.Errorf("PagerDuty received %v, want one trigger", pagerduty)
+	}
+
+	// The failures age out of the window and the alert resolves
+	now = now.Add(10 * time.Minute)
+	if err := service.Evaluate(context.Background(), now); err != nil {
+		t.Fatalf("Evaluate() returned error: %v", err)
+	}
+	if len(alertmanager) != 3 || !alertmanager[2][0].EndsAt.Equal(now) {
+		t.Errorf("Alertmanager received %v, want the alert resolved", alertmanager)
+	}
+	if len(pagerduty) != 2 || pagerduty[1]["event_action"] != "resolve" || pagerduty[1]["dedup_key"] != pagerduty[0]["dedup_key"] {
+		t.Errorf("PagerDuty received %v, want a matching resolve", pagerduty)
+	}
+
+	status, err := service.Status()
+	if err != nil || len(status.Firing) != 0 || len(status.Rules) != 4 {
+		t.Errorf("Status() = %+v, %v", status, err)
+	}
+}
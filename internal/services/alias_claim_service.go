@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	apperrors "github.com/alexnthnz/url-shortener/internal/errors"
+	"github.com/alexnthnz/url-shortener/internal/models"
+	"github.com/alexnthnz/url-shortener/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// Alias claim outcomes
+const (
+	AliasClaimClaimed  = "claimed"
+	AliasClaimLimited  = "limited"
+	AliasClaimCooldown = "cooldown"
+)
+
+const (
+	// aliasClaimWindow is the period the daily claim limits count over
+	aliasClaimWindow = 24 * time.Hour
+	// maxAliasClaimsListed caps the claims returned by the admin API
+	maxAliasClaimsListed = 1000
+	// maxLoggedAliasLength fits the log column; longer aliases are refused anyway
+	maxLoggedAliasLength = 80
+)
+
+// AliasClaimDecision is the outcome of asking to claim a custom alias
+type AliasClaimDecision struct {
+	Allowed    bool
+	Outcome    string // AliasClaimLimited or AliasClaimCooldown when refused
+	Limit      int
+	RetryAfter time.Duration
+}
+
+// AliasClaimService keeps anyone from registering brandable custom aliases in bulk. Each
+// client IP, or signing key for signed requests, may claim a limited number of aliases a
+// day and must wait a cooldown between two claims. Claims and refusals are logged for
+// admins.
+type AliasClaimService struct {
+	repo     *repository.AliasClaimRepository
+	cache    repository.Cache
+	ipLimit  int
+	keyLimit int
+	cooldown time.Duration
+	logger   *logrus.Logger
+}
+
+func NewAliasClaimService(repo *repository.AliasClaimRepository, cache repository.Cache, ipLimit, keyLimit int, cooldown time.Duration, logger *logrus.Logger) *AliasClaimService {
+	return &AliasClaimService{
+		repo:     repo,
+		cache:    cache,
+		ipLimit:  ipLimit,
+		keyLimit: keyLimit,
+		cooldown: cooldown,
+		logger:   logger,
+	}
+}
+
+// Allow counts an attempt by actor, as returned by handlers.RequestActor, to claim an
+// alias. Attempts count whether or not the alias turns out to be available, so probing
+// for free aliases uses up the allowance too. If Redis is unavailable the claim is allowed.
+func (s *AliasClaimService) Allow(ctx context.Context, actor, alias string) AliasClaimDecision {
+	limit := s.ipLimit
+	if strings.HasPrefix(actor, "key:") {
+		limit = s.keyLimit
+	}
+
+	if s.cooldown > 0 {
+		started, err := s.cache.SetNX(ctx, aliasClaimCooldownKey(actor), "1", s.cooldown)
+		if err != nil {
+			s.logger.Warnf("Failed to check alias claim cooldown: %v", err)
+			return AliasClaimDecision{Allowed: true, Limit: limit}
+		}
+		if !started {
+			retryAfter, err := s.cache.TTL(ctx, aliasClaimCooldownKey(actor))
+			if err != nil || retryAfter < 0 {
+				retryAfter = s.cooldown
+			}
+			s.log(actor, alias, "", AliasClaimCooldown)
+			return AliasClaimDecision{Outcome: AliasClaimCooldown, Limit: limit, RetryAfter: retryAfter}
+		}
+	}
+
+	if limit <= 0 {
+		return AliasClaimDecision{Allowed: true}
+	}
+	count, resetIn, err := s.cache.IncrWindow(ctx, aliasClaimCountKey(actor), aliasClaimWindow)
+	if err != nil {
+		s.logger.Warnf("Failed to count alias claim: %v", err)
+		return AliasClaimDecision{Allowed: true, Limit: limit}
+	}
+	if count > int64(limit) {
+		s.log(actor, alias, "", AliasClaimLimited)
+		return AliasClaimDecision{Outcome: AliasClaimLimited, Limit: limit, RetryAfter: resetIn}
+	}
+	return AliasClaimDecision{Allowed: true, Limit: limit}
+}
+
+// Claimed logs an alias that actor attached to a link
+func (s *AliasClaimService) Claimed(actor, alias, shortCode string) {
+	s.log(actor, alias, shortCode, AliasClaimClaimed)
+}
+
+// List returns logged claims of the last number of days, newest first, optionally only
+// those of one actor or with one outcome
+func (s *AliasClaimService) List(actor, outcome string, days int) ([]*models.AliasClaim, error) {
+	if outcome != "" && outcome != AliasClaimClaimed && outcome != AliasClaimLimited && outcome != AliasClaimCooldown {
+		return nil, apperrors.Errorf(apperrors.ErrInvalid, "invalid outcome: must be %q, %q or %q", AliasClaimClaimed, AliasClaimLimited, AliasClaimCooldown)
+	}
+
+	since := time.Now().UTC().AddDate(0, 0, -days)
+	claims, err := s.repo.List(actor, outcome, since, maxAliasClaimsListed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list alias claims: %w", err)
+	}
+	return claims, nil
+}
+
+// log records a claim; the log is for review only, so failures do not block the request
+func (s *AliasClaimService) log(actor, alias, shortCode, outcome string) {
+	if runes := []rune(alias); len(runes) > maxLoggedAliasLength {
+		alias = string(runes[:maxLoggedAliasLength])
+	}
+	claim := &models.AliasClaim{Actor: actor, Alias: alias, ShortCode: shortCode, Outcome: outcome}
+	if err := s.repo.Create(claim); err != nil {
+		s.logger.Warnf("Failed to log alias claim: %v", err)
+	}
+}
+
+func aliasClaimCountKey(actor string) string {
+	return "alias_claims:" + actor
+}
+
+func aliasClaimCooldownKey(actor string) string {
+	return "alias_claim_cooldown:" + actor
+}
@@ -1,11 +1,16 @@
 package services
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net"
+	"net/url"
 	"strings"
+	"sync/atomic"
 	"time"
 
+	apperrors "github.com/alexnthnz/url-shortener/internal/errors"
 	"github.com/alexnthnz/url-shortener/internal/models"
 	"github.com/alexnthnz/url-shortener/internal/repository"
 	"github.com/sirupsen/logrus"
@@ -13,27 +18,83 @@ import (
 
 // AnalyticsEvent represents an analytics event to be processed
 type AnalyticsEvent struct {
+	ClickID   string
+	VisitorID string
 	ShortCode string
 	IPAddress string
 	UserAgent string
+	Referrer  string
+	ViaQR     bool
+	Internal  bool // from one of the internal networks
+	Rule      *int // redirect rule that picked the destination, nil for the link's own
 	Timestamp time.Time
 }
 
+// AnalyticsDrainResult reports what happened to the events buffered at shutdown
+type AnalyticsDrainResult struct {
+	Persisted int
+	Dropped   int
+}
+
+// maxJourneyTouchpoints caps the clicks returned for one visitor journey
+const maxJourneyTouchpoints = 1000
+
+// Bucket sizes of the per-link analytics time series
+const (
+	AnalyticsIntervalHour = "hour"
+	AnalyticsIntervalDay  = "day"
+	AnalyticsIntervalWeek = "week"
+)
+
+const (
+	// maxAnalyticsBuckets caps the time series length, e.g. 83 days of hourly buckets
+	maxAnalyticsBuckets = 2000
+	// analyticsTopUserAgents is the number of user agents listed in link analytics
+	analyticsTopUserAgents = 10
+	// analyticsTopReferrers is the number of referring hosts listed in link statistics
+	analyticsTopReferrers = 10
+	// analyticsTopClients is the number of browsers and operating systems listed
+	analyticsTopClients = 10
+	// maxReferrerLength caps the stored referrer, like the user agent
+	maxReferrerLength = 500
+)
+
 type AnalyticsService struct {
-	analyticsRepo *repository.AnalyticsRepository
+	analyticsRepo repository.AnalyticsStore
+	mirror        repository.AnalyticsStore // optional double-write target during a backend migration
+	webhooks      *WebhookService
+	trending      *TrendingService
+	forwarder     *EventForwardingService // optional, forwards recorded clicks to product analytics
+	stream        *EventStreamService     // optional, publishes recorded clicks to Kafka
+	internal      *InternalNetworks       // optional, no click is internal without it
+	logPrivacy    *LogPrivacy
 	logger        *logrus.Logger
 	eventQueue    chan AnalyticsEvent
 	batchSize     int
 	flushInterval time.Duration
+
+	// Stop hands its context to the processor, which drains the queue and replies on drained
+	stopping atomic.Bool
+	stop     chan context.Context
+	drained  chan AnalyticsDrainResult
 }
 
-func NewAnalyticsService(analyticsRepo *repository.AnalyticsRepository, logger *logrus.Logger) *AnalyticsService {
+func NewAnalyticsService(analyticsRepo, mirror repository.AnalyticsStore, webhooks *WebhookService, trending *TrendingService, forwarder *EventForwardingService, stream *EventStreamService, internal *InternalNetworks, logPrivacy *LogPrivacy, logger *logrus.Logger) *AnalyticsService {
 	service := &AnalyticsService{
 		analyticsRepo: analyticsRepo,
+		mirror:        mirror,
+		webhooks:      webhooks,
+		trending:      trending,
+		forwarder:     forwarder,
+		stream:        stream,
+		internal:      internal,
+		logPrivacy:    logPrivacy,
 		logger:        logger,
 		eventQueue:    make(chan AnalyticsEvent, 10000), // Buffered channel for async processing
 		batchSize:     100,
 		flushInterval: 5 * time.Second,
+		stop:          make(chan context.Context),
+		drained:       make(chan AnalyticsDrainResult, 1),
 	}
 
 	// Start async processor
@@ -43,12 +104,16 @@ func NewAnalyticsService(analyticsRepo *repository.AnalyticsRepository, logger *
 }
 
 // RecordClickAsync queues a click event for async processing (non-blocking)
-func (s *AnalyticsService) RecordClickAsync(shortCode, ipAddress, userAgent string) {
-	event := AnalyticsEvent{
-		ShortCode: shortCode,
-		IPAddress: s.sanitizeIPAddress(ipAddress),
-		UserAgent: s.sanitizeUserAgent(userAgent),
-		Timestamp: time.Now(),
+func (s *AnalyticsService) RecordClickAsync(event AnalyticsEvent) {
+	event.IPAddress = s.sanitizeIPAddress(event.IPAddress)
+	event.Internal = s.internal.Contains(event.IPAddress)
+	event.UserAgent = s.sanitizeUserAgent(event.UserAgent)
+	event.Referrer = sanitizeReferrer(event.Referrer)
+	event.Timestamp = time.Now()
+
+	if s.stopping.Load() {
+		s.logger.Warn("Analytics pipeline is stopped, dropping click event")
+		return
 	}
 
 	// Non-blocking send to queue
@@ -59,29 +124,56 @@ func (s *AnalyticsService) RecordClickAsync(shortCode, ipAddress, userAgent stri
 		// Queue is full, log warning but don't block redirect
 		s.logger.Warn("Analytics queue full, dropping click event")
 	}
+
+	// Webhook delivery has its own queue so slow consumers never stall analytics
+	s.webhooks.NotifyClick(event)
+}
+
+// QueueDepth returns the click events waiting to be recorded
+func (s *AnalyticsService) QueueDepth() int {
+	return len(s.eventQueue)
+}
+
+// QueueDepths returns the click events waiting in each queue of this instance
+func (s *AnalyticsService) QueueDepths() map[string]int {
+	depths := map[string]int{"analytics": s.QueueDepth()}
+	if s.webhooks != nil {
+		depths["webhooks"] = s.webhooks.QueueDepth()
+	}
+	return depths
 }
 
 // RecordClick records a click event for analytics (blocking - for backward compatibility)
-func (s *AnalyticsService) RecordClick(shortCode, ipAddress, userAgent string) error {
+func (s *AnalyticsService) RecordClick(ctx context.Context, shortCode, ipAddress, userAgent string) error {
 	// Sanitize inputs
 	cleanIP := s.sanitizeIPAddress(ipAddress)
 	cleanUserAgent := s.sanitizeUserAgent(userAgent)
 
+	client := ParseUserAgent(cleanUserAgent)
 	analytics := &models.Analytics{
-		ShortCode: shortCode,
-		IPAddress: cleanIP,
-		UserAgent: cleanUserAgent,
+		ShortCode:  shortCode,
+		IPAddress:  cleanIP,
+		UserAgent:  cleanUserAgent,
+		DeviceType: client.DeviceType,
+		Browser:    client.Browser,
+		OS:         client.OS,
+		IsBot:      IsBot(cleanUserAgent),
+		IsInternal: s.internal.Contains(cleanIP),
 	}
 
-	if err := s.analyticsRepo.RecordClick(analytics); err != nil {
+	if err := s.analyticsRepo.RecordClick(ctx, analytics); err != nil {
 		return fmt.Errorf("failed to record click: %w", err)
 	}
+	s.mirrorClicks(ctx, []*models.Analytics{analytics})
+	s.trending.Record([]*models.Analytics{analytics})
+	s.forwarder.Forward([]*models.Analytics{analytics})
+	s.stream.PublishClicks([]*models.Analytics{analytics})
 
-	s.logger.Infof("Click recorded for short code: %s", shortCode)
+	s.logger.Infof("Click recorded for short code: %s", s.logPrivacy.ShortCode(shortCode))
 	return nil
 }
 
-// processEvents processes analytics events asynchronously in batches
+// processEvents processes analytics events asynchronously in batches until Stop is called
 func (s *AnalyticsService) processEvents() {
 	batch := make([]*models.Analytics, 0, s.batchSize)
 	ticker := time.NewTicker(s.flushInterval)
@@ -90,48 +182,276 @@ func (s *AnalyticsService) processEvents() {
 	for {
 		select {
 		case event := <-s.eventQueue:
-			analytics := &models.Analytics{
-				ShortCode: event.ShortCode,
-				IPAddress: event.IPAddress,
-				UserAgent: event.UserAgent,
-			}
-			batch = append(batch, analytics)
+			batch = append(batch, event.toAnalytics())
 
 			// Flush batch if it reaches target size
 			if len(batch) >= s.batchSize {
-				s.flushBatch(batch)
+				s.flushBatch(context.Background(), batch)
 				batch = batch[:0] // Reset slice
 			}
 
 		case <-ticker.C:
 			// Flush batch on timer
 			if len(batch) > 0 {
-				s.flushBatch(batch)
+				s.flushBatch(context.Background(), batch)
 				batch = batch[:0] // Reset slice
 			}
+
+		case ctx := <-s.stop:
+			s.drained <- s.drain(ctx, batch)
+			return
+		}
+	}
+}
+
+// Stop stops accepting click events, then persists the queued ones and the final batch.
+// Events still queued when ctx is done are dropped; a write already in progress is
+// allowed to finish.
+func (s *AnalyticsService) Stop(ctx context.Context) (AnalyticsDrainResult, error) {
+	if !s.stopping.CompareAndSwap(false, true) {
+		return AnalyticsDrainResult{}, errors.New("analytics pipeline already stopped")
+	}
+
+	s.stop <- ctx
+	return <-s.drained, nil
+}
+
+// drain flushes the pending batch and everything left in the queue
+func (s *AnalyticsService) drain(ctx context.Context, batch []*models.Analytics) AnalyticsDrainResult {
+	var result AnalyticsDrainResult
+	for {
+		if ctx.Err() != nil {
+			result.Dropped += len(batch) + len(s.eventQueue)
+			return result
+		}
+
+		select {
+		case event := <-s.eventQueue:
+			batch = append(batch, event.toAnalytics())
+			if len(batch) < s.batchSize {
+				continue
+			}
+		default:
+			// Queue is empty, flush the final batch
+			if len(batch) == 0 {
+				return result
+			}
 		}
+
+		persisted := s.flushBatch(context.Background(), batch)
+		result.Persisted += persisted
+		result.Dropped += len(batch) - persisted
+		batch = batch[:0]
 	}
 }
 
-// flushBatch processes a batch of analytics events
-func (s *AnalyticsService) flushBatch(batch []*models.Analytics) {
+// toAnalytics converts a queued event to a click record. The user agent is parsed here,
+// in the background processor, to keep that work off the redirect path.
+func (e AnalyticsEvent) toAnalytics() *models.Analytics {
+	client := ParseUserAgent(e.UserAgent)
+	return &models.Analytics{
+		ClickID:      e.ClickID,
+		VisitorID:    e.VisitorID,
+		ShortCode:    e.ShortCode,
+		IPAddress:    e.IPAddress,
+		UserAgent:    e.UserAgent,
+		Referrer:     e.Referrer,
+		DeviceType:   client.DeviceType,
+		Browser:      client.Browser,
+		OS:           client.OS,
+		IsBot:        IsBot(e.UserAgent),
+		IsInternal:   e.Internal,
+		ViaQR:        e.ViaQR,
+		RedirectRule: e.Rule,
+	}
+}
+
+// flushBatch processes a batch of analytics events, returning how many were recorded
+func (s *AnalyticsService) flushBatch(ctx context.Context, batch []*models.Analytics) int {
+	recorded := make([]*models.Analytics, 0, len(batch))
 	for _, analytics := range batch {
-		if err := s.analyticsRepo.RecordClick(analytics); err != nil {
+		if err := s.analyticsRepo.RecordClick(ctx, analytics); err != nil {
 			s.logger.Errorf("Failed to record click in batch: %v", err)
+			continue
 		}
+		recorded = append(recorded, analytics)
 	}
+	s.mirrorClicks(ctx, recorded)
+	s.trending.Record(recorded)
+	s.forwarder.Forward(recorded)
+	s.stream.PublishClicks(recorded)
 	s.logger.Debugf("Processed analytics batch of %d events", len(batch))
+	return len(recorded)
+}
+
+// mirrorClicks double-writes recorded clicks to the mirror with their primary ids.
+// The primary stays the source of truth: a failed copy is only logged, and the gap
+// is closed by the next analytics backfill.
+func (s *AnalyticsService) mirrorClicks(ctx context.Context, clicks []*models.Analytics) {
+	if s.mirror == nil || len(clicks) == 0 {
+		return
+	}
+	if _, err := s.mirror.CopyClicks(ctx, clicks); err != nil {
+		s.logger.Warnf("Failed to mirror %d click(s), run the analytics backfill to repair: %v", len(clicks), err)
+	}
+}
+
+// GetVisitorJourney returns the clicks of one attributed visitor across all links, oldest first
+func (s *AnalyticsService) GetVisitorJourney(ctx context.Context, visitorID string) (*models.VisitorJourney, error) {
+	touchpoints, err := s.analyticsRepo.GetVisitorClicks(ctx, visitorID, maxJourneyTouchpoints)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get visitor clicks: %w", err)
+	}
+	if len(touchpoints) == 0 {
+		return nil, apperrors.Errorf(apperrors.ErrNotFound, "visitor not found")
+	}
+
+	return &models.VisitorJourney{
+		VisitorID:   visitorID,
+		FirstTouch:  touchpoints[0].ShortCode,
+		LastTouch:   touchpoints[len(touchpoints)-1].ShortCode,
+		Touchpoints: touchpoints,
+	}, nil
 }
 
 // GetClickCount returns the total click count for a short code
-func (s *AnalyticsService) GetClickCount(shortCode string) (int64, error) {
-	count, err := s.analyticsRepo.GetClickCount(shortCode)
+func (s *AnalyticsService) GetClickCount(ctx context.Context, shortCode string) (int64, error) {
+	count, err := s.analyticsRepo.GetClickCount(ctx, shortCode)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get click count: %w", err)
 	}
 	return count, nil
 }
 
+// GetURLAnalytics returns the clicks of a short code within [from, to) bucketed by hour,
+// day or week, with unique visitors and the top user agents. Buckets are in UTC, weeks
+// start on Monday, and buckets without clicks are included with a count of 0. Clicks of
+// bots only appear in the bot click count, and internal clicks only in the internal click
+// count unless includeInternal counts them as clicks by people.
+func (s *AnalyticsService) GetURLAnalytics(ctx context.Context, shortCode, interval string, from, to time.Time, includeInternal bool) (*models.URLAnalytics, error) {
+	from, to = from.UTC(), to.UTC()
+	starts, err := analyticsBucketStarts(interval, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	summary, err := s.analyticsRepo.GetClickSummary(ctx, shortCode, from, to, includeInternal)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get click summary: %w", err)
+	}
+	counted, err := s.analyticsRepo.GetClickBuckets(ctx, shortCode, interval, from, to, includeInternal)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get click buckets: %w", err)
+	}
+	userAgents, err := s.analyticsRepo.GetUserAgentCounts(ctx, shortCode, from, to, includeInternal)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user agents: %w", err)
+	}
+	breakdown, err := s.GetTrafficBreakdown(ctx, shortCode, from, to, includeInternal)
+	if err != nil {
+		return nil, err
+	}
+	variants, err := s.analyticsRepo.GetVariantCounts(ctx, shortCode, from, to, includeInternal)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get variant counts: %w", err)
+	}
+	if len(variants) == 1 && variants[0].Rule == nil {
+		variants = nil
+	}
+
+	byStart := make(map[int64]int64, len(counted))
+	for _, bucket := range counted {
+		byStart[bucket.Start.Unix()] += bucket.Clicks
+	}
+	buckets := make([]models.ClickBucket, len(starts))
+	for i, start := range starts {
+		buckets[i] = models.ClickBucket{Start: start, Clicks: byStart[start.Unix()]}
+	}
+
+	return &models.URLAnalytics{
+		ShortCode:        shortCode,
+		Interval:         interval,
+		From:             from,
+		To:               to,
+		TotalClicks:      summary.Clicks,
+		BotClicks:        summary.BotClicks,
+		InternalClicks:   summary.InternalClicks,
+		QRScans:          summary.QRScans,
+		UniqueVisitors:   summary.Visitors,
+		Buckets:          buckets,
+		TopUserAgents:    topDimensions(userAgents, analyticsTopUserAgents),
+		TrafficBreakdown: breakdown,
+		Variants:         variants,
+	}, nil
+}
+
+// GetTrafficBreakdown returns the top referring hosts, browsers and operating systems of
+// the clicks of a short code within [from, to), and its clicks per device type. A zero
+// from covers all clicks.
+func (s *AnalyticsService) GetTrafficBreakdown(ctx context.Context, shortCode string, from, to time.Time, includeInternal bool) (models.TrafficBreakdown, error) {
+	referrers, err := s.analyticsRepo.GetReferrerCounts(ctx, shortCode, from, to, includeInternal)
+	if err != nil {
+		return models.TrafficBreakdown{}, fmt.Errorf("failed to get referrers: %w", err)
+	}
+	devices, browsers, systems, err := s.analyticsRepo.GetClientCounts(ctx, shortCode, from, to, includeInternal)
+	if err != nil {
+		return models.TrafficBreakdown{}, fmt.Errorf("failed to get client counts: %w", err)
+	}
+
+	return models.TrafficBreakdown{
+		TopReferrers:     topDimensions(mergeReferrerHosts(referrers), analyticsTopReferrers),
+		Devices:          topDimensions(devices, len(devices)),
+		Browsers:         topDimensions(browsers, analyticsTopClients),
+		OperatingSystems: topDimensions(systems, analyticsTopClients),
+	}, nil
+}
+
+// mergeReferrerHosts counts the Unicode and punycode spellings of a referring host together
+func mergeReferrerHosts(counts map[string]int64) map[string]int64 {
+	merged := make(map[string]int64, len(counts))
+	for host, count := range counts {
+		if canonical, err := CanonicalHost(host); err == nil {
+			host = canonical
+		}
+		merged[host] += count
+	}
+	return merged
+}
+
+// analyticsBucketStarts lists the start of every bucket overlapping [from, to), truncated
+// the way PostgreSQL's date_trunc does in UTC
+func analyticsBucketStarts(interval string, from, to time.Time) ([]time.Time, error) {
+	if !to.After(from) {
+		return nil, apperrors.Errorf(apperrors.ErrInvalid, "invalid range: to must be after from")
+	}
+
+	var start time.Time
+	var next func(time.Time) time.Time
+	switch interval {
+	case AnalyticsIntervalHour:
+		start = from.Truncate(time.Hour)
+		next = func(t time.Time) time.Time { return t.Add(time.Hour) }
+	case AnalyticsIntervalDay:
+		start = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+		next = func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }
+	case AnalyticsIntervalWeek:
+		day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+		start = day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+		next = func(t time.Time) time.Time { return t.AddDate(0, 0, 7) }
+	default:
+		return nil, apperrors.Errorf(apperrors.ErrInvalid, "invalid interval: must be %q, %q or %q", AnalyticsIntervalHour, AnalyticsIntervalDay, AnalyticsIntervalWeek)
+	}
+
+	var starts []time.Time
+	for t := start; t.Before(to); t = next(t) {
+		if len(starts) == maxAnalyticsBuckets {
+			return nil, apperrors.Errorf(apperrors.ErrInvalid, "invalid range: more than %d buckets, use a shorter range or a longer interval", maxAnalyticsBuckets)
+		}
+		starts = append(starts, t)
+	}
+	return starts, nil
+}
+
 // sanitizeIPAddress cleans and validates IP address
 func (s *AnalyticsService) sanitizeIPAddress(ipAddress string) string {
 	// Handle X-Forwarded-For header (take the first IP)
@@ -147,6 +467,22 @@ func (s *AnalyticsService) sanitizeIPAddress(ipAddress string) string {
 	return ipAddress
 }
 
+// sanitizeReferrer reduces a Referer header to the scheme, host and path of an HTTP(S)
+// page. Query strings and fragments often carry session tokens or search terms, and
+// credentials never belong in analytics, so they are dropped. Anything else is "".
+func sanitizeReferrer(referrer string) string {
+	parsed, err := url.Parse(strings.TrimSpace(referrer))
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Hostname() == "" {
+		return ""
+	}
+
+	cleaned := (&url.URL{Scheme: parsed.Scheme, Host: parsed.Host, Path: parsed.Path}).String()
+	if len(cleaned) > maxReferrerLength {
+		cleaned = cleaned[:maxReferrerLength]
+	}
+	return cleaned
+}
+
 // sanitizeUserAgent cleans user agent string
 func (s *AnalyticsService) sanitizeUserAgent(userAgent string) string {
 	// Limit length to prevent storage issues
@@ -0,0 +1,41 @@
+package services
+
+import "strings"
+
+// knownCrawlers are user agent tokens of search engines, link unfurlers of chat and social
+// apps, SEO tools and AI crawlers whose names do not all say "bot"
+var knownCrawlers = []string{
+	"googlebot", "adsbot-google", "mediapartners-google", "google-inspectiontool", "storebot-google",
+	"bingbot", "bingpreview", "slurp", "duckduckbot", "baiduspider", "yandexbot", "exabot",
+	"applebot", "petalbot", "seznambot", "qwantify",
+	"facebookexternalhit", "facebookcatalog", "meta-externalagent", "twitterbot", "linkedinbot",
+	"pinterestbot", "redditbot", "slackbot", "slack-imgproxy", "discordbot", "telegrambot",
+	"whatsapp", "skypeuripreview", "vkshare", "embedly", "iframely", "outbrain", "quora link preview",
+	"ahrefsbot", "semrushbot", "mj12bot", "dotbot", "rogerbot", "screaming frog", "dataforseobot",
+	"gptbot", "chatgpt-user", "oai-searchbot", "ccbot", "anthropic-ai", "perplexitybot", "bytespider",
+	"amazonbot", "uptimerobot", "pingdom", "statuscake", "site24x7", "datadog synthetic",
+}
+
+// botTokens are generic markers of automated clients: crawlers, headless browsers and
+// HTTP libraries
+var botTokens = []string{
+	"bot", "crawl", "spider", "scrape", "preview", "fetcher", "monitor", "headless", "phantomjs",
+	"lighthouse", "curl/", "wget/", "httpie/", "python-requests", "python-urllib", "aiohttp",
+	"go-http-client", "okhttp", "java/", "apache-httpclient", "libwww-perl", "node-fetch", "axios/",
+}
+
+// IsBot reports whether a click most likely comes from a crawler or a script rather than
+// a person: known crawler names, generic automation markers, and clicks without a user
+// agent, which browsers always send.
+func IsBot(userAgent string) bool {
+	ua := strings.ToLower(strings.TrimSpace(userAgent))
+	if ua == "" || ua == "unknown" {
+		return true
+	}
+	return isBotUA(ua)
+}
+
+// isBotUA checks a lowercased user agent against the crawler list and automation markers
+func isBotUA(ua string) bool {
+	return containsAny(ua, knownCrawlers) || containsAny(ua, botTokens)
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alexnthnz/url-shortener/internal/models"
+)
+
+const (
+	CohortStable = "stable"
+	CohortCanary = "canary"
+)
+
+// Redirect features that can be rolled out to the canary cohort first
+const (
+	FeatureNegativeCache = "negative_cache"
+	FeatureEarlyRefresh  = "early_refresh"
+)
+
+var canaryFeatures = []string{FeatureNegativeCache, FeatureEarlyRefresh}
+
+// cohortKey carries the cohort of a request in its context
+type cohortKey struct{}
+
+// WithCohort returns a context carrying the cohort a request was assigned to
+func WithCohort(ctx context.Context, cohort string) context.Context {
+	return context.WithValue(ctx, cohortKey{}, cohort)
+}
+
+// cohortCounters accumulates request outcomes for one cohort
+type cohortCounters struct {
+	requests     int64
+	serverErrors int64
+	totalLatency time.Duration
+	maxLatency   time.Duration
+}
+
+// CanaryService assigns traffic to the stable or canary cohort and tracks per-cohort
+// outcomes. Features under canary run only for canary requests, so their effect shows in
+// the per-cohort metrics before they are turned on for everyone.
+type CanaryService struct {
+	percent  float64
+	features map[string]bool
+
+	mu      sync.Mutex
+	cohorts map[string]*cohortCounters
+}
+
+func NewCanaryService(percent float64, features []string) (*CanaryService, error) {
+	service := &CanaryService{
+		percent:  percent,
+		features: make(map[string]bool, len(features)),
+		cohorts: map[string]*cohortCounters{
+			CohortStable: {},
+			CohortCanary: {},
+		},
+	}
+	for _, feature := range features {
+		if !slices.Contains(canaryFeatures, feature) {
+			return nil, fmt.Errorf("unknown canary feature %q: must be one of %s", feature, strings.Join(canaryFeatures, ", "))
+		}
+		service.features[feature] = true
+	}
+	return service, nil
+}
+
+// FeatureOn reports whether a feature applies to the request of ctx: always, unless the
+// feature is under canary, then only for canary requests
+func (s *CanaryService) FeatureOn(ctx context.Context, feature string) bool {
+	if s == nil || !s.features[feature] {
+		return true
+	}
+	cohort, _ := ctx.Value(cohortKey{}).(string)
+	return cohort == CohortCanary
+}
+
+// Enabled reports whether any traffic is routed to the canary
+func (s *CanaryService) Enabled() bool {
+	return s.percent > 0
+}
+
+// Assign picks a cohort for a request without an explicit preference
+func (s *CanaryService) Assign() string {
+	if rand.Float64()*100 < s.percent {
+		return CohortCanary
+	}
+	return CohortStable
+}
+
+// Record counts the outcome of a request served by a cohort
+func (s *CanaryService) Record(cohort string, duration time.Duration, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	counters := s.cohorts[cohort]
+	if counters == nil {
+		return
+	}
+
+	counters.requests++
+	if status >= 500 {
+		counters.serverErrors++
+	}
+	counters.totalLatency += duration
+	if duration > counters.maxLatency {
+		counters.maxLatency = duration
+	}
+}
+
+// Metrics returns per-cohort request metrics since startup
+func (s *CanaryService) Metrics() map[string]models.CohortMetrics {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	metrics := make(map[string]models.CohortMetrics, len(s.cohorts))
+	for name, counters := range s.cohorts {
+		cohort := models.CohortMetrics{
+			Requests:     counters.requests,
+			ServerErrors: counters.serverErrors,
+			MaxLatencyMs: float64(counters.maxLatency) / float64(time.Millisecond),
+		}
+		if counters.requests > 0 {
+			cohort.ErrorRate = float64(counters.serverErrors) / float64(counters.requests)
+			cohort.AvgLatencyMs = float64(counters.totalLatency) / float64(counters.requests) / float64(time.Millisecond)
+		}
+		metrics[name] = cohort
+	}
+	return metrics
+}
@@ -0,0 +1,42 @@
+package services
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCanaryFeatures(t *testing.T) {
+	canary, err := NewCanaryService(10, []string{FeatureNegativeCache})
+	if err != nil {
+		t.Fatalf("NewCanaryService() returned error: %v", err)
+	}
+
+	tests := []struct {
+		feature string
+		cohort  string
+		on      bool
+	}{
+		{FeatureNegativeCache, CohortCanary, true},
+		{FeatureNegativeCache, CohortStable, false},
+		{FeatureNegativeCache, "", false},
+		{FeatureEarlyRefresh, CohortStable, true},
+		{FeatureEarlyRefresh, "", true},
+	}
+	for _, test := range tests {
+		ctx := context.Background()
+		if test.cohort != "" {
+			ctx = WithCohort(ctx, test.cohort)
+		}
+		if on := canary.FeatureOn(ctx, test.feature); on != test.on {
+			t.Errorf("FeatureOn(%s, cohort %q) = %v; expected %v", test.feature, test.cohort, on, test.on)
+		}
+	}
+
+	var none *CanaryService
+	if !none.FeatureOn(context.Background(), FeatureNegativeCache) {
+		t.Error("features are on for everyone without a canary service")
+	}
+	if _, err := NewCanaryService(10, []string{"new_cache"}); err == nil {
+		t.Error("NewCanaryService() should reject an unknown feature")
+	}
+}
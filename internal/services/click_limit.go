@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	apperrors "github.com/alexnthnz/url-shortener/internal/errors"
+)
+
+// clickLimitSyncInterval is how often click counts of capped links are copied from Redis
+// to the database
+const clickLimitSyncInterval = 10 * time.Second
+
+// ConsumeClick counts a redirect against the link's click cap and reports whether the
+// link is capped at all. Once the cap is used up it fails with "click limit reached".
+//
+// Redis holds the authoritative counter so every instance shares it; the database copy is
+// refreshed in the background and seeds the counter again if Redis loses it. While Redis
+// is unavailable the database counts clicks itself.
+func (s *URLService) ConsumeClick(ctx context.Context, shortCode string) (bool, error) {
+	maxClicks, err := s.clickCap(ctx, shortCode)
+	if err != nil {
+		return false, err
+	}
+	if maxClicks == 0 {
+		return false, nil
+	}
+
+	count, err := s.incrClickCount(ctx, shortCode)
+	if err != nil {
+		s.logger.Warnf("Failed to count click in cache, using the database: %v", err)
+		allowed, err := s.urlRepo.ConsumeClick(ctx, shortCode)
+		if err != nil {
+			return true, fmt.Errorf("failed to count click: %w", err)
+		}
+		if !allowed {
+			return true, apperrors.Errorf(apperrors.ErrClickLimit, "click limit reached")
+		}
+		return true, nil
+	}
+	if count > maxClicks {
+		return true, apperrors.Errorf(apperrors.ErrClickLimit, "click limit reached")
+	}
+
+	s.clickCountsMu.Lock()
+	if s.clickCounts == nil {
+		s.clickCounts = make(map[string]int64)
+	}
+	if count > s.clickCounts[shortCode] {
+		s.clickCounts[shortCode] = count
+	}
+	s.clickCountsMu.Unlock()
+
+	return true, nil
+}
+
+// clicksRemaining returns how many redirects a capped link has left
+func (s *URLService) clicksRemaining(ctx context.Context, shortCode string, maxClicks int64) (int64, error) {
+	used, err := s.cache.Get(ctx, clickCountKey(shortCode))
+	count, parseErr := strconv.ParseInt(used, 10, 64)
+	if err != nil || parseErr != nil {
+		if count, err = s.urlRepo.GetClicksUsed(ctx, shortCode); err != nil {
+			return 0, fmt.Errorf("failed to get clicks used: %w", err)
+		}
+	}
+	if count >= maxClicks {
+		return 0, nil
+	}
+	return maxClicks - count, nil
+}
+
+// clickCap returns the link's click cap, or 0 when it has none
+func (s *URLService) clickCap(ctx context.Context, shortCode string) (int64, error) {
+	if cached, err := s.cache.Get(ctx, clickCapCacheKey(shortCode)); err == nil {
+		if maxClicks, err := strconv.ParseInt(cached, 10, 64); err == nil {
+			return maxClicks, nil
+		}
+	}
+
+	urlRecord, err := s.urlRepo.GetByShortCode(ctx, shortCode)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get URL: %w", err)
+	}
+	if urlRecord == nil {
+		return 0, apperrors.Errorf(apperrors.ErrNotFound, "URL not found")
+	}
+
+	var maxClicks int64
+	if urlRecord.MaxClicks != nil {
+		maxClicks = *urlRecord.MaxClicks
+	}
+	if err := s.cache.Set(ctx, clickCapCacheKey(shortCode), strconv.FormatInt(maxClicks, 10)); err != nil {
+		s.logger.Warnf("Failed to cache click cap: %v", err)
+	}
+	return maxClicks, nil
+}
+
+// incrClickCount increments the shared click counter of a capped link, seeding it from
+// the database when Redis does not have it yet
+func (s *URLService) incrClickCount(ctx context.Context, shortCode string) (int64, error) {
+	key := clickCountKey(shortCode)
+	count, ok, err := s.cache.IncrExisting(ctx, key)
+	if err != nil || ok {
+		return count, err
+	}
+
+	used, err := s.urlRepo.GetClicksUsed(ctx, shortCode)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get clicks used: %w", err)
+	}
+	// Another instance may seed the counter first; either way it is incremented once
+	if _, err := s.cache.SetNX(ctx, key, strconv.FormatInt(used, 10), 0); err != nil {
+		return 0, err
+	}
+	count, _, err = s.cache.IncrExisting(ctx, key)
+	return count, err
+}
+
+// syncClickCountsLoop periodically copies the click counts seen by this instance to the
+// database
+func (s *URLService) syncClickCountsLoop() {
+	ticker := time.NewTicker(clickLimitSyncInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.syncClickCounts()
+	}
+}
+
+func (s *URLService) syncClickCounts() {
+	ctx := context.Background()
+	s.clickCountsMu.Lock()
+	counts := s.clickCounts
+	s.clickCounts = nil
+	s.clickCountsMu.Unlock()
+
+	if len(counts) == 0 {
+		return
+	}
+	if err := s.urlRepo.SyncClicksUsed(ctx, counts); err != nil {
+		s.logger.Warnf("Failed to sync click counts: %v", err)
+
+		// Keep the counts for the next sync unless newer ones arrived meanwhile
+		s.clickCountsMu.Lock()
+		if s.clickCounts == nil {
+			s.clickCounts = make(map[string]int64, len(counts))
+		}
+		for code, count := range counts {
+			if count > s.clickCounts[code] {
+				s.clickCounts[code] = count
+			}
+		}
+		s.clickCountsMu.Unlock()
+	}
+}
+
+// clickCapCacheKey is the cache key of a link's click cap, "0" when it has none
+func clickCapCacheKey(shortCode string) string {
+	return "maxclicks:" + shortCode
+}
+
+// clickCountKey is the Redis key of a capped link's shared click counter
+func clickCountKey(shortCode string) string {
+	return "clicks:" + shortCode
+}
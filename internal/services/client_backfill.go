@@ -0,0 +1,77 @@
+package services
+
+import (
+	"context"
+
+	"github.com/alexnthnz/url-shortener/internal/models"
+	"github.com/alexnthnz/url-shortener/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+const clientBackfillJobType = "client_backfill"
+
+// ClientBackfillService fills in the device type, browser and OS of clicks recorded before
+// user agents were parsed, in the primary database and then the mirror. It runs as a
+// paced background job, so it resumes after a restart and never holds locks for long.
+type ClientBackfillService struct {
+	analyticsRepo repository.AnalyticsStore
+	mirror        repository.AnalyticsStore // optional, see AnalyticsService
+	jobs          *JobService
+	batchSize     int
+	logger        *logrus.Logger
+}
+
+func NewClientBackfillService(analyticsRepo, mirror repository.AnalyticsStore, jobs *JobService, batchSize int, logger *logrus.Logger) *ClientBackfillService {
+	service := &ClientBackfillService{
+		analyticsRepo: analyticsRepo,
+		mirror:        mirror,
+		jobs:          jobs,
+		batchSize:     batchSize,
+		logger:        logger,
+	}
+	jobs.RegisterBatchJob(clientBackfillJobType, service.backfillBatch)
+	return service
+}
+
+// Run starts the backfill job
+func (s *ClientBackfillService) Run() (*models.Job, error) {
+	return s.jobs.StartBatchJob(clientBackfillJobType, storeCursor(0, 0))
+}
+
+// backfillBatch parses the stored user agents of one batch of clicks
+func (s *ClientBackfillService) backfillBatch(ctx context.Context, cursor string) (int64, string, error) {
+	repos := []repository.AnalyticsStore{s.analyticsRepo}
+	if s.mirror != nil {
+		repos = append(repos, s.mirror)
+	}
+
+	index, afterID, err := parseStoreCursor(cursor)
+	if err != nil {
+		return 0, "", err
+	}
+	for ; index < len(repos); index, afterID = index+1, 0 {
+		clicks, err := repos[index].ListUnparsed(ctx, afterID, s.batchSize)
+		if err != nil {
+			return 0, "", err
+		}
+		if len(clicks) == 0 {
+			// This database is done, continue with the next one from the start
+			continue
+		}
+
+		parseClients(clicks)
+		if err := repos[index].SetClientInfo(ctx, clicks); err != nil {
+			return 0, "", err
+		}
+		return int64(len(clicks)), storeCursor(index, clicks[len(clicks)-1].ID), nil
+	}
+	return 0, "", nil
+}
+
+// parseClients sets the client fields of clicks from their user agents as RecordClick does
+func parseClients(clicks []*models.Analytics) {
+	for _, click := range clicks {
+		client := ParseUserAgent(click.UserAgent)
+		click.DeviceType, click.Browser, click.OS = client.DeviceType, client.Browser, client.OS
+	}
+}
@@ -0,0 +1,202 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/alexnthnz/url-shortener/internal/models"
+)
+
+// Code strategies as recorded with every link
+const (
+	CodeStrategySequential    = "sequential"
+	CodeStrategyRandom        = "random"
+	CodeStrategyDeterministic = "deterministic"
+	CodeStrategyPronounceable = CodeStylePronounceable
+	CodeStrategyWords         = CodeStyleWords
+	CodeStrategyCustom        = "custom"
+	CodeStrategyUnclassified  = "unclassified"
+)
+
+// codeStrategyOrder is the order strategies are reported in
+var codeStrategyOrder = []string{
+	CodeStrategySequential, CodeStrategyRandom, CodeStrategyDeterministic, CodeStrategyPronounceable,
+	CodeStrategyWords, CodeStrategyCustom, CodeStrategyUnclassified,
+}
+
+// maxExhaustionDays leaves projections more than a century out of the report
+const maxExhaustionDays = 36500
+
+// codeGenerationStats counts generated codes per strategy, and how many of them were
+// already taken, since the instance started
+type codeGenerationStats struct {
+	mu         sync.Mutex
+	attempts   map[string]int64
+	collisions map[string]int64
+}
+
+func (s *codeGenerationStats) record(strategy string, collided bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.attempts == nil {
+		s.attempts = make(map[string]int64)
+		s.collisions = make(map[string]int64)
+	}
+	s.attempts[strategy]++
+	if collided {
+		s.collisions[strategy]++
+	}
+}
+
+func (s *codeGenerationStats) get(strategy string) (attempts, collisions int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.attempts[strategy], s.collisions[strategy]
+}
+
+// GetCodeSpaceReport reports, per code strategy and length, how many codes are taken out
+// of how many exist, the collisions met while generating codes, and when each length
+// runs out at the rate links were created over the last days
+func (s *URLService) GetCodeSpaceReport(ctx context.Context, days int) (*models.CodeSpaceReport, error) {
+	now := time.Now().UTC()
+	rows, err := s.urlRepo.GetCodeSpaceUsage(ctx, now.AddDate(0, 0, -days))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get code space usage: %w", err)
+	}
+	currentID, err := s.urlRepo.GetCurrentID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get code sequence: %w", err)
+	}
+
+	// Every link draws an id from the sequence, whatever its strategy
+	var idsPerDay float64
+	strategies := make(map[string]*models.CodeStrategy)
+	for _, row := range rows {
+		idsPerDay += float64(row.Recent) / float64(days)
+
+		strategy := strategies[row.Strategy]
+		if strategy == nil {
+			strategy = &models.CodeStrategy{Strategy: row.Strategy, Lengths: []models.CodeLengthUsage{}}
+			strategies[row.Strategy] = strategy
+		}
+		strategy.Links += row.Links
+		strategy.Lengths = append(strategy.Lengths, models.CodeLengthUsage{
+			Length:        row.Length,
+			Links:         row.Links,
+			Capacity:      s.codeCapacity(row.Strategy, row.Length),
+			CreatedPerDay: float64(row.Recent) / float64(days),
+		})
+	}
+
+	report := &models.CodeSpaceReport{GeneratedAt: now, Days: days, Strategies: []models.CodeStrategy{}}
+	for _, name := range codeStrategyOrder {
+		attempts, collisions := s.codeStats.get(name)
+		strategy := strategies[name]
+		if strategy == nil {
+			if attempts == 0 {
+				continue
+			}
+			strategy = &models.CodeStrategy{Strategy: name, Lengths: []models.CodeLengthUsage{}}
+		}
+		strategy.Attempts, strategy.Collisions = attempts, collisions
+		if attempts > 0 {
+			strategy.CollisionRate = float64(collisions) / float64(attempts)
+		}
+
+		for i := range strategy.Lengths {
+			usage := &strategy.Lengths[i]
+			if usage.Capacity == 0 {
+				continue
+			}
+			if name == CodeStrategySequential {
+				s.projectSequential(usage, currentID, idsPerDay, now)
+			} else {
+				projectExhaustion(usage, usage.Links, usage.CreatedPerDay, now)
+			}
+		}
+		report.Strategies = append(report.Strategies, *strategy)
+	}
+	return report, nil
+}
+
+// projectSequential measures a sequential code length by the position of the sequence:
+// ids are never reused, so codes of deleted links and ids taken by other strategies count
+// as used. Sequential codes grow by a character once the sequence passes a length.
+func (s *URLService) projectSequential(usage *models.CodeLengthUsage, currentID int64, idsPerDay float64, now time.Time) {
+	base := usage.Length
+	if s.checksumDigit {
+		base--
+	}
+	first := int64(0)
+	if base > 1 {
+		first = pow(62, base-1)
+	}
+	used := min(max(currentID-first+1, 0), usage.Capacity)
+	projectExhaustion(usage, used, idsPerDay, now)
+}
+
+// projectExhaustion sets the utilization of a code length and the date its remaining
+// codes run out at the given rate
+func projectExhaustion(usage *models.CodeLengthUsage, used int64, perDay float64, now time.Time) {
+	usage.Utilization = float64(used) / float64(usage.Capacity)
+	if perDay <= 0 || used >= usage.Capacity {
+		return
+	}
+	days := float64(usage.Capacity-used) / perDay
+	if days > maxExhaustionDays {
+		return
+	}
+	exhaustsAt := now.Add(time.Duration(days * float64(24*time.Hour)))
+	usage.ExhaustsAt = &exhaustsAt
+}
+
+// codeCapacity returns how many codes of a length a strategy can generate, 0 when it is
+// unbounded or unknown
+func (s *URLService) codeCapacity(strategy string, length int) int64 {
+	if length > maxShortCodeLength {
+		return 0
+	}
+	base := length
+	if s.checksumDigit {
+		base--
+	}
+
+	switch strategy {
+	case CodeStrategySequential:
+		if base < 1 {
+			return 0
+		}
+		if base == 1 {
+			return 62
+		}
+		return pow(62, base) - pow(62, base-1)
+	case CodeStrategyRandom, CodeStrategyDeterministic:
+		if base < 1 {
+			return 0
+		}
+		return pow(62, base)
+	case CodeStrategyPronounceable:
+		if length%2 != 0 {
+			return 0
+		}
+		return pow(int64(len(pronounceableConsonants)*len(pronounceableVowels)), length/2)
+	case CodeStrategyWords:
+		if s.wordCodes == nil {
+			return 0
+		}
+		return s.wordCodes.Capacity(length)
+	}
+	return 0
+}
+
+// pow raises a base to a small exponent; code capacities stay far below overflow
+func pow(base int64, exponent int) int64 {
+	result := int64(1)
+	for i := 0; i < exponent; i++ {
+		result *= base
+	}
+	return result
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alexnthnz/url-shortener/internal/models"
+)
+
+func TestCodeCapacity(t *testing.T) {
+	words, err := NewWordCodeGenerator([]string{"ox", "red", "fox", "owl", "sun", "bee", "cup", "jam", "ink", "oak"}, 2, 2, "-")
+	if err != nil {
+		t.Fatalf("NewWordCodeGenerator: %v", err)
+	}
+	service := &URLService{wordCodes: words}
+	checksummed := &URLService{checksumDigit: true}
+
+	tests := []struct {
+		service  *URLService
+		strategy string
+		length   int
+		expected int64
+	}{
+		{service, CodeStrategySequential, 1, 62},
+		{service, CodeStrategySequential, 3, 62*62*62 - 62*62},
+		{checksummed, CodeStrategySequential, 3, 62*62 - 62},
+		{service, CodeStrategyRandom, 7, 3521614606208},
+		{checksummed, CodeStrategyRandom, 7, 56800235584},
+		{service, CodeStrategyDeterministic, 8, 218340105584896},
+		{service, CodeStrategyPronounceable, 6, 512000},
+		{service, CodeStrategyPronounceable, 7, 0},
+		// Two words of 3 letters, or 1 word of 2 letters and 1 of 3, each with 100 numbers
+		{service, CodeStrategyWords, 10, 9 * 9 * 100},
+		{service, CodeStrategyWords, 9, 2 * 9 * 100},
+		{service, CodeStrategyWords, 8, 100},
+		{service, CodeStrategyCustom, 5, 0},
+		{service, CodeStrategyRandom, 12, 0},
+	}
+
+	for _, test := range tests {
+		if capacity := test.service.codeCapacity(test.strategy, test.length); capacity != test.expected {
+			t.Errorf("codeCapacity(%s, %d) = %d; expected %d", test.strategy, test.length, capacity, test.expected)
+		}
+	}
+}
+
+func TestProjectExhaustion(t *testing.T) {
+	now := time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+
+	usage := models.CodeLengthUsage{Capacity: 1000}
+	projectExhaustion(&usage, 250, 50, now)
+	if usage.Utilization != 0.25 || usage.ExhaustsAt == nil || !usage.ExhaustsAt.Equal(now.AddDate(0, 0, 15)) {
+		t.Errorf("projectExhaustion = %v, %v; expected 0.25, 15 days out", usage.Utilization, usage.ExhaustsAt)
+	}
+
+	idle := models.CodeLengthUsage{Capacity: 1000}
+	projectExhaustion(&idle, 250, 0, now)
+	if idle.ExhaustsAt != nil {
+		t.Errorf("projectExhaustion without new links = %v; expected no date", idle.ExhaustsAt)
+	}
+
+	// The sequence is past the 2 character codes and a quarter into the 3 character ones
+	sequential := models.CodeLengthUsage{Length: 3, Capacity: 62*62*62 - 62*62}
+	service := &URLService{}
+	service.projectSequential(&sequential, 62*62+(62*62*62-62*62)/4-1, 0, now)
+	if sequential.Utilization != 0.25 {
+		t.Errorf("projectSequential utilization = %v; expected 0.25", sequential.Utilization)
+	}
+}
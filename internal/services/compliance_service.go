@@ -0,0 +1,91 @@
+package services
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	apperrors "github.com/alexnthnz/url-shortener/internal/errors"
+	"github.com/alexnthnz/url-shortener/internal/models"
+	"github.com/alexnthnz/url-shortener/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// complianceExportBatchSize bounds the records read per query during an export
+const complianceExportBatchSize = 1000
+
+// ComplianceService keeps a separate, append-only record of every redirect to a domain on
+// the configured sensitive list, with the visitor's country for jurisdiction review
+type ComplianceService struct {
+	complianceRepo *repository.ComplianceRepository
+	domains        []string
+	logger         *logrus.Logger
+}
+
+func NewComplianceService(complianceRepo *repository.ComplianceRepository, domains []string, logger *logrus.Logger) *ComplianceService {
+	return &ComplianceService{
+		complianceRepo: complianceRepo,
+		domains:        CanonicalDomains(domains),
+		logger:         logger,
+	}
+}
+
+// Enabled reports whether any sensitive domains are configured
+func (s *ComplianceService) Enabled() bool {
+	return len(s.domains) > 0
+}
+
+// RecordRedirect logs the redirect when its destination is on a sensitive domain. Failures
+// are logged loudly but do not block the redirect.
+func (s *ComplianceService) RecordRedirect(shortCode, clickID, destination, country string) {
+	if !s.Enabled() {
+		return
+	}
+
+	parsed, err := url.Parse(destination)
+	if err != nil {
+		return
+	}
+	// Hosts are compared in punycode so Unicode spellings cannot slip past the list
+	matched := MatchDomain(parsed.Hostname(), s.domains)
+	if matched == "" {
+		return
+	}
+	host, _ := CanonicalHost(parsed.Hostname())
+
+	record := &models.ComplianceRedirect{
+		ShortCode:       shortCode,
+		ClickID:         clickID,
+		Destination:     destination,
+		DestinationHost: host,
+		MatchedDomain:   matched,
+		VisitorCountry:  country,
+	}
+	if err := s.complianceRepo.Create(record); err != nil {
+		s.logger.Errorf("Failed to write compliance record for click %s: %v", clickID, err)
+	}
+}
+
+// Export streams every record with from <= occurred_at < to to emit, oldest first
+func (s *ComplianceService) Export(from, to time.Time, emit func(*models.ComplianceRedirect) error) error {
+	if !to.After(from) {
+		return apperrors.Errorf(apperrors.ErrInvalid, "invalid range: to must be after from")
+	}
+
+	var afterID int64
+	for {
+		records, err := s.complianceRepo.ListRange(from, to, afterID, complianceExportBatchSize)
+		if err != nil {
+			return fmt.Errorf("failed to read compliance records: %w", err)
+		}
+		for _, record := range records {
+			if err := emit(record); err != nil {
+				return err
+			}
+			afterID = record.ID
+		}
+		if len(records) < complianceExportBatchSize {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+	"strconv"
+)
+
+// minDeterministicKeyLength keeps the key from being guessed, which would let anyone work
+// out the code of a destination before it is shortened
+const minDeterministicKeyLength = 16
+
+// deterministicCodeAttempts caps the candidates tried for a destination whose codes are
+// taken by other links
+const deterministicCodeAttempts = 10
+
+// DeterministicCodeGenerator derives short codes from a keyed hash of the destination, so
+// the same destination gets the same code on every request and every instance sharing
+// the key
+type DeterministicCodeGenerator struct {
+	key           []byte
+	length        int
+	checksumDigit bool
+}
+
+// NewDeterministicCodeGenerator returns a generator of codes of the given length, before
+// any check character
+func NewDeterministicCodeGenerator(key string, length int, checksumDigit bool) (*DeterministicCodeGenerator, error) {
+	if len(key) < minDeterministicKeyLength {
+		return nil, fmt.Errorf("deterministic code key must be at least %d characters long", minDeterministicKeyLength)
+	}
+	maxLength := maxShortCodeLength
+	if checksumDigit {
+		maxLength--
+	}
+	if length < minRandomCodeLength || length > maxLength {
+		return nil, fmt.Errorf("deterministic short codes must be between %d and %d characters long", minRandomCodeLength, maxLength)
+	}
+	return &DeterministicCodeGenerator{key: []byte(key), length: length, checksumDigit: checksumDigit}, nil
+}
+
+// Code returns the candidate code of a destination for an attempt. Attempt 0 is the code
+// of the destination; later attempts are the fallbacks used, in order, while earlier
+// candidates are taken by links to other destinations.
+func (g *DeterministicCodeGenerator) Code(destination string, attempt int) string {
+	mac := hmac.New(sha256.New, g.key)
+	mac.Write([]byte(destination))
+	if attempt > 0 {
+		mac.Write([]byte{0})
+		mac.Write([]byte(strconv.Itoa(attempt)))
+	}
+
+	n := new(big.Int).SetBytes(mac.Sum(nil))
+	base := big.NewInt(int64(len(base62Chars)))
+	digit := new(big.Int)
+	code := make([]byte, g.length, g.length+1)
+	for i := range code {
+		n.DivMod(n, base, digit)
+		code[i] = base62Chars[digit.Int64()]
+	}
+	if g.checksumDigit {
+		code = append(code, checksumChar(string(code)))
+	}
+	return string(code)
+}
@@ -0,0 +1,278 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	apperrors "github.com/alexnthnz/url-shortener/internal/errors"
+	"github.com/alexnthnz/url-shortener/internal/models"
+	"github.com/alexnthnz/url-shortener/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// Domain policy actions
+const (
+	DomainPolicyAllow = "allow"
+	DomainPolicyBlock = "block"
+)
+
+const (
+	// domainPolicyReloadInterval bounds how long other instances enforce stale policies
+	domainPolicyReloadInterval = 30 * time.Second
+	// domainPolicyReportDays is the period click counts in the violation report cover
+	domainPolicyReportDays = 30
+	// domainPolicySampleCodes caps the short codes listed per violating domain
+	domainPolicySampleCodes = 20
+	// domainPolicyAllowlist is the rule reported for hosts refused only because they
+	// match no allow policy
+	domainPolicyAllowlist = "allowlist"
+)
+
+// DomainPolicyService enforces the allowed and blocked destination domains set by admins.
+// A policy covers a domain and its subdomains, and the most specific matching policy
+// wins. Once any allow policy exists, domains without a matching policy are refused,
+// except for allow policies that only carve an exception out of a blocked domain.
+type DomainPolicyService struct {
+	repo    *repository.DomainPolicyRepository
+	urlRepo repository.URLStore
+	logger  *logrus.Logger
+
+	mu       sync.RWMutex
+	policies map[string]*models.DomainPolicy // by canonical domain
+}
+
+func NewDomainPolicyService(repo *repository.DomainPolicyRepository, urlRepo repository.URLStore, logger *logrus.Logger) *DomainPolicyService {
+	service := &DomainPolicyService{
+		repo:     repo,
+		urlRepo:  urlRepo,
+		logger:   logger,
+		policies: make(map[string]*models.DomainPolicy),
+	}
+
+	if err := service.reload(); err != nil {
+		logger.Warnf("Failed to load domain policies: %v", err)
+	}
+	go service.reloadLoop()
+
+	return service
+}
+
+// Check returns an error when the policies do not permit the host as a destination
+func (s *DomainPolicyService) Check(host string) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	switch rule, _ := evaluateDomainPolicies(host, s.policies); rule {
+	case "":
+		return nil
+	case domainPolicyAllowlist:
+		return fmt.Errorf("destination domain is not on the allowlist")
+	default:
+		return fmt.Errorf("destination domain is blocked by policy")
+	}
+}
+
+// CheckURL is Check for the host of a stored destination URL
+func (s *DomainPolicyService) CheckURL(destination string) error {
+	base, _, _ := splitFragment(destination)
+	parsed, err := url.Parse(base)
+	if err != nil {
+		// Destinations were validated when stored, so there is no host to judge
+		return nil
+	}
+	return s.Check(parsed.Hostname())
+}
+
+// evaluateDomainPolicies returns the most specific policy covering a host, and the rule
+// that refuses it: the blocking domain, domainPolicyAllowlist, or "" when it is permitted
+func evaluateDomainPolicies(host string, policies map[string]*models.DomainPolicy) (string, *models.DomainPolicy) {
+	host, err := CanonicalHost(host)
+	if err != nil {
+		return "", nil
+	}
+
+	// Walk from the host up to its top-level domain; the first policy found is the most specific
+	for domain := host; domain != ""; {
+		if policy, ok := policies[domain]; ok {
+			if policy.Action == DomainPolicyBlock {
+				return domain, policy
+			}
+			return "", policy
+		}
+		_, parent, found := strings.Cut(domain, ".")
+		if !found {
+			break
+		}
+		domain = parent
+	}
+
+	// Allow policies below a blocked domain are exceptions and do not start an allowlist
+	for domain, policy := range policies {
+		if policy.Action == DomainPolicyAllow && !underBlockedDomain(domain, policies) {
+			return domainPolicyAllowlist, nil
+		}
+	}
+	return "", nil
+}
+
+// underBlockedDomain reports whether a parent domain of domain is blocked
+func underBlockedDomain(domain string, policies map[string]*models.DomainPolicy) bool {
+	for {
+		_, parent, found := strings.Cut(domain, ".")
+		if !found {
+			return false
+		}
+		if policy, ok := policies[parent]; ok && policy.Action == DomainPolicyBlock {
+			return true
+		}
+		domain = parent
+	}
+}
+
+// List returns every policy
+func (s *DomainPolicyService) List() ([]*models.DomainPolicy, error) {
+	policies, err := s.repo.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list domain policies: %w", err)
+	}
+	return policies, nil
+}
+
+// Set allows or blocks a domain and its subdomains
+func (s *DomainPolicyService) Set(domain, action, reason string) (*models.DomainPolicy, error) {
+	canonical, err := CanonicalHost(strings.Trim(domain, ". "))
+	if err != nil || !strings.Contains(canonical, ".") {
+		return nil, apperrors.Errorf(apperrors.ErrInvalid, "invalid domain")
+	}
+	if action != DomainPolicyAllow && action != DomainPolicyBlock {
+		return nil, apperrors.Errorf(apperrors.ErrInvalid, "invalid action: must be %q or %q", DomainPolicyAllow, DomainPolicyBlock)
+	}
+
+	policy := &models.DomainPolicy{Domain: canonical, Action: action, Reason: strings.TrimSpace(reason)}
+	if err := s.repo.Upsert(policy); err != nil {
+		return nil, fmt.Errorf("failed to save domain policy: %w", err)
+	}
+
+	s.mu.Lock()
+	s.policies[canonical] = policy
+	s.mu.Unlock()
+	return policy, nil
+}
+
+// Delete removes the policy of a domain
+func (s *DomainPolicyService) Delete(domain string) error {
+	canonical, err := CanonicalHost(strings.Trim(domain, ". "))
+	if err != nil {
+		return apperrors.Errorf(apperrors.ErrNotFound, "domain policy not found")
+	}
+
+	deleted, err := s.repo.Delete(canonical)
+	if err != nil {
+		return fmt.Errorf("failed to delete domain policy: %w", err)
+	}
+	if !deleted {
+		return apperrors.Errorf(apperrors.ErrNotFound, "domain policy not found")
+	}
+
+	s.mu.Lock()
+	delete(s.policies, canonical)
+	s.mu.Unlock()
+	return nil
+}
+
+// Violations lists the destination domains of existing links that the current policies
+// refuse, with their clicks over the last 30 days, most clicked first. Such links stop
+// redirecting as soon as the policy applies.
+func (s *DomainPolicyService) Violations(ctx context.Context) ([]*models.DomainPolicyViolation, error) {
+	rows, err := s.urlRepo.GetDomainStats(ctx, time.Now().UTC().AddDate(0, 0, -domainPolicyReportDays))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get domain stats: %w", err)
+	}
+
+	s.mu.RLock()
+	byDomain := make(map[string]*models.DomainPolicyViolation)
+	hostsByDomain := make(map[string][]string)
+	for _, row := range rows {
+		rule, policy := evaluateDomainPolicies(row.Domain, s.policies)
+		if rule == "" {
+			continue
+		}
+		domain, _ := CanonicalHost(row.Domain)
+		violation, ok := byDomain[domain]
+		if !ok {
+			violation = &models.DomainPolicyViolation{Domain: domain, Rule: rule, ShortCodes: []string{}}
+			if policy != nil {
+				violation.Reason = policy.Reason
+			}
+			byDomain[domain] = violation
+		}
+		violation.Links += row.Links
+		violation.Clicks += row.Clicks
+		hostsByDomain[domain] = append(hostsByDomain[domain], row.Domain)
+	}
+	s.mu.RUnlock()
+
+	violations := make([]*models.DomainPolicyViolation, 0, len(byDomain))
+	var hosts []string
+	for domain, violation := range byDomain {
+		violations = append(violations, violation)
+		hosts = append(hosts, hostsByDomain[domain]...)
+	}
+	sort.Slice(violations, func(i, j int) bool {
+		if violations[i].Clicks != violations[j].Clicks {
+			return violations[i].Clicks > violations[j].Clicks
+		}
+		return violations[i].Domain < violations[j].Domain
+	})
+	if len(hosts) == 0 {
+		return violations, nil
+	}
+
+	codes, err := s.urlRepo.ListCodesByDomains(ctx, hosts, domainPolicySampleCodes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list short codes: %w", err)
+	}
+	for _, violation := range violations {
+		for _, host := range hostsByDomain[violation.Domain] {
+			violation.ShortCodes = append(violation.ShortCodes, codes[host]...)
+		}
+		if len(violation.ShortCodes) > domainPolicySampleCodes {
+			violation.ShortCodes = violation.ShortCodes[:domainPolicySampleCodes]
+		}
+	}
+	return violations, nil
+}
+
+// reloadLoop picks up policies changed through other instances
+func (s *DomainPolicyService) reloadLoop() {
+	ticker := time.NewTicker(domainPolicyReloadInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := s.reload(); err != nil {
+			s.logger.Warnf("Failed to reload domain policies: %v", err)
+		}
+	}
+}
+
+func (s *DomainPolicyService) reload() error {
+	list, err := s.repo.List()
+	if err != nil {
+		return err
+	}
+
+	policies := make(map[string]*models.DomainPolicy, len(list))
+	for _, policy := range list {
+		policies[policy.Domain] = policy
+	}
+
+	s.mu.Lock()
+	s.policies = policies
+	s.mu.Unlock()
+	return nil
+}
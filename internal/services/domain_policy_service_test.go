@@ -0,0 +1,53 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/alexnthnz/url-shortener/internal/models"
+)
+
+func TestEvaluateDomainPolicies(t *testing.T) {
+	policies := func(list ...*models.DomainPolicy) map[string]*models.DomainPolicy {
+		byDomain := make(map[string]*models.DomainPolicy)
+		for _, policy := range list {
+			byDomain[policy.Domain] = policy
+		}
+		return byDomain
+	}
+	block := func(domain string) *models.DomainPolicy {
+		return &models.DomainPolicy{Domain: domain, Action: DomainPolicyBlock}
+	}
+	allow := func(domain string) *models.DomainPolicy {
+		return &models.DomainPolicy{Domain: domain, Action: DomainPolicyAllow}
+	}
+
+	blocklist := policies(block("tracker.example"), allow("safe.tracker.example"), block("xn--bcher-kva.de"))
+	allowlist := policies(allow("example.com"), block("ads.example.com"))
+
+	testCases := []struct {
+		name     string
+		policies map[string]*models.DomainPolicy
+		host     string
+		rule     string
+	}{
+		{"no policies", policies(), "anything.org", ""},
+		{"blocked domain", blocklist, "tracker.example", "tracker.example"},
+		{"blocked subdomain", blocklist, "cdn.tracker.example", "tracker.example"},
+		{"allowed below a block", blocklist, "www.safe.tracker.example", ""},
+		{"unlisted with blocklist only", blocklist, "example.org", ""},
+		{"unicode spelling of a blocked domain", blocklist, "BÜCHER.de", "xn--bcher-kva.de"},
+		{"allowed domain", allowlist, "docs.example.com", ""},
+		{"blocked below an allow", allowlist, "x.ads.example.com", "ads.example.com"},
+		{"unlisted with an allowlist", allowlist, "example.org", domainPolicyAllowlist},
+		{"suffix is not a subdomain", allowlist, "badexample.com", domainPolicyAllowlist},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rule, _ := evaluateDomainPolicies(tc.host, tc.policies)
+			if rule != tc.rule {
+				t.Errorf("evaluateDomainPolicies(%q) = %q; expected %q", tc.host, rule, tc.rule)
+			}
+		})
+	}
+}
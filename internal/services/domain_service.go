@@ -0,0 +1,333 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	apperrors "github.com/alexnthnz/url-shortener/internal/errors"
+	"github.com/alexnthnz/url-shortener/internal/models"
+	"github.com/alexnthnz/url-shortener/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// domainReloadInterval bounds how long other instances take to serve a new custom domain
+const domainReloadInterval = 30 * time.Second
+
+// DomainService manages custom domains. Each domain has its own namespace of short codes,
+// so the same code can name different links on different domains. A code on a custom
+// domain points to a canonical link, like an alias does, which keeps statistics,
+// updates and the rest of the API working on the canonical short code. Custom domains
+// only serve their own codes; the default domain serves canonical codes and aliases.
+type DomainService struct {
+	repo    *repository.DomainRepository
+	urls    *URLService
+	cache   repository.Cache
+	baseURL string // short URLs on the default domain start with it
+	scheme  string // scheme of short URLs on custom domains, the one of baseURL
+	host    string // canonical host of baseURL, which cannot be registered
+	// smsMaxURLLength caps the short URLs of links created with the SMS profile
+	smsMaxURLLength int
+	logger          *logrus.Logger
+
+	mu      sync.RWMutex
+	domains map[string]*models.Domain // by canonical domain
+}
+
+func NewDomainService(repo *repository.DomainRepository, urls *URLService, cache repository.Cache, baseURL string, smsMaxURLLength int, logger *logrus.Logger) *DomainService {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	service := &DomainService{
+		repo:            repo,
+		urls:            urls,
+		cache:           cache,
+		baseURL:         baseURL,
+		scheme:          "https",
+		smsMaxURLLength: smsMaxURLLength,
+		logger:          logger,
+		domains:         make(map[string]*models.Domain),
+	}
+	if parsed, err := url.Parse(baseURL); err == nil {
+		if parsed.Scheme != "" {
+			service.scheme = parsed.Scheme
+		}
+		service.host, _ = CanonicalHost(parsed.Hostname())
+	}
+
+	if err := service.reload(); err != nil {
+		logger.Warnf("Failed to load custom domains: %v", err)
+	}
+	go service.reloadLoop()
+
+	return service
+}
+
+// Register adds a custom domain owned by a signing key. The domain's DNS must point at
+// this service for its short URLs to work.
+func (s *DomainService) Register(domain, owner string) (*models.Domain, error) {
+	canonical, err := CanonicalHost(strings.Trim(domain, ". "))
+	if err != nil || !strings.Contains(canonical, ".") || net.ParseIP(canonical) != nil {
+		return nil, apperrors.Errorf(apperrors.ErrInvalid, "invalid domain")
+	}
+	if canonical == s.host {
+		return nil, apperrors.Errorf(apperrors.ErrInvalid, "invalid domain: %s is the default domain", canonical)
+	}
+
+	record := &models.Domain{Domain: canonical, Owner: owner}
+	if err := s.repo.Create(record); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			return nil, apperrors.Errorf(apperrors.ErrConflict, "domain already registered")
+		}
+		return nil, fmt.Errorf("failed to register domain: %w", err)
+	}
+
+	s.mu.Lock()
+	s.domains[canonical] = record
+	s.mu.Unlock()
+	return record, nil
+}
+
+// List returns the custom domains of an owner
+func (s *DomainService) List(owner string) ([]*models.Domain, error) {
+	domains, err := s.repo.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list domains: %w", err)
+	}
+
+	owned := make([]*models.Domain, 0, len(domains))
+	for _, domain := range domains {
+		if domain.Owner == owner {
+			owned = append(owned, domain)
+		}
+	}
+	return owned, nil
+}
+
+// Delete removes a custom domain of an owner with all its short codes. The links keep
+// working on the default domain under their canonical codes.
+func (s *DomainService) Delete(domain, owner string) error {
+	canonical, err := CanonicalHost(strings.Trim(domain, ". "))
+	if err != nil {
+		return apperrors.Errorf(apperrors.ErrNotFound, "domain not found")
+	}
+
+	deleted, err := s.repo.Delete(canonical, owner)
+	if err != nil {
+		return fmt.Errorf("failed to delete domain: %w", err)
+	}
+	if !deleted {
+		return apperrors.Errorf(apperrors.ErrNotFound, "domain not found")
+	}
+
+	s.mu.Lock()
+	delete(s.domains, canonical)
+	s.mu.Unlock()
+	return nil
+}
+
+// Lookup returns the custom domain a request Host header names, nil for any other host
+func (s *DomainService) Lookup(host string) *models.Domain {
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		host = name
+	}
+	canonical, err := CanonicalHost(host)
+	if err != nil {
+		return nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.domains[canonical]
+}
+
+// ShortURL returns the short URL of a code on a custom domain, or on the default domain
+// when domain is empty
+func (s *DomainService) ShortURL(domain, code string) string {
+	if domain == "" {
+		return s.baseURL + "/" + url.PathEscape(code)
+	}
+	if canonical, err := CanonicalHost(strings.Trim(domain, ". ")); err == nil {
+		domain = canonical
+	}
+	return s.scheme + "://" + domain + "/" + url.PathEscape(code)
+}
+
+// NumericURL returns the URL of a numeric code, which only the default domain serves
+func (s *DomainService) NumericURL(digits string) string {
+	return s.baseURL + "/n/" + digits
+}
+
+// SMSCodeBudget returns how many characters the SMS length limit leaves for the code of a
+// short URL on a custom domain, or on the default domain when domain is empty
+func (s *DomainService) SMSCodeBudget(domain string) (int, error) {
+	prefix := s.ShortURL(domain, "")
+	budget := s.smsMaxURLLength - len(prefix)
+	if budget < 1 {
+		return 0, apperrors.Errorf(apperrors.ErrInvalid, "invalid profile: short URLs starting with %s leave no room for a code within the SMS limit of %d characters", prefix, s.smsMaxURLLength)
+	}
+	return budget, nil
+}
+
+// ShortenURL creates a link on a custom domain of the owner, returning the link and its
+// code on the domain: the custom alias when one was requested, else the generated
+// canonical code.
+func (s *DomainService) ShortenURL(ctx context.Context, req *models.ShortenRequest, owner string) (*models.URL, string, error) {
+	domain, plain, code, err := s.prepareShorten(ctx, req, owner)
+	if err != nil {
+		return nil, "", err
+	}
+
+	urlRecord, err := s.urls.ShortenURL(ctx, plain)
+	if err != nil {
+		return nil, "", err
+	}
+	if code == "" {
+		code = urlRecord.ShortCode
+	}
+
+	// A concurrent request may have taken the alias since it was checked; the canonical
+	// link then stays reachable on the default domain only
+	if err := s.repo.CreateLink(domain.ID, code, urlRecord.ShortCode); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			return nil, "", apperrors.Errorf(apperrors.ErrAliasExists, "custom alias already exists")
+		}
+		return nil, "", fmt.Errorf("failed to create domain link: %w", err)
+	}
+
+	if err := s.cache.Set(ctx, domainLinkCacheKey(domain.ID, code), urlRecord.ShortCode); err != nil {
+		s.logger.Warnf("Failed to cache domain link: %v", err)
+	}
+	return urlRecord, code, nil
+}
+
+// PreviewShorten runs every check of ShortenURL without creating anything. The code on
+// the domain is only known for custom aliases.
+func (s *DomainService) PreviewShorten(ctx context.Context, req *models.ShortenRequest, owner string) (*models.URL, string, error) {
+	_, plain, code, err := s.prepareShorten(ctx, req, owner)
+	if err != nil {
+		return nil, "", err
+	}
+
+	urlRecord, err := s.urls.PreviewShorten(ctx, plain)
+	if err != nil {
+		return nil, "", err
+	}
+	return urlRecord, code, nil
+}
+
+// prepareShorten checks a shorten request for a custom domain. It returns the domain, the
+// request for the canonical link, which always gets a generated code, and the custom
+// alias to use on the domain, if any.
+func (s *DomainService) prepareShorten(ctx context.Context, req *models.ShortenRequest, owner string) (*models.Domain, *models.ShortenRequest, string, error) {
+	domain := s.Lookup(req.Domain)
+	if domain == nil || domain.Owner != owner {
+		return nil, nil, "", apperrors.Errorf(apperrors.ErrInvalid, "invalid domain: %s is not registered to this signing key", req.Domain)
+	}
+	if req.Ephemeral {
+		return nil, nil, "", apperrors.Errorf(apperrors.ErrInvalid, "invalid domain: ephemeral links cannot use a custom domain")
+	}
+
+	plain := *req
+	plain.CustomAlias = ""
+	plain.Domain = domain.Domain
+	if req.CustomAlias == "" {
+		return domain, &plain, "", nil
+	}
+
+	code := NormalizeShortCode(req.CustomAlias)
+	if err := s.urls.validateCustomAlias(code); err != nil {
+		return nil, nil, "", apperrors.Errorf(apperrors.ErrInvalid, "invalid custom alias: %w", err)
+	}
+	if err := validateProfile(req, code); err != nil {
+		return nil, nil, "", err
+	}
+	// With an alias on the domain, the canonical code does not appear in the short URL
+	plain.MaxCodeLength = 0
+	plain.DomainAlias = code
+	existing, err := s.repo.GetShortCode(ctx, domain.ID, code)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("failed to check alias existence: %w", err)
+	}
+	if existing != "" {
+		return nil, nil, "", apperrors.Errorf(apperrors.ErrAliasExists, "custom alias already exists")
+	}
+	return domain, &plain, code, nil
+}
+
+// LinkDomains returns the domains a link or one of its aliases is on: its custom domains,
+// or "" for the default domain when it has none
+func (s *DomainService) LinkDomains(ctx context.Context, shortCode string) ([]string, error) {
+	canonicalCode, err := s.urls.ResolveShortCode(ctx, shortCode)
+	if err != nil {
+		return nil, err
+	}
+	domains, err := s.repo.ListLinkDomains(ctx, canonicalCode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get link domains: %w", err)
+	}
+	if len(domains) == 0 {
+		return []string{""}, nil
+	}
+	return domains, nil
+}
+
+// Resolve returns the canonical short code a code on a custom domain points to. Unknown
+// codes are "not found".
+func (s *DomainService) Resolve(ctx context.Context, domain *models.Domain, code string) (string, error) {
+	key := domainLinkCacheKey(domain.ID, code)
+	if shortCode, err := s.cache.Get(ctx, key); err == nil {
+		return shortCode, nil
+	}
+
+	shortCode, err := s.repo.GetShortCode(ctx, domain.ID, code)
+	if err != nil {
+		return "", fmt.Errorf("failed to get domain link: %w", err)
+	}
+	if shortCode == "" {
+		return "", apperrors.Errorf(apperrors.ErrNotFound, "URL not found")
+	}
+
+	if err := s.cache.Set(ctx, key, shortCode); err != nil {
+		s.logger.Warnf("Failed to cache domain link: %v", err)
+	}
+	return shortCode, nil
+}
+
+// reloadLoop picks up domains registered or removed through other instances
+func (s *DomainService) reloadLoop() {
+	ticker := time.NewTicker(domainReloadInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := s.reload(); err != nil {
+			s.logger.Warnf("Failed to reload custom domains: %v", err)
+		}
+	}
+}
+
+func (s *DomainService) reload() error {
+	domains, err := s.repo.List()
+	if err != nil {
+		return err
+	}
+
+	byDomain := make(map[string]*models.Domain, len(domains))
+	for _, domain := range domains {
+		byDomain[domain.Domain] = domain
+	}
+
+	s.mu.Lock()
+	s.domains = byDomain
+	s.mu.Unlock()
+	return nil
+}
+
+// domainLinkCacheKey is the cache key mapping a code on a custom domain to its canonical
+// short code. Domain ids are never reused, so entries of a deleted domain are harmless.
+func domainLinkCacheKey(domainID int64, code string) string {
+	return fmt.Sprintf("domain_link:%d:%s", domainID, code)
+}
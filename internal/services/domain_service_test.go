@@ -0,0 +1,50 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/alexnthnz/url-shortener/internal/models"
+)
+
+func TestDomainLookupAndShortURL(t *testing.T) {
+	acme := &models.Domain{ID: 1, Domain: "go.acme.com", Owner: "key:acme"}
+	service := &DomainService{
+		baseURL: "https://sho.rt",
+		scheme:  "https",
+		host:    "sho.rt",
+		domains: map[string]*models.Domain{"go.acme.com": acme},
+	}
+
+	lookups := []struct {
+		host     string
+		expected *models.Domain
+	}{
+		{"go.acme.com", acme},
+		{"GO.Acme.com:8080", acme},
+		{"go.acme.com.", acme},
+		{"sho.rt", nil},
+		{"acme.com", nil},
+		{"", nil},
+	}
+	for _, tc := range lookups {
+		if got := service.Lookup(tc.host); got != tc.expected {
+			t.Errorf("Lookup(%q) = %v; expected %v", tc.host, got, tc.expected)
+		}
+	}
+
+	shortURLs := []struct {
+		domain   string
+		code     string
+		expected string
+	}{
+		{"", "dnh", "https://sho.rt/dnh"},
+		{"go.acme.com", "sale", "https://go.acme.com/sale"},
+		{"Go.Acme.com", "sale", "https://go.acme.com/sale"},
+		{"go.acme.com", "🍕", "https://go.acme.com/%F0%9F%8D%95"},
+	}
+	for _, tc := range shortURLs {
+		if got := service.ShortURL(tc.domain, tc.code); got != tc.expected {
+			t.Errorf("ShortURL(%q, %q) = %q; expected %q", tc.domain, tc.code, got, tc.expected)
+		}
+	}
+}
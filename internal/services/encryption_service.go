@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	apperrors "github.com/alexnthnz/url-shortener/internal/errors"
+	"github.com/alexnthnz/url-shortener/internal/models"
+	"github.com/alexnthnz/url-shortener/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// dataKeyRefreshInterval bounds how long an instance keeps encrypting with a data key
+// another instance has already rotated out
+const dataKeyRefreshInterval = time.Minute
+
+const reencryptJobType = "pii_reencrypt"
+
+// EncryptionService manages the data keys protecting personal data in click analytics
+type EncryptionService struct {
+	cipher        *repository.PIICipher
+	analyticsRepo repository.AnalyticsStore
+	mirror        repository.AnalyticsStore // optional, see AnalyticsService
+	jobs          *JobService
+	batchSize     int
+	logger        *logrus.Logger
+}
+
+func NewEncryptionService(cipher *repository.PIICipher, analyticsRepo, mirror repository.AnalyticsStore, jobs *JobService, batchSize int, logger *logrus.Logger) *EncryptionService {
+	service := &EncryptionService{
+		cipher:        cipher,
+		analyticsRepo: analyticsRepo,
+		mirror:        mirror,
+		jobs:          jobs,
+		batchSize:     batchSize,
+		logger:        logger,
+	}
+
+	if cipher != nil {
+		jobs.RegisterBatchJob(reencryptJobType, service.reencryptBatch)
+		go service.refresh()
+	}
+
+	return service
+}
+
+// Rotate switches new clicks to a fresh data key and starts re-encrypting stored ones
+func (s *EncryptionService) Rotate() (*models.Job, error) {
+	if s.cipher == nil {
+		return nil, apperrors.Errorf(apperrors.ErrNotConfigured, "PII encryption is not configured")
+	}
+
+	keyID, err := s.cipher.Rotate()
+	if err != nil {
+		return nil, fmt.Errorf("failed to rotate data key: %w", err)
+	}
+	s.logger.Infof("Rotated PII data key, new clicks are encrypted with key %d", keyID)
+
+	return s.Reencrypt()
+}
+
+// Reencrypt starts a job sealing every click still stored in plaintext or under an old
+// data key, first in the primary database and then in the mirror
+func (s *EncryptionService) Reencrypt() (*models.Job, error) {
+	if s.cipher == nil {
+		return nil, apperrors.Errorf(apperrors.ErrNotConfigured, "PII encryption is not configured")
+	}
+
+	return s.jobs.StartBatchJob(reencryptJobType, storeCursor(0, 0))
+}
+
+// reencryptBatch seals one batch of clicks. The cursor holds the index of the database,
+// the primary then the mirror, and the id of the last click sealed in it.
+func (s *EncryptionService) reencryptBatch(ctx context.Context, cursor string) (int64, string, error) {
+	repos := []repository.AnalyticsStore{s.analyticsRepo}
+	if s.mirror != nil {
+		repos = append(repos, s.mirror)
+	}
+
+	index, afterID, err := parseStoreCursor(cursor)
+	if err != nil {
+		return 0, "", err
+	}
+	for ; index < len(repos); index, afterID = index+1, 0 {
+		lastID, n, err := repos[index].ReencryptBatch(ctx, afterID, s.batchSize)
+		if err != nil {
+			return 0, "", err
+		}
+		if n > 0 {
+			return n, storeCursor(index, lastID), nil
+		}
+		// This database is done, continue with the next one from the start
+	}
+	return 0, "", nil
+}
+
+// refresh picks up data keys rotated by other instances
+func (s *EncryptionService) refresh() {
+	ticker := time.NewTicker(dataKeyRefreshInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := s.cipher.Reload(); err != nil {
+			s.logger.Warnf("Failed to refresh PII data keys: %v", err)
+		}
+	}
+}
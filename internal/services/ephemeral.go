@@ -0,0 +1,206 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	apperrors "github.com/alexnthnz/url-shortener/internal/errors"
+	"github.com/alexnthnz/url-shortener/internal/models"
+)
+
+const (
+	// ephemeralCodeLength is longer than any code the database can hold, so ephemeral
+	// codes never shadow a stored link and are impractical to guess
+	ephemeralCodeLength = 12
+	// ephemeralCodeAttempts bounds the retries on a (vanishingly unlikely) code collision
+	ephemeralCodeAttempts = 5
+	// DefaultEphemeralTTL is how long an ephemeral link lives unless the request says otherwise
+	DefaultEphemeralTTL = time.Hour
+	// MaxEphemeralTTL caps the lifetime of an ephemeral link
+	MaxEphemeralTTL = 24 * time.Hour
+)
+
+// ephemeralLink is the Redis record of an ephemeral link
+type ephemeralLink struct {
+	URL       string    `json:"url"`
+	MaxClicks int64     `json:"max_clicks,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// createEphemeral stores a link only in Redis, where it expires after ttl. The link never
+// reaches the database: it has no analytics, history, aliases or takedown workflow, and
+// it is lost if Redis loses its data.
+func (s *URLService) createEphemeral(ctx context.Context, urlRecord *models.URL, ttl time.Duration) error {
+	now := time.Now().UTC()
+	link := ephemeralLink{URL: urlRecord.OriginalURL, CreatedAt: now}
+	if urlRecord.MaxClicks != nil {
+		link.MaxClicks = *urlRecord.MaxClicks
+	}
+	record, err := json.Marshal(link)
+	if err != nil {
+		return fmt.Errorf("failed to encode ephemeral link: %w", err)
+	}
+
+	for attempt := 0; attempt < ephemeralCodeAttempts; attempt++ {
+		code, err := randomBase62(ephemeralCodeLength)
+		if err != nil {
+			return fmt.Errorf("failed to generate code: %w", err)
+		}
+		stored, err := s.cache.SetNX(ctx, ephemeralKey(code), string(record), ttl)
+		if err != nil {
+			return fmt.Errorf("failed to store ephemeral link: %w", err)
+		}
+		if stored {
+			expiresAt := now.Add(ttl)
+			urlRecord.ShortCode = code
+			urlRecord.CreatedAt = now
+			urlRecord.ExpiresAt = &expiresAt
+			return nil
+		}
+	}
+	return fmt.Errorf("failed to store ephemeral link: no free code after %d attempts", ephemeralCodeAttempts)
+}
+
+// validateEphemeral checks the options of a shorten request that an ephemeral link
+// cannot honor, or that only apply to one
+func validateEphemeral(req *models.ShortenRequest) error {
+	if !req.Ephemeral {
+		if req.TTLSeconds != 0 {
+			return fmt.Errorf("ttl_seconds only applies to ephemeral links")
+		}
+		return nil
+	}
+	if req.CustomAlias != "" || req.CodeStyle != CodeStyleDefault || req.PathPassthrough || req.UTM != nil || req.ActivateAt != nil {
+		return fmt.Errorf("custom aliases, code styles, path passthrough, UTM parameters and activation times are not supported")
+	}
+	if req.TTLSeconds < 0 || time.Duration(req.TTLSeconds)*time.Second > MaxEphemeralTTL {
+		return fmt.Errorf("ttl_seconds must be between 1 and %d", int64(MaxEphemeralTTL/time.Second))
+	}
+	return nil
+}
+
+// ephemeralTTL returns the lifetime requested for an ephemeral link
+func ephemeralTTL(req *models.ShortenRequest) time.Duration {
+	if req.TTLSeconds == 0 {
+		return DefaultEphemeralTTL
+	}
+	return time.Duration(req.TTLSeconds) * time.Second
+}
+
+// GetEphemeralURL returns the destination of an ephemeral link and whether it has a click
+// cap, counting the click against the cap. Expired links are "not found"; links whose
+// cap is used up fail with "click limit reached".
+func (s *URLService) GetEphemeralURL(ctx context.Context, shortCode string) (string, bool, error) {
+	link, err := s.getEphemeral(ctx, shortCode)
+	if err != nil {
+		return "", false, err
+	}
+	if link.MaxClicks == 0 {
+		return link.URL, false, nil
+	}
+
+	// The counter may outlive the link by up to a day, which is harmless
+	count, _, err := s.cache.IncrWindow(ctx, ephemeralClicksKey(shortCode), MaxEphemeralTTL)
+	if err != nil {
+		return "", true, fmt.Errorf("failed to count click: %w", err)
+	}
+	if count > link.MaxClicks {
+		return "", true, apperrors.Errorf(apperrors.ErrClickLimit, "click limit reached")
+	}
+	return link.URL, true, nil
+}
+
+// getEphemeralInfo describes an ephemeral link without counting a click. Clicks are not
+// recorded, so only the remaining clicks of a capped link are known.
+func (s *URLService) getEphemeralInfo(ctx context.Context, shortCode string) (*models.URLInfo, error) {
+	link, err := s.getEphemeral(ctx, shortCode)
+	if err != nil {
+		return nil, err
+	}
+	ttl, err := s.cache.TTL(ctx, ephemeralKey(shortCode))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ephemeral link TTL: %w", err)
+	}
+
+	expiresAt := time.Now().UTC().Add(ttl).Truncate(time.Second)
+	info := &models.URLInfo{
+		ShortCode:   shortCode,
+		OriginalURL: link.URL,
+		CreatedAt:   link.CreatedAt,
+		ExpiresAt:   &expiresAt,
+		Active:      true,
+		Ephemeral:   true,
+	}
+	if link.MaxClicks > 0 {
+		maxClicks := link.MaxClicks
+		remaining := maxClicks
+		if used, err := s.cache.Get(ctx, ephemeralClicksKey(shortCode)); err == nil {
+			if count, err := strconv.ParseInt(used, 10, 64); err == nil {
+				remaining = max(maxClicks-count, 0)
+			}
+		}
+		info.MaxClicks = &maxClicks
+		info.ClicksRemaining = &remaining
+	}
+	return info, nil
+}
+
+// getEphemeral loads the Redis record of an ephemeral link
+func (s *URLService) getEphemeral(ctx context.Context, shortCode string) (*ephemeralLink, error) {
+	cached, err := s.cache.MGet(ctx, ephemeralKey(shortCode))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ephemeral link: %w", err)
+	}
+	if cached[0] == "" {
+		return nil, apperrors.Errorf(apperrors.ErrNotFound, "URL not found")
+	}
+
+	var link ephemeralLink
+	if err := json.Unmarshal([]byte(cached[0]), &link); err != nil {
+		return nil, fmt.Errorf("failed to decode ephemeral link: %w", err)
+	}
+	return &link, nil
+}
+
+// IsEphemeralCode reports whether a short code has the shape of an ephemeral link's code
+func IsEphemeralCode(shortCode string) bool {
+	if len(shortCode) != ephemeralCodeLength {
+		return false
+	}
+	for i := 0; i < len(shortCode); i++ {
+		if strings.IndexByte(base62Chars, shortCode[i]) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// randomBase62 returns a cryptographically random base62 string
+func randomBase62(length int) (string, error) {
+	code := make([]byte, length)
+	limit := big.NewInt(int64(len(base62Chars)))
+	for i := range code {
+		n, err := rand.Int(rand.Reader, limit)
+		if err != nil {
+			return "", err
+		}
+		code[i] = base62Chars[n.Int64()]
+	}
+	return string(code), nil
+}
+
+// ephemeralKey is the cache key holding an ephemeral link
+func ephemeralKey(shortCode string) string {
+	return "ephemeral:" + shortCode
+}
+
+// ephemeralClicksKey is the cache key counting the clicks of a capped ephemeral link
+func ephemeralClicksKey(shortCode string) string {
+	return "ephemeral-clicks:" + shortCode
+}
@@ -0,0 +1,336 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	apperrors "github.com/alexnthnz/url-shortener/internal/errors"
+	"github.com/alexnthnz/url-shortener/internal/models"
+	"github.com/alexnthnz/url-shortener/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// Product analytics services click events can be forwarded to
+const (
+	ProviderSegment   = "segment"
+	ProviderAmplitude = "amplitude"
+)
+
+const (
+	// forwardedEventName is the event clicks are tracked as
+	forwardedEventName = "Link Clicked"
+	// maxEventDestinationKeyLength bounds the API keys accepted for a destination
+	maxEventDestinationKeyLength = 200
+)
+
+// EventForwardingConfig holds the API endpoints of the supported services, which differ
+// for EU data residency
+type EventForwardingConfig struct {
+	SegmentURL   string // Segment's batch endpoint, https://api.segment.io/v1/batch
+	AmplitudeURL string // Amplitude's batch endpoint, https://api2.amplitude.com/batch
+}
+
+// EventForwardingService forwards recorded clicks to Segment and Amplitude, so product
+// analytics see link traffic next to app events. Bot and internal clicks are left out.
+// Forwarding is best effort: batches wait in a queue of their own, so a slow service never
+// holds up analytics, and a failed delivery is recorded on the destination, not retried.
+type EventForwardingService struct {
+	cfg             EventForwardingConfig
+	destinationRepo *repository.EventDestinationRepository
+	client          *http.Client
+	queue           chan []*models.Analytics
+	refreshInterval time.Duration
+	logger          *logrus.Logger
+
+	mu           sync.Mutex
+	destinations []*models.EventDestination
+}
+
+func NewEventForwardingService(cfg EventForwardingConfig, destinationRepo *repository.EventDestinationRepository, logger *logrus.Logger) *EventForwardingService {
+	service := &EventForwardingService{
+		cfg:             cfg,
+		destinationRepo: destinationRepo,
+		client:          &http.Client{Timeout: 10 * time.Second},
+		queue:           make(chan []*models.Analytics, 100),
+		refreshInterval: time.Minute,
+		logger:          logger,
+	}
+
+	service.loadDestinations()
+	go service.run()
+
+	return service
+}
+
+// List returns the destinations with their API keys redacted
+func (s *EventForwardingService) List() ([]*models.EventDestination, error) {
+	destinations, err := s.destinationRepo.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list event destinations: %w", err)
+	}
+	for _, destination := range destinations {
+		destination.KeyHint = keyHint(destination.APIKey)
+	}
+	if destinations == nil {
+		destinations = []*models.EventDestination{}
+	}
+	return destinations, nil
+}
+
+// Set sets up a destination or updates it, rotating its API key when one is given
+func (s *EventForwardingService) Set(provider string, req *models.EventDestinationRequest) (*models.EventDestination, error) {
+	if provider != ProviderSegment && provider != ProviderAmplitude {
+		return nil, apperrors.Errorf(apperrors.ErrInvalid, "invalid destination: provider must be %s or %s", ProviderSegment, ProviderAmplitude)
+	}
+	apiKey := strings.TrimSpace(req.APIKey)
+	if len(apiKey) > maxEventDestinationKeyLength || strings.ContainsAny(apiKey, " \t\r\n") {
+		return nil, apperrors.Errorf(apperrors.ErrInvalid, "invalid destination: malformed api_key")
+	}
+
+	if apiKey == "" {
+		destinations, err := s.destinationRepo.List()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list event destinations: %w", err)
+		}
+		for _, existing := range destinations {
+			if existing.Provider == provider {
+				apiKey = existing.APIKey
+			}
+		}
+		if apiKey == "" {
+			return nil, apperrors.Errorf(apperrors.ErrInvalid, "invalid destination: api_key is required")
+		}
+	}
+
+	destination := &models.EventDestination{Provider: provider, APIKey: apiKey, Enabled: true}
+	if req.Enabled != nil {
+		destination.Enabled = *req.Enabled
+	}
+	if err := s.destinationRepo.Upsert(destination); err != nil {
+		return nil, fmt.Errorf("failed to save event destination: %w", err)
+	}
+
+	s.loadDestinations()
+	destination.KeyHint = keyHint(apiKey)
+	return destination, nil
+}
+
+// Delete removes a destination and its API key
+func (s *EventForwardingService) Delete(provider string) error {
+	deleted, err := s.destinationRepo.Delete(provider)
+	if err != nil {
+		return fmt.Errorf("failed to delete event destination: %w", err)
+	}
+	if !deleted {
+		return apperrors.Errorf(apperrors.ErrNotFound, "event destination not found")
+	}
+
+	s.loadDestinations()
+	return nil
+}
+
+// Forward queues recorded clicks for the enabled destinations (non-blocking)
+func (s *EventForwardingService) Forward(clicks []*models.Analytics) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	enabled := len(s.destinations) > 0
+	s.mu.Unlock()
+	if !enabled {
+		return
+	}
+
+	forwarded := make([]*models.Analytics, 0, len(clicks))
+	for _, click := range clicks {
+		if !click.IsBot && !click.IsInternal {
+			forwarded = append(forwarded, click)
+		}
+	}
+	if len(forwarded) == 0 {
+		return
+	}
+
+	select {
+	case s.queue <- forwarded:
+	default:
+		s.logger.Warnf("Event forwarding queue full, dropping %d click event(s)", len(forwarded))
+	}
+}
+
+// run delivers queued batches and refreshes the destinations
+func (s *EventForwardingService) run() {
+	refreshTicker := time.NewTicker(s.refreshInterval)
+	defer refreshTicker.Stop()
+
+	for {
+		select {
+		case clicks := <-s.queue:
+			s.mu.Lock()
+			destinations := s.destinations
+			s.mu.Unlock()
+
+			for _, destination := range destinations {
+				err := s.deliver(destination, clicks)
+				message := ""
+				if err != nil {
+					message = err.Error()
+					s.logger.Warnf("Failed to forward %d click event(s) to %s: %v", len(clicks), destination.Provider, err)
+				}
+				if recordErr := s.destinationRepo.RecordDelivery(destination.Provider, message, time.Now().UTC()); recordErr != nil {
+					s.logger.Warnf("Failed to record event delivery: %v", recordErr)
+				}
+			}
+		case <-refreshTicker.C:
+			// Pick up destinations set up or rotated on other instances
+			s.loadDestinations()
+		}
+	}
+}
+
+// loadDestinations refreshes the in-memory list of enabled destinations
+func (s *EventForwardingService) loadDestinations() {
+	destinations, err := s.destinationRepo.List()
+	if err != nil {
+		s.logger.Errorf("Failed to load event destinations: %v", err)
+		return
+	}
+
+	enabled := make([]*models.EventDestination, 0, len(destinations))
+	for _, destination := range destinations {
+		if destination.Enabled {
+			enabled = append(enabled, destination)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.destinations = enabled
+}
+
+// deliver sends clicks to one destination through its batch API
+func (s *EventForwardingService) deliver(destination *models.EventDestination, clicks []*models.Analytics) error {
+	var endpoint string
+	var payload interface{}
+	switch destination.Provider {
+	case ProviderSegment:
+		endpoint, payload = s.cfg.SegmentURL, segmentBatch(clicks)
+	case ProviderAmplitude:
+		endpoint, payload = s.cfg.AmplitudeURL, amplitudeBatch(destination.APIKey, clicks)
+	default:
+		return fmt.Errorf("unknown provider %q", destination.Provider)
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if destination.Provider == ProviderSegment {
+		// Segment authenticates with the write key as the basic auth user name
+		req.SetBasicAuth(destination.APIKey, "")
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", destination.Provider, resp.StatusCode)
+	}
+	return nil
+}
+
+// clickProperties are the properties a forwarded click carries
+func clickProperties(click *models.Analytics) map[string]interface{} {
+	properties := map[string]interface{}{
+		"short_code":  click.ShortCode,
+		"device_type": click.DeviceType,
+		"browser":     click.Browser,
+		"os":          click.OS,
+		"via_qr":      click.ViaQR,
+	}
+	if click.Referrer != "" {
+		properties["referrer"] = click.Referrer
+	}
+	if click.ClickID != "" {
+		properties["click_id"] = click.ClickID
+	}
+	if click.RedirectRule != nil {
+		properties["redirect_rule"] = *click.RedirectRule
+	}
+	return properties
+}
+
+// clickEventID identifies a click to the receiving service, which drops duplicates by it
+func clickEventID(click *models.Analytics) string {
+	if click.ClickID != "" {
+		return click.ClickID
+	}
+	return fmt.Sprintf("click-%d", click.ID)
+}
+
+// clickVisitorID is the anonymous user a click is attributed to: the attribution visitor
+// when there is one, else the click itself
+func clickVisitorID(click *models.Analytics) string {
+	if click.VisitorID != "" {
+		return click.VisitorID
+	}
+	return clickEventID(click)
+}
+
+// segmentBatch is the body of a Segment batch request tracking clicks
+func segmentBatch(clicks []*models.Analytics) map[string]interface{} {
+	events := make([]map[string]interface{}, len(clicks))
+	for i, click := range clicks {
+		events[i] = map[string]interface{}{
+			"type":        "track",
+			"event":       forwardedEventName,
+			"anonymousId": clickVisitorID(click),
+			"messageId":   clickEventID(click),
+			"timestamp":   click.ClickedAt.UTC().Format(time.RFC3339Nano),
+			"properties":  clickProperties(click),
+		}
+	}
+	return map[string]interface{}{"batch": events}
+}
+
+// amplitudeBatch is the body of an Amplitude batch request tracking clicks
+func amplitudeBatch(apiKey string, clicks []*models.Analytics) map[string]interface{} {
+	events := make([]map[string]interface{}, len(clicks))
+	for i, click := range clicks {
+		events[i] = map[string]interface{}{
+			"event_type":       forwardedEventName,
+			"device_id":        clickVisitorID(click),
+			"insert_id":        clickEventID(click),
+			"time":             click.ClickedAt.UnixMilli(),
+			"os_name":          click.OS,
+			"event_properties": clickProperties(click),
+		}
+	}
+	return map[string]interface{}{"api_key": apiKey, "events": events}
+}
+
+// keyHint shows the last characters of an API key, enough to tell keys apart
+func keyHint(apiKey string) string {
+	if len(apiKey) <= 8 {
+		return "****"
+	}
+	return "****" + apiKey[len(apiKey)-4:]
+}
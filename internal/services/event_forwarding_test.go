@@ -0,0 +1,99 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alexnthnz/url-shortener/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+func TestEventForwardingDeliver(t *testing.T) {
+	var segment, amplitude map[string]interface{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/segment", func(w http.ResponseWriter, r *http.Request) {
+		if user, _, ok := r.BasicAuth(); !ok || user != "write-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&segment)
+	})
+	mux.HandleFunc("/amplitude", func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&amplitude)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	service := &EventForwardingService{
+		cfg:    EventForwardingConfig{SegmentURL: server.URL + "/segment", AmplitudeURL: server.URL + "/amplitude"},
+		client: server.Client(),
+		logger: logrus.New(),
+	}
+	rule := 1
+	clicks := []*models.Analytics{{
+		ID:           42,
+		ClickID:      "c1",
+		VisitorID:    "visitor-1",
+		ShortCode:    "abc123",
+		ClickedAt:    time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+		DeviceType:   DeviceMobile,
+		OS:           "iOS",
+		RedirectRule: &rule,
+	}}
+
+	if err := service.deliver(&models.EventDestination{Provider: ProviderSegment, APIKey: "write-key"}, clicks); err != nil {
+		t.Fatalf("deliver() to Segment returned error: %v", err)
+	}
+	event := segment["batch"].([]interface{})[0].(map[string]interface{})
+	properties := event["properties"].(map[string]interface{})
+	if event["anonymousId"] != "visitor-1" || event["messageId"] != "c1" || properties["short_code"] != "abc123" || properties["redirect_rule"] != float64(1) {
+		t.Errorf("deliver() sent Segment event %v", event)
+	}
+
+	if err := service.deliver(&models.EventDestination{Provider: ProviderAmplitude, APIKey: "api-key"}, clicks); err != nil {
+		t.Fatalf("deliver() to Amplitude returned error: %v", err)
+	}
+	event = amplitude["events"].([]interface{})[0].(map[string]interface{})
+	if amplitude["api_key"] != "api-key" || event["device_id"] != "visitor-1" || event["insert_id"] != "c1" || event["time"] != float64(1709294400000) {
+		t.Errorf("deliver() sent Amplitude body %v", amplitude)
+	}
+
+	if err := service.deliver(&models.EventDestination{Provider: ProviderSegment, APIKey: "revoked"}, clicks); err == nil {
+		t.Error("deliver() returned no error for a rejected write key")
+	}
+}
+
+func TestEventForwardingSkipsBots(t *testing.T) {
+	service := &EventForwardingService{
+		queue:        make(chan []*models.Analytics, 1),
+		destinations: []*models.EventDestination{{Provider: ProviderSegment}},
+		logger:       logrus.New(),
+	}
+	service.Forward([]*models.Analytics{
+		{ID: 1, ShortCode: "abc123"},
+		{ID: 2, ShortCode: "abc123", IsBot: true},
+		{ID: 3, ShortCode: "abc123", IsInternal: true},
+	})
+
+	forwarded := <-service.queue
+	if len(forwarded) != 1 || forwarded[0].ID != 1 {
+		t.Errorf("Forward() queued %d click(s); expected only the human, external click", len(forwarded))
+	}
+
+	service.Forward([]*models.Analytics{{ID: 4, IsBot: true}})
+	if len(service.queue) != 0 {
+		t.Error("Forward() queued a batch with only bot clicks")
+	}
+}
+
+func TestKeyHint(t *testing.T) {
+	if hint := keyHint("sk_live_0123456789abcd"); hint != "****abcd" {
+		t.Errorf("keyHint() = %q; expected ****abcd", hint)
+	}
+	if hint := keyHint("short"); hint != "****" {
+		t.Errorf("keyHint() = %q; expected short keys to be hidden", hint)
+	}
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	apperrors "github.com/alexnthnz/url-shortener/internal/errors"
+	"github.com/alexnthnz/url-shortener/internal/models"
+)
+
+// eventSchema is one version of the payload of a webhook event
+type eventSchema struct {
+	event       string
+	version     int
+	description string
+	payload     interface{} // the payload type, as delivered
+}
+
+// eventSchemas lists every published payload version. Adding optional fields keeps the
+// version; removing, renaming or retyping a field adds a new version of the event, and
+// the old versions stay listed for consumers still reading them.
+var eventSchemas = []eventSchema{
+	{"click", 1, "A click on a link, delivered to subscriptions without an aggregation window", models.WebhookClickEvent{}},
+	{"click.aggregate", 1, "A summary of the clicks seen during one aggregation window", models.WebhookClickWindow{}},
+	{"takedown.requested", 1, "A takedown request was filed against a link", models.WebhookTakedownEvent{}},
+	{"takedown.resolved", 1, "A takedown request against a link was upheld or rejected", models.WebhookTakedownEvent{}},
+	{"goal.reached", 1, "A link reached its click goal before the deadline", models.WebhookGoalEvent{}},
+	{"goal.behind", 1, "A link fell behind the pace needed to reach its click goal", models.WebhookGoalEvent{}},
+	{"report.ready", 1, "A scheduled campaign report was generated", models.WebhookReportEvent{}},
+	{"login.locked", 1, "Failed attempts locked out a client IP of the admin or quick shorten token", models.WebhookLoginLockoutEvent{}},
+	{"login.failures", 1, "Failed attempts of all clients on the admin or quick shorten token reached the alert limit", models.WebhookLoginFailuresEvent{}},
+}
+
+// EventSchemaVersion returns the current schema version of an event, the one deliveries
+// carry in schema_version
+func EventSchemaVersion(event string) int {
+	version := 0
+	for _, schema := range eventSchemas {
+		if schema.event == event && schema.version > version {
+			version = schema.version
+		}
+	}
+	return version
+}
+
+// ListEventSchemas returns every published payload schema, by event and version
+func ListEventSchemas() []models.EventSchema {
+	schemas := make([]models.EventSchema, len(eventSchemas))
+	for i, schema := range eventSchemas {
+		schemas[i] = schema.document()
+	}
+	return schemas
+}
+
+// GetEventSchema returns one version of an event's payload schema, the current one when
+// version is 0
+func GetEventSchema(event string, version int) (*models.EventSchema, error) {
+	if version == 0 {
+		version = EventSchemaVersion(event)
+	}
+	for _, schema := range eventSchemas {
+		if schema.event == event && schema.version == version {
+			document := schema.document()
+			return &document, nil
+		}
+	}
+	return nil, apperrors.Errorf(apperrors.ErrNotFound, "schema not found")
+}
+
+func (s eventSchema) document() models.EventSchema {
+	schema := jsonSchema(reflect.TypeOf(s.payload))
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = fmt.Sprintf("%s v%d", s.event, s.version)
+
+	return models.EventSchema{
+		Event:       s.event,
+		Version:     s.version,
+		Current:     s.version == EventSchemaVersion(s.event),
+		Description: s.description,
+		Schema:      schema,
+	}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// jsonSchema describes a payload type as JSON Schema, following its json tags. Fields
+// without omitempty are required.
+func jsonSchema(t reflect.Type) map[string]interface{} {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case t.Kind() == reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		return map[string]interface{}{"type": "array", "items": jsonSchema(t.Elem())}
+	case t.Kind() == reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": jsonSchema(t.Elem())}
+	case t.Kind() == reflect.Struct:
+		properties := make(map[string]interface{})
+		required := []string{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			properties[name] = jsonSchema(field.Type)
+			if !strings.Contains(options, "omitempty") {
+				required = append(required, name)
+			}
+		}
+		return map[string]interface{}{"type": "object", "properties": properties, "required": required}
+	default:
+		return map[string]interface{}{}
+	}
+}
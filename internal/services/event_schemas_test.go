@@ -0,0 +1,34 @@
+package services
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestEventSchemas(t *testing.T) {
+	for _, schema := range ListEventSchemas() {
+		required, _ := schema.Schema["required"].([]string)
+		if !slices.Contains(required, "event") || !slices.Contains(required, "schema_version") {
+			t.Errorf("%s v%d: required = %v; expected event and schema_version", schema.Event, schema.Version, required)
+		}
+	}
+
+	click, err := GetEventSchema("click", 0)
+	if err != nil {
+		t.Fatalf("GetEventSchema() returned error: %v", err)
+	}
+	properties := click.Schema["properties"].(map[string]interface{})
+	if clickedAt, _ := properties["clicked_at"].(map[string]interface{}); clickedAt["format"] != "date-time" {
+		t.Errorf("clicked_at = %v; expected a date-time string", properties["clicked_at"])
+	}
+	if required := click.Schema["required"].([]string); slices.Contains(required, "click_id") {
+		t.Error("click_id is omitted when empty and should not be required")
+	}
+
+	if version := EventSchemaVersion("takedown.resolved"); version != 1 {
+		t.Errorf("EventSchemaVersion() = %d; expected 1", version)
+	}
+	if _, err := GetEventSchema("click", 99); err == nil {
+		t.Error("GetEventSchema() should fail for an unknown version")
+	}
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/alexnthnz/url-shortener/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// Streaming platforms click and link events can be published to
+const (
+	StreamKafka = "kafka"
+)
+
+// Link lifecycle events
+const (
+	LinkCreated  = "link.created"
+	LinkUpdated  = "link.updated"
+	LinkDeleted  = "link.deleted"
+	LinkDisabled = "link.disabled"
+	LinkEnabled  = "link.enabled"
+)
+
+// StreamMessage is one event as published. Messages are keyed by short code, so the
+// events of a link keep their order on a Kafka partition.
+type StreamMessage struct {
+	Key   string
+	Value json.RawMessage
+}
+
+// StreamPublisher writes messages to a topic
+type StreamPublisher interface {
+	Name() string
+	Publish(ctx context.Context, topic string, messages []StreamMessage) error
+}
+
+// EventStreamConfig names the topics events are published to
+type EventStreamConfig struct {
+	ClickTopic string
+	LinkTopic  string
+}
+
+// streamClick is a click as published. IP addresses and user agents stay in the primary
+// database; the parsed client fields are published instead.
+type streamClick struct {
+	Event        string    `json:"event"`
+	ID           int64     `json:"id"`
+	ClickID      string    `json:"click_id,omitempty"`
+	VisitorID    string    `json:"visitor_id,omitempty"`
+	ShortCode    string    `json:"short_code"`
+	ClickedAt    time.Time `json:"clicked_at"`
+	Referrer     string    `json:"referrer,omitempty"`
+	DeviceType   string    `json:"device_type,omitempty"`
+	Browser      string    `json:"browser,omitempty"`
+	OS           string    `json:"os,omitempty"`
+	IsBot        bool      `json:"is_bot"`
+	IsInternal   bool      `json:"is_internal"`
+	ViaQR        bool      `json:"via_qr"`
+	RedirectRule *int      `json:"redirect_rule,omitempty"`
+}
+
+type streamBatch struct {
+	topic    string
+	messages []StreamMessage
+}
+
+// EventStreamService publishes every recorded click and link lifecycle event to Kafka, so
+// data pipelines consume raw events instead of querying Postgres. Publishing is best
+// effort: batches wait in a queue of their own, so a slow broker never holds up
+// redirects or analytics, and a failed batch is logged, not retried.
+type EventStreamService struct {
+	publisher StreamPublisher // nil disables publishing
+	cfg       EventStreamConfig
+	queue     chan streamBatch
+	logger    *logrus.Logger
+}
+
+func NewEventStreamService(publisher StreamPublisher, cfg EventStreamConfig, logger *logrus.Logger) *EventStreamService {
+	service := &EventStreamService{
+		publisher: publisher,
+		cfg:       cfg,
+		queue:     make(chan streamBatch, 1000),
+		logger:    logger,
+	}
+
+	if publisher != nil {
+		go service.run()
+	}
+
+	return service
+}
+
+// PublishClicks queues recorded clicks for the click topic (non-blocking)
+func (s *EventStreamService) PublishClicks(clicks []*models.Analytics) {
+	if s == nil || s.publisher == nil || len(clicks) == 0 {
+		return
+	}
+
+	messages := make([]StreamMessage, 0, len(clicks))
+	for _, click := range clicks {
+		value, err := json.Marshal(streamClick{
+			Event:        "click",
+			ID:           click.ID,
+			ClickID:      click.ClickID,
+			VisitorID:    click.VisitorID,
+			ShortCode:    click.ShortCode,
+			ClickedAt:    click.ClickedAt.UTC(),
+			Referrer:     click.Referrer,
+			DeviceType:   click.DeviceType,
+			Browser:      click.Browser,
+			OS:           click.OS,
+			IsBot:        click.IsBot,
+			IsInternal:   click.IsInternal,
+			ViaQR:        click.ViaQR,
+			RedirectRule: click.RedirectRule,
+		})
+		if err != nil {
+			s.logger.Warnf("Failed to encode click event: %v", err)
+			continue
+		}
+		messages = append(messages, StreamMessage{Key: click.ShortCode, Value: value})
+	}
+	s.enqueue(streamBatch{topic: s.cfg.ClickTopic, messages: messages})
+}
+
+// PublishLink queues a link lifecycle event for the link topic (non-blocking)
+func (s *EventStreamService) PublishLink(event, shortCode, originalURL, reason string) {
+	if s == nil || s.publisher == nil {
+		return
+	}
+
+	value, err := json.Marshal(models.LinkEvent{
+		Event:       event,
+		ShortCode:   shortCode,
+		OriginalURL: originalURL,
+		Reason:      reason,
+		OccurredAt:  time.Now().UTC(),
+	})
+	if err != nil {
+		s.logger.Warnf("Failed to encode %s event: %v", event, err)
+		return
+	}
+	s.enqueue(streamBatch{topic: s.cfg.LinkTopic, messages: []StreamMessage{{Key: shortCode, Value: value}}})
+}
+
+func (s *EventStreamService) enqueue(batch streamBatch) {
+	select {
+	case s.queue <- batch:
+	default:
+		s.logger.Warnf("Event stream queue full, dropping %d event(s) for %s", len(batch.messages), batch.topic)
+	}
+}
+
+// run publishes queued batches one at a time, in order
+func (s *EventStreamService) run() {
+	for batch := range s.queue {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := s.publisher.Publish(ctx, batch.topic, batch.messages); err != nil {
+			s.logger.Warnf("Failed to publish %d event(s) to %s %s: %v", len(batch.messages), s.publisher.Name(), batch.topic, err)
+		}
+		cancel()
+	}
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// KafkaConfig holds the connection to a Kafka REST Proxy. Events go through the proxy's
+// v2 API, so the service needs no Kafka client and brokers keep their own authentication.
+type KafkaConfig struct {
+	RESTURL  string // base URL of the REST Proxy, e.g. http://kafka-rest:8082
+	Username string // optional basic auth
+	Password string
+}
+
+// KafkaPublisher publishes events to Kafka through a REST Proxy
+type KafkaPublisher struct {
+	cfg    KafkaConfig
+	client *http.Client
+}
+
+func NewKafkaPublisher(cfg KafkaConfig) (*KafkaPublisher, error) {
+	if cfg.RESTURL == "" {
+		return nil, fmt.Errorf("KAFKA_REST_URL is required")
+	}
+	return &KafkaPublisher{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+func (p *KafkaPublisher) Name() string {
+	return StreamKafka
+}
+
+// kafkaRecord is a record as the REST Proxy's JSON embedded format takes it
+type kafkaRecord struct {
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value"`
+}
+
+// Publish produces the messages to a topic in one request. The proxy answers 200 with an
+// offset or error per record, so a partly failed batch is reported as failed.
+func (p *KafkaPublisher) Publish(ctx context.Context, topic string, messages []StreamMessage) error {
+	records := make([]kafkaRecord, len(messages))
+	for i, message := range messages {
+		records[i] = kafkaRecord{Key: message.Key, Value: message.Value}
+	}
+	body, err := json.Marshal(map[string]interface{}{"records": records})
+	if err != nil {
+		return err
+	}
+
+	endpoint := strings.TrimSuffix(p.cfg.RESTURL, "/") + "/topics/" + url.PathEscape(topic)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/vnd.kafka.json.v2+json")
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+	if p.cfg.Username != "" {
+		req.SetBasicAuth(p.cfg.Username, p.cfg.Password)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Offsets []struct {
+			ErrorCode *int   `json:"error_code"`
+			Error     string `json:"error"`
+		} `json:"offsets"`
+		Message string `json:"message"`
+	}
+	json.NewDecoder(resp.Body).Decode(&result)
+
+	if resp.StatusCode != http.StatusOK {
+		if result.Message != "" {
+			return fmt.Errorf("REST proxy returned status %d: %s", resp.StatusCode, result.Message)
+		}
+		return fmt.Errorf("REST proxy returned status %d", resp.StatusCode)
+	}
+	failed := 0
+	var lastError string
+	for _, offset := range result.Offsets {
+		if offset.ErrorCode != nil {
+			failed++
+			lastError = offset.Error
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d record(s) rejected: %s", failed, len(messages), lastError)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestKafkaPublisherPublish(t *testing.T) {
+	var body map[string][]kafkaRecord
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/topics/urlshortener.clicks" || r.Header.Get("Content-Type") != "application/vnd.kafka.json.v2+json" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if len(body["records"]) == 2 {
+			fmt.Fprint(w, `{"offsets":[{"partition":0,"offset":1},{"partition":0,"offset":2}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"offsets":[{"error_code":40403,"error":"Schema not found"}]}`)
+	}))
+	defer server.Close()
+
+	publisher, err := NewKafkaPublisher(KafkaConfig{RESTURL: server.URL})
+	if err != nil {
+		t.Fatalf("NewKafkaPublisher() returned error: %v", err)
+	}
+	messages := []StreamMessage{
+		{Key: "abc123", Value: json.RawMessage(`{"event":"click"}`)},
+		{Key: "xyz789", Value: json.RawMessage(`{"event":"click"}`)},
+	}
+	if err := publisher.Publish(context.Background(), "urlshortener.clicks", messages); err != nil {
+		t.Fatalf("Publish() returned error: %v", err)
+	}
+	if records := body["records"]; records[0].Key != "abc123" || string(records[1].Value) != `{"event":"click"}` {
+		t.Errorf("Publish() sent %v", records)
+	}
+
+	if err := publisher.Publish(context.Background(), "urlshortener.clicks", messages[:1]); err == nil || !strings.Contains(err.Error(), "Schema not found") {
+		t.Errorf("Publish() with a rejected record returned %v, want the rejection", err)
+	}
+}
@@ -0,0 +1,246 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	apperrors "github.com/alexnthnz/url-shortener/internal/errors"
+	"github.com/alexnthnz/url-shortener/internal/models"
+	"github.com/alexnthnz/url-shortener/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// Status of a click goal
+const (
+	GoalOnTrack = "on_track"
+	GoalBehind  = "behind"
+	GoalReached = "reached"
+	GoalMissed  = "missed"
+)
+
+const (
+	// maxGoalsPerLink bounds the goals a link can have, open or closed
+	maxGoalsPerLink = 20
+	// maxGoalDuration bounds how far away a deadline can be
+	maxGoalDuration = 366 * 24 * time.Hour
+	// goalPaceGrace is the part of a goal's time that passes before its pace is judged, so
+	// a quiet first hour does not raise an alert
+	goalPaceGrace = 0.25
+)
+
+// GoalService tracks the click goals of links. A background evaluator, run by one instance
+// at a time, checks the open goals every interval and delivers goal.reached and goal.behind
+// webhook events, each once per goal.
+type GoalService struct {
+	goalRepo      *repository.GoalRepository
+	analyticsRepo repository.AnalyticsStore
+	urlService    *URLService
+	webhooks      *WebhookService
+	cache         repository.Cache
+	interval      time.Duration // 0 disables the evaluator
+	logger        *logrus.Logger
+}
+
+func NewGoalService(goalRepo *repository.GoalRepository, analyticsRepo repository.AnalyticsStore, urlService *URLService, webhooks *WebhookService, cache repository.Cache, interval time.Duration, logger *logrus.Logger) *GoalService {
+	service := &GoalService{
+		goalRepo:      goalRepo,
+		analyticsRepo: analyticsRepo,
+		urlService:    urlService,
+		webhooks:      webhooks,
+		cache:         cache,
+		interval:      interval,
+		logger:        logger,
+	}
+
+	if interval > 0 {
+		go service.schedule()
+	}
+
+	return service
+}
+
+// Create sets a click goal for a link, also when addressed by one of its aliases
+func (s *GoalService) Create(ctx context.Context, shortCode string, req *models.ClickGoalRequest) (*models.ClickGoal, error) {
+	now := time.Now().UTC()
+	if req.TargetClicks < 1 {
+		return nil, apperrors.Errorf(apperrors.ErrInvalid, "invalid goal: target_clicks must be positive")
+	}
+	if !req.Deadline.After(now) || req.Deadline.Sub(now) > maxGoalDuration {
+		return nil, apperrors.Errorf(apperrors.ErrInvalid, "invalid goal: deadline must be in the future and within %d days", int(maxGoalDuration.Hours()/24))
+	}
+
+	canonicalCode, err := s.urlService.ResolveShortCode(ctx, shortCode)
+	if err != nil {
+		return nil, err
+	}
+	goals, err := s.goalRepo.ListByShortCode(canonicalCode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list goals: %w", err)
+	}
+	if len(goals) >= maxGoalsPerLink {
+		return nil, apperrors.Errorf(apperrors.ErrInvalid, "invalid goal: a link can have at most %d goals", maxGoalsPerLink)
+	}
+
+	goal := &models.ClickGoal{
+		ShortCode:    canonicalCode,
+		TargetClicks: req.TargetClicks,
+		Deadline:     req.Deadline.UTC(),
+	}
+	if err := s.goalRepo.Create(goal); err != nil {
+		return nil, fmt.Errorf("failed to create goal: %w", err)
+	}
+	goalProgress(goal, 0, now)
+	return goal, nil
+}
+
+// List returns the goals of a link with their progress
+func (s *GoalService) List(ctx context.Context, shortCode string) ([]*models.ClickGoal, error) {
+	canonicalCode, err := s.urlService.ResolveShortCode(ctx, shortCode)
+	if err != nil {
+		return nil, err
+	}
+	goals, err := s.goalRepo.ListByShortCode(canonicalCode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list goals: %w", err)
+	}
+
+	now := time.Now().UTC()
+	for _, goal := range goals {
+		if err := s.fillProgress(ctx, goal, now); err != nil {
+			return nil, err
+		}
+	}
+	if goals == nil {
+		goals = []*models.ClickGoal{}
+	}
+	return goals, nil
+}
+
+// Delete removes a goal of a link
+func (s *GoalService) Delete(ctx context.Context, shortCode string, id int64) error {
+	canonicalCode, err := s.urlService.ResolveShortCode(ctx, shortCode)
+	if err != nil {
+		return err
+	}
+	deleted, err := s.goalRepo.Delete(id, canonicalCode)
+	if err != nil {
+		return fmt.Errorf("failed to delete goal: %w", err)
+	}
+	if !deleted {
+		return apperrors.Errorf(apperrors.ErrNotFound, "goal not found")
+	}
+	return nil
+}
+
+// Evaluate checks every open goal: goals with enough clicks are reached, goals past their
+// deadline are missed, and goals falling behind raise an alert
+func (s *GoalService) Evaluate(ctx context.Context, now time.Time) error {
+	goals, err := s.goalRepo.ListOpen()
+	if err != nil {
+		return fmt.Errorf("failed to list open goals: %w", err)
+	}
+
+	for _, goal := range goals {
+		if err := s.fillProgress(ctx, goal, now); err != nil {
+			s.logger.Errorf("Failed to evaluate goal %d: %v", goal.ID, err)
+			continue
+		}
+
+		switch goal.Status {
+		case GoalReached:
+			if marked, err := s.goalRepo.MarkReached(goal.ID, now); err != nil {
+				s.logger.Errorf("Failed to mark goal %d reached: %v", goal.ID, err)
+			} else if marked {
+				s.notify("goal.reached", goal, now)
+			}
+		case GoalMissed:
+			if _, err := s.goalRepo.MarkMissed(goal.ID, now); err != nil {
+				s.logger.Errorf("Failed to mark goal %d missed: %v", goal.ID, err)
+			}
+		case GoalBehind:
+			if marked, err := s.goalRepo.MarkBehindAlerted(goal.ID, now); err != nil {
+				s.logger.Errorf("Failed to record goal %d alert: %v", goal.ID, err)
+			} else if marked {
+				s.notify("goal.behind", goal, now)
+			}
+		}
+	}
+	return nil
+}
+
+// fillProgress counts the clicks of a goal up to now or its deadline
+func (s *GoalService) fillProgress(ctx context.Context, goal *models.ClickGoal, now time.Time) error {
+	end := now
+	if goal.Deadline.Before(end) {
+		end = goal.Deadline
+	}
+	clicks, err := s.analyticsRepo.GetClickCountBetween(ctx, goal.ShortCode, goal.CreatedAt, end)
+	if err != nil {
+		return fmt.Errorf("failed to count goal clicks: %w", err)
+	}
+	goalProgress(goal, clicks, now)
+	return nil
+}
+
+func (s *GoalService) notify(event string, goal *models.ClickGoal, now time.Time) {
+	s.webhooks.NotifyGoal(models.WebhookGoalEvent{
+		Event:           event,
+		SchemaVersion:   EventSchemaVersion(event),
+		GoalID:          goal.ID,
+		ShortCode:       goal.ShortCode,
+		TargetClicks:    goal.TargetClicks,
+		Clicks:          goal.Clicks,
+		ProjectedClicks: goal.ProjectedClicks,
+		Deadline:        goal.Deadline,
+		OccurredAt:      now,
+	})
+}
+
+// schedule evaluates the open goals every interval, on one instance at a time
+func (s *GoalService) schedule() {
+	ctx := context.Background()
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		lockKey := fmt.Sprintf("lock:click_goals:%d", now.Truncate(s.interval).Unix())
+		acquired, err := s.cache.SetNX(ctx, lockKey, "1", s.interval)
+		if err != nil {
+			s.logger.Warnf("Failed to acquire goal evaluation lock: %v", err)
+			continue
+		}
+		if !acquired {
+			continue
+		}
+
+		if err := s.Evaluate(ctx, now.UTC()); err != nil {
+			s.logger.Errorf("Failed to evaluate click goals: %v", err)
+		}
+	}
+}
+
+// goalProgress fills in the clicks, projection and status of a goal. The projection
+// extends the pace since the goal was set to its deadline; a goal is behind once the
+// projection falls short after the grace part of its time.
+func goalProgress(goal *models.ClickGoal, clicks int64, now time.Time) {
+	goal.Clicks = clicks
+	goal.ProjectedClicks = clicks
+
+	duration := goal.Deadline.Sub(goal.CreatedAt)
+	elapsed := now.Sub(goal.CreatedAt)
+	if elapsed > 0 && elapsed < duration {
+		goal.ProjectedClicks = int64(float64(clicks) * float64(duration) / float64(elapsed))
+	}
+
+	switch {
+	case goal.ReachedAt != nil || clicks >= goal.TargetClicks:
+		goal.Status = GoalReached
+	case goal.MissedAt != nil || !now.Before(goal.Deadline):
+		goal.Status = GoalMissed
+	case float64(elapsed) >= goalPaceGrace*float64(duration) && goal.ProjectedClicks < goal.TargetClicks:
+		goal.Status = GoalBehind
+	default:
+		goal.Status = GoalOnTrack
+	}
+}
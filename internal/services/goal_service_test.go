@@ -0,0 +1,42 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alexnthnz/url-shortener/internal/models"
+)
+
+func TestGoalProgress(t *testing.T) {
+	created := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+	deadline := created.Add(100 * time.Hour)
+
+	tests := []struct {
+		name      string
+		clicks    int64
+		elapsed   time.Duration
+		status    string
+		projected int64
+	}{
+		{"reached early", 1000, 50 * time.Hour, GoalReached, 2000},
+		{"ahead of pace", 600, 50 * time.Hour, GoalOnTrack, 1200},
+		{"behind pace", 300, 50 * time.Hour, GoalBehind, 600},
+		{"slow start within grace", 10, 10 * time.Hour, GoalOnTrack, 100},
+		{"deadline passed", 999, 120 * time.Hour, GoalMissed, 999},
+	}
+
+	for _, test := range tests {
+		goal := &models.ClickGoal{TargetClicks: 1000, CreatedAt: created, Deadline: deadline}
+		goalProgress(goal, test.clicks, created.Add(test.elapsed))
+		if goal.Status != test.status || goal.ProjectedClicks != test.projected {
+			t.Errorf("%s: status %s, projected %d; expected %s, %d", test.name, goal.Status, goal.ProjectedClicks, test.status, test.projected)
+		}
+	}
+
+	reachedAt := created.Add(time.Hour)
+	goal := &models.ClickGoal{TargetClicks: 1000, CreatedAt: created, Deadline: deadline, ReachedAt: &reachedAt}
+	goalProgress(goal, 1000, created.Add(200*time.Hour))
+	if goal.Status != GoalReached {
+		t.Errorf("a reached goal stays reached after its deadline, got %s", goal.Status)
+	}
+}
@@ -0,0 +1,139 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/alexnthnz/url-shortener/internal/models"
+)
+
+// Criticality decides how a failing check affects overall health
+type Criticality string
+
+const (
+	// CriticalityCritical checks make the service unhealthy, failing load balancer probes
+	CriticalityCritical Criticality = "critical"
+	// CriticalityDegraded checks mark the service degraded while it keeps serving
+	CriticalityDegraded Criticality = "degraded"
+	// CriticalityInfo checks are reported without affecting overall health
+	CriticalityInfo Criticality = "info"
+
+	HealthStatusHealthy   = "healthy"
+	HealthStatusDegraded  = "degraded"
+	HealthStatusUnhealthy = "unhealthy"
+)
+
+// HealthCheckFunc probes one subsystem, returning an error when it is not usable
+type HealthCheckFunc func(ctx context.Context) error
+
+type healthCheck struct {
+	name        string
+	criticality Criticality
+	check       HealthCheckFunc
+}
+
+// HealthService aggregates named health checks registered by subsystems. Checks run
+// concurrently, each bounded by the service timeout, so one hanging dependency cannot
+// stall the health endpoint.
+type HealthService struct {
+	timeout time.Duration
+
+	mu     sync.RWMutex
+	checks []healthCheck
+}
+
+func NewHealthService(timeout time.Duration) *HealthService {
+	return &HealthService{timeout: timeout}
+}
+
+// Register adds a named check; registering a name again replaces the earlier check
+func (s *HealthService) Register(name string, criticality Criticality, check HealthCheckFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.checks {
+		if s.checks[i].name == name {
+			s.checks[i] = healthCheck{name: name, criticality: criticality, check: check}
+			return
+		}
+	}
+	s.checks = append(s.checks, healthCheck{name: name, criticality: criticality, check: check})
+}
+
+// Check runs every registered check and derives the overall status from the failures
+func (s *HealthService) Check(ctx context.Context) *models.HealthReport {
+	s.mu.RLock()
+	checks := append([]healthCheck(nil), s.checks...)
+	s.mu.RUnlock()
+
+	results := make([]models.HealthCheckResult, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check healthCheck) {
+			defer wg.Done()
+			results[i] = s.run(ctx, check)
+		}(i, check)
+	}
+	wg.Wait()
+
+	report := &models.HealthReport{
+		Status: OverallHealth(results),
+		Checks: make(map[string]models.HealthCheckResult, len(results)),
+	}
+	for _, result := range results {
+		report.Checks[result.Name] = result
+	}
+	return report
+}
+
+// run executes one check with the service timeout
+func (s *HealthService) run(ctx context.Context, check healthCheck) models.HealthCheckResult {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- check.check(ctx) }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := models.HealthCheckResult{
+		Name:        check.name,
+		Criticality: string(check.criticality),
+		Status:      HealthStatusHealthy,
+		LatencyMs:   float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Error = err.Error()
+		result.Status = HealthStatusUnhealthy
+		if check.criticality != CriticalityCritical {
+			result.Status = HealthStatusDegraded
+		}
+	}
+	return result
+}
+
+// OverallHealth is unhealthy when a critical check failed, degraded when a degraded check
+// failed, and healthy otherwise
+func OverallHealth(results []models.HealthCheckResult) string {
+	status := HealthStatusHealthy
+	for _, result := range results {
+		if result.Error == "" {
+			continue
+		}
+		switch Criticality(result.Criticality) {
+		case CriticalityCritical:
+			return HealthStatusUnhealthy
+		case CriticalityDegraded:
+			status = HealthStatusDegraded
+		}
+	}
+	return status
+}
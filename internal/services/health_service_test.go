@@ -0,0 +1,53 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHealthServiceAggregation(t *testing.T) {
+	ok := func(ctx context.Context) error { return nil }
+	failing := func(ctx context.Context) error { return errors.New("down") }
+	hanging := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	testCases := []struct {
+		name     string
+		register func(s *HealthService)
+		expected string
+	}{
+		{"all healthy", func(s *HealthService) {
+			s.Register("database", CriticalityCritical, ok)
+			s.Register("cache", CriticalityDegraded, ok)
+		}, HealthStatusHealthy},
+		{"degraded dependency", func(s *HealthService) {
+			s.Register("database", CriticalityCritical, ok)
+			s.Register("cache", CriticalityDegraded, failing)
+		}, HealthStatusDegraded},
+		{"critical dependency hangs", func(s *HealthService) {
+			s.Register("database", CriticalityCritical, hanging)
+			s.Register("cache", CriticalityDegraded, failing)
+		}, HealthStatusUnhealthy},
+		{"informational failure", func(s *HealthService) {
+			s.Register("geoip", CriticalityInfo, failing)
+		}, HealthStatusHealthy},
+		{"re-registration replaces", func(s *HealthService) {
+			s.Register("database", CriticalityCritical, failing)
+			s.Register("database", CriticalityCritical, ok)
+		}, HealthStatusHealthy},
+	}
+
+	for _, tc := range testCases {
+		service := NewHealthService(20 * time.Millisecond)
+		tc.register(service)
+
+		report := service.Check(context.Background())
+		if report.Status != tc.expected {
+			t.Errorf("%s: status = %s; expected %s", tc.name, report.Status, tc.expected)
+		}
+	}
+}
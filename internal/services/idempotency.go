@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	apperrors "github.com/alexnthnz/url-shortener/internal/errors"
+	"github.com/alexnthnz/url-shortener/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// maxIdempotencyKeyLength bounds the Idempotency-Key header
+	maxIdempotencyKeyLength = 255
+	// idempotencyPendingTTL bounds how long a request holds its key before it completes, so
+	// a key is not locked forever when an instance dies mid-request
+	idempotencyPendingTTL = time.Minute
+)
+
+// idempotencyRecord is what is stored under an idempotency key: the request it was first
+// used with and, once that completed, its response
+type idempotencyRecord struct {
+	Fingerprint string      `json:"fingerprint"`
+	Pending     bool        `json:"pending,omitempty"`
+	Status      int         `json:"status,omitempty"`
+	Header      http.Header `json:"header,omitempty"`
+	Body        []byte      `json:"body,omitempty"`
+}
+
+// IdempotentResponse is the stored response replayed for a retried request. Header holds
+// the headers the route itself set, such as Content-Type or Location.
+type IdempotentResponse struct {
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// IdempotencyService remembers the responses of requests sent with an Idempotency-Key, so
+// a client retrying after a timeout gets the original response instead of a second link.
+// Keys are scoped per client and kept in Redis for ttl.
+type IdempotencyService struct {
+	cache  repository.Cache
+	ttl    time.Duration
+	logger *logrus.Logger
+}
+
+func NewIdempotencyService(cache repository.Cache, ttl time.Duration, logger *logrus.Logger) *IdempotencyService {
+	return &IdempotencyService{
+		cache:  cache,
+		ttl:    ttl,
+		logger: logger,
+	}
+}
+
+// ValidateIdempotencyKey checks an Idempotency-Key header: 1 to 255 printable ASCII characters
+func ValidateIdempotencyKey(key string) error {
+	if key == "" || len(key) > maxIdempotencyKeyLength {
+		return apperrors.Errorf(apperrors.ErrInvalid, "invalid idempotency key: must be 1 to %d characters", maxIdempotencyKeyLength)
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x20 || key[i] > 0x7e {
+			return apperrors.Errorf(apperrors.ErrInvalid, "invalid idempotency key: must be printable ASCII")
+		}
+	}
+	return nil
+}
+
+// Begin claims a key of a client for a request, identified by its fingerprint. It returns
+// the stored response when the request already completed. A key still held by the
+// original request is "in use"; a key used with a different request was "reused".
+func (s *IdempotencyService) Begin(ctx context.Context, scope, key, fingerprint string) (*IdempotentResponse, error) {
+	cacheKey := idempotencyCacheKey(scope, key)
+	pending, _ := json.Marshal(idempotencyRecord{Fingerprint: fingerprint, Pending: true})
+	claimed, err := s.cache.SetNX(ctx, cacheKey, string(pending), idempotencyPendingTTL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim idempotency key: %w", err)
+	}
+	if claimed {
+		return nil, nil
+	}
+
+	stored, err := s.cache.Get(ctx, cacheKey)
+	if err != nil {
+		// The original request released the key in the meantime
+		return nil, apperrors.Errorf(apperrors.ErrConflict, "idempotency key in use")
+	}
+	var record idempotencyRecord
+	if err := json.Unmarshal([]byte(stored), &record); err != nil {
+		return nil, fmt.Errorf("failed to decode idempotency record: %w", err)
+	}
+
+	switch {
+	case record.Fingerprint != fingerprint:
+		return nil, apperrors.Errorf(apperrors.ErrMismatch, "idempotency key reused with a different request")
+	case record.Pending:
+		return nil, apperrors.Errorf(apperrors.ErrConflict, "idempotency key in use")
+	}
+	return &IdempotentResponse{Status: record.Status, Header: record.Header, Body: record.Body}, nil
+}
+
+// Complete stores the response of a request that claimed a key. It is stored even when the
+// client has gone, since that is when it retries.
+func (s *IdempotencyService) Complete(ctx context.Context, scope, key, fingerprint string, response *IdempotentResponse) {
+	ctx = context.WithoutCancel(ctx)
+	encoded, _ := json.Marshal(idempotencyRecord{Fingerprint: fingerprint, Status: response.Status, Header: response.Header, Body: response.Body})
+	if err := s.cache.SetWithTTL(ctx, idempotencyCacheKey(scope, key), string(encoded), s.ttl); err != nil {
+		s.logger.Warnf("Failed to store idempotent response: %v", err)
+	}
+}
+
+// Release frees a key whose request failed, so a retry runs again. Like Complete, it runs
+// even when the client has gone.
+func (s *IdempotencyService) Release(ctx context.Context, scope, key string) {
+	ctx = context.WithoutCancel(ctx)
+	if err := s.cache.Delete(ctx, idempotencyCacheKey(scope, key)); err != nil {
+		s.logger.Warnf("Failed to release idempotency key: %v", err)
+	}
+}
+
+// RequestFingerprint identifies a request by its method, URI and body
+func RequestFingerprint(method, uri string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(method + " " + uri + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// idempotencyCacheKey is the cache key of a client's idempotency key; keys are hashed
+// since they are arbitrary client input
+func idempotencyCacheKey(scope, key string) string {
+	sum := sha256.Sum256([]byte(scope + "\x00" + key))
+	return "idempotency:" + hex.EncodeToString(sum[:16])
+}
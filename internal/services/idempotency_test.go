@@ -0,0 +1,71 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexnthnz/url-shortener/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+func TestValidateIdempotencyKey(t *testing.T) {
+	testCases := []struct {
+		key   string
+		valid bool
+	}{
+		{"3b4c5a7e-9f1d-4c2b-8e6a-1d2f3a4b5c6d", true},
+		{"order 42 / retry", true},
+		{strings.Repeat("k", maxIdempotencyKeyLength), true},
+		{"", false},
+		{strings.Repeat("k", maxIdempotencyKeyLength+1), false},
+		{"line\nbreak", false},
+		{"clé", false},
+	}
+
+	for _, tc := range testCases {
+		if err := ValidateIdempotencyKey(tc.key); (err == nil) != tc.valid {
+			t.Errorf("ValidateIdempotencyKey(%q) = %v; expected valid = %v", tc.key, err, tc.valid)
+		}
+	}
+}
+
+func TestRequestFingerprint(t *testing.T) {
+	body := []byte(`{"url": "https://example.com"}`)
+	first := RequestFingerprint("POST", "/api/v1/shorten", body)
+
+	if RequestFingerprint("POST", "/api/v1/shorten", body) != first {
+		t.Error("the same request should have the same fingerprint")
+	}
+	if RequestFingerprint("POST", "/api/v1/shorten?dry_run=true", body) == first {
+		t.Error("a different query should change the fingerprint")
+	}
+	if RequestFingerprint("POST", "/api/v1/shorten", []byte(`{"url": "https://example.org"}`)) == first {
+		t.Error("a different body should change the fingerprint")
+	}
+}
+
+func TestIdempotencyReplay(t *testing.T) {
+	ctx := context.Background()
+	service := NewIdempotencyService(repository.NewMemoryCache(0), time.Hour, logrus.New())
+	fingerprint := RequestFingerprint("POST", "/api/v1/shorten", []byte(`{"url": "https://example.com"}`))
+
+	if replay, err := service.Begin(ctx, "ip:203.0.113.7", "key-1", fingerprint); err != nil || replay != nil {
+		t.Fatalf("Begin() = %v, %v; expected the key to be claimed", replay, err)
+	}
+	service.Complete(ctx, "ip:203.0.113.7", "key-1", fingerprint, &IdempotentResponse{
+		Status: http.StatusCreated,
+		Header: http.Header{"Content-Type": {"application/json; charset=utf-8"}, "Location": {"/api/v1/urls/abc123"}},
+		Body:   []byte(`{"short_code": "abc123"}`),
+	})
+
+	replay, err := service.Begin(ctx, "ip:203.0.113.7", "key-1", fingerprint)
+	if err != nil || replay == nil {
+		t.Fatalf("Begin() = %v, %v; expected the stored response", replay, err)
+	}
+	if replay.Status != http.StatusCreated || replay.Header.Get("Location") != "/api/v1/urls/abc123" || string(replay.Body) != `{"short_code": "abc123"}` {
+		t.Errorf("Begin() replayed %d %v %s; expected the stored response with its headers", replay.Status, replay.Header, replay.Body)
+	}
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// InternalNetworks are the address ranges of the team's own offices and VPNs. Clicks from
+// them are recorded but flagged internal, so a team testing its own links does not skew
+// the numbers of a small campaign.
+type InternalNetworks struct {
+	networks []*net.IPNet
+}
+
+// ParseInternalNetworks parses CIDR ranges such as 203.0.113.0/24; a plain address counts
+// as a range of one
+func ParseInternalNetworks(ranges []string) (*InternalNetworks, error) {
+	internal := &InternalNetworks{}
+	for _, entry := range ranges {
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid internal network %q", entry)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			internal.networks = append(internal.networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid internal network %q", entry)
+		}
+		internal.networks = append(internal.networks, network)
+	}
+	return internal, nil
+}
+
+// Contains reports whether a click from ipAddress is internal
+func (n *InternalNetworks) Contains(ipAddress string) bool {
+	if n == nil {
+		return false
+	}
+	ip := net.ParseIP(ipAddress)
+	if ip == nil {
+		return false
+	}
+	for _, network := range n.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
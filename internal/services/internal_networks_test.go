@@ -0,0 +1,36 @@
+package services
+
+import "testing"
+
+func TestInternalNetworks(t *testing.T) {
+	networks, err := ParseInternalNetworks([]string{"203.0.113.0/24", "2001:db8::/32", "198.51.100.7"})
+	if err != nil {
+		t.Fatalf("ParseInternalNetworks() returned error: %v", err)
+	}
+
+	tests := []struct {
+		ip       string
+		internal bool
+	}{
+		{"203.0.113.42", true},
+		{"203.0.114.1", false},
+		{"2001:db8::1", true},
+		{"198.51.100.7", true},
+		{"198.51.100.8", false},
+		{"::ffff:203.0.113.9", true},
+		{"unknown", false},
+	}
+	for _, test := range tests {
+		if internal := networks.Contains(test.ip); internal != test.internal {
+			t.Errorf("Contains(%s) = %v; expected %v", test.ip, internal, test.internal)
+		}
+	}
+
+	if _, err := ParseInternalNetworks([]string{"203.0.113.0/33"}); err == nil {
+		t.Error("ParseInternalNetworks() should reject an invalid range")
+	}
+	var none *InternalNetworks
+	if none.Contains("203.0.113.42") {
+		t.Error("no click is internal without configured networks")
+	}
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	apperrors "github.com/alexnthnz/url-shortener/internal/errors"
+	"github.com/alexnthnz/url-shortener/internal/models"
+	"github.com/alexnthnz/url-shortener/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	JobStatusRunning   = "running"
+	JobStatusCompleted = "completed"
+	JobStatusFailed    = "failed"
+
+	recentJobsLimit = 50
+)
+
+// BatchFunc processes one bounded batch starting at cursor. It returns how many items it
+// handled and the cursor the next batch starts at; the cursor is stored with the job so
+// an interrupted job resumes where it stopped.
+type BatchFunc func(ctx context.Context, cursor string) (int64, string, error)
+
+// JobService runs background jobs and records their progress
+type JobService struct {
+	jobRepo    *repository.JobRepository
+	batchPause time.Duration
+	staleAfter time.Duration
+	logger     *logrus.Logger
+
+	mu      sync.RWMutex
+	batches map[string]BatchFunc
+}
+
+// NewJobService creates the job runner. Running jobs whose progress has not been recorded
+// for staleAfter are taken to have been interrupted by a restart or crash.
+func NewJobService(jobRepo *repository.JobRepository, batchPause, staleAfter time.Duration, logger *logrus.Logger) *JobService {
+	return &JobService{
+		jobRepo:    jobRepo,
+		batchPause: batchPause,
+		staleAfter: staleAfter,
+		logger:     logger,
+		batches:    make(map[string]BatchFunc),
+	}
+}
+
+// RegisterBatchJob names the batch function of a job type, so jobs of the type can be
+// started and resumed after a restart
+func (s *JobService) RegisterBatchJob(jobType string, batch BatchFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches[jobType] = batch
+}
+
+// StartBatchJob records a job and runs its batches from cursor until one returns 0,
+// pausing between batches so bulk work never holds locks for long
+func (s *JobService) StartBatchJob(jobType, cursor string) (*models.Job, error) {
+	batch := s.batch(jobType)
+	if batch == nil {
+		return nil, fmt.Errorf("unknown job type %s", jobType)
+	}
+
+	job := &models.Job{
+		Type:   jobType,
+		Status: JobStatusRunning,
+		Cursor: cursor,
+	}
+	if err := s.jobRepo.Create(job); err != nil {
+		return nil, fmt.Errorf("failed to create job: %w", err)
+	}
+
+	go s.runBatches(job, batch)
+
+	return job, nil
+}
+
+// ResumeInterrupted continues the jobs left running by an instance that stopped, from
+// the cursor of their last recorded batch. Jobs of a type this instance cannot run are
+// marked failed instead of staying running forever.
+func (s *JobService) ResumeInterrupted() {
+	jobs, err := s.jobRepo.ClaimStale(JobStatusRunning, s.staleAfter)
+	if err != nil {
+		s.logger.Errorf("Failed to look up interrupted jobs: %v", err)
+		return
+	}
+
+	for _, job := range jobs {
+		batch := s.batch(job.Type)
+		if batch == nil {
+			s.logger.Warnf("Job %d (%s) was interrupted and cannot be resumed", job.ID, job.Type)
+			if err := s.jobRepo.Finish(job.ID, JobStatusFailed, job.Processed, "interrupted by a restart"); err != nil {
+				s.logger.Errorf("Failed to record job %d failure: %v", job.ID, err)
+			}
+			continue
+		}
+
+		s.logger.Infof("Resuming job %d (%s) after %d items", job.ID, job.Type, job.Processed)
+		go s.runBatches(job, batch)
+	}
+}
+
+// GetJob retrieves a job by ID
+func (s *JobService) GetJob(id int64) (*models.Job, error) {
+	job, err := s.jobRepo.GetByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job: %w", err)
+	}
+	if job == nil {
+		return nil, apperrors.Errorf(apperrors.ErrNotFound, "job not found")
+	}
+	return job, nil
+}
+
+// ListJobs returns the most recent jobs
+func (s *JobService) ListJobs() ([]*models.Job, error) {
+	jobs, err := s.jobRepo.ListRecent(recentJobsLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	}
+	return jobs, nil
+}
+
+func (s *JobService) batch(jobType string) BatchFunc {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.batches[jobType]
+}
+
+// runBatches drives a batch job to completion, recording its progress and cursor after
+// every batch
+func (s *JobService) runBatches(job *models.Job, batch BatchFunc) {
+	ctx := context.Background()
+	processed, cursor := job.Processed, job.Cursor
+	for {
+		n, next, err := batch(ctx, cursor)
+		if err != nil {
+			s.logger.Errorf("Job %d (%s) failed after %d items: %v", job.ID, job.Type, processed, err)
+			if err := s.jobRepo.Finish(job.ID, JobStatusFailed, processed, err.Error()); err != nil {
+				s.logger.Errorf("Failed to record job %d failure: %v", job.ID, err)
+			}
+			return
+		}
+		if n == 0 {
+			break
+		}
+
+		processed, cursor = processed+n, next
+		if err := s.jobRepo.UpdateProgress(job.ID, processed, cursor); err != nil {
+			s.logger.Warnf("Failed to record job %d progress: %v", job.ID, err)
+		}
+
+		time.Sleep(s.batchPause)
+	}
+
+	if err := s.jobRepo.Finish(job.ID, JobStatusCompleted, processed, ""); err != nil {
+		s.logger.Errorf("Failed to record job %d completion: %v", job.ID, err)
+	}
+	s.logger.Infof("Job %d (%s) completed, %d items processed", job.ID, job.Type, processed)
+}
+
+// storeCursor is the cursor of a job walking several databases in turn: the index of the
+// database and the id of the last row handled in it
+func storeCursor(index int, afterID int64) string {
+	return fmt.Sprintf("%d:%d", index, afterID)
+}
+
+func parseStoreCursor(cursor string) (int, int64, error) {
+	var index int
+	var afterID int64
+	if _, err := fmt.Sscanf(cursor, "%d:%d", &index, &afterID); err != nil {
+		return 0, 0, fmt.Errorf("invalid job cursor %q: %w", cursor, err)
+	}
+	return index, afterID, nil
+}
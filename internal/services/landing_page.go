@@ -0,0 +1,170 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+	"unicode/utf8"
+
+	apperrors "github.com/alexnthnz/url-shortener/internal/errors"
+	"github.com/alexnthnz/url-shortener/internal/models"
+)
+
+const (
+	// maxLandingPageLinks bounds the buttons of a landing page
+	maxLandingPageLinks = 50
+	// maxLandingPageTitle bounds a page's title and each button label
+	maxLandingPageTitle = 100
+	// maxLandingPageDescription bounds a page's description
+	maxLandingPageDescription = 500
+)
+
+// GetLandingPage returns the landing page of a link
+func (s *URLService) GetLandingPage(ctx context.Context, shortCode string) (*models.LandingPage, error) {
+	canonicalCode, err := s.ResolveShortCode(ctx, shortCode)
+	if err != nil {
+		return nil, err
+	}
+	page, _, err := s.urlRepo.GetLandingPage(ctx, canonicalCode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get landing page: %w", err)
+	}
+	if page == nil {
+		return nil, apperrors.Errorf(apperrors.ErrNotFound, "landing page not found")
+	}
+	return page, nil
+}
+
+// SetLandingPage validates and replaces the landing page of a link, which then serves
+// the page instead of redirecting. Button URLs are checked, normalized and screened like
+// the link's own destination.
+func (s *URLService) SetLandingPage(ctx context.Context, shortCode string, page *models.LandingPage) (*models.LandingPage, error) {
+	canonicalCode, err := s.ResolveShortCode(ctx, shortCode)
+	if err != nil {
+		return nil, err
+	}
+
+	page.Title = strings.TrimSpace(page.Title)
+	page.Description = strings.TrimSpace(page.Description)
+	if page.Title == "" || utf8.RuneCountInString(page.Title) > maxLandingPageTitle {
+		return nil, apperrors.Errorf(apperrors.ErrInvalid, "invalid landing page: title must be 1 to %d characters", maxLandingPageTitle)
+	}
+	if utf8.RuneCountInString(page.Description) > maxLandingPageDescription {
+		return nil, apperrors.Errorf(apperrors.ErrInvalid, "invalid landing page: description must be at most %d characters", maxLandingPageDescription)
+	}
+	if len(page.Links) == 0 || len(page.Links) > maxLandingPageLinks {
+		return nil, apperrors.Errorf(apperrors.ErrInvalid, "invalid landing page: between 1 and %d links are required", maxLandingPageLinks)
+	}
+
+	for i := range page.Links {
+		link := &page.Links[i]
+		link.Label = strings.TrimSpace(link.Label)
+		if link.Label == "" || utf8.RuneCountInString(link.Label) > maxLandingPageTitle {
+			return nil, apperrors.Errorf(apperrors.ErrInvalid, "invalid landing page: link %d: label must be 1 to %d characters", i, maxLandingPageTitle)
+		}
+		if err := s.validateURL(link.URL); err != nil {
+			return nil, apperrors.Errorf(apperrors.ErrInvalid, "invalid landing page: link %d: %w", i, err)
+		}
+		link.URL = normalizeURL(link.URL, s.normalize)
+		if err := s.validateLink(&models.LinkProposal{Action: ValidationLandingPage, ShortCode: canonicalCode, Destination: link.URL}); err != nil {
+			var rejection *LinkRejection
+			if errors.As(err, &rejection) {
+				return nil, apperrors.Errorf(apperrors.ErrInvalid, "invalid landing page: link %d: %w", i, err)
+			}
+			return nil, err
+		}
+	}
+
+	destinations := make([]string, len(page.Links))
+	for i, link := range page.Links {
+		destinations[i] = link.URL
+	}
+	listed, threat, err := s.screenDestinations(ctx, destinations)
+	if err != nil {
+		return nil, apperrors.Errorf(apperrors.ErrInvalid, "invalid landing page: %w", err)
+	}
+
+	if err := s.storeLandingPage(ctx, canonicalCode, page); err != nil {
+		return nil, err
+	}
+	s.flagThreat(ctx, canonicalCode, listed, threat)
+	return page, nil
+}
+
+// DeleteLandingPage removes the landing page of a link, which redirects again
+func (s *URLService) DeleteLandingPage(ctx context.Context, shortCode string) error {
+	canonicalCode, err := s.ResolveShortCode(ctx, shortCode)
+	if err != nil {
+		return err
+	}
+	return s.storeLandingPage(ctx, canonicalCode, nil)
+}
+
+func (s *URLService) storeLandingPage(ctx context.Context, shortCode string, page *models.LandingPage) error {
+	found, err := s.urlRepo.SetLandingPage(ctx, shortCode, page)
+	if err != nil {
+		return fmt.Errorf("failed to update landing page: %w", err)
+	}
+	if !found {
+		return apperrors.Errorf(apperrors.ErrNotFound, "URL not found")
+	}
+	if err := s.cache.Delete(ctx, landingPageCacheKey(shortCode)); err != nil {
+		s.logger.Warnf("Failed to invalidate landing page cache: %v", err)
+	}
+	return nil
+}
+
+// landingPage returns the landing page of a link, nil when it redirects, cached next to
+// its destination
+func (s *URLService) landingPage(ctx context.Context, shortCode string) (*models.LandingPage, error) {
+	var page *models.LandingPage
+	if cached, err := s.cache.Get(ctx, landingPageCacheKey(shortCode)); err == nil {
+		if err := json.Unmarshal([]byte(cached), &page); err == nil {
+			return page, nil
+		}
+	}
+
+	page, _, err := s.urlRepo.GetLandingPage(ctx, shortCode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get landing page: %w", err)
+	}
+
+	encoded, _ := json.Marshal(page)
+	if err := s.cache.Set(ctx, landingPageCacheKey(shortCode), string(encoded)); err != nil {
+		s.logger.Warnf("Failed to cache landing page: %v", err)
+	}
+	return page, nil
+}
+
+// RenderLandingPage renders a landing page as HTML. Buttons whose URL a domain policy
+// added since no longer permits are left out, as those links would not redirect either.
+func (s *URLService) RenderLandingPage(page *models.LandingPage) string {
+	var buttons strings.Builder
+	for _, link := range page.Links {
+		if s.CheckDestination(link.URL) != nil {
+			continue
+		}
+		fmt.Fprintf(&buttons, `<li><a href="%s" rel="noopener noreferrer">%s</a></li>`,
+			html.EscapeString(link.URL), html.EscapeString(link.Label))
+	}
+
+	description := ""
+	if page.Description != "" {
+		description = "<p>" + html.EscapeString(page.Description) + "</p>"
+	}
+	return fmt.Sprintf(`<!DOCTYPE html><html><head><meta charset="utf-8">`+
+		`<meta name="viewport" content="width=device-width, initial-scale=1"><title>%s</title><style>`+
+		`body{font-family:sans-serif;max-width:480px;margin:2em auto;padding:0 1em;text-align:center}`+
+		`ul{list-style:none;padding:0}li{margin:.75em 0}`+
+		`a{display:block;padding:.9em;border:1px solid #333;border-radius:8px;color:#333;text-decoration:none}`+
+		`</style></head><body><h1>%s</h1>%s<ul>%s</ul></body></html>`,
+		html.EscapeString(page.Title), html.EscapeString(page.Title), description, buttons.String())
+}
+
+// landingPageCacheKey is the cache key of a link's landing page
+func landingPageCacheKey(shortCode string) string {
+	return "page:" + shortCode
+}
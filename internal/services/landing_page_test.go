@@ -0,0 +1,37 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alexnthnz/url-shortener/internal/models"
+)
+
+func TestRenderLandingPage(t *testing.T) {
+	service := &URLService{}
+	page := &models.LandingPage{
+		Title:       "Jo's <links>",
+		Description: "Everything & more",
+		Links: []models.LandingPageLink{
+			{Label: "Shop", URL: "https://shop.example.com/?a=1&b=2"},
+			{Label: "Blog", URL: "https://blog.example.com/"},
+		},
+	}
+
+	rendered := service.RenderLandingPage(page)
+	for _, expected := range []string{
+		"<title>Jo&#39;s &lt;links&gt;</title>",
+		"<p>Everything &amp; more</p>",
+		`<a href="https://shop.example.com/?a=1&amp;b=2" rel="noopener noreferrer">Shop</a>`,
+	} {
+		if !strings.Contains(rendered, expected) {
+			t.Errorf("RenderLandingPage() does not contain %s", expected)
+		}
+	}
+	if strings.Index(rendered, ">Shop<") > strings.Index(rendered, ">Blog<") {
+		t.Error("RenderLandingPage() should keep the order of the links")
+	}
+	if strings.Contains(rendered, "<links>") {
+		t.Error("RenderLandingPage() should escape the title")
+	}
+}
@@ -0,0 +1,39 @@
+package services
+
+import (
+	"time"
+
+	apperrors "github.com/alexnthnz/url-shortener/internal/errors"
+	"github.com/alexnthnz/url-shortener/internal/models"
+)
+
+// LinkInactiveError is returned for a scheduled link visited before its activation time
+type LinkInactiveError struct {
+	ActivateAt time.Time
+}
+
+func (e *LinkInactiveError) Error() string {
+	return "link not active until " + e.ActivateAt.UTC().Format(time.RFC3339)
+}
+
+func (e *LinkInactiveError) Unwrap() error {
+	return apperrors.ErrNotActive
+}
+
+// validateActivation checks the activation time of a shorten request, returning it in UTC
+func validateActivation(req *models.ShortenRequest, now time.Time) (*time.Time, error) {
+	if req.ActivateAt == nil {
+		return nil, nil
+	}
+	if !req.ActivateAt.After(now) {
+		return nil, apperrors.Errorf(apperrors.ErrInvalid, "invalid activation time: activate_at must be in the future")
+	}
+	activateAt := req.ActivateAt.UTC()
+	return &activateAt, nil
+}
+
+// isActive reports whether a link redirects at now, which scheduled links only do from
+// their activation time
+func isActive(urlRecord *models.URL, now time.Time) bool {
+	return urlRecord.ActivateAt == nil || !now.Before(*urlRecord.ActivateAt)
+}
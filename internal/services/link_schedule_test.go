@@ -0,0 +1,50 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alexnthnz/url-shortener/internal/models"
+)
+
+func TestValidateActivation(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	launch := now.Add(24 * time.Hour).In(time.FixedZone("CEST", 2*60*60))
+
+	activateAt, err := validateActivation(&models.ShortenRequest{ActivateAt: &launch}, now)
+	if err != nil {
+		t.Fatalf("validateActivation() returned error: %v", err)
+	}
+	if !activateAt.Equal(launch) || activateAt.Location() != time.UTC {
+		t.Errorf("validateActivation() = %v; expected %v in UTC", activateAt, launch)
+	}
+
+	if activateAt, err := validateActivation(&models.ShortenRequest{}, now); err != nil || activateAt != nil {
+		t.Errorf("validateActivation() = %v, %v; expected no activation time", activateAt, err)
+	}
+	if _, err := validateActivation(&models.ShortenRequest{ActivateAt: &now}, now); err == nil {
+		t.Error("validateActivation() accepted an activation time that is not in the future")
+	}
+}
+
+func TestIsActive(t *testing.T) {
+	launch := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name       string
+		activateAt *time.Time
+		now        time.Time
+		expected   bool
+	}{
+		{"unscheduled", nil, launch, true},
+		{"before launch", &launch, launch.Add(-time.Second), false},
+		{"at launch", &launch, launch, true},
+		{"after launch", &launch, launch.Add(time.Hour), true},
+	}
+
+	for _, tc := range testCases {
+		if result := isActive(&models.URL{ActivateAt: tc.activateAt}, tc.now); result != tc.expected {
+			t.Errorf("%s: isActive() = %v; expected %v", tc.name, result, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	apperrors "github.com/alexnthnz/url-shortener/internal/errors"
+	"github.com/alexnthnz/url-shortener/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// Actions link validators are asked about
+const (
+	ValidationShorten           = "shorten"
+	ValidationAddAlias          = "add_alias"
+	ValidationUpdateDestination = "update_destination"
+	ValidationRedirectRule      = "redirect_rule"
+	ValidationLandingPage       = "landing_page"
+)
+
+// LinkValidator vetoes links before they are created or changed, so deployments can
+// enforce their own policies on aliases and destinations. Validators see proposals after
+// the service's own checks passed, destinations normalized. Returning a *LinkRejection
+// refuses the proposal with its reason; any other error fails the request.
+type LinkValidator interface {
+	Validate(proposal *models.LinkProposal) error
+}
+
+// LinkRejection is the error a validator vetoes a proposal with
+type LinkRejection struct {
+	Reason string
+}
+
+func (e *LinkRejection) Error() string {
+	return "rejected by link policy: " + e.Reason
+}
+
+func (e *LinkRejection) Unwrap() error {
+	return apperrors.ErrRejected
+}
+
+// RegisterValidator adds a validator consulted on every new link, alias, destination
+// change and redirect rule, after those registered before it. Validators are registered
+// at startup, before requests are served.
+func (s *URLService) RegisterValidator(validator LinkValidator) {
+	s.validators = append(s.validators, validator)
+}
+
+// validateLink asks every validator about a proposal, stopping at the first veto
+func (s *URLService) validateLink(proposal *models.LinkProposal) error {
+	for _, validator := range s.validators {
+		err := validator.Validate(proposal)
+		if err == nil {
+			continue
+		}
+		var rejection *LinkRejection
+		if errors.As(err, &rejection) {
+			return rejection
+		}
+		return fmt.Errorf("failed to validate link: %w", err)
+	}
+	return nil
+}
+
+// WebhookValidator asks an external service about each proposal. The proposal is posted
+// as JSON, signed like click webhooks with an X-Webhook-Signature header, and the service
+// answers 200 with {"allowed": true} or {"allowed": false, "reason": "..."}.
+type WebhookValidator struct {
+	url    string
+	secret string
+	// failOpen lets proposals through when the service cannot be reached or answers
+	// unexpectedly, instead of failing the request
+	failOpen bool
+	client   *http.Client
+	logger   *logrus.Logger
+}
+
+func NewWebhookValidator(url, secret string, timeout time.Duration, failOpen bool, logger *logrus.Logger) *WebhookValidator {
+	return &WebhookValidator{
+		url:      url,
+		secret:   secret,
+		failOpen: failOpen,
+		client:   &http.Client{Timeout: timeout},
+		logger:   logger,
+	}
+}
+
+// Validate implements LinkValidator
+func (v *WebhookValidator) Validate(proposal *models.LinkProposal) error {
+	verdict, err := v.ask(proposal)
+	if err != nil {
+		if v.failOpen {
+			v.logger.Warnf("Link validator unavailable, allowing %s: %v", proposal.Action, err)
+			return nil
+		}
+		return err
+	}
+
+	if !verdict.Allowed {
+		reason := verdict.Reason
+		if reason == "" {
+			reason = "not allowed"
+		}
+		return &LinkRejection{Reason: reason}
+	}
+	return nil
+}
+
+// ask posts a proposal to the validation service and decodes its verdict
+func (v *WebhookValidator) ask(proposal *models.LinkProposal) (*models.LinkVerdict, error) {
+	body, err := json.Marshal(proposal)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), v.client.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if v.secret != "" {
+		req.Header.Set("X-Webhook-Signature", "sha256="+signPayload(v.secret, body))
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("link validator request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("link validator returned status %d", resp.StatusCode)
+	}
+
+	var verdict models.LinkVerdict
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&verdict); err != nil {
+		return nil, fmt.Errorf("failed to decode link validator response: %w", err)
+	}
+	return &verdict, nil
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alexnthnz/url-shortener/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+func TestWebhookValidator(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get("X-Webhook-Signature") != "sha256="+signPayload("secret", body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var proposal models.LinkProposal
+		if err := json.Unmarshal(body, &proposal); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch proposal.Alias {
+		case "ceo":
+			w.Write([]byte(`{"allowed": false, "reason": "reserved for the executive team"}`))
+		case "broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.Write([]byte(`{"allowed": true}`))
+		}
+	}))
+	defer server.Close()
+
+	validator := NewWebhookValidator(server.URL, "secret", time.Second, false, logrus.New())
+
+	if err := validator.Validate(&models.LinkProposal{Action: ValidationShorten, Alias: "launch"}); err != nil {
+		t.Errorf("Validate() returned error for an allowed alias: %v", err)
+	}
+
+	err := validator.Validate(&models.LinkProposal{Action: ValidationShorten, Alias: "ceo"})
+	var rejection *LinkRejection
+	if !errors.As(err, &rejection) || rejection.Reason != "reserved for the executive team" {
+		t.Errorf("Validate() = %v; expected a rejection with the service's reason", err)
+	}
+
+	err = validator.Validate(&models.LinkProposal{Action: ValidationShorten, Alias: "broken"})
+	if err == nil || errors.As(err, &rejection) {
+		t.Errorf("Validate() = %v; expected a failure, not a rejection", err)
+	}
+
+	validator.failOpen = true
+	if err := validator.Validate(&models.LinkProposal{Action: ValidationShorten, Alias: "broken"}); err != nil {
+		t.Errorf("Validate() returned error while failing open: %v", err)
+	}
+
+	validator.secret = "wrong"
+	validator.failOpen = false
+	if err := validator.Validate(&models.LinkProposal{Action: ValidationShorten, Alias: "launch"}); err == nil {
+		t.Error("Validate() should fail when the service refuses the signature")
+	}
+}
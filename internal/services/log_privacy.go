@@ -0,0 +1,54 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+const (
+	LogShortCodesPlain  = "plain"
+	LogShortCodesHashed = "hashed"
+
+	// hashedCodeLength keeps logged hashes short while collisions stay unlikely
+	hashedCodeLength = 16
+)
+
+// LogPrivacy decides how short codes appear in logs. In hashed mode every instance
+// sharing the key logs the same keyed hash for a code, so requests can still be
+// correlated internally without the logs revealing which links were visited.
+type LogPrivacy struct {
+	hashCodes bool
+	key       []byte
+}
+
+func NewLogPrivacy(mode, key string) (*LogPrivacy, error) {
+	switch mode {
+	case "", LogShortCodesPlain:
+		return &LogPrivacy{}, nil
+	case LogShortCodesHashed:
+		if len(key) < 32 {
+			return nil, fmt.Errorf("hashed short code logging requires a hash key of at least 32 bytes")
+		}
+		return &LogPrivacy{hashCodes: true, key: []byte(key)}, nil
+	default:
+		return nil, fmt.Errorf("unknown short code log mode %q", mode)
+	}
+}
+
+// HashCodes reports whether short codes and destinations must be kept out of logs
+func (p *LogPrivacy) HashCodes() bool {
+	return p != nil && p.hashCodes
+}
+
+// ShortCode returns the short code as it should be logged
+func (p *LogPrivacy) ShortCode(shortCode string) string {
+	if !p.HashCodes() {
+		return shortCode
+	}
+
+	mac := hmac.New(sha256.New, p.key)
+	mac.Write([]byte(shortCode))
+	return "h:" + hex.EncodeToString(mac.Sum(nil))[:hashedCodeLength]
+}
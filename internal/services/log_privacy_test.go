@@ -0,0 +1,42 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLogPrivacy(t *testing.T) {
+	key := strings.Repeat("k", 32)
+
+	plain, err := NewLogPrivacy(LogShortCodesPlain, "")
+	if err != nil {
+		t.Fatalf("NewLogPrivacy(plain) failed: %v", err)
+	}
+	if result := plain.ShortCode("abc123"); result != "abc123" {
+		t.Errorf("plain ShortCode = %s; expected abc123", result)
+	}
+
+	hashed, err := NewLogPrivacy(LogShortCodesHashed, key)
+	if err != nil {
+		t.Fatalf("NewLogPrivacy(hashed) failed: %v", err)
+	}
+	other, _ := NewLogPrivacy(LogShortCodesHashed, key)
+
+	result := hashed.ShortCode("abc123")
+	if strings.Contains(result, "abc123") || len(result) != len("h:")+hashedCodeLength {
+		t.Errorf("hashed ShortCode = %s; expected an opaque hash", result)
+	}
+	if other.ShortCode("abc123") != result {
+		t.Error("instances sharing a key must log the same hash")
+	}
+	if hashed.ShortCode("abc124") == result {
+		t.Error("different codes must log different hashes")
+	}
+
+	if _, err := NewLogPrivacy(LogShortCodesHashed, "short"); err == nil {
+		t.Error("hashed mode with a short key should fail")
+	}
+	if _, err := NewLogPrivacy("masked", key); err == nil {
+		t.Error("unknown mode should fail")
+	}
+}
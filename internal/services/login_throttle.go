@@ -0,0 +1,150 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/alexnthnz/url-shortener/internal/models"
+	"github.com/alexnthnz/url-shortener/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// Credentials guarded by the login throttle; each is an account of its own
+const (
+	CredentialAdmin = "admin"
+	CredentialQuick = "quick"
+)
+
+// LockoutScopeIP is the scope of a lockout, which only ever covers one client IP
+const LockoutScopeIP = "ip"
+
+// LoginThrottleConfig bounds failed attempts. A client IP reaching IPMaxFailures within the
+// window is locked out for Lockout, doubling with every further failure up to MaxLockout.
+// Reaching AccountMaxFailures across all IPs raises an alert but locks nobody out, since
+// that would let anyone lock the operator out. A zero limit turns that check off.
+type LoginThrottleConfig struct {
+	IPMaxFailures      int
+	AccountMaxFailures int
+	Window             time.Duration
+	Lockout            time.Duration
+	MaxLockout         time.Duration
+}
+
+// LoginThrottle slows down guessing of bearer tokens. Failures are counted in Redis per
+// client IP of a credential and per credential, so every instance enforces the same
+// lockouts. Lockouts and guessing spread over many IPs are announced to instance-wide
+// webhook subscriptions, the closest thing to the owner of an operator's token.
+type LoginThrottle struct {
+	cache    repository.Cache
+	cfg      LoginThrottleConfig
+	webhooks *WebhookService
+	logger   *logrus.Logger
+}
+
+func NewLoginThrottle(cache repository.Cache, cfg LoginThrottleConfig, webhooks *WebhookService, logger *logrus.Logger) *LoginThrottle {
+	return &LoginThrottle{cache: cache, cfg: cfg, webhooks: webhooks, logger: logger}
+}
+
+// Locked returns how long attempts on a credential from ip are still refused, 0 when
+// they are allowed
+func (t *LoginThrottle) Locked(ctx context.Context, credential, ip string) (time.Duration, error) {
+	remaining, err := t.cache.TTL(ctx, t.key("login_lock", credential, ip))
+	if err != nil || remaining < 0 {
+		return 0, err
+	}
+	return remaining, nil
+}
+
+// Failed counts a failed attempt, locking out the IP once its limit is reached and
+// alerting once the failures of all IPs reach theirs
+func (t *LoginThrottle) Failed(ctx context.Context, credential, ip string) error {
+	if t.cfg.IPMaxFailures > 0 {
+		failures, _, err := t.cache.IncrWindow(ctx, t.key("login_failures", credential, ip), t.cfg.Window)
+		if err != nil {
+			return err
+		}
+		if lockout := loginLockout(failures, t.cfg.IPMaxFailures, t.cfg.Lockout, t.cfg.MaxLockout); lockout > 0 {
+			if err := t.cache.SetWithTTL(ctx, t.key("login_lock", credential, ip), "1", lockout); err != nil {
+				return err
+			}
+			t.announceLockout(credential, ip, failures, lockout)
+		}
+	}
+
+	if t.cfg.AccountMaxFailures > 0 {
+		failures, _, err := t.cache.IncrWindow(ctx, t.key("login_failures", credential, ""), t.cfg.Window)
+		if err != nil {
+			return err
+		}
+		// Only the failure reaching the limit alerts, so there is one alert per window
+		if failures == int64(t.cfg.AccountMaxFailures) {
+			t.announceFailures(credential, failures)
+		}
+	}
+	return nil
+}
+
+// Succeeded clears the failures of the IP, so a user who mistyped a token starts over
+func (t *LoginThrottle) Succeeded(ctx context.Context, credential, ip string) error {
+	if t.cfg.IPMaxFailures <= 0 {
+		return nil
+	}
+	return t.cache.Delete(ctx, t.key("login_failures", credential, ip))
+}
+
+func (t *LoginThrottle) announceLockout(credential, ip string, failures int64, lockout time.Duration) {
+	now := time.Now().UTC()
+	t.logger.Warnf("Locked out %s login attempts from %s for %s after %d failures", credential, ip, lockout, failures)
+	if t.webhooks == nil {
+		return
+	}
+	t.webhooks.NotifyLoginLockout(models.WebhookLoginLockoutEvent{
+		Event:         "login.locked",
+		SchemaVersion: EventSchemaVersion("login.locked"),
+		Credential:    credential,
+		Scope:         LockoutScopeIP,
+		IPAddress:     ip,
+		Failures:      failures,
+		LockedUntil:   now.Add(lockout),
+		OccurredAt:    now,
+	})
+}
+
+func (t *LoginThrottle) announceFailures(credential string, failures int64) {
+	now := time.Now().UTC()
+	t.logger.Warnf("%d failed %s login attempts within %s", failures, credential, t.cfg.Window)
+	if t.webhooks == nil {
+		return
+	}
+	t.webhooks.NotifyLoginFailures(models.WebhookLoginFailuresEvent{
+		Event:         "login.failures",
+		SchemaVersion: EventSchemaVersion("login.failures"),
+		Credential:    credential,
+		Failures:      failures,
+		WindowSeconds: int64(t.cfg.Window.Seconds()),
+		OccurredAt:    now,
+	})
+}
+
+func (t *LoginThrottle) key(prefix, credential, ip string) string {
+	if ip == "" {
+		return prefix + ":" + credential
+	}
+	return prefix + ":" + credential + ":ip:" + ip
+}
+
+// loginLockout returns the lockout after a number of failures: none below the limit, then
+// base doubling with every failure past it, capped at max
+func loginLockout(failures int64, limit int, base, max time.Duration) time.Duration {
+	if failures < int64(limit) {
+		return 0
+	}
+	lockout := base
+	for i := int64(limit); i < failures && lockout < max; i++ {
+		lockout *= 2
+	}
+	if lockout > max {
+		lockout = max
+	}
+	return lockout
+}
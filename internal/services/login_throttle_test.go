@@ -0,0 +1,63 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alexnthnz/url-shortener/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+func TestLoginLockout(t *testing.T) {
+	testCases := []struct {
+		failures int64
+		expected time.Duration
+	}{
+		{1, 0},
+		{4, 0},
+		{5, time.Minute},
+		{6, 2 * time.Minute},
+		{8, 8 * time.Minute},
+		{11, time.Hour},
+		{1000, time.Hour},
+	}
+
+	for _, tc := range testCases {
+		if lockout := loginLockout(tc.failures, 5, time.Minute, time.Hour); lockout != tc.expected {
+			t.Errorf("loginLockout(%d) = %s; expected %s", tc.failures, lockout, tc.expected)
+		}
+	}
+}
+
+func TestLoginThrottleLocksOutIPsOnly(t *testing.T) {
+	ctx := context.Background()
+	throttle := NewLoginThrottle(repository.NewMemoryCache(0), LoginThrottleConfig{
+		IPMaxFailures:      3,
+		AccountMaxFailures: 4,
+		Window:             time.Minute,
+		Lockout:            time.Minute,
+		MaxLockout:         time.Hour,
+	}, nil, logrus.New())
+
+	// Guesses spread over many IPs reach the account limit without locking anyone out
+	for i := 0; i < 6; i++ {
+		if err := throttle.Failed(ctx, CredentialAdmin, fmt.Sprintf("203.0.113.%d", i)); err != nil {
+			t.Fatalf("Failed() returned error: %v", err)
+		}
+	}
+	if locked, _ := throttle.Locked(ctx, CredentialAdmin, "198.51.100.1"); locked != 0 {
+		t.Errorf("Locked() = %s for an IP without failures; the account limit should only alert", locked)
+	}
+
+	for i := 0; i < 3; i++ {
+		throttle.Failed(ctx, CredentialAdmin, "198.51.100.2")
+	}
+	if locked, _ := throttle.Locked(ctx, CredentialAdmin, "198.51.100.2"); locked <= 0 {
+		t.Error("Locked() = 0 for an IP that reached its limit")
+	}
+	if locked, _ := throttle.Locked(ctx, CredentialQuick, "198.51.100.2"); locked != 0 {
+		t.Errorf("Locked() = %s for another credential", locked)
+	}
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/alexnthnz/url-shortener/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	readOnlyKey             = "maintenance:read_only"
+	maintenanceRefreshEvery = 5 * time.Second
+)
+
+// MaintenanceService tracks the instance-wide read-only switch. The runtime flag lives
+// in Redis so toggling it on one instance applies to all of them within a few seconds.
+type MaintenanceService struct {
+	cache      repository.Cache
+	forced     bool
+	retryAfter time.Duration
+	logger     *logrus.Logger
+	readOnly   atomic.Bool
+}
+
+func NewMaintenanceService(cache repository.Cache, forced bool, retryAfter time.Duration, logger *logrus.Logger) *MaintenanceService {
+	service := &MaintenanceService{
+		cache:      cache,
+		forced:     forced,
+		retryAfter: retryAfter,
+		logger:     logger,
+	}
+
+	service.refresh()
+
+	// Start flag polling
+	go service.poll()
+
+	return service
+}
+
+// ReadOnly reports whether writes are currently rejected
+func (s *MaintenanceService) ReadOnly() bool {
+	return s.forced || s.readOnly.Load()
+}
+
+// RetryAfter returns the delay clients are asked to wait before retrying writes
+func (s *MaintenanceService) RetryAfter() time.Duration {
+	return s.retryAfter
+}
+
+// SetReadOnly toggles the runtime read-only flag for all instances
+func (s *MaintenanceService) SetReadOnly(ctx context.Context, readOnly bool) error {
+	var err error
+	if readOnly {
+		err = s.cache.SetWithTTL(ctx, readOnlyKey, "1", 0)
+	} else {
+		err = s.cache.Delete(ctx, readOnlyKey)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update read-only flag: %w", err)
+	}
+
+	s.readOnly.Store(readOnly)
+	s.logger.Warnf("Read-only mode set to %t", readOnly)
+	return nil
+}
+
+// poll keeps the local flag in sync with Redis
+func (s *MaintenanceService) poll() {
+	ticker := time.NewTicker(maintenanceRefreshEvery)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.refresh()
+	}
+}
+
+// refresh reads the runtime flag, keeping the last known state if Redis is unavailable
+func (s *MaintenanceService) refresh() {
+	ctx := context.Background()
+	_, err := s.cache.Get(ctx, readOnlyKey)
+	switch {
+	case err == nil:
+		s.readOnly.Store(true)
+	case err == repository.ErrCacheMiss:
+		s.readOnly.Store(false)
+	default:
+		s.logger.Warnf("Failed to read read-only flag: %v", err)
+	}
+}
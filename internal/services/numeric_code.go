@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	apperrors "github.com/alexnthnz/url-shortener/internal/errors"
+)
+
+// maxNumericCodeLength bounds the digits accepted by /n/:digits; sequence values fit in 19
+// digits, plus an optional check digit
+const maxNumericCodeLength = 20
+
+// numericCode returns the digits-only code of a link, for media that cannot carry letters
+// such as SMS short numbers, phone keypads or NFC tags. It is the link's id from the code
+// sequence, followed by a Luhn check digit when checksum codes are enabled.
+func (s *URLService) numericCode(id int64) string {
+	digits := strconv.FormatInt(id, 10)
+	if s.checksumDigit {
+		digits += string(luhnDigit(digits))
+	}
+	return digits
+}
+
+// ResolveNumericCode returns the short code of the link with a numeric code. Malformed
+// codes, and codes failing their check digit, are "not found" like unknown ones.
+func (s *URLService) ResolveNumericCode(ctx context.Context, digits string) (string, error) {
+	if !IsNumericCode(digits) {
+		return "", apperrors.Errorf(apperrors.ErrNotFound, "URL not found")
+	}
+	if s.checksumDigit && luhnDigit(digits[:len(digits)-1]) != digits[len(digits)-1] {
+		return "", apperrors.Errorf(apperrors.ErrNotFound, "URL not found")
+	}
+
+	if shortCode, err := s.cache.Get(ctx, numericCacheKey(digits)); err == nil {
+		return shortCode, nil
+	}
+	shortCode, err := s.urlRepo.GetShortCodeByNumericCode(ctx, digits)
+	if err != nil {
+		return "", fmt.Errorf("failed to get URL: %w", err)
+	}
+	if shortCode == "" {
+		return "", apperrors.Errorf(apperrors.ErrNotFound, "URL not found")
+	}
+
+	if err := s.cache.Set(ctx, numericCacheKey(digits), shortCode); err != nil {
+		s.logger.Warnf("Failed to cache numeric code: %v", err)
+	}
+	return shortCode, nil
+}
+
+// IsNumericCode reports whether a code has the shape of a numeric code
+func IsNumericCode(code string) bool {
+	if code == "" || len(code) > maxNumericCodeLength || code[0] == '0' {
+		return false
+	}
+	for i := 0; i < len(code); i++ {
+		if code[i] < '0' || code[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// luhnDigit computes the Luhn check digit of a string of digits, which catches every
+// single-digit typo and most swaps of adjacent digits
+func luhnDigit(digits string) byte {
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		// Double every second digit, starting with the rightmost one
+		if (len(digits)-i)%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+// numericCacheKey is the cache key mapping a numeric code to its short code
+func numericCacheKey(digits string) string {
+	return "numeric:" + digits
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	apperrors "github.com/alexnthnz/url-shortener/internal/errors"
+	"github.com/alexnthnz/url-shortener/internal/models"
+	"github.com/alexnthnz/url-shortener/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// privacyBatchSize bounds the click events read or anonymized per query
+const privacyBatchSize = 1000
+
+// PrivacyService answers data subject access and erasure requests over click analytics
+type PrivacyService struct {
+	analyticsRepo repository.AnalyticsStore
+	mirror        repository.AnalyticsStore // optional, see AnalyticsService
+	logger        *logrus.Logger
+}
+
+func NewPrivacyService(analyticsRepo, mirror repository.AnalyticsStore, logger *logrus.Logger) *PrivacyService {
+	return &PrivacyService{
+		analyticsRepo: analyticsRepo,
+		mirror:        mirror,
+		logger:        logger,
+	}
+}
+
+// ValidateSubject checks that exactly one well-formed identifier is given
+func ValidateSubject(subject models.DataSubject) error {
+	switch {
+	case subject.IPAddress != "" && subject.VisitorID != "":
+		return apperrors.Errorf(apperrors.ErrInvalid, "invalid subject: give either an IP address or a visitor id, not both")
+	case subject.IPAddress != "":
+		if net.ParseIP(subject.IPAddress) == nil {
+			return apperrors.Errorf(apperrors.ErrInvalid, "invalid subject: malformed IP address")
+		}
+	case subject.VisitorID != "":
+		if len(subject.VisitorID) != 32 {
+			return apperrors.Errorf(apperrors.ErrInvalid, "invalid subject: malformed visitor id")
+		}
+	default:
+		return apperrors.Errorf(apperrors.ErrInvalid, "invalid subject: an IP address or visitor id is required")
+	}
+	return nil
+}
+
+// BuildReport compiles every stored click event referencing the subject
+func (s *PrivacyService) BuildReport(ctx context.Context, subject models.DataSubject) (*models.DataSubjectReport, error) {
+	if err := ValidateSubject(subject); err != nil {
+		return nil, err
+	}
+
+	report := &models.DataSubjectReport{
+		Subject:     subject,
+		GeneratedAt: time.Now().UTC(),
+		Clicks:      []*models.Analytics{},
+	}
+
+	var afterID int64
+	for {
+		clicks, err := s.analyticsRepo.ListBySubject(ctx, subject, afterID, privacyBatchSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list subject clicks: %w", err)
+		}
+		report.Clicks = append(report.Clicks, clicks...)
+		if len(clicks) < privacyBatchSize {
+			break
+		}
+		afterID = clicks[len(clicks)-1].ID
+	}
+
+	report.ClickCount = len(report.Clicks)
+	return report, nil
+}
+
+// Erase anonymizes every click event referencing the subject, in the primary database and
+// in the analytics mirror. Clicks are kept without personal data so link statistics stay
+// correct; aggregates are computed from these rows, so nothing else holds the data.
+func (s *PrivacyService) Erase(ctx context.Context, subject models.DataSubject) (*models.ErasureResult, error) {
+	if err := ValidateSubject(subject); err != nil {
+		return nil, err
+	}
+
+	result := &models.ErasureResult{Subject: subject}
+	for {
+		// Anonymized rows stop matching the subject, so always read from the start
+		clicks, err := s.analyticsRepo.ListBySubject(ctx, subject, 0, privacyBatchSize)
+		if err != nil {
+			return result, fmt.Errorf("failed to list subject clicks: %w", err)
+		}
+		if len(clicks) == 0 {
+			break
+		}
+
+		ids := make([]int64, len(clicks))
+		for i, click := range clicks {
+			ids[i] = click.ID
+		}
+
+		// Mirror first: if it fails the primary rows still match and a retry finds them
+		if s.mirror != nil {
+			anonymized, err := s.mirror.AnonymizeByIDs(ctx, ids)
+			if err != nil {
+				return result, fmt.Errorf("failed to anonymize mirror clicks: %w", err)
+			}
+			result.Mirror += anonymized
+		}
+
+		anonymized, err := s.analyticsRepo.AnonymizeByIDs(ctx, ids)
+		if err != nil {
+			return result, fmt.Errorf("failed to anonymize clicks: %w", err)
+		}
+		result.Anonymized += anonymized
+	}
+
+	s.logger.Infof("Erased personal data of %d click(s) for a data subject request", result.Anonymized)
+	return result, nil
+}
@@ -0,0 +1,36 @@
+package services
+
+import "strings"
+
+// QRScanQuery is appended to short URLs encoded in QR codes, so that scans of printed
+// codes can be told apart from clicks on the same link
+const QRScanQuery = "qr=1"
+
+// QRScanURL returns the URL to encode in the QR code of a short URL
+func QRScanURL(shortURL string) string {
+	return shortURL + "?" + QRScanQuery
+}
+
+// StripQRScan removes the QR scan marker from the query string of a redirect, reporting
+// whether it was there. The other parameters keep their order, so links with path
+// passthrough forward the query exactly as it would have been without the marker.
+func StripQRScan(rawQuery string) (string, bool) {
+	if rawQuery == "" {
+		return rawQuery, false
+	}
+
+	params := strings.Split(rawQuery, "&")
+	kept := params[:0]
+	scanned := false
+	for _, param := range params {
+		if param == QRScanQuery {
+			scanned = true
+			continue
+		}
+		kept = append(kept, param)
+	}
+	if !scanned {
+		return rawQuery, false
+	}
+	return strings.Join(kept, "&"), true
+}
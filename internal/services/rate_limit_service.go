@@ -0,0 +1,214 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	apperrors "github.com/alexnthnz/url-shortener/internal/errors"
+	"github.com/alexnthnz/url-shortener/internal/models"
+	"github.com/alexnthnz/url-shortener/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// Rate limit tiers; routes without a tier of their own use the default
+const (
+	RateLimitTierDefault  = "default"
+	RateLimitTierShorten  = "shorten"
+	RateLimitTierRedirect = "redirect"
+	RateLimitTierStats    = "stats"
+)
+
+// rateLimitReloadInterval bounds how long other instances serve stale key overrides
+const rateLimitReloadInterval = 30 * time.Second
+
+// rateLimitRouteTiers maps route patterns to their tier
+var rateLimitRouteTiers = map[string]string{
+	"/api/v1/shorten":                    RateLimitTierShorten,
+	"/api/v1/quick":                      RateLimitTierShorten,
+	"/share":                             RateLimitTierShorten,
+	"/:short_code":                       RateLimitTierRedirect,
+	"/:short_code/*path":                 RateLimitTierRedirect,
+	"/api/v1/urls/:short_code/stats":     RateLimitTierStats,
+	"/api/v1/urls/:short_code/analytics": RateLimitTierStats,
+	"/api/v1/stats/domains":              RateLimitTierStats,
+}
+
+// RateLimitDecision is the outcome of counting one request
+type RateLimitDecision struct {
+	Allowed   bool
+	Limit     int // 0 when the request is not limited
+	Remaining int64
+	Reset     time.Time
+}
+
+// RateLimitService counts requests per client in fixed windows. Each route tier has its
+// own limit and bucket; requests signed with an API key are counted per key instead of
+// per IP, and a key may have an override that replaces the tier limits.
+type RateLimitService struct {
+	repo   *repository.RateLimitRepository
+	cache  repository.Cache
+	tiers  map[string]int
+	window time.Duration
+	logger *logrus.Logger
+
+	mu        sync.RWMutex
+	overrides map[string]int
+}
+
+func NewRateLimitService(repo *repository.RateLimitRepository, cache repository.Cache, tiers map[string]int, window time.Duration, logger *logrus.Logger) *RateLimitService {
+	service := &RateLimitService{
+		repo:      repo,
+		cache:     cache,
+		tiers:     tiers,
+		window:    window,
+		logger:    logger,
+		overrides: make(map[string]int),
+	}
+
+	if err := service.reload(); err != nil {
+		logger.Warnf("Failed to load rate limit overrides: %v", err)
+	}
+	go service.reloadLoop()
+
+	return service
+}
+
+// Window returns the length of a rate limit window
+func (s *RateLimitService) Window() time.Duration {
+	return s.window
+}
+
+// RouteTier returns the tier of a route pattern
+func RouteTier(fullPath string) string {
+	if tier, ok := rateLimitRouteTiers[fullPath]; ok {
+		return tier
+	}
+	return RateLimitTierDefault
+}
+
+// Allow counts a request in the tier's bucket for the API key, or for the client IP when
+// the request was not signed
+func (s *RateLimitService) Allow(ctx context.Context, tier, keyID, clientIP string) (RateLimitDecision, error) {
+	limit := s.limitFor(tier, keyID)
+	if limit <= 0 {
+		return RateLimitDecision{Allowed: true}, nil
+	}
+
+	key := fmt.Sprintf("rate_limit:%s:ip:%s", tier, clientIP)
+	if keyID != "" {
+		key = fmt.Sprintf("rate_limit:%s:key:%s", tier, keyID)
+	}
+
+	count, resetIn, err := s.cache.IncrWindow(ctx, key, s.window)
+	if err != nil {
+		return RateLimitDecision{}, err
+	}
+
+	remaining := int64(limit) - count
+	if remaining < 0 {
+		remaining = 0
+	}
+	return RateLimitDecision{
+		Allowed:   count <= int64(limit),
+		Limit:     limit,
+		Remaining: remaining,
+		Reset:     time.Now().Add(resetIn),
+	}, nil
+}
+
+// limitFor returns a key's override, or the tier limit
+func (s *RateLimitService) limitFor(tier, keyID string) int {
+	if keyID != "" {
+		s.mu.RLock()
+		override, ok := s.overrides[keyID]
+		s.mu.RUnlock()
+		if ok {
+			return override
+		}
+	}
+	if limit, ok := s.tiers[tier]; ok {
+		return limit
+	}
+	return s.tiers[RateLimitTierDefault]
+}
+
+// Tiers returns the configured limit of every tier
+func (s *RateLimitService) Tiers() map[string]int {
+	return s.tiers
+}
+
+// ListOverrides returns the per-key overrides
+func (s *RateLimitService) ListOverrides() ([]*models.RateLimitOverride, error) {
+	overrides, err := s.repo.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list rate limit overrides: %w", err)
+	}
+	return overrides, nil
+}
+
+// SetOverride sets the limit of an API key across all tiers
+func (s *RateLimitService) SetOverride(keyID string, requestsPerWindow int) (*models.RateLimitOverride, error) {
+	if keyID == "" || len(keyID) > 100 {
+		return nil, apperrors.Errorf(apperrors.ErrInvalid, "invalid key id")
+	}
+	if requestsPerWindow <= 0 {
+		return nil, apperrors.Errorf(apperrors.ErrInvalid, "invalid limit")
+	}
+
+	override := &models.RateLimitOverride{KeyID: keyID, RequestsPerWindow: requestsPerWindow}
+	if err := s.repo.Upsert(override); err != nil {
+		return nil, fmt.Errorf("failed to save rate limit override: %w", err)
+	}
+
+	s.mu.Lock()
+	s.overrides[keyID] = requestsPerWindow
+	s.mu.Unlock()
+	return override, nil
+}
+
+// DeleteOverride returns an API key to the tier limits
+func (s *RateLimitService) DeleteOverride(keyID string) error {
+	deleted, err := s.repo.Delete(keyID)
+	if err != nil {
+		return fmt.Errorf("failed to delete rate limit override: %w", err)
+	}
+	if !deleted {
+		return apperrors.Errorf(apperrors.ErrNotFound, "rate limit override not found")
+	}
+
+	s.mu.Lock()
+	delete(s.overrides, keyID)
+	s.mu.Unlock()
+	return nil
+}
+
+// reloadLoop picks up overrides changed through other instances
+func (s *RateLimitService) reloadLoop() {
+	ticker := time.NewTicker(rateLimitReloadInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := s.reload(); err != nil {
+			s.logger.Warnf("Failed to reload rate limit overrides: %v", err)
+		}
+	}
+}
+
+func (s *RateLimitService) reload() error {
+	list, err := s.repo.List()
+	if err != nil {
+		return err
+	}
+
+	overrides := make(map[string]int, len(list))
+	for _, override := range list {
+		overrides[override.KeyID] = override.RequestsPerWindow
+	}
+
+	s.mu.Lock()
+	s.overrides = overrides
+	s.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,36 @@
+package services
+
+import "testing"
+
+func TestRateLimitFor(t *testing.T) {
+	service := &RateLimitService{
+		tiers: map[string]int{
+			RateLimitTierDefault:  100,
+			RateLimitTierShorten:  10,
+			RateLimitTierRedirect: 0,
+		},
+		overrides: map[string]int{"partner": 5000},
+	}
+
+	tests := []struct {
+		name     string
+		route    string
+		keyID    string
+		expected int
+	}{
+		{"shorten tier", "/api/v1/shorten", "", 10},
+		{"unlisted route uses default", "/api/v1/webhooks", "", 100},
+		{"tier missing from config uses default", "/api/v1/urls/:short_code/stats", "", 100},
+		{"disabled tier", "/:short_code", "", 0},
+		{"key without override", "/api/v1/shorten", "ci", 10},
+		{"key override replaces tier", "/api/v1/shorten", "partner", 5000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := service.limitFor(RouteTier(tt.route), tt.keyID); got != tt.expected {
+				t.Errorf("Expected limit %d, got %d", tt.expected, got)
+			}
+		})
+	}
+}
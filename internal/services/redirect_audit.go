@@ -0,0 +1,150 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/alexnthnz/url-shortener/internal/models"
+	"github.com/alexnthnz/url-shortener/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// redirectAuditKey is the Redis list holding the sampled decisions of all instances,
+// newest first
+const redirectAuditKey = "redirect_audit"
+
+// RedirectAuditService records a sample of redirect decisions in full, for debugging why
+// visitors end up where they do. Records are kept in a capped Redis list shared by all
+// instances; recording is asynchronous and failures only cost the record.
+type RedirectAuditService struct {
+	cache      repository.Cache
+	percent    float64 // share of redirects recorded, 0 disables auditing
+	maxEntries int
+	logger     *logrus.Logger
+}
+
+func NewRedirectAuditService(cache repository.Cache, percent float64, maxEntries int, logger *logrus.Logger) *RedirectAuditService {
+	return &RedirectAuditService{
+		cache:      cache,
+		percent:    percent,
+		maxEntries: maxEntries,
+		logger:     logger,
+	}
+}
+
+// Enabled reports whether any redirects are recorded
+func (s *RedirectAuditService) Enabled() bool {
+	return s.percent > 0 && s.maxEntries > 0
+}
+
+// Sample decides whether to record a redirect
+func (s *RedirectAuditService) Sample() bool {
+	return s.Enabled() && rand.Float64()*100 < s.percent
+}
+
+// Record stores a decision without blocking the redirect. The outcome is derived from
+// the response status when not set.
+func (s *RedirectAuditService) Record(ctx context.Context, audit *models.RedirectAudit) {
+	if audit.Outcome == "" {
+		audit.Outcome = redirectOutcome(audit.Status)
+	}
+
+	// The redirect has been answered by the time the audit is stored
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		encoded, err := json.Marshal(audit)
+		if err != nil {
+			s.logger.Warnf("Failed to encode redirect audit: %v", err)
+			return
+		}
+		if err := s.cache.PushCapped(ctx, redirectAuditKey, string(encoded), int64(s.maxEntries)); err != nil {
+			s.logger.Warnf("Failed to record redirect audit: %v", err)
+		}
+	}()
+}
+
+// List returns up to limit recorded decisions, newest first, optionally only those of
+// one link under any of its codes
+func (s *RedirectAuditService) List(ctx context.Context, shortCode string, limit int) ([]models.RedirectAudit, error) {
+	count := int64(limit)
+	if shortCode != "" {
+		// Filtering happens here, so the whole list is read
+		count = int64(s.maxEntries)
+	}
+	entries, err := s.cache.ListRange(ctx, redirectAuditKey, count)
+	if err != nil {
+		return nil, err
+	}
+
+	audits := make([]models.RedirectAudit, 0, min(len(entries), limit))
+	for _, entry := range entries {
+		var audit models.RedirectAudit
+		if err := json.Unmarshal([]byte(entry), &audit); err != nil {
+			continue
+		}
+		if shortCode != "" && audit.ShortCode != shortCode && audit.CanonicalCode != shortCode {
+			continue
+		}
+		audits = append(audits, audit)
+		if len(audits) == limit {
+			break
+		}
+	}
+	return audits, nil
+}
+
+// RedirectTrace times the stages of one redirect. A nil trace records nothing, so
+// redirects that are not sampled pay nothing for it.
+type RedirectTrace struct {
+	start  time.Time
+	last   time.Time
+	stages []models.AuditStage
+}
+
+func NewRedirectTrace() *RedirectTrace {
+	now := time.Now()
+	return &RedirectTrace{start: now, last: now}
+}
+
+// Stage ends a stage, timing it from the end of the previous one
+func (t *RedirectTrace) Stage(name string) {
+	if t == nil {
+		return
+	}
+	now := time.Now()
+	t.stages = append(t.stages, models.AuditStage{Name: name, Micros: now.Sub(t.last).Microseconds()})
+	t.last = now
+}
+
+// Finish fills in the stage timings of a decision
+func (t *RedirectTrace) Finish(audit *models.RedirectAudit) {
+	if t == nil {
+		return
+	}
+	audit.Stages = t.stages
+	audit.TotalMicros = time.Since(t.start).Microseconds()
+}
+
+// redirectOutcome names what a redirect did from its response status, using the
+// outcomes of redirect simulations
+func redirectOutcome(status int) string {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound:
+		return "redirect"
+	case http.StatusBadRequest:
+		return "invalid_path"
+	case http.StatusForbidden:
+		return "destination_refused"
+	case http.StatusNotFound:
+		return "not_found"
+	case http.StatusGone:
+		return "click_limit_reached"
+	case http.StatusUnavailableForLegalReasons:
+		return "disabled"
+	default:
+		return "error"
+	}
+}
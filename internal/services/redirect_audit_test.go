@@ -0,0 +1,45 @@
+package services
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/alexnthnz/url-shortener/internal/models"
+)
+
+func TestRedirectTrace(t *testing.T) {
+	trace := NewRedirectTrace()
+	trace.Stage("lookup")
+	trace.Stage("rules")
+
+	var audit models.RedirectAudit
+	trace.Finish(&audit)
+	if len(audit.Stages) != 2 || audit.Stages[0].Name != "lookup" || audit.Stages[1].Name != "rules" {
+		t.Errorf("Stages = %+v; expected lookup and rules", audit.Stages)
+	}
+
+	// Redirects that are not sampled have no trace
+	var unsampled *RedirectTrace
+	unsampled.Stage("lookup")
+	unsampled.Finish(&audit)
+}
+
+func TestRedirectOutcome(t *testing.T) {
+	testCases := []struct {
+		status   int
+		expected string
+	}{
+		{http.StatusMovedPermanently, "redirect"},
+		{http.StatusFound, "redirect"},
+		{http.StatusNotFound, "not_found"},
+		{http.StatusGone, "click_limit_reached"},
+		{http.StatusUnavailableForLegalReasons, "disabled"},
+		{http.StatusInternalServerError, "error"},
+	}
+
+	for _, tc := range testCases {
+		if result := redirectOutcome(tc.status); result != tc.expected {
+			t.Errorf("redirectOutcome(%d) = %q; expected %q", tc.status, result, tc.expected)
+		}
+	}
+}
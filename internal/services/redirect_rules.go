@@ -0,0 +1,368 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"strings"
+	"time"
+
+	apperrors "github.com/alexnthnz/url-shortener/internal/errors"
+	"github.com/alexnthnz/url-shortener/internal/models"
+)
+
+// maxRedirectRules bounds the rules of a link, which are evaluated on every redirect
+const maxRedirectRules = 20
+
+// Platforms redirect rules can match
+const (
+	PlatformIOS      = "ios"
+	PlatformAndroid  = "android"
+	PlatformWindows  = "windows"
+	PlatformMacOS    = "macos"
+	PlatformLinux    = "linux"
+	PlatformChromeOS = "chromeos"
+	PlatformOther    = "other"
+)
+
+// ruleDevices are the device types rules can match, as ParseUserAgent reports them
+var ruleDevices = map[string]bool{
+	DeviceDesktop: true,
+	DeviceMobile:  true,
+	DeviceTablet:  true,
+	DeviceBot:     true,
+	DeviceUnknown: true,
+}
+
+// rulePlatforms are the platforms rules can match
+var rulePlatforms = map[string]bool{
+	PlatformIOS:      true,
+	PlatformAndroid:  true,
+	PlatformWindows:  true,
+	PlatformMacOS:    true,
+	PlatformLinux:    true,
+	PlatformChromeOS: true,
+	PlatformOther:    true,
+}
+
+// osPlatforms maps the operating systems ParseUserAgent reports to platforms; iPads count
+// as iOS, like the App Store sees them. Anything else is "other".
+var osPlatforms = map[string]string{
+	"iOS":           PlatformIOS,
+	"iPadOS":        PlatformIOS,
+	"Android":       PlatformAndroid,
+	"Windows":       PlatformWindows,
+	"Windows Phone": PlatformWindows,
+	"macOS":         PlatformMacOS,
+	"Linux":         PlatformLinux,
+	"ChromeOS":      PlatformChromeOS,
+}
+
+// Visitor describes who follows a link, as redirect rules see it
+type Visitor struct {
+	Country  string // ISO 3166 code
+	Language string // primary language subtag
+	Device   string
+	Platform string // operating system family, such as ios or android
+	// Key places the visitor in A/B tests, so repeat visits see the same variant: the
+	// attribution visitor id when there is one, else a key derived from the IP address.
+	// Without either, as in simulations, it is the click id.
+	Key  string
+	Time time.Time
+}
+
+// NewVisitor derives the visitor of a click
+func NewVisitor(click ClickContext, key string, at time.Time) Visitor {
+	if key == "" {
+		key = click.ClickID
+	}
+	client := ParseUserAgent(click.UserAgent)
+	device := click.Device
+	if device == "" {
+		device = client.DeviceType
+	}
+	return Visitor{
+		Country:  click.Country,
+		Language: PreferredLanguage(click.AcceptLanguage),
+		Device:   device,
+		Platform: platformOf(client.OS),
+		Key:      key,
+		Time:     at,
+	}
+}
+
+// platformOf returns the platform of an operating system as ParseUserAgent reports it
+func platformOf(os string) string {
+	if platform, ok := osPlatforms[os]; ok {
+		return platform
+	}
+	return PlatformOther
+}
+
+// Redirect is where a link sends a visitor
+type Redirect struct {
+	Destination   string
+	CanonicalCode string
+	Rule          int // index of the redirect rule that fired, -1 for the link's own destination
+	Bucket        int // A/B bucket of the visitor, 0 to 99
+	// Page is the landing page the link serves instead of redirecting, if it has one
+	Page *models.LandingPage
+}
+
+// ResolveRedirect decides where a link sends a visitor. The steps run in order: the lookup,
+// which fails for unknown and disabled links, the link's landing page, which ends the
+// resolution, its redirect rules, path passthrough, which forwards the extra path and
+// query to the chosen destination, and its UTM parameters. Links without passthrough only
+// resolve when there is no extra path. trace, when not nil, times each step.
+func (s *URLService) ResolveRedirect(ctx context.Context, shortCode, extraPath, rawQuery string, visitor Visitor, trace *RedirectTrace) (*Redirect, error) {
+	originalURL, canonical, err := s.GetOriginalURL(ctx, shortCode)
+	trace.Stage("lookup")
+	if err != nil {
+		return nil, err
+	}
+	redirect := &Redirect{Destination: originalURL, CanonicalCode: canonical, Rule: -1, Bucket: visitorBucket(canonical, visitor.Key)}
+
+	redirect.Page, err = s.landingPage(ctx, canonical)
+	trace.Stage("landing_page")
+	if err != nil {
+		return nil, err
+	}
+	if redirect.Page != nil {
+		if strings.Trim(extraPath, "/") != "" {
+			return nil, apperrors.Errorf(apperrors.ErrNotFound, "URL not found")
+		}
+		return redirect, nil
+	}
+
+	rules, err := s.redirectRules(ctx, canonical)
+	if err != nil {
+		return nil, err
+	}
+	if i := matchRule(rules, visitor, redirect.Bucket); i >= 0 {
+		redirect.Destination, redirect.Rule = rules[i].Destination, i
+	}
+	trace.Stage("rules")
+
+	if extraPath != "" || rawQuery != "" {
+		passthrough, err := s.isPassthrough(ctx, canonical)
+		if err != nil {
+			return nil, err
+		}
+		if passthrough {
+			redirect.Destination, err = buildPassthroughURL(redirect.Destination, extraPath, rawQuery)
+			trace.Stage("passthrough")
+			if err != nil {
+				return nil, apperrors.Errorf(apperrors.ErrInvalid, "invalid passthrough path: %w", err)
+			}
+		} else {
+			trace.Stage("passthrough")
+			if strings.Trim(extraPath, "/") != "" {
+				return nil, apperrors.Errorf(apperrors.ErrNotFound, "URL not found")
+			}
+		}
+	}
+
+	utm, err := s.utmParams(ctx, canonical)
+	if err != nil {
+		return nil, err
+	}
+	redirect.Destination = appendUTMParams(redirect.Destination, utm)
+	trace.Stage("utm")
+	return redirect, nil
+}
+
+// GetRedirectRules returns the redirect rules of a link, in evaluation order
+func (s *URLService) GetRedirectRules(ctx context.Context, shortCode string) ([]models.RedirectRule, error) {
+	rules, found, err := s.urlRepo.GetRedirectRules(ctx, shortCode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get redirect rules: %w", err)
+	}
+	if !found {
+		return nil, apperrors.Errorf(apperrors.ErrNotFound, "URL not found")
+	}
+	if rules == nil {
+		rules = []models.RedirectRule{}
+	}
+	return rules, nil
+}
+
+// SetRedirectRules validates and replaces the redirect rules of a link; no rules removes
+// them. Destinations are normalized and screened like the link's own.
+func (s *URLService) SetRedirectRules(ctx context.Context, shortCode string, rules []models.RedirectRule) ([]models.RedirectRule, error) {
+	if len(rules) > maxRedirectRules {
+		return nil, apperrors.Errorf(apperrors.ErrInvalid, "invalid rules: a link has at most %d redirect rules", maxRedirectRules)
+	}
+
+	percent := 0
+	for i := range rules {
+		rule := &rules[i]
+		if err := validateRule(rule); err != nil {
+			return nil, apperrors.Errorf(apperrors.ErrInvalid, "invalid rules: rule %d: %w", i, err)
+		}
+		if err := s.validateURL(rule.Destination); err != nil {
+			return nil, apperrors.Errorf(apperrors.ErrInvalid, "invalid rules: rule %d: %w", i, err)
+		}
+		if err := validateTemplate(rule.Destination); err != nil {
+			return nil, apperrors.Errorf(apperrors.ErrInvalid, "invalid rules: rule %d: %w", i, err)
+		}
+		rule.Destination = normalizeURL(rule.Destination, s.normalize)
+		if err := s.validateLink(&models.LinkProposal{Action: ValidationRedirectRule, ShortCode: shortCode, Destination: rule.Destination}); err != nil {
+			var rejection *LinkRejection
+			if errors.As(err, &rejection) {
+				return nil, apperrors.Errorf(apperrors.ErrInvalid, "invalid rules: rule %d: %w", i, err)
+			}
+			return nil, err
+		}
+
+		percent += rule.Percent
+		if percent > 100 {
+			return nil, apperrors.Errorf(apperrors.ErrInvalid, "invalid rules: percentages add up to more than 100")
+		}
+	}
+
+	destinations := make([]string, len(rules))
+	for i, rule := range rules {
+		destinations[i] = rule.Destination
+	}
+	listed, threat, err := s.screenDestinations(ctx, destinations)
+	if err != nil {
+		return nil, apperrors.Errorf(apperrors.ErrInvalid, "invalid rules: %w", err)
+	}
+
+	found, err := s.urlRepo.SetRedirectRules(ctx, shortCode, rules)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update redirect rules: %w", err)
+	}
+	if !found {
+		return nil, apperrors.Errorf(apperrors.ErrNotFound, "URL not found")
+	}
+	s.flagThreat(ctx, shortCode, listed, threat)
+
+	if err := s.cache.Delete(ctx, redirectRulesCacheKey(shortCode)); err != nil {
+		s.logger.Warnf("Failed to invalidate redirect rules cache: %v", err)
+	}
+	if rules == nil {
+		rules = []models.RedirectRule{}
+	}
+	return rules, nil
+}
+
+// redirectRules returns the redirect rules of a link, cached next to its destination
+func (s *URLService) redirectRules(ctx context.Context, shortCode string) ([]models.RedirectRule, error) {
+	var rules []models.RedirectRule
+	if cached, err := s.cache.Get(ctx, redirectRulesCacheKey(shortCode)); err == nil {
+		if err := json.Unmarshal([]byte(cached), &rules); err == nil {
+			return rules, nil
+		}
+	}
+
+	rules, _, err := s.urlRepo.GetRedirectRules(ctx, shortCode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get redirect rules: %w", err)
+	}
+
+	encoded, _ := json.Marshal(rules)
+	if err := s.cache.Set(ctx, redirectRulesCacheKey(shortCode), string(encoded)); err != nil {
+		s.logger.Warnf("Failed to cache redirect rules: %v", err)
+	}
+	return rules, nil
+}
+
+// validateRule normalizes the conditions of a rule and checks them
+func validateRule(rule *models.RedirectRule) error {
+	for i, country := range rule.Countries {
+		country = strings.ToUpper(strings.TrimSpace(country))
+		if len(country) != 2 || country[0] < 'A' || country[0] > 'Z' || country[1] < 'A' || country[1] > 'Z' {
+			return fmt.Errorf("invalid country %q", rule.Countries[i])
+		}
+		rule.Countries[i] = country
+	}
+	for i, device := range rule.Devices {
+		device = strings.ToLower(strings.TrimSpace(device))
+		if !ruleDevices[device] {
+			return fmt.Errorf("invalid device %q", rule.Devices[i])
+		}
+		rule.Devices[i] = device
+	}
+	for i, platform := range rule.Platforms {
+		platform = strings.ToLower(strings.TrimSpace(platform))
+		if !rulePlatforms[platform] {
+			return fmt.Errorf("invalid platform %q", rule.Platforms[i])
+		}
+		rule.Platforms[i] = platform
+	}
+	for i, language := range rule.Languages {
+		language = strings.ToLower(strings.TrimSpace(language))
+		if !isLanguageSubtag(language) {
+			return fmt.Errorf("invalid language %q", rule.Languages[i])
+		}
+		rule.Languages[i] = language
+	}
+	if rule.After != nil && rule.Before != nil && !rule.After.Before(*rule.Before) {
+		return fmt.Errorf("after must be earlier than before")
+	}
+	if rule.Percent < 0 || rule.Percent > 100 {
+		return fmt.Errorf("percent must be between 1 and 100")
+	}
+
+	if len(rule.Countries) == 0 && len(rule.Devices) == 0 && len(rule.Platforms) == 0 && len(rule.Languages) == 0 &&
+		rule.After == nil && rule.Before == nil && rule.Percent == 0 {
+		return fmt.Errorf("a rule needs at least one condition")
+	}
+	if rule.Destination == "" {
+		return fmt.Errorf("destination is required")
+	}
+	return nil
+}
+
+// matchRule returns the index of the first rule whose conditions all hold for a visitor,
+// -1 when none does. bucket, between 0 and 99, places the visitor in the slices of the
+// percentage rules.
+func matchRule(rules []models.RedirectRule, visitor Visitor, bucket int) int {
+	offset := 0
+	for i, rule := range rules {
+		if rule.Percent > 0 {
+			inSlice := bucket >= offset && bucket < offset+rule.Percent
+			offset += rule.Percent
+			if !inSlice {
+				continue
+			}
+		}
+
+		if len(rule.Countries) > 0 && !slices.Contains(rule.Countries, visitor.Country) {
+			continue
+		}
+		if len(rule.Devices) > 0 && !slices.Contains(rule.Devices, visitor.Device) {
+			continue
+		}
+		if len(rule.Platforms) > 0 && !slices.Contains(rule.Platforms, visitor.Platform) {
+			continue
+		}
+		if len(rule.Languages) > 0 && !slices.Contains(rule.Languages, visitor.Language) {
+			continue
+		}
+		if rule.After != nil && visitor.Time.Before(*rule.After) {
+			continue
+		}
+		if rule.Before != nil && !visitor.Time.Before(*rule.Before) {
+			continue
+		}
+		return i
+	}
+	return -1
+}
+
+// visitorBucket places a visitor key in one of 100 buckets, independently per link
+func visitorBucket(shortCode, key string) int {
+	hash := fnv.New32a()
+	hash.Write([]byte(shortCode + "\x00" + key))
+	return int(hash.Sum32() % 100)
+}
+
+// redirectRulesCacheKey is the cache key of a link's redirect rules
+func redirectRulesCacheKey(shortCode string) string {
+	return "rules:" + shortCode
+}
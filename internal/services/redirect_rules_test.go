@@ -0,0 +1,176 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alexnthnz/url-shortener/internal/models"
+	"github.com/alexnthnz/url-shortener/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+func TestMatchRule(t *testing.T) {
+	launch := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	rules := []models.RedirectRule{
+		{Countries: []string{"DE", "AT"}, Devices: []string{DeviceMobile}, Destination: "https://example.com/de-app"},
+		{Languages: []string{"fr"}, Destination: "https://example.com/fr"},
+		{After: &launch, Percent: 30, Destination: "https://example.com/new"},
+		{Percent: 20, Destination: "https://example.com/variant-b"},
+		{Platforms: []string{PlatformIOS}, Destination: "https://apps.apple.com/app/id123"},
+		{Platforms: []string{PlatformAndroid}, Destination: "https://play.google.com/store/apps/details?id=com.example"},
+	}
+	before := launch.Add(-time.Hour)
+
+	testCases := []struct {
+		name     string
+		visitor  Visitor
+		bucket   int
+		expected int
+	}{
+		{"country and device", Visitor{Country: "AT", Device: DeviceMobile, Time: before}, 99, 0},
+		{"country without device", Visitor{Country: "DE", Device: DeviceDesktop, Time: before}, 99, -1},
+		{"language", Visitor{Country: "DE", Language: "fr", Time: before}, 99, 1},
+		{"first slice after launch", Visitor{Time: launch}, 0, 2},
+		{"first slice before launch", Visitor{Time: before}, 29, -1},
+		{"second slice", Visitor{Time: before}, 30, 3},
+		{"second slice end", Visitor{Time: launch}, 49, 3},
+		{"outside the slices", Visitor{Time: launch}, 50, -1},
+		{"ios", Visitor{Platform: PlatformIOS, Time: launch}, 50, 4},
+		{"android", Visitor{Platform: PlatformAndroid, Time: launch}, 50, 5},
+		{"desktop platform", Visitor{Platform: PlatformMacOS, Time: launch}, 50, -1},
+	}
+
+	for _, tc := range testCases {
+		if result := matchRule(rules, tc.visitor, tc.bucket); result != tc.expected {
+			t.Errorf("%s: matchRule() = %d; expected %d", tc.name, result, tc.expected)
+		}
+	}
+}
+
+func TestValidateRule(t *testing.T) {
+	rule := models.RedirectRule{
+		Countries:   []string{" de"},
+		Devices:     []string{"Mobile"},
+		Platforms:   []string{"iOS"},
+		Languages:   []string{"FR"},
+		Destination: "https://example.com/",
+	}
+	if err := validateRule(&rule); err != nil {
+		t.Fatalf("validateRule() returned error: %v", err)
+	}
+	if rule.Countries[0] != "DE" || rule.Devices[0] != "mobile" || rule.Platforms[0] != "ios" || rule.Languages[0] != "fr" {
+		t.Errorf("validateRule() did not normalize the conditions: %+v", rule)
+	}
+
+	later := time.Now()
+	earlier := later.Add(-time.Hour)
+	invalid := []models.RedirectRule{
+		{Destination: "https://example.com/"},
+		{Countries: []string{"DEU"}, Destination: "https://example.com/"},
+		{Devices: []string{"watch"}, Destination: "https://example.com/"},
+		{Platforms: []string{"symbian"}, Destination: "https://example.com/"},
+		{Languages: []string{"english"}, Destination: "https://example.com/"},
+		{After: &later, Before: &earlier, Destination: "https://example.com/"},
+		{Percent: 101, Destination: "https://example.com/"},
+		{Countries: []string{"DE"}},
+	}
+	for _, rule := range invalid {
+		if err := validateRule(&rule); err == nil {
+			t.Errorf("validateRule(%+v) should be invalid, but passed", rule)
+		}
+	}
+}
+
+func TestVisitorBucket(t *testing.T) {
+	if visitorBucket("abc", "visitor") != visitorBucket("abc", "visitor") {
+		t.Error("visitorBucket() should be stable")
+	}
+	for _, key := range []string{"", "a", "visitor", "9f2c0e"} {
+		if bucket := visitorBucket("abc", key); bucket < 0 || bucket > 99 {
+			t.Errorf("visitorBucket(%q) = %d; expected 0-99", key, bucket)
+		}
+	}
+}
+
+func TestNewVisitorPlatform(t *testing.T) {
+	testCases := []struct {
+		userAgent string
+		expected  string
+	}{
+		{"Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 Mobile/15E148", PlatformIOS},
+		{"Mozilla/5.0 (iPad; CPU OS 17_0 like Mac OS X) AppleWebKit/605.1.15 Mobile/15E148", PlatformIOS},
+		{"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 Chrome/120.0 Mobile Safari/537.36", PlatformAndroid},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 Chrome/120.0 Safari/537.36", PlatformWindows},
+		{"Mozilla/5.0 (X11; CrOS x86_64 14541.0.0) AppleWebKit/537.36 Chrome/120.0 Safari/537.36", PlatformChromeOS},
+		{"curl/8.4.0", PlatformOther},
+		{"", PlatformOther},
+	}
+
+	for _, tc := range testCases {
+		visitor := NewVisitor(ClickContext{UserAgent: tc.userAgent}, "key", time.Now())
+		if visitor.Platform != tc.expected {
+			t.Errorf("NewVisitor(%q).Platform = %q; expected %q", tc.userAgent, visitor.Platform, tc.expected)
+		}
+	}
+}
+
+// rulesRecorder is a URL store that records redirect rule changes
+type rulesRecorder struct {
+	repository.URLStore
+	updated int
+}
+
+func (r *rulesRecorder) SetRedirectRules(ctx context.Context, shortCode string, rules []models.RedirectRule) (bool, error) {
+	r.updated++
+	return true, nil
+}
+
+func TestSetRedirectRulesScreening(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"matches": [{"threatType": "SOCIAL_ENGINEERING", "threat": {"url": "https://phish.example/login"}}]}`))
+	}))
+	defer server.Close()
+
+	testCases := []struct {
+		name        string
+		action      string
+		destination string
+		updated     bool
+	}{
+		{"clean destination", SafeBrowsingReject, "https://example.com/", true},
+		{"listed destination rejected", SafeBrowsingReject, "https://phish.example/login", false},
+		{"listed destination flagged", SafeBrowsingFlag, "https://phish.example/login", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := &rulesRecorder{}
+			service := &URLService{
+				urlRepo: store,
+				cache:   repository.NewMemoryCache(0),
+				safeBrowsing: &SafeBrowsingService{
+					cfg:    SafeBrowsingConfig{APIKey: "test-key", Endpoint: server.URL, Action: tc.action, CacheTTL: time.Minute},
+					cache:  repository.NewMemoryCache(0),
+					client: server.Client(),
+					logger: logrus.New(),
+				},
+				logger: logrus.New(),
+			}
+
+			rules := []models.RedirectRule{
+				{Countries: []string{"DE"}, Destination: "https://example.com/de"},
+				{Devices: []string{"mobile"}, Destination: tc.destination},
+			}
+			_, err := service.SetRedirectRules(context.Background(), "abc123", rules)
+			if tc.updated != (err == nil) {
+				t.Fatalf("SetRedirectRules(%q) = %v; expected updated %v", tc.destination, err, tc.updated)
+			}
+			if tc.updated != (store.updated == 1) {
+				t.Errorf("rule changes = %d; expected updated %v", store.updated, tc.updated)
+			}
+		})
+	}
+}
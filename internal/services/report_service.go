@@ -0,0 +1,350 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	apperrors "github.com/alexnthnz/url-shortener/internal/errors"
+	"github.com/alexnthnz/url-shortener/internal/models"
+	"github.com/alexnthnz/url-shortener/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// Report periods
+const (
+	ReportWeekly  = "weekly"
+	ReportMonthly = "monthly"
+)
+
+const (
+	// maxReportLinks bounds the links of a campaign a report covers
+	maxReportLinks = 200
+	// reportCheckInterval is how often instances look for a period due a report
+	reportCheckInterval = time.Hour
+	// reportChartWidth and reportChartHeight size the daily clicks chart
+	reportChartWidth  = 640
+	reportChartHeight = 160
+)
+
+var reportColorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// ReportConfig configures campaign reports and the branding they are rendered with
+type ReportConfig struct {
+	Periods    []string // periods generated on schedule; none disables the schedule
+	BrandName  string
+	BrandColor string // #rrggbb
+	LogoURL    string
+}
+
+// ReportService summarizes campaigns, the links sharing a utm_campaign, as HTML reports
+// with a server-side rendered chart. Reports for the configured periods are generated
+// by one instance once a period ends and announced with a report.ready webhook event;
+// any report can also be generated on demand.
+type ReportService struct {
+	cfg           ReportConfig
+	reportRepo    *repository.ReportRepository
+	urlRepo       repository.URLStore
+	analyticsRepo repository.AnalyticsStore
+	webhooks      *WebhookService
+	cache         repository.Cache
+	logger        *logrus.Logger
+}
+
+func NewReportService(cfg ReportConfig, reportRepo *repository.ReportRepository, urlRepo repository.URLStore, analyticsRepo repository.AnalyticsStore, webhooks *WebhookService, cache repository.Cache, logger *logrus.Logger) (*ReportService, error) {
+	for _, period := range cfg.Periods {
+		if period != ReportWeekly && period != ReportMonthly {
+			return nil, fmt.Errorf("invalid report period %q: must be %q or %q", period, ReportWeekly, ReportMonthly)
+		}
+	}
+	if !reportColorPattern.MatchString(cfg.BrandColor) {
+		return nil, fmt.Errorf("invalid brand color %q: must be #rrggbb", cfg.BrandColor)
+	}
+	if cfg.LogoURL != "" {
+		if logo, err := url.Parse(cfg.LogoURL); err != nil || (logo.Scheme != "http" && logo.Scheme != "https") || logo.Host == "" {
+			return nil, fmt.Errorf("invalid logo URL %q", cfg.LogoURL)
+		}
+	}
+
+	service := &ReportService{
+		cfg:           cfg,
+		reportRepo:    reportRepo,
+		urlRepo:       urlRepo,
+		analyticsRepo: analyticsRepo,
+		webhooks:      webhooks,
+		cache:         cache,
+		logger:        logger,
+	}
+
+	if len(cfg.Periods) > 0 {
+		go service.schedule()
+	}
+
+	return service, nil
+}
+
+// Generate summarizes a campaign over the period containing start, replacing an earlier
+// report of the same period
+func (s *ReportService) Generate(ctx context.Context, campaign, period string, start time.Time) (*models.Report, error) {
+	from, to, err := reportPeriod(period, start)
+	if err != nil {
+		return nil, err
+	}
+	if !from.Before(time.Now()) {
+		return nil, apperrors.Errorf(apperrors.ErrInvalid, "invalid report: the period has not started yet")
+	}
+
+	links, err := s.urlRepo.GetCampaignLinks(ctx, campaign, maxReportLinks)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get campaign links: %w", err)
+	}
+	if len(links) == 0 {
+		return nil, apperrors.Errorf(apperrors.ErrNotFound, "campaign not found")
+	}
+
+	shortCodes := make([]string, len(links))
+	rows := make([]reportRow, len(links))
+	for i, link := range links {
+		shortCodes[i] = link.ShortCode
+		summary, err := s.analyticsRepo.GetClickSummary(ctx, link.ShortCode, from, to, false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get click summary: %w", err)
+		}
+		rows[i] = reportRow{link: link, summary: summary}
+	}
+	total, err := s.analyticsRepo.GetLinksClickSummary(ctx, shortCodes, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get campaign summary: %w", err)
+	}
+	buckets, err := s.analyticsRepo.GetLinksClickBuckets(ctx, shortCodes, AnalyticsIntervalDay, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get daily clicks: %w", err)
+	}
+
+	report := &models.Report{
+		Campaign:       campaign,
+		Period:         period,
+		PeriodStart:    from,
+		PeriodEnd:      to,
+		Links:          len(links),
+		Clicks:         total.Clicks,
+		UniqueVisitors: total.Visitors,
+	}
+	report.HTML = s.render(report, total, dailySeries(from, to, buckets), rows)
+	if err := s.reportRepo.Save(report); err != nil {
+		return nil, fmt.Errorf("failed to save report: %w", err)
+	}
+	return report, nil
+}
+
+// GenerateLast summarizes a campaign over the last period that ended before now
+func (s *ReportService) GenerateLast(ctx context.Context, campaign, period string, now time.Time) (*models.Report, error) {
+	current, _, err := reportPeriod(period, now)
+	if err != nil {
+		return nil, err
+	}
+	return s.Generate(ctx, campaign, period, current.Add(-time.Nanosecond))
+}
+
+// List returns the latest reports, of one campaign or of all when campaign is empty
+func (s *ReportService) List(campaign string, limit int) ([]*models.Report, error) {
+	reports, err := s.reportRepo.List(campaign, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reports: %w", err)
+	}
+	if reports == nil {
+		reports = []*models.Report{}
+	}
+	return reports, nil
+}
+
+// Get returns a report with its HTML
+func (s *ReportService) Get(id int64) (*models.Report, error) {
+	report, err := s.reportRepo.Get(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get report: %w", err)
+	}
+	if report == nil {
+		return nil, apperrors.Errorf(apperrors.ErrNotFound, "report not found")
+	}
+	return report, nil
+}
+
+// GenerateAll reports on every campaign for the last period that ended before now
+func (s *ReportService) GenerateAll(period string, now time.Time) error {
+	ctx := context.Background()
+	campaigns, err := s.urlRepo.ListCampaigns(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list campaigns: %w", err)
+	}
+
+	for _, campaign := range campaigns {
+		report, err := s.GenerateLast(ctx, campaign, period, now)
+		if err != nil {
+			s.logger.Errorf("Failed to generate %s report of campaign %s: %v", period, campaign, err)
+			continue
+		}
+		s.webhooks.NotifyReport(models.WebhookReportEvent{
+			Event:         "report.ready",
+			SchemaVersion: EventSchemaVersion("report.ready"),
+			ReportID:      report.ID,
+			Campaign:      report.Campaign,
+			Period:        report.Period,
+			PeriodStart:   report.PeriodStart,
+			PeriodEnd:     report.PeriodEnd,
+			Clicks:        report.Clicks,
+			OccurredAt:    now,
+		})
+	}
+	return nil
+}
+
+// schedule generates the reports of each configured period once it ends, on one instance
+func (s *ReportService) schedule() {
+	ctx := context.Background()
+	ticker := time.NewTicker(reportCheckInterval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		now = now.UTC()
+		for _, period := range s.cfg.Periods {
+			current, next, _ := reportPeriod(period, now)
+			lockKey := fmt.Sprintf("lock:reports:%s:%s", period, current.Format(usageDateLayout))
+			acquired, err := s.cache.SetNX(ctx, lockKey, "1", next.Sub(current))
+			if err != nil {
+				s.logger.Warnf("Failed to acquire report lock: %v", err)
+				continue
+			}
+			if !acquired {
+				continue
+			}
+
+			if err := s.GenerateAll(period, now); err != nil {
+				s.logger.Errorf("Failed to generate %s reports: %v", period, err)
+			}
+		}
+	}
+}
+
+// reportPeriod returns the UTC week, starting on Monday, or month containing t
+func reportPeriod(period string, t time.Time) (time.Time, time.Time, error) {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch period {
+	case ReportWeekly:
+		start := day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+		return start, start.AddDate(0, 0, 7), nil
+	case ReportMonthly:
+		start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 1, 0), nil
+	default:
+		return time.Time{}, time.Time{}, apperrors.Errorf(apperrors.ErrInvalid, "invalid report: period must be %q or %q", ReportWeekly, ReportMonthly)
+	}
+}
+
+// dailySeries lists the clicks of every day in [from, to), filling in days without clicks
+func dailySeries(from, to time.Time, buckets []models.ClickBucket) []models.ClickBucket {
+	byDay := make(map[int64]int64, len(buckets))
+	for _, bucket := range buckets {
+		byDay[bucket.Start.Unix()] += bucket.Clicks
+	}
+	var series []models.ClickBucket
+	for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+		series = append(series, models.ClickBucket{Start: day, Clicks: byDay[day.Unix()]})
+	}
+	return series
+}
+
+// reportRow is a link of a report with its numbers for the period
+type reportRow struct {
+	link    models.CampaignLink
+	summary models.ClickSummary
+}
+
+// render lays a report out as a standalone HTML document in the configured branding
+func (s *ReportService) render(report *models.Report, total models.ClickSummary, series []models.ClickBucket, rows []reportRow) string {
+	rows = slices.Clone(rows)
+	slices.SortStableFunc(rows, func(a, b reportRow) int {
+		switch {
+		case a.summary.Clicks > b.summary.Clicks:
+			return -1
+		case a.summary.Clicks < b.summary.Clicks:
+			return 1
+		}
+		return 0
+	})
+
+	title := fmt.Sprintf("%s: %s report", report.Campaign, report.Period)
+	var page strings.Builder
+	fmt.Fprintf(&page, `<!DOCTYPE html><html><head><meta charset="utf-8"><title>%s</title><style>`+
+		`body{font-family:sans-serif;color:#111827;max-width:760px;margin:2em auto;padding:0 1em}`+
+		`header{border-bottom:4px solid %s;padding-bottom:.5em;margin-bottom:1em}header img{max-height:40px}`+
+		`.totals{display:flex;gap:1em}.totals div{flex:1;border:1px solid #e5e7eb;border-radius:6px;padding:.75em}`+
+		`.totals strong{display:block;font-size:1.5em}table{width:100%%;border-collapse:collapse;margin-top:1em}`+
+		`th,td{text-align:left;padding:.4em;border-bottom:1px solid #e5e7eb}td.n,th.n{text-align:right}`+
+		`</style></head><body><header>`, html.EscapeString(title), s.cfg.BrandColor)
+	if s.cfg.LogoURL != "" {
+		fmt.Fprintf(&page, `<img src="%s" alt="%s"><br>`, html.EscapeString(s.cfg.LogoURL), html.EscapeString(s.cfg.BrandName))
+	}
+	fmt.Fprintf(&page, `<small>%s</small><h1>%s</h1><p>%s to %s (UTC)</p></header>`,
+		html.EscapeString(s.cfg.BrandName), html.EscapeString(title),
+		report.PeriodStart.Format("2 January 2006"), report.PeriodEnd.AddDate(0, 0, -1).Format("2 January 2006"))
+
+	fmt.Fprintf(&page, `<div class="totals"><div><strong>%d</strong>clicks</div><div><strong>%d</strong>unique visitors</div>`+
+		`<div><strong>%d</strong>QR scans</div><div><strong>%d</strong>links</div></div>`,
+		total.Clicks, total.Visitors, total.QRScans, report.Links)
+
+	page.WriteString(`<h2>Clicks per day</h2>`)
+	page.WriteString(renderBarChart(series, s.cfg.BrandColor))
+
+	page.WriteString(`<h2>Links</h2><table><tr><th>Link</th><th>Destination</th><th class="n">Clicks</th>` +
+		`<th class="n">Visitors</th><th class="n">QR scans</th><th class="n">Share</th></tr>`)
+	for _, row := range rows {
+		share := 0.0
+		if total.Clicks > 0 {
+			share = float64(row.summary.Clicks) / float64(total.Clicks) * 100
+		}
+		fmt.Fprintf(&page, `<tr><td>/%s</td><td>%s</td><td class="n">%d</td><td class="n">%d</td><td class="n">%d</td><td class="n">%.1f%%</td></tr>`,
+			html.EscapeString(row.link.ShortCode), html.EscapeString(row.link.OriginalURL),
+			row.summary.Clicks, row.summary.Visitors, row.summary.QRScans, share)
+	}
+	fmt.Fprintf(&page, `</table><p><small>Clicks by people; bots and internal networks are left out. Generated %s.</small></p></body></html>`,
+		time.Now().UTC().Format("2 January 2006 15:04 MST"))
+	return page.String()
+}
+
+// renderBarChart draws daily clicks as an inline SVG bar chart
+func renderBarChart(series []models.ClickBucket, color string) string {
+	var peak int64 = 1
+	for _, bucket := range series {
+		if bucket.Clicks > peak {
+			peak = bucket.Clicks
+		}
+	}
+
+	const labelHeight = 18
+	plotHeight := float64(reportChartHeight - labelHeight - 14)
+	slot := float64(reportChartWidth) / float64(max(len(series), 1))
+	var svg strings.Builder
+	fmt.Fprintf(&svg, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" role="img">`,
+		reportChartWidth, reportChartHeight, reportChartWidth, reportChartHeight)
+	for i, bucket := range series {
+		height := float64(bucket.Clicks) / float64(peak) * plotHeight
+		x := float64(i)*slot + slot*0.15
+		y := float64(reportChartHeight-labelHeight) - height
+		fmt.Fprintf(&svg, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"><title>%s: %d</title></rect>`,
+			x, y, slot*0.7, height, color, bucket.Start.Format("2 Jan"), bucket.Clicks)
+		if len(series) <= 7 || i%7 == 0 {
+			fmt.Fprintf(&svg, `<text x="%.1f" y="%d" font-family="sans-serif" font-size="10" fill="#6b7280" text-anchor="middle">%s</text>`,
+				float64(i)*slot+slot/2, reportChartHeight-4, bucket.Start.Format("2 Jan"))
+		}
+	}
+	fmt.Fprintf(&svg, `<text x="0" y="10" font-family="sans-serif" font-size="10" fill="#6b7280">%d</text>`, peak)
+	svg.WriteString(`</svg>`)
+	return svg.String()
+}
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexnthnz/url-shortener/internal/models"
+)
+
+func TestReportPeriod(t *testing.T) {
+	tests := []struct {
+		period   string
+		at       string
+		expected [2]string
+	}{
+		{ReportWeekly, "2024-03-06T15:00:00Z", [2]string{"2024-03-04", "2024-03-11"}},
+		{ReportWeekly, "2024-03-04T00:00:00Z", [2]string{"2024-03-04", "2024-03-11"}},
+		{ReportWeekly, "2024-03-10T23:59:59Z", [2]string{"2024-03-04", "2024-03-11"}},
+		{ReportMonthly, "2024-02-29T12:00:00Z", [2]string{"2024-02-01", "2024-03-01"}},
+		{ReportMonthly, "2024-12-31T23:00:00Z", [2]string{"2024-12-01", "2025-01-01"}},
+	}
+
+	for _, test := range tests {
+		at, _ := time.Parse(time.RFC3339, test.at)
+		start, end, err := reportPeriod(test.period, at)
+		if err != nil {
+			t.Fatalf("reportPeriod(%s, %s) returned error: %v", test.period, test.at, err)
+		}
+		if got := [2]string{start.Format("2006-01-02"), end.Format("2006-01-02")}; got != test.expected {
+			t.Errorf("reportPeriod(%s, %s) = %v; expected %v", test.period, test.at, got, test.expected)
+		}
+	}
+
+	if _, _, err := reportPeriod("daily", time.Now()); err == nil {
+		t.Error("reportPeriod() should reject unknown periods")
+	}
+}
+
+func TestReportRender(t *testing.T) {
+	service := &ReportService{cfg: ReportConfig{BrandName: "Acme <Marketing>", BrandColor: "#ff6600"}}
+	from := time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 7)
+	series := dailySeries(from, to, []models.ClickBucket{{Start: from.AddDate(0, 0, 2), Clicks: 12}})
+	if len(series) != 7 || series[2].Clicks != 12 || series[0].Clicks != 0 {
+		t.Fatalf("dailySeries() = %v; expected 7 days with 12 clicks on the third", series)
+	}
+
+	report := &models.Report{Campaign: "spring", Period: ReportWeekly, PeriodStart: from, PeriodEnd: to, Links: 2}
+	rows := []reportRow{
+		{link: models.CampaignLink{ShortCode: "a", OriginalURL: "https://example.com/a"}, summary: models.ClickSummary{Clicks: 2}},
+		{link: models.CampaignLink{ShortCode: "b", OriginalURL: "https://example.com/b?x=1&y=2"}, summary: models.ClickSummary{Clicks: 10}},
+	}
+	rendered := service.render(report, models.ClickSummary{Clicks: 12, Visitors: 9}, series, rows)
+
+	for _, expected := range []string{
+		"Acme &lt;Marketing&gt;",
+		"border-bottom:4px solid #ff6600",
+		"4 March 2024 to 10 March 2024",
+		"https://example.com/b?x=1&amp;y=2",
+		"83.3%",
+		"<svg",
+	} {
+		if !strings.Contains(rendered, expected) {
+			t.Errorf("render() does not contain %s", expected)
+		}
+	}
+	if strings.Index(rendered, "<td>/b</td>") > strings.Index(rendered, "<td>/a</td>") {
+		t.Error("render() should list the links with the most clicks first")
+	}
+}
@@ -0,0 +1,225 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	apperrors "github.com/alexnthnz/url-shortener/internal/errors"
+	"github.com/alexnthnz/url-shortener/internal/repository"
+	"github.com/alexnthnz/url-shortener/pkg/signing"
+)
+
+// RequestVerifier checks HMAC request signatures made with pkg/signing. A signature is
+// accepted once: its nonce is remembered for longer than the timestamp window, so a
+// captured request cannot be replayed.
+type RequestVerifier struct {
+	keys     map[string][]byte
+	scopes   map[string]*KeyScopes
+	expiry   map[string]time.Time
+	window   time.Duration
+	required bool
+	cache    repository.Cache
+}
+
+// ParseSigningKeys parses "id:secret,id:secret"; several keys allow rotation
+func ParseSigningKeys(spec string) (map[string][]byte, error) {
+	keys := make(map[string][]byte)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, secret, ok := strings.Cut(entry, ":")
+		if !ok || id == "" || len(secret) < 32 {
+			return nil, fmt.Errorf("signing keys must look like id:secret with secrets of at least 32 bytes")
+		}
+		keys[id] = []byte(secret)
+	}
+	return keys, nil
+}
+
+// API key scopes; a key with scopes may only call the routes they grant, while keys
+// without any keep full access
+const (
+	ScopeLinksRead  = "links:read"
+	ScopeLinksWrite = "links:write"
+	ScopeStatsRead  = "stats:read"
+	ScopeAdmin      = "admin" // grants every other scope too
+)
+
+var validScopes = []string{ScopeLinksRead, ScopeLinksWrite, ScopeStatsRead, ScopeAdmin}
+
+// KeyScopes restricts what a signing key may do. Domains, when set, are the only custom
+// domains the key may create links on; links on the default domain are refused.
+type KeyScopes struct {
+	Scopes  []string
+	Domains []string
+}
+
+// Allows reports whether the scopes grant scope
+func (k *KeyScopes) Allows(scope string) bool {
+	return slices.Contains(k.Scopes, ScopeAdmin) || slices.Contains(k.Scopes, scope)
+}
+
+// AllowsDomain reports whether the key may create links on a custom domain, "" being the
+// default domain
+func (k *KeyScopes) AllowsDomain(domain string) bool {
+	return len(k.Domains) == 0 || slices.Contains(k.Domains, strings.ToLower(strings.TrimSpace(domain)))
+}
+
+// ParseKeyScopes parses the scopes ("id:scope+scope,...") and domains
+// ("id:domain+domain,...") of signing keys. Every key id must be a configured key, and a
+// key given domains but no scopes may only create links.
+func ParseKeyScopes(scopes, domains []string, keys map[string][]byte) (map[string]*KeyScopes, error) {
+	result := make(map[string]*KeyScopes)
+	entry := func(spec string) (*KeyScopes, []string, error) {
+		id, values, ok := strings.Cut(spec, ":")
+		if !ok || id == "" || values == "" {
+			return nil, nil, fmt.Errorf("%q must look like id:value+value", spec)
+		}
+		if _, ok := keys[id]; !ok {
+			return nil, nil, fmt.Errorf("%q names no configured signing key", id)
+		}
+		if result[id] == nil {
+			result[id] = &KeyScopes{}
+		}
+		return result[id], strings.Split(values, "+"), nil
+	}
+
+	for _, spec := range scopes {
+		key, values, err := entry(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key scopes: %w", err)
+		}
+		for _, scope := range values {
+			if !slices.Contains(validScopes, scope) {
+				return nil, fmt.Errorf("invalid key scopes: unknown scope %q, use %s", scope, strings.Join(validScopes, ", "))
+			}
+			key.Scopes = append(key.Scopes, scope)
+		}
+	}
+	for _, spec := range domains {
+		key, values, err := entry(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key domains: %w", err)
+		}
+		for _, domain := range values {
+			if domain = strings.ToLower(strings.TrimSpace(domain)); domain != "" {
+				key.Domains = append(key.Domains, domain)
+			}
+		}
+	}
+
+	for _, key := range result {
+		if len(key.Scopes) == 0 {
+			key.Scopes = []string{ScopeLinksWrite}
+		}
+	}
+	return result, nil
+}
+
+// ParseKeyExpiry parses the expiry times of signing keys, "id:2006-01-02,..." or with
+// RFC 3339 times; a date expires the key at the start of that day, UTC
+func ParseKeyExpiry(specs []string, keys map[string][]byte) (map[string]time.Time, error) {
+	expiry := make(map[string]time.Time)
+	for _, spec := range specs {
+		id, value, ok := strings.Cut(spec, ":")
+		if !ok || id == "" || value == "" {
+			return nil, fmt.Errorf("invalid key expiry: %q must look like id:2006-01-02", spec)
+		}
+		if _, ok := keys[id]; !ok {
+			return nil, fmt.Errorf("invalid key expiry: %q names no configured signing key", id)
+		}
+		at, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			if at, err = time.Parse("2006-01-02", value); err != nil {
+				return nil, fmt.Errorf("invalid key expiry: %q is neither a date nor an RFC 3339 time", value)
+			}
+		}
+		expiry[id] = at.UTC()
+	}
+	return expiry, nil
+}
+
+func NewRequestVerifier(keys map[string][]byte, scopes map[string]*KeyScopes, expiry map[string]time.Time, window time.Duration, required bool, cache repository.Cache) (*RequestVerifier, error) {
+	if required && len(keys) == 0 {
+		return nil, fmt.Errorf("request signing is required but no signing keys are configured")
+	}
+	return &RequestVerifier{keys: keys, scopes: scopes, expiry: expiry, window: window, required: required, cache: cache}, nil
+}
+
+// KeyIDs returns the ids of the configured signing keys, sorted
+func (v *RequestVerifier) KeyIDs() []string {
+	ids := make([]string, 0, len(v.keys))
+	for id := range v.keys {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// Scopes returns the restrictions of a signing key, nil when it has full access
+func (v *RequestVerifier) Scopes(keyID string) *KeyScopes {
+	return v.scopes[keyID]
+}
+
+// ExpiresAt returns when a signing key stops being accepted, if it expires
+func (v *RequestVerifier) ExpiresAt(keyID string) (time.Time, bool) {
+	at, ok := v.expiry[keyID]
+	return at, ok
+}
+
+// Required reports whether unsigned requests must be rejected
+func (v *RequestVerifier) Required() bool {
+	return v.required
+}
+
+// Verify checks the signature headers of a request. Errors about the signature itself
+// start with "invalid signature".
+func (v *RequestVerifier) Verify(ctx context.Context, method, requestURI string, header http.Header, body []byte) error {
+	keyID := header.Get(signing.HeaderKeyID)
+	nonce := header.Get(signing.HeaderNonce)
+	signature := header.Get(signing.HeaderSignature)
+	if keyID == "" || nonce == "" || signature == "" {
+		return apperrors.Errorf(apperrors.ErrUnauthorized, "invalid signature: missing signature headers")
+	}
+
+	secret, ok := v.keys[keyID]
+	if !ok {
+		return apperrors.Errorf(apperrors.ErrUnauthorized, "invalid signature: unknown key")
+	}
+	if expiresAt, ok := v.expiry[keyID]; ok && !time.Now().Before(expiresAt) {
+		return apperrors.Errorf(apperrors.ErrUnauthorized, "invalid signature: key expired")
+	}
+	if len(nonce) < 16 || len(nonce) > 64 {
+		return apperrors.Errorf(apperrors.ErrUnauthorized, "invalid signature: nonce must be 16 to 64 characters")
+	}
+
+	seconds, err := strconv.ParseInt(header.Get(signing.HeaderTimestamp), 10, 64)
+	if err != nil {
+		return apperrors.Errorf(apperrors.ErrUnauthorized, "invalid signature: malformed timestamp")
+	}
+	timestamp := time.Unix(seconds, 0)
+	if skew := time.Since(timestamp); skew > v.window || skew < -v.window {
+		return apperrors.Errorf(apperrors.ErrUnauthorized, "invalid signature: timestamp outside the allowed window")
+	}
+
+	if !signing.Verify(secret, method, requestURI, timestamp, nonce, body, signature) {
+		return apperrors.Errorf(apperrors.ErrUnauthorized, "invalid signature: signature mismatch")
+	}
+
+	// Only now record the nonce, so forged requests cannot burn nonces of real ones
+	fresh, err := v.cache.SetNX(ctx, "signature_nonce:"+keyID+":"+nonce, "1", 2*v.window)
+	if err != nil {
+		return fmt.Errorf("failed to check signature nonce: %w", err)
+	}
+	if !fresh {
+		return apperrors.Errorf(apperrors.ErrUnauthorized, "invalid signature: nonce already used")
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexnthnz/url-shortener/internal/models"
+	"github.com/alexnthnz/url-shortener/pkg/signing"
+)
+
+func TestRequestVerifierRejects(t *testing.T) {
+	secret := []byte(strings.Repeat("s", 32))
+	keys := map[string][]byte{"ci": secret, "retired": secret}
+	expiry := map[string]time.Time{"retired": time.Now().Add(-time.Hour)}
+	verifier, err := NewRequestVerifier(keys, nil, expiry, 5*time.Minute, true, nil)
+	if err != nil {
+		t.Fatalf("NewRequestVerifier failed: %v", err)
+	}
+
+	body := []byte(`{"url":"https://example.com"}`)
+	nonce := "0123456789abcdef"
+	signed := func(keyID string, timestamp time.Time, signedBody []byte) http.Header {
+		header := http.Header{}
+		header.Set(signing.HeaderKeyID, keyID)
+		header.Set(signing.HeaderTimestamp, strconv.FormatInt(timestamp.Unix(), 10))
+		header.Set(signing.HeaderNonce, nonce)
+		header.Set(signing.HeaderSignature, signing.Sign(secret, "POST", "/api/v1/shorten", timestamp, nonce, signedBody))
+		return header
+	}
+
+	testCases := []struct {
+		name   string
+		header http.Header
+	}{
+		{"unsigned", http.Header{}},
+		{"unknown key", signed("other", time.Now(), body)},
+		{"expired key", signed("retired", time.Now(), body)},
+		{"stale timestamp", signed("ci", time.Now().Add(-10*time.Minute), body)},
+		{"tampered body", signed("ci", time.Now(), []byte(`{"url":"https://evil.example"}`))},
+	}
+
+	for _, tc := range testCases {
+		err := verifier.Verify(context.Background(), "POST", "/api/v1/shorten", tc.header, body)
+		if err == nil || !strings.Contains(err.Error(), "invalid signature") {
+			t.Errorf("%s: Verify() = %v; expected an invalid signature error", tc.name, err)
+		}
+	}
+
+	if _, err := NewRequestVerifier(nil, nil, nil, time.Minute, true, nil); err == nil {
+		t.Error("requiring signatures without keys should fail")
+	}
+}
+
+func TestParseKeyScopes(t *testing.T) {
+	keys := map[string][]byte{"ci": nil, "dashboard": nil, "ops": nil}
+	scopes, err := ParseKeyScopes(
+		[]string{"ci:links:write", "dashboard:stats:read+links:read", "ops:admin"},
+		[]string{"ci:Go.example.com+promo.example.com"},
+		keys)
+	if err != nil {
+		t.Fatalf("ParseKeyScopes failed: %v", err)
+	}
+
+	testCases := []struct {
+		key      string
+		scope    string
+		expected bool
+	}{
+		{"ci", ScopeLinksWrite, true},
+		{"ci", ScopeStatsRead, false},
+		{"ci", ScopeLinksRead, false},
+		{"dashboard", ScopeStatsRead, true},
+		{"dashboard", ScopeLinksRead, true},
+		{"dashboard", ScopeLinksWrite, false},
+		{"ops", ScopeStatsRead, true},
+	}
+	for _, tc := range testCases {
+		if allowed := scopes[tc.key].Allows(tc.scope); allowed != tc.expected {
+			t.Errorf("%s.Allows(%s) = %v; expected %v", tc.key, tc.scope, allowed, tc.expected)
+		}
+	}
+
+	if !scopes["ci"].AllowsDomain("go.example.com") || scopes["ci"].AllowsDomain("") || scopes["ci"].AllowsDomain("other.example.com") {
+		t.Errorf("ci domains = %v; expected only go.example.com and promo.example.com", scopes["ci"].Domains)
+	}
+	if !scopes["dashboard"].AllowsDomain("") {
+		t.Error("keys without domains should create links on any domain")
+	}
+
+	// A key with only domains may create links
+	domainsOnly, err := ParseKeyScopes(nil, []string{"ci:go.example.com"}, keys)
+	if err != nil || !domainsOnly["ci"].Allows(ScopeLinksWrite) || domainsOnly["ci"].Allows(ScopeStatsRead) {
+		t.Errorf("ParseKeyScopes with only domains = %v, %v; expected links:write", domainsOnly["ci"], err)
+	}
+
+	for _, spec := range []string{"ci:links:delete", "unknown:links:write", "ci", "ci:"} {
+		if _, err := ParseKeyScopes([]string{spec}, nil, keys); err == nil {
+			t.Errorf("ParseKeyScopes(%q) succeeded; expected an error", spec)
+		}
+	}
+}
+
+func TestParseKeyExpiry(t *testing.T) {
+	keys := map[string][]byte{"ci": nil, "ops": nil}
+	expiry, err := ParseKeyExpiry([]string{"ci:2027-01-31", "ops:2027-01-31T12:00:00+02:00"}, keys)
+	if err != nil {
+		t.Fatalf("ParseKeyExpiry failed: %v", err)
+	}
+	if want := time.Date(2027, 1, 31, 0, 0, 0, 0, time.UTC); !expiry["ci"].Equal(want) {
+		t.Errorf("ci expires at %v; expected %v", expiry["ci"], want)
+	}
+	if want := time.Date(2027, 1, 31, 10, 0, 0, 0, time.UTC); !expiry["ops"].Equal(want) {
+		t.Errorf("ops expires at %v; expected %v", expiry["ops"], want)
+	}
+
+	for _, spec := range []string{"ci:next-year", "unknown:2027-01-31", "ci", "ci:"} {
+		if _, err := ParseKeyExpiry([]string{spec}, keys); err == nil {
+			t.Errorf("ParseKeyExpiry(%q) succeeded; expected an error", spec)
+		}
+	}
+}
+
+func TestMarkStale(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+
+	testCases := []struct {
+		name    string
+		key     models.SigningKey
+		expired bool
+		stale   bool
+	}{
+		{"recently used", models.SigningKey{LastUsedAt: at(-time.Hour)}, false, false},
+		{"never used", models.SigningKey{}, false, true},
+		{"unused too long", models.SigningKey{LastUsedAt: at(-31 * 24 * time.Hour)}, false, true},
+		{"expired", models.SigningKey{LastUsedAt: at(-time.Hour), ExpiresAt: at(-time.Minute)}, true, true},
+		{"expiring", models.SigningKey{LastUsedAt: at(-time.Hour), ExpiresAt: at(time.Minute)}, false, false},
+	}
+	for _, tc := range testCases {
+		key := tc.key
+		markStale(&key, 30*24*time.Hour, now)
+		if key.Expired != tc.expired || key.Stale != tc.stale {
+			t.Errorf("%s: expired, stale = %v, %v; expected %v, %v", tc.name, key.Expired, key.Stale, tc.expired, tc.stale)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	apperrors "github.com/alexnthnz/url-shortener/internal/errors"
+	"github.com/alexnthnz/url-shortener/internal/models"
+	"github.com/alexnthnz/url-shortener/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	retentionCheckInterval = time.Hour
+	retentionJobType       = "analytics_retention"
+)
+
+// RetentionService purges analytics older than the configured retention period
+type RetentionService struct {
+	analyticsRepo repository.AnalyticsStore
+	jobs          *JobService
+	cache         repository.Cache
+	retentionDays int
+	batchSize     int
+	logger        *logrus.Logger
+}
+
+func NewRetentionService(analyticsRepo repository.AnalyticsStore, jobs *JobService, cache repository.Cache, retentionDays, batchSize int, logger *logrus.Logger) *RetentionService {
+	service := &RetentionService{
+		analyticsRepo: analyticsRepo,
+		jobs:          jobs,
+		cache:         cache,
+		retentionDays: retentionDays,
+		batchSize:     batchSize,
+		logger:        logger,
+	}
+
+	jobs.RegisterBatchJob(retentionJobType, service.purgeBatch)
+	if retentionDays > 0 {
+		// Start daily purge scheduler
+		go service.schedule()
+	}
+
+	return service
+}
+
+// RunPurge starts an analytics retention purge job immediately
+func (s *RetentionService) RunPurge() (*models.Job, error) {
+	if s.retentionDays <= 0 {
+		return nil, apperrors.Errorf(apperrors.ErrNotConfigured, "analytics retention is not configured")
+	}
+
+	// The cutoff is the cursor, so a resumed purge keeps the cutoff it started with
+	cutoff := time.Now().AddDate(0, 0, -s.retentionDays)
+	return s.jobs.StartBatchJob(retentionJobType, cutoff.UTC().Format(time.RFC3339))
+}
+
+// purgeBatch deletes one batch of clicks older than the cutoff in cursor
+func (s *RetentionService) purgeBatch(ctx context.Context, cursor string) (int64, string, error) {
+	cutoff, err := time.Parse(time.RFC3339, cursor)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid retention cutoff %q: %w", cursor, err)
+	}
+	n, err := s.analyticsRepo.DeleteOlderThan(ctx, cutoff, s.batchSize)
+	return n, cursor, err
+}
+
+// schedule runs the purge once per day across all instances
+func (s *RetentionService) schedule() {
+	ctx := context.Background()
+	ticker := time.NewTicker(retentionCheckInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		// Only one instance claims each day's purge
+		lockKey := "lock:analytics_retention:" + time.Now().UTC().Format(usageDateLayout)
+		acquired, err := s.cache.SetNX(ctx, lockKey, "1", 25*time.Hour)
+		if err != nil {
+			s.logger.Warnf("Failed to acquire retention lock: %v", err)
+			continue
+		}
+		if !acquired {
+			continue
+		}
+
+		if _, err := s.RunPurge(); err != nil {
+			s.logger.Errorf("Failed to start retention purge: %v", err)
+		}
+	}
+}
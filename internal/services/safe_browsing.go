@@ -0,0 +1,336 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	apperrors "github.com/alexnthnz/url-shortener/internal/errors"
+	"github.com/alexnthnz/url-shortener/internal/models"
+	"github.com/alexnthnz/url-shortener/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// Safe Browsing actions for listed destinations of new links
+const (
+	SafeBrowsingReject = "reject"
+	SafeBrowsingFlag   = "flag"
+)
+
+const (
+	// safeBrowsingBatchSize is the most URLs the Lookup API takes in one request
+	safeBrowsingBatchSize = 500
+	// safeBrowsingCheckInterval is how often instances look for a due rescan
+	safeBrowsingCheckInterval = time.Hour
+	safeBrowsingJobType       = "safe_browsing_rescan"
+)
+
+// safeBrowsingThreatTypes are the lists destinations are checked against
+var safeBrowsingThreatTypes = []string{"MALWARE", "SOCIAL_ENGINEERING", "UNWANTED_SOFTWARE", "POTENTIALLY_HARMFUL_APPLICATION"}
+
+// SafeBrowsingConfig configures destination screening with the Google Safe Browsing Lookup API
+type SafeBrowsingConfig struct {
+	APIKey         string // screening is off when empty
+	Endpoint       string
+	Action         string        // SafeBrowsingReject or SafeBrowsingFlag
+	CacheTTL       time.Duration // how long a clean result is trusted
+	RescanInterval time.Duration // 0 disables rescans of stored links
+	Version        string
+}
+
+// SafeBrowsingService screens destinations against Google Safe Browsing. New links with a
+// listed destination are rejected, or created and flagged with a takedown request for
+// review; periodic rescans flag stored links whose destination was listed later. Clean
+// results are cached in Redis so popular destinations are not looked up again and again.
+// Lookup failures never block shortening.
+type SafeBrowsingService struct {
+	cfg       SafeBrowsingConfig
+	urlRepo   repository.URLStore
+	cache     repository.Cache
+	jobs      *JobService
+	takedowns *TakedownService
+	client    *http.Client
+	logger    *logrus.Logger
+}
+
+func NewSafeBrowsingService(cfg SafeBrowsingConfig, urlRepo repository.URLStore, cache repository.Cache, jobs *JobService, logger *logrus.Logger) (*SafeBrowsingService, error) {
+	if cfg.Action != SafeBrowsingReject && cfg.Action != SafeBrowsingFlag {
+		return nil, fmt.Errorf("invalid action %q: must be %q or %q", cfg.Action, SafeBrowsingReject, SafeBrowsingFlag)
+	}
+
+	service := &SafeBrowsingService{
+		cfg:     cfg,
+		urlRepo: urlRepo,
+		cache:   cache,
+		jobs:    jobs,
+		client:  &http.Client{Timeout: 5 * time.Second},
+		logger:  logger,
+	}
+
+	if service.Enabled() {
+		jobs.RegisterBatchJob(safeBrowsingJobType, service.rescanBatch)
+	}
+	if service.Enabled() && cfg.RescanInterval > 0 {
+		go service.schedule()
+	}
+
+	return service, nil
+}
+
+// SetTakedownService connects the takedown review that flagged links are filed with. The
+// takedown service depends on the URL service, which depends on this one, so it is set
+// once all three exist.
+func (s *SafeBrowsingService) SetTakedownService(takedowns *TakedownService) {
+	s.takedowns = takedowns
+}
+
+// Enabled reports whether destinations are screened
+func (s *SafeBrowsingService) Enabled() bool {
+	return s != nil && s.cfg.APIKey != ""
+}
+
+// Rejects reports whether new links with a listed destination are refused
+func (s *SafeBrowsingService) Rejects() bool {
+	return s.cfg.Action == SafeBrowsingReject
+}
+
+// Check returns the threat type a destination is listed under, empty when it is clean
+func (s *SafeBrowsingService) Check(ctx context.Context, destination string) (string, error) {
+	threats, err := s.Lookup(ctx, []string{destination})
+	if err != nil {
+		return "", err
+	}
+	return threats[destination], nil
+}
+
+// Lookup returns the threat type of each listed URL among urls. URLs with a cached clean
+// result are not looked up again.
+func (s *SafeBrowsingService) Lookup(ctx context.Context, urls []string) (map[string]string, error) {
+	return s.lookup(ctx, urls, true)
+}
+
+// lookup looks urls up, skipping those with a cached clean result when useCache is set.
+// Clean results are cached either way.
+func (s *SafeBrowsingService) lookup(ctx context.Context, urls []string, useCache bool) (map[string]string, error) {
+	threats := make(map[string]string)
+	if !s.Enabled() || len(urls) == 0 {
+		return threats, nil
+	}
+
+	cached := make([]string, len(urls))
+	if useCache {
+		keys := make([]string, len(urls))
+		for i, u := range urls {
+			keys[i] = safeBrowsingCacheKey(u)
+		}
+		values, err := s.cache.MGet(ctx, keys...)
+		if err != nil {
+			s.logger.Warnf("Failed to read Safe Browsing cache: %v", err)
+		} else {
+			cached = values
+		}
+	}
+
+	var pending []string
+	for i, u := range urls {
+		if cached[i] == "" {
+			pending = append(pending, u)
+		}
+	}
+
+	for start := 0; start < len(pending); start += safeBrowsingBatchSize {
+		batch := pending[start:min(start+safeBrowsingBatchSize, len(pending))]
+		matches, err := s.find(batch)
+		if err != nil {
+			return nil, err
+		}
+		for _, u := range batch {
+			if threat, ok := matches[u]; ok {
+				threats[u] = threat
+				continue
+			}
+			if err := s.cache.SetWithTTL(ctx, safeBrowsingCacheKey(u), "clean", s.cfg.CacheTTL); err != nil {
+				s.logger.Warnf("Failed to cache Safe Browsing result: %v", err)
+			}
+		}
+	}
+	return threats, nil
+}
+
+// find asks the Lookup API which of urls are listed
+func (s *SafeBrowsingService) find(urls []string) (map[string]string, error) {
+	entries := make([]map[string]string, len(urls))
+	for i, u := range urls {
+		entries[i] = map[string]string{"url": u}
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"client": map[string]string{"clientId": "url-shortener", "clientVersion": s.cfg.Version},
+		"threatInfo": map[string]interface{}{
+			"threatTypes":      safeBrowsingThreatTypes,
+			"platformTypes":    []string{"ANY_PLATFORM"},
+			"threatEntryTypes": []string{"URL"},
+			"threatEntries":    entries,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	// A header rather than the key query parameter keeps the key out of error messages
+	req.Header.Set("X-Goog-Api-Key", s.cfg.APIKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("safe browsing lookup failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("safe browsing lookup returned status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Matches []struct {
+			ThreatType string `json:"threatType"`
+			Threat     struct {
+				URL string `json:"url"`
+			} `json:"threat"`
+		} `json:"matches"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 4<<20)).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode safe browsing response: %w", err)
+	}
+
+	matches := make(map[string]string, len(result.Matches))
+	for _, match := range result.Matches {
+		matches[match.Threat.URL] = match.ThreatType
+	}
+	return matches, nil
+}
+
+// Flag files a takedown request for a link whose destination is listed, unless one is
+// already open. With TAKEDOWN_AUTO_DISABLE the link goes dark straight away.
+func (s *SafeBrowsingService) Flag(ctx context.Context, shortCode, destination, threat string) error {
+	if s.takedowns == nil {
+		return fmt.Errorf("takedown review is not connected")
+	}
+
+	open, err := s.takedowns.HasOpenRequest(shortCode)
+	if err != nil || open {
+		return err
+	}
+
+	reason := TakedownReasonMalware
+	if threat == "SOCIAL_ENGINEERING" {
+		reason = TakedownReasonPhishing
+	}
+	_, err = s.takedowns.Submit(ctx, &models.TakedownSubmission{
+		ShortCode:    shortCode,
+		Reason:       reason,
+		ReporterName: "Google Safe Browsing",
+		Description:  fmt.Sprintf("Safe Browsing lists the destination %s as %s.", destination, threat),
+	})
+	return err
+}
+
+// RunRescan starts a job that screens the destinations of all enabled links again and
+// flags those listed since they were created
+func (s *SafeBrowsingService) RunRescan() (*models.Job, error) {
+	if !s.Enabled() {
+		return nil, apperrors.Errorf(apperrors.ErrNotConfigured, "safe browsing is not configured")
+	}
+
+	return s.jobs.StartBatchJob(safeBrowsingJobType, "0")
+}
+
+// rescanBatch screens the destinations, rule destinations and landing page buttons of
+// the links after the link id in cursor
+func (s *SafeBrowsingService) rescanBatch(ctx context.Context, cursor string) (int64, string, error) {
+	afterID, err := strconv.ParseInt(cursor, 10, 64)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid rescan cursor %q: %w", cursor, err)
+	}
+	links, err := s.urlRepo.ListDestinations(ctx, afterID, safeBrowsingBatchSize)
+	if err != nil || len(links) == 0 {
+		return 0, "", err
+	}
+
+	var destinations []string
+	seen := make(map[string]bool)
+	for _, link := range links {
+		for _, u := range link.URLs {
+			if !seen[u] {
+				seen[u] = true
+				destinations = append(destinations, u)
+			}
+		}
+	}
+	// Rescans look every destination up again, whatever the cache says
+	threats, err := s.lookup(ctx, destinations, false)
+	if err != nil {
+		return 0, "", err
+	}
+
+	for _, link := range links {
+		// One takedown request per link covers all of its listed URLs
+		for _, u := range link.URLs {
+			threat, ok := threats[u]
+			if !ok {
+				continue
+			}
+			s.logger.Warnf("Safe Browsing lists %s, a destination of %s, as %s", u, link.ShortCode, threat)
+			if err := s.Flag(ctx, link.ShortCode, u, threat); err != nil {
+				s.logger.Errorf("Failed to flag %s: %v", link.ShortCode, err)
+			}
+			break
+		}
+	}
+	return int64(len(links)), strconv.FormatInt(links[len(links)-1].ID, 10), nil
+}
+
+// schedule runs a rescan once per interval across all instances
+func (s *SafeBrowsingService) schedule() {
+	ctx := context.Background()
+	ticker := time.NewTicker(safeBrowsingCheckInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		// Only one instance claims each interval's rescan
+		period := time.Now().UTC().UnixNano() / int64(s.cfg.RescanInterval)
+		lockKey := fmt.Sprintf("lock:safe_browsing_rescan:%d", period)
+		acquired, err := s.cache.SetNX(ctx, lockKey, "1", s.cfg.RescanInterval+time.Hour)
+		if err != nil {
+			s.logger.Warnf("Failed to acquire Safe Browsing rescan lock: %v", err)
+			continue
+		}
+		if !acquired {
+			continue
+		}
+
+		if _, err := s.RunRescan(); err != nil {
+			s.logger.Errorf("Failed to start Safe Browsing rescan: %v", err)
+		}
+	}
+}
+
+// safeBrowsingCacheKey is the cache key of a destination's clean result; destinations
+// are hashed since they can be long and carry tokens
+func safeBrowsingCacheKey(destination string) string {
+	sum := sha256.Sum256([]byte(destination))
+	return "safebrowsing:" + hex.EncodeToString(sum[:16])
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestSafeBrowsingFind(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Goog-Api-Key") != "test-key" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		var body struct {
+			ThreatInfo struct {
+				ThreatEntries []struct {
+					URL string `json:"url"`
+				} `json:"threatEntries"`
+			} `json:"threatInfo"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || len(body.ThreatInfo.ThreatEntries) != 2 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"matches": [{"threatType": "SOCIAL_ENGINEERING", "threat": {"url": "https://phish.example/login"}}]}`))
+	}))
+	defer server.Close()
+
+	service := &SafeBrowsingService{
+		cfg:    SafeBrowsingConfig{APIKey: "test-key", Endpoint: server.URL},
+		client: server.Client(),
+		logger: logrus.New(),
+	}
+
+	matches, err := service.find([]string{"https://example.com/", "https://phish.example/login"})
+	if err != nil {
+		t.Fatalf("find() returned error: %v", err)
+	}
+	if len(matches) != 1 || matches["https://phish.example/login"] != "SOCIAL_ENGINEERING" {
+		t.Errorf("find() = %v; expected only the phishing URL", matches)
+	}
+
+	service.cfg.APIKey = "wrong-key"
+	if _, err := service.find([]string{"https://example.com/", "https://phish.example/login"}); err == nil {
+		t.Error("find() should fail when the API refuses the key")
+	}
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"github.com/alexnthnz/url-shortener/internal/models"
+	"github.com/alexnthnz/url-shortener/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// signingKeyUseInterval spaces the writes of a busy key's last use; an unchanged IP is
+// recorded at most this often
+const signingKeyUseInterval = time.Minute
+
+// SigningKeyService tracks when and from where each request signing key was last used, so
+// unused and expired keys can be found and pruned
+type SigningKeyService struct {
+	repo       *repository.SigningKeyRepository
+	verifier   *RequestVerifier
+	staleAfter time.Duration
+	logger     *logrus.Logger
+
+	mu       sync.Mutex
+	recorded map[string]models.SigningKeyUsage // last write per key
+}
+
+func NewSigningKeyService(repo *repository.SigningKeyRepository, verifier *RequestVerifier, staleAfter time.Duration, logger *logrus.Logger) *SigningKeyService {
+	return &SigningKeyService{
+		repo:       repo,
+		verifier:   verifier,
+		staleAfter: staleAfter,
+		logger:     logger,
+		recorded:   make(map[string]models.SigningKeyUsage),
+	}
+}
+
+// StaleAfter returns how long a key may go unused before it is reported as stale
+func (s *SigningKeyService) StaleAfter() time.Duration {
+	return s.staleAfter
+}
+
+// RecordUse notes a verified request signed with a key. Failures are only logged: losing
+// a last-used time must not fail the request.
+func (s *SigningKeyService) RecordUse(keyID, ip string) {
+	now := time.Now().UTC()
+	s.mu.Lock()
+	last, ok := s.recorded[keyID]
+	if ok && last.LastUsedIP == ip && now.Sub(last.LastUsedAt) < signingKeyUseInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.recorded[keyID] = models.SigningKeyUsage{KeyID: keyID, LastUsedAt: now, LastUsedIP: ip}
+	s.mu.Unlock()
+
+	if err := s.repo.RecordUse(keyID, ip, now); err != nil {
+		s.logger.Warnf("Failed to record use of signing key %s: %v", keyID, err)
+	}
+}
+
+// List describes every configured key; with staleOnly, only the keys that are stale
+// after staleAfter without use
+func (s *SigningKeyService) List(staleAfter time.Duration, staleOnly bool) ([]*models.SigningKey, error) {
+	usage, err := s.repo.ListUsage()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	keys := make([]*models.SigningKey, 0)
+	for _, id := range s.verifier.KeyIDs() {
+		key := &models.SigningKey{KeyID: id}
+		if scopes := s.verifier.Scopes(id); scopes != nil {
+			key.Scopes, key.Domains = scopes.Scopes, scopes.Domains
+		}
+		if expiresAt, ok := s.verifier.ExpiresAt(id); ok {
+			key.ExpiresAt = &expiresAt
+		}
+		if use, ok := usage[id]; ok {
+			key.LastUsedAt, key.LastUsedIP = &use.LastUsedAt, use.LastUsedIP
+		}
+		markStale(key, staleAfter, now)
+		if staleOnly && !key.Stale {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// markStale sets whether a key has expired, and whether it is stale: expired, never used,
+// or last used longer than staleAfter ago
+func markStale(key *models.SigningKey, staleAfter time.Duration, now time.Time) {
+	key.Expired = key.ExpiresAt != nil && !now.Before(*key.ExpiresAt)
+	key.Stale = key.Expired || key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > staleAfter
+}
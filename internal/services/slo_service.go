@@ -0,0 +1,190 @@
+package services
+
+import (
+	"sync"
+	"time"
+
+	"github.com/alexnthnz/url-shortener/internal/models"
+)
+
+const (
+	sloWindow = 30 * 24 * time.Hour
+
+	AlertNone   = "none"
+	AlertTicket = "ticket"
+	AlertPage   = "page"
+)
+
+// burnWindows are the lookback windows burn rates are reported for
+var burnWindows = []struct {
+	name     string
+	duration time.Duration
+}{
+	{"5m", 5 * time.Minute},
+	{"30m", 30 * time.Minute},
+	{"1h", time.Hour},
+	{"2h", 2 * time.Hour},
+	{"6h", 6 * time.Hour},
+	{"1d", 24 * time.Hour},
+	{"3d", 3 * 24 * time.Hour},
+	{"30d", sloWindow},
+}
+
+// sloBucket holds one minute of redirect outcomes
+type sloBucket struct {
+	minute int64
+	total  int64
+	errors int64
+	slow   int64
+}
+
+// SLOService tracks redirect availability and latency against their objectives.
+// Outcomes are kept per minute for the whole SLO window, per instance.
+type SLOService struct {
+	availabilityObjective float64
+	latencyObjective      float64
+	latencyThreshold      time.Duration
+
+	mu      sync.Mutex
+	buckets []sloBucket
+	now     func() time.Time
+}
+
+func NewSLOService(availabilityObjective, latencyObjective float64, latencyThreshold time.Duration) *SLOService {
+	return &SLOService{
+		availabilityObjective: availabilityObjective,
+		latencyObjective:      latencyObjective,
+		latencyThreshold:      latencyThreshold,
+		buckets:               make([]sloBucket, int(sloWindow/time.Minute)),
+		now:                   time.Now,
+	}
+}
+
+// Record counts one redirect. Server errors burn the availability budget and responses
+// slower than the latency threshold burn the latency budget.
+func (s *SLOService) Record(duration time.Duration, status int) {
+	minute := s.now().Unix() / 60
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bucket := &s.buckets[minute%int64(len(s.buckets))]
+	if bucket.minute != minute {
+		*bucket = sloBucket{minute: minute}
+	}
+
+	bucket.total++
+	if status >= 500 {
+		bucket.errors++
+	}
+	if duration > s.latencyThreshold {
+		bucket.slow++
+	}
+}
+
+// Outcomes counts the redirects and server errors recorded over the last window
+func (s *SLOService) Outcomes(window time.Duration) (total, errors int64) {
+	currentMinute := s.now().Unix() / 60
+	minutes := int64(window / time.Minute)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, bucket := range s.buckets {
+		age := currentMinute - bucket.minute
+		if bucket.total == 0 || age < 0 || age >= minutes {
+			continue
+		}
+		total += bucket.total
+		errors += bucket.errors
+	}
+	return total, errors
+}
+
+// Status reports burn rates, remaining error budget and the resulting alert level
+func (s *SLOService) Status() *models.SLOStatus {
+	currentMinute := s.now().Unix() / 60
+
+	// Sum outcomes per burn window in one pass over the ring
+	type totals struct{ total, errors, slow int64 }
+	sums := make([]totals, len(burnWindows))
+
+	s.mu.Lock()
+	for _, bucket := range s.buckets {
+		age := currentMinute - bucket.minute
+		if bucket.total == 0 || age < 0 {
+			continue
+		}
+		for i, window := range burnWindows {
+			if age < int64(window.duration/time.Minute) {
+				sums[i].total += bucket.total
+				sums[i].errors += bucket.errors
+				sums[i].slow += bucket.slow
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	availability := models.SLIStatus{
+		Name:      "availability",
+		Objective: s.availabilityObjective,
+		BurnRates: make(map[string]float64, len(burnWindows)),
+	}
+	latency := models.SLIStatus{
+		Name:      "latency",
+		Objective: s.latencyObjective,
+		Threshold: s.latencyThreshold.String(),
+		BurnRates: make(map[string]float64, len(burnWindows)),
+	}
+
+	for i, window := range burnWindows {
+		availability.BurnRates[window.name] = burnRate(sums[i].errors, sums[i].total, s.availabilityObjective)
+		latency.BurnRates[window.name] = burnRate(sums[i].slow, sums[i].total, s.latencyObjective)
+	}
+
+	month := sums[len(sums)-1]
+	availability.Compliance = compliance(month.errors, month.total)
+	availability.ErrorBudgetRemaining = 1 - availability.BurnRates["30d"]
+	availability.Alert = alertLevel(availability.BurnRates)
+
+	latency.Compliance = compliance(month.slow, month.total)
+	latency.ErrorBudgetRemaining = 1 - latency.BurnRates["30d"]
+	latency.Alert = alertLevel(latency.BurnRates)
+
+	return &models.SLOStatus{
+		Window:    "30d",
+		Redirects: month.total,
+		SLIs:      []models.SLIStatus{availability, latency},
+	}
+}
+
+// burnRate is how fast the error budget is consumed; 1 spends exactly the budget over the SLO window
+func burnRate(bad, total int64, objective float64) float64 {
+	if total == 0 || objective >= 1 {
+		return 0
+	}
+	return (float64(bad) / float64(total)) / (1 - objective)
+}
+
+// compliance is the ratio of good events, 1 when there was no traffic
+func compliance(bad, total int64) float64 {
+	if total == 0 {
+		return 1
+	}
+	return 1 - float64(bad)/float64(total)
+}
+
+// alertLevel applies the multi-window, multi-burn-rate policy: page on fast burns
+// confirmed by a short window, open a ticket on slow sustained burns
+func alertLevel(rates map[string]float64) string {
+	switch {
+	case rates["1h"] > 14.4 && rates["5m"] > 14.4,
+		rates["6h"] > 6 && rates["30m"] > 6:
+		return AlertPage
+	case rates["1d"] > 3 && rates["2h"] > 3,
+		rates["3d"] > 1 && rates["6h"] > 1:
+		return AlertTicket
+	default:
+		return AlertNone
+	}
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSLOBurnRates(t *testing.T) {
+	now := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	service := NewSLOService(0.999, 0.99, 100*time.Millisecond)
+	service.now = func() time.Time { return now }
+
+	// 1000 redirects in the current minute: 20 server errors and 50 slow responses
+	for i := 0; i < 1000; i++ {
+		status := http.StatusMovedPermanently
+		if i < 20 {
+			status = http.StatusInternalServerError
+		}
+		duration := 10 * time.Millisecond
+		if i >= 950 {
+			duration = 250 * time.Millisecond
+		}
+		service.Record(duration, status)
+	}
+
+	status := service.Status()
+	if status.Redirects != 1000 {
+		t.Fatalf("Expected 1000 redirects, got %d", status.Redirects)
+	}
+
+	availability, latency := status.SLIs[0], status.SLIs[1]
+
+	// 2% errors against a 0.1% budget burns 20x
+	if rate := availability.BurnRates["5m"]; rate < 19.99 || rate > 20.01 {
+		t.Errorf("Expected availability burn rate 20, got %f", rate)
+	}
+	if availability.Alert != AlertPage {
+		t.Errorf("Expected availability alert %q, got %q", AlertPage, availability.Alert)
+	}
+
+	// 5% slow against a 1% budget burns 5x: sustained but not page-worthy
+	if rate := latency.BurnRates["1h"]; rate < 4.99 || rate > 5.01 {
+		t.Errorf("Expected latency burn rate 5, got %f", rate)
+	}
+	if latency.Alert != AlertTicket {
+		t.Errorf("Expected latency alert %q, got %q", AlertTicket, latency.Alert)
+	}
+}
+
+func TestSLOIgnoresExpiredBuckets(t *testing.T) {
+	now := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	service := NewSLOService(0.999, 0.99, 100*time.Millisecond)
+	service.now = func() time.Time { return now }
+
+	service.Record(time.Millisecond, http.StatusInternalServerError)
+
+	// Two hours later the error is outside the short windows
+	now = now.Add(2 * time.Hour)
+	status := service.Status()
+	if rate := status.SLIs[0].BurnRates["1h"]; rate != 0 {
+		t.Errorf("Expected no 1h burn after two hours, got %f", rate)
+	}
+	if rate := status.SLIs[0].BurnRates["6h"]; rate == 0 {
+		t.Error("Expected the error to still count in the 6h window")
+	}
+}
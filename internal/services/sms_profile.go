@@ -0,0 +1,64 @@
+package services
+
+import (
+	apperrors "github.com/alexnthnz/url-shortener/internal/errors"
+	"github.com/alexnthnz/url-shortener/internal/models"
+)
+
+// Shorten request profiles
+const (
+	ProfileDefault = ""
+	// ProfileSMS keeps the short URL within the SMS length limit and its code to letters,
+	// digits and inner hyphens. Other characters, even ones in the GSM alphabet such as
+	// "_", are altered by some gateways or end the link when phones detect it.
+	ProfileSMS = "sms"
+)
+
+// validateProfile checks the profile of a shorten request and, for the SMS profile, the
+// custom alias it asks for
+func validateProfile(req *models.ShortenRequest, customAlias string) error {
+	switch req.Profile {
+	case ProfileDefault:
+		return nil
+	case ProfileSMS:
+		if req.Ephemeral {
+			return checkSMSLength(ephemeralCodeLength, req.MaxCodeLength)
+		}
+		if customAlias != "" {
+			return checkSMSCode(customAlias, req.MaxCodeLength)
+		}
+		return nil
+	default:
+		return apperrors.Errorf(apperrors.ErrInvalid, "invalid profile: must be empty or %q", ProfileSMS)
+	}
+}
+
+// checkSMSCode returns an error when a code does not suit the SMS profile; maxLength is
+// the room left for the code, 0 for no limit
+func checkSMSCode(code string, maxLength int) error {
+	if !isSMSSafe(code) {
+		return apperrors.Errorf(apperrors.ErrInvalid, "invalid profile: %q may be altered by SMS gateways; use letters, digits and inner hyphens", code)
+	}
+	return checkSMSLength(len(code), maxLength)
+}
+
+func checkSMSLength(length, maxLength int) error {
+	if maxLength > 0 && length > maxLength {
+		return apperrors.Errorf(apperrors.ErrInvalid, "invalid profile: a %d character code makes the short URL too long for SMS; %d characters are left for it", length, maxLength)
+	}
+	return nil
+}
+
+// isSMSSafe reports whether a code only has ASCII letters, digits and hyphens between them
+func isSMSSafe(code string) bool {
+	if code == "" || code[0] == '-' || code[len(code)-1] == '-' {
+		return false
+	}
+	for i := 0; i < len(code); i++ {
+		c := code[i]
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
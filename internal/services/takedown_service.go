@@ -0,0 +1,216 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	apperrors "github.com/alexnthnz/url-shortener/internal/errors"
+	"github.com/alexnthnz/url-shortener/internal/models"
+	"github.com/alexnthnz/url-shortener/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// Takedown reasons
+const (
+	TakedownReasonCopyright = "copyright"
+	TakedownReasonPhishing  = "phishing"
+	TakedownReasonMalware   = "malware"
+	TakedownReasonAbuse     = "abuse"
+	TakedownReasonOther     = "other"
+)
+
+// Takedown statuses
+const (
+	TakedownPending  = "pending"
+	TakedownUpheld   = "upheld"
+	TakedownRejected = "rejected"
+)
+
+const (
+	maxTakedownEvidence    = 10
+	maxTakedownDescription = 10000
+	maxTakedownsListed     = 500
+)
+
+var takedownReasons = map[string]bool{
+	TakedownReasonCopyright: true,
+	TakedownReasonPhishing:  true,
+	TakedownReasonMalware:   true,
+	TakedownReasonAbuse:     true,
+	TakedownReasonOther:     true,
+}
+
+// TakedownService runs the review of legal and abuse complaints against links. Anyone
+// can file a request; an admin upholds it, which keeps the link disabled, or rejects it.
+// With autoDisable the link is disabled as soon as a request is filed.
+type TakedownService struct {
+	repo        *repository.TakedownRepository
+	urls        *URLService
+	webhooks    *WebhookService
+	autoDisable bool
+	logger      *logrus.Logger
+}
+
+func NewTakedownService(repo *repository.TakedownRepository, urls *URLService, webhooks *WebhookService, autoDisable bool, logger *logrus.Logger) *TakedownService {
+	return &TakedownService{
+		repo:        repo,
+		urls:        urls,
+		webhooks:    webhooks,
+		autoDisable: autoDisable,
+		logger:      logger,
+	}
+}
+
+// Submit files a takedown request against a link or one of its aliases
+func (s *TakedownService) Submit(ctx context.Context, req *models.TakedownSubmission) (*models.TakedownRequest, error) {
+	if err := validateTakedown(req); err != nil {
+		return nil, err
+	}
+
+	shortCode, err := s.urls.ResolveShortCode(ctx, NormalizeShortCode(req.ShortCode))
+	if err != nil {
+		return nil, err
+	}
+
+	takedown := &models.TakedownRequest{
+		ShortCode:     shortCode,
+		Reason:        req.Reason,
+		ReporterName:  strings.TrimSpace(req.ReporterName),
+		ReporterEmail: strings.TrimSpace(req.ReporterEmail),
+		Description:   strings.TrimSpace(req.Description),
+		EvidenceURLs:  req.EvidenceURLs,
+	}
+	if takedown.EvidenceURLs == nil {
+		takedown.EvidenceURLs = []string{}
+	}
+	if err := s.repo.Create(takedown); err != nil {
+		return nil, fmt.Errorf("failed to create takedown request: %w", err)
+	}
+	s.logger.Infof("Takedown request %d filed against %s (%s)", takedown.ID, shortCode, takedown.Reason)
+
+	if s.autoDisable {
+		if err := s.urls.SetDisabled(ctx, shortCode, true, "takedown request pending review"); err != nil {
+			// The request is recorded either way; an admin can still act on it
+			s.logger.Errorf("Failed to disable %s for takedown request %d: %v", shortCode, takedown.ID, err)
+		}
+	}
+
+	s.notify("takedown.requested", takedown, s.autoDisable)
+	return takedown, nil
+}
+
+// HasOpenRequest reports whether a link has a pending or upheld takedown request
+func (s *TakedownService) HasOpenRequest(shortCode string) (bool, error) {
+	count, err := s.repo.CountOpen(shortCode, 0, true)
+	if err != nil {
+		return false, fmt.Errorf("failed to count takedown requests: %w", err)
+	}
+	return count > 0, nil
+}
+
+// List returns takedown requests, oldest first, optionally only those with a status
+func (s *TakedownService) List(status string) ([]*models.TakedownRequest, error) {
+	if status != "" && status != TakedownPending && status != TakedownUpheld && status != TakedownRejected {
+		return nil, apperrors.Errorf(apperrors.ErrInvalid, "invalid status: must be %q, %q or %q", TakedownPending, TakedownUpheld, TakedownRejected)
+	}
+
+	takedowns, err := s.repo.List(status, maxTakedownsListed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list takedown requests: %w", err)
+	}
+	return takedowns, nil
+}
+
+// Get returns a takedown request
+func (s *TakedownService) Get(id int64) (*models.TakedownRequest, error) {
+	takedown, err := s.repo.Get(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get takedown request: %w", err)
+	}
+	if takedown == nil {
+		return nil, apperrors.Errorf(apperrors.ErrNotFound, "takedown request not found")
+	}
+	return takedown, nil
+}
+
+// Resolve records the decision on a pending request. Upholding it disables the link;
+// rejecting it enables the link again unless another request still keeps it down.
+func (s *TakedownService) Resolve(ctx context.Context, id int64, status, note, resolvedBy string) (*models.TakedownRequest, error) {
+	if status != TakedownUpheld && status != TakedownRejected {
+		return nil, apperrors.Errorf(apperrors.ErrInvalid, "invalid status: must be %q or %q", TakedownUpheld, TakedownRejected)
+	}
+
+	takedown, err := s.repo.Resolve(id, status, strings.TrimSpace(note), resolvedBy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve takedown request: %w", err)
+	}
+	if takedown == nil {
+		if _, err := s.Get(id); err != nil {
+			return nil, err
+		}
+		return nil, apperrors.Errorf(apperrors.ErrConflict, "takedown request already resolved")
+	}
+
+	disabled := status == TakedownUpheld
+	if disabled {
+		err = s.urls.SetDisabled(ctx, takedown.ShortCode, true, "removed after a "+takedown.Reason+" complaint")
+	} else {
+		var open int64
+		if open, err = s.repo.CountOpen(takedown.ShortCode, takedown.ID, s.autoDisable); err == nil {
+			if open > 0 {
+				disabled = true
+			} else {
+				err = s.urls.SetDisabled(ctx, takedown.ShortCode, false, "")
+			}
+		}
+	}
+	if err != nil {
+		// The decision is recorded; the admin can resolve the link state by hand
+		return nil, fmt.Errorf("failed to update link for takedown request %d: %w", id, err)
+	}
+
+	s.logger.Infof("Takedown request %d against %s %s by %s", takedown.ID, takedown.ShortCode, status, resolvedBy)
+	s.notify("takedown.resolved", takedown, disabled)
+	return takedown, nil
+}
+
+// notify tells webhook subscribers of the link about a takedown request
+func (s *TakedownService) notify(event string, takedown *models.TakedownRequest, disabled bool) {
+	if s.webhooks == nil {
+		return
+	}
+	s.webhooks.NotifyTakedown(models.WebhookTakedownEvent{
+		Event:         event,
+		SchemaVersion: EventSchemaVersion(event),
+		TakedownID:    takedown.ID,
+		ShortCode:     takedown.ShortCode,
+		Reason:        takedown.Reason,
+		Status:        takedown.Status,
+		Disabled:      disabled,
+		OccurredAt:    time.Now().UTC(),
+	})
+}
+
+// validateTakedown checks a submission; the reporter email is validated when binding
+func validateTakedown(req *models.TakedownSubmission) error {
+	if !takedownReasons[req.Reason] {
+		return apperrors.Errorf(apperrors.ErrInvalid, "invalid reason: must be one of copyright, phishing, malware, abuse or other")
+	}
+	description := strings.TrimSpace(req.Description)
+	if description == "" || len(description) > maxTakedownDescription {
+		return apperrors.Errorf(apperrors.ErrInvalid, "invalid description: must be 1 to %d characters", maxTakedownDescription)
+	}
+	if len(req.EvidenceURLs) > maxTakedownEvidence {
+		return apperrors.Errorf(apperrors.ErrInvalid, "invalid evidence: at most %d URLs", maxTakedownEvidence)
+	}
+	for _, evidence := range req.EvidenceURLs {
+		parsed, err := url.Parse(evidence)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return apperrors.Errorf(apperrors.ErrInvalid, "invalid evidence: %q is not an HTTP(S) URL", evidence)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alexnthnz/url-shortener/internal/models"
+)
+
+func TestValidateTakedown(t *testing.T) {
+	valid := func() *models.TakedownSubmission {
+		return &models.TakedownSubmission{
+			ShortCode:     "abc123",
+			Reason:        TakedownReasonCopyright,
+			ReporterEmail: "legal@example.com",
+			Description:   "The destination hosts our copyrighted film.",
+			EvidenceURLs:  []string{"https://example.com/original"},
+		}
+	}
+
+	testCases := []struct {
+		name   string
+		modify func(*models.TakedownSubmission)
+		err    string
+	}{
+		{"valid", func(*models.TakedownSubmission) {}, ""},
+		{"without evidence", func(r *models.TakedownSubmission) { r.EvidenceURLs = nil }, ""},
+		{"unknown reason", func(r *models.TakedownSubmission) { r.Reason = "dislike" }, "invalid reason"},
+		{"blank description", func(r *models.TakedownSubmission) { r.Description = "  " }, "invalid description"},
+		{"long description", func(r *models.TakedownSubmission) { r.Description = strings.Repeat("x", maxTakedownDescription+1) }, "invalid description"},
+		{"evidence not a URL", func(r *models.TakedownSubmission) { r.EvidenceURLs = []string{"see attached"} }, "invalid evidence"},
+		{"evidence not HTTP", func(r *models.TakedownSubmission) { r.EvidenceURLs = []string{"javascript:alert(1)"} }, "invalid evidence"},
+		{"too much evidence", func(r *models.TakedownSubmission) {
+			r.EvidenceURLs = make([]string, maxTakedownEvidence+1)
+			for i := range r.EvidenceURLs {
+				r.EvidenceURLs[i] = "https://example.com/"
+			}
+		}, "invalid evidence"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := valid()
+			tc.modify(req)
+			err := validateTakedown(req)
+			if tc.err == "" {
+				if err != nil {
+					t.Errorf("validateTakedown failed: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("validateTakedown error = %v; expected %q", err, tc.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/alexnthnz/url-shortener/internal/models"
+	"github.com/alexnthnz/url-shortener/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	telemetryCheckInterval = time.Hour
+	telemetryInstanceKey   = "telemetry:instance_id"
+	// telemetryUsageDays is the period the heartbeat's usage counts cover
+	telemetryUsageDays = 7
+)
+
+// TelemetryConfig configures the anonymous usage heartbeat
+type TelemetryConfig struct {
+	Enabled  bool
+	Endpoint string
+	Version  string
+	Features []string // names of enabled optional subsystems
+}
+
+// TelemetryService sends a daily heartbeat to the maintainers' collector. The report is
+// limited to the version, platform, enabled features and usage counts rounded to an
+// order of magnitude; it never contains URLs, short codes, hostnames or visitor data.
+type TelemetryService struct {
+	cfg    TelemetryConfig
+	usage  *UsageService
+	cache  repository.Cache
+	client *http.Client
+	logger *logrus.Logger
+}
+
+func NewTelemetryService(cfg TelemetryConfig, usage *UsageService, cache repository.Cache, logger *logrus.Logger) *TelemetryService {
+	service := &TelemetryService{
+		cfg:    cfg,
+		usage:  usage,
+		cache:  cache,
+		client: &http.Client{Timeout: 10 * time.Second},
+		logger: logger,
+	}
+
+	if service.Sending() {
+		logger.Infof("Sending an anonymous usage heartbeat to %s once a day; set TELEMETRY_ENABLED=false to stop", cfg.Endpoint)
+		go service.schedule()
+	}
+
+	return service
+}
+
+// Sending reports whether heartbeats are sent
+func (s *TelemetryService) Sending() bool {
+	return s.cfg.Enabled && s.cfg.Endpoint != ""
+}
+
+// Endpoint returns the collector heartbeats are sent to
+func (s *TelemetryService) Endpoint() string {
+	return s.cfg.Endpoint
+}
+
+// Report builds the heartbeat exactly as it would be sent
+func (s *TelemetryService) Report(ctx context.Context) (*models.TelemetryReport, error) {
+	instanceID, err := s.instanceID(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get instance id: %w", err)
+	}
+
+	usage, err := s.usage.GetUsageReport(ctx, telemetryUsageDays)
+	if err != nil {
+		return nil, err
+	}
+
+	features := s.cfg.Features
+	if features == nil {
+		features = []string{}
+	}
+
+	return &models.TelemetryReport{
+		InstanceID:   instanceID,
+		Version:      s.cfg.Version,
+		GoVersion:    runtime.Version(),
+		OS:           runtime.GOOS,
+		Arch:         runtime.GOARCH,
+		Features:     features,
+		LinksCreated: TelemetryBucket(usage.LinksCreated),
+		Redirects:    TelemetryBucket(usage.Redirects),
+	}, nil
+}
+
+// TelemetryBucket rounds a count down to its order of magnitude, e.g. 1234 to "1000-9999"
+func TelemetryBucket(count int64) string {
+	if count <= 0 {
+		return "0"
+	}
+
+	lower := int64(1)
+	for lower <= count/10 {
+		lower *= 10
+	}
+	if lower >= 1_000_000_000 {
+		return strconv.FormatInt(lower, 10) + "+"
+	}
+	return strconv.FormatInt(lower, 10) + "-" + strconv.FormatInt(lower*10-1, 10)
+}
+
+// instanceID returns a random id shared by all instances of this deployment, so the
+// collector can count deployments without learning anything about them
+func (s *TelemetryService) instanceID(ctx context.Context) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	if _, err := s.cache.SetNX(ctx, telemetryInstanceKey, hex.EncodeToString(buf), 0); err != nil {
+		return "", err
+	}
+	return s.cache.Get(ctx, telemetryInstanceKey)
+}
+
+// schedule sends one heartbeat per day across all instances
+func (s *TelemetryService) schedule() {
+	ctx := context.Background()
+	ticker := time.NewTicker(telemetryCheckInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		// Only one instance sends each day's heartbeat
+		lockKey := "lock:telemetry:" + time.Now().UTC().Format(usageDateLayout)
+		acquired, err := s.cache.SetNX(ctx, lockKey, "1", 25*time.Hour)
+		if err != nil || !acquired {
+			continue
+		}
+
+		// Heartbeats are best effort and never retried
+		if err := s.send(ctx); err != nil {
+			s.logger.Debugf("Failed to send usage heartbeat: %v", err)
+		}
+	}
+}
+
+func (s *TelemetryService) send(ctx context.Context) error {
+	report, err := s.Report(ctx)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "url-shortener/"+s.cfg.Version)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("collector returned status %d", resp.StatusCode)
+	}
+	return nil
+}
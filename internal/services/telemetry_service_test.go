@@ -0,0 +1,25 @@
+package services
+
+import "testing"
+
+func TestTelemetryBucket(t *testing.T) {
+	tests := []struct {
+		count    int64
+		expected string
+	}{
+		{0, "0"},
+		{-5, "0"},
+		{1, "1-9"},
+		{9, "1-9"},
+		{10, "10-99"},
+		{1234, "1000-9999"},
+		{99999, "10000-99999"},
+		{2_500_000_000, "1000000000+"},
+	}
+
+	for _, tt := range tests {
+		if got := TelemetryBucket(tt.count); got != tt.expected {
+			t.Errorf("TelemetryBucket(%d) = %q, expected %q", tt.count, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/alexnthnz/url-shortener/internal/models"
+	"github.com/alexnthnz/url-shortener/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+// trendingEpochHalfLives is the length of a leaderboard epoch in half-lives. Click weights
+// double every half-life within an epoch, so they stay below 2^32.
+const trendingEpochHalfLives = 32
+
+// TrendingService keeps a leaderboard of the links trending right now in a Redis sorted
+// set fed by the analytics pipeline. Every click adds to its link's score and scores
+// halve every half-life. Rather than decaying every score over time, each click is
+// weighted by 2^(t/halfLife), which keeps the order of the set correct with one ZINCRBY
+// per link and batch; reads divide the weight out again. The weights grow without bound,
+// so each epoch of 32 half-lives starts a new set with the previous one's scores scaled down.
+// Bot and internal clicks are left out.
+type TrendingService struct {
+	cache    repository.Cache
+	halfLife time.Duration // 0 disables the leaderboard
+	maxLinks int           // links kept in the set, beyond those returned
+	logger   *logrus.Logger
+}
+
+func NewTrendingService(cache repository.Cache, halfLife time.Duration, maxLinks int, logger *logrus.Logger) *TrendingService {
+	return &TrendingService{
+		cache:    cache,
+		halfLife: halfLife,
+		maxLinks: maxLinks,
+		logger:   logger,
+	}
+}
+
+// Enabled reports whether clicks are counted
+func (s *TrendingService) Enabled() bool {
+	return s != nil && s.halfLife > 0 && s.maxLinks > 0
+}
+
+// HalfLife returns the time after which a click counts half
+func (s *TrendingService) HalfLife() time.Duration {
+	return s.halfLife
+}
+
+// Record counts recorded clicks towards their links' scores
+func (s *TrendingService) Record(clicks []*models.Analytics) {
+	ctx := context.Background()
+	if !s.Enabled() {
+		return
+	}
+
+	epoch, weight := s.epoch(time.Now())
+	increments := make(map[string]float64)
+	for _, click := range clicks {
+		if !click.IsBot && !click.IsInternal {
+			increments[click.ShortCode] += weight
+		}
+	}
+	if len(increments) == 0 {
+		return
+	}
+
+	s.rollOver(epoch)
+	if err := s.cache.ZIncrBatch(ctx, trendingKey(epoch), increments, int64(s.maxLinks), s.keyTTL()); err != nil {
+		s.logger.Warnf("Failed to update trending links: %v", err)
+	}
+}
+
+// Top returns the limit links with the highest current scores, a score being the number
+// of clicks with each weighted by its age
+func (s *TrendingService) Top(ctx context.Context, limit int) ([]models.TrendingLink, error) {
+	links := []models.TrendingLink{}
+	if !s.Enabled() {
+		return links, nil
+	}
+
+	epoch, weight := s.epoch(time.Now())
+	s.rollOver(epoch)
+	members, err := s.cache.ZTop(ctx, trendingKey(epoch), int64(limit))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get trending links: %w", err)
+	}
+
+	for _, member := range members {
+		links = append(links, models.TrendingLink{
+			ShortCode: member.Member,
+			Score:     math.Round(member.Score/weight*100) / 100,
+		})
+	}
+	return links, nil
+}
+
+// Remove takes a deleted link off the leaderboard
+func (s *TrendingService) Remove(ctx context.Context, shortCode string) {
+	if !s.Enabled() {
+		return
+	}
+
+	epoch, _ := s.epoch(time.Now())
+	if err := s.cache.ZRem(ctx, shortCode, trendingKey(epoch), trendingKey(epoch-1)); err != nil {
+		s.logger.Warnf("Failed to remove %s from trending links: %v", shortCode, err)
+	}
+}
+
+// epoch returns the leaderboard epoch of a time and the weight of a click at that time
+func (s *TrendingService) epoch(at time.Time) (int64, float64) {
+	epochLength := int64(s.halfLife) * trendingEpochHalfLives
+	epoch := at.UnixNano() / epochLength
+	elapsed := at.UnixNano() - epoch*epochLength
+	return epoch, math.Exp2(float64(elapsed) / float64(s.halfLife))
+}
+
+// rollOver carries the scores of the previous epoch into a new one, once across all
+// instances. At the boundary a weight of 2^32 becomes 1.
+func (s *TrendingService) rollOver(epoch int64) {
+	ctx := context.Background()
+	started, err := s.cache.SetNX(ctx, fmt.Sprintf("lock:trending_rollover:%d", epoch), "1", s.keyTTL())
+	if err != nil || !started {
+		return
+	}
+	if err := s.cache.ZMergeInto(ctx, trendingKey(epoch), trendingKey(epoch-1), math.Exp2(-trendingEpochHalfLives), s.keyTTL()); err != nil {
+		s.logger.Warnf("Failed to roll trending links over: %v", err)
+	}
+}
+
+// keyTTL keeps an epoch's set until the next epoch has taken its scores over
+func (s *TrendingService) keyTTL() time.Duration {
+	return 2 * trendingEpochHalfLives * s.halfLife
+}
+
+// trendingKey is the cache key of an epoch's leaderboard
+func trendingKey(epoch int64) string {
+	return fmt.Sprintf("trending:%d", epoch)
+}
@@ -0,0 +1,30 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTrendingEpoch(t *testing.T) {
+	service := &TrendingService{halfLife: time.Hour, maxLinks: 100}
+	start := time.Unix(0, 0).Add(5 * trendingEpochHalfLives * time.Hour)
+
+	testCases := []struct {
+		name   string
+		at     time.Time
+		epoch  int64
+		weight float64
+	}{
+		{"epoch start", start, 5, 1},
+		{"one half-life in", start.Add(time.Hour), 5, 2},
+		{"three half-lives in", start.Add(3 * time.Hour), 5, 8},
+		{"next epoch", start.Add(trendingEpochHalfLives * time.Hour), 6, 1},
+	}
+
+	for _, tc := range testCases {
+		epoch, weight := service.epoch(tc.at)
+		if epoch != tc.epoch || weight != tc.weight {
+			t.Errorf("%s: epoch() = %d, %g; expected %d, %g", tc.name, epoch, weight, tc.epoch, tc.weight)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alexnthnz/url-shortener/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// UpdateService periodically checks the latest release published on GitHub so
+// self-hosters learn about new versions from the health and version endpoints
+type UpdateService struct {
+	current  string
+	url      string
+	interval time.Duration
+	client   *http.Client
+	logger   *logrus.Logger
+
+	mu     sync.RWMutex
+	status models.UpdateStatus
+}
+
+func NewUpdateService(current, releasesURL string, enabled bool, interval time.Duration, logger *logrus.Logger) *UpdateService {
+	service := &UpdateService{
+		current:  current,
+		url:      releasesURL,
+		interval: interval,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		logger:   logger,
+		status:   models.UpdateStatus{Enabled: enabled && releasesURL != "" && interval > 0},
+	}
+
+	if service.status.Enabled {
+		go service.schedule()
+	}
+
+	return service
+}
+
+// Status returns the result of the last update check
+func (s *UpdateService) Status() models.UpdateStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.status
+}
+
+func (s *UpdateService) schedule() {
+	s.check()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.check()
+	}
+}
+
+// check fetches the latest release and records whether it is newer than the running version
+func (s *UpdateService) check() {
+	latest, releaseURL, err := s.fetchLatest()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC()
+	s.status.CheckedAt = &now
+	if err != nil {
+		s.status.LastError = err.Error()
+		s.logger.Debugf("Update check failed: %v", err)
+		return
+	}
+
+	s.status.LastError = ""
+	s.status.LatestVersion = latest
+	s.status.ReleaseURL = releaseURL
+	s.status.UpdateAvailable = NewerVersion(s.current, latest)
+	if s.status.UpdateAvailable {
+		s.logger.Infof("A newer version is available: %s (running %s)", latest, s.current)
+	}
+}
+
+func (s *UpdateService) fetchLatest() (string, string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return "", "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("User-Agent", "url-shortener/"+s.current)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("releases endpoint returned status %d", resp.StatusCode)
+	}
+
+	var release struct {
+		TagName string `json:"tag_name"`
+		HTMLURL string `json:"html_url"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&release); err != nil {
+		return "", "", fmt.Errorf("failed to decode release: %w", err)
+	}
+	if release.TagName == "" {
+		return "", "", fmt.Errorf("release has no tag")
+	}
+	return release.TagName, release.HTMLURL, nil
+}
+
+// NewerVersion reports whether latest is a higher semantic version than current.
+// Versions that do not parse, such as development builds, never report an update.
+func NewerVersion(current, latest string) bool {
+	currentParts, ok := parseVersion(current)
+	if !ok {
+		return false
+	}
+	latestParts, ok := parseVersion(latest)
+	if !ok {
+		return false
+	}
+
+	for i := range currentParts {
+		if latestParts[i] != currentParts[i] {
+			return latestParts[i] > currentParts[i]
+		}
+	}
+	// A release supersedes its own pre-releases
+	return isPrerelease(current) && !isPrerelease(latest)
+}
+
+func isPrerelease(version string) bool {
+	version, _, _ = strings.Cut(version, "+")
+	return strings.Contains(version, "-")
+}
+
+// parseVersion parses "v1.2.3" or "1.2", ignoring pre-release and build suffixes
+func parseVersion(version string) ([3]int, bool) {
+	var parts [3]int
+
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if i := strings.IndexAny(version, "-+"); i >= 0 {
+		version = version[:i]
+	}
+	fields := strings.Split(version, ".")
+	if len(fields) > 3 {
+		return parts, false
+	}
+	for i, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return parts, false
+		}
+		parts[i] = n
+	}
+	return parts, true
+}
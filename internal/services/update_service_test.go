@@ -0,0 +1,27 @@
+package services
+
+import "testing"
+
+func TestNewerVersion(t *testing.T) {
+	tests := []struct {
+		current  string
+		latest   string
+		expected bool
+	}{
+		{"1.0.0", "v1.0.1", true},
+		{"v1.2.0", "v1.10.0", true},
+		{"1.2.3", "2.0", true},
+		{"1.2.3", "1.2.3", false},
+		{"1.3.0", "v1.2.9", false},
+		{"1.2.3-rc.1", "1.2.3", true},
+		{"1.2.3", "1.2.3-rc.1", false},
+		{"dev", "v9.9.9", false},
+		{"1.0.0", "nightly", false},
+	}
+
+	for _, tt := range tests {
+		if got := NewerVersion(tt.current, tt.latest); got != tt.expected {
+			t.Errorf("NewerVersion(%q, %q) = %v, expected %v", tt.current, tt.latest, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// hostProfile applies UTS #46 lookup mapping like browsers do, but accepts the underscores
+// and double hyphens that real host names sometimes contain
+var hostProfile = idna.New(
+	idna.MapForLookup(),
+	idna.BidiRule(),
+	idna.Transitional(false),
+	idna.StrictDomainName(false),
+	idna.CheckHyphens(false),
+)
+
+// CanonicalHost returns the form of a host name used for comparisons: lowercase ASCII with
+// internationalized labels in punycode and no trailing dot. UTS #46 mapping folds case,
+// full-width characters and other compatibility forms, so "ＥＸＡＭＰＬＥ.com", "Example.COM."
+// and "example.com" compare equal, and a Unicode domain matches its xn-- form. Redirects
+// keep the host as the user wrote it.
+func CanonicalHost(host string) (string, error) {
+	host = strings.TrimSuffix(strings.TrimSpace(host), ".")
+	if host == "" {
+		return "", fmt.Errorf("empty host")
+	}
+	if ip := net.ParseIP(strings.Trim(host, "[]")); ip != nil {
+		return ip.String(), nil
+	}
+
+	canonical, err := hostProfile.ToASCII(host)
+	if err != nil {
+		return "", fmt.Errorf("invalid host name: %w", err)
+	}
+	return canonical, nil
+}
+
+// CanonicalDomains converts a configured domain list to canonical hosts, skipping blank
+// and invalid entries
+func CanonicalDomains(domains []string) []string {
+	canonical := make([]string, 0, len(domains))
+	for _, domain := range domains {
+		if host, err := CanonicalHost(strings.Trim(domain, ". ")); err == nil {
+			canonical = append(canonical, host)
+		}
+	}
+	return canonical
+}
+
+// MatchDomain returns the domain of a canonical list that a host falls under, itself or as
+// a subdomain, or "" when none does
+func MatchDomain(host string, domains []string) string {
+	host, err := CanonicalHost(host)
+	if err != nil {
+		return ""
+	}
+	for _, domain := range domains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return domain
+		}
+	}
+	return ""
+}
@@ -0,0 +1,57 @@
+package services
+
+import "testing"
+
+func TestCanonicalHost(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"Example.COM.", "example.com"},
+		{"ＥＸＡＭＰＬＥ.com", "example.com"},
+		{"bücher.example", "xn--bcher-kva.example"},
+		{"XN--BCHER-KVA.example", "xn--bcher-kva.example"},
+		{"аррӏе.com", "xn--80ak6aa92e.com"},
+		{"straße.de", "xn--strae-oqa.de"},
+		{"my_site.example.com", "my_site.example.com"},
+		{"r3---sn-abc.example.com", "r3---sn-abc.example.com"},
+		{"[2001:DB8::1]", "2001:db8::1"},
+	}
+
+	for _, tc := range testCases {
+		got, err := CanonicalHost(tc.input)
+		if err != nil {
+			t.Errorf("CanonicalHost(%q) failed: %v", tc.input, err)
+			continue
+		}
+		if got != tc.expected {
+			t.Errorf("CanonicalHost(%q) = %q; expected %q", tc.input, got, tc.expected)
+		}
+	}
+
+	if _, err := CanonicalHost("xn--zz.com"); err == nil {
+		t.Error("Expected invalid punycode to be rejected")
+	}
+}
+
+func TestMatchDomain(t *testing.T) {
+	domains := CanonicalDomains([]string{"xn--80ak6aa92e.com", " Bücher.example. ", ""})
+
+	testCases := []struct {
+		host     string
+		expected string
+	}{
+		{"аррӏе.com", "xn--80ak6aa92e.com"},
+		{"login.аррӏе.COM", "xn--80ak6aa92e.com"},
+		{"bücher.example", "xn--bcher-kva.example"},
+		{"shop.xn--bcher-kva.example", "xn--bcher-kva.example"},
+		{"apple.com", ""},
+		{"notbücher.example", ""},
+	}
+
+	for _, tc := range testCases {
+		if got := MatchDomain(tc.host, domains); got != tc.expected {
+			t.Errorf("MatchDomain(%q) = %q; expected %q", tc.host, got, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/alexnthnz/url-shortener/internal/models"
+)
+
+// NormalizeOptions are the URL normalization rules applied to destinations
+type NormalizeOptions struct {
+	ForceHTTPS         bool // upgrade http:// destinations to https://
+	StripTrailingSlash bool
+	StripFragment      bool
+	LowercaseHost      bool
+	SortQuery          bool // order query parameters by name
+}
+
+// With returns the options with the rules set in a request applied on top
+func (o NormalizeOptions) With(rules *models.NormalizeRules) NormalizeOptions {
+	if rules == nil {
+		return o
+	}
+
+	for _, rule := range []struct {
+		value *bool
+		into  *bool
+	}{
+		{rules.ForceHTTPS, &o.ForceHTTPS},
+		{rules.StripTrailingSlash, &o.StripTrailingSlash},
+		{rules.StripFragment, &o.StripFragment},
+		{rules.LowercaseHost, &o.LowercaseHost},
+		{rules.SortQuery, &o.SortQuery},
+	} {
+		if rule.value != nil {
+			*rule.into = *rule.value
+		}
+	}
+	return o
+}
+
+// normalizeURL normalizes the URL format according to the enabled rules
+func normalizeURL(rawURL string, opts NormalizeOptions) string {
+	rawURL, fragment, hasFragment := splitFragment(rawURL)
+	parsedURL, _ := url.Parse(rawURL)
+
+	// Ensure scheme is present
+	if parsedURL.Scheme == "" {
+		parsedURL.Scheme = "https"
+	}
+	if opts.ForceHTTPS && parsedURL.Scheme == "http" {
+		parsedURL.Scheme = "https"
+	}
+
+	if opts.LowercaseHost {
+		parsedURL.Host = strings.ToLower(parsedURL.Host)
+	}
+
+	if opts.StripTrailingSlash {
+		parsedURL.Path = strings.TrimSuffix(parsedURL.Path, "/")
+		parsedURL.RawPath = strings.TrimSuffix(parsedURL.RawPath, "/")
+	}
+
+	if opts.StripFragment {
+		hasFragment = false
+	}
+
+	if opts.SortQuery && parsedURL.RawQuery != "" {
+		// Sort the raw pairs instead of re-encoding them, which would escape the braces
+		// of destination templates
+		pairs := strings.Split(parsedURL.RawQuery, "&")
+		sort.SliceStable(pairs, func(i, j int) bool {
+			return queryKey(pairs[i]) < queryKey(pairs[j])
+		})
+		parsedURL.RawQuery = strings.Join(pairs, "&")
+	}
+
+	return joinFragment(parsedURL.String(), fragment, hasFragment)
+}
+
+// splitFragment cuts the fragment off a URL. Fragments are only read by the browser, often
+// by single-page apps routing on "#/path?query" or even nested "#", so they are carried
+// through verbatim instead of being parsed and re-encoded by net/url.
+func splitFragment(rawURL string) (string, string, bool) {
+	return strings.Cut(rawURL, "#")
+}
+
+// joinFragment appends a fragment cut off by splitFragment. Only bytes that cannot appear
+// in a Location header (controls, spaces and non-ASCII) are percent-encoded, as browsers
+// would do themselves.
+func joinFragment(base, fragment string, hasFragment bool) string {
+	if !hasFragment || fragment == "" {
+		return base
+	}
+
+	var b strings.Builder
+	b.Grow(len(base) + 1 + len(fragment))
+	b.WriteString(base)
+	b.WriteByte('#')
+	for i := 0; i < len(fragment); i++ {
+		if c := fragment[i]; c <= ' ' || c >= 0x7f {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+func queryKey(pair string) string {
+	key, _, _ := strings.Cut(pair, "=")
+	return key
+}
@@ -1,131 +1,1324 @@
 package services
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math"
+	"math/rand"
 	"net/url"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 
+	apperrors "github.com/alexnthnz/url-shortener/internal/errors"
 	"github.com/alexnthnz/url-shortener/internal/models"
 	"github.com/alexnthnz/url-shortener/internal/repository"
-	"github.com/go-redis/redis/v8"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
 )
 
 const base62Chars = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
 
+// checksumModulus is prime so every single-character typo and adjacent swap in the code
+// changes the checksum, except substituting 0 for Z or back (the only digits 61 apart)
+const checksumModulus = 61
+
+const (
+	CodeStyleDefault       = ""
+	CodeStylePronounceable = "pronounceable"
+	CodeStyleWords         = "words"
+)
+
+// Pronounceable codes are lowercase consonant-vowel syllables meant to be read aloud;
+// letters that are easily misheard or misspelled (c, q, w, x, y) are left out
+const (
+	pronounceableConsonants = "bdfghjklmnprstvz"
+	pronounceableVowels     = "aeiou"
+
+	// 3 syllables give 512,000 codes; each retry length adds a factor of 80, up to the
+	// 10 characters a short code can hold
+	pronounceableSyllables         = 3
+	pronounceableAttemptsPerLength = 5
+)
+
+// styledCodeAttempts caps the tries at a free pronounceable or word code
+const styledCodeAttempts = 15
+
+// Random codes are retried on collision, growing by a character every few attempts up to
+// the 10 characters a short code can hold
+const (
+	minRandomCodeLength         = 4
+	maxShortCodeLength          = 10
+	randomCodeAttempts          = 10
+	randomCodeAttemptsPerLength = 3
+)
+
+// maxHistoryEntries caps the destination changes returned for one link
+const maxHistoryEntries = 100
+
+// urlCacheTTL is how long a link's destination stays cached
+const urlCacheTTL = 24 * time.Hour
+
+// maxSuggestions caps the "did you mean" candidates looked up for a mistyped code
+const maxSuggestions = 20
+
 type URLService struct {
-	urlRepo *repository.URLRepository
-	cache   *repository.RedisCache
-	logger  *logrus.Logger
+	urlRepo     repository.URLStore
+	aliasRepo   *repository.AliasRepository
+	cache       repository.Cache
+	notFoundTTL time.Duration // how long a missing code is remembered; 0 always asks the database
+	// earlyRefreshBeta scales how early hot links are refreshed before their cache entry
+	// expires; 0 refreshes only on expiry
+	earlyRefreshBeta float64
+	lookups          singleflight.Group
+	usage            *UsageService
+	checksumDigit    bool
+	// randomCodeLength switches generated codes from the encoded counter, which can be
+	// enumerated, to random codes of this length; 0 keeps counter codes
+	randomCodeLength int
+	wordCodes        *WordCodeGenerator
+	// deterministicCodes derives codes from the destination for deterministic requests;
+	// nil rejects them
+	deterministicCodes *DeterministicCodeGenerator
+	codeStats          codeGenerationStats
+	emojiAliases       bool
+	deduplicate        bool             // instance default for returning existing links, overridable per request
+	normalize          NormalizeOptions // instance defaults, overridable per request
+	blocked            []string         // canonical domains that may not be shortened
+	policies           *DomainPolicyService
+	safeBrowsing       *SafeBrowsingService
+	stream             *EventStreamService // optional, publishes link lifecycle events
+	canary             *CanaryService      // gates features rolled out to the canary cohort first
+	validators         []LinkValidator
+	logger             *logrus.Logger
+
+	// Click counts of capped links seen since the last sync to the database
+	clickCountsMu sync.Mutex
+	clickCounts   map[string]int64
+}
+
+func NewURLService(urlRepo repository.URLStore, aliasRepo *repository.AliasRepository, cache repository.Cache, notFoundTTL time.Duration, earlyRefreshBeta float64, usage *UsageService, checksumDigit bool, randomCodeLength int, wordCodes *WordCodeGenerator, deterministicCodes *DeterministicCodeGenerator, emojiAliases, deduplicate bool, normalize NormalizeOptions, blockedDomains []string, policies *DomainPolicyService, safeBrowsing *SafeBrowsingService, stream *EventStreamService, canary *CanaryService, logger *logrus.Logger) *URLService {
+	service := &URLService{
+		urlRepo:            urlRepo,
+		aliasRepo:          aliasRepo,
+		cache:              cache,
+		notFoundTTL:        notFoundTTL,
+		earlyRefreshBeta:   earlyRefreshBeta,
+		usage:              usage,
+		checksumDigit:      checksumDigit,
+		randomCodeLength:   randomCodeLength,
+		wordCodes:          wordCodes,
+		deterministicCodes: deterministicCodes,
+		emojiAliases:       emojiAliases,
+		deduplicate:        deduplicate,
+		normalize:          normalize,
+		blocked:            CanonicalDomains(blockedDomains),
+		policies:           policies,
+		safeBrowsing:       safeBrowsing,
+		stream:             stream,
+		canary:             canary,
+		logger:             logger,
+	}
+
+	// Start periodic sync of click cap counters
+	go service.syncClickCountsLoop()
+
+	return service
+}
+
+// ShortenURL creates a short URL from a long URL. The code style selects how the code
+// is generated when no custom alias is given.
+func (s *URLService) ShortenURL(ctx context.Context, req *models.ShortenRequest) (*models.URL, error) {
+	urlRecord, threat, err := s.prepareShorten(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	shortCode, normalizedURL := urlRecord.ShortCode, urlRecord.OriginalURL
+
+	existing, err := s.findReusable(ctx, req, urlRecord)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		s.flagThreat(ctx, existing.ShortCode, normalizedURL, threat)
+		return existing, nil
+	}
+
+	if urlRecord.Ephemeral {
+		if err := s.createEphemeral(ctx, urlRecord, ephemeralTTL(req)); err != nil {
+			return nil, err
+		}
+		s.stream.PublishLink(LinkCreated, urlRecord.ShortCode, normalizedURL, "")
+		return urlRecord, nil
+	}
+
+	// Deterministic codes are looked up before an id is drawn, since repeated requests
+	// mostly return the link the first one created
+	if req.Deterministic {
+		code, existing, err := s.deterministicCode(ctx, normalizedURL)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			s.flagThreat(ctx, existing.ShortCode, normalizedURL, threat)
+			return existing, nil
+		}
+		shortCode = code
+		urlRecord.CodeStrategy = CodeStrategyDeterministic
+	}
+
+	// Every link also gets a numeric code from the code sequence: the id its generated
+	// code encodes, or a fresh one for custom aliases, random, pronounceable and word codes
+	var numericID int64
+	if shortCode == "" && req.CodeStyle == CodeStyleDefault && s.randomCodeLength == 0 {
+		// Without a custom alias, generate short code using counter-based approach,
+		// skipping codes already taken by custom aliases or link aliases
+		for {
+			nextID, err := s.urlRepo.GetNextID(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get next ID: %w", err)
+			}
+			shortCode = s.encodeBase62(nextID)
+			if s.checksumDigit {
+				shortCode += string(checksumChar(shortCode))
+			}
+
+			taken, err := s.urlRepo.Exists(ctx, shortCode)
+			if err != nil {
+				return nil, fmt.Errorf("failed to check code existence: %w", err)
+			}
+			s.codeStats.record(CodeStrategySequential, taken)
+			if !taken {
+				numericID = nextID
+				break
+			}
+		}
+		if req.Profile == ProfileSMS {
+			if err := checkSMSLength(len(shortCode), req.MaxCodeLength); err != nil {
+				return nil, err
+			}
+		}
+		urlRecord.CodeStrategy = CodeStrategySequential
+	}
+	if numericID == 0 {
+		nextID, err := s.urlRepo.GetNextID(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get next ID: %w", err)
+		}
+		numericID = nextID
+	}
+	urlRecord.NumericCode = s.numericCode(numericID)
+
+	urlRecord.ShortCode = shortCode
+	if shortCode == "" && req.CodeStyle == CodeStyleDefault {
+		if err := s.createWithRandomCode(ctx, urlRecord, req); err != nil {
+			return nil, err
+		}
+		shortCode = urlRecord.ShortCode
+	} else if shortCode == "" {
+		// Random pronounceable and word codes can collide, so retry with a fresh code on a
+		// unique violation
+		urlRecord.CodeStrategy = req.CodeStyle
+		for attempt := 0; ; attempt++ {
+			urlRecord.ShortCode = s.styledCode(req.CodeStyle, attempt)
+			if req.Profile == ProfileSMS {
+				if err := checkSMSLength(len(urlRecord.ShortCode), req.MaxCodeLength); err != nil {
+					return nil, err
+				}
+			}
+			err := s.urlRepo.Create(ctx, urlRecord)
+			duplicate := errors.Is(err, repository.ErrDuplicate)
+			s.codeStats.record(req.CodeStyle, duplicate)
+			if err == nil {
+				break
+			}
+			if !duplicate || attempt+1 >= styledCodeAttempts {
+				return nil, fmt.Errorf("failed to create URL: %w", err)
+			}
+		}
+		shortCode = urlRecord.ShortCode
+	} else if req.Deterministic {
+		existing, err := s.createDeterministic(ctx, urlRecord)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			s.flagThreat(ctx, existing.ShortCode, normalizedURL, threat)
+			return existing, nil
+		}
+		shortCode = urlRecord.ShortCode
+	} else if err := s.urlRepo.Create(ctx, urlRecord); err != nil {
+		return nil, fmt.Errorf("failed to create URL: %w", err)
+	}
+	s.forgetNotFound(ctx, shortCode)
+	if urlRecord.UTM != nil {
+		if err := s.urlRepo.SetUTMParams(ctx, shortCode, urlRecord.UTM); err != nil {
+			return nil, fmt.Errorf("failed to store UTM parameters: %w", err)
+		}
+	}
+
+	// Cache the mapping; scheduled links are cached by their first visit once active
+	if urlRecord.ActivateAt == nil {
+		if err := s.cache.Set(ctx, shortCode, normalizedURL); err != nil {
+			s.logger.Warnf("Failed to cache URL mapping: %v", err)
+		}
+	}
+
+	s.flagThreat(ctx, shortCode, normalizedURL, threat)
+	s.stream.PublishLink(LinkCreated, shortCode, normalizedURL, "")
+	return urlRecord, nil
+}
+
+// createWithRandomCode stores a new link under a random code. Codes taken by links or
+// aliases are skipped, and a code taken concurrently fails the insert and is retried.
+func (s *URLService) createWithRandomCode(ctx context.Context, urlRecord *models.URL, req *models.ShortenRequest) error {
+	for attempt := 0; ; attempt++ {
+		if attempt >= randomCodeAttempts {
+			return fmt.Errorf("failed to create URL: no free random code after %d attempts", randomCodeAttempts)
+		}
+
+		maxLength := maxShortCodeLength
+		if s.checksumDigit {
+			maxLength--
+		}
+		code, err := randomBase62(min(s.randomCodeLength+attempt/randomCodeAttemptsPerLength, maxLength))
+		if err != nil {
+			return fmt.Errorf("failed to generate short code: %w", err)
+		}
+		if s.checksumDigit {
+			code += string(checksumChar(code))
+		}
+		if req.Profile == ProfileSMS {
+			if err := checkSMSLength(len(code), req.MaxCodeLength); err != nil {
+				return err
+			}
+		}
+
+		taken, err := s.urlRepo.Exists(ctx, code)
+		if err != nil {
+			return fmt.Errorf("failed to check code existence: %w", err)
+		}
+		if taken {
+			s.codeStats.record(CodeStrategyRandom, true)
+			continue
+		}
+
+		urlRecord.ShortCode = code
+		urlRecord.CodeStrategy = CodeStrategyRandom
+		err = s.urlRepo.Create(ctx, urlRecord)
+		duplicate := errors.Is(err, repository.ErrDuplicate)
+		s.codeStats.record(CodeStrategyRandom, duplicate)
+		if err == nil {
+			return nil
+		}
+		if !duplicate {
+			return fmt.Errorf("failed to create URL: %w", err)
+		}
+	}
+}
+
+// deterministicCode returns the first candidate code of a destination that is free, or
+// that holds a deterministic link to the destination, which is then returned too.
+// Candidates taken by other links or aliases are skipped.
+func (s *URLService) deterministicCode(ctx context.Context, destination string) (string, *models.URL, error) {
+	for attempt := 0; attempt < deterministicCodeAttempts; attempt++ {
+		code := s.deterministicCodes.Code(destination, attempt)
+
+		link, err := s.urlRepo.GetByShortCode(ctx, code)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to look up deterministic code: %w", err)
+		}
+		if link != nil && link.OriginalURL == destination && link.CodeStrategy == CodeStrategyDeterministic {
+			link.Existing = true
+			return code, link, nil
+		}
+
+		taken := link != nil
+		if !taken {
+			// Aliases live in their own table
+			if taken, err = s.urlRepo.Exists(ctx, code); err != nil {
+				return "", nil, fmt.Errorf("failed to check code existence: %w", err)
+			}
+		}
+		s.codeStats.record(CodeStrategyDeterministic, taken)
+		if !taken {
+			return code, nil, nil
+		}
+	}
+	return "", nil, fmt.Errorf("failed to create URL: no free deterministic code after %d attempts", deterministicCodeAttempts)
+}
+
+// createDeterministic stores a new link under the deterministic code already chosen. When
+// a concurrent request takes the code first, usually for the same destination, the
+// candidates are looked up again and the link that request created is returned.
+func (s *URLService) createDeterministic(ctx context.Context, urlRecord *models.URL) (*models.URL, error) {
+	for attempt := 0; ; attempt++ {
+		err := s.urlRepo.Create(ctx, urlRecord)
+		if err == nil {
+			return nil, nil
+		}
+		if !errors.Is(err, repository.ErrDuplicate) || attempt+1 >= deterministicCodeAttempts {
+			return nil, fmt.Errorf("failed to create URL: %w", err)
+		}
+
+		code, existing, err := s.deterministicCode(ctx, urlRecord.OriginalURL)
+		if err != nil || existing != nil {
+			return existing, err
+		}
+		urlRecord.ShortCode = code
+	}
+}
+
+// ValidateRandomCodeLength checks the configured length of random codes, which leaves
+// room for a checksum character when one is appended
+func ValidateRandomCodeLength(length int, checksumDigit bool) error {
+	maxLength := maxShortCodeLength
+	if checksumDigit {
+		maxLength--
+	}
+	if length < minRandomCodeLength || length > maxLength {
+		return fmt.Errorf("random short codes must be between %d and %d characters long", minRandomCodeLength, maxLength)
+	}
+	return nil
+}
+
+// flagThreat files a link whose destination Safe Browsing lists for takedown review
+func (s *URLService) flagThreat(ctx context.Context, shortCode, destination, threat string) {
+	if threat == "" {
+		return
+	}
+	s.logger.Warnf("Safe Browsing lists the destination of link %s as %s", shortCode, threat)
+	if err := s.safeBrowsing.Flag(ctx, shortCode, destination, threat); err != nil {
+		s.logger.Errorf("Failed to flag %s: %v", shortCode, err)
+	}
+}
+
+// findReusable returns the existing link a deduplicating shorten request resolves to, nil
+// when a new link is needed. Only plain requests are deduplicated, and only against plain
+// links: custom aliases, code styles, click caps, passthrough and ephemeral or custom
+// domain links always get a link of their own.
+func (s *URLService) findReusable(ctx context.Context, req *models.ShortenRequest, urlRecord *models.URL) (*models.URL, error) {
+	deduplicate := s.deduplicate
+	if req.Deduplicate != nil {
+		deduplicate = *req.Deduplicate
+	}
+	if !deduplicate || urlRecord.CustomAlias || urlRecord.Ephemeral || urlRecord.PathPassthrough ||
+		urlRecord.MaxClicks != nil || urlRecord.UTM != nil || urlRecord.ActivateAt != nil ||
+		req.CodeStyle != CodeStyleDefault || req.Domain != "" || req.Deterministic {
+		return nil, nil
+	}
+
+	existing, err := s.urlRepo.FindReusable(ctx, urlRecord.OriginalURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up existing link: %w", err)
+	}
+	// The SMS profile needs a code short enough for its length limit
+	if existing == nil || (req.Profile == ProfileSMS && len(existing.ShortCode) > req.MaxCodeLength) {
+		return nil, nil
+	}
+	existing.Existing = true
+	return existing, nil
 }
 
-func NewURLService(urlRepo *repository.URLRepository, cache *repository.RedisCache, logger *logrus.Logger) *URLService {
-	return &URLService{
-		urlRepo: urlRepo,
-		cache:   cache,
-		logger:  logger,
+// PreviewShorten runs every check of ShortenURL without creating anything, returning the
+// record that would be created. Generated codes are only assigned on creation, so the
+// short code is empty unless a custom alias was requested, an existing link is reused or
+// the code is deterministic.
+func (s *URLService) PreviewShorten(ctx context.Context, req *models.ShortenRequest) (*models.URL, error) {
+	urlRecord, _, err := s.prepareShorten(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := s.findReusable(ctx, req, urlRecord)
+	if err != nil || existing != nil {
+		return existing, err
+	}
+	if req.Deterministic {
+		code, existing, err := s.deterministicCode(ctx, urlRecord.OriginalURL)
+		if err != nil || existing != nil {
+			return existing, err
+		}
+		urlRecord.ShortCode = code
 	}
+	return urlRecord, nil
 }
 
-// ShortenURL creates a short URL from a long URL
-func (s *URLService) ShortenURL(originalURL, customAlias string) (*models.URL, error) {
+// prepareShorten validates and normalizes a shorten request and, for a custom alias,
+// checks that it is available. It also returns the Safe Browsing threat type of a listed
+// destination when listed destinations are flagged rather than rejected.
+func (s *URLService) prepareShorten(ctx context.Context, req *models.ShortenRequest) (*models.URL, string, error) {
+	originalURL, customAlias, style := req.URL, req.CustomAlias, req.CodeStyle
+
 	// Validate and normalize URL
 	if err := s.validateURL(originalURL); err != nil {
-		return nil, fmt.Errorf("invalid URL: %w", err)
+		return nil, "", apperrors.Errorf(apperrors.ErrInvalidURL, "invalid URL: %w", err)
+	}
+	if err := validateTemplate(originalURL); err != nil {
+		return nil, "", apperrors.Errorf(apperrors.ErrInvalidURL, "invalid URL: %w", err)
+	}
+	if style != CodeStyleDefault && style != CodeStylePronounceable && (style != CodeStyleWords || s.wordCodes == nil) {
+		return nil, "", apperrors.Errorf(apperrors.ErrInvalid, "invalid code style: must be empty, %q or %q", CodeStylePronounceable, CodeStyleWords)
+	}
+	if err := s.validateDeterministic(req); err != nil {
+		return nil, "", err
+	}
+	if req.MaxClicks != nil && *req.MaxClicks < 1 {
+		return nil, "", apperrors.Errorf(apperrors.ErrInvalid, "invalid max clicks: must be at least 1")
+	}
+	if err := validateEphemeral(req); err != nil {
+		return nil, "", apperrors.Errorf(apperrors.ErrInvalid, "invalid ephemeral link: %w", err)
+	}
+	utm, err := validateUTMParams(req.UTM)
+	if err != nil {
+		return nil, "", err
+	}
+	activateAt, err := validateActivation(req, time.Now())
+	if err != nil {
+		return nil, "", err
 	}
 
-	normalizedURL := s.normalizeURL(originalURL)
-
-	var shortCode string
-	var isCustom bool
+	urlRecord := &models.URL{
+		OriginalURL:     normalizeURL(originalURL, s.normalize.With(req.Normalize)),
+		PathPassthrough: req.PathPassthrough,
+		MaxClicks:       req.MaxClicks,
+		UTM:             utm,
+		Ephemeral:       req.Ephemeral,
+		ActivateAt:      activateAt,
+	}
 
 	if customAlias != "" {
 		// Validate custom alias
+		customAlias = NormalizeShortCode(customAlias)
 		if err := s.validateCustomAlias(customAlias); err != nil {
-			return nil, fmt.Errorf("invalid custom alias: %w", err)
+			return nil, "", apperrors.Errorf(apperrors.ErrInvalid, "invalid custom alias: %w", err)
 		}
 
 		// Check if custom alias already exists
-		exists, err := s.urlRepo.Exists(customAlias)
+		exists, err := s.urlRepo.Exists(ctx, customAlias)
 		if err != nil {
-			return nil, fmt.Errorf("failed to check alias existence: %w", err)
+			return nil, "", fmt.Errorf("failed to check alias existence: %w", err)
 		}
 		if exists {
-			return nil, fmt.Errorf("custom alias already exists")
+			return nil, "", apperrors.Errorf(apperrors.ErrAliasExists, "custom alias already exists")
 		}
 
-		shortCode = customAlias
-		isCustom = true
-	} else {
-		// Generate short code using counter-based approach
-		nextID, err := s.urlRepo.GetNextID()
-		if err != nil {
-			return nil, fmt.Errorf("failed to get next ID: %w", err)
-		}
-		shortCode = s.encodeBase62(nextID)
+		urlRecord.ShortCode = customAlias
+		urlRecord.CustomAlias = true
+		urlRecord.CodeStrategy = CodeStrategyCustom
 	}
 
-	// Create URL record
-	urlRecord := &models.URL{
-		ShortCode:   shortCode,
-		OriginalURL: normalizedURL,
-		CustomAlias: isCustom,
+	if err := validateProfile(req, customAlias); err != nil {
+		return nil, "", err
 	}
 
-	if err := s.urlRepo.Create(urlRecord); err != nil {
-		return nil, fmt.Errorf("failed to create URL: %w", err)
+	// Screening and validators run last since they call out to other services
+	threat, err := s.screenDestination(ctx, urlRecord)
+	if err != nil {
+		return nil, "", err
+	}
+	alias := customAlias
+	if req.DomainAlias != "" {
+		alias = req.DomainAlias
 	}
+	if err := s.validateLink(&models.LinkProposal{
+		Action:      ValidationShorten,
+		Alias:       alias,
+		Destination: urlRecord.OriginalURL,
+		Domain:      req.Domain,
+		Ephemeral:   urlRecord.Ephemeral,
+	}); err != nil {
+		return nil, "", err
+	}
+	return urlRecord, threat, nil
+}
 
-	// Cache the mapping
-	if err := s.cache.Set(shortCode, normalizedURL); err != nil {
-		s.logger.Warnf("Failed to cache URL mapping: %v", err)
+// validateDeterministic checks that a deterministic request leaves the code to the
+// destination: custom aliases, code styles, ephemeral links and custom domains choose
+// their codes otherwise
+func (s *URLService) validateDeterministic(req *models.ShortenRequest) error {
+	if !req.Deterministic {
+		return nil
+	}
+	if s.deterministicCodes == nil {
+		return apperrors.Errorf(apperrors.ErrInvalid, "invalid deterministic request: deterministic codes are not enabled on this instance")
+	}
+	if req.CustomAlias != "" || req.CodeStyle != CodeStyleDefault || req.Ephemeral || req.Domain != "" {
+		return apperrors.Errorf(apperrors.ErrInvalid, "invalid deterministic request: cannot be combined with a custom alias, code style, ephemeral link or custom domain")
 	}
+	return nil
+}
 
-	return urlRecord, nil
+// screenDestination looks a new or changed destination up with Safe Browsing, returning
+// its threat type when listed destinations are flagged. Lookup failures let the link
+// through; rescans catch up with it.
+func (s *URLService) screenDestination(ctx context.Context, urlRecord *models.URL) (string, error) {
+	if !s.safeBrowsing.Enabled() {
+		return "", nil
+	}
+
+	threat, err := s.safeBrowsing.Check(ctx, urlRecord.OriginalURL)
+	if err != nil {
+		s.logger.Warnf("Failed to screen destination: %v", err)
+		return "", nil
+	}
+	// Ephemeral links have no database row a takedown request could point at
+	if threat != "" && (s.safeBrowsing.Rejects() || urlRecord.Ephemeral) {
+		return "", apperrors.Errorf(apperrors.ErrInvalidURL, "invalid URL: Safe Browsing lists the destination as %s", threat)
+	}
+	return threat, nil
+}
+
+// screenDestinations looks further destinations of a link, such as those of its redirect
+// rules or landing page buttons, up with Safe Browsing in one go. It returns the first
+// listed one with its threat type when listed destinations are flagged; like
+// screenDestination, lookup failures let them through.
+func (s *URLService) screenDestinations(ctx context.Context, destinations []string) (string, string, error) {
+	if !s.safeBrowsing.Enabled() || len(destinations) == 0 {
+		return "", "", nil
+	}
+
+	threats, err := s.safeBrowsing.Lookup(ctx, destinations)
+	if err != nil {
+		s.logger.Warnf("Failed to screen destinations: %v", err)
+		return "", "", nil
+	}
+	for _, destination := range destinations {
+		threat, ok := threats[destination]
+		if !ok {
+			continue
+		}
+		if s.safeBrowsing.Rejects() {
+			return "", "", apperrors.Errorf(apperrors.ErrInvalidURL, "invalid URL: Safe Browsing lists %s as %s", destination, threat)
+		}
+		return destination, threat, nil
+	}
+	return "", "", nil
+}
+
+// styledCode generates the code of a code style for the given attempt. Pronounceable codes
+// grow every few attempts as the shorter space fills up; word codes keep their format.
+func (s *URLService) styledCode(style string, attempt int) string {
+	if style == CodeStyleWords {
+		return s.wordCodes.Generate()
+	}
+	return pronounceableCode(pronounceableSyllables + attempt/pronounceableAttemptsPerLength)
 }
 
-// GetOriginalURL retrieves the original URL for a short code
-func (s *URLService) GetOriginalURL(shortCode string) (string, error) {
-	// Try cache first
-	originalURL, err := s.cache.Get(shortCode)
+// pronounceableCode builds a random code of alternating consonants and vowels
+func pronounceableCode(syllables int) string {
+	code := make([]byte, 0, syllables*2)
+	for i := 0; i < syllables; i++ {
+		code = append(code,
+			pronounceableConsonants[rand.Intn(len(pronounceableConsonants))],
+			pronounceableVowels[rand.Intn(len(pronounceableVowels))])
+	}
+	return string(code)
+}
+
+// GetOriginalURL retrieves the original URL for a short code or one of its aliases,
+// along with the canonical short code the click belongs to
+func (s *URLService) GetOriginalURL(ctx context.Context, shortCode string) (string, string, error) {
+	if IsEphemeralCode(shortCode) {
+		link, err := s.getEphemeral(ctx, shortCode)
+		if err != nil {
+			return "", "", err
+		}
+		return link.URL, shortCode, nil
+	}
+
+	// Try cache first; an alias is cached as a pointer to its canonical code, and a code
+	// recently found in neither table is remembered as missing
+	canonical := shortCode
+	keys := []string{shortCode, aliasCacheKey(shortCode), notFoundCacheKey(shortCode)}
+	if s.earlyRefresh(ctx) {
+		keys = append(keys, freshnessCacheKey(shortCode))
+	}
+	cached, err := s.cache.MGet(ctx, keys...)
 	if err == nil {
-		return originalURL, nil
+		if cached[0] != "" {
+			s.usage.RecordCacheHit()
+			if len(cached) > 3 && s.refreshDue(cached[3], time.Now()) {
+				go s.lookupShared(ctx, shortCode, shortCode)
+			}
+			return cached[0], shortCode, nil
+		}
+		if cached[1] != "" {
+			canonical = cached[1]
+			if originalURL, err := s.cache.Get(ctx, canonical); err == nil {
+				s.usage.RecordCacheHit()
+				return originalURL, canonical, nil
+			}
+		} else if cached[2] != "" && s.negativeCaching(ctx) {
+			s.usage.RecordCacheHit()
+			return "", "", apperrors.Errorf(apperrors.ErrNotFound, "URL not found")
+		}
 	}
+	s.usage.RecordCacheMiss()
 
 	// If not in cache or cache error, query database
-	if err != redis.Nil {
+	if err != nil {
 		s.logger.Warnf("Cache error: %v", err)
 	}
 
-	urlRecord, err := s.urlRepo.GetByShortCode(shortCode)
+	return s.lookupShared(ctx, shortCode, canonical)
+}
+
+// lookupResult is the outcome of one database lookup, shared by every caller waiting for it
+type lookupResult struct {
+	originalURL string
+	canonical   string
+}
+
+// lookupShared looks a code up in the database and caches it. Concurrent lookups of the
+// same code, such as the redirects of a hot link whose cache entry just expired, wait for
+// a single query instead of each reaching the database.
+func (s *URLService) lookupShared(ctx context.Context, shortCode, canonical string) (string, string, error) {
+	// The lookup is shared, so one caller giving up must not fail the others; query and
+	// command timeouts still bound it
+	ctx = context.WithoutCancel(ctx)
+	value, err, _ := s.lookups.Do(shortCode, func() (interface{}, error) {
+		originalURL, canonical, err := s.lookup(ctx, shortCode, canonical)
+		return lookupResult{originalURL: originalURL, canonical: canonical}, err
+	})
+	result := value.(lookupResult)
+	return result.originalURL, result.canonical, err
+}
+
+func (s *URLService) lookup(ctx context.Context, shortCode, canonical string) (string, string, error) {
+	started := time.Now()
+	urlRecord, err := s.urlRepo.GetByShortCode(ctx, canonical)
 	if err != nil {
-		return "", fmt.Errorf("failed to get URL: %w", err)
+		return "", "", fmt.Errorf("failed to get URL: %w", err)
+	}
+	if urlRecord == nil && canonical == shortCode {
+		target, err := s.aliasRepo.GetShortCode(ctx, shortCode)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to get alias: %w", err)
+		}
+		if target != "" {
+			canonical = target
+			if urlRecord, err = s.urlRepo.GetByShortCode(ctx, canonical); err != nil {
+				return "", "", fmt.Errorf("failed to get URL: %w", err)
+			}
+		}
 	}
 	if urlRecord == nil {
-		return "", fmt.Errorf("URL not found")
+		s.rememberNotFound(ctx, shortCode)
+		return "", "", apperrors.Errorf(apperrors.ErrNotFound, "URL not found")
+	}
+	if urlRecord.DisabledAt != nil {
+		// Disabled links are never cached, so every visit reaches this check
+		return "", canonical, apperrors.Errorf(apperrors.ErrDisabled, "link disabled")
+	}
+	if !isActive(urlRecord, time.Now()) {
+		// Scheduled links are not cached before they activate, for the same reason
+		return "", canonical, &LinkInactiveError{ActivateAt: *urlRecord.ActivateAt}
 	}
 
 	// Cache the result
-	if err := s.cache.Set(shortCode, urlRecord.OriginalURL); err != nil {
+	if err := s.cache.SetWithTTL(ctx, canonical, urlRecord.OriginalURL, urlCacheTTL); err != nil {
 		s.logger.Warnf("Failed to cache URL mapping: %v", err)
+	} else if s.earlyRefresh(ctx) {
+		freshness := fmt.Sprintf("%d:%d", time.Now().Add(urlCacheTTL).UnixMilli(), time.Since(started).Microseconds())
+		if err := s.cache.SetWithTTL(ctx, freshnessCacheKey(canonical), freshness, urlCacheTTL); err != nil {
+			s.logger.Warnf("Failed to cache URL freshness: %v", err)
+		}
+	}
+	if canonical != shortCode {
+		if err := s.cache.Set(ctx, aliasCacheKey(shortCode), canonical); err != nil {
+			s.logger.Warnf("Failed to cache alias mapping: %v", err)
+		}
 	}
 
-	return urlRecord.OriginalURL, nil
+	return urlRecord.OriginalURL, canonical, nil
 }
 
-// GetURLStats retrieves statistics for a URL
-func (s *URLService) GetURLStats(shortCode string) (*models.URLStats, error) {
-	stats, err := s.urlRepo.GetStats(shortCode)
+// refreshDue decides whether a cache hit refreshes its entry ahead of expiry, using
+// probabilistic early expiration (XFetch): the closer the entry is to expiring and the
+// longer its lookup took, the likelier each hit refreshes it, so one request usually
+// renews a hot link before it expires for all of them. freshness is "expiry ms:lookup µs".
+func (s *URLService) refreshDue(freshness string, now time.Time) bool {
+	expiry, cost, ok := strings.Cut(freshness, ":")
+	if !ok {
+		return false
+	}
+	expiresAt, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil {
+		return false
+	}
+	lookupTime, err := strconv.ParseInt(cost, 10, 64)
+	if err != nil {
+		return false
+	}
+
+	gap := time.Duration(float64(lookupTime) * float64(time.Microsecond) * s.earlyRefreshBeta * -math.Log(1-rand.Float64()))
+	return !now.Add(gap).Before(time.UnixMilli(expiresAt))
+}
+
+// GetURLInfo describes a link, also when addressed by one of its aliases, without
+// recording a click
+func (s *URLService) GetURLInfo(ctx context.Context, shortCode string) (*models.URLInfo, error) {
+	if IsEphemeralCode(shortCode) {
+		return s.getEphemeralInfo(ctx, shortCode)
+	}
+
+	canonical := shortCode
+	urlRecord, err := s.urlRepo.GetByShortCode(ctx, shortCode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get URL: %w", err)
+	}
+	if urlRecord == nil {
+		target, err := s.aliasRepo.GetShortCode(ctx, shortCode)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get alias: %w", err)
+		}
+		if target == "" {
+			return nil, apperrors.Errorf(apperrors.ErrNotFound, "URL not found")
+		}
+		canonical = target
+		if urlRecord, err = s.urlRepo.GetByShortCode(ctx, canonical); err != nil {
+			return nil, fmt.Errorf("failed to get URL: %w", err)
+		}
+		if urlRecord == nil {
+			return nil, apperrors.Errorf(apperrors.ErrNotFound, "URL not found")
+		}
+	}
+
+	stats, err := s.urlRepo.GetStats(ctx, canonical)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get URL stats: %w", err)
+	}
+
+	info := &models.URLInfo{
+		ShortCode:       shortCode,
+		OriginalURL:     urlRecord.OriginalURL,
+		CreatedAt:       urlRecord.CreatedAt,
+		ExpiresAt:       urlRecord.ExpiresAt,
+		ActivateAt:      urlRecord.ActivateAt,
+		Active:          isActive(urlRecord, time.Now()),
+		PathPassthrough: urlRecord.PathPassthrough,
+		MaxClicks:       urlRecord.MaxClicks,
+		Disabled:        urlRecord.DisabledAt != nil,
+		NumericCode:     urlRecord.NumericCode,
+	}
+	if info.UTM, err = s.urlRepo.GetUTMParams(ctx, canonical); err != nil {
+		return nil, fmt.Errorf("failed to get UTM parameters: %w", err)
+	}
+	if canonical != shortCode {
+		info.CanonicalCode = canonical
+	}
+	if stats != nil {
+		info.ClickCount = stats.ClickCount
+	}
+	if urlRecord.MaxClicks != nil {
+		remaining, err := s.clicksRemaining(ctx, canonical, *urlRecord.MaxClicks)
+		if err != nil {
+			return nil, err
+		}
+		info.ClicksRemaining = &remaining
+	}
+	return info, nil
+}
+
+// DeleteURL removes a link for good, with its statistics, aliases and codes on custom
+// domains, and drops everything cached about it. Ephemeral links are removed from Redis.
+func (s *URLService) DeleteURL(ctx context.Context, shortCode string) error {
+	if IsEphemeralCode(shortCode) {
+		if _, err := s.getEphemeral(ctx, shortCode); err != nil {
+			return err
+		}
+		if err := s.cache.Delete(ctx, ephemeralKey(shortCode), ephemeralClicksKey(shortCode)); err != nil {
+			return fmt.Errorf("failed to delete ephemeral link: %w", err)
+		}
+		s.stream.PublishLink(LinkDeleted, shortCode, "", "")
+		return nil
+	}
+
+	aliases, found, err := s.urlRepo.Delete(ctx, shortCode)
+	if err != nil {
+		return fmt.Errorf("failed to delete URL: %w", err)
+	}
+	if !found {
+		return apperrors.Errorf(apperrors.ErrNotFound, "URL not found")
+	}
+
+	keys := []string{shortCode, passthroughCacheKey(shortCode), redirectRulesCacheKey(shortCode), utmCacheKey(shortCode), landingPageCacheKey(shortCode), clickCapCacheKey(shortCode), clickCountKey(shortCode)}
+	for _, alias := range aliases {
+		keys = append(keys, aliasCacheKey(alias))
+	}
+	if err := s.cache.Delete(ctx, keys...); err != nil {
+		s.logger.Warnf("Failed to invalidate cached URL mapping: %v", err)
+	}
+	s.stream.PublishLink(LinkDeleted, shortCode, "", "")
+	return nil
+}
+
+// SetDisabled takes a link down, or brings it back, and drops its cached destination so
+// every instance sees the change on the next visit
+func (s *URLService) SetDisabled(ctx context.Context, shortCode string, disabled bool, reason string) error {
+	found, err := s.urlRepo.SetDisabled(ctx, shortCode, disabled, reason)
+	if err != nil {
+		return fmt.Errorf("failed to update URL: %w", err)
+	}
+	if !found {
+		return apperrors.Errorf(apperrors.ErrNotFound, "URL not found")
+	}
+
+	if err := s.cache.Delete(ctx, shortCode); err != nil {
+		s.logger.Warnf("Failed to invalidate cached URL mapping: %v", err)
+	}
+	if disabled {
+		s.stream.PublishLink(LinkDisabled, shortCode, "", reason)
+	} else {
+		s.stream.PublishLink(LinkEnabled, shortCode, "", "")
+	}
+	return nil
+}
+
+// CacheCounts returns the redirects this instance served from cache and from the
+// database since it started
+func (s *URLService) CacheCounts() (hits, misses int64) {
+	return s.usage.CacheCounts()
+}
+
+// CheckDestination returns an error when the domain policies no longer permit a stored
+// destination, so links created before a policy change stop redirecting
+func (s *URLService) CheckDestination(destination string) error {
+	if s.policies == nil {
+		return nil
+	}
+	return s.policies.CheckURL(destination)
+}
+
+// ResolveShortCode returns the canonical short code of a link or one of its aliases
+func (s *URLService) ResolveShortCode(ctx context.Context, shortCode string) (string, error) {
+	urlRecord, err := s.urlRepo.GetByShortCode(ctx, shortCode)
+	if err != nil {
+		return "", fmt.Errorf("failed to get URL: %w", err)
+	}
+	if urlRecord != nil {
+		return shortCode, nil
+	}
+
+	target, err := s.aliasRepo.GetShortCode(ctx, shortCode)
+	if err != nil {
+		return "", fmt.Errorf("failed to get alias: %w", err)
+	}
+	if target == "" {
+		return "", apperrors.Errorf(apperrors.ErrNotFound, "URL not found")
+	}
+	return target, nil
+}
+
+// GetURLStats retrieves statistics for a URL, also when addressed by one of its aliases
+func (s *URLService) GetURLStats(ctx context.Context, shortCode string) (*models.URLStats, error) {
+	stats, err := s.urlRepo.GetStats(ctx, shortCode)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get URL stats: %w", err)
 	}
 	if stats == nil {
-		return nil, fmt.Errorf("URL not found")
+		target, err := s.aliasRepo.GetShortCode(ctx, shortCode)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get alias: %w", err)
+		}
+		if target == "" {
+			return nil, apperrors.Errorf(apperrors.ErrNotFound, "URL not found")
+		}
+		if stats, err = s.urlRepo.GetStats(ctx, target); err != nil {
+			return nil, fmt.Errorf("failed to get URL stats: %w", err)
+		}
+		if stats == nil {
+			return nil, apperrors.Errorf(apperrors.ErrNotFound, "URL not found")
+		}
+	}
+
+	aliases, err := s.aliasRepo.ListByShortCode(stats.ShortCode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list aliases: %w", err)
+	}
+	for _, alias := range aliases {
+		stats.Aliases = append(stats.Aliases, alias.Alias)
 	}
 	return stats, nil
 }
 
+// GetDomainStats reports the destination domains with the most clicks over the last
+// number of days, with the number of links to each. Unicode and punycode spellings of a
+// domain are counted together.
+func (s *URLService) GetDomainStats(ctx context.Context, days, limit int) (*models.DomainStatsReport, error) {
+	since := time.Now().UTC().AddDate(0, 0, -days)
+	rows, err := s.urlRepo.GetDomainStats(ctx, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get domain stats: %w", err)
+	}
+
+	domains := mergeDomainStats(rows)
+	report := &models.DomainStatsReport{Days: days, TotalDomains: len(domains), Domains: domains}
+	if len(domains) > limit {
+		report.Domains = domains[:limit]
+	}
+	return report, nil
+}
+
+// mergeDomainStats combines rows whose domains share a canonical form and orders them by
+// clicks, then links. Rows without a parseable domain are dropped.
+func mergeDomainStats(rows []models.DomainStats) []models.DomainStats {
+	merged := make(map[string]*models.DomainStats)
+	for _, row := range rows {
+		domain, err := CanonicalHost(row.Domain)
+		if err != nil || domain == "" {
+			continue
+		}
+		if existing, ok := merged[domain]; ok {
+			existing.Links += row.Links
+			existing.Clicks += row.Clicks
+			continue
+		}
+		merged[domain] = &models.DomainStats{Domain: domain, Links: row.Links, Clicks: row.Clicks}
+	}
+
+	domains := make([]models.DomainStats, 0, len(merged))
+	for _, stats := range merged {
+		domains = append(domains, *stats)
+	}
+	sort.Slice(domains, func(i, j int) bool {
+		if domains[i].Clicks != domains[j].Clicks {
+			return domains[i].Clicks > domains[j].Clicks
+		}
+		if domains[i].Links != domains[j].Links {
+			return domains[i].Links > domains[j].Links
+		}
+		return domains[i].Domain < domains[j].Domain
+	})
+	return domains
+}
+
+// UpdateDestination points an existing short code at a new destination. The previous
+// destination is kept in the link's history along with who changed it. UTM parameters,
+// when given, replace the link's.
+func (s *URLService) UpdateDestination(ctx context.Context, shortCode, newURL string, normalize *models.NormalizeRules, utm *models.UTMParams, changedBy string) (*models.URLHistoryEntry, error) {
+	if err := s.validateURL(newURL); err != nil {
+		return nil, apperrors.Errorf(apperrors.ErrInvalidURL, "invalid URL: %w", err)
+	}
+	if err := validateTemplate(newURL); err != nil {
+		return nil, apperrors.Errorf(apperrors.ErrInvalidURL, "invalid URL: %w", err)
+	}
+	if _, err := validateUTMParams(utm); err != nil {
+		return nil, err
+	}
+
+	newURL = normalizeURL(newURL, s.normalize.With(normalize))
+	threat, err := s.screenDestination(ctx, &models.URL{ShortCode: shortCode, OriginalURL: newURL})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.validateLink(&models.LinkProposal{Action: ValidationUpdateDestination, ShortCode: shortCode, Destination: newURL}); err != nil {
+		return nil, err
+	}
+
+	entry, err := s.urlRepo.UpdateOriginalURL(ctx, shortCode, newURL, changedBy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update URL: %w", err)
+	}
+	if entry == nil {
+		return nil, apperrors.Errorf(apperrors.ErrNotFound, "URL not found")
+	}
+
+	// Aliases are cached as pointers to the canonical code, so dropping its entry is enough
+	if err := s.cache.Delete(ctx, shortCode); err != nil {
+		s.logger.Warnf("Failed to invalidate URL cache: %v", err)
+	}
+
+	if utm != nil {
+		if err := s.SetUTMParams(ctx, shortCode, utm); err != nil {
+			return nil, err
+		}
+	}
+	s.flagThreat(ctx, shortCode, newURL, threat)
+	s.stream.PublishLink(LinkUpdated, shortCode, newURL, "")
+	return entry, nil
+}
+
+// GetURLHistory returns the destination changes of a link, newest first
+func (s *URLService) GetURLHistory(ctx context.Context, shortCode string) ([]*models.URLHistoryEntry, error) {
+	urlRecord, err := s.urlRepo.GetByShortCode(ctx, shortCode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get URL: %w", err)
+	}
+	if urlRecord == nil {
+		return nil, apperrors.Errorf(apperrors.ErrNotFound, "URL not found")
+	}
+
+	entries, err := s.urlRepo.ListHistory(ctx, shortCode, maxHistoryEntries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list URL history: %w", err)
+	}
+	if entries == nil {
+		entries = []*models.URLHistoryEntry{}
+	}
+	return entries, nil
+}
+
+// AddAlias attaches an additional alias to an existing link. The alias shares the
+// link's destination, settings and analytics.
+func (s *URLService) AddAlias(ctx context.Context, shortCode, alias string) (*models.Alias, error) {
+	alias = NormalizeShortCode(alias)
+	if err := s.validateCustomAlias(alias); err != nil {
+		return nil, apperrors.Errorf(apperrors.ErrInvalid, "invalid alias: %w", err)
+	}
+
+	urlRecord, err := s.urlRepo.GetByShortCode(ctx, shortCode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get URL: %w", err)
+	}
+	if urlRecord == nil {
+		return nil, apperrors.Errorf(apperrors.ErrNotFound, "URL not found")
+	}
+
+	exists, err := s.urlRepo.Exists(ctx, alias)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check alias existence: %w", err)
+	}
+	if exists {
+		return nil, apperrors.Errorf(apperrors.ErrAliasExists, "alias already exists")
+	}
+	if err := s.validateLink(&models.LinkProposal{Action: ValidationAddAlias, ShortCode: shortCode, Alias: alias}); err != nil {
+		return nil, err
+	}
+
+	record := &models.Alias{Alias: alias, ShortCode: shortCode}
+	if err := s.aliasRepo.Create(record); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			return nil, apperrors.Errorf(apperrors.ErrAliasExists, "alias already exists")
+		}
+		return nil, fmt.Errorf("failed to create alias: %w", err)
+	}
+	s.forgetNotFound(ctx, alias)
+
+	return record, nil
+}
+
+// ListAliases returns the aliases attached to a link
+func (s *URLService) ListAliases(ctx context.Context, shortCode string) ([]*models.Alias, error) {
+	urlRecord, err := s.urlRepo.GetByShortCode(ctx, shortCode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get URL: %w", err)
+	}
+	if urlRecord == nil {
+		return nil, apperrors.Errorf(apperrors.ErrNotFound, "URL not found")
+	}
+
+	aliases, err := s.aliasRepo.ListByShortCode(shortCode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list aliases: %w", err)
+	}
+	return aliases, nil
+}
+
+// RemoveAlias detaches an alias from a link
+func (s *URLService) RemoveAlias(ctx context.Context, shortCode, alias string) error {
+	alias = NormalizeShortCode(alias)
+	deleted, err := s.aliasRepo.Delete(shortCode, alias)
+	if err != nil {
+		return fmt.Errorf("failed to delete alias: %w", err)
+	}
+	if !deleted {
+		return apperrors.Errorf(apperrors.ErrNotFound, "alias not found")
+	}
+
+	if err := s.cache.Delete(ctx, aliasCacheKey(alias)); err != nil {
+		s.logger.Warnf("Failed to invalidate alias cache: %v", err)
+	}
+	return nil
+}
+
+// isPassthrough reports whether a link forwards extra paths, cached next to its destination
+func (s *URLService) isPassthrough(ctx context.Context, shortCode string) (bool, error) {
+	if cached, err := s.cache.Get(ctx, passthroughCacheKey(shortCode)); err == nil {
+		return cached == "1", nil
+	}
+
+	urlRecord, err := s.urlRepo.GetByShortCode(ctx, shortCode)
+	if err != nil {
+		return false, fmt.Errorf("failed to get URL: %w", err)
+	}
+	if urlRecord == nil {
+		return false, apperrors.Errorf(apperrors.ErrNotFound, "URL not found")
+	}
+
+	flag := "0"
+	if urlRecord.PathPassthrough {
+		flag = "1"
+	}
+	if err := s.cache.Set(ctx, passthroughCacheKey(shortCode), flag); err != nil {
+		s.logger.Warnf("Failed to cache passthrough flag: %v", err)
+	}
+	return urlRecord.PathPassthrough, nil
+}
+
+// buildPassthroughURL appends an extra path below the destination path and merges the
+// query, keeping the destination's own parameters when both set the same key. The
+// result always stays on the destination host and below its path.
+func buildPassthroughURL(destination, extraPath, rawQuery string) (string, error) {
+	destination, fragment, hasFragment := splitFragment(destination)
+	target, err := url.Parse(destination)
+	if err != nil {
+		return "", fmt.Errorf("malformed destination")
+	}
+
+	extra := strings.Trim(extraPath, "/")
+	if extra != "" {
+		for _, segment := range strings.Split(extra, "/") {
+			if segment == "" || segment == "." || segment == ".." {
+				return "", fmt.Errorf("path must not contain empty, . or .. segments")
+			}
+			if strings.ContainsAny(segment, "\\\x00") {
+				return "", fmt.Errorf("path contains forbidden characters")
+			}
+		}
+		target.Path = strings.TrimSuffix(target.Path, "/") + "/" + extra
+		target.RawPath = ""
+	}
+
+	if rawQuery != "" {
+		extraQuery, err := url.ParseQuery(rawQuery)
+		if err != nil {
+			return "", fmt.Errorf("malformed query")
+		}
+		query := target.Query()
+		for key, values := range extraQuery {
+			if _, ok := query[key]; !ok {
+				query[key] = values
+			}
+		}
+		target.RawQuery = query.Encode()
+	}
+
+	return joinFragment(target.String(), fragment, hasFragment), nil
+}
+
+// passthroughCacheKey is the cache key of a link's path passthrough flag
+func passthroughCacheKey(shortCode string) string {
+	return "passthrough:" + shortCode
+}
+
+// aliasCacheKey is the cache key mapping an alias to its canonical short code
+func aliasCacheKey(alias string) string {
+	return "alias:" + alias
+}
+
+// freshnessCacheKey holds when a link's cache entry expires and how long its lookup took
+func freshnessCacheKey(shortCode string) string {
+	return "fresh:" + shortCode
+}
+
+// negativeCaching reports whether misses are remembered for the request of ctx
+func (s *URLService) negativeCaching(ctx context.Context) bool {
+	return s.notFoundTTL > 0 && s.canary.FeatureOn(ctx, FeatureNegativeCache)
+}
+
+// earlyRefresh reports whether hot links are refreshed early for the request of ctx
+func (s *URLService) earlyRefresh(ctx context.Context) bool {
+	return s.earlyRefreshBeta > 0 && s.canary.FeatureOn(ctx, FeatureEarlyRefresh)
+}
+
+// notFoundCacheKey marks a code that is neither a link nor an alias
+func notFoundCacheKey(shortCode string) string {
+	return "notfound:" + shortCode
+}
+
+// rememberNotFound caches a miss so repeated requests for a code that does not exist,
+// such as enumeration, are answered without the database
+func (s *URLService) rememberNotFound(ctx context.Context, shortCode string) {
+	if !s.negativeCaching(ctx) {
+		return
+	}
+	if err := s.cache.SetWithTTL(ctx, notFoundCacheKey(shortCode), "1", s.notFoundTTL); err != nil {
+		s.logger.Warnf("Failed to cache missing short code: %v", err)
+	}
+}
+
+// forgetNotFound drops a cached miss once the code is taken by a new link or alias
+func (s *URLService) forgetNotFound(ctx context.Context, shortCode string) {
+	if s.notFoundTTL <= 0 {
+		return
+	}
+	if err := s.cache.Delete(ctx, notFoundCacheKey(shortCode)); err != nil {
+		s.logger.Warnf("Failed to clear cached miss for %s: %v", shortCode, err)
+	}
+}
+
+// SuggestShortCodes returns existing short codes one typo away from a code that was not
+// found. It only applies to codes with a failing checksum digit, so custom aliases and
+// codes generated before checksums were enabled never get suggestions.
+func (s *URLService) SuggestShortCodes(ctx context.Context, shortCode string) ([]string, error) {
+	if !s.checksumDigit || len(shortCode) < 2 || hasValidChecksum(shortCode) {
+		return nil, nil
+	}
+
+	seen := make(map[string]bool)
+	var candidates []string
+	add := func(candidate string) {
+		if !seen[candidate] && hasValidChecksum(candidate) {
+			seen[candidate] = true
+			candidates = append(candidates, candidate)
+		}
+	}
+
+	code := []byte(shortCode)
+	for i := range code {
+		original := code[i]
+		for j := 0; j < len(base62Chars); j++ {
+			code[i] = base62Chars[j]
+			add(string(code))
+		}
+		code[i] = original
+	}
+	for i := 0; i+1 < len(code); i++ {
+		code[i], code[i+1] = code[i+1], code[i]
+		add(string(code))
+		code[i], code[i+1] = code[i+1], code[i]
+	}
+
+	if len(candidates) > maxSuggestions {
+		candidates = candidates[:maxSuggestions]
+	}
+
+	existing, err := s.urlRepo.FilterExisting(ctx, candidates)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up suggestions: %w", err)
+	}
+	return existing, nil
+}
+
+// checksumChar computes the check character of a code from its position-weighted base62 digits
+func checksumChar(code string) byte {
+	sum := 0
+	for i := 0; i < len(code); i++ {
+		sum += (i + 1) * strings.IndexByte(base62Chars, code[i])
+	}
+	return base62Chars[sum%checksumModulus]
+}
+
+// hasValidChecksum reports whether the last character of a code is the checksum of the rest
+func hasValidChecksum(code string) bool {
+	if len(code) < 2 || strings.IndexByte(base62Chars, code[len(code)-1]) < 0 {
+		return false
+	}
+	for i := 0; i < len(code)-1; i++ {
+		if strings.IndexByte(base62Chars, code[i]) < 0 {
+			return false
+		}
+	}
+	return checksumChar(code[:len(code)-1]) == code[len(code)-1]
+}
+
 // encodeBase62 converts an integer to base62 string
 func (s *URLService) encodeBase62(num int64) string {
 	if num == 0 {
@@ -149,7 +1342,9 @@ func (s *URLService) encodeBase62(num int64) string {
 
 // validateURL validates and checks if URL is safe
 func (s *URLService) validateURL(rawURL string) error {
-	parsedURL, err := url.Parse(rawURL)
+	// Fragments are kept verbatim, so only the rest of the URL has to parse
+	base, _, _ := splitFragment(rawURL)
+	parsedURL, err := url.Parse(base)
 	if err != nil {
 		return fmt.Errorf("malformed URL")
 	}
@@ -162,6 +1357,19 @@ func (s *URLService) validateURL(rawURL string) error {
 		return fmt.Errorf("URL must have a valid host")
 	}
 
+	// Compare in punycode so Unicode and full-width spellings of a blocked domain are caught
+	if _, err := CanonicalHost(parsedURL.Hostname()); err != nil {
+		return fmt.Errorf("URL must have a valid host")
+	}
+	if MatchDomain(parsedURL.Hostname(), s.blocked) != "" {
+		return fmt.Errorf("destination domain is blocked")
+	}
+	if s.policies != nil {
+		if err := s.policies.Check(parsedURL.Hostname()); err != nil {
+			return err
+		}
+	}
+
 	// Basic security check for malicious URLs
 	maliciousPatterns := []string{
 		"javascript:",
@@ -182,6 +1390,14 @@ func (s *URLService) validateURL(rawURL string) error {
 
 // validateCustomAlias validates custom alias format
 func (s *URLService) validateCustomAlias(alias string) error {
+	if s.emojiAliases && isEmojiAlias(alias) {
+		// short_code holds 10 characters, which Postgres counts in code points
+		if count := utf8.RuneCountInString(alias); count > 10 {
+			return fmt.Errorf("emoji alias is too long (%d code points, at most 10)", count)
+		}
+		return nil
+	}
+
 	if len(alias) < 3 || len(alias) > 20 {
 		return fmt.Errorf("custom alias must be between 3 and 20 characters")
 	}
@@ -196,7 +1412,7 @@ func (s *URLService) validateCustomAlias(alias string) error {
 	}
 
 	// Reserved words
-	reserved := []string{"api", "health", "admin", "www", "app", "short", "url"}
+	reserved := []string{"api", "health", "admin", "www", "app", "short", "url", "trending", "share", "bookmarklet", "docs"}
 	for _, word := range reserved {
 		if strings.ToLower(alias) == word {
 			return fmt.Errorf("custom alias cannot be a reserved word")
@@ -206,25 +1422,53 @@ func (s *URLService) validateCustomAlias(alias string) error {
 	return nil
 }
 
-// normalizeURL normalizes the URL format
-func (s *URLService) normalizeURL(rawURL string) string {
-	parsedURL, _ := url.Parse(rawURL)
-
-	// Ensure scheme is present
-	if parsedURL.Scheme == "" {
-		parsedURL.Scheme = "https"
+// NormalizeShortCode strips emoji variation selectors, which some keyboards and platforms
+// add or drop, so every spelling of an emoji alias resolves to the same link
+func NormalizeShortCode(code string) string {
+	for i := 0; i < len(code); i++ {
+		if code[i] >= utf8.RuneSelf {
+			return strings.Map(func(r rune) rune {
+				if r == '\uFE0E' || r == '\uFE0F' {
+					return -1
+				}
+				return r
+			}, code)
+		}
 	}
+	return code
+}
 
-	// Remove trailing slash for consistency
-	parsedURL.Path = strings.TrimSuffix(parsedURL.Path, "/")
-
-	return parsedURL.String()
+// isEmojiAlias reports whether an alias is made only of emoji, including skin tone
+// modifiers, zero width joiners, flags and keycaps
+func isEmojiAlias(alias string) bool {
+	hasEmoji := false
+	for _, r := range alias {
+		switch {
+		case r >= 0x1F000 && r <= 0x1FAFF, // pictographs, emoticons, flags, skin tones
+			r >= 0x2600 && r <= 0x27BF, // miscellaneous symbols and dingbats
+			r >= 0x2300 && r <= 0x23FF, // technical symbols such as watches and hourglasses
+			r >= 0x2B00 && r <= 0x2BFF: // arrows, stars and squares
+			hasEmoji = true
+		case r == 0x20E3:
+			hasEmoji = true // combining keycap
+		case r == 0x200D, r >= 0xE0020 && r <= 0xE007F:
+			// zero width joiners and tag sequences only combine emoji
+		case r >= '0' && r <= '9', r == '#', r == '*':
+			// keycap bases, valid only when followed by U+20E3
+			if !strings.ContainsRune(alias, 0x20E3) {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return hasEmoji
 }
 
 // HealthCheck verifies database connectivity
-func (s *URLService) HealthCheck() error {
+func (s *URLService) HealthCheck(ctx context.Context) error {
 	// Test database connectivity with a simple query
-	_, err := s.urlRepo.HealthCheck()
+	_, err := s.urlRepo.HealthCheck(ctx)
 	if err != nil {
 		return fmt.Errorf("database health check failed: %w", err)
 	}
@@ -232,9 +1476,9 @@ func (s *URLService) HealthCheck() error {
 }
 
 // CacheHealthCheck verifies cache connectivity
-func (s *URLService) CacheHealthCheck() error {
+func (s *URLService) CacheHealthCheck(ctx context.Context) error {
 	// Test cache connectivity
-	if err := s.cache.Ping(); err != nil {
+	if err := s.cache.Ping(ctx); err != nil {
 		return fmt.Errorf("cache health check failed: %w", err)
 	}
 	return nil
@@ -1,9 +1,16 @@
 package services
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/alexnthnz/url-shortener/internal/models"
+	"github.com/alexnthnz/url-shortener/internal/repository"
 	"github.com/sirupsen/logrus"
 )
 
@@ -113,9 +120,7 @@ func TestValidateCustomAlias(t *testing.T) {
 }
 
 func TestNormalizeURL(t *testing.T) {
-	service := &URLService{
-		logger: logrus.New(),
-	}
+	defaults := NormalizeOptions{StripTrailingSlash: true}
 
 	testCases := []struct {
 		input    string
@@ -129,13 +134,40 @@ func TestNormalizeURL(t *testing.T) {
 	}
 
 	for _, tc := range testCases {
-		result := service.normalizeURL(tc.input)
+		result := normalizeURL(tc.input, defaults)
 		if result != tc.expected {
 			t.Errorf("normalizeURL(%s) = %s; expected %s", tc.input, result, tc.expected)
 		}
 	}
 }
 
+func TestNormalizeURLRules(t *testing.T) {
+	on, off := true, false
+	defaults := NormalizeOptions{StripTrailingSlash: true}
+
+	testCases := []struct {
+		name     string
+		input    string
+		rules    *models.NormalizeRules
+		expected string
+	}{
+		{"keep trailing slash", "https://example.com/docs/", &models.NormalizeRules{StripTrailingSlash: &off}, "https://example.com/docs/"},
+		{"force https", "http://example.com/a", &models.NormalizeRules{ForceHTTPS: &on}, "https://example.com/a"},
+		{"strip fragment", "https://example.com/a#section", &models.NormalizeRules{StripFragment: &on}, "https://example.com/a"},
+		{"keep fragment by default", "https://example.com/a#section", nil, "https://example.com/a#section"},
+		{"lowercase host", "https://Example.COM/Path", &models.NormalizeRules{LowercaseHost: &on}, "https://example.com/Path"},
+		{"sort query keeps templates", "https://example.com/?z=1&utm={click_id}&a=2", &models.NormalizeRules{SortQuery: &on}, "https://example.com?a=2&utm={click_id}&z=1"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if result := normalizeURL(tc.input, defaults.With(tc.rules)); result != tc.expected {
+				t.Errorf("normalizeURL(%s) = %s; expected %s", tc.input, result, tc.expected)
+			}
+		})
+	}
+}
+
 func TestAnalyticsEventCreation(t *testing.T) {
 	// Test analytics event structure
 	event := AnalyticsEvent{
@@ -153,3 +185,670 @@ func TestAnalyticsEventCreation(t *testing.T) {
 		t.Errorf("Expected IP address '192.168.1.1', got %s", event.IPAddress)
 	}
 }
+
+func TestShortCodeChecksum(t *testing.T) {
+	service := &URLService{
+		logger: logrus.New(),
+	}
+
+	for _, id := range []int64{1, 61, 12345, 999999, 56800235583} {
+		code := service.encodeBase62(id)
+		code += string(checksumChar(code))
+		if !hasValidChecksum(code) {
+			t.Fatalf("hasValidChecksum(%s) = false for a generated code", code)
+		}
+
+		// Every single-character substitution in the code must be detected
+		for i := 0; i < len(code)-1; i++ {
+			for j := 0; j < len(base62Chars); j++ {
+				typo := []byte(code)
+				if typo[i] == base62Chars[j] {
+					continue
+				}
+				if (typo[i] == '0' && base62Chars[j] == 'Z') || (typo[i] == 'Z' && base62Chars[j] == '0') {
+					continue
+				}
+				typo[i] = base62Chars[j]
+				if hasValidChecksum(string(typo)) {
+					t.Errorf("substitution %s -> %s was not detected", code, typo)
+				}
+			}
+		}
+
+		// Every adjacent swap of distinct characters in the code must be detected
+		for i := 0; i+2 < len(code); i++ {
+			typo := []byte(code)
+			if typo[i] == typo[i+1] {
+				continue
+			}
+			typo[i], typo[i+1] = typo[i+1], typo[i]
+			if hasValidChecksum(string(typo)) {
+				t.Errorf("transposition %s -> %s was not detected", code, typo)
+			}
+		}
+	}
+
+	invalid := []string{"", "a", "ab-c", "héllo"}
+	for _, code := range invalid {
+		if hasValidChecksum(code) {
+			t.Errorf("hasValidChecksum(%q) should be false", code)
+		}
+	}
+}
+
+func TestPronounceableCode(t *testing.T) {
+	for syllables := 3; syllables <= 5; syllables++ {
+		code := pronounceableCode(syllables)
+		if len(code) != syllables*2 {
+			t.Fatalf("pronounceableCode(%d) = %s; expected %d characters", syllables, code, syllables*2)
+		}
+		for i := 0; i < len(code); i++ {
+			letters := pronounceableConsonants
+			if i%2 == 1 {
+				letters = pronounceableVowels
+			}
+			if !strings.ContainsRune(letters, rune(code[i])) {
+				t.Errorf("pronounceableCode(%d) = %s; unexpected %q at position %d", syllables, code, code[i], i)
+			}
+		}
+	}
+
+	// The longest code produced by the retry budget must fit the short_code column
+	longest := pronounceableSyllables + (styledCodeAttempts-1)/pronounceableAttemptsPerLength
+	if longest*2 > 10 {
+		t.Errorf("retry budget grows codes to %d characters, more than fit in a short code", longest*2)
+	}
+}
+
+func TestWordCodeGenerator(t *testing.T) {
+	wordlist, err := LoadWordlist("")
+	if err != nil {
+		t.Fatalf("LoadWordlist: %v", err)
+	}
+	long := append([]string{"whiteboard"}, wordlist...)
+
+	tests := []struct {
+		words     []string
+		count     int
+		digits    int
+		separator string
+		valid     bool
+	}{
+		{wordlist, 2, 2, "-", true},
+		{wordlist, 2, 0, "_", true},
+		{wordlist, 3, 2, "-", false},
+		{wordlist, 2, 2, ".", false},
+		{wordlist, 0, 2, "-", false},
+		{long, 1, 0, "-", true},
+		{long, 1, 2, "-", false},
+		{[]string{"red", "fox", "red"}, 1, 0, "-", false},
+		{append([]string{"Fox"}, wordlist...), 1, 0, "-", false},
+	}
+
+	for _, test := range tests {
+		generator, err := NewWordCodeGenerator(test.words, test.count, test.digits, test.separator)
+		if (err == nil) != test.valid {
+			t.Errorf("NewWordCodeGenerator(%d words, %d, %d, %q) error = %v; expected valid = %v",
+				len(test.words), test.count, test.digits, test.separator, err, test.valid)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		code := generator.Generate()
+		parts := strings.Split(code, test.separator)
+		if len(code) > maxShortCodeLength || len(parts) != test.count+min(test.digits, 1) {
+			t.Errorf("Generate() = %q; expected %d words and %d digits", code, test.count, test.digits)
+		}
+		if test.digits > 0 && len(parts[len(parts)-1]) != test.digits {
+			t.Errorf("Generate() = %q; expected %d digits at the end", code, test.digits)
+		}
+	}
+}
+
+func TestDeterministicCodeGenerator(t *testing.T) {
+	key := "0123456789abcdef"
+	generator, err := NewDeterministicCodeGenerator(key, 8, false)
+	if err != nil {
+		t.Fatalf("NewDeterministicCodeGenerator: %v", err)
+	}
+	other, err := NewDeterministicCodeGenerator(key, 8, false)
+	if err != nil {
+		t.Fatalf("NewDeterministicCodeGenerator: %v", err)
+	}
+
+	code := generator.Code("https://example.com/a", 0)
+	if len(code) != 8 || strings.Trim(code, base62Chars) != "" {
+		t.Errorf("Code() = %q; expected 8 base62 characters", code)
+	}
+	if again := other.Code("https://example.com/a", 0); again != code {
+		t.Errorf("Code() with the same key = %q; expected %q", again, code)
+	}
+	if fallback := generator.Code("https://example.com/a", 1); fallback == code {
+		t.Errorf("Code() of attempt 1 = %q; expected a different candidate", fallback)
+	}
+	if different := generator.Code("https://example.com/b", 0); different == code {
+		t.Errorf("Code() of another destination = %q; expected a different code", different)
+	}
+	rekeyed, _ := NewDeterministicCodeGenerator("fedcba9876543210", 8, false)
+	if code == rekeyed.Code("https://example.com/a", 0) {
+		t.Errorf("Code() with another key = %q; expected a different code", code)
+	}
+
+	checksummed, err := NewDeterministicCodeGenerator(key, 9, true)
+	if err != nil {
+		t.Fatalf("NewDeterministicCodeGenerator: %v", err)
+	}
+	if code := checksummed.Code("https://example.com/a", 0); len(code) != 10 || !hasValidChecksum(code) {
+		t.Errorf("Code() = %q; expected 9 characters and a valid check character", code)
+	}
+
+	for _, test := range []struct {
+		key      string
+		length   int
+		checksum bool
+	}{
+		{"short", 8, false},
+		{key, 3, false},
+		{key, 10, true},
+	} {
+		if _, err := NewDeterministicCodeGenerator(test.key, test.length, test.checksum); err == nil {
+			t.Errorf("NewDeterministicCodeGenerator(%q, %d, %v) succeeded; expected an error", test.key, test.length, test.checksum)
+		}
+	}
+}
+
+func TestValidateRandomCodeLength(t *testing.T) {
+	tests := []struct {
+		length   int
+		checksum bool
+		valid    bool
+	}{
+		{7, false, true},
+		{4, false, true},
+		{3, false, false},
+		{10, false, true},
+		{10, true, false},
+		{9, true, true},
+	}
+
+	for _, test := range tests {
+		err := ValidateRandomCodeLength(test.length, test.checksum)
+		if (err == nil) != test.valid {
+			t.Errorf("ValidateRandomCodeLength(%d, %v) = %v; expected valid = %v", test.length, test.checksum, err, test.valid)
+		}
+	}
+}
+
+func TestEmojiAliases(t *testing.T) {
+	service := &URLService{
+		emojiAliases: true,
+		logger:       logrus.New(),
+	}
+
+	validAliases := []string{
+		"🔥",
+		"🍕🍺",
+		"👍🏽",          // skin tone modifier
+		"👨‍👩‍👧",       // zero width joiner sequence
+		"🇫🇷",          // flag
+		"1⃣",          // keycap
+		"☕",           // miscellaneous symbols
+		"valid-alias", // ASCII aliases still work
+	}
+	for _, alias := range validAliases {
+		if err := service.validateCustomAlias(NormalizeShortCode(alias)); err != nil {
+			t.Errorf("validateCustomAlias(%q) should be valid, got error: %v", alias, err)
+		}
+	}
+
+	invalidAliases := []string{
+		"🔥abc", // mixed emoji and text
+		"1",    // keycap base alone is not an emoji
+		"é",    // non-emoji letters
+		"🔥🔥🔥🔥🔥🔥🔥🔥🔥🔥🔥", // longer than the short_code column
+	}
+	for _, alias := range invalidAliases {
+		if err := service.validateCustomAlias(NormalizeShortCode(alias)); err == nil {
+			t.Errorf("validateCustomAlias(%q) should be invalid, but passed", alias)
+		}
+	}
+
+	disabled := &URLService{logger: logrus.New()}
+	if err := disabled.validateCustomAlias("🍕🍺"); err == nil {
+		t.Error("emoji aliases should be rejected when disabled")
+	}
+
+	// Variation selectors are dropped so both spellings resolve to one alias
+	if NormalizeShortCode("❤️") != NormalizeShortCode("❤") {
+		t.Error("NormalizeShortCode should strip variation selectors")
+	}
+	if NormalizeShortCode("abc123") != "abc123" {
+		t.Error("NormalizeShortCode should not change ASCII codes")
+	}
+}
+
+func TestBuildPassthroughURL(t *testing.T) {
+	testCases := []struct {
+		destination string
+		extraPath   string
+		rawQuery    string
+		expected    string
+	}{
+		{"https://docs.example.com", "/guide/install", "", "https://docs.example.com/guide/install"},
+		{"https://docs.example.com/v2", "/guide/", "x=1", "https://docs.example.com/v2/guide?x=1"},
+		{"https://docs.example.com/v2/", "/guide", "", "https://docs.example.com/v2/guide"},
+		{"https://example.com/path?utm_source=qr", "", "utm_source=evil&page=2", "https://example.com/path?page=2&utm_source=qr"},
+		{"https://example.com/path#top", "/deep", "", "https://example.com/path/deep#top"},
+	}
+
+	for _, tc := range testCases {
+		result, err := buildPassthroughURL(tc.destination, tc.extraPath, tc.rawQuery)
+		if err != nil {
+			t.Errorf("buildPassthroughURL(%s, %s, %s) returned error: %v", tc.destination, tc.extraPath, tc.rawQuery, err)
+			continue
+		}
+		if result != tc.expected {
+			t.Errorf("buildPassthroughURL(%s, %s, %s) = %s; expected %s", tc.destination, tc.extraPath, tc.rawQuery, result, tc.expected)
+		}
+	}
+
+	// Paths must never climb out of the destination
+	invalidPaths := []string{"/../admin", "/guide/../../etc", "/./x", "/a//b", "/a\\b"}
+	for _, extraPath := range invalidPaths {
+		if _, err := buildPassthroughURL("https://docs.example.com/v2", extraPath, ""); err == nil {
+			t.Errorf("buildPassthroughURL with path %q should fail", extraPath)
+		}
+	}
+}
+
+func TestStripQRScan(t *testing.T) {
+	testCases := []struct {
+		rawQuery string
+		expected string
+		viaQR    bool
+	}{
+		{"", "", false},
+		{"qr=1", "", true},
+		{"page=2&qr=1&x=y", "page=2&x=y", true},
+		{"qr=0", "qr=0", false},
+		{"qrcode=1&page=2", "qrcode=1&page=2", false},
+	}
+
+	for _, tc := range testCases {
+		result, viaQR := StripQRScan(tc.rawQuery)
+		if result != tc.expected || viaQR != tc.viaQR {
+			t.Errorf("StripQRScan(%q) = %q, %v; expected %q, %v", tc.rawQuery, result, viaQR, tc.expected, tc.viaQR)
+		}
+	}
+}
+
+func TestNumericCode(t *testing.T) {
+	plain := &URLService{}
+	checked := &URLService{checksumDigit: true}
+
+	if got := plain.numericCode(1234); got != "1234" {
+		t.Errorf("numericCode(1234) = %s; expected 1234", got)
+	}
+	// 7992739871 is the textbook Luhn example, with check digit 3
+	if got := checked.numericCode(7992739871); got != "79927398713" {
+		t.Errorf("numericCode(7992739871) with checksum = %s; expected 79927398713", got)
+	}
+
+	testCases := []struct {
+		code     string
+		expected bool
+	}{
+		{"7", true},
+		{"79927398713", true},
+		{"", false},
+		{"0123", false},
+		{"12a4", false},
+		{"123456789012345678901", false},
+	}
+	for _, tc := range testCases {
+		if got := IsNumericCode(tc.code); got != tc.expected {
+			t.Errorf("IsNumericCode(%q) = %v; expected %v", tc.code, got, tc.expected)
+		}
+	}
+}
+
+func TestValidateProfile(t *testing.T) {
+	testCases := []struct {
+		req         models.ShortenRequest
+		customAlias string
+		valid       bool
+	}{
+		{models.ShortenRequest{}, "my_link", true},
+		{models.ShortenRequest{Profile: ProfileSMS, MaxCodeLength: 10}, "", true},
+		{models.ShortenRequest{Profile: ProfileSMS, MaxCodeLength: 10}, "spring-24", true},
+		{models.ShortenRequest{Profile: ProfileSMS, MaxCodeLength: 10}, "spring_24", false},
+		{models.ShortenRequest{Profile: ProfileSMS, MaxCodeLength: 10}, "-spring", false},
+		{models.ShortenRequest{Profile: ProfileSMS, MaxCodeLength: 10}, "🍕🍺", false},
+		{models.ShortenRequest{Profile: ProfileSMS, MaxCodeLength: 5}, "spring-24", false},
+		{models.ShortenRequest{Profile: ProfileSMS, MaxCodeLength: 10, Ephemeral: true}, "", false},
+		{models.ShortenRequest{Profile: "mms"}, "", false},
+	}
+
+	for _, tc := range testCases {
+		err := validateProfile(&tc.req, tc.customAlias)
+		if (err == nil) != tc.valid {
+			t.Errorf("validateProfile(%+v, %q) = %v; expected valid=%v", tc.req, tc.customAlias, err, tc.valid)
+		}
+	}
+
+	service := &DomainService{baseURL: "https://sho.rt", scheme: "https", smsMaxURLLength: 30}
+	if budget, err := service.SMSCodeBudget(""); err != nil || budget != 15 {
+		t.Errorf("SMSCodeBudget on the default domain = %d, %v; expected 15", budget, err)
+	}
+	if _, err := service.SMSCodeBudget("links.a-very-long-brand-name.example"); err == nil {
+		t.Error("SMSCodeBudget should fail when the domain alone exceeds the limit")
+	}
+}
+
+func TestURLFragmentsPreserved(t *testing.T) {
+	service := &URLService{logger: logrus.New()}
+	defaults := NormalizeOptions{StripTrailingSlash: true}
+
+	testCases := []struct {
+		name        string
+		input       string
+		normalized  string
+		passthrough string // with extra path /extra and query a=1
+	}{
+		{"docs anchor", "https://docs.example.com/guide#installation",
+			"https://docs.example.com/guide#installation",
+			"https://docs.example.com/guide/extra?a=1#installation"},
+		{"spa route with query", "https://app.example.com/#/users/42?tab=posts&x=1",
+			"https://app.example.com#/users/42?tab=posts&x=1",
+			"https://app.example.com/extra?a=1#/users/42?tab=posts&x=1"},
+		{"hashbang", "https://example.com/app#!/inbox",
+			"https://example.com/app#!/inbox",
+			"https://example.com/app/extra?a=1#!/inbox"},
+		{"nested hash", "https://example.com/p#a#b",
+			"https://example.com/p#a#b",
+			"https://example.com/p/extra?a=1#a#b"},
+		{"escapes kept", "https://example.com/p#key=val&x=%2F",
+			"https://example.com/p#key=val&x=%2F",
+			"https://example.com/p/extra?a=1#key=val&x=%2F"},
+		{"bare percent", "https://example.com/p#100%",
+			"https://example.com/p#100%",
+			"https://example.com/p/extra?a=1#100%"},
+		{"non-ascii encoded", "https://example.com/p#größe",
+			"https://example.com/p#gr%C3%B6%C3%9Fe",
+			"https://example.com/p/extra?a=1#gr%C3%B6%C3%9Fe"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := service.validateURL(tc.input); err != nil {
+				t.Fatalf("validateURL(%s) failed: %v", tc.input, err)
+			}
+
+			normalized := normalizeURL(tc.input, defaults)
+			if normalized != tc.normalized {
+				t.Errorf("normalizeURL(%s) = %s; expected %s", tc.input, normalized, tc.normalized)
+			}
+
+			passthrough, err := buildPassthroughURL(normalized, "/extra", "a=1")
+			if err != nil {
+				t.Fatalf("buildPassthroughURL(%s) failed: %v", normalized, err)
+			}
+			if passthrough != tc.passthrough {
+				t.Errorf("buildPassthroughURL(%s) = %s; expected %s", normalized, passthrough, tc.passthrough)
+			}
+		})
+	}
+}
+
+func TestShortenMaxClicks(t *testing.T) {
+	service := &URLService{logger: logrus.New()}
+
+	testCases := []struct {
+		name      string
+		maxClicks *int64
+		valid     bool
+	}{
+		{"no cap", nil, true},
+		{"single use", int64Ptr(1), true},
+		{"large cap", int64Ptr(1_000_000), true},
+		{"zero", int64Ptr(0), false},
+		{"negative", int64Ptr(-5), false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			record, err := service.PreviewShorten(context.Background(), &models.ShortenRequest{URL: "https://example.com", MaxClicks: tc.maxClicks})
+			if !tc.valid {
+				if err == nil || !strings.Contains(err.Error(), "invalid max clicks") {
+					t.Errorf("expected invalid max clicks error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PreviewShorten failed: %v", err)
+			}
+			if tc.maxClicks == nil && record.MaxClicks != nil {
+				t.Errorf("expected no cap, got %d", *record.MaxClicks)
+			}
+			if tc.maxClicks != nil && (record.MaxClicks == nil || *record.MaxClicks != *tc.maxClicks) {
+				t.Errorf("expected cap %d, got %v", *tc.maxClicks, record.MaxClicks)
+			}
+		})
+	}
+}
+
+func TestShortenEphemeral(t *testing.T) {
+	service := &URLService{logger: logrus.New()}
+
+	testCases := []struct {
+		name  string
+		req   models.ShortenRequest
+		valid bool
+	}{
+		{"default ttl", models.ShortenRequest{Ephemeral: true}, true},
+		{"one-time share", models.ShortenRequest{Ephemeral: true, TTLSeconds: 600, MaxClicks: int64Ptr(1)}, true},
+		{"longest ttl", models.ShortenRequest{Ephemeral: true, TTLSeconds: 86400}, true},
+		{"ttl too long", models.ShortenRequest{Ephemeral: true, TTLSeconds: 86401}, false},
+		{"negative ttl", models.ShortenRequest{Ephemeral: true, TTLSeconds: -1}, false},
+		{"ttl without ephemeral", models.ShortenRequest{TTLSeconds: 600}, false},
+		{"custom alias", models.ShortenRequest{Ephemeral: true, CustomAlias: "share"}, false},
+		{"pronounceable", models.ShortenRequest{Ephemeral: true, CodeStyle: CodeStylePronounceable}, false},
+		{"path passthrough", models.ShortenRequest{Ephemeral: true, PathPassthrough: true}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.req.URL = "https://example.com"
+			record, err := service.PreviewShorten(context.Background(), &tc.req)
+			if !tc.valid {
+				if err == nil || !strings.Contains(err.Error(), "invalid ephemeral link") {
+					t.Errorf("expected invalid ephemeral link error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("PreviewShorten failed: %v", err)
+			}
+			if !record.Ephemeral {
+				t.Error("expected an ephemeral record")
+			}
+		})
+	}
+}
+
+func TestFindReusableSkipsTailoredRequests(t *testing.T) {
+	// Without a repository, any lookup would panic
+	service := &URLService{deduplicate: true, logger: logrus.New()}
+	optOut := false
+
+	testCases := []struct {
+		name   string
+		req    models.ShortenRequest
+		record models.URL
+	}{
+		{"opted out", models.ShortenRequest{Deduplicate: &optOut}, models.URL{}},
+		{"custom alias", models.ShortenRequest{CustomAlias: "launch"}, models.URL{CustomAlias: true}},
+		{"pronounceable", models.ShortenRequest{CodeStyle: CodeStylePronounceable}, models.URL{}},
+		{"click cap", models.ShortenRequest{}, models.URL{MaxClicks: int64Ptr(10)}},
+		{"path passthrough", models.ShortenRequest{}, models.URL{PathPassthrough: true}},
+		{"ephemeral", models.ShortenRequest{}, models.URL{Ephemeral: true}},
+		{"custom domain", models.ShortenRequest{Domain: "go.example.com"}, models.URL{}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.record.OriginalURL = "https://example.com"
+			existing, err := service.findReusable(context.Background(), &tc.req, &tc.record)
+			if err != nil || existing != nil {
+				t.Errorf("findReusable() = %v, %v; expected a new link", existing, err)
+			}
+		})
+	}
+}
+
+func TestIsEphemeralCode(t *testing.T) {
+	code, err := randomBase62(ephemeralCodeLength)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEphemeralCode(code) {
+		t.Errorf("generated code %q is not recognized as ephemeral", code)
+	}
+
+	for _, code := range []string{"dnh", "abcdefghij", "abcdefghijk-", "abcdefghijklm", "🔥🔥🔥"} {
+		if IsEphemeralCode(code) {
+			t.Errorf("IsEphemeralCode(%q) = true; expected false", code)
+		}
+	}
+}
+
+func int64Ptr(v int64) *int64 {
+	return &v
+}
+
+func TestMergeDomainStats(t *testing.T) {
+	rows := []models.DomainStats{
+		{Domain: "example.com", Links: 3, Clicks: 10},
+		{Domain: "bücher.de", Links: 1, Clicks: 4},
+		{Domain: "xn--bcher-kva.de", Links: 2, Clicks: 8},
+		{Domain: "docs.example.com", Links: 5, Clicks: 10},
+		{Domain: "", Links: 2, Clicks: 1},
+	}
+
+	merged := mergeDomainStats(rows)
+	expected := []models.DomainStats{
+		{Domain: "xn--bcher-kva.de", Links: 3, Clicks: 12},
+		{Domain: "docs.example.com", Links: 5, Clicks: 10},
+		{Domain: "example.com", Links: 3, Clicks: 10},
+	}
+
+	if len(merged) != len(expected) {
+		t.Fatalf("mergeDomainStats returned %d domains; expected %d: %+v", len(merged), len(expected), merged)
+	}
+	for i := range expected {
+		if merged[i] != expected[i] {
+			t.Errorf("domain %d = %+v; expected %+v", i, merged[i], expected[i])
+		}
+	}
+}
+
+func TestNotFoundCache(t *testing.T) {
+	service := &URLService{
+		cache:       repository.NewMemoryCache(0),
+		notFoundTTL: time.Minute,
+		usage:       &UsageService{},
+		logger:      logrus.New(),
+	}
+
+	// A remembered miss is answered without the database, which the test does not have
+	service.rememberNotFound(context.Background(), "nothere")
+	if _, _, err := service.GetOriginalURL(context.Background(), "nothere"); err == nil || err.Error() != "URL not found" {
+		t.Fatalf("GetOriginalURL = %v; expected URL not found", err)
+	}
+
+	service.forgetNotFound(context.Background(), "nothere")
+	if _, err := service.cache.Get(context.Background(), notFoundCacheKey("nothere")); err != repository.ErrCacheMiss {
+		t.Errorf("the miss should be forgotten once the code is taken, got %v", err)
+	}
+}
+
+func TestRefreshDue(t *testing.T) {
+	service := &URLService{earlyRefreshBeta: 1}
+	now := time.Now()
+	freshness := func(expiresIn, lookup time.Duration) string {
+		return fmt.Sprintf("%d:%d", now.Add(expiresIn).UnixMilli(), lookup.Microseconds())
+	}
+
+	testCases := []struct {
+		name      string
+		freshness string
+		due       bool
+	}{
+		{"expired", freshness(-time.Second, time.Millisecond), true},
+		{"hours left", freshness(time.Hour, 5*time.Millisecond), false},
+		{"no lookup time", freshness(time.Minute, 0), false},
+		{"malformed", "soon", false},
+	}
+
+	for _, tc := range testCases {
+		if due := service.refreshDue(tc.freshness, now); due != tc.due {
+			t.Errorf("%s: refreshDue = %v; expected %v", tc.name, due, tc.due)
+		}
+	}
+}
+
+// updateRecorder is a URL store that records destination changes
+type updateRecorder struct {
+	repository.URLStore
+	updated []string
+}
+
+func (r *updateRecorder) UpdateOriginalURL(ctx context.Context, shortCode, newURL, changedBy string) (*models.URLHistoryEntry, error) {
+	r.updated = append(r.updated, newURL)
+	return &models.URLHistoryEntry{ShortCode: shortCode, ChangedBy: changedBy}, nil
+}
+
+func TestUpdateDestinationScreening(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"matches": [{"threatType": "SOCIAL_ENGINEERING", "threat": {"url": "https://phish.example/login"}}]}`))
+	}))
+	defer server.Close()
+
+	testCases := []struct {
+		name        string
+		action      string
+		destination string
+		updated     bool
+	}{
+		{"clean destination", SafeBrowsingReject, "https://example.com/", true},
+		{"listed destination rejected", SafeBrowsingReject, "https://phish.example/login", false},
+		{"listed destination flagged", SafeBrowsingFlag, "https://phish.example/login", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store := &updateRecorder{}
+			service := &URLService{
+				urlRepo: store,
+				cache:   repository.NewMemoryCache(0),
+				safeBrowsing: &SafeBrowsingService{
+					cfg:    SafeBrowsingConfig{APIKey: "test-key", Endpoint: server.URL, Action: tc.action, CacheTTL: time.Minute},
+					cache:  repository.NewMemoryCache(0),
+					client: server.Client(),
+					logger: logrus.New(),
+				},
+				logger: logrus.New(),
+			}
+
+			_, err := service.UpdateDestination(context.Background(), "abc123", tc.destination, nil, nil, "admin")
+			if tc.updated != (err == nil) {
+				t.Fatalf("UpdateDestination(%q) = %v; expected updated %v", tc.destination, err, tc.updated)
+			}
+			if tc.updated != (len(store.updated) == 1) {
+				t.Errorf("destination changes = %v; expected updated %v", store.updated, tc.updated)
+			}
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// templatePlaceholders is the strict set of placeholders a destination may contain
+var templatePlaceholders = map[string]bool{
+	"click_id":     true,
+	"short_code":   true,
+	"country":      true,
+	"utm_source":   true,
+	"utm_medium":   true,
+	"utm_campaign": true,
+	"language":     true,
+	"device":       true,
+}
+
+// placeholderRe matches placeholders both literally and percent-encoded, since
+// normalizing a destination escapes braces in its path
+var placeholderRe = regexp.MustCompile(`(?i)(?:\{|%7B)([^{}%/?#&=]*)(?:\}|%7D)`)
+
+// ClickContext carries the per-click values substituted into destination templates
+type ClickContext struct {
+	ClickID     string
+	ShortCode   string
+	Country     string
+	UTMSource   string
+	UTMMedium   string
+	UTMCampaign string
+	// AcceptLanguage and UserAgent are the raw request headers; they are only parsed when
+	// the destination uses {language} or {device}
+	AcceptLanguage string
+	UserAgent      string
+	// Device overrides the device type parsed from UserAgent
+	Device string
+}
+
+// NewClickID returns a random identifier for a single click
+func NewClickID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// IsTemplate reports whether a destination contains placeholders
+func IsTemplate(destination string) bool {
+	return placeholderRe.MatchString(destination)
+}
+
+// ExpandDestination substitutes click metadata into a destination template. Values are
+// query-escaped so a visitor-controlled value can never change the URL structure.
+func ExpandDestination(destination string, click ClickContext) string {
+	if !IsTemplate(destination) {
+		return destination
+	}
+
+	values := click.values()
+	return placeholderRe.ReplaceAllStringFunc(destination, func(match string) string {
+		name := strings.ToLower(placeholderRe.FindStringSubmatch(match)[1])
+		value, ok := values[name]
+		if !ok {
+			return match
+		}
+		return url.QueryEscape(value)
+	})
+}
+
+// TemplateValues returns the values a click substitutes for the placeholders a destination
+// uses, by placeholder name
+func TemplateValues(destination string, click ClickContext) map[string]string {
+	all := click.values()
+	used := make(map[string]string)
+	for _, match := range placeholderRe.FindAllStringSubmatch(destination, -1) {
+		name := strings.ToLower(match[1])
+		if value, ok := all[name]; ok {
+			used[name] = value
+		}
+	}
+	return used
+}
+
+// values returns the value of every placeholder for a click
+func (click ClickContext) values() map[string]string {
+	return map[string]string{
+		"click_id":     click.ClickID,
+		"short_code":   click.ShortCode,
+		"country":      click.Country,
+		"utm_source":   click.UTMSource,
+		"utm_medium":   click.UTMMedium,
+		"utm_campaign": click.UTMCampaign,
+		"language":     PreferredLanguage(click.AcceptLanguage),
+		"device":       click.device(),
+	}
+}
+
+// device returns the device type of a click, parsing the user agent unless it is given
+func (click ClickContext) device() string {
+	if click.Device != "" {
+		return click.Device
+	}
+	return ParseUserAgent(click.UserAgent).DeviceType
+}
+
+// PreferredLanguage returns the primary language subtag, such as "de" for "de-CH", of the
+// Accept-Language entry with the highest weight. Ties keep header order; the result is
+// empty when the header names no language.
+func PreferredLanguage(acceptLanguage string) string {
+	best, bestWeight := "", 0.0
+	for _, entry := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(entry, ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		primary, _, _ := strings.Cut(tag, "-")
+		if !isLanguageSubtag(primary) {
+			continue // also skips the "*" wildcard
+		}
+
+		weight := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+			weight = parsed
+		}
+		if weight > bestWeight {
+			best, bestWeight = primary, weight
+		}
+	}
+	return best
+}
+
+// isLanguageSubtag reports whether s is a two or three letter ISO 639 language code
+func isLanguageSubtag(s string) bool {
+	if len(s) < 2 || len(s) > 3 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < 'a' || s[i] > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+// validateTemplate rejects unknown placeholders and placeholders outside the path,
+// query and fragment, so a template can never redirect to another host
+func validateTemplate(destination string) error {
+	for _, match := range placeholderRe.FindAllStringSubmatch(destination, -1) {
+		if !templatePlaceholders[strings.ToLower(match[1])] {
+			return fmt.Errorf("unknown placeholder {%s}", match[1])
+		}
+	}
+
+	if strings.ContainsAny(destination, "{}") || strings.Contains(strings.ToUpper(destination), "%7B") {
+		parsed, err := url.Parse(destination)
+		if err != nil {
+			return fmt.Errorf("malformed URL")
+		}
+		if placeholderRe.MatchString(parsed.Host) || strings.ContainsAny(parsed.Host, "{}") {
+			return fmt.Errorf("placeholders are not allowed in the host")
+		}
+	}
+
+	return nil
+}
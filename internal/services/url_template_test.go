@@ -0,0 +1,78 @@
+package services
+
+import "testing"
+
+func TestExpandDestination(t *testing.T) {
+	click := ClickContext{
+		ClickID:        "c0ffee",
+		ShortCode:      "abc",
+		Country:        "DE",
+		UTMSource:      "radio & tv",
+		AcceptLanguage: "fr-CH, en;q=0.8",
+		UserAgent:      "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) Mobile/15E148",
+	}
+
+	testCases := []struct {
+		destination string
+		expected    string
+	}{
+		{"https://example.com/landing", "https://example.com/landing"},
+		{"https://example.com/?cid={click_id}&c={country}", "https://example.com/?cid=c0ffee&c=DE"},
+		{"https://example.com/r/%7Bshort_code%7D", "https://example.com/r/abc"},
+		{"https://example.com/?src={utm_source}", "https://example.com/?src=radio+%26+tv"},
+		{"https://example.com/?m={utm_medium}", "https://example.com/?m="},
+		{"https://example.com/{language}/?d={device}", "https://example.com/fr/?d=mobile"},
+	}
+
+	for _, tc := range testCases {
+		if result := ExpandDestination(tc.destination, click); result != tc.expected {
+			t.Errorf("ExpandDestination(%s) = %s; expected %s", tc.destination, result, tc.expected)
+		}
+	}
+}
+
+func TestValidateTemplate(t *testing.T) {
+	valid := []string{
+		"https://example.com/landing",
+		"https://example.com/?cid={click_id}&src={utm_source}",
+		"https://example.com/%7Bcountry%7D/home",
+	}
+	for _, destination := range valid {
+		if err := validateTemplate(destination); err != nil {
+			t.Errorf("validateTemplate(%s) should be valid, got error: %v", destination, err)
+		}
+	}
+
+	invalid := []string{
+		"https://example.com/?ip={ip_address}",
+		"https://example.com/%7Bcookie%7D",
+		"https://{country}.example.com/",
+	}
+	for _, destination := range invalid {
+		if err := validateTemplate(destination); err == nil {
+			t.Errorf("validateTemplate(%s) should be invalid, but passed", destination)
+		}
+	}
+}
+
+func TestPreferredLanguage(t *testing.T) {
+	testCases := []struct {
+		header   string
+		expected string
+	}{
+		{"", ""},
+		{"de-DE", "de"},
+		{"fr-CH, fr;q=0.9, en;q=0.8", "fr"},
+		{"en;q=0.5, es;q=0.9", "es"},
+		{"*, nl;q=0.3", "nl"},
+		{"pt-BR;q=0.7, it;q=0.7", "pt"},
+		{"en;q=0, ja;q=0.1", "ja"},
+		{"1234, x", ""},
+	}
+
+	for _, tc := range testCases {
+		if result := PreferredLanguage(tc.header); result != tc.expected {
+			t.Errorf("PreferredLanguage(%q) = %q; expected %q", tc.header, result, tc.expected)
+		}
+	}
+}
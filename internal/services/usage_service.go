@@ -0,0 +1,211 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/alexnthnz/url-shortener/internal/models"
+	"github.com/alexnthnz/url-shortener/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	usageFlushInterval = 10 * time.Second
+	usageCounterTTL    = 400 * 24 * time.Hour
+	usageDateLayout    = "2006-01-02"
+	usageTopDomains    = 10
+)
+
+// UsageService tracks instance-wide usage for operators
+type UsageService struct {
+	urlRepo       repository.URLStore
+	analyticsRepo repository.AnalyticsStore
+	cache         repository.Cache
+	logger        *logrus.Logger
+
+	// Counters are accumulated locally and flushed to Redis so every instance contributes
+	cacheHits   int64
+	cacheMisses int64
+
+	// Totals since start, never flushed, for rates over short windows
+	cacheHitsTotal   int64
+	cacheMissesTotal int64
+}
+
+func NewUsageService(urlRepo repository.URLStore, analyticsRepo repository.AnalyticsStore, cache repository.Cache, logger *logrus.Logger) *UsageService {
+	service := &UsageService{
+		urlRepo:       urlRepo,
+		analyticsRepo: analyticsRepo,
+		cache:         cache,
+		logger:        logger,
+	}
+
+	// Start periodic counter flush
+	go service.flushLoop()
+
+	return service
+}
+
+// RecordCacheHit counts a redirect served from cache
+func (s *UsageService) RecordCacheHit() {
+	atomic.AddInt64(&s.cacheHits, 1)
+	atomic.AddInt64(&s.cacheHitsTotal, 1)
+}
+
+// RecordCacheMiss counts a redirect that fell through to the database
+func (s *UsageService) RecordCacheMiss() {
+	atomic.AddInt64(&s.cacheMisses, 1)
+	atomic.AddInt64(&s.cacheMissesTotal, 1)
+}
+
+// CacheCounts returns the redirects this instance served from cache and from the
+// database since it started
+func (s *UsageService) CacheCounts() (hits, misses int64) {
+	return atomic.LoadInt64(&s.cacheHitsTotal), atomic.LoadInt64(&s.cacheMissesTotal)
+}
+
+// flushLoop periodically moves local counters into daily Redis counters
+func (s *UsageService) flushLoop() {
+	ticker := time.NewTicker(usageFlushInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.flushCounters()
+	}
+}
+
+// flushCounters adds the local counters to today's Redis counters
+func (s *UsageService) flushCounters() {
+	ctx := context.Background()
+	date := time.Now().UTC().Format(usageDateLayout)
+
+	counters := []struct {
+		key   string
+		value *int64
+	}{
+		{"usage:cache_hits:" + date, &s.cacheHits},
+		{"usage:cache_misses:" + date, &s.cacheMisses},
+	}
+
+	for _, counter := range counters {
+		value := atomic.SwapInt64(counter.value, 0)
+		if value == 0 {
+			continue
+		}
+		if err := s.cache.IncrByWithTTL(ctx, counter.key, value, usageCounterTTL); err != nil {
+			// Put the count back so it is retried on the next flush
+			atomic.AddInt64(counter.value, value)
+			s.logger.Warnf("Failed to flush usage counter: %v", err)
+		}
+	}
+}
+
+// GetUsageReport compiles daily instance metrics for the last number of days
+func (s *UsageService) GetUsageReport(ctx context.Context, days int) (*models.UsageReport, error) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	since := today.AddDate(0, 0, -(days - 1))
+
+	created, err := s.urlRepo.GetDailyCreated(ctx, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get links created: %w", err)
+	}
+
+	redirects, err := s.analyticsRepo.GetDailyRedirects(ctx, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get redirects: %w", err)
+	}
+
+	topDomains, err := s.urlRepo.GetTopDomains(ctx, since, usageTopDomains)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get top domains: %w", err)
+	}
+
+	report := &models.UsageReport{
+		From:       since.Format(usageDateLayout),
+		To:         today.Format(usageDateLayout),
+		Days:       make([]models.UsageDay, days),
+		TopDomains: topDomains,
+	}
+
+	index := make(map[string]*models.UsageDay, days)
+	keys := make([]string, 0, days*2)
+	for i := range report.Days {
+		date := since.AddDate(0, 0, i).Format(usageDateLayout)
+		report.Days[i].Date = date
+		index[date] = &report.Days[i]
+		keys = append(keys, "usage:cache_hits:"+date, "usage:cache_misses:"+date)
+	}
+
+	for _, day := range created {
+		if usageDay := index[day.Day.UTC().Format(usageDateLayout)]; usageDay != nil {
+			usageDay.LinksCreated = day.Count
+		}
+	}
+	for _, day := range redirects {
+		if usageDay := index[day.Day.UTC().Format(usageDateLayout)]; usageDay != nil {
+			usageDay.Redirects = day.Count
+		}
+	}
+
+	// Cache counters are best effort; a Redis outage leaves them at zero
+	values, err := s.cache.MGet(ctx, keys...)
+	if err != nil {
+		s.logger.Warnf("Failed to read cache usage counters: %v", err)
+		values = make([]string, len(keys))
+	}
+
+	var totalHits, totalMisses int64
+	for i := range report.Days {
+		day := &report.Days[i]
+		day.CacheHits, _ = strconv.ParseInt(values[i*2], 10, 64)
+		day.CacheMisses, _ = strconv.ParseInt(values[i*2+1], 10, 64)
+		day.CacheHitRatio = hitRatio(day.CacheHits, day.CacheMisses)
+
+		report.LinksCreated += day.LinksCreated
+		report.Redirects += day.Redirects
+		totalHits += day.CacheHits
+		totalMisses += day.CacheMisses
+	}
+	report.CacheHitRatio = hitRatio(totalHits, totalMisses)
+
+	return report, nil
+}
+
+// GetTotals returns instance-wide counts of links, aliases and clicks
+func (s *UsageService) GetTotals(ctx context.Context) (*models.InstanceTotals, error) {
+	totals, err := s.urlRepo.GetTotals(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get totals: %w", err)
+	}
+	return totals, nil
+}
+
+// GetTopLinks returns the links with the most clicks by people over the last number of days
+func (s *UsageService) GetTopLinks(ctx context.Context, days, limit int) ([]models.LinkSummary, error) {
+	since := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -(days - 1))
+	links, err := s.urlRepo.GetTopLinks(ctx, since, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get top links: %w", err)
+	}
+	return links, nil
+}
+
+// GetRecentLinks returns the most recently created links, newest first
+func (s *UsageService) GetRecentLinks(ctx context.Context, limit int) ([]models.LinkSummary, error) {
+	links, err := s.urlRepo.ListRecent(ctx, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list recent links: %w", err)
+	}
+	return links, nil
+}
+
+// hitRatio returns hits / (hits + misses), or 0 without lookups
+func hitRatio(hits, misses int64) float64 {
+	if hits+misses == 0 {
+		return 0
+	}
+	return float64(hits) / float64(hits+misses)
+}
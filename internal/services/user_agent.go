@@ -0,0 +1,111 @@
+package services
+
+import "strings"
+
+// Device types a user agent is classified as
+const (
+	DeviceDesktop = "desktop"
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceBot     = "bot"
+	DeviceUnknown = "unknown"
+)
+
+// ClientInfo is the device type, browser and operating system parsed from a user agent
+type ClientInfo struct {
+	DeviceType string
+	Browser    string
+	OS         string
+}
+
+// uaRule maps the first matching user agent token to a name. Order matters: most
+// browsers claim to be Safari and Chromium-based ones also claim to be Chrome.
+type uaRule struct {
+	token string
+	name  string
+}
+
+var browserRules = []uaRule{
+	{"edg/", "Edge"},
+	{"edge/", "Edge"},
+	{"edga/", "Edge"},
+	{"edgios/", "Edge"},
+	{"opr/", "Opera"},
+	{"opera", "Opera"},
+	{"samsungbrowser/", "Samsung Internet"},
+	{"yabrowser/", "Yandex"},
+	{"ucbrowser/", "UC Browser"},
+	{"fxios/", "Firefox"},
+	{"firefox/", "Firefox"},
+	{"crios/", "Chrome"},
+	{"chrome/", "Chrome"},
+	{"chromium/", "Chrome"},
+	{"msie ", "Internet Explorer"},
+	{"trident/", "Internet Explorer"},
+	{"version/", "Safari"}, // Safari is the only one left reporting Version/ next to Safari/
+	{"applewebkit/", "WebView"},
+}
+
+var osRules = []uaRule{
+	{"iphone", "iOS"},
+	{"ipad", "iPadOS"},
+	{"ipod", "iOS"},
+	{"android", "Android"},
+	{"windows phone", "Windows Phone"},
+	{"windows", "Windows"},
+	{"cros", "ChromeOS"},
+	{"mac os x", "macOS"},
+	{"macintosh", "macOS"},
+	{"linux", "Linux"},
+}
+
+// ParseUserAgent classifies a user agent by device type, browser and operating system.
+// It only looks for well-known tokens, so uncommon clients come out as "Other" rather
+// than misidentified; an empty or "unknown" user agent is unknown on every field.
+func ParseUserAgent(userAgent string) ClientInfo {
+	ua := strings.ToLower(strings.TrimSpace(userAgent))
+	if ua == "" || ua == "unknown" {
+		return ClientInfo{DeviceType: DeviceUnknown, Browser: "Unknown", OS: "Unknown"}
+	}
+
+	info := ClientInfo{
+		Browser: matchUARule(ua, browserRules),
+		OS:      matchUARule(ua, osRules),
+	}
+
+	switch {
+	case isBotUA(ua):
+		info.DeviceType = DeviceBot
+	case strings.Contains(ua, "ipad") || strings.Contains(ua, "tablet") ||
+		(strings.Contains(ua, "android") && !strings.Contains(ua, "mobile")):
+		// Android tablets are the Android devices that do not say "Mobile"
+		info.DeviceType = DeviceTablet
+	case strings.Contains(ua, "mobi") || strings.Contains(ua, "iphone") || strings.Contains(ua, "ipod") ||
+		strings.Contains(ua, "windows phone"):
+		info.DeviceType = DeviceMobile
+	case info.OS == "Windows" || info.OS == "macOS" || info.OS == "Linux" || info.OS == "ChromeOS":
+		info.DeviceType = DeviceDesktop
+	default:
+		info.DeviceType = DeviceUnknown
+	}
+	return info
+}
+
+// matchUARule returns the name of the first rule whose token the user agent contains
+func matchUARule(ua string, rules []uaRule) string {
+	for _, rule := range rules {
+		if strings.Contains(ua, rule.token) {
+			return rule.name
+		}
+	}
+	return "Other"
+}
+
+func containsAny(s string, tokens []string) bool {
+	for _, token := range tokens {
+		if strings.Contains(s, token) {
+			return true
+		}
+	}
+	return false
+}
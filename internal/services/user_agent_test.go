@@ -0,0 +1,105 @@
+package services
+
+import "testing"
+
+func TestParseUserAgent(t *testing.T) {
+	testCases := []struct {
+		name      string
+		userAgent string
+		expected  ClientInfo
+	}{
+		{
+			"chrome on windows",
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+			ClientInfo{DeviceDesktop, "Chrome", "Windows"},
+		},
+		{
+			"edge on windows",
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36 Edg/124.0.2478.51",
+			ClientInfo{DeviceDesktop, "Edge", "Windows"},
+		},
+		{
+			"safari on macos",
+			"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Safari/605.1.15",
+			ClientInfo{DeviceDesktop, "Safari", "macOS"},
+		},
+		{
+			"firefox on linux",
+			"Mozilla/5.0 (X11; Linux x86_64; rv:125.0) Gecko/20100101 Firefox/125.0",
+			ClientInfo{DeviceDesktop, "Firefox", "Linux"},
+		},
+		{
+			"safari on iphone",
+			"Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1",
+			ClientInfo{DeviceMobile, "Safari", "iOS"},
+		},
+		{
+			"chrome on iphone",
+			"Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/124.0.6367.88 Mobile/15E148 Safari/604.1",
+			ClientInfo{DeviceMobile, "Chrome", "iOS"},
+		},
+		{
+			"samsung internet on android phone",
+			"Mozilla/5.0 (Linux; Android 14; SM-S918B) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/24.0 Chrome/117.0.0.0 Mobile Safari/537.36",
+			ClientInfo{DeviceMobile, "Samsung Internet", "Android"},
+		},
+		{
+			"android tablet",
+			"Mozilla/5.0 (Linux; Android 13; SM-X700) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+			ClientInfo{DeviceTablet, "Chrome", "Android"},
+		},
+		{
+			"ipad",
+			"Mozilla/5.0 (iPad; CPU OS 16_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/16.6 Mobile/15E148 Safari/604.1",
+			ClientInfo{DeviceTablet, "Safari", "iPadOS"},
+		},
+		{
+			"search crawler",
+			"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			ClientInfo{DeviceBot, "Other", "Other"},
+		},
+		{
+			"http library",
+			"curl/8.4.0",
+			ClientInfo{DeviceBot, "Other", "Other"},
+		},
+		{
+			"unknown",
+			"unknown",
+			ClientInfo{DeviceUnknown, "Unknown", "Unknown"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := ParseUserAgent(tc.userAgent); got != tc.expected {
+				t.Errorf("ParseUserAgent(%q) = %+v; expected %+v", tc.userAgent, got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestIsBot(t *testing.T) {
+	testCases := []struct {
+		userAgent string
+		expected  bool
+	}{
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36", false},
+		{"Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148 [Pinterest/iOS]", false},
+		{"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", true},
+		{"facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)", true},
+		{"Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)", true},
+		{"WhatsApp/2.23.20.0", true},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) HeadlessChrome/124.0.0.0 Safari/537.36", true},
+		{"python-requests/2.31.0", true},
+		{"curl/8.4.0", true},
+		{"", true},
+		{"unknown", true},
+	}
+
+	for _, tc := range testCases {
+		if got := IsBot(tc.userAgent); got != tc.expected {
+			t.Errorf("IsBot(%q) = %v; expected %v", tc.userAgent, got, tc.expected)
+		}
+	}
+}
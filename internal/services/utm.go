@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	apperrors "github.com/alexnthnz/url-shortener/internal/errors"
+	"github.com/alexnthnz/url-shortener/internal/models"
+)
+
+// maxUTMValueLength bounds each UTM parameter, like the column storing it
+const maxUTMValueLength = 200
+
+// validateUTMParams checks the UTM parameters of a link, returning nil for a request
+// that sets none of them
+func validateUTMParams(utm *models.UTMParams) (*models.UTMParams, error) {
+	if utm == nil || *utm == (models.UTMParams{}) {
+		return nil, nil
+	}
+	for name, value := range utmValues(utm) {
+		if len(value) > maxUTMValueLength {
+			return nil, apperrors.Errorf(apperrors.ErrInvalid, "invalid UTM parameters: %s is longer than %d characters", name, maxUTMValueLength)
+		}
+		for i := 0; i < len(value); i++ {
+			if value[i] < 0x20 || value[i] == 0x7f {
+				return nil, apperrors.Errorf(apperrors.ErrInvalid, "invalid UTM parameters: %s contains control characters", name)
+			}
+		}
+	}
+	return utm, nil
+}
+
+// SetUTMParams replaces the UTM parameters of a link; nil or an empty set removes them
+func (s *URLService) SetUTMParams(ctx context.Context, shortCode string, utm *models.UTMParams) error {
+	utm, err := validateUTMParams(utm)
+	if err != nil {
+		return err
+	}
+	if err := s.urlRepo.SetUTMParams(ctx, shortCode, utm); err != nil {
+		return fmt.Errorf("failed to update UTM parameters: %w", err)
+	}
+	if err := s.cache.Delete(ctx, utmCacheKey(shortCode)); err != nil {
+		s.logger.Warnf("Failed to invalidate UTM parameters cache: %v", err)
+	}
+	return nil
+}
+
+// utmParams returns the UTM parameters of a link, cached next to its destination
+func (s *URLService) utmParams(ctx context.Context, shortCode string) (*models.UTMParams, error) {
+	var utm *models.UTMParams
+	if cached, err := s.cache.Get(ctx, utmCacheKey(shortCode)); err == nil {
+		if err := json.Unmarshal([]byte(cached), &utm); err == nil {
+			return utm, nil
+		}
+	}
+
+	utm, err := s.urlRepo.GetUTMParams(ctx, shortCode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get UTM parameters: %w", err)
+	}
+
+	encoded, _ := json.Marshal(utm)
+	if err := s.cache.Set(ctx, utmCacheKey(shortCode), string(encoded)); err != nil {
+		s.logger.Warnf("Failed to cache UTM parameters: %v", err)
+	}
+	return utm, nil
+}
+
+// appendUTMParams adds UTM parameters to the query of a destination, keeping those it
+// already sets and leaving the rest of the query as it is. Destinations that do not
+// parse are returned unchanged.
+func appendUTMParams(destination string, utm *models.UTMParams) string {
+	if utm == nil {
+		return destination
+	}
+	base, fragment, hasFragment := splitFragment(destination)
+	target, err := url.Parse(base)
+	if err != nil {
+		return destination
+	}
+
+	existing := target.Query()
+	added := url.Values{}
+	for name, value := range utmValues(utm) {
+		if value != "" && !existing.Has(name) {
+			added.Set(name, value)
+		}
+	}
+	if len(added) == 0 {
+		return destination
+	}
+
+	if target.RawQuery != "" {
+		target.RawQuery += "&"
+	}
+	target.RawQuery += added.Encode()
+	return joinFragment(target.String(), fragment, hasFragment)
+}
+
+// utmValues returns the UTM parameters by query parameter name
+func utmValues(utm *models.UTMParams) map[string]string {
+	return map[string]string{
+		"utm_source":   utm.Source,
+		"utm_medium":   utm.Medium,
+		"utm_campaign": utm.Campaign,
+		"utm_term":     utm.Term,
+		"utm_content":  utm.Content,
+	}
+}
+
+// utmCacheKey is the cache key of a link's UTM parameters
+func utmCacheKey(shortCode string) string {
+	return "utm:" + shortCode
+}
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alexnthnz/url-shortener/internal/models"
+)
+
+func TestAppendUTMParams(t *testing.T) {
+	utm := &models.UTMParams{Source: "newsletter", Medium: "email", Campaign: "spring sale"}
+
+	tests := []struct {
+		name        string
+		destination string
+		expected    string
+	}{
+		{"no query", "https://example.com/page", "https://example.com/page?utm_campaign=spring+sale&utm_medium=email&utm_source=newsletter"},
+		{"keeps query order", "https://example.com/?z=1&a=2", "https://example.com/?z=1&a=2&utm_campaign=spring+sale&utm_medium=email&utm_source=newsletter"},
+		{"destination wins", "https://example.com/?utm_source=partner", "https://example.com/?utm_source=partner&utm_campaign=spring+sale&utm_medium=email"},
+		{"keeps fragment", "https://example.com/docs#install", "https://example.com/docs?utm_campaign=spring+sale&utm_medium=email&utm_source=newsletter#install"},
+		{"keeps placeholders", "https://example.com/?id={click_id}", "https://example.com/?id={click_id}&utm_campaign=spring+sale&utm_medium=email&utm_source=newsletter"},
+	}
+
+	for _, test := range tests {
+		if got := appendUTMParams(test.destination, utm); got != test.expected {
+			t.Errorf("%s: appendUTMParams(%s) = %s; expected %s", test.name, test.destination, got, test.expected)
+		}
+	}
+
+	if got := appendUTMParams("https://example.com/", nil); got != "https://example.com/" {
+		t.Errorf("appendUTMParams() without parameters = %s; expected the destination unchanged", got)
+	}
+}
+
+func TestValidateUTMParams(t *testing.T) {
+	if utm, err := validateUTMParams(&models.UTMParams{}); utm != nil || err != nil {
+		t.Errorf("validateUTMParams(empty) = %v, %v; expected no parameters", utm, err)
+	}
+	if _, err := validateUTMParams(&models.UTMParams{Source: strings.Repeat("a", maxUTMValueLength+1)}); err == nil {
+		t.Error("validateUTMParams() should reject values over the length limit")
+	}
+	if _, err := validateUTMParams(&models.UTMParams{Campaign: "spring\nsale"}); err == nil {
+		t.Error("validateUTMParams() should reject control characters")
+	}
+}
@@ -0,0 +1,383 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	apperrors "github.com/alexnthnz/url-shortener/internal/errors"
+	"github.com/alexnthnz/url-shortener/internal/models"
+	"github.com/alexnthnz/url-shortener/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	minAggregationWindow = 10   // seconds
+	maxAggregationWindow = 3600 // seconds
+	topDimensionsLimit   = 5
+)
+
+// clickWindow accumulates clicks for one subscription until its window closes
+type clickWindow struct {
+	start      time.Time
+	total      int64
+	shortCodes map[string]int64
+	userAgents map[string]int64
+}
+
+type WebhookService struct {
+	webhookRepo     *repository.WebhookRepository
+	urlRepo         repository.URLStore
+	logger          *logrus.Logger
+	client          *http.Client
+	events          chan AnalyticsEvent
+	refreshInterval time.Duration
+
+	mu       sync.Mutex
+	webhooks []*models.Webhook
+	windows  map[int64]*clickWindow
+}
+
+func NewWebhookService(webhookRepo *repository.WebhookRepository, urlRepo repository.URLStore, logger *logrus.Logger) *WebhookService {
+	service := &WebhookService{
+		webhookRepo:     webhookRepo,
+		urlRepo:         urlRepo,
+		logger:          logger,
+		client:          newPublicHTTPClient(10 * time.Second),
+		events:          make(chan AnalyticsEvent, 10000),
+		refreshInterval: time.Minute,
+		windows:         make(map[int64]*clickWindow),
+	}
+
+	service.loadWebhooks()
+
+	// Start delivery loop
+	go service.run()
+
+	return service
+}
+
+// CreateWebhook registers a new click webhook subscription
+func (s *WebhookService) CreateWebhook(ctx context.Context, req *models.WebhookRequest) (*models.Webhook, error) {
+	if err := s.validateTargetURL(ctx, req.TargetURL); err != nil {
+		return nil, apperrors.Errorf(apperrors.ErrInvalid, "invalid webhook: %w", err)
+	}
+
+	if req.AggregationWindow != 0 &&
+		(req.AggregationWindow < minAggregationWindow || req.AggregationWindow > maxAggregationWindow) {
+		return nil, apperrors.Errorf(apperrors.ErrInvalid, "invalid webhook: aggregation window must be 0 or between %d and %d seconds",
+			minAggregationWindow, maxAggregationWindow)
+	}
+
+	if req.ShortCode != "" {
+		exists, err := s.urlRepo.Exists(ctx, req.ShortCode)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check short code existence: %w", err)
+		}
+		if !exists {
+			return nil, apperrors.Errorf(apperrors.ErrNotFound, "URL not found")
+		}
+	}
+
+	secret, err := generateSecret()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+
+	webhook := &models.Webhook{
+		TargetURL:         req.TargetURL,
+		ShortCode:         req.ShortCode,
+		Secret:            secret,
+		AggregationWindow: req.AggregationWindow,
+	}
+
+	if err := s.webhookRepo.Create(webhook); err != nil {
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
+	}
+
+	s.loadWebhooks()
+	return webhook, nil
+}
+
+// ListWebhooks returns all webhook subscriptions with their secrets redacted
+func (s *WebhookService) ListWebhooks() ([]*models.Webhook, error) {
+	webhooks, err := s.webhookRepo.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+	for _, webhook := range webhooks {
+		webhook.Secret = ""
+	}
+	return webhooks, nil
+}
+
+// DeleteWebhook removes a webhook subscription
+func (s *WebhookService) DeleteWebhook(id int64) error {
+	deleted, err := s.webhookRepo.Delete(id)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	if !deleted {
+		return apperrors.Errorf(apperrors.ErrNotFound, "webhook not found")
+	}
+
+	s.loadWebhooks()
+	return nil
+}
+
+// NotifyClick queues a click for webhook delivery (non-blocking)
+func (s *WebhookService) NotifyClick(event AnalyticsEvent) {
+	select {
+	case s.events <- event:
+	default:
+		s.logger.Warn("Webhook queue full, dropping click event")
+	}
+}
+
+// QueueDepth returns the click events waiting for webhook delivery
+func (s *WebhookService) QueueDepth() int {
+	return len(s.events)
+}
+
+// NotifyTakedown delivers a takedown event to the subscriptions of the link and to
+// instance-wide subscriptions. Link subscriptions are the closest thing to an owner.
+func (s *WebhookService) NotifyTakedown(event models.WebhookTakedownEvent) {
+	s.notifyLink(event.ShortCode, event)
+}
+
+// NotifyGoal delivers a click goal event to the subscriptions of the link and to
+// instance-wide subscriptions
+func (s *WebhookService) NotifyGoal(event models.WebhookGoalEvent) {
+	s.notifyLink(event.ShortCode, event)
+}
+
+// NotifyReport delivers a report.ready event to instance-wide subscriptions; reports cover
+// campaigns rather than single links
+func (s *WebhookService) NotifyReport(event models.WebhookReportEvent) {
+	s.notifyLink("", event)
+}
+
+// NotifyLoginLockout delivers a login.locked event to instance-wide subscriptions, which
+// operators set up
+func (s *WebhookService) NotifyLoginLockout(event models.WebhookLoginLockoutEvent) {
+	s.notifyLink("", event)
+}
+
+// NotifyLoginFailures delivers a login.failures event to instance-wide subscriptions
+func (s *WebhookService) NotifyLoginFailures(event models.WebhookLoginFailuresEvent) {
+	s.notifyLink("", event)
+}
+
+// notifyLink delivers a payload about a link to its subscriptions and the instance-wide
+// ones; an empty short code reaches only the instance-wide ones
+func (s *WebhookService) notifyLink(shortCode string, payload interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, webhook := range s.webhooks {
+		if webhook.ShortCode != "" && webhook.ShortCode != shortCode {
+			continue
+		}
+		go s.deliver(webhook, payload)
+	}
+}
+
+// run consumes click events, closes aggregation windows and refreshes subscriptions
+func (s *WebhookService) run() {
+	flushTicker := time.NewTicker(time.Second)
+	defer flushTicker.Stop()
+	refreshTicker := time.NewTicker(s.refreshInterval)
+	defer refreshTicker.Stop()
+
+	for {
+		select {
+		case event := <-s.events:
+			s.handleClick(event)
+		case now := <-flushTicker.C:
+			s.flushWindows(now)
+		case <-refreshTicker.C:
+			// Pick up subscriptions created by other instances
+			s.loadWebhooks()
+		}
+	}
+}
+
+// loadWebhooks refreshes the in-memory subscription list
+func (s *WebhookService) loadWebhooks() {
+	webhooks, err := s.webhookRepo.List()
+	if err != nil {
+		s.logger.Errorf("Failed to load webhooks: %v", err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	active := make(map[int64]bool, len(webhooks))
+	for _, webhook := range webhooks {
+		active[webhook.ID] = true
+	}
+	// Discard windows of deleted subscriptions
+	for id := range s.windows {
+		if !active[id] {
+			delete(s.windows, id)
+		}
+	}
+	s.webhooks = webhooks
+}
+
+// handleClick delivers or aggregates a click for every matching subscription
+func (s *WebhookService) handleClick(event AnalyticsEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, webhook := range s.webhooks {
+		if webhook.ShortCode != "" && webhook.ShortCode != event.ShortCode {
+			continue
+		}
+
+		if webhook.AggregationWindow == 0 {
+			go s.deliver(webhook, models.WebhookClickEvent{
+				Event:         "click",
+				SchemaVersion: EventSchemaVersion("click"),
+				ClickID:       event.ClickID,
+				ShortCode:     event.ShortCode,
+				ClickedAt:     event.Timestamp,
+				UserAgent:     event.UserAgent,
+			})
+			continue
+		}
+
+		window := s.windows[webhook.ID]
+		if window == nil {
+			window = &clickWindow{
+				start:      event.Timestamp.Truncate(time.Duration(webhook.AggregationWindow) * time.Second),
+				shortCodes: make(map[string]int64),
+				userAgents: make(map[string]int64),
+			}
+			s.windows[webhook.ID] = window
+		}
+		window.total++
+		window.shortCodes[event.ShortCode]++
+		window.userAgents[event.UserAgent]++
+	}
+}
+
+// flushWindows delivers every aggregation window that has closed
+func (s *WebhookService) flushWindows(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, webhook := range s.webhooks {
+		window := s.windows[webhook.ID]
+		if window == nil {
+			continue
+		}
+
+		end := window.start.Add(time.Duration(webhook.AggregationWindow) * time.Second)
+		if now.Before(end) {
+			continue
+		}
+
+		delete(s.windows, webhook.ID)
+		go s.deliver(webhook, models.WebhookClickWindow{
+			Event:         "click.aggregate",
+			SchemaVersion: EventSchemaVersion("click.aggregate"),
+			WindowStart:   window.start,
+			WindowEnd:     end,
+			TotalClicks:   window.total,
+			TopShortCodes: topDimensions(window.shortCodes, topDimensionsLimit),
+			TopUserAgents: topDimensions(window.userAgents, topDimensionsLimit),
+		})
+	}
+}
+
+// deliver posts a signed payload to the subscription target
+func (s *WebhookService) deliver(webhook *models.Webhook, payload interface{}) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		s.logger.Errorf("Failed to encode webhook payload: %v", err)
+		return
+	}
+
+	req, err := http.NewRequest(http.MethodPost, webhook.TargetURL, bytes.NewReader(body))
+	if err != nil {
+		s.logger.Errorf("Failed to build webhook request: %v", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Signature", "sha256="+signPayload(webhook.Secret, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		s.logger.Warnf("Webhook %d delivery failed: %v", webhook.ID, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		s.logger.Warnf("Webhook %d delivery rejected with status %d", webhook.ID, resp.StatusCode)
+	}
+}
+
+// validateTargetURL checks that a webhook target is an absolute HTTP(S) URL of a public
+// host, so subscriptions cannot probe the internal network
+func (s *WebhookService) validateTargetURL(ctx context.Context, rawURL string) error {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("malformed target URL")
+	}
+	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
+		return fmt.Errorf("target URL must use HTTP or HTTPS")
+	}
+	if parsedURL.Hostname() == "" {
+		return fmt.Errorf("target URL must have a valid host")
+	}
+	return checkPublicHost(ctx, parsedURL.Hostname())
+}
+
+// topDimensions returns the highest counts, ties broken by value for stable output
+func topDimensions(counts map[string]int64, limit int) []models.DimensionCount {
+	dimensions := make([]models.DimensionCount, 0, len(counts))
+	for value, count := range counts {
+		dimensions = append(dimensions, models.DimensionCount{Value: value, Count: count})
+	}
+
+	sort.Slice(dimensions, func(i, j int) bool {
+		if dimensions[i].Count != dimensions[j].Count {
+			return dimensions[i].Count > dimensions[j].Count
+		}
+		return dimensions[i].Value < dimensions[j].Value
+	})
+
+	if len(dimensions) > limit {
+		dimensions = dimensions[:limit]
+	}
+	return dimensions
+}
+
+// signPayload computes the hex HMAC-SHA256 of a webhook body
+func signPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// generateSecret returns a random hex-encoded signing secret
+func generateSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alexnthnz/url-shortener/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+func TestTopDimensions(t *testing.T) {
+	counts := map[string]int64{
+		"abc": 5,
+		"def": 9,
+		"ghi": 5,
+		"jkl": 1,
+	}
+
+	result := topDimensions(counts, 3)
+	expected := []models.DimensionCount{
+		{Value: "def", Count: 9},
+		{Value: "abc", Count: 5},
+		{Value: "ghi", Count: 5},
+	}
+
+	if len(result) != len(expected) {
+		t.Fatalf("topDimensions returned %d entries; expected %d", len(result), len(expected))
+	}
+	for i := range expected {
+		if result[i] != expected[i] {
+			t.Errorf("topDimensions()[%d] = %+v; expected %+v", i, result[i], expected[i])
+		}
+	}
+}
+
+func TestClickWindowAggregation(t *testing.T) {
+	service := &WebhookService{
+		logger: logrus.New(),
+		webhooks: []*models.Webhook{
+			{ID: 1, AggregationWindow: 60},
+			{ID: 2, ShortCode: "other", AggregationWindow: 60},
+		},
+		windows: make(map[int64]*clickWindow),
+	}
+
+	clickedAt := time.Date(2024, 1, 15, 10, 30, 15, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		service.handleClick(AnalyticsEvent{ShortCode: "abc", UserAgent: "Mozilla/5.0", Timestamp: clickedAt})
+	}
+
+	window := service.windows[1]
+	if window == nil {
+		t.Fatal("expected an open window for subscription 1")
+	}
+	if window.total != 3 {
+		t.Errorf("Expected 3 clicks in window, got %d", window.total)
+	}
+	if !window.start.Equal(clickedAt.Truncate(time.Minute)) {
+		t.Errorf("Expected window to start at %v, got %v", clickedAt.Truncate(time.Minute), window.start)
+	}
+	if service.windows[2] != nil {
+		t.Error("Expected no window for subscription filtered to another short code")
+	}
+}
+
+func TestValidateTargetURL(t *testing.T) {
+	service := &WebhookService{}
+
+	tests := []struct {
+		url   string
+		valid bool
+	}{
+		{"https://203.0.114.10/hooks", true},
+		{"http://[2606:4700::1111]:8080/hooks", true},
+		{"ftp://203.0.114.10/hooks", false},
+		{"http://127.0.0.1:6379/", false},
+		{"http://10.0.0.5/hooks", false},
+		{"http://192.168.1.1/", false},
+		{"http://169.254.169.254/latest/meta-data/", false},
+		{"http://100.64.0.1/", false},
+		{"http://0.0.0.0/", false},
+		{"http://[::1]/", false},
+		{"http://[fd00:ec2::254]/", false},
+		{"http://[::ffff:127.0.0.1]/", false},
+	}
+	for _, test := range tests {
+		err := service.validateTargetURL(context.Background(), test.url)
+		if valid := err == nil; valid != test.valid {
+			t.Errorf("validateTargetURL(%s) = %v; expected valid=%v", test.url, err, test.valid)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	apperrors "github.com/alexnthnz/url-shortener/internal/errors"
+	"github.com/alexnthnz/url-shortener/internal/repository"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	widgetDays   = 30
+	widgetWidth  = 240
+	widgetHeight = 64
+	sparkHeight  = 32
+)
+
+type WidgetService struct {
+	analyticsRepo repository.AnalyticsStore
+	urlRepo       repository.URLStore
+	signingKey    []byte
+	logger        *logrus.Logger
+}
+
+func NewWidgetService(analyticsRepo repository.AnalyticsStore, urlRepo repository.URLStore, signingKey string, logger *logrus.Logger) *WidgetService {
+	return &WidgetService{
+		analyticsRepo: analyticsRepo,
+		urlRepo:       urlRepo,
+		signingKey:    []byte(signingKey),
+		logger:        logger,
+	}
+}
+
+// Enabled reports whether a signing key is configured
+func (s *WidgetService) Enabled() bool {
+	return len(s.signingKey) > 0
+}
+
+// Token returns the embed token for a short code, or "" when widgets are disabled
+func (s *WidgetService) Token(shortCode string) string {
+	if !s.Enabled() {
+		return ""
+	}
+	mac := hmac.New(sha256.New, s.signingKey)
+	mac.Write([]byte("widget:" + shortCode))
+	return hex.EncodeToString(mac.Sum(nil))[:32]
+}
+
+// VerifyToken checks an embed token in constant time
+func (s *WidgetService) VerifyToken(shortCode, token string) bool {
+	if !s.Enabled() {
+		return false
+	}
+	return hmac.Equal([]byte(s.Token(shortCode)), []byte(token))
+}
+
+// RenderSVG renders the click sparkline and total count for a short code
+func (s *WidgetService) RenderSVG(ctx context.Context, shortCode string) (string, error) {
+	stats, err := s.urlRepo.GetStats(ctx, shortCode)
+	if err != nil {
+		return "", fmt.Errorf("failed to get URL stats: %w", err)
+	}
+	if stats == nil {
+		return "", apperrors.Errorf(apperrors.ErrNotFound, "URL not found")
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	since := today.AddDate(0, 0, -(widgetDays - 1))
+	days, err := s.analyticsRepo.GetDailyClicks(ctx, shortCode, since)
+	if err != nil {
+		return "", fmt.Errorf("failed to get daily clicks: %w", err)
+	}
+
+	// Fill in days without clicks
+	series := make([]int64, widgetDays)
+	for _, day := range days {
+		index := int(day.Day.UTC().Sub(since).Hours() / 24)
+		if index >= 0 && index < widgetDays {
+			series[index] = day.Clicks
+		}
+	}
+
+	return renderSparkline(stats.ShortCode, stats.ClickCount, series), nil
+}
+
+// renderSparkline draws the widget as a standalone SVG document
+func renderSparkline(shortCode string, total int64, series []int64) string {
+	var peak int64 = 1
+	for _, clicks := range series {
+		if clicks > peak {
+			peak = clicks
+		}
+	}
+
+	step := float64(widgetWidth-16) / float64(len(series)-1)
+	points := make([]string, len(series))
+	for i, clicks := range series {
+		x := 8 + float64(i)*step
+		y := float64(widgetHeight-6) - float64(clicks)/float64(peak)*sparkHeight
+		points[i] = fmt.Sprintf("%.1f,%.1f", x, y)
+	}
+
+	var svg strings.Builder
+	fmt.Fprintf(&svg, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`,
+		widgetWidth, widgetHeight, widgetWidth, widgetHeight)
+	svg.WriteString(`<rect width="100%" height="100%" rx="6" fill="#ffffff" stroke="#e5e7eb"/>`)
+	fmt.Fprintf(&svg, `<text x="8" y="18" font-family="sans-serif" font-size="12" fill="#6b7280">/%s</text>`,
+		html.EscapeString(shortCode))
+	fmt.Fprintf(&svg, `<text x="%d" y="18" font-family="sans-serif" font-size="12" font-weight="bold" fill="#111827" text-anchor="end">%d clicks</text>`,
+		widgetWidth-8, total)
+	fmt.Fprintf(&svg, `<polyline fill="none" stroke="#2563eb" stroke-width="2" points="%s"/>`,
+		strings.Join(points, " "))
+	svg.WriteString(`</svg>`)
+
+	return svg.String()
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// defaultWordlist holds short, concrete words that are spelled the way they sound; words
+// with common homophones (sea, tea, two) or several spellings (axe, dew) are left out. At
+// three letters, two words and two digits fit the 10 characters a short code can hold.
+const defaultWordlist = `
+ace ant ape arm art bag bat bay bed bee big bin box bud bug bun bus cab cap car cat cob
+cod cog cot cow cub cup dam day den dig dip dog dot dry ear eel egg elk elm emu fan fax
+fig fin fir fit fog fox fun gap gas gel gem gum hat hay hem hen hip hog hot hub hug hut
+ice icy ink jam jar jaw jet jog jug key kid kit lab lap leg lid lip log lot map mat mix
+mop mud mug nap net new nod nut oak oar oat odd old orb owl pad pan paw pea peg pen pie
+pig pin pit pod pot pub pug ram rat raw ray rib rim rod rug run sap saw shy sip six sky
+spa sub tab tag tan tap ten tin tip top toy tub tug urn van vat vet wax web wet wig wok
+yak yam zap zen zip zoo
+`
+
+// minWordlistSize keeps word codes from colliding after a handful of links
+const minWordlistSize = 10
+
+// WordCodeGenerator builds memorable codes such as red-fox-42 from a wordlist, for links
+// that people type from a slide or a whiteboard
+type WordCodeGenerator struct {
+	words     []string
+	count     int
+	digits    int
+	separator string
+}
+
+// NewWordCodeGenerator returns a generator of codes made of count words and a number of
+// digits, joined by separator. Every code it can build must fit in a short code.
+func NewWordCodeGenerator(words []string, count, digits int, separator string) (*WordCodeGenerator, error) {
+	if count < 1 || count > 5 {
+		return nil, fmt.Errorf("word codes must have between 1 and 5 words")
+	}
+	if digits < 0 || digits > 4 {
+		return nil, fmt.Errorf("word codes must end in between 0 and 4 digits")
+	}
+	if separator != "-" && separator != "_" {
+		return nil, fmt.Errorf("word code separator must be - or _")
+	}
+
+	seen := make(map[string]bool, len(words))
+	unique := make([]string, 0, len(words))
+	longest := 0
+	for _, word := range words {
+		if word == "" || strings.Trim(word, "abcdefghijklmnopqrstuvwxyz") != "" {
+			return nil, fmt.Errorf("invalid word %q: words must be lowercase ASCII letters", word)
+		}
+		if seen[word] {
+			continue
+		}
+		seen[word] = true
+		unique = append(unique, word)
+		longest = max(longest, len(word))
+	}
+	if len(unique) < minWordlistSize {
+		return nil, fmt.Errorf("wordlist has %d distinct words, at least %d are needed", len(unique), minWordlistSize)
+	}
+
+	parts := count
+	if digits > 0 {
+		parts++
+	}
+	if length := count*longest + digits + (parts-1)*len(separator); length > maxShortCodeLength {
+		return nil, fmt.Errorf("word codes can be %d characters long, more than the %d a short code holds: use shorter words, fewer words or fewer digits",
+			length, maxShortCodeLength)
+	}
+
+	return &WordCodeGenerator{words: unique, count: count, digits: digits, separator: separator}, nil
+}
+
+// LoadWordlist reads a wordlist file of words separated by whitespace, where lines starting
+// with # are comments. An empty path returns the built-in list.
+func LoadWordlist(path string) ([]string, error) {
+	if path == "" {
+		return strings.Fields(defaultWordlist), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read wordlist: %w", err)
+	}
+	var words []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		words = append(words, strings.Fields(strings.ToLower(line))...)
+	}
+	return words, nil
+}
+
+// Generate returns a random code; codes are not checked against existing links
+func (g *WordCodeGenerator) Generate() string {
+	parts := make([]string, 0, g.count+1)
+	for i := 0; i < g.count; i++ {
+		parts = append(parts, g.words[rand.Intn(len(g.words))])
+	}
+	if g.digits > 0 {
+		number := strconv.Itoa(rand.Intn(int(pow(10, g.digits))))
+		parts = append(parts, strings.Repeat("0", g.digits-len(number))+number)
+	}
+	return strings.Join(parts, g.separator)
+}
+
+// Capacity returns how many distinct codes of the given length the generator can build
+func (g *WordCodeGenerator) Capacity(length int) int64 {
+	parts := g.count
+	if g.digits > 0 {
+		parts++
+	}
+	letters := length - g.digits - (parts-1)*len(g.separator)
+	if letters < g.count {
+		return 0
+	}
+
+	// combinations[n] counts the sequences of the words picked so far spelling n letters
+	combinations := make([]int64, letters+1)
+	combinations[0] = 1
+	for i := 0; i < g.count; i++ {
+		next := make([]int64, letters+1)
+		for n, count := range combinations {
+			if count == 0 {
+				continue
+			}
+			for _, word := range g.words {
+				if n+len(word) <= letters {
+					next[n+len(word)] += count
+				}
+			}
+		}
+		combinations = next
+	}
+	return combinations[letters] * pow(10, g.digits)
+}
@@ -0,0 +1,236 @@
+// Package client is a Go client for the URL shortener API.
+//
+//	c := client.New("https://sho.rt", client.WithSigningKey("ci", secret))
+//	link, err := c.Shorten(ctx, client.ShortenRequest{URL: "https://example.com/long"})
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/alexnthnz/url-shortener/pkg/signing"
+)
+
+// Client calls the URL shortener API. It is safe for concurrent use.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	adminToken string
+	keyID      string
+	secret     []byte
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient replaces the default HTTP client, which times out after 10 seconds
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) { c.httpClient = httpClient }
+}
+
+// WithAdminToken sends the admin bearer token, required by the admin API
+func WithAdminToken(token string) Option {
+	return func(c *Client) { c.adminToken = token }
+}
+
+// WithSigningKey signs every request with the named key. Each signature carries a
+// timestamp and a fresh nonce, so servers requiring signatures reject replays.
+func WithSigningKey(keyID string, secret []byte) Option {
+	return func(c *Client) { c.keyID, c.secret = keyID, secret }
+}
+
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// ShortenRequest is the payload of Shorten
+type ShortenRequest struct {
+	URL             string `json:"url"`
+	CustomAlias     string `json:"custom_alias,omitempty"`
+	CodeStyle       string `json:"code_style,omitempty"`
+	PathPassthrough bool   `json:"path_passthrough,omitempty"`
+	MaxClicks       *int64 `json:"max_clicks,omitempty"`
+	// Ephemeral links live only in the server's cache for TTLSeconds and keep no statistics
+	Ephemeral  bool  `json:"ephemeral,omitempty"`
+	TTLSeconds int64 `json:"ttl_seconds,omitempty"`
+	// Domain puts the link on a custom domain of the signing key
+	Domain string `json:"domain,omitempty"`
+	// Profile "sms" keeps the short URL short and safe for text messages
+	Profile string `json:"profile,omitempty"`
+	// ActivateAt schedules the link; it answers 404 until then
+	ActivateAt *time.Time `json:"activate_at,omitempty"`
+	// Deterministic derives the code from the destination, so repeating the request
+	// returns the same link
+	Deterministic bool `json:"deterministic,omitempty"`
+}
+
+// ShortenResponse describes a created short link
+type ShortenResponse struct {
+	ShortCode   string     `json:"short_code"`
+	ShortURL    string     `json:"short_url"`
+	QRURL       string     `json:"qr_url,omitempty"`
+	Domain      string     `json:"domain,omitempty"`
+	NumericCode string     `json:"numeric_code,omitempty"`
+	NumericURL  string     `json:"numeric_url,omitempty"`
+	OriginalURL string     `json:"original_url"`
+	WidgetToken string     `json:"widget_token,omitempty"`
+	MaxClicks   *int64     `json:"max_clicks,omitempty"`
+	Ephemeral   bool       `json:"ephemeral,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	ActivateAt  *time.Time `json:"activate_at,omitempty"`
+}
+
+// URLStats holds the statistics of a short link
+type URLStats struct {
+	ShortCode   string    `json:"short_code"`
+	OriginalURL string    `json:"original_url"`
+	ClickCount  int64     `json:"click_count"`
+	BotClicks   int64     `json:"bot_clicks"`
+	QRScans     int64     `json:"qr_scans"`
+	CreatedAt   time.Time `json:"created_at"`
+	Aliases     []string  `json:"aliases,omitempty"`
+}
+
+// URLInfo describes a short link
+type URLInfo struct {
+	ShortCode       string     `json:"short_code"`
+	CanonicalCode   string     `json:"canonical_code,omitempty"`
+	OriginalURL     string     `json:"original_url"`
+	CreatedAt       time.Time  `json:"created_at"`
+	ExpiresAt       *time.Time `json:"expires_at,omitempty"`
+	ActivateAt      *time.Time `json:"activate_at,omitempty"`
+	Active          bool       `json:"active"`
+	PathPassthrough bool       `json:"path_passthrough"`
+	ClickCount      int64      `json:"click_count"`
+	MaxClicks       *int64     `json:"max_clicks,omitempty"`
+	ClicksRemaining *int64     `json:"clicks_remaining,omitempty"`
+	Disabled        bool       `json:"disabled,omitempty"`
+	Ephemeral       bool       `json:"ephemeral,omitempty"`
+	NumericCode     string     `json:"numeric_code,omitempty"`
+}
+
+// Error is returned for responses with a non-2xx status
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("url shortener API returned %d: %s", e.StatusCode, e.Message)
+}
+
+// Shorten creates a short link
+func (c *Client) Shorten(ctx context.Context, req ShortenRequest) (*ShortenResponse, error) {
+	var resp ShortenResponse
+	if err := c.Do(ctx, http.MethodPost, "/api/v1/shorten", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Stats returns the statistics of a short link
+func (c *Client) Stats(ctx context.Context, shortCode string) (*URLStats, error) {
+	var stats URLStats
+	if err := c.Do(ctx, http.MethodGet, "/api/v1/urls/"+url.PathEscape(shortCode)+"/stats", nil, &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// Info returns a short link
+func (c *Client) Info(ctx context.Context, shortCode string) (*URLInfo, error) {
+	var info URLInfo
+	if err := c.Do(ctx, http.MethodGet, "/api/v1/urls/"+url.PathEscape(shortCode), nil, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// Delete removes a short link for good, with its statistics; it needs the admin token
+func (c *Client) Delete(ctx context.Context, shortCode string) error {
+	return c.Do(ctx, http.MethodDelete, "/api/v1/admin/urls/"+url.PathEscape(shortCode), nil, nil)
+}
+
+// Do sends a request to any API path, encoding in as JSON and decoding the response into
+// out. Either may be nil.
+func (c *Client) Do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.adminToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.adminToken)
+	}
+	if c.secret != nil {
+		if err := c.sign(req, body); err != nil {
+			return err
+		}
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&apiErr)
+		return &Error{StatusCode: resp.StatusCode, Message: apiErr.Error}
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// sign adds the signature headers for the request
+func (c *Client) sign(req *http.Request, body []byte) error {
+	nonceBytes := make([]byte, 16)
+	if _, err := rand.Read(nonceBytes); err != nil {
+		return err
+	}
+	nonce := hex.EncodeToString(nonceBytes)
+	timestamp := time.Now()
+
+	req.Header.Set(signing.HeaderKeyID, c.keyID)
+	req.Header.Set(signing.HeaderTimestamp, fmt.Sprint(timestamp.Unix()))
+	req.Header.Set(signing.HeaderNonce, nonce)
+	req.Header.Set(signing.HeaderSignature,
+		signing.Sign(c.secret, req.Method, req.URL.RequestURI(), timestamp, nonce, body))
+	return nil
+}